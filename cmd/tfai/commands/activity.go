@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// NewActivityCmd constructs the `tfai activity` command, which lists the
+// file-producing actions recorded for a workspace.
+func NewActivityCmd() *cobra.Command {
+	var dir string
+	var limit int
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "activity",
+		Short: "List the generations that wrote files to a workspace",
+		Long: `List the actions that wrote files to a workspace, newest first, with their
+summary and files. Every generation that writes files is recorded, whether
+it ran in the server or through tfai generate or tfai upgrade; questions and
+answers are not. The activity is stored in the conversation history database
+(TFAI_HISTORY_DB, default ~/.tfai/history.db), which keeps the newest 200
+entries of each workspace.
+
+Examples:
+  tfai activity
+  tfai activity --dir ./infra --limit 5
+  tfai activity --dir ./infra --format json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if limit <= 0 {
+				return fmt.Errorf("activity: --limit must be positive")
+			}
+			if format != "text" && format != "json" {
+				return fmt.Errorf("activity: unknown --format %q (want text or json)", format)
+			}
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("activity: failed to resolve workspace directory: %w", err)
+			}
+
+			hs, err := openHistoryDB(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("activity: %w", err)
+			}
+			defer func() { _ = hs.Close() }()
+
+			entries, err := hs.Activity(cmd.Context(), absDir, 0, limit)
+			if err != nil {
+				return fmt.Errorf("activity: %w", err)
+			}
+			if format == "json" {
+				return writeActivityJSON(cmd.OutOrStdout(), entries)
+			}
+			printActivity(cmd.OutOrStdout(), absDir, entries)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", ".", "Workspace directory")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Number of entries to list")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+
+	return cmd
+}
+
+// printActivity writes entries as one paragraph each: the time, summary,
+// file count and model, then the files and any conflict resolutions.
+func printActivity(w io.Writer, dir string, entries []store.Activity) {
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "No activity recorded for %s.\n", dir)
+		return
+	}
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		detail := fmt.Sprintf("%d files", len(e.Files))
+		if len(e.Files) == 1 {
+			detail = "1 file"
+		}
+		if e.Model != "" {
+			detail += ", " + e.Model
+		}
+		fmt.Fprintf(w, "%s  %s (%s)\n", e.CreatedAt.Format("2006-01-02 15:04"), e.Summary, detail)
+		if len(e.Files) > 0 {
+			fmt.Fprintf(w, "  %s\n", strings.Join(e.Files, ", "))
+		}
+		if len(e.Conflicts) > 0 {
+			paths := slices.Sorted(maps.Keys(e.Conflicts))
+			for i, p := range paths {
+				paths[i] = p + " (" + e.Conflicts[p] + ")"
+			}
+			fmt.Fprintf(w, "  conflicts: %s\n", strings.Join(paths, ", "))
+		}
+	}
+}
+
+// writeActivityJSON writes entries as an indented JSON array of
+// api.WorkspaceActivity, the element type of GET /api/workspace/activity.
+func writeActivityJSON(w io.Writer, entries []store.Activity) error {
+	out := make([]api.WorkspaceActivity, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, api.WorkspaceActivity{
+			ID:        e.ID,
+			Summary:   e.Summary,
+			Files:     e.Files,
+			Conflicts: e.Conflicts,
+			RequestID: e.RequestID,
+			Model:     e.Model,
+			CreatedAt: api.NewTimestamp(e.CreatedAt),
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return fmt.Errorf("activity: failed to encode entries: %w", err)
+	}
+	return nil
+}
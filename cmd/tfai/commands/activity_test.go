@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+func TestActivityCmd(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+	t.Setenv("TFAI_HISTORY_DB", dbPath)
+	ws := t.TempDir()
+
+	hs, err := store.Open(context.Background(), dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []store.Activity{
+		{Workspace: ws, Summary: "Created a VPC", Files: []string{"main.tf", "outputs.tf"}, Model: "gpt-4o"},
+		{Workspace: ws, Summary: "Added flow logs", Files: []string{"logs.tf"}, RequestID: "req-1"},
+		{Workspace: "/elsewhere", Summary: "Other workspace"},
+	} {
+		if err := hs.RecordActivity(context.Background(), a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = hs.Close()
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := NewActivityCmd()
+		var out strings.Builder
+		cmd.SetOut(&out)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	out := run("--dir", ws)
+	first, second := strings.Index(out, "Added flow logs (1 file)"), strings.Index(out, "Created a VPC (2 files, gpt-4o)")
+	if first < 0 || second < first {
+		t.Errorf("expected both entries, newest first, in:\n%s", out)
+	}
+	if !strings.Contains(out, "  main.tf, outputs.tf\n") || strings.Contains(out, "Other workspace") {
+		t.Errorf("expected only this workspace's entries with their files, got:\n%s", out)
+	}
+
+	var entries []api.WorkspaceActivity
+	if err := json.Unmarshal([]byte(run("--dir", ws, "--limit", "1", "--format", "json")), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Summary != "Added flow logs" || entries[0].RequestID != "req-1" {
+		t.Errorf("expected the newest entry only, got %+v", entries)
+	}
+
+	if out := run("--dir", t.TempDir()); !strings.HasPrefix(out, "No activity recorded for ") {
+		t.Errorf("expected the empty message, got %q", out)
+	}
+}
+
+func TestPrintActivity(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2024, 6, 1, 10, 30, 0, 0, time.Local)
+	var out strings.Builder
+	printActivity(&out, "/ws", []store.Activity{
+		{Summary: "Created EKS module", Files: []string{"main.tf"}, CreatedAt: at},
+		{Summary: "Added outputs", Files: []string{"outputs.tf"}, CreatedAt: at,
+			Conflicts: map[string]string{"outputs.tf": "merge-markers", "main.tf": "keep-mine"}},
+	})
+	want := "2024-06-01 10:30  Created EKS module (1 file)\n  main.tf\n\n" +
+		"2024-06-01 10:30  Added outputs (1 file)\n  outputs.tf\n  conflicts: main.tf (keep-mine), outputs.tf (merge-markers)\n"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
@@ -2,20 +2,22 @@ package commands
 
 import (
 	"fmt"
-	"log/slog"
 	"os"
 
 	"github.com/spf13/cobra"
 
 	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/logging"
 	"github.com/54b3r/tfai-go/internal/provider"
-	"github.com/54b3r/tfai-go/internal/tools"
 )
 
 // NewAskCmd constructs the `tfai ask` command, which sends a single natural
 // language question to the agent and streams the response to stdout.
 func NewAskCmd() *cobra.Command {
-	var dir string
+	var (
+		dir string
+		yes bool
+	)
 
 	cmd := &cobra.Command{
 		Use:   "ask [question]",
@@ -23,7 +25,10 @@ func NewAskCmd() *cobra.Command {
 		Long: `Ask the TF-AI agent a natural language question about Terraform.
 
 The agent has access to your local Terraform workspace (set with --dir) and
-can inspect plan output, state, and generated files.
+can inspect plan output, state, and generated files. On a terminal it asks
+before running terraform plan or state, which reach your cloud provider and
+state backend: answer y, n, or always (for the rest of the command). Pass
+--yes to skip the prompts.
 
 Examples:
   tfai ask "how do I create an EKS cluster with private endpoints?"
@@ -38,25 +43,32 @@ Examples:
 				return fmt.Errorf("ask: failed to initialise model provider: %w", err)
 			}
 
-			runner, err := tools.NewExecRunner()
+			ts := loadTools()
+
+			retriever, closeRetriever, err := buildRetriever(ctx, logging.FromContext(ctx))
 			if err != nil {
-				// terraform not on PATH is non-fatal for ask — warn and continue.
-				fmt.Fprintf(os.Stderr, "warning: %v (plan/state tools unavailable)\n", err)
-				runner = nil
+				return fmt.Errorf("ask: %w", err)
 			}
+			defer closeRetriever()
 
-			agentTools := buildTools(runner)
-
-			retriever, closeRetriever, err := buildRetriever(ctx, slog.Default())
+			reranker, err := buildReranker(retriever, models.ChatModel)
 			if err != nil {
 				return fmt.Errorf("ask: %w", err)
 			}
-			defer closeRetriever()
 
+			minScore, maxChars := ragLimits()
+			spoolThreshold, spoolDir := spoolSettings()
 			tfAgent, err := agent.New(ctx, &agent.Config{
-				ChatModel: models.ChatModel, // Always Chat model for ask ops
-				Tools:     agentTools,
-				Retriever: retriever,
+				ChatModel:            models.ChatModel, // Always Chat model for ask ops
+				Tools:                ts.tools,
+				TerraformUnavailable: ts.unavailable,
+				Retriever:            retriever,
+				Reranker:             reranker,
+				RAGMinScore:          minScore,
+				RAGMaxChars:          maxChars,
+				Disclosure:           disclosureText(),
+				SpoolThreshold:       spoolThreshold,
+				SpoolDir:             spoolDir,
 			})
 			if err != nil {
 				return fmt.Errorf("ask: failed to initialise agent: %w", err)
@@ -67,12 +79,22 @@ Examples:
 				question = fmt.Sprintf("[workspace: %s]\n\n%s", dir, question)
 			}
 
-			_, err = tfAgent.Query(ctx, question, "", os.Stdout)
-			return err //nolint:wrapcheck // CLI entry point — error goes directly to cobra
+			res, err := tfAgent.Run(ctx, agent.QueryRequest{
+				Message: question,
+				Output:  os.Stdout,
+				Events:  stderrNotices{},
+				Options: agent.QueryOptions{ConfirmTool: toolConfirmer(yes)},
+			})
+			if err != nil {
+				return err //nolint:wrapcheck // CLI entry point — error goes directly to cobra
+			}
+			printDisclosure(os.Stdout, res, isTerminal(os.Stdout))
+			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&dir, "dir", "d", "", "Terraform working directory to use as context")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Run terraform plan and state without asking for confirmation")
 
 	return cmd
 }
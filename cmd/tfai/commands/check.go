@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/check"
+	"github.com/54b3r/tfai-go/internal/gitutil"
+	"github.com/54b3r/tfai-go/internal/secretscan"
+)
+
+// NewCheckCmd constructs the `tfai check` command, which runs the
+// deterministic checks over a directory's Terraform files, or only the
+// staged ones.
+func NewCheckCmd() *cobra.Command {
+	var dir string
+	var checks string
+	var staged bool
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Run formatting, variable, and secret checks on Terraform files",
+		Long: `Run tfai's deterministic checks over the .tf files under a directory.
+No model and no terraform binary are needed.
+
+Checks (select with --checks, default all):
+  fmt      files that differ from terraform fmt output (blocking)
+  vars     HCL syntax errors (blocking); variables without a type or
+           description (warning)
+  secrets  hardcoded credentials, honouring <dir>/.tfai/secrets.allow
+           (blocking)
+
+With --staged only the .tf files staged in git are checked, as staged — the
+content that would be committed, not the working tree. Findings are grouped
+by file. The command exits non-zero when any blocking finding is reported,
+which is how the hook written by ` + "`tfai hook install`" + ` stops a commit.
+
+Examples:
+  tfai check
+  tfai check --dir ./infra --checks fmt,secrets
+  tfai check --staged`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selected, err := check.ParseChecks(checks)
+			if err != nil {
+				return fmt.Errorf("check: %w", err)
+			}
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("check: failed to resolve directory: %w", err)
+			}
+			allow, err := secretscan.LoadAllowlist(secretscan.AllowlistPath(absDir))
+			if err != nil {
+				return fmt.Errorf("check: %w", err)
+			}
+
+			var files []check.File
+			if staged {
+				stagedFiles, err := gitutil.Staged(cmd.Context(), absDir, check.IsTerraform)
+				if err != nil {
+					return fmt.Errorf("check: %w", err)
+				}
+				for _, f := range stagedFiles {
+					files = append(files, check.File{Path: f.Path, Content: f.Content})
+				}
+			} else if files, err = check.DirFiles(absDir); err != nil {
+				return fmt.Errorf("check: %w", err)
+			}
+
+			findings := check.Run(files, selected, secretscan.Default().WithAllowlist(allow))
+			printFindings(cmd.OutOrStdout(), len(files), findings)
+			if n := check.Blocking(findings); n > 0 {
+				return fmt.Errorf("check: %d blocking finding(s)", n)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", ".", "Directory to check")
+	cmd.Flags().StringVar(&checks, "checks", "", "Comma-separated checks to run: "+strings.Join(check.All, ",")+" (default: all)")
+	cmd.Flags().BoolVar(&staged, "staged", false, "Check only the .tf files staged in git, as staged")
+
+	return cmd
+}
+
+// printFindings writes findings grouped by file, or a one-line summary when
+// there are none.
+func printFindings(out io.Writer, files int, findings []check.Finding) {
+	if len(findings) == 0 {
+		fmt.Fprintf(out, "No problems found in %d file(s).\n", files)
+		return
+	}
+	for i, f := range findings {
+		if i == 0 || findings[i-1].File != f.File {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintln(out, f.File)
+		}
+		severity := "warning"
+		if f.Blocking {
+			severity = "error"
+		}
+		fmt.Fprintf(out, "  %4d  %-7s %-7s %s\n", f.Line, f.Check, severity, f.Message)
+	}
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runCheck runs `tfai check` with args and returns its output and error.
+func runCheck(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := NewCheckCmd()
+	var out strings.Builder
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestCheckCmd_ExitCodes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		content  string
+		checks   string
+		wantErr  bool
+		wantText string
+	}{
+		{name: "clean", content: "variable \"a\" {\n  type        = string\n  description = \"A\"\n}\n", wantText: "No problems found in 1 file(s)."},
+		{name: "warnings only", content: "variable \"a\" {}\n", wantText: `vars    warning variable "a" has no type`},
+		{name: "blocking", content: "variable \"a\" {\n  type = string\n  description = \"A\"\n}\n", wantErr: true, wantText: "fmt     error"},
+		{name: "blocking check not selected", content: "variable \"a\" {\n  type = string\n  description = \"A\"\n}\n", checks: "secrets"},
+		{name: "unknown check", content: "", checks: "policy", wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(tc.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			out, err := runCheck(t, "--dir", dir, "--checks", tc.checks)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v\n%s", tc.wantErr, err, out)
+			}
+			if !strings.Contains(out, tc.wantText) {
+				t.Errorf("expected %q in the output:\n%s", tc.wantText, out)
+			}
+		})
+	}
+}
+
+func TestCheckCmd_Staged(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "--quiet")
+	unformatted := "variable \"a\" {\n  type = string\n  description = \"A\"\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "staged.tf"), []byte("variable \"a\" {\n  type        = string\n  description = \"A\"\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "staged.tf")
+	// Unstaged problems, in the working tree only, do not block.
+	for name, content := range map[string]string{"staged.tf": unformatted, "untracked.tf": unformatted} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := runCheck(t, "--staged", "--dir", dir)
+	if err != nil || !strings.Contains(out, "No problems found in 1 file(s).") {
+		t.Fatalf("expected the staged content to pass, got %v:\n%s", err, out)
+	}
+
+	git("add", "staged.tf")
+	out, err = runCheck(t, "--staged", "--dir", dir)
+	if err == nil || !strings.Contains(out, "staged.tf\n") || strings.Contains(out, "untracked.tf") {
+		t.Errorf("expected only the staged file to block, got %v:\n%s", err, out)
+	}
+}
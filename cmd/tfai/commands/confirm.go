@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+)
+
+// toolPrompter asks on a terminal before the agent runs a tool that requires
+// confirmation, such as terraform plan or state. It is safe for concurrent
+// use: parallel tool calls are asked about one at a time.
+type toolPrompter struct {
+	// in reads the user's answers.
+	in *bufio.Reader
+	// out shows the prompts.
+	out io.Writer
+	// mu serialises prompts and guards always.
+	mu sync.Mutex
+	// always holds the tools the user approved for the rest of the session.
+	always map[string]bool
+}
+
+// newToolPrompter returns a toolPrompter reading answers from in and
+// writing prompts to out.
+func newToolPrompter(in io.Reader, out io.Writer) *toolPrompter {
+	return &toolPrompter{in: bufio.NewReader(in), out: out, always: make(map[string]bool)}
+}
+
+// confirm implements agent.ToolConfirmer. It shows the tool and its
+// arguments and waits for y (run once), n (deny), or always (run this tool
+// without asking again). Anything else, including end of input, denies.
+func (p *toolPrompter) confirm(_ context.Context, call agent.ToolCall) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.always[call.Name] {
+		return true
+	}
+	_, _ = fmt.Fprintf(p.out, "\nThe assistant wants to run %s %s\nAllow? [y/N/always] ", call.Name, call.Arguments)
+	line, _ := p.in.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	case "a", "always":
+		p.always[call.Name] = true
+		return true
+	}
+	_, _ = fmt.Fprintf(p.out, "Skipped %s.\n", call.Name)
+	return false
+}
+
+// toolConfirmer returns the confirmation hook for a CLI query: nil, which
+// runs every tool, when yes is set or stdin is not a terminal to prompt on;
+// otherwise a prompt on stderr.
+func toolConfirmer(yes bool) agent.ToolConfirmer {
+	if yes || !isTerminal(os.Stdin) {
+		return nil
+	}
+	return newToolPrompter(os.Stdin, os.Stderr).confirm
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+)
+
+func TestToolPrompter(t *testing.T) {
+	t.Parallel()
+
+	plan := agent.ToolCall{Name: "terraform_plan", Arguments: `{"dir":"/ws"}`}
+	state := agent.ToolCall{Name: "terraform_state", Arguments: `{"dir":"/ws","subcommand":"list"}`}
+	tests := []struct {
+		name  string
+		input string
+		calls []agent.ToolCall
+		want  []bool
+		// wantPrompts is the number of prompts shown.
+		wantPrompts int
+	}{
+		{name: "approve once", input: "y\n", calls: []agent.ToolCall{plan}, want: []bool{true}, wantPrompts: 1},
+		{name: "deny", input: "n\n", calls: []agent.ToolCall{plan}, want: []bool{false}, wantPrompts: 1},
+		{name: "empty answer denies", input: "\n", calls: []agent.ToolCall{plan}, want: []bool{false}, wantPrompts: 1},
+		{name: "end of input denies", input: "", calls: []agent.ToolCall{plan}, want: []bool{false}, wantPrompts: 1},
+		{
+			name:        "yes asks again",
+			input:       "yes\nn\n",
+			calls:       []agent.ToolCall{plan, plan},
+			want:        []bool{true, false},
+			wantPrompts: 2,
+		},
+		{
+			name:        "always covers later calls of that tool only",
+			input:       "always\nn\n",
+			calls:       []agent.ToolCall{plan, plan, state, plan},
+			want:        []bool{true, true, false, true},
+			wantPrompts: 2,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var out strings.Builder
+			p := newToolPrompter(strings.NewReader(tc.input), &out)
+			var got []bool
+			for _, c := range tc.calls {
+				got = append(got, p.confirm(context.Background(), c))
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+			if n := strings.Count(out.String(), "Allow? [y/N/always]"); n != tc.wantPrompts {
+				t.Errorf("expected %d prompts, got %d:\n%s", tc.wantPrompts, n, out.String())
+			}
+			if !strings.Contains(out.String(), `terraform_plan {"dir":"/ws"}`) {
+				t.Errorf("expected the prompt to show the tool and arguments, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestToolConfirmer_Yes(t *testing.T) {
+	t.Parallel()
+
+	if toolConfirmer(true) != nil {
+		t.Error("expected --yes to run every tool without a prompt")
+	}
+}
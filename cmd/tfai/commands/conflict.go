@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+)
+
+// conflictPrompter asks on a terminal how to write each generated file the
+// user changed since tfai last wrote it. The agent asks about one file at a
+// time.
+type conflictPrompter struct {
+	// in reads the user's answers.
+	in *bufio.Reader
+	// out shows the prompts and diffs.
+	out io.Writer
+}
+
+// newConflictPrompter returns a conflictPrompter reading answers from in
+// and writing prompts to out.
+func newConflictPrompter(in io.Reader, out io.Writer) *conflictPrompter {
+	return &conflictPrompter{in: bufio.NewReader(in), out: out}
+}
+
+// resolve implements agent.ConflictResolver. It names the file and waits for
+// k (keep mine), t (take generated), m (write both with conflict markers),
+// or d, which shows the diff from the user's file to the generated one and
+// asks again. Anything else, including end of input, keeps the user's file.
+func (p *conflictPrompter) resolve(_ context.Context, c agent.FileConflict) agent.ConflictResolution {
+	for {
+		_, _ = fmt.Fprintf(p.out, "\nYou changed %s since tfai last wrote it.\n"+
+			"[k]eep mine, [t]ake generated, show [d]iff, or write [m]erge markers? [K/t/d/m] ", c.Path)
+		line, err := p.in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "t", "take":
+			return agent.TakeGenerated
+		case "m", "merge":
+			return agent.MergeMarkers
+		case "d", "diff":
+			if err == nil {
+				_, _ = fmt.Fprint(p.out, c.Diff())
+				continue
+			}
+		}
+		_, _ = fmt.Fprintf(p.out, "Kept your %s.\n", c.Path)
+		return agent.KeepMine
+	}
+}
+
+// keepMine is the conflict hook where nobody can be asked: the user's
+// changes are never overwritten.
+func keepMine(context.Context, agent.FileConflict) agent.ConflictResolution {
+	return agent.KeepMine
+}
+
+// conflictResolver returns the conflict hook for a CLI generation: a prompt
+// on stderr when stdin is a terminal, otherwise keepMine.
+func conflictResolver() agent.ConflictResolver {
+	if !isTerminal(os.Stdin) {
+		return keepMine
+	}
+	return newConflictPrompter(os.Stdin, os.Stderr).resolve
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+)
+
+func TestConflictPrompter(t *testing.T) {
+	t.Parallel()
+
+	c := agent.FileConflict{Path: "main.tf", Mine: "a\nmine\n", Generated: "a\ngenerated\n"}
+	tests := []struct {
+		name  string
+		input string
+		want  agent.ConflictResolution
+		// wantDiff is true when the diff is shown before the answer.
+		wantDiff bool
+	}{
+		{name: "keep", input: "k\n", want: agent.KeepMine},
+		{name: "empty answer keeps", input: "\n", want: agent.KeepMine},
+		{name: "end of input keeps", input: "", want: agent.KeepMine},
+		{name: "unknown answer keeps", input: "overwrite\n", want: agent.KeepMine},
+		{name: "take", input: "t\n", want: agent.TakeGenerated},
+		{name: "merge", input: "M\n", want: agent.MergeMarkers},
+		{name: "diff then take", input: "d\ntake\n", want: agent.TakeGenerated, wantDiff: true},
+		{name: "diff at end of input keeps", input: "d", want: agent.KeepMine},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var out strings.Builder
+			p := newConflictPrompter(strings.NewReader(tc.input), &out)
+			if got := p.resolve(context.Background(), c); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+			if !strings.Contains(out.String(), "You changed main.tf since tfai last wrote it.") {
+				t.Errorf("expected the prompt to name the file, got %q", out.String())
+			}
+			if got := strings.Contains(out.String(), "-mine\n+generated\n"); got != tc.wantDiff {
+				t.Errorf("expected diff shown %v, got:\n%s", tc.wantDiff, out.String())
+			}
+		})
+	}
+}
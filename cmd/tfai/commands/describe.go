@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/describe"
+	"github.com/54b3r/tfai-go/internal/gitutil"
+	"github.com/54b3r/tfai-go/internal/provider"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+)
+
+// NewDescribeChangesCmd constructs the `tfai describe-changes` command, which
+// drafts a commit message and pull request description for the changes in a
+// workspace.
+func NewDescribeChangesCmd() *cobra.Command {
+	var dir string
+	var sinceManifest bool
+	var gitDiff bool
+	var format string
+	var maxDiffBytes int
+	var commit bool
+
+	cmd := &cobra.Command{
+		Use:   "describe-changes",
+		Short: "Draft a commit message and PR description for workspace changes",
+		Long: `Describe the changes in a workspace as a title, summary, security notes,
+breaking changes, and test plan, ready to paste into a pull request.
+
+The changes are read from one of:
+  --since-manifest  every file tfai changed since the .tfai manifest was last
+                    reset (recorded whenever tfai writes files)
+  --git-diff        the uncommitted changes against git HEAD, including
+                    untracked files
+Without either flag the manifest is used when there is one, git otherwise.
+
+Diffs larger than --max-diff-bytes are truncated file by file; files that do
+not fit are listed by name only.
+
+With --commit the changes are committed to git with the generated title and
+summary as the commit message, and the manifest is reset.
+
+Examples:
+  tfai describe-changes --dir ./infra
+  tfai describe-changes --dir ./infra --git-diff --format json
+  tfai describe-changes --dir ./infra --commit`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+
+			if format != "markdown" && format != "json" {
+				return fmt.Errorf("describe-changes: unknown --format %q (want markdown or json)", format)
+			}
+			src := describe.SourceAuto
+			switch {
+			case sinceManifest:
+				src = describe.SourceManifest
+			case gitDiff:
+				src = describe.SourceGit
+			}
+
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("describe-changes: failed to resolve workspace directory: %w", err)
+			}
+
+			diffs, src, err := describe.Gather(ctx, absDir, src)
+			if err != nil {
+				return fmt.Errorf("describe-changes: %w", err)
+			}
+
+			models, err := provider.NewFromEnv(ctx)
+			if err != nil {
+				return fmt.Errorf("describe-changes: failed to initialise model provider: %w", err)
+			}
+			tfAgent, err := agent.New(ctx, &agent.Config{ChatModel: models.ChatModel})
+			if err != nil {
+				return fmt.Errorf("describe-changes: failed to initialise agent: %w", err)
+			}
+
+			describer := &describe.Describer{Querier: tfAgent, MaxDiffBytes: maxDiffBytes}
+			desc, err := describer.Describe(ctx, diffs)
+			if err != nil {
+				return err //nolint:wrapcheck // describe errors are already prefixed
+			}
+
+			if format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(desc); err != nil {
+					return fmt.Errorf("describe-changes: failed to encode description: %w", err)
+				}
+			} else {
+				fmt.Println(desc.Markdown())
+			}
+
+			if !commit {
+				return nil
+			}
+			hash, err := gitutil.Commit(ctx, absDir, desc.CommitMessage())
+			if err != nil {
+				return fmt.Errorf("describe-changes: %w", err)
+			}
+			if src == describe.SourceManifest {
+				if err := tfaidir.ResetManifest(absDir); err != nil {
+					return fmt.Errorf("describe-changes: committed %s but %w", hash, err)
+				}
+			}
+			fmt.Fprintf(os.Stderr, "Committed %s: %s\n", hash, desc.Title)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", ".", "Terraform workspace whose changes to describe")
+	cmd.Flags().BoolVar(&sinceManifest, "since-manifest", false, "Describe the files tfai changed since the manifest was last reset")
+	cmd.Flags().BoolVar(&gitDiff, "git-diff", false, "Describe the uncommitted changes against git HEAD")
+	cmd.Flags().StringVar(&format, "format", "markdown", "Output format: markdown or json")
+	cmd.Flags().IntVar(&maxDiffBytes, "max-diff-bytes", describe.DefaultMaxDiffBytes, "Maximum diff size sent to the model; larger diffs are truncated file by file")
+	cmd.Flags().BoolVar(&commit, "commit", false, "Commit the changes to git with the generated title and summary")
+	cmd.MarkFlagsMutuallyExclusive("since-manifest", "git-diff")
+
+	return cmd
+}
@@ -5,10 +5,13 @@ import (
 	"io"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/hclinspect"
+	"github.com/54b3r/tfai-go/internal/logging"
 )
 
 // NewDiagnoseCmd constructs the `tfai diagnose` command, which analyses a
@@ -17,6 +20,9 @@ import (
 func NewDiagnoseCmd() *cobra.Command {
 	var planFile string
 	var dir string
+	var yes bool
+	var focus bool
+	var timeout time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "diagnose",
@@ -26,6 +32,15 @@ analysis with step-by-step remediation guidance.
 
 You can pipe plan output directly or provide a saved plan file.
 
+With --dir, the workspace's .tf files are given to the model as context.
+When the plan output mentions resources, only the files defining them, the
+files its diagnostics point at, and the terraform and provider settings are
+given; pass --focus=false to give every file. The workspace is never
+modified. The lock file is also checked for providers that have no checksum
+for this machine's platform. On a terminal
+you are asked before the agent runs terraform plan or state; pass --yes to
+skip the prompts.
+
 Examples:
   terraform plan 2>&1 | tfai diagnose
   tfai diagnose --plan plan.txt
@@ -56,15 +71,20 @@ Examples:
 				}
 			}
 
-			models, agentTools, _, _, err := initCommand(ctx)
+			models, ts, _, _, err := initCommand(ctx)
 			if err != nil {
-				slog.Error("failed to initialize command", slog.String("command", cmd.Name()), slog.Any("error", err))
+				logging.FromContext(ctx).Error("failed to initialize command", slog.String("command", cmd.Name()), slog.Any("error", err))
 				return fmt.Errorf("diagnose: failed to initialize command: %w", err)
 			}
 
+			spoolThreshold, spoolDir := spoolSettings()
 			tfAgent, err := agent.New(ctx, &agent.Config{
-				ChatModel: models.ChatModel,
-				Tools:     agentTools,
+				ChatModel:            models.ChatModel,
+				Tools:                ts.tools,
+				TerraformUnavailable: ts.unavailable,
+				Disclosure:           disclosureText(),
+				SpoolThreshold:       spoolThreshold,
+				SpoolDir:             spoolDir,
 			})
 			if err != nil {
 				return fmt.Errorf("diagnose: failed to initialise agent: %w", err)
@@ -84,13 +104,44 @@ Examples:
 				return fmt.Errorf("diagnose: provide --plan <file>, pipe plan output via stdin, or specify --dir <workspace>")
 			}
 
-			_, err = tfAgent.Query(ctx, prompt, "", os.Stdout)
-			return err //nolint:wrapcheck // CLI entry point — error goes directly to cobra
+			// Missing lock file hashes are found deterministically; report
+			// them directly and hand them to the model as a known finding.
+			if dir != "" {
+				finding, err := hclinspect.CheckPlatform(dir, hclinspect.HostPlatform())
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				} else if finding != nil {
+					fmt.Fprintf(os.Stderr, "lock file: %s\n\n", finding)
+					prompt += "\n\nA deterministic check of the dependency lock file found:\n\n" + finding.String()
+				}
+			}
+
+			req := agent.QueryRequest{
+				Message: prompt,
+				Output:  os.Stdout,
+				Events:  stderrNotices{},
+				Options: agent.QueryOptions{ConfirmTool: toolConfirmer(yes), NoWrite: true},
+			}
+			if dir != "" {
+				req.WorkspaceDir = dir
+				if focus && planContent != "" {
+					req.Scope = agent.FocusScope(ctx, dir, planContent)
+				}
+			}
+			res, err := timeoutQuerier{q: tfAgent, timeout: timeout}.Run(ctx, req)
+			if err != nil {
+				return err //nolint:wrapcheck // CLI entry point — error goes directly to cobra
+			}
+			printDisclosure(os.Stdout, res, isTerminal(os.Stdout))
+			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&planFile, "plan", "p", "", "Path to a saved terraform plan output file")
 	cmd.Flags().StringVarP(&dir, "dir", "d", "", "Terraform working directory to run plan against")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Run terraform plan and state without asking for confirmation")
+	cmd.Flags().BoolVar(&focus, "focus", true, "Limit the workspace context to the files of the resources the plan output mentions")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Stop a diagnosis that runs longer than this, e.g. 3m (0 means no limit)")
 
 	return cmd
 }
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// ANSI sequences for the dimmed disclosure footer.
+const (
+	ansiDim   = "\x1b[2m"
+	ansiReset = "\x1b[0m"
+)
+
+// disclosureText returns the label that marks answers as AI-generated, from
+// TFAI_DISCLOSURE. Empty when unset.
+func disclosureText() string {
+	return strings.TrimSpace(os.Getenv("TFAI_DISCLOSURE"))
+}
+
+// printDisclosure writes the disclosure of res as a footer below the answer,
+// dimmed when color is true. Nothing is written when res carries none.
+func printDisclosure(w io.Writer, res *agent.QueryResult, color bool) {
+	if res == nil || res.Disclosure == "" {
+		return
+	}
+	label := res.Disclosure
+	if color {
+		label = ansiDim + label + ansiReset
+	}
+	_, _ = fmt.Fprintf(w, "\n\n%s\n", label)
+}
+
+// jsonResult is the --format json output of a finished query: the same
+// shape as a non-streaming POST /api/chat response, without a request ID.
+func jsonResult(res *agent.QueryResult, answer string, duration time.Duration) api.ChatResponse {
+	resp := api.ChatResponse{
+		Answer:       answer,
+		FilesWritten: res.FilesWritten(),
+		Files:        res.Files,
+		Sources:      res.Sources,
+		Notices:      res.Notices,
+		Disclosure:   res.Disclosure,
+		DurationMs:   duration.Milliseconds(),
+	}
+	// Encode empty lists as [] so scripts need no null checks.
+	if resp.Files == nil {
+		resp.Files = []string{}
+	}
+	if resp.Sources == nil {
+		resp.Sources = []string{}
+	}
+	if res.Usage != nil {
+		resp.Usage = &api.ChatUsage{PromptTokens: res.Usage.PromptTokens, CompletionTokens: res.Usage.CompletionTokens}
+	}
+	if t := res.Timings; t != nil {
+		resp.Timings = &api.ChatTimings{
+			ContextMs:    t.Context.Milliseconds(),
+			FirstTokenMs: t.FirstToken.Milliseconds(),
+			ModelMs:      t.Model.Milliseconds(),
+			ToolsMs:      t.Tools.Milliseconds(),
+			ToolCalls:    len(t.ToolCalls),
+			ParseMs:      t.Parse.Milliseconds(),
+			ApplyMs:      t.Apply.Milliseconds(),
+			TotalMs:      t.Total.Milliseconds(),
+		}
+		for _, c := range t.ToolCalls {
+			resp.Timings.Tools = append(resp.Timings.Tools, api.ToolTiming{Tool: c.Name, ElapsedMs: c.Elapsed.Milliseconds()})
+		}
+	}
+	if len(res.InputsNeeded) > 0 {
+		resp.InputsNeeded = &api.InputsNeededEvent{}
+		for _, in := range res.InputsNeeded {
+			resp.InputsNeeded.Imports = append(resp.InputsNeeded.Imports, api.ImportInput{To: in.To, ID: in.ID, Path: in.Path, Line: in.Line})
+		}
+	}
+	return resp
+}
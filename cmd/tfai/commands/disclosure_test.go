@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+)
+
+// label is the disclosure used by these tests.
+const label = "AI-generated advice. Review before applying."
+
+func TestPrintDisclosure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		res   *agent.QueryResult
+		color bool
+		want  string
+	}{
+		{name: "plain", res: &agent.QueryResult{Disclosure: label}, want: "\n\n" + label + "\n"},
+		{name: "dimmed on a terminal", res: &agent.QueryResult{Disclosure: label}, color: true, want: "\n\n\x1b[2m" + label + "\x1b[0m\n"},
+		{name: "none configured", res: &agent.QueryResult{}, color: true, want: ""},
+		{name: "nil result", res: nil, want: ""},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var b strings.Builder
+			printDisclosure(&b, tc.res, tc.color)
+			if b.String() != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, b.String())
+			}
+		})
+	}
+}
+
+func TestJSONResult_Disclosure(t *testing.T) {
+	t.Parallel()
+
+	for _, disclosure := range []string{label, ""} {
+		res := &agent.QueryResult{Files: []string{"main.tf"}, Disclosure: disclosure}
+		b, err := json.Marshal(jsonResult(res, "Wrote 1 file.", 1500*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `{"answer":"Wrote 1 file.","filesWritten":true,"files":["main.tf"],"sources":[],"requestId":"","durationMs":1500}`
+		if disclosure != "" {
+			want = `{"answer":"Wrote 1 file.","filesWritten":true,"files":["main.tf"],"sources":[],"disclosure":"` + label + `","requestId":"","durationMs":1500}`
+		}
+		if string(b) != want {
+			t.Errorf("expected %s, got %s", want, b)
+		}
+	}
+}
+
+func TestJSONResult_Timings(t *testing.T) {
+	t.Parallel()
+
+	res := &agent.QueryResult{Timings: &agent.Timings{
+		Context:    40 * time.Millisecond,
+		FirstToken: 700 * time.Millisecond,
+		Model:      900 * time.Millisecond,
+		Tools:      300 * time.Millisecond,
+		ToolCalls:  []agent.ToolTiming{{Name: "terraform_validate", Elapsed: 300 * time.Millisecond}},
+		Parse:      2 * time.Millisecond,
+		Apply:      5 * time.Millisecond,
+		Total:      1250 * time.Millisecond,
+	}}
+	b, err := json.Marshal(jsonResult(res, "Wrote 1 file.", 1300*time.Millisecond).Timings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"contextMs":40,"firstTokenMs":700,"modelMs":900,"toolsMs":300,"toolCalls":1,"tools":[{"tool":"terraform_validate","elapsedMs":300}],"parseMs":2,"applyMs":5,"totalMs":1250}`
+	if string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+}
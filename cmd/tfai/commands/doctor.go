@@ -0,0 +1,49 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/deprecation"
+)
+
+// NewDoctorCmd constructs the `tfai doctor` subcommand, which reports
+// settings that still work but are due to be removed.
+func NewDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Report deprecated settings in use",
+		Long: `Check the environment for deprecated variables and list each one in use
+with its replacement and deprecation ID. Deprecated API request fields cannot
+be detected here: the server reports them in the deprecations array of each
+response that used one.
+
+A deprecation listed in TFAI_SUPPRESS_DEPRECATIONS (comma-separated IDs) is
+neither logged nor reported.
+
+Examples:
+  tfai doctor
+  TFAI_SUPPRESS_DEPRECATIONS=env-generate-model-id tfai doctor`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printDeprecations(cmd.OutOrStdout(), deprecation.Used())
+			return nil
+		},
+	}
+}
+
+// printDeprecations writes the deprecations in use, one per paragraph.
+func printDeprecations(w io.Writer, used []deprecation.Deprecation) {
+	if len(used) == 0 {
+		fmt.Fprintln(w, "No deprecated settings in use.")
+		return
+	}
+	for _, d := range used {
+		fmt.Fprintf(w, "%s is deprecated [%s]\n", d.Name, d.ID)
+		fmt.Fprintf(w, "  use %s instead: %s\n", d.Replacement, d.Message)
+		fmt.Fprintf(w, "  silence with %s=%s\n\n", deprecation.SuppressEnv, d.ID)
+	}
+	fmt.Fprintf(w, "%d deprecated setting(s) in use.\n", len(used))
+}
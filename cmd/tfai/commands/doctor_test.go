@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/deprecation"
+)
+
+func TestDoctorCmd(t *testing.T) {
+	tests := []struct {
+		name     string
+		modelID  string
+		suppress string
+		want     string
+	}{
+		{name: "nothing deprecated", want: "No deprecated settings in use."},
+		{name: "legacy variable set", modelID: "anthropic.claude-v2", want: "GENERATE_MODEL_ID is deprecated [" + deprecation.GenerateModelID + "]"},
+		{name: "legacy variable suppressed", modelID: "anthropic.claude-v2", suppress: deprecation.GenerateModelID, want: "No deprecated settings in use."},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("GENERATE_MODEL_ID", tc.modelID)
+			t.Setenv(deprecation.SuppressEnv, tc.suppress)
+
+			cmd := NewDoctorCmd()
+			var out strings.Builder
+			cmd.SetOut(&out)
+			cmd.SetArgs(nil)
+			if err := cmd.Execute(); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), tc.want) {
+				t.Errorf("expected %q in:\n%s", tc.want, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/eval"
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/provider"
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// defaultEvalSuites is the suite pattern `tfai eval generate` runs when
+// none is given.
+var defaultEvalSuites = filepath.Join("testdata", "eval", "*.yaml")
+
+// NewEvalCmd constructs the `tfai eval` command group for scoring tfai's
+// output against golden specs.
+func NewEvalCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "eval",
+		Short: "Score generation quality against golden specs",
+	}
+	cmd.AddCommand(newEvalGenerateCmd())
+	return cmd
+}
+
+// newEvalGenerateCmd constructs `tfai eval generate`, which runs each suite
+// through the generation path and scores the files it writes.
+func newEvalGenerateCmd() *cobra.Command {
+	var suites []string
+	var replay bool
+	var format string
+	var minScore float64
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "generate [suite...]",
+		Short: "Generate each golden spec and score the result",
+		Long: `Generate the module each suite file describes, in a scratch workspace and
+through the same path as ` + "`tfai generate`" + `, then score the files it writes
+against the suite's deterministic assertions:
+
+  files         the listed files are generated
+  resources     each listed resource or data block sets the listed arguments
+  policy_clean  the vars and secrets checks of ` + "`tfai check`" + ` report nothing
+  fmt_clean     every .tf file is canonically formatted
+  max_files     no more than this many files are generated
+
+Suites are given as arguments or --suite globs (default ` + defaultEvalSuites + `).
+Generation uses the configured provider; with --replay, each suite is answered
+by the replay script it names instead, so the run is deterministic and needs
+no model — run it in CI to catch changes to prompts, parsing, and formatting
+that break a known-good answer.
+
+The report ends with a score, the share of all assertions that passed. A suite
+whose generation fails fails every assertion, so two runs of the same suites
+score out of the same total: compare the --format json reports of two
+branches to see what a change did to quality. The command exits non-zero when
+the score is below --min-score.
+
+Examples:
+  tfai eval generate --replay
+  tfai eval generate --suite 'testdata/eval/*.yaml'
+  tfai eval generate --format json --min-score 0 testdata/eval/vpc.yaml > vpc.json`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case format != "text" && format != "json":
+				return fmt.Errorf("eval: unknown --format %q (want text or json)", format)
+			case minScore < 0 || minScore > 1:
+				return fmt.Errorf("eval: --min-score must be between 0 and 1")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			patterns := append(append([]string{}, suites...), args...)
+			if len(patterns) == 0 {
+				patterns = []string{defaultEvalSuites}
+			}
+			loaded, err := eval.LoadSuites(patterns)
+			if err != nil {
+				return err //nolint:wrapcheck // eval errors are already prefixed
+			}
+
+			var llm model.ToolCallingChatModel
+			var retriever rag.Retriever
+			ts := loadTools()
+			if !replay {
+				models, err := provider.NewFromEnv(ctx)
+				if err != nil {
+					return fmt.Errorf("eval: failed to initialise model provider: %w", err)
+				}
+				llm = models.ChatModel
+				if models.GenerateModel != nil {
+					llm = models.GenerateModel
+				}
+				var closeRetriever func()
+				retriever, closeRetriever, err = buildRetriever(ctx, logging.FromContext(ctx))
+				if err != nil {
+					return fmt.Errorf("eval: %w", err)
+				}
+				defer closeRetriever()
+			}
+
+			report, err := eval.Run(ctx, loaded, evalGenerator(llm, ts, retriever, replay, timeout))
+			if err != nil {
+				return err //nolint:wrapcheck // eval errors are already prefixed
+			}
+			out := cmd.OutOrStdout()
+			if format == "json" {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return fmt.Errorf("eval: failed to encode report: %w", err)
+				}
+			} else if err := report.WriteText(out); err != nil {
+				return err //nolint:wrapcheck // eval errors are already prefixed
+			}
+			if report.Score < minScore {
+				return fmt.Errorf("eval: score %.1f%% is below --min-score %.1f%%", report.Score*100, minScore*100)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&suites, "suite", nil, "Suite file or glob to run; repeatable (default: "+defaultEvalSuites+")")
+	cmd.Flags().BoolVar(&replay, "replay", false, "Answer each suite with its recorded replay script instead of the configured provider")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	cmd.Flags().Float64Var(&minScore, "min-score", 1, "Exit non-zero when the score, from 0 to 1, is below this")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Stop a suite's generation that runs longer than this, e.g. 3m (0 means no limit)")
+
+	return cmd
+}
+
+// evalGenerator returns the eval.Generator that runs a suite's spec through
+// the agent with the prompt `tfai generate` uses. With replay, each suite
+// is answered by its own script, played back leniently since the scratch
+// workspace path in the prompt differs from run to run; otherwise by llm.
+func evalGenerator(llm model.ToolCallingChatModel, ts toolSet, retriever rag.Retriever, replay bool, timeout time.Duration) eval.Generator {
+	return func(ctx context.Context, s *eval.Suite, dir string) error {
+		m := llm
+		if replay {
+			if s.Replay == "" {
+				return fmt.Errorf("eval: suite %s has no replay script", s.Name)
+			}
+			script, err := provider.LoadScript(s.Replay)
+			if err != nil {
+				return err //nolint:wrapcheck // provider errors are already prefixed
+			}
+			m = provider.NewReplay(script, false)
+		}
+		a, err := agent.New(ctx, &agent.Config{
+			ChatModel:            m,
+			Tools:                ts.tools,
+			TerraformUnavailable: ts.unavailable,
+			Retriever:            retriever,
+		})
+		if err != nil {
+			return fmt.Errorf("eval: failed to initialise agent: %w", err)
+		}
+		_, err = timeoutQuerier{q: a, timeout: timeout}.Run(ctx, agent.QueryRequest{
+			Message:      generatePrompt(dir, s.Spec, false),
+			WorkspaceDir: dir,
+			Output:       io.Discard,
+			Options:      agent.QueryOptions{ExpectEnvelope: true},
+		})
+		return err
+	}
+}
@@ -0,0 +1,61 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/eval"
+)
+
+// runEval runs `tfai eval generate` with args and returns its output and
+// error.
+func runEval(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := newEvalGenerateCmd()
+	var out strings.Builder
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestEvalGenerateCmd_Replay(t *testing.T) {
+	t.Parallel()
+
+	golden := filepath.Join("..", "..", "..", "testdata", "eval", "*.yaml")
+	out, err := runEval(t, "--replay", "--format", "json", "--suite", golden)
+	if err != nil {
+		t.Fatalf("expected the bundled suites to pass on replay, got %v\n%s", err, out)
+	}
+	var report eval.Report
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("expected a JSON report, got %v\n%s", err, out)
+	}
+	if report.Score != 1 || report.SuitesPassed != len(report.Suites) || len(report.Suites) == 0 {
+		t.Errorf("expected every suite to pass, got %+v", report)
+	}
+
+	// A suite without a replay script fails every assertion, which fails
+	// the default --min-score of 1.
+	dir := t.TempDir()
+	suite := filepath.Join(dir, "unrecorded.yaml")
+	if err := os.WriteFile(suite, []byte("spec: A bucket.\nassert:\n  files: [main.tf]\n  fmt_clean: true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = runEval(t, "--replay", suite)
+	if err == nil || !strings.Contains(err.Error(), "below --min-score") {
+		t.Errorf("expected the run to fail the minimum score, got %v", err)
+	}
+	for _, want := range []string{"FAIL unrecorded (0/2, ", "generation failed: eval: suite unrecorded has no replay script", "Score: 0.0%"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in the output:\n%s", want, out)
+		}
+	}
+	if _, err := runEval(t, "--replay", "--min-score", "0", suite); err != nil {
+		t.Errorf("expected --min-score 0 to report without failing, got %v", err)
+	}
+}
@@ -1,15 +1,22 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/spf13/cobra"
 
 	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/logging"
+	tfwatch "github.com/54b3r/tfai-go/internal/watch"
 )
 
 // NewGenerateCmd constructs the `tfai generate` command, which generates
@@ -17,6 +24,11 @@ import (
 // to the specified output directory.
 func NewGenerateCmd() *cobra.Command {
 	var outDir string
+	var fromFile string
+	var watch bool
+	var format string
+	var timeout time.Duration
+	var importMode bool
 
 	cmd := &cobra.Command{
 		Use:   "generate [description]",
@@ -26,18 +38,68 @@ func NewGenerateCmd() *cobra.Command {
 The agent will create appropriately structured .tf files (main.tf, variables.tf,
 outputs.tf, versions.tf) in the specified output directory.
 
+With --watch, the description is read from --from-file and regenerated every
+time the file changes. Each run edits the previously generated files instead
+of rewriting them, and prints a summary of what changed.
+
+When a generated file would overwrite changes you made since tfai last
+wrote it, generate asks on a terminal what to do with it: keep yours, take
+the generated version, show the diff, or write both with git-style conflict
+markers. Without a terminal your changes are kept. The summary lists every
+such file and what was done with it.
+
+With --import, or when the description says the resources already exist,
+the agent writes Terraform 1.5+ import blocks in imports.tf along with the
+configuration, so the existing resources are adopted rather than created.
+Every import must target a generated resource. The summary lists the import
+IDs left as <placeholders> for you to look up before running terraform plan.
+
+With --format json, the summary, written files, sources, and token usage are
+printed as one JSON object once generation finishes.
+
+Whatever the format, the last line on stderr is a summary for CI logs:
+  tfai_result status=ok files_written=4 duration_ms=5210 provider=openai request_id=3f2a9c0d1e4b5a69
+status is ok, partial (truncated or tool-limited), or failed. --watch does
+not print it.
+
 Examples:
   tfai generate "EKS cluster with IRSA, private endpoints, and managed node groups"
   tfai generate --out ./modules/aks "AKS cluster with Azure CNI and workload identity"
-  tfai generate "GCS bucket with versioning, CMEK, and uniform bucket-level access"`,
-		Args: cobra.MinimumNArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
+  tfai generate "GCS bucket with versioning, CMEK, and uniform bucket-level access"
+  tfai generate --out ./modules/vpc --from-file vpc.md --watch
+  tfai generate --import "The S3 bucket acme-logs that was created in the console"`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			switch {
+			case fromFile == "" && len(args) != 1:
+				return fmt.Errorf("generate: provide a description argument or --from-file")
+			case fromFile != "" && len(args) > 0:
+				return fmt.Errorf("generate: use either a description argument or --from-file, not both")
+			case watch && fromFile == "":
+				return fmt.Errorf("generate: --watch requires --from-file")
+			case format != "text" && format != "json":
+				return fmt.Errorf("generate: unknown --format %q (want text or json)", format)
+			case watch && format == "json":
+				return fmt.Errorf("generate: --format json cannot be used with --watch")
+			case watch && importMode:
+				return fmt.Errorf("generate: --import cannot be used with --watch")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
 			var llm model.ToolCallingChatModel
+			var res *agent.QueryResult
 
 			ctx := cmd.Context()
-			models, agentTools, retriever, retrieverClose, err := initCommand(ctx)
+			began := time.Now()
+			requestID := newCLIRequestID()
+			if !watch {
+				defer func() {
+					writeResultLine(cmd.ErrOrStderr(), generateResultFields(res, err, time.Since(began), generateProviderName(), requestID))
+				}()
+			}
+			models, ts, retriever, retrieverClose, err := initCommand(ctx)
 			if err != nil {
-				slog.Error("failed to initialize command", slog.Any("error", err))
+				logging.FromContext(ctx).Error("failed to initialize command", slog.Any("error", err))
 				return fmt.Errorf("generate: failed to initialize command: %w", err)
 			}
 			defer retrieverClose()
@@ -48,10 +110,29 @@ Examples:
 				llm = models.ChatModel
 			}
 
+			reranker, err := buildReranker(retriever, models.ChatModel)
+			if err != nil {
+				return fmt.Errorf("generate: %w", err)
+			}
+
+			activity, closeActivity := openActivityRecorder(ctx)
+			defer closeActivity()
+
+			minScore, maxChars := ragLimits()
+			spoolThreshold, spoolDir := spoolSettings()
 			tfAgent, err := agent.New(ctx, &agent.Config{
-				ChatModel: llm,
-				Tools:     agentTools,
-				Retriever: retriever,
+				ChatModel:            llm,
+				Tools:                ts.tools,
+				TerraformUnavailable: ts.unavailable,
+				Retriever:            retriever,
+				Reranker:             reranker,
+				RAGMinScore:          minScore,
+				RAGMaxChars:          maxChars,
+				Disclosure:           disclosureText(),
+				Activity:             activity,
+				ModelName:            generateModelName(ctx),
+				SpoolThreshold:       spoolThreshold,
+				SpoolDir:             spoolDir,
 			})
 			if err != nil {
 				return fmt.Errorf("generate: failed to initialise agent: %w", err)
@@ -62,26 +143,106 @@ Examples:
 				return fmt.Errorf("generate: failed to resolve output directory: %w", err)
 			}
 			outDir = absOutDir
+			// The agent only creates subdirectories beneath an existing
+			// workspace, so make sure --out exists before querying.
+			if err := os.MkdirAll(outDir, 0o755); err != nil {
+				return fmt.Errorf("generate: failed to create output directory: %w", err)
+			}
 
-			prompt := fmt.Sprintf(
-				"Generate production-grade Terraform code for the following and write the files to directory %q.\n\n"+
-					"Requirements:\n"+
-					"- Every resource and module block must have a comment above it explaining its purpose\n"+
-					"- Every variable must have a description field and a sensible default where applicable\n"+
-					"- Every output must have a description field\n"+
-					"- Group related resources with section comment headers (e.g. # ── Networking ──)\n"+
-					"- Use blank lines between blocks for readability\n"+
-					"- Apply security best practices by default (encryption, least-privilege IAM, private endpoints)\n\n"+
-					"Description: %s",
-				outDir, args[0],
-			)
-
-			_, err = tfAgent.Query(ctx, prompt, outDir, os.Stdout)
-			return err //nolint:wrapcheck // CLI entry point — error goes directly to cobra
+			if watch {
+				// Ctrl-C stops the watch loop; the in-flight generation is
+				// cancelled with it.
+				wctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+				defer stop()
+				w := &tfwatch.Watcher{
+					Path:    fromFile,
+					OutDir:  outDir,
+					Querier: timeoutQuerier{q: tfAgent, timeout: timeout},
+					Prompt: func(desc string, iteration int) string {
+						return generatePrompt(outDir, desc, iteration > 0)
+					},
+					Out:             os.Stdout,
+					Color:           isTerminal(os.Stdout),
+					ResolveConflict: conflictResolver(),
+				}
+				return w.Run(wctx) //nolint:wrapcheck // CLI entry point — error goes directly to cobra
+			}
+
+			description := ""
+			if len(args) > 0 {
+				description = args[0]
+			} else {
+				b, err := os.ReadFile(fromFile)
+				if err != nil {
+					return fmt.Errorf("generate: failed to read description file: %w", err)
+				}
+				description = string(b)
+			}
+
+			req := agent.QueryRequest{
+				Message:      generatePrompt(outDir, description, false),
+				WorkspaceDir: outDir,
+				Output:       os.Stdout,
+				Events:       stderrNotices{},
+				Options:      agent.QueryOptions{ExpectEnvelope: true, ResolveConflict: conflictResolver(), ImportMode: importMode},
+				RequestID:    requestID,
+			}
+			var answer strings.Builder
+			if format == "json" {
+				req.Output = &answer
+			}
+			start := time.Now()
+			res, err = timeoutQuerier{q: tfAgent, timeout: timeout}.Run(ctx, req)
+			if err != nil {
+				return err //nolint:wrapcheck // CLI entry point — error goes directly to cobra
+			}
+			if format == "json" {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(jsonResult(res, answer.String(), time.Since(start))); err != nil {
+					return fmt.Errorf("generate: failed to encode result: %w", err)
+				}
+				return nil
+			}
+			printDisclosure(os.Stdout, res, isTerminal(os.Stdout))
+			return nil
 		},
 	}
 
 	cmd.Flags().StringVarP(&outDir, "out", "o", ".", "Output directory for generated .tf files")
+	cmd.Flags().StringVarP(&fromFile, "from-file", "f", "", "Read the description from a file instead of the argument")
+	cmd.Flags().BoolVar(&watch, "watch", false, "Regenerate whenever the --from-file description changes")
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Stop a generation that runs longer than this, e.g. 3m (0 means no limit)")
+	cmd.Flags().BoolVar(&importMode, "import", false, "Write import blocks that adopt existing cloud resources along with their configuration")
 
 	return cmd
 }
+
+// generatePrompt builds the generation prompt for description. When
+// incremental is true the model is told to edit the files already in outDir
+// (which the agent injects as workspace context) rather than start over.
+func generatePrompt(outDir, description string, incremental bool) string {
+	intro := fmt.Sprintf("Generate production-grade Terraform code for the following and write the files to directory %q.", outDir)
+	if incremental {
+		intro = fmt.Sprintf("The description below has changed. Update the existing Terraform files in directory %q to match it. "+
+			"Make the smallest edits needed, keep unrelated files and blocks as they are, "+
+			"and return every file you change in full.", outDir)
+	}
+	return intro + "\n\n" +
+		"Requirements:\n" +
+		"- Every resource and module block must have a comment above it explaining its purpose\n" +
+		"- Every variable must have a description field and a sensible default where applicable\n" +
+		"- Every output must have a description field\n" +
+		"- Group related resources with section comment headers (e.g. # ── Networking ──)\n" +
+		"- Use blank lines between blocks for readability\n" +
+		"- Apply security best practices by default (encryption, least-privilege IAM, private endpoints)\n\n" +
+		"Description: " + description
+}
+
+// isTerminal reports whether f is a character device, used to decide
+// whether to emit ANSI colors.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
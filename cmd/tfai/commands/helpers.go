@@ -2,51 +2,168 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/tool"
-	"github.com/qdrant/go-client/qdrant"
 
+	"github.com/54b3r/tfai-go/internal/agent"
 	"github.com/54b3r/tfai-go/internal/embedder"
+	"github.com/54b3r/tfai-go/internal/logging"
 	"github.com/54b3r/tfai-go/internal/provider"
 	"github.com/54b3r/tfai-go/internal/rag"
 	"github.com/54b3r/tfai-go/internal/server"
+	"github.com/54b3r/tfai-go/internal/store"
 	tftools "github.com/54b3r/tfai-go/internal/tools"
+	"github.com/54b3r/tfai-go/pkg/api"
 )
 
-// Returns initialized models, agentTools, retriever,  error
-func initCommand(ctx context.Context) (*provider.ModelCfg, []tool.BaseTool, rag.Retriever, func(), error) {
+// Returns initialized models, agent tools, retriever,  error
+func initCommand(ctx context.Context) (*provider.ModelCfg, toolSet, rag.Retriever, func(), error) {
 
 	models, err := provider.NewFromEnv(ctx)
 	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("initCommand: failed to initialise model provider: %w", err)
+		return nil, toolSet{}, nil, nil, fmt.Errorf("initCommand: failed to initialise model provider: %w", err)
 	}
 
-	runner, err := tftools.NewExecRunner()
+	ts := loadTools()
+
+	retriever, closeRetriever, err := buildRetriever(ctx, logging.FromContext(ctx))
 	if err != nil {
-		// terraform not on PATH is non-fatal for ask — warn and continue.
-		fmt.Fprintf(os.Stderr, "initCommand: warning: %v (plan/state tools unavailable)\n", err)
-		runner = nil
+		return nil, toolSet{}, nil, nil, fmt.Errorf("initCommand: %w", err)
 	}
 
-	agentTools := buildTools(runner)
+	return models, ts, retriever, closeRetriever, err
+}
+
+// terraformToolNames lists the tools buildTools omits when the terraform
+// binary is unavailable.
+var terraformToolNames = []string{"terraform_init", "terraform_plan", "terraform_state", "terraform_validate", "terraform_fmt", "terraform_providers_lock"}
+
+// toolSet is the agent's tool list together with the reason the terraform
+// tools are missing from it, if they are.
+type toolSet struct {
+	// tools is passed to agent.Config.Tools.
+	tools []tool.BaseTool
+	// unavailable is non-nil when terraform is not on PATH; it is passed to
+	// agent.Config.TerraformUnavailable.
+	unavailable error
+}
 
-	retriever, closeRetriever, err := buildRetriever(ctx, slog.Default())
+// loadTools locates the terraform binary and builds the agent tools. A
+// missing binary is non-fatal: the terraform tools are omitted and a warning
+// on stderr says what the agent cannot do without them.
+func loadTools() toolSet {
+	runner, err := tftools.NewExecRunner()
 	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("initCommand: %w", err)
+		fmt.Fprintf(os.Stderr, "warning: %v\n"+
+			"warning: terraform plan, state, and validate are unavailable; the agent will give you the commands to run instead\n", err)
+		return toolSet{tools: buildTools(nil, false), unavailable: err}
+	}
+	allowApply := os.Getenv(tftools.AllowApplyEnv) == "true"
+	if allowApply {
+		fmt.Fprintf(os.Stderr, "warning: %s=true: the agent can run terraform apply, which changes real infrastructure\n", tftools.AllowApplyEnv)
+	}
+	return toolSet{tools: buildTools(runner, allowApply)}
+}
+
+// statuses reports the availability of each terraform tool for
+// GET /api/status.
+func (ts toolSet) statuses() []api.ToolStatus {
+	out := make([]api.ToolStatus, 0, len(terraformToolNames))
+	for _, name := range terraformToolNames {
+		st := api.ToolStatus{Name: name, Available: ts.unavailable == nil}
+		if ts.unavailable != nil {
+			st.Reason = ts.unavailable.Error()
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// catalogRunner lets buildTools construct every tool for the catalog. The
+// tools it backs are only described, never run.
+type catalogRunner struct{}
+
+// Run always fails.
+func (catalogRunner) Run(context.Context, *tftools.WorkspaceContext, string, ...string) (*tftools.RunResult, error) {
+	return nil, errors.New("catalog tools cannot run")
+}
+
+// catalog describes every tool buildTools can register, for GET /api/tools
+// and `tfai tools`. The tools are built by buildTools itself so the catalog
+// cannot drift from what the agent is given; those missing from ts.tools
+// are marked unavailable with the reason.
+func (ts toolSet) catalog(ctx context.Context) ([]api.ToolDescription, error) {
+	registered := make(map[string]bool, len(ts.tools))
+	for _, t := range ts.tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("tools: %w", err)
+		}
+		registered[info.Name] = true
+	}
+
+	all := buildTools(catalogRunner{}, true)
+	out := make([]api.ToolDescription, 0, len(all))
+	for _, t := range all {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("tools: %w", err)
+		}
+		params, err := info.ParamsOneOf.ToJSONSchema()
+		if err != nil {
+			return nil, fmt.Errorf("tools: %s: %w", info.Name, err)
+		}
+		schema, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("tools: %s: %w", info.Name, err)
+		}
+		c, ok := t.(tftools.Confirmable)
+		d := api.ToolDescription{
+			Name:                 info.Name,
+			Description:          info.Desc,
+			Parameters:           schema,
+			RequiresConfirmation: ok && c.RequiresConfirmation(),
+			Available:            registered[info.Name],
+		}
+		switch {
+		case d.Available:
+		case ts.unavailable != nil:
+			d.Reason = ts.unavailable.Error()
+		case info.Name == "terraform_apply":
+			d.Reason = tftools.AllowApplyEnv + " is not set to true"
+		default:
+			d.Reason = "not registered"
+		}
+		out = append(out, d)
 	}
+	return out, nil
+}
 
-	return models, agentTools, retriever, closeRetriever, err
+// stderrNotices prints agent notices to stderr so they do not mix with the
+// streamed answer on stdout.
+type stderrNotices struct {
+	agent.NopEventSink
+}
+
+// OnNotice prints notice to stderr.
+func (stderrNotices) OnNotice(notice string) {
+	fmt.Fprintf(os.Stderr, "notice: %s\n", notice)
 }
 
 // buildPingers constructs the readiness probes for GET /api/ready.
 // The LLM pinger is always included and uses a zero-cost HTTP health check
 // when the provider supports it, falling back to a Generate call otherwise.
-// A Qdrant pinger is added when QDRANT_HOST is set in the environment.
+// A Qdrant pinger is added when QDRANT_HOST is set in the environment; it
+// connects with the same settings, API key and TLS included, as the
+// retriever.
 func buildPingers(_ context.Context, chatModel model.ToolCallingChatModel, cfg *provider.Config, log *slog.Logger) []server.Pinger {
 	hc := provider.NewHealthCheckConfig(cfg.Backend, cfg)
 
@@ -54,22 +171,24 @@ func buildPingers(_ context.Context, chatModel model.ToolCallingChatModel, cfg *
 		server.NewLLMPinger(chatModel, hc, string(cfg.Backend)),
 	}
 
-	qdrantHost := os.Getenv("QDRANT_HOST")
-	if qdrantHost != "" {
-		client, err := qdrant.NewClient(&qdrant.Config{
-			Host: qdrantHost,
-			Port: getEnvInt("QDRANT_PORT", 6334),
-		})
-		if err != nil || client == nil {
+	qcfg, err := rag.QdrantConfigFromEnv()
+	if err != nil {
+		log.Warn("readiness: invalid qdrant settings, skipping probe", slog.Any("error", err))
+		return pingers
+	}
+	if qcfg.Host != "" {
+		client, err := newQdrantClient(qcfg)
+		if err != nil {
 			log.Warn("readiness: failed to create qdrant client, skipping probe",
-				slog.String("host", qdrantHost),
+				slog.String("host", qcfg.Host),
 				slog.Any("error", err),
 			)
 		} else {
 			pingers = append(pingers, server.NewQdrantPinger(client))
 			log.Info("readiness: qdrant probe registered",
-				slog.String("host", qdrantHost),
-				slog.Int("port", getEnvInt("QDRANT_PORT", 6334)),
+				slog.String("host", qcfg.Host),
+				slog.Int("port", qcfg.Port),
+				slog.Bool("tls", qcfg.UseTLS),
 			)
 		}
 	}
@@ -77,6 +196,10 @@ func buildPingers(_ context.Context, chatModel model.ToolCallingChatModel, cfg *
 	return pingers
 }
 
+// newQdrantClient creates the readiness probe's Qdrant client; tests
+// replace it to inspect the configuration without connecting.
+var newQdrantClient = rag.NewClientFromConfig
+
 // buildRetriever constructs a rag.Retriever when QDRANT_HOST is set in the
 // environment. Returns (nil, noop, nil) when Qdrant is not configured — the
 // agent treats a nil retriever as "RAG disabled". Returns a non-nil error when
@@ -86,8 +209,11 @@ func buildPingers(_ context.Context, chatModel model.ToolCallingChatModel, cfg *
 func buildRetriever(ctx context.Context, log *slog.Logger) (rag.Retriever, func(), error) {
 	noop := func() {}
 
-	qdrantHost := os.Getenv("QDRANT_HOST")
-	if qdrantHost == "" {
+	qcfg, err := rag.QdrantConfigFromEnv()
+	if err != nil {
+		return nil, noop, fmt.Errorf("rag: %w", err)
+	}
+	if qcfg.Host == "" {
 		return nil, noop, nil
 	}
 
@@ -100,52 +226,141 @@ func buildRetriever(ctx context.Context, log *slog.Logger) (rag.Retriever, func(
 		return nil, noop, fmt.Errorf("rag: failed to initialise embedder: %w", err)
 	}
 
-	qdrantPort := getEnvInt("QDRANT_PORT", 6334)
-	collection := getEnvOrDefault("QDRANT_COLLECTION", "tfai-docs")
-	vectorSize := uint64(embedder.DefaultDimensions(getEnvOrDefault("EMBEDDING_PROVIDER", getEnvOrDefault("MODEL_PROVIDER", "ollama")))) //nolint:gosec // dimensions are bounded
+	qcfg.VectorSize = uint64(embedder.DefaultDimensions(getEnvOrDefault("EMBEDDING_PROVIDER", getEnvOrDefault("MODEL_PROVIDER", "ollama")))) //nolint:gosec // dimensions are bounded
 
-	qstore, err := rag.NewQdrantStore(ctx, &rag.QdrantConfig{
-		Host:       qdrantHost,
-		Port:       qdrantPort,
-		Collection: collection,
-		VectorSize: vectorSize,
-		APIKey:     os.Getenv("QDRANT_API_KEY"),
-		UseTLS:     os.Getenv("QDRANT_TLS") == "true",
-	})
+	qstore, err := rag.NewQdrantStore(ctx, qcfg)
 	if err != nil {
-		return nil, noop, fmt.Errorf("rag: failed to connect to Qdrant at %s:%d: %w", qdrantHost, qdrantPort, err)
+		return nil, noop, fmt.Errorf("rag: failed to connect to Qdrant at %s:%d: %w", qcfg.Host, qcfg.Port, err)
 	}
 
-	retriever, err := rag.NewRetriever(emb, qstore, getEnvInt("RAG_TOP_K", 5))
+	retriever, err := rag.NewRetriever(emb, qstore, &rag.RetrieverConfig{
+		TopK:   getEnvInt("RAG_TOP_K", 5),
+		Hybrid: os.Getenv("RAG_HYBRID") == "true",
+	})
 	if err != nil {
 		_ = qstore.Close()
 		return nil, noop, fmt.Errorf("rag: failed to create retriever: %w", err)
 	}
 
 	log.Info("rag: retriever ready",
-		slog.String("host", qdrantHost),
-		slog.Int("port", qdrantPort),
-		slog.String("collection", collection),
+		slog.String("host", qcfg.Host),
+		slog.Int("port", qcfg.Port),
+		slog.String("collection", qcfg.Collection),
+		slog.Bool("hybrid", os.Getenv("RAG_HYBRID") == "true"),
 	)
 	return retriever, func() { _ = qstore.Close() }, nil
 }
 
+// errHistoryDisabled is returned by openHistoryDB when TFAI_HISTORY_DB is
+// "disabled".
+var errHistoryDisabled = errors.New("history is disabled via TFAI_HISTORY_DB=disabled")
+
+// openHistoryDB opens the conversation history database at TFAI_HISTORY_DB,
+// or at the default path (~/.tfai/history.db) when it is unset. The caller
+// must close the store.
+func openHistoryDB(ctx context.Context) (*store.SQLiteStore, error) {
+	dbPath := os.Getenv("TFAI_HISTORY_DB")
+	if dbPath == "disabled" {
+		return nil, errHistoryDisabled
+	}
+	if dbPath == "" {
+		var err error
+		if dbPath, err = store.DefaultDBPath(); err != nil {
+			return nil, err //nolint:wrapcheck // store errors are already prefixed
+		}
+	}
+	return store.Open(ctx, dbPath) //nolint:wrapcheck // store errors are already prefixed
+}
+
+// openActivityRecorder opens the history database for recording the files
+// a CLI command writes in the workspace activity feed, as the server does.
+// Activity is a record, not a requirement: when the database is disabled or
+// cannot be opened it returns nil, after a warning in the latter case. The
+// returned closer must be called.
+func openActivityRecorder(ctx context.Context) (store.ActivityRecorder, func()) {
+	hs, err := openHistoryDB(ctx)
+	if errors.Is(err, errHistoryDisabled) {
+		return nil, func() {}
+	}
+	if err != nil {
+		logging.FromContext(ctx).Warn("activity: failed to open history store; written files will not be recorded", slog.Any("error", err))
+		return nil, func() {}
+	}
+	return hs, func() { _ = hs.Close() }
+}
+
+// generateModelName returns the name of the model NewFromEnv uses for
+// generation, which labels recorded activity.
+func generateModelName(ctx context.Context) string {
+	cfg := provider.ConfigFromEnv()
+	if cfg.Generate != nil && cfg.Generate.Backend != cfg.Backend {
+		return cfg.WithGenerateOverrides(ctx).ModelName()
+	}
+	return cfg.ModelName()
+}
+
+// buildReranker constructs the rag.Reranker selected by RAG_RERANKER: "none"
+// (the default) returns nil, "lexical" a LexicalReranker, and "llm" an
+// LLMReranker backed by chat. It returns nil when retriever is nil, since
+// there is nothing to rerank.
+func buildReranker(retriever rag.Retriever, chat model.BaseChatModel) (rag.Reranker, error) {
+	if retriever == nil {
+		return nil, nil
+	}
+	switch kind := getEnvOrDefault("RAG_RERANKER", "none"); kind {
+	case "none":
+		return nil, nil
+	case "lexical":
+		return rag.NewLexicalReranker(), nil
+	case "llm":
+		r, err := rag.NewLLMReranker(chat)
+		if err != nil {
+			return nil, fmt.Errorf("rag: failed to create reranker: %w", err)
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("rag: unknown RAG_RERANKER %q (want none, lexical, or llm)", kind)
+	}
+}
+
+// ragLimits returns the agent.Config RAGMinScore and RAGMaxChars set by
+// RAG_MIN_SCORE and RAG_MAX_CHARS; zero values select the agent defaults.
+func ragLimits() (minScore float32, maxChars int) {
+	return float32(getEnvFloat("RAG_MIN_SCORE", 0)), getEnvInt("RAG_MAX_CHARS", 0)
+}
+
+// spoolSettings returns the agent.Config SpoolThreshold and SpoolDir set by
+// TFAI_SPOOL_THRESHOLD (in bytes) and TFAI_SPOOL_DIR; zero values select the
+// agent defaults.
+func spoolSettings() (threshold int, dir string) {
+	return getEnvInt("TFAI_SPOOL_THRESHOLD", 0), os.Getenv("TFAI_SPOOL_DIR")
+}
+
 // buildTools constructs the full list of Eino-compatible Terraform tools to
 // register with the agent. If runner is nil, tools that require a live
-// terraform binary are omitted gracefully.
+// terraform binary are omitted gracefully. terraform_apply is only added
+// when allowApply is set, from tftools.AllowApplyEnv.
 //
 // Note: terraform_generate is intentionally excluded. File generation is
-// handled by parseAgentOutput + applyFiles in agent.Query(), which parses
+// handled by parseAgentOutput + applyFiles in agent.Run(), which parses
 // the JSON envelope from the LLM's text response directly.
-func buildTools(runner tftools.Runner) []tool.BaseTool {
+func buildTools(runner tftools.Runner, allowApply bool) []tool.BaseTool {
 	var toolList []tool.BaseTool
 
-	// plan and state tools require a live terraform binary.
+	// init, plan, state, validate, fmt, and providers lock tools require a
+	// live terraform binary.
 	if runner != nil {
 		toolList = append(toolList,
-			tftools.NewPlanTool(runner),
+			tftools.NewInitTool(runner),
+			tftools.NewPlanTool(runner).WithSummaryBytes(getEnvInt(tftools.PlanSummaryBytesEnv, 0)),
 			tftools.NewStateTool(runner),
+			tftools.NewValidateTool(runner),
+			tftools.NewFmtTool(runner),
+			tftools.NewLockInfoTool(runner),
 		)
+		if allowApply {
+			toolList = append(toolList, tftools.NewApplyTool(runner))
+		}
 	}
 
 	return toolList
@@ -170,3 +385,58 @@ func getEnvInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvFloat returns the float value of the named environment variable, or
+// fallback if the variable is unset, empty, or not parseable as a float.
+func getEnvFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+// getEnvDuration returns the time.ParseDuration value of the named
+// environment variable, or zero if it is unset or empty. An unparseable
+// value is an error so a typo cannot silently fall back to the default.
+func getEnvDuration(key string) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", key, err)
+	}
+	return d, nil
+}
+
+// querier is the query interface of *agent.TerraformAgent.
+type querier interface {
+	Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error)
+}
+
+// timeoutQuerier runs each query through q with the --timeout flag's limit,
+// the same way the server applies TFAI_CHAT_TIMEOUT. Zero means no limit.
+type timeoutQuerier struct {
+	// q runs the queries.
+	q querier
+	// timeout is the time limit of each query.
+	timeout time.Duration
+}
+
+// Run runs req through q. A query the timeout stops fails with an
+// *agent.TimeoutError.
+func (t timeoutQuerier) Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	if t.timeout <= 0 {
+		return t.q.Run(ctx, req) //nolint:wrapcheck // the agent's errors are already prefixed
+	}
+	qctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	res, err := t.q.Run(qctx, req)
+	if err != nil && ctx.Err() == nil && errors.Is(qctx.Err(), context.DeadlineExceeded) {
+		return res, &agent.TimeoutError{After: t.timeout, Err: err}
+	}
+	return res, err //nolint:wrapcheck // the agent's errors are already prefixed
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/provider"
+	"github.com/54b3r/tfai-go/internal/rag"
+	tftools "github.com/54b3r/tfai-go/internal/tools"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+func TestBuildPingers_QdrantSettings(t *testing.T) {
+	t.Setenv("QDRANT_HOST", "qdrant.example.com")
+	t.Setenv("QDRANT_PORT", "6335")
+	t.Setenv("QDRANT_API_KEY", "secret")
+	t.Setenv("QDRANT_TLS", "true")
+	t.Setenv("QDRANT_DIAL_TIMEOUT", "")
+
+	var got *rag.QdrantConfig
+	orig := newQdrantClient
+	newQdrantClient = func(cfg *rag.QdrantConfig) (*qdrant.Client, error) {
+		got = cfg
+		return &qdrant.Client{}, nil
+	}
+	t.Cleanup(func() { newQdrantClient = orig })
+
+	pingers := buildPingers(context.Background(), nil, &provider.Config{Backend: provider.BackendOllama},
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if got == nil {
+		t.Fatal("expected the qdrant probe client built through rag.NewClientFromConfig")
+	}
+	if got.Host != "qdrant.example.com" || got.Port != 6335 || got.APIKey != "secret" || !got.UseTLS {
+		t.Errorf("expected the retriever's qdrant settings, got %+v", got)
+	}
+	if len(pingers) != 2 || pingers[1].Name() != "qdrant" {
+		t.Errorf("expected the LLM and qdrant pingers, got %d", len(pingers))
+	}
+}
+
+// blockingQuerier waits for the query context to end, like a hung provider.
+type blockingQuerier struct{}
+
+func (blockingQuerier) Run(ctx context.Context, _ agent.QueryRequest) (*agent.QueryResult, error) {
+	<-ctx.Done()
+	return &agent.QueryResult{ErrorCode: agent.CodeModel}, fmt.Errorf("agent: stream failed: %w", ctx.Err())
+}
+
+func TestTimeoutQuerier(t *testing.T) {
+	t.Parallel()
+
+	_, err := timeoutQuerier{q: blockingQuerier{}, timeout: 50 * time.Millisecond}.Run(context.Background(), agent.QueryRequest{})
+	var te *agent.TimeoutError
+	if !errors.As(err, &te) || err.Error() != "query timed out after 50ms" {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the timeout to wrap the deadline error, got %v", err)
+	}
+
+	// A caller's cancellation is not reported as the timeout.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = timeoutQuerier{q: blockingQuerier{}, timeout: time.Minute}.Run(ctx, agent.QueryRequest{})
+	if errors.As(err, &te) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation passed through, got %v", err)
+	}
+}
+
+// nopRunner is a tftools.Runner that is never expected to run.
+type nopRunner struct{}
+
+func (nopRunner) Run(context.Context, *tftools.WorkspaceContext, string, ...string) (*tftools.RunResult, error) {
+	return nil, errors.New("unexpected terraform run")
+}
+
+func TestBuildTools_ApplyGate(t *testing.T) {
+	t.Parallel()
+
+	names := func(allowApply bool, runner tftools.Runner) []string {
+		var out []string
+		for _, bt := range buildTools(runner, allowApply) {
+			info, err := bt.Info(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, info.Name)
+		}
+		return out
+	}
+	if got := names(false, nopRunner{}); slices.Contains(got, "terraform_apply") {
+		t.Errorf("expected terraform_apply to be left out without opt-in, got %v", got)
+	}
+	if got := names(true, nopRunner{}); !slices.Contains(got, "terraform_apply") {
+		t.Errorf("expected terraform_apply with opt-in, got %v", got)
+	}
+	if got := names(true, nil); len(got) != 0 {
+		t.Errorf("expected no terraform tools without a runner, got %v", got)
+	}
+}
+
+func TestToolSet_Catalog(t *testing.T) {
+	t.Parallel()
+
+	byName := func(ts toolSet) map[string]api.ToolDescription {
+		catalog, err := ts.catalog(context.Background())
+		if err != nil {
+			t.Fatalf("catalog: %v", err)
+		}
+		out := make(map[string]api.ToolDescription, len(catalog))
+		for _, d := range catalog {
+			out[d.Name] = d
+		}
+		return out
+	}
+
+	// Every tool is listed whatever is registered.
+	missing := errors.New("tools: terraform binary not found on PATH")
+	for name, d := range byName(toolSet{unavailable: missing}) {
+		if d.Available || d.Reason != missing.Error() {
+			t.Errorf("%s: expected unavailable with the lookup error, got %+v", name, d)
+		}
+	}
+
+	gated := byName(toolSet{tools: buildTools(nopRunner{}, false)})
+	if len(gated) != 7 {
+		t.Fatalf("expected all 7 tools in the catalog, got %d", len(gated))
+	}
+	if d := gated["terraform_apply"]; d.Available || d.Reason != "TFAI_ALLOW_APPLY is not set to true" {
+		t.Errorf("expected terraform_apply gated by TFAI_ALLOW_APPLY, got %+v", d)
+	}
+	if d := gated["terraform_validate"]; !d.Available || d.Reason != "" || d.RequiresConfirmation {
+		t.Errorf("expected terraform_validate available without confirmation, got %+v", d)
+	}
+	if d := byName(toolSet{tools: buildTools(nopRunner{}, true)})["terraform_apply"]; !d.Available || !d.RequiresConfirmation {
+		t.Errorf("expected terraform_apply available with confirmation, got %+v", d)
+	}
+
+	plan := gated["terraform_plan"]
+	if !plan.Available || !plan.RequiresConfirmation {
+		t.Errorf("expected terraform_plan available with confirmation, got %+v", plan)
+	}
+	var schema struct {
+		Type       string `json:"type"`
+		Properties map[string]struct {
+			Type  string `json:"type"`
+			Items *struct {
+				Type string `json:"type"`
+			} `json:"items"`
+		} `json:"properties"`
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(plan.Parameters, &schema); err != nil {
+		t.Fatalf("expected a JSON schema, got %s: %v", plan.Parameters, err)
+	}
+	if schema.Type != "object" || !slices.Equal(schema.Required, []string{"dir"}) ||
+		schema.Properties["dir"].Type != "string" || schema.Properties["destroy"].Type != "boolean" ||
+		schema.Properties["raw"].Type != "boolean" || schema.Properties["var_files"].Type != "array" ||
+		schema.Properties["var_files"].Items == nil || schema.Properties["var_files"].Items.Type != "string" {
+		t.Errorf("unexpected terraform_plan schema %s", plan.Parameters)
+	}
+}
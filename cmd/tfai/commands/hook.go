@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/check"
+	"github.com/54b3r/tfai-go/internal/githook"
+)
+
+// NewHookCmd constructs the `tfai hook` command group, which manages the git
+// pre-commit hook that runs `tfai check --staged`.
+func NewHookCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hook",
+		Short: "Install or remove a git pre-commit hook that runs tfai check",
+	}
+	cmd.AddCommand(newHookInstallCmd(), newHookUninstallCmd())
+	return cmd
+}
+
+// newHookInstallCmd constructs `tfai hook install`.
+func newHookInstallCmd() *cobra.Command {
+	var dir string
+	var checks string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "Write a pre-commit hook that runs tfai check --staged",
+		Long: `Write the pre-commit hook of the git repository containing --dir. Before
+every commit the hook runs ` + "`tfai check --staged`" + ` on the staged .tf files
+under --dir and stops the commit on blocking findings. tfai must be on the
+PATH of whoever commits. Bypass the hook once with ` + "`git commit --no-verify`" + `.
+
+An existing pre-commit hook that tfai did not write is left alone unless
+--force is given. core.hooksPath is honoured.
+
+Examples:
+  tfai hook install
+  tfai hook install --dir ./infra --checks fmt,secrets`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			selected, err := check.ParseChecks(checks)
+			if err != nil {
+				return fmt.Errorf("hook install: %w", err)
+			}
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("hook install: failed to resolve directory: %w", err)
+			}
+			path, err := githook.Install(cmd.Context(), absDir, selected, force)
+			if err != nil {
+				return fmt.Errorf("hook install: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Installed %s (checks: %s)\n", path, strings.Join(selected, ","))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", ".", "Directory inside the repository whose staged files are checked")
+	cmd.Flags().StringVar(&checks, "checks", "", "Comma-separated checks to run: "+strings.Join(check.All, ",")+" (default: all)")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing pre-commit hook not written by tfai")
+
+	return cmd
+}
+
+// newHookUninstallCmd constructs `tfai hook uninstall`.
+func newHookUninstallCmd() *cobra.Command {
+	var dir string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the pre-commit hook written by tfai hook install",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("hook uninstall: failed to resolve directory: %w", err)
+			}
+			path, err := githook.Uninstall(cmd.Context(), absDir, force)
+			if err != nil {
+				return fmt.Errorf("hook uninstall: %w", err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Removed %s\n", path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", ".", "Directory inside the repository")
+	cmd.Flags().BoolVar(&force, "force", false, "Remove the pre-commit hook even if tfai did not write it")
+
+	return cmd
+}
@@ -1,14 +1,17 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
-	"os"
+	"regexp"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/54b3r/tfai-go/internal/embedder"
 	"github.com/54b3r/tfai-go/internal/ingestion"
+	"github.com/54b3r/tfai-go/internal/logging"
 	"github.com/54b3r/tfai-go/internal/rag"
 )
 
@@ -19,6 +22,16 @@ func NewIngestCmd() *cobra.Command {
 	var framework string
 	var docType string
 	var urls []string
+	var presetNames []string
+	var statePath string
+	var resumePath string
+	var concurrency int
+	var sitemapURL string
+	var crawlDepth int
+	var includePattern string
+	var limit int
+	var dryRun bool
+	var chunkStrategy string
 
 	cmd := &cobra.Command{
 		Use:   "ingest",
@@ -33,6 +46,8 @@ Required environment variables:
   QDRANT_PORT          Qdrant gRPC port (default: 6334)
   QDRANT_COLLECTION    Collection name (default: tfai-docs)
   QDRANT_API_KEY       Optional API key for authenticated clusters
+  QDRANT_TLS           Set to "true" to connect over TLS
+  QDRANT_DIAL_TIMEOUT  Time allowed per gRPC connection attempt (e.g. 10s)
   MODEL_PROVIDER       Embedding backend: ollama, openai, azure (default: ollama)
   EMBEDDING_*          Provider-specific overrides (see README)
 
@@ -40,16 +55,99 @@ Metadata flags (--provider, --framework, --doc-type) are optional. When omitted,
 metadata is auto-inferred from the URL pattern (e.g. registry.terraform.io URLs
 resolve provider and framework automatically). Explicit flags override inference.
 
+--preset adds a built-in list of URLs and is repeatable. "aws-core",
+"azure-core", and "gcp-core" cover each provider's most used resources and
+guides, "terraform-lang" the language and CLI reference, and "upgrade-guides"
+the official provider major-version upgrade guides used by ` + "`tfai upgrade`" + `.
+Preset sources keep their own metadata; --provider and friends apply to --url
+only. Chunks record the preset they came from, so ` + "`tfai rag status`" + ` reports
+coverage per preset. --dry-run prints the expanded list.
+
 Examples:
   tfai ingest --url https://registry.terraform.io/providers/hashicorp/aws/latest/docs/resources/eks_cluster
   tfai ingest --url https://atmos.tools/core-concepts/stacks
-  tfai ingest --provider aws --framework terraform --url https://example.com/custom-aws-doc`,
-		RunE: func(cmd *cobra.Command, args []string) error {
+  tfai ingest --provider aws --framework terraform --url https://example.com/custom-aws-doc
+  tfai ingest --preset aws-core --preset terraform-lang --dry-run
+  tfai ingest --preset upgrade-guides --state ingest-state.json
+  tfai ingest --resume ingest-state.json
+  tfai ingest --sitemap https://example.com/sitemap.xml --include-pattern '/docs/resources/' --dry-run
+  tfai ingest --sitemap https://atmos.tools/core-concepts --crawl-depth 2 --limit 50
+
+--state checkpoints the run's progress to a file every 25 pages. If the run is
+interrupted, --resume continues from the checkpoint: finished pages are not
+fetched again and failed pages are retried. A state file can only be resumed
+with the same chunking and embedding settings it was written with.
+
+--concurrency pages are fetched, embedded, and stored in parallel.
+
+--sitemap reads a sitemap.xml (or sitemap index) and ingests every page it
+lists, with metadata inferred from each URL as for --url. With --crawl-depth
+the URL is an index page instead, and same-host links are followed that many
+levels deep. --include-pattern keeps only the URLs matching a regular
+expression, and --limit (default 200, 0 for none) caps the number of pages.
+--dry-run prints the URLs that would be ingested and exits.
+
+Except with --dry-run, the last line on stderr is a summary for CI logs:
+  tfai_result status=partial sources_ok=41 sources_failed=2 chunks=1873
+status is ok, partial (some pages failed or the run was interrupted), or
+failed.`,
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
 			ctx := cmd.Context()
-			log := slog.Default()
+			log := logging.FromContext(ctx)
 
-			if len(urls) == 0 {
-				return fmt.Errorf("ingest: at least one --url is required")
+			var report *ingestion.Report
+			if !dryRun {
+				defer func() { writeResultLine(cmd.ErrOrStderr(), ingestResultFields(report, err)) }()
+			}
+
+			if len(urls) == 0 && len(presetNames) == 0 && resumePath == "" && sitemapURL == "" {
+				return fmt.Errorf("ingest: at least one --url, --preset, --sitemap, or --resume is required")
+			}
+			if statePath != "" && resumePath != "" {
+				return fmt.Errorf("ingest: --state and --resume are mutually exclusive")
+			}
+			if sitemapURL == "" && (cmd.Flags().Changed("crawl-depth") || includePattern != "" || cmd.Flags().Changed("limit")) {
+				return fmt.Errorf("ingest: --crawl-depth, --include-pattern, and --limit require --sitemap")
+			}
+			if dryRun && resumePath != "" {
+				return fmt.Errorf("ingest: --dry-run cannot be combined with --resume")
+			}
+
+			if sitemapURL != "" {
+				discovered, err := discoverSources(ctx, sitemapURL, crawlDepth, includePattern, limit)
+				if err != nil {
+					return err
+				}
+				log.Info("sitemap discovered",
+					slog.String("sitemap", sitemapURL),
+					slog.Int("urls", len(discovered.URLs)),
+					slog.Bool("truncated", discovered.Truncated),
+				)
+				if discovered.Truncated {
+					log.Warn("sitemap: more pages matched than --limit allows; raise --limit or narrow --include-pattern",
+						slog.Int("limit", limit))
+				}
+				urls = append(urls, discovered.URLs...)
+			}
+
+			var presetSources []ingestion.Source
+			for _, name := range presetNames {
+				expanded, err := ingestion.ExpandPreset(name)
+				if err != nil {
+					return fmt.Errorf("ingest: %w", err)
+				}
+				presetSources = append(presetSources, expanded...)
+			}
+
+			if dryRun {
+				out := cmd.OutOrStdout()
+				for _, u := range urls {
+					fmt.Fprintln(out, u)
+				}
+				for _, src := range presetSources {
+					fmt.Fprintln(out, src.URL)
+				}
+				return nil
 			}
 
 			if err := embedder.ValidateForRAG(log); err != nil {
@@ -62,27 +160,34 @@ Examples:
 			}
 			log.Info("embedder initialised", slog.String("provider", getEnvOrDefault("EMBEDDING_PROVIDER", getEnvOrDefault("MODEL_PROVIDER", "ollama"))))
 
-			qdrantHost := getEnvOrDefault("QDRANT_HOST", "localhost")
-			qdrantPort := getEnvInt("QDRANT_PORT", 6334)
-			collection := getEnvOrDefault("QDRANT_COLLECTION", "tfai-docs")
+			// Ingest defaults QDRANT_HOST to localhost rather than
+			// requiring it.
+			qcfg, err := rag.QdrantConfigFromEnv()
+			if err != nil {
+				return fmt.Errorf("ingest: %w", err)
+			}
 			embBackend := getEnvOrDefault("EMBEDDING_PROVIDER", getEnvOrDefault("MODEL_PROVIDER", "ollama"))
-			vectorSize := uint64(embedder.DefaultDimensions(embBackend)) //nolint:gosec // dimensions are bounded
-
-			store, err := rag.NewQdrantStore(ctx, &rag.QdrantConfig{
-				Host:       qdrantHost,
-				Port:       qdrantPort,
-				Collection: collection,
-				VectorSize: vectorSize,
-				APIKey:     os.Getenv("QDRANT_API_KEY"),
-				UseTLS:     os.Getenv("QDRANT_TLS") == "true",
-			})
+			qcfg.VectorSize = uint64(embedder.DefaultDimensions(embBackend)) //nolint:gosec // dimensions are bounded
+
+			store, err := rag.NewQdrantStore(ctx, qcfg)
 			if err != nil {
-				return fmt.Errorf("ingest: failed to connect to Qdrant at %s:%d: %w", qdrantHost, qdrantPort, err)
+				return fmt.Errorf("ingest: failed to connect to Qdrant at %s:%d: %w", qcfg.Host, qcfg.Port, err)
 			}
 			defer func() { _ = store.Close() }()
-			log.Info("qdrant store ready", slog.String("host", qdrantHost), slog.Int("port", qdrantPort), slog.String("collection", collection))
+			log.Info("qdrant store ready", slog.String("host", qcfg.Host), slog.Int("port", qcfg.Port), slog.String("collection", qcfg.Collection))
 
-			pipeline, err := ingestion.NewPipeline(emb, store, nil)
+			// EMBEDDING_MAX_RETRIES=0 disables retries; RetryPolicy reads a
+			// zero MaxRetries as the default.
+			maxRetries := getEnvInt("EMBEDDING_MAX_RETRIES", embedder.DefaultMaxRetries)
+			if maxRetries == 0 {
+				maxRetries = -1
+			}
+			pipeline, err := ingestion.NewPipeline(emb, store, &ingestion.Config{
+				EmbedderID:    embedder.IDFromEnv(),
+				EmbedRetry:    embedder.RetryPolicy{MaxRetries: maxRetries},
+				Concurrency:   concurrency,
+				ChunkStrategy: chunkStrategy,
+			})
 			if err != nil {
 				return fmt.Errorf("ingest: failed to create pipeline: %w", err)
 			}
@@ -91,7 +196,7 @@ Examples:
 			frameworkSet := cmd.Flags().Changed("framework")
 			docTypeSet := cmd.Flags().Changed("doc-type")
 
-			sources := make([]ingestion.Source, 0, len(urls))
+			sources := make([]ingestion.Source, 0, len(urls)+len(presetSources))
 			for _, u := range urls {
 				inferred := ingestion.InferMetadata(u)
 
@@ -120,16 +225,56 @@ Examples:
 				)
 				sources = append(sources, src)
 			}
+			for _, src := range presetSources {
+				log.Info("source metadata",
+					slog.String("url", src.URL),
+					slog.String("provider", src.Provider),
+					slog.String("framework", src.Framework),
+					slog.String("doc_type", src.DocType),
+					slog.String("preset", src.Preset),
+				)
+			}
+			sources = append(sources, presetSources...)
 
-			log.Info("starting ingestion", slog.Int("sources", len(sources)))
+			state := ingestion.NewState(pipeline.Fingerprint())
+			if resumePath != "" {
+				state, err = ingestion.LoadState(resumePath, pipeline.Fingerprint())
+				if err != nil {
+					return fmt.Errorf("ingest: %w", err)
+				}
+				statePath = resumePath
+			}
+			for _, src := range sources {
+				state.Enqueue(src)
+			}
 
-			if err := pipeline.Ingest(ctx, sources, func(msg string) {
-				log.Info(msg)
-			}); err != nil {
+			log.Info("starting ingestion", slog.Int("pages", len(state.Pages)), slog.String("state", statePath))
+
+			report, err = pipeline.Run(ctx, state, ingestion.RunOptions{
+				StatePath: statePath,
+				Progress:  func(msg string) { log.Info(msg) },
+			})
+			if err != nil {
+				if statePath != "" {
+					return fmt.Errorf("ingest: run interrupted (resume with --resume %s): %w", statePath, err)
+				}
 				return fmt.Errorf("ingest: pipeline failed: %w", err)
 			}
 
-			log.Info("ingestion complete", slog.Int("sources", len(sources)))
+			log.Info("ingestion complete",
+				slog.Int("new", report.New),
+				slog.Int("skipped", report.Skipped),
+				slog.Int("failed", len(report.Failed)),
+			)
+			if len(report.Failed) > 0 {
+				for _, pg := range report.Failed {
+					log.Error("page failed", slog.String("url", pg.Source.URL), slog.String("error", pg.Error))
+				}
+				if statePath != "" {
+					return fmt.Errorf("ingest: %d page(s) failed; retry them with --resume %s", len(report.Failed), statePath)
+				}
+				return fmt.Errorf("ingest: %d page(s) failed", len(report.Failed))
+			}
 			return nil
 		},
 	}
@@ -138,6 +283,37 @@ Examples:
 	cmd.Flags().StringVarP(&framework, "framework", "f", "terraform", "IaC framework label (terraform, atmos, terragrunt, cdktf)")
 	cmd.Flags().StringVarP(&docType, "doc-type", "d", "reference", "Documentation type (reference, tutorial, guide, api, changelog)")
 	cmd.Flags().StringArrayVarP(&urls, "url", "u", nil, "Documentation URL to ingest (repeatable)")
+	cmd.Flags().StringArrayVar(&presetNames, "preset", nil, "Built-in URL list to ingest (repeatable): "+strings.Join(ingestion.PresetNames(), ", "))
+	cmd.Flags().StringVar(&statePath, "state", "", "Checkpoint run progress to this file so an interrupted run can be resumed")
+	cmd.Flags().StringVar(&resumePath, "resume", "", "Resume the run checkpointed in this state file")
+	cmd.Flags().IntVar(&concurrency, "concurrency", ingestion.DefaultConcurrency, "Number of pages ingested in parallel")
+	cmd.Flags().StringVar(&sitemapURL, "sitemap", "", "Sitemap (or, with --crawl-depth, index page) URL whose pages are ingested")
+	cmd.Flags().IntVar(&crawlDepth, "crawl-depth", 0, "Crawl the --sitemap URL as an HTML index page, following links this many levels deep")
+	cmd.Flags().StringVar(&includePattern, "include-pattern", "", "Regular expression a --sitemap URL must match to be ingested")
+	cmd.Flags().IntVar(&limit, "limit", ingestion.DefaultDiscoverLimit, "Maximum number of --sitemap pages to ingest (0 for no limit)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the URLs that would be ingested and exit")
+	cmd.Flags().StringVar(&chunkStrategy, "chunk-strategy", ingestion.ChunkStrategyHeading, "How pages are split into chunks: heading (at headings and paragraphs) or fixed (every 1000 characters)")
 
 	return cmd
 }
+
+// discoverSources expands --sitemap into page URLs. A --limit of 0 means no
+// limit, which ingestion.DiscoverOptions spells as a negative Limit.
+func discoverSources(ctx context.Context, sitemapURL string, crawlDepth int, includePattern string, limit int) (*ingestion.Discovery, error) {
+	opts := ingestion.DiscoverOptions{CrawlDepth: crawlDepth, Limit: limit}
+	if limit == 0 {
+		opts.Limit = -1
+	}
+	if includePattern != "" {
+		re, err := regexp.Compile(includePattern)
+		if err != nil {
+			return nil, fmt.Errorf("ingest: invalid --include-pattern: %w", err)
+		}
+		opts.Include = re
+	}
+	discovered, err := ingestion.Discover(ctx, sitemapURL, opts)
+	if err != nil {
+		return nil, fmt.Errorf("ingest: %w", err)
+	}
+	return discovered, nil
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/embedder"
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// NewRAGCmd constructs the `tfai rag` command group for inspecting the RAG
+// vector store.
+func NewRAGCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rag",
+		Short: "Inspect the RAG vector store",
+	}
+	cmd.AddCommand(newRAGStatusCmd(), newRAGSearchCmd(), newRAGPurgeCmd())
+	return cmd
+}
+
+// openRAGStore connects to the Qdrant collection tfai ingest writes to,
+// configured from the same environment, without creating it. The vector
+// size is the default of the configured embedding backend. name prefixes
+// errors.
+func openRAGStore(name string) (*rag.QdrantStore, error) {
+	cfg, err := rag.QdrantConfigFromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("%s: QDRANT_HOST is not set; point it at the Qdrant server tfai ingest writes to", name)
+	}
+	embBackend := getEnvOrDefault("EMBEDDING_PROVIDER", getEnvOrDefault("MODEL_PROVIDER", "ollama"))
+	cfg.VectorSize = uint64(embedder.DefaultDimensions(embBackend)) //nolint:gosec // dimensions are bounded
+	store, err := rag.OpenQdrantStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to connect to Qdrant at %s:%d: %w", name, cfg.Host, cfg.Port, err)
+	}
+	return store, nil
+}
+
+// newRAGStatusCmd constructs `tfai rag status`, which reports what the
+// Qdrant collection holds.
+func newRAGStatusCmd() *cobra.Command {
+	var asJSON bool
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the collection's size and what is indexed in it",
+		Long: `Connect to Qdrant with the same environment as ` + "`tfai ingest`" + ` and print the
+collection name, vector size, total point count, and the number of points
+per provider, framework, doc_type, and preset. The preset breakdown shows
+how much of each ` + "`tfai ingest --preset`" + ` list is indexed. Points ingested
+without one of these fields are counted as (none).
+
+Environment variables:
+  QDRANT_HOST          Qdrant server hostname (required)
+  QDRANT_PORT          Qdrant gRPC port (default: 6334)
+  QDRANT_COLLECTION    Collection name (default: tfai-docs)
+  QDRANT_API_KEY       Optional API key for authenticated clusters
+  QDRANT_TLS           Set to "true" to connect over TLS
+  QDRANT_DIAL_TIMEOUT  Time allowed per gRPC connection attempt (e.g. 10s)
+
+Examples:
+  tfai rag status
+  tfai rag status --json | jq '.breakdown.provider'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openRAGStore("rag status")
+			if err != nil {
+				return err
+			}
+			defer func() { _ = store.Close() }()
+
+			status, err := store.Status(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("rag status: %w", err)
+			}
+			if asJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(status); err != nil {
+					return fmt.Errorf("rag status: failed to write JSON: %w", err)
+				}
+				return nil
+			}
+			return printRAGStatus(cmd.OutOrStdout(), status)
+		},
+	}
+
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the status as JSON")
+
+	return cmd
+}
+
+// printRAGStatus writes status as a header followed by one table per
+// breakdown field, values with the most points first.
+func printRAGStatus(w io.Writer, status *rag.CollectionStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Collection:\t%s\n", status.Collection)
+	fmt.Fprintf(tw, "Vector size:\t%d\n", status.VectorSize)
+	fmt.Fprintf(tw, "Points:\t%d\n", status.Points)
+	for _, field := range rag.StatusFields {
+		counts := status.Breakdown[field]
+		values := make([]string, 0, len(counts))
+		for v := range counts {
+			values = append(values, v)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if counts[values[i]] != counts[values[j]] {
+				return counts[values[i]] > counts[values[j]]
+			}
+			return values[i] < values[j]
+		})
+		fmt.Fprintf(tw, "\n%s\tPOINTS\n", strings.ToUpper(field))
+		for _, v := range values {
+			fmt.Fprintf(tw, "%s\t%d\n", v, counts[v])
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("rag status: failed to write table: %w", err)
+	}
+	return nil
+}
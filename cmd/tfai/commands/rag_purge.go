@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// purgeProviders are the provider values ingestion stores, and so the only
+// ones --provider accepts.
+var purgeProviders = []string{"aws", "azure", "gcp"}
+
+// newRAGPurgeCmd constructs `tfai rag purge`, which deletes documents from
+// the Qdrant collection.
+func newRAGPurgeCmd() *cobra.Command {
+	var (
+		source   string
+		provider string
+		all      bool
+		yes      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete documents by source URL or provider, or empty the collection",
+		Long: `Delete the points ingested from --source, or tagged with --provider, from the
+Qdrant collection. Both flags together delete the points matching both.
+--all instead drops the collection and recreates it empty, with the vector
+size of the configured embedding backend.
+
+The number of matching points is shown and confirmed before anything is
+deleted. --yes skips the confirmation, and is required when stdin is not a
+terminal.
+
+Uses the same QDRANT_* environment as ` + "`tfai rag status`" + `.
+
+Examples:
+  tfai rag purge --source https://developer.hashicorp.com/terraform/language/upgrade-guides
+  tfai rag purge --provider gcp --yes
+  tfai rag purge --all`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := purgeFilter(source, provider, all)
+			if err != nil {
+				return fmt.Errorf("rag purge: %w", err)
+			}
+			store, err := openRAGStore("rag purge")
+			if err != nil {
+				return err
+			}
+			defer func() { _ = store.Close() }()
+
+			ctx := cmd.Context()
+			out := cmd.OutOrStdout()
+			n, err := store.Count(ctx, filter)
+			if err != nil {
+				return fmt.Errorf("rag purge: %w", err)
+			}
+			if n == 0 && !all {
+				fmt.Fprintln(out, "No matching points.")
+				return nil
+			}
+			prompt := fmt.Sprintf("Delete %d point(s) matching %s?", n, describePurge(filter))
+			if all {
+				prompt = fmt.Sprintf("Drop and recreate the collection, deleting all %d point(s)?", n)
+			}
+			ok, err := confirmPurge(os.Stdin, cmd.ErrOrStderr(), prompt, yes, isTerminal(os.Stdin))
+			if err != nil {
+				return fmt.Errorf("rag purge: %w", err)
+			}
+			if !ok {
+				fmt.Fprintln(out, "Nothing deleted.")
+				return nil
+			}
+
+			if all {
+				n, err = store.Recreate(ctx)
+			} else {
+				n, err = store.DeleteByFilter(ctx, filter)
+			}
+			if err != nil {
+				return fmt.Errorf("rag purge: %w", err)
+			}
+			fmt.Fprintf(out, "Deleted %d point(s).\n", n)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&source, "source", "", "Delete the points ingested from this URL")
+	cmd.Flags().StringVar(&provider, "provider", "", "Delete the points of this provider: "+strings.Join(purgeProviders, ", "))
+	cmd.Flags().BoolVar(&all, "all", false, "Drop and recreate the whole collection")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Delete without asking for confirmation")
+
+	return cmd
+}
+
+// purgeFilter builds the filter the purge flags select. --all selects every
+// point, and so cannot be combined with the other flags; without it at least
+// one of source and provider is required.
+func purgeFilter(source, provider string, all bool) (rag.SearchFilter, error) {
+	if all {
+		if source != "" || provider != "" {
+			return rag.SearchFilter{}, fmt.Errorf("--all cannot be combined with --source or --provider")
+		}
+		return rag.SearchFilter{}, nil
+	}
+	if source == "" && provider == "" {
+		return rag.SearchFilter{}, fmt.Errorf("one of --source, --provider, or --all is required")
+	}
+	if provider != "" && !slices.Contains(purgeProviders, provider) {
+		return rag.SearchFilter{}, fmt.Errorf("unknown provider %q; expected one of %s", provider, strings.Join(purgeProviders, ", "))
+	}
+	return rag.SearchFilter{Source: source, Provider: provider}, nil
+}
+
+// describePurge renders filter for the confirmation prompt.
+func describePurge(filter rag.SearchFilter) string {
+	var parts []string
+	if filter.Source != "" {
+		parts = append(parts, "source="+filter.Source)
+	}
+	if filter.Provider != "" {
+		parts = append(parts, "provider="+filter.Provider)
+	}
+	return strings.Join(parts, " and ")
+}
+
+// confirmPurge reports whether the purge may go ahead: immediately when yes
+// is set, otherwise after prompt is answered y on out. Without yes, a
+// non-interactive stdin is an error rather than a silent no, so scripts
+// learn they need --yes.
+func confirmPurge(in io.Reader, out io.Writer, prompt string, yes, interactive bool) (bool, error) {
+	if yes {
+		return true, nil
+	}
+	if !interactive {
+		return false, fmt.Errorf("stdin is not a terminal; pass --yes to delete without confirmation")
+	}
+	_, _ = fmt.Fprintf(out, "%s [y/N] ", prompt)
+	line, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
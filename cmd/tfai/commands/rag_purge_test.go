@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+func TestPurgeFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		source   string
+		provider string
+		all      bool
+		want     rag.SearchFilter
+		wantErr  string
+	}{
+		{name: "source", source: "https://example.com/a", want: rag.SearchFilter{Source: "https://example.com/a"}},
+		{name: "provider", provider: "azure", want: rag.SearchFilter{Provider: "azure"}},
+		{name: "source and provider", source: "https://example.com/a", provider: "gcp", want: rag.SearchFilter{Source: "https://example.com/a", Provider: "gcp"}},
+		{name: "all", all: true},
+		{name: "nothing selected", wantErr: "one of --source, --provider, or --all"},
+		{name: "unknown provider", provider: "azurerm", wantErr: `unknown provider "azurerm"`},
+		{name: "all with a filter", provider: "aws", all: true, wantErr: "--all cannot be combined"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := purgeFilter(tc.source, tc.provider, tc.all)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConfirmPurge(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		input       string
+		yes         bool
+		interactive bool
+		want        bool
+		wantErr     bool
+		wantPrompt  bool
+	}{
+		{name: "yes skips the prompt", yes: true, want: true},
+		{name: "yes without a terminal", yes: true, interactive: false, want: true},
+		{name: "no terminal requires yes", wantErr: true},
+		{name: "answered y", input: "y\n", interactive: true, want: true, wantPrompt: true},
+		{name: "answered yes", input: "YES\n", interactive: true, want: true, wantPrompt: true},
+		{name: "answered n", input: "n\n", interactive: true, wantPrompt: true},
+		{name: "empty answer", input: "\n", interactive: true, wantPrompt: true},
+		{name: "end of input", interactive: true, wantPrompt: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var out strings.Builder
+			got, err := confirmPurge(strings.NewReader(tc.input), &out, "Delete 3 point(s)?", tc.yes, tc.interactive)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+			if prompted := strings.Contains(out.String(), "Delete 3 point(s)? [y/N]"); prompted != tc.wantPrompt {
+				t.Errorf("expected prompt=%v, got %q", tc.wantPrompt, out.String())
+			}
+		})
+	}
+}
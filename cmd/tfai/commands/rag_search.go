@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// searchSnippetChars is how much of each hit's content `tfai rag search`
+// prints without --full.
+const searchSnippetChars = 200
+
+// ragHit is one search result as `tfai rag search --json` prints it.
+type ragHit struct {
+	// Score is the retrieval score.
+	Score float32 `json:"score"`
+	// Source is the URL the document was ingested from.
+	Source string `json:"source"`
+	// Provider is the provider metadata, if any.
+	Provider string `json:"provider,omitempty"`
+	// DocType is the doc_type metadata, if any.
+	DocType string `json:"doc_type,omitempty"`
+	// Content is the document content, cut to searchSnippetChars unless
+	// --full is set.
+	Content string `json:"content"`
+}
+
+// newRAGSearchCmd constructs `tfai rag search`, which runs one retrieval
+// the way the agent does and prints the hits.
+func newRAGSearchCmd() *cobra.Command {
+	var (
+		topK   int
+		full   bool
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Run one RAG retrieval and print the hits",
+		Long: `Embed the query and retrieve documents through the same retriever the agent
+uses, configured from the same environment (QDRANT_*, the embedding
+variables, RAG_HYBRID), and print each hit's score, source, provider,
+doc_type, and the first 200 characters of its content.
+
+Use it to debug retrieval quality: an embedding dimension mismatch or an
+empty collection shows up here as an error or as no hits.
+
+Examples:
+  tfai rag search "eks managed node group scaling"
+  tfai rag search --top-k 10 --full "azurerm_kubernetes_cluster node pools"
+  tfai rag search --json "s3 bucket versioning" | jq '.[].source'`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if topK < 1 {
+				return fmt.Errorf("rag search: --top-k must be at least 1")
+			}
+			retriever, closeRetriever, err := buildRetriever(cmd.Context(), logging.FromContext(cmd.Context()))
+			if err != nil {
+				return fmt.Errorf("rag search: %w", err)
+			}
+			defer closeRetriever()
+			if retriever == nil {
+				return fmt.Errorf("rag search: QDRANT_HOST is not set; point it at the Qdrant server tfai ingest writes to")
+			}
+
+			return runRAGSearch(cmd.Context(), cmd.OutOrStdout(), retriever, args[0], topK, full, asJSON)
+		},
+	}
+
+	cmd.Flags().IntVar(&topK, "top-k", 5, "Number of documents to retrieve")
+	cmd.Flags().BoolVar(&full, "full", false, "Print each document's full content")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the hits as JSON")
+
+	return cmd
+}
+
+// runRAGSearch retrieves the topK documents for query from retriever and
+// writes them to w as text or, with asJSON, as JSON.
+func runRAGSearch(ctx context.Context, w io.Writer, retriever rag.Retriever, query string, topK int, full, asJSON bool) error {
+	docs, err := retriever.Retrieve(ctx, query, topK)
+	if err != nil {
+		return fmt.Errorf("rag search: %w", err)
+	}
+	if asJSON {
+		return writeSearchJSON(w, docs, full)
+	}
+	printSearchResults(w, docs, full)
+	return nil
+}
+
+// searchHits converts docs to ragHits, cutting their content to
+// searchSnippetChars unless full is set.
+func searchHits(docs []rag.Document, full bool) []ragHit {
+	hits := make([]ragHit, 0, len(docs))
+	for _, d := range docs {
+		content := d.Content
+		if !full {
+			content = snippet(content, searchSnippetChars)
+		}
+		hits = append(hits, ragHit{
+			Score:    d.Score,
+			Source:   d.Source,
+			Provider: d.Metadata["provider"],
+			DocType:  d.Metadata["doc_type"],
+			Content:  content,
+		})
+	}
+	return hits
+}
+
+// snippet returns the first n characters of s with runs of whitespace
+// collapsed to single spaces, followed by "..." when s was longer.
+func snippet(s string, n int) string {
+	r := []rune(strings.Join(strings.Fields(s), " "))
+	if len(r) <= n {
+		return string(r)
+	}
+	return string(r[:n]) + "..."
+}
+
+// printSearchResults writes one numbered block per hit: the score and
+// source, the metadata, and the content indented below.
+func printSearchResults(w io.Writer, docs []rag.Document, full bool) {
+	if len(docs) == 0 {
+		fmt.Fprintln(w, "No results. Check that the collection is not empty with `tfai rag status`.")
+		return
+	}
+	for i, h := range searchHits(docs, full) {
+		fmt.Fprintf(w, "%d. %.4f  %s\n", i+1, h.Score, h.Source)
+		fmt.Fprintf(w, "   provider=%s doc_type=%s\n", orNone(h.Provider), orNone(h.DocType))
+		for _, line := range strings.Split(h.Content, "\n") {
+			if line == "" {
+				fmt.Fprintln(w)
+				continue
+			}
+			fmt.Fprintf(w, "   %s\n", line)
+		}
+		if i < len(docs)-1 {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// writeSearchJSON writes the hits as an indented JSON array.
+func writeSearchJSON(w io.Writer, docs []rag.Document, full bool) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(searchHits(docs, full)); err != nil {
+		return fmt.Errorf("rag search: failed to write JSON: %w", err)
+	}
+	return nil
+}
+
+// orNone returns s, or rag.NoValue when s is empty.
+func orNone(s string) string {
+	if s == "" {
+		return rag.NoValue
+	}
+	return s
+}
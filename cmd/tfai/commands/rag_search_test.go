@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// fakeRetriever returns docs, or err, and records the last request.
+type fakeRetriever struct {
+	docs  []rag.Document
+	err   error
+	query string
+	topK  int
+}
+
+func (r *fakeRetriever) Retrieve(_ context.Context, query string, topK int) ([]rag.Document, error) {
+	r.query, r.topK = query, topK
+	return r.docs, r.err
+}
+
+// searchDocs are two hits: one with metadata and long, multi-line content,
+// one without metadata.
+var searchDocs = []rag.Document{
+	{
+		Score:    0.8123,
+		Source:   "https://registry.terraform.io/providers/hashicorp/aws/latest/docs/resources/eks_node_group",
+		Content:  "# aws_eks_node_group\n\nManages an EKS Node Group.\n\n" + strings.Repeat("scaling_config ", 20),
+		Metadata: map[string]string{"provider": "aws", "doc_type": "resource"},
+	},
+	{Score: 0.5, Source: "https://example.com/notes", Content: "short"},
+}
+
+func TestRunRAGSearch_Text(t *testing.T) {
+	t.Parallel()
+
+	r := &fakeRetriever{docs: searchDocs}
+	var out strings.Builder
+	if err := runRAGSearch(context.Background(), &out, r, "eks node group", 7, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if r.query != "eks node group" || r.topK != 7 {
+		t.Errorf("expected the query and top-k to be passed through, got %q, %d", r.query, r.topK)
+	}
+	want := `1. 0.8123  https://registry.terraform.io/providers/hashicorp/aws/latest/docs/resources/eks_node_group
+   provider=aws doc_type=resource
+   # aws_eks_node_group Manages an EKS Node Group. scaling_config scaling_config scaling_config scaling_config scaling_config scaling_config scaling_config scaling_config scaling_config scaling_config sc...
+
+2. 0.5000  https://example.com/notes
+   provider=(none) doc_type=(none)
+   short
+`
+	if out.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, out.String())
+	}
+}
+
+func TestRunRAGSearch_Full(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	if err := runRAGSearch(context.Background(), &out, &fakeRetriever{docs: searchDocs[:1]}, "q", 5, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "   # aws_eks_node_group\n\n   Manages an EKS Node Group.\n") {
+		t.Errorf("expected the full content, indented line by line:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "...") {
+		t.Error("expected the content not to be cut")
+	}
+}
+
+func TestRunRAGSearch_JSON(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	if err := runRAGSearch(context.Background(), &out, &fakeRetriever{docs: searchDocs}, "q", 5, false, true); err != nil {
+		t.Fatal(err)
+	}
+	var hits []map[string]any
+	if err := json.Unmarshal([]byte(out.String()), &hits); err != nil {
+		t.Fatalf("expected a JSON array: %v\n%s", err, out.String())
+	}
+	if len(hits) != 2 {
+		t.Fatalf("expected 2 hits, got %d", len(hits))
+	}
+	if hits[0]["provider"] != "aws" || hits[0]["doc_type"] != "resource" || hits[0]["score"] != 0.8123 {
+		t.Errorf("unexpected first hit %v", hits[0])
+	}
+	if content := hits[0]["content"].(string); len([]rune(content)) != searchSnippetChars+3 {
+		t.Errorf("expected the content cut to %d characters, got %d", searchSnippetChars, len(content))
+	}
+	if _, ok := hits[1]["provider"]; ok {
+		t.Errorf("expected no provider for a hit without metadata, got %v", hits[1])
+	}
+}
+
+func TestRunRAGSearch_Empty(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	if err := runRAGSearch(context.Background(), &out, &fakeRetriever{}, "q", 5, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "No results") {
+		t.Errorf("expected a no-results hint, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := runRAGSearch(context.Background(), &out, &fakeRetriever{}, "q", 5, false, true); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("expected an empty JSON array, got %q", out.String())
+	}
+
+	err := runRAGSearch(context.Background(), &out, &fakeRetriever{err: errors.New("vector dimension error: expected dim: 768, got 1536")}, "q", 5, false, false)
+	if err == nil || !strings.Contains(err.Error(), "expected dim: 768") {
+		t.Errorf("expected the retrieval error to be returned, got %v", err)
+	}
+}
@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+func TestRAGStatusCmd_RequiresQdrantHost(t *testing.T) {
+	t.Setenv("QDRANT_HOST", "")
+
+	cmd := NewRAGCmd()
+	var out strings.Builder
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"status"})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "QDRANT_HOST is not set") {
+		t.Errorf("expected a QDRANT_HOST error, got %v", err)
+	}
+}
+
+func TestPrintRAGStatus(t *testing.T) {
+	t.Parallel()
+
+	status := &rag.CollectionStatus{
+		Collection: "tfai-docs",
+		VectorSize: 768,
+		Points:     12,
+		Breakdown: map[string]map[string]uint64{
+			"provider":  {"aws": 4, "azurerm": 6, rag.NoValue: 2},
+			"framework": {"terraform": 12},
+			"doc_type":  {"guide": 6, "resource": 6},
+			"preset":    {"aws-core": 4, rag.NoValue: 8},
+		},
+	}
+	var out strings.Builder
+	if err := printRAGStatus(&out, status); err != nil {
+		t.Fatal(err)
+	}
+	want := `Collection:   tfai-docs
+Vector size:  768
+Points:       12
+
+PROVIDER  POINTS
+azurerm   6
+aws       4
+(none)    2
+
+FRAMEWORK  POINTS
+terraform  12
+
+DOC_TYPE  POINTS
+guide     6
+resource  6
+
+PRESET    POINTS
+(none)    8
+aws-core  4
+`
+	if out.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, out.String())
+	}
+}
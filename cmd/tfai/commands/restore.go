@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+)
+
+// NewRestoreCmd constructs the `tfai restore` command, which lists the
+// backups taken before the agent overwrote workspace files and restores
+// one of them.
+func NewRestoreCmd() *cobra.Command {
+	var dir string
+	var list bool
+	var timestamp string
+
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "List or restore backups of files the agent overwrote",
+		Long: `Before the agent overwrites files in a workspace it copies them to
+.tfai/backups/<timestamp>/. The newest 10 backups of each workspace are
+kept, and files larger than 1 MiB are not backed up.
+
+--list shows the backups, newest first, with the files each holds.
+--timestamp copies the files of one backup back into the workspace,
+replacing their current content. Files written since that the backup does
+not hold are left alone.
+
+Examples:
+  tfai restore --workspace ./infra --list
+  tfai restore --workspace ./infra --timestamp 20260102T150405.000Z`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if list == (timestamp != "") {
+				return fmt.Errorf("restore: pass exactly one of --list or --timestamp")
+			}
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("restore: failed to resolve workspace directory: %w", err)
+			}
+			info, err := os.Stat(absDir)
+			if err != nil {
+				return fmt.Errorf("restore: %w", err)
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("restore: %s is not a directory", absDir)
+			}
+
+			out := cmd.OutOrStdout()
+			if list {
+				snapshots, err := tfaidir.ListBackups(absDir)
+				if err != nil {
+					return fmt.Errorf("restore: %w", err)
+				}
+				if len(snapshots) == 0 {
+					fmt.Fprintf(out, "No backups in %s.\n", absDir)
+					return nil
+				}
+				for _, s := range snapshots {
+					fmt.Fprintf(out, "%s  %s\n  %s\n", s.Timestamp, s.Time.Local().Format("2006-01-02 15:04:05"), strings.Join(s.Files, ", "))
+				}
+				return nil
+			}
+
+			files, err := tfaidir.RestoreBackup(absDir, timestamp)
+			if err != nil {
+				return fmt.Errorf("restore: %w", err)
+			}
+			for _, f := range files {
+				fmt.Fprintf(out, "restored %s\n", f)
+			}
+			fmt.Fprintf(out, "%d files restored from %s\n", len(files), timestamp)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "workspace", "w", ".", "Terraform workspace directory")
+	cmd.Flags().BoolVar(&list, "list", false, "List the workspace's backups, newest first")
+	cmd.Flags().StringVar(&timestamp, "timestamp", "", "Restore the backup with this timestamp, as --list shows it")
+
+	return cmd
+}
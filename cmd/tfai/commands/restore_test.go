@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+)
+
+func TestRestoreCmd(t *testing.T) {
+	t.Parallel()
+
+	ws := t.TempDir()
+	main := filepath.Join(ws, "main.tf")
+	if err := os.WriteFile(main, []byte("# before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backupDir, err := tfaidir.Backup(ws, []string{"main.tf"}, time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(main, []byte("# after\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (string, error) {
+		cmd := NewRestoreCmd()
+		var out strings.Builder
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("--workspace", ws, "--list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "20260102T150405.000Z") || !strings.Contains(out, "  main.tf\n") {
+		t.Errorf("expected the backup and its files listed, got:\n%s", out)
+	}
+
+	if _, err := run("--workspace", ws, "--timestamp", filepath.Base(backupDir)); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(main); string(b) != "# before\n" {
+		t.Errorf("expected main.tf restored, got %q", b)
+	}
+
+	if _, err := run("--workspace", ws); err == nil {
+		t.Error("expected an error without --list or --timestamp")
+	}
+	if _, err := run("--workspace", ws, "--list", "--timestamp", "x"); err == nil {
+		t.Error("expected an error with both --list and --timestamp")
+	}
+}
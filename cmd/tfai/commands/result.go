@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/ingestion"
+	"github.com/54b3r/tfai-go/internal/provider"
+)
+
+// resultLinePrefix starts the summary line generate and ingest write to
+// stderr as their last output, for CI logs to grep. The line is a stable
+// contract: its fields, their order, and their meaning only ever grow at
+// the end, and result_test.go pins them against golden files.
+const resultLinePrefix = "tfai_result"
+
+// Values of the status field of a result line.
+const (
+	// resultOK means the command did everything it was asked to.
+	resultOK = "ok"
+	// resultPartial means the command finished with part of the work
+	// missing: a truncated or tool-limited generation, or an ingest run
+	// with failed or interrupted pages.
+	resultPartial = "partial"
+	// resultFailed means the command produced nothing usable.
+	resultFailed = "failed"
+)
+
+// resultField is one key=value pair of a result line.
+type resultField struct {
+	key   string
+	value string
+}
+
+// writeResultLine writes fields as a single result line to w. Values
+// containing spaces, quotes, or an equals sign are Go-quoted, and an empty
+// value is written as "", so the line always splits on spaces.
+func writeResultLine(w io.Writer, fields []resultField) {
+	var b strings.Builder
+	b.WriteString(resultLinePrefix)
+	for _, f := range fields {
+		v := f.value
+		if v == "" || strings.ContainsAny(v, " \t\"=") {
+			v = strconv.Quote(v)
+		}
+		fmt.Fprintf(&b, " %s=%s", f.key, v)
+	}
+	b.WriteByte('\n')
+	_, _ = io.WriteString(w, b.String())
+}
+
+// generateResultFields returns the result line fields of a generate run
+// that returned res and err after elapsed.
+func generateResultFields(res *agent.QueryResult, err error, elapsed time.Duration, providerName, requestID string) []resultField {
+	status, files := resultOK, 0
+	if res != nil {
+		files = len(res.Files)
+		if res.Truncated || res.ToolLimitReached {
+			status = resultPartial
+		}
+	}
+	if err != nil {
+		status = resultFailed
+	}
+	return []resultField{
+		{"status", status},
+		{"files_written", strconv.Itoa(files)},
+		{"duration_ms", strconv.FormatInt(elapsed.Milliseconds(), 10)},
+		{"provider", providerName},
+		{"request_id", requestID},
+	}
+}
+
+// ingestResultFields returns the result line fields of an ingest run that
+// returned report and err. A report is returned even by an interrupted run,
+// so sources finished before the interruption still count as ok.
+func ingestResultFields(report *ingestion.Report, err error) []resultField {
+	ok, failed, chunks := 0, 0, 0
+	if report != nil {
+		ok, failed, chunks = report.New+report.Skipped, len(report.Failed), report.Chunks
+	}
+	status := resultOK
+	switch {
+	case err != nil && ok == 0:
+		status = resultFailed
+	case err != nil || failed > 0:
+		status = resultPartial
+	}
+	return []resultField{
+		{"status", status},
+		{"sources_ok", strconv.Itoa(ok)},
+		{"sources_failed", strconv.Itoa(failed)},
+		{"chunks", strconv.Itoa(chunks)},
+	}
+}
+
+// newCLIRequestID returns a random request ID for a CLI query, in the same
+// form the server generates for HTTP requests.
+func newCLIRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// generateProviderName returns the backend generate runs on: the
+// GENERATE_MODEL_PROVIDER override when it differs from MODEL_PROVIDER.
+func generateProviderName() string {
+	cfg := provider.ConfigFromEnv()
+	if cfg.Generate != nil && cfg.Generate.Backend != "" && cfg.Generate.Backend != cfg.Backend {
+		return string(cfg.Generate.Backend)
+	}
+	return string(cfg.Backend)
+}
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/ingestion"
+)
+
+// updateGolden rewrites the golden files instead of comparing against them:
+//
+//	go test ./cmd/tfai/commands -run ResultLine -update
+var updateGolden = flag.Bool("update", false, "rewrite testdata/golden files")
+
+// assertGoldenLine compares got with testdata/golden/<name>.txt. CI jobs
+// parse the result line, so renaming or reordering a field fails here.
+func assertGoldenLine(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".txt")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden file (run with -update): %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s drifted from %s:\ngot:  %s\nwant: %s", name, path, got, want)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Golden result lines — the tfai_result line is a stable contract
+// ---------------------------------------------------------------------------
+
+func TestResultLine_Generate(t *testing.T) {
+	t.Parallel()
+
+	files := []string{"main.tf", "variables.tf"}
+	tests := []struct {
+		name     string
+		res      *agent.QueryResult
+		err      error
+		provider string
+	}{
+		{name: "generate_ok", res: &agent.QueryResult{Files: files}, provider: "openai"},
+		{name: "generate_partial", res: &agent.QueryResult{Files: files[:1], Truncated: true}, provider: "ollama"},
+		{name: "generate_failed", err: errors.New("timed out"), provider: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var b bytes.Buffer
+			writeResultLine(&b, generateResultFields(tc.res, tc.err, 5210*time.Millisecond, tc.provider, "3f2a9c0d1e4b5a69"))
+			assertGoldenLine(t, tc.name, b.String())
+		})
+	}
+}
+
+func TestResultLine_Ingest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		report *ingestion.Report
+		err    error
+	}{
+		{name: "ingest_ok", report: &ingestion.Report{New: 40, Skipped: 3, Chunks: 1873}},
+		{
+			name:   "ingest_partial",
+			report: &ingestion.Report{New: 40, Skipped: 1, Failed: make([]ingestion.PageState, 2), Chunks: 1790},
+			err:    errors.New("2 page(s) failed"),
+		},
+		{name: "ingest_failed", err: errors.New("failed to connect to Qdrant")},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var b bytes.Buffer
+			writeResultLine(&b, ingestResultFields(tc.report, tc.err))
+			assertGoldenLine(t, tc.name, b.String())
+		})
+	}
+}
+
+func TestWriteResultLine_Quoting(t *testing.T) {
+	t.Parallel()
+
+	var b bytes.Buffer
+	writeResultLine(&b, []resultField{{"a", "plain"}, {"b", "two words"}, {"c", "k=v"}, {"d", ""}})
+	want := `tfai_result a=plain b="two words" c="k=v" d=""` + "\n"
+	if b.String() != want {
+		t.Errorf("expected %q, got %q", want, b.String())
+	}
+}
+
+func TestIngestCmd_ResultLineOnStderr(t *testing.T) {
+	t.Parallel()
+
+	cmd := NewIngestCmd()
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SilenceErrors, cmd.SilenceUsage = true, true
+	cmd.SetArgs(nil)
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected an error without sources")
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("expected nothing on stdout, got %q", stdout.String())
+	}
+	if got := stderr.String(); !strings.HasPrefix(got, "tfai_result status=failed ") {
+		t.Errorf("expected a failed result line on stderr, got %q", got)
+	}
+
+	dry := NewIngestCmd()
+	stderr.Reset()
+	dry.SetOut(&stdout)
+	dry.SetErr(&stderr)
+	dry.SetArgs([]string{"--url", "https://example.com/doc", "--dry-run"})
+	if err := dry.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stderr.String(), resultLinePrefix) {
+		t.Errorf("expected no result line for --dry-run, got %q", stderr.String())
+	}
+}
@@ -12,6 +12,10 @@ import (
 // configPath holds the --config flag value for YAML config file override.
 var configPath string
 
+// lenientConfig holds the --lenient flag value: report unknown or invalid
+// config keys as warnings instead of failing.
+var lenientConfig bool
+
 // loadedConfigPath stores the resolved config file path for audit logging.
 var loadedConfigPath string
 
@@ -32,23 +36,28 @@ See 'tfai --help' for available commands.`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			// Every command runs under a context carrying the logger, so
+			// nothing below needs slog.Default.
 			log := logging.New()
+			ctx := logging.WithLogger(cmd.Context(), log)
+			cmd.SetContext(ctx)
 
 			// Load YAML config (env vars always override YAML values).
-			path, err := config.Load(configPath, log)
+			path, err := config.Load(configPath, config.Options{Lenient: lenientConfig}, log)
 			if err != nil {
 				return err //nolint:wrapcheck // config error is already descriptive
 			}
 			loadedConfigPath = path
 
 			// Emit structured audit log for every command invocation.
-			audit.LogCommandStart(log, cmd.Name(), loadedConfigPath)
+			audit.LogCommandStart(ctx, cmd.Name(), loadedConfigPath)
 
 			return nil
 		},
 	}
 
 	root.PersistentFlags().StringVar(&configPath, "config", "", "Path to YAML config file (default: ~/.tfai/config.yaml)")
+	root.PersistentFlags().BoolVar(&lenientConfig, "lenient", false, "Warn about unknown or invalid config keys instead of failing")
 
 	root.AddCommand(
 		NewAskCmd(),
@@ -56,6 +65,19 @@ See 'tfai --help' for available commands.`,
 		NewDiagnoseCmd(),
 		NewServeCmd(),
 		NewIngestCmd(),
+		NewRAGCmd(),
+		NewUpgradeCmd(),
+		NewDescribeChangesCmd(),
+		NewWorkspaceCmd(),
+		NewRestoreCmd(),
+		NewUsageCmd(),
+		NewActivityCmd(),
+		NewScanCmd(),
+		NewCheckCmd(),
+		NewHookCmd(),
+		NewDoctorCmd(),
+		NewEvalCmd(),
+		NewToolsCmd(),
 		NewVersionCmd(),
 	)
 
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
@@ -9,6 +10,7 @@ import (
 	"syscall"
 
 	"github.com/cloudwego/eino/callbacks"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 
 	"github.com/54b3r/tfai-go/internal/agent"
@@ -16,8 +18,8 @@ import (
 	"github.com/54b3r/tfai-go/internal/provider"
 	"github.com/54b3r/tfai-go/internal/server"
 	"github.com/54b3r/tfai-go/internal/store"
-	"github.com/54b3r/tfai-go/internal/tools"
 	"github.com/54b3r/tfai-go/internal/tracing"
+	"github.com/54b3r/tfai-go/internal/wscache"
 )
 
 // NewServeCmd constructs the `tfai serve` command, which starts the HTTP
@@ -26,6 +28,7 @@ func NewServeCmd() *cobra.Command {
 	var host string
 	var port int
 	var workspaceRoot string
+	var printReport bool
 
 	cmd := &cobra.Command{
 		Use:   "serve",
@@ -39,19 +42,50 @@ interface similar to a local IDE companion.
 Examples:
   tfai serve
   tfai serve --port 9090
+  tfai serve --host 0.0.0.0 --print-security-report
   MODEL_PROVIDER=azure tfai serve`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
 			defer stop()
 
-			log := logging.New()
-			ctx = logging.WithLogger(ctx, log)
+			log := logging.FromContext(ctx)
+
+			// Resolve workspace root path if the flag has been provided
+			if cmd.Flags().Changed("workspace-root") {
+				var err error
+				workspaceRoot, err = filepath.Abs(cmd.Flags().Lookup("workspace-root").Value.String())
+				if err != nil {
+					return fmt.Errorf("serve:workspace-root: failed to resolve absolute path of workspace root: %w", err)
+				}
+			} else {
+				log.Debug("workspace-root not set; workspace path confinement disabled")
+				workspaceRoot = ""
+			}
+
+			// The report needs only the resolved config, so it is printed
+			// before any provider, store, or tracing setup.
+			if printReport {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(server.SecurityReport(&server.Config{
+					Host:               host,
+					Port:               port,
+					APIKey:             os.Getenv("TFAI_API_KEY"),
+					AdminAPIKey:        os.Getenv("TFAI_ADMIN_API_KEY"),
+					WorkspaceRoot:      workspaceRoot,
+					BlockSecretsOnSave: os.Getenv("TFAI_BLOCK_SECRETS") == "true",
+					Tools:              loadTools().statuses(),
+				})); err != nil {
+					return fmt.Errorf("serve: failed to write security report: %w", err)
+				}
+				return nil
+			}
 
 			log.Info("serve starting", slog.String("provider", os.Getenv("MODEL_PROVIDER")))
 
 			// Setup Langfuse tracing — opt-in, no-op if keys are absent.
-			handler, flush, ok := tracing.Setup()
-			if ok {
+			handler, flush, tracingEnabled := tracing.Setup()
+			if tracingEnabled {
 				callbacks.AppendGlobalHandlers(handler)
 				defer flush()
 				log.Info("langfuse tracing enabled")
@@ -66,17 +100,20 @@ Examples:
 			}
 			log.Info("provider initialised", slog.String("provider", string(providerCfg.Backend)))
 
-			runner, err := tools.NewExecRunner()
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "warning: %v (plan/state tools unavailable)\n", err)
-				runner = nil
+			ts := loadTools()
+			if ts.unavailable != nil {
+				log.Warn("terraform tools unavailable; the agent will give users commands to run instead",
+					slog.Any("error", ts.unavailable))
+			} else {
+				log.Info("terraform tools registered", slog.Int("count", len(ts.tools)))
 			}
 
-			agentTools := buildTools(runner)
-
 			// Open conversation history store. TFAI_HISTORY_DB overrides the
 			// default path (~/.tfai/history.db). Set to empty string to disable.
 			var historyStore store.ConversationStore
+			var usageReader store.UsageReader
+			var activityRecorder store.ActivityRecorder
+			var activityReader store.ActivityReader
 			dbPath := os.Getenv("TFAI_HISTORY_DB")
 			if dbPath != "disabled" {
 				if dbPath == "" {
@@ -91,6 +128,8 @@ Examples:
 						log.Warn("history: failed to open store, disabling", slog.Any("error", hsErr))
 					} else {
 						historyStore = hs
+						usageReader = hs
+						activityRecorder, activityReader = hs, hs
 						defer func() { _ = hs.Close() }()
 						log.Info("history: store opened", slog.String("path", dbPath))
 					}
@@ -105,11 +144,43 @@ Examples:
 			}
 			defer closeRetriever()
 
+			reranker, err := buildReranker(retriever, chatModel)
+			if err != nil {
+				return fmt.Errorf("serve: %w", err)
+			}
+
+			workspaceCache := wscache.NewGroup(prometheus.DefaultRegisterer)
+			minScore, maxChars := ragLimits()
+			spoolThreshold, spoolDir := spoolSettings()
 			tfAgent, err := agent.New(ctx, &agent.Config{
-				ChatModel: chatModel,
-				Tools:     agentTools,
-				History:   historyStore,
-				Retriever: retriever,
+				ChatModel:            chatModel,
+				Tools:                ts.tools,
+				TerraformUnavailable: ts.unavailable,
+				History:              historyStore,
+				Activity:             activityRecorder,
+				Retriever:            retriever,
+				Reranker:             reranker,
+				RAGMinScore:          minScore,
+				RAGMaxChars:          maxChars,
+				// Provider and model names are stored with each response's
+				// token usage for `tfai usage report`, and the model name
+				// with workspace activity.
+				ProviderName: string(providerCfg.Backend),
+				ModelName:    providerCfg.ModelName(),
+				// Register agent metrics alongside the server's so /metrics
+				// exports tool guard trips.
+				MetricsRegistry: prometheus.DefaultRegisterer,
+				// Persisted with each turn; the server streams its own copy.
+				Disclosure: disclosureText(),
+				// Shared with the server so file saves invalidate the
+				// cached workspace context.
+				WorkspaceCache: workspaceCache,
+				// Large responses spill to disk instead of memory.
+				SpoolThreshold: spoolThreshold,
+				SpoolDir:       spoolDir,
+				// Trimmed history is summarised by the chat model, and the
+				// summary cached in the history database.
+				SummarizeDroppedHistory: os.Getenv("TFAI_SUMMARIZE_HISTORY") == "true",
 			})
 			if err != nil {
 				return fmt.Errorf("serve: failed to initialise agent: %w", err)
@@ -117,24 +188,58 @@ Examples:
 
 			pingers := buildPingers(ctx, chatModel, providerCfg, log)
 
-			// Resolve workspace root path if the flag has been provided
-			if cmd.Flags().Changed("workspace-root") {
-				workspaceRoot, err = filepath.Abs(cmd.Flags().Lookup("workspace-root").Value.String())
-				if err != nil {
-					return fmt.Errorf("serve:workspace-root: failed to resolve absolute path of workspace root: %w", err)
-				}
-			} else {
-				log.Debug("workspace-root not set; workspace path confinement disabled")
-				workspaceRoot = ""
+			// Timeouts default in server.New; it refuses to start when
+			// WriteTimeout would cut off chat streams.
+			chatTimeout, err := getEnvDuration("TFAI_CHAT_TIMEOUT")
+			if err != nil {
+				return fmt.Errorf("serve: %w", err)
+			}
+			writeTimeout, err := getEnvDuration("TFAI_WRITE_TIMEOUT")
+			if err != nil {
+				return fmt.Errorf("serve: %w", err)
+			}
+			heartbeat, err := getEnvDuration("TFAI_SSE_HEARTBEAT")
+			if err != nil {
+				return fmt.Errorf("serve: %w", err)
+			}
+			toolCatalog, err := ts.catalog(ctx)
+			if err != nil {
+				return fmt.Errorf("serve: %w", err)
 			}
 
 			srv, err := server.New(tfAgent, &server.Config{
-				Host:          host,
-				Port:          port,
-				Logger:        log,
-				Pingers:       pingers,
-				APIKey:        os.Getenv("TFAI_API_KEY"),
-				WorkspaceRoot: workspaceRoot,
+				Host:            host,
+				Port:            port,
+				ChatTimeout:     chatTimeout,
+				WriteTimeout:    writeTimeout,
+				SSEHeartbeat:    heartbeat,
+				ProviderTimeout: providerCfg.HTTPTimeout(),
+				Logger:          log,
+				Pingers:         pingers,
+				APIKey:          os.Getenv("TFAI_API_KEY"),
+				AdminAPIKey:     os.Getenv("TFAI_ADMIN_API_KEY"),
+				MetricsAuth:     os.Getenv("TFAI_METRICS_AUTH") == "true",
+				WorkspaceRoot:   workspaceRoot,
+				History:         historyStore,
+				Usage:           usageReader,
+				Activity:        activityReader,
+				Prices:          loadPrices(log),
+				// Saves are always scanned; the env var upgrades the warning
+				// header to a 422 rejection.
+				BlockSecretsOnSave: os.Getenv("TFAI_BLOCK_SECRETS") == "true",
+				// Reported by GET /api/status so the UI can explain missing tools.
+				Tools:          ts.statuses(),
+				ToolCatalog:    toolCatalog,
+				DisclosureText: disclosureText(),
+				Tracing:        tracingEnabled,
+				MaxTokensLimit: getEnvInt("TFAI_MAX_TOKENS_LIMIT", server.DefaultMaxTokensLimit),
+				WorkspaceCache: workspaceCache,
+				// Set when a reverse proxy forwards a path prefix.
+				BasePath:          os.Getenv("TFAI_BASE_PATH"),
+				DisableRootProbes: os.Getenv("TFAI_ROOT_PROBES") == "false",
+				// Debounce /api/ready so a briefly slow dependency does not flap it.
+				ReadyFailureThreshold:  getEnvInt("TFAI_READY_FAILURES", server.DefaultReadyFailureThreshold),
+				ReadyRecoveryThreshold: getEnvInt("TFAI_READY_RECOVERIES", server.DefaultReadyRecoveryThreshold),
 			})
 			if err != nil {
 				return fmt.Errorf("serve: failed to create server: %w", err)
@@ -147,6 +252,7 @@ Examples:
 	cmd.Flags().StringVar(&host, "host", "127.0.0.1", "Host address to bind to")
 	cmd.Flags().StringVarP(&workspaceRoot, "workspace-root", "w", "", "Workspace root directory")
 	cmd.Flags().IntVarP(&port, "port", "p", 8080, "TCP port to listen on")
+	cmd.Flags().BoolVar(&printReport, "print-security-report", false, "Print the security report for the resolved configuration as JSON and exit")
 
 	return cmd
 }
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// NewToolsCmd constructs the `tfai tools` command, which lists the tools the
+// agent has in the current environment.
+func NewToolsCmd() *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "tools",
+		Short: "List the tools the agent can use in this environment",
+		Long: `List every tool the agent can be given, whether it is available here, and
+why not when it is missing: the terraform tools need terraform on PATH, and
+terraform_apply also needs TFAI_ALLOW_APPLY=true. Parameters marked * are
+required; tools marked for confirmation are confirmed before each call by
+ask and diagnose.
+
+The list is the one GET /api/tools serves. --format json prints it with the
+descriptions and parameter schemas sent to the model.
+
+Examples:
+  tfai tools
+  tfai tools --format json`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "text" && format != "json" {
+				return fmt.Errorf("tools: unknown --format %q (want text or json)", format)
+			}
+			catalog, err := loadTools().catalog(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if format == "json" {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(api.ToolsResponse{Tools: catalog}); err != nil {
+					return fmt.Errorf("tools: failed to encode catalog: %w", err)
+				}
+				return nil
+			}
+			return printToolCatalog(cmd.OutOrStdout(), catalog)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "text", "Output format: text or json")
+
+	return cmd
+}
+
+// printToolCatalog writes catalog as a table with one row per tool.
+func printToolCatalog(w io.Writer, catalog []api.ToolDescription) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tAVAILABLE\tCONFIRM\tPARAMETERS")
+	for _, t := range catalog {
+		available := "yes"
+		if !t.Available {
+			available = "no: " + t.Reason
+		}
+		confirm := "no"
+		if t.RequiresConfirmation {
+			confirm = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", t.Name, available, confirm, parameterList(t.Parameters))
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("tools: %w", err)
+	}
+	return nil
+}
+
+// parameterList renders a tool's parameter schema as its property names,
+// sorted, with required ones marked "*".
+func parameterList(schema json.RawMessage) string {
+	var s struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	}
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return "?"
+	}
+	names := slices.Sorted(maps.Keys(s.Properties))
+	for i, name := range names {
+		if slices.Contains(s.Required, name) {
+			names[i] = name + "*"
+		}
+	}
+	return strings.Join(names, ", ")
+}
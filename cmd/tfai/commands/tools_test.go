@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+func TestPrintToolCatalog(t *testing.T) {
+	t.Parallel()
+
+	catalog := []api.ToolDescription{
+		{
+			Name:                 "terraform_plan",
+			Parameters:           json.RawMessage(`{"type":"object","properties":{"raw":{"type":"boolean"},"dir":{"type":"string"}},"required":["dir"]}`),
+			RequiresConfirmation: true,
+			Available:            true,
+		},
+		{
+			Name:       "terraform_apply",
+			Parameters: json.RawMessage(`{"type":"object","properties":{"confirm":{"type":"boolean"},"dir":{"type":"string"}},"required":["dir","confirm"]}`),
+			Reason:     "TFAI_ALLOW_APPLY is not set to true",
+		},
+	}
+	var out strings.Builder
+	if err := printToolCatalog(&out, catalog); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"NAME             AVAILABLE                                CONFIRM  PARAMETERS\n" +
+		"terraform_plan   yes                                      yes      dir*, raw\n" +
+		"terraform_apply  no: TFAI_ALLOW_APPLY is not set to true  no       confirm*, dir*\n"
+	if out.String() != want {
+		t.Errorf("unexpected table:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/tools"
+	"github.com/54b3r/tfai-go/internal/upgrade"
+)
+
+// NewUpgradeCmd constructs the `tfai upgrade` command, which plans and applies
+// a provider major-version upgrade to an existing workspace.
+func NewUpgradeCmd() *cobra.Command {
+	var dir string
+	var providerName string
+	var to int
+	var dryRun bool
+	var maxFix int
+
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Migrate a workspace to a new provider major version",
+		Long: `Plan and apply a provider major-version upgrade for an existing workspace.
+
+The agent reads the workspace, looks up the provider's upgrade guide in the
+RAG store, and responds with a migration plan (removed and renamed arguments,
+replacement risks, manual follow-ups) plus the edited files. After the edits
+are applied, terraform init and validate are run; validation errors are sent
+back to the agent for up to --max-fix-attempts correction rounds.
+
+With --dry-run the agent works on a scratch copy of the workspace and only a
+summary of the proposed changes is printed.
+
+For best results, ingest the official upgrade guides first:
+  tfai ingest --preset upgrade-guides
+
+Examples:
+  tfai upgrade --dir ./infra --provider aws --to 5 --dry-run
+  tfai upgrade --dir ./infra --provider azurerm --to 4`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var llm model.ToolCallingChatModel
+
+			ctx := cmd.Context()
+			models, ts, retriever, retrieverClose, err := initCommand(ctx)
+			if err != nil {
+				logging.FromContext(ctx).Error("failed to initialize command", slog.Any("error", err))
+				return fmt.Errorf("upgrade: failed to initialize command: %w", err)
+			}
+			defer retrieverClose()
+
+			if models.GenerateModel != nil {
+				llm = models.GenerateModel
+			} else {
+				llm = models.ChatModel
+			}
+
+			reranker, err := buildReranker(retriever, models.ChatModel)
+			if err != nil {
+				return fmt.Errorf("upgrade: %w", err)
+			}
+
+			activity, closeActivity := openActivityRecorder(ctx)
+			defer closeActivity()
+
+			minScore, maxChars := ragLimits()
+			spoolThreshold, spoolDir := spoolSettings()
+			tfAgent, err := agent.New(ctx, &agent.Config{
+				ChatModel:            llm,
+				Tools:                ts.tools,
+				TerraformUnavailable: ts.unavailable,
+				Retriever:            retriever,
+				Reranker:             reranker,
+				RAGMinScore:          minScore,
+				RAGMaxChars:          maxChars,
+				Activity:             activity,
+				ModelName:            generateModelName(ctx),
+				SpoolThreshold:       spoolThreshold,
+				SpoolDir:             spoolDir,
+			})
+			if err != nil {
+				return fmt.Errorf("upgrade: failed to initialise agent: %w", err)
+			}
+
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("upgrade: failed to resolve workspace directory: %w", err)
+			}
+
+			advisor := &upgrade.Advisor{
+				Querier:        tfAgent,
+				Out:            os.Stdout,
+				Color:          isTerminal(os.Stdout),
+				MaxFixAttempts: maxFix,
+			}
+			// initCommand has already warned when terraform is missing;
+			// validation is simply skipped in that case.
+			if runner, err := tools.NewExecRunner(); err == nil {
+				advisor.Runner = runner
+			}
+
+			return advisor.Run(ctx, upgrade.Request{ //nolint:wrapcheck // CLI entry point — error goes directly to cobra
+				Dir:      absDir,
+				Provider: providerName,
+				To:       to,
+				DryRun:   dryRun,
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", ".", "Terraform workspace to upgrade")
+	cmd.Flags().StringVarP(&providerName, "provider", "p", "", "Provider name as used in required_providers (e.g. aws, azurerm, google)")
+	cmd.Flags().IntVar(&to, "to", 0, "Target provider major version")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the plan and proposed changes without modifying the workspace")
+	cmd.Flags().IntVar(&maxFix, "max-fix-attempts", upgrade.DefaultMaxFixAttempts, "Correction rounds after a failed terraform validate")
+	_ = cmd.MarkFlagRequired("provider")
+	_ = cmd.MarkFlagRequired("to")
+
+	return cmd
+}
@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/config"
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/usage"
+)
+
+// NewUsageCmd constructs the `tfai usage` command group for inspecting the
+// token usage recorded in the conversation history store. Run without a
+// subcommand it prints the report, taking the report's flags.
+func NewUsageCmd() *cobra.Command {
+	report := newUsageReportCmd()
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Inspect recorded token usage and estimated cost",
+		Long: `Inspect the token usage recorded in the conversation history database.
+Run without a subcommand, usage prints the report of ` + "`tfai usage report`" + `.
+
+Examples:
+  tfai usage --since 7d
+  tfai usage --since 30d --group-by provider`,
+		Args: cobra.NoArgs,
+		RunE: report.RunE,
+	}
+	cmd.Flags().AddFlagSet(report.Flags())
+	cmd.AddCommand(report)
+	return cmd
+}
+
+// newUsageReportCmd constructs `tfai usage report`, which aggregates the
+// usage metadata stored with assistant responses.
+func newUsageReportCmd() *cobra.Command {
+	var since string
+	var groupBy string
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Summarise requests, tokens, and estimated cost",
+		Long: `Summarise the token usage stored with assistant responses in the
+conversation history database (TFAI_HISTORY_DB, default ~/.tfai/history.db).
+
+Costs are estimated from list prices built in for common OpenAI, Azure
+OpenAI, Gemini, and Bedrock models; a provider listed in the budget.prices
+table of the config file, in dollars per 1K tokens, is priced by that table
+instead. Responses from models without a price are counted as unpriced; responses stored before usage tracking existed, or from
+providers that do not report usage, are counted as untracked.
+
+Examples:
+  tfai usage report
+  tfai usage report --since 7d
+  tfai usage report --since 2024-06-01 --group-by workspace
+  tfai usage report --group-by provider --format csv > usage.csv`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			start, err := usage.ParseSince(since)
+			if err != nil {
+				return fmt.Errorf("usage report: %w", err)
+			}
+			dim, err := usage.ParseGroupBy(groupBy)
+			if err != nil {
+				return fmt.Errorf("usage report: %w", err)
+			}
+
+			hs, err := openHistoryDB(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("usage report: %w", err)
+			}
+			defer func() { _ = hs.Close() }()
+
+			records, err := hs.UsageRecords(cmd.Context(), start)
+			if err != nil {
+				return fmt.Errorf("usage report: %w", err)
+			}
+
+			report := usage.Aggregate(records, start, dim, loadPrices(logging.FromContext(cmd.Context())))
+			if err := usage.Write(cmd.OutOrStdout(), report, format); err != nil {
+				return fmt.Errorf("usage report: %w", err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&since, "since", "", "Only include responses since this date (YYYY-MM-DD or RFC 3339) or time ago (e.g. 7d, 12h)")
+	cmd.Flags().StringVar(&groupBy, "group-by", "day", "Group rows by: day, workspace, provider")
+	cmd.Flags().StringVar(&format, "format", "table", "Output format: table, json, csv")
+
+	return cmd
+}
+
+// loadPrices returns the built-in price table with the providers listed in
+// budget.prices of the loaded YAML config file replacing their built-in
+// prices. A missing or unreadable config leaves the built-in table.
+func loadPrices(log *slog.Logger) usage.Prices {
+	if loadedConfigPath == "" {
+		return usage.DefaultPrices()
+	}
+	cfg, err := config.Read(loadedConfigPath, config.Options{Lenient: lenientConfig}, log)
+	if err != nil {
+		log.Warn("usage: failed to read price table, using built-in prices", slog.Any("error", err))
+		return usage.DefaultPrices()
+	}
+	return usage.DefaultPrices().With(usage.PricesFromConfig(cfg.Budget))
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+)
+
+// NewWorkspaceCmd constructs the `tfai workspace` command group for
+// maintenance tasks on local Terraform workspaces.
+func NewWorkspaceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Maintain tfai data stored in Terraform workspaces",
+	}
+	cmd.AddCommand(newWorkspaceCleanCmd())
+	return cmd
+}
+
+// newWorkspaceCleanCmd constructs `tfai workspace clean`, which removes aged
+// artifacts from the workspace's .tfai directory.
+func newWorkspaceCleanCmd() *cobra.Command {
+	var dir string
+	var olderThan string
+	var what string
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove old backups, trash, and state backups from .tfai",
+		Long: `Remove artifacts that tfai stored under the workspace's .tfai directory.
+
+Only entries inside the recognised .tfai subdirectories (backups, trash,
+state-backups, tmp) are deleted. Terraform files and anything else in the
+workspace are never touched.
+
+Examples:
+  tfai workspace clean --dir ./infra --dry-run
+  tfai workspace clean --dir ./infra --older-than 30d
+  tfai workspace clean --dir ./infra --what trash,backups --older-than 7d`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			age, err := tfaidir.ParseAge(olderThan)
+			if err != nil {
+				return fmt.Errorf("workspace clean: %w", err)
+			}
+			subdirs, err := tfaidir.ParseSubdirs(what)
+			if err != nil {
+				return fmt.Errorf("workspace clean: %w", err)
+			}
+			absDir, err := filepath.Abs(dir)
+			if err != nil {
+				return fmt.Errorf("workspace clean: failed to resolve directory: %w", err)
+			}
+			info, err := os.Stat(absDir)
+			if err != nil {
+				return fmt.Errorf("workspace clean: %w", err)
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("workspace clean: %s is not a directory", absDir)
+			}
+
+			report, err := tfaidir.Clean(absDir, tfaidir.CleanOptions{
+				Subdirs:   subdirs,
+				OlderThan: age,
+				DryRun:    dryRun,
+			})
+			if err != nil {
+				return fmt.Errorf("workspace clean: %w", err)
+			}
+
+			out := cmd.OutOrStdout()
+			for _, e := range report.Entries {
+				fmt.Fprintf(out, "%-14s %10s  %s  %s\n", e.Subdir, formatBytes(e.Bytes), e.ModTime.Format("2006-01-02"), e.Path)
+			}
+			verb := "reclaimed"
+			if dryRun {
+				verb = "would reclaim"
+			}
+			fmt.Fprintf(out, "%d artifacts, %s %s\n", len(report.Entries), verb, formatBytes(report.Bytes))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "dir", "d", ".", "Terraform workspace directory")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Only remove artifacts at least this old (e.g. 30d, 12h)")
+	cmd.Flags().StringVar(&what, "what", "all", "Comma-separated artifact types: backups, trash, state-backups, tmp, all")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List what would be removed without deleting anything")
+
+	return cmd
+}
+
+// formatBytes renders n using binary units, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
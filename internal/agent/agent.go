@@ -12,19 +12,34 @@ import (
 	"io/fs"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/compose"
+	einoagent "github.com/cloudwego/eino/flow/agent"
 	"github.com/cloudwego/eino/flow/agent/react"
 	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/54b3r/tfai-go/internal/budget"
+	"github.com/54b3r/tfai-go/internal/envelope"
+	"github.com/54b3r/tfai-go/internal/hclinspect"
 	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/provider"
 	"github.com/54b3r/tfai-go/internal/rag"
+	"github.com/54b3r/tfai-go/internal/secretscan"
 	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/internal/textenc"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+	"github.com/54b3r/tfai-go/internal/tools"
+	"github.com/54b3r/tfai-go/internal/wscache"
 )
 
 // systemPrompt is the base system prompt injected into every conversation.
@@ -105,6 +120,8 @@ Before returning generated code, verify:
 - [ ] Security groups have explicit rules — no implicit defaults relied upon
 - [ ] Stateful resources have deletion protection or lifecycle policies
 - [ ] The code would pass a code review from a Senior Platform Engineer
+- [ ] If terraform_validate is available and the workspace already contains files you wrote or
+      are modifying, call it on the workspace directory and fix every reported error in your response
 
 ## Output Format for Code Generation
 
@@ -145,8 +162,13 @@ For module requests with a root caller:
 
 ## Diagnosing Issues
 
+- A workspace without a .terraform directory has never been initialised: call terraform_init
+  before terraform_plan, including right after generating a new module
+- Use terraform_validate to check for syntax and reference errors before running a plan
 - Use terraform_plan to inspect the current plan before advising
 - Use terraform_state to inspect resource state when diagnosing drift or corruption
+- Use terraform_providers_lock to read the locked provider versions when diagnosing provider
+  version conflicts or checksum mismatch errors
 - Always identify the root cause — not just the symptom
 - Provide step-by-step remediation with the exact commands to run
 - Note any state surgery risks before recommending ` + "`terraform state`" + ` commands
@@ -167,6 +189,11 @@ type Config struct {
 	// Tools is the list of Terraform tools available to the agent.
 	Tools []tool.BaseTool
 
+	// TerraformUnavailable is why the terraform binary cannot be run, or nil
+	// when it can. When set, the system prompt tells the model not to claim
+	// to run terraform, and queries that need it get a one-time notice.
+	TerraformUnavailable error
+
 	// Retriever is the RAG retriever for Terraform documentation context.
 	// May be nil if RAG is not configured.
 	Retriever rag.Retriever
@@ -174,19 +201,97 @@ type Config struct {
 	// RAGTopK controls how many RAG documents are injected per query.
 	// Defaults to 5 if zero.
 	RAGTopK int
+
+	// Reranker, when set, reorders rerankCandidates times RAGTopK retrieved
+	// documents and keeps the best RAGTopK. May be nil.
+	Reranker rag.Reranker
+
+	// RAGMinScore drops retrieved documents scoring below it before they
+	// are injected. Zero keeps every document.
+	RAGMinScore float32
+
+	// RAGMaxChars caps the total content of the injected documents; the
+	// lowest-scoring documents are dropped first. Defaults to
+	// DefaultRAGMaxChars if zero; negative disables the cap.
+	RAGMaxChars int
 	// History is the optional conversation store used to persist and replay
 	// prior turns. If nil, each query is stateless.
 	History store.ConversationStore
 	// HistoryDepth is the number of prior turns (user+assistant pairs) to
 	// inject per query. Defaults to 10 if zero.
 	HistoryDepth int
+	// Activity records every query that writes files, for the workspace
+	// activity feed. Unlike History it is written even when
+	// QueryOptions.NoHistory is set. If nil, no activity is recorded.
+	Activity store.ActivityRecorder
 	// MaxContextTokens is the estimated token budget for the full input context
 	// (system prompt + history + RAG + workspace + user message). History is
 	// trimmed oldest-first to fit. Defaults to budget.DefaultMaxContextTokens
 	// if zero.
 	MaxContextTokens int
+	// SummarizeDroppedHistory makes the agent summarise, with ChatModel,
+	// the history turns trimmed to fit MaxContextTokens when more than
+	// SummarizeMinDropped messages are dropped, and inject the summary as a
+	// system message in their place. The summary is cached in History when
+	// it implements store.SummaryStore, and generated again only once more
+	// than SummarizeMinDropped messages it does not cover are dropped.
+	SummarizeDroppedHistory bool
+	// SummarizeMinDropped is the number of dropped history messages that
+	// must be exceeded before they are summarised. Defaults to
+	// DefaultSummarizeMinDropped if zero.
+	SummarizeMinDropped int
+	// TokenCounter counts tokens against MaxContextTokens. Defaults to the
+	// counter matching ProviderName and ModelName (budget.CounterFor): the
+	// model's BPE encoding for OpenAI and Azure OpenAI, the character
+	// heuristic otherwise.
+	TokenCounter budget.TokenCounter
 	// WorkspaceRoot is the root directory for the workspace.
 	WorkspaceRoot string
+	// MaxToolIterations caps the number of tool calls the ReAct loop may make
+	// for a single query. The first call over the cap receives a terminal
+	// "iteration limit reached" result; any further call aborts the run.
+	// Defaults to DefaultMaxToolIterations if zero.
+	MaxToolIterations int
+	// EnvelopeLimits bounds the file count and content size of a generated
+	// JSON envelope. Envelopes over any limit are rejected and nothing is
+	// written. Zero fields use the envelope package defaults.
+	EnvelopeLimits envelope.Limits
+	// WorkspaceLimits bounds the files injected as workspace context; the
+	// files least relevant to the query are left out first. Zero fields use
+	// the DefaultMaxWorkspace* defaults.
+	WorkspaceLimits WorkspaceLimits
+	// MetricsRegistry is the Prometheus registerer for agent metrics. If nil,
+	// metrics are recorded in a private registry and not exported.
+	MetricsRegistry prometheus.Registerer
+	// ProviderName labels the token usage persisted with each assistant
+	// message when History also implements store.UsageRecorder (e.g. "openai").
+	ProviderName string
+	// ModelName labels persisted token usage and activity (e.g. "gpt-4o").
+	ModelName string
+	// SecretScanner redacts credentials from workspace files before they are
+	// injected as context. Defaults to secretscan.Default() if nil.
+	SecretScanner *secretscan.Scanner
+	// FormatOnWrite rewrites generated .tf and .tfvars files into
+	// `terraform fmt` style before they are written. Defaults to true if nil.
+	FormatOnWrite *bool
+	// Disclosure labels every answer as AI-generated (e.g. "AI-generated
+	// advice — review before applying"). It is returned in
+	// QueryResult.Disclosure and persisted with each turn, never mixed into
+	// the answer text. Empty disables the label.
+	Disclosure string
+	// WorkspaceCache holds the caches of workspace-derived data, such as the
+	// workspace context, so that the server's file endpoints can invalidate
+	// them. A private group is used if nil.
+	WorkspaceCache *wscache.Group
+	// SpoolThreshold is how many bytes of a model response are held in
+	// memory; a larger response spills to a temporary file for the rest
+	// of the query. Defaults to DefaultSpoolThreshold if zero; negative
+	// keeps every response in memory.
+	SpoolThreshold int
+	// SpoolDir is where spilled responses are written. Defaults to the
+	// workspace's .tfai/tmp when the query may write to its workspace, and
+	// to os.TempDir() otherwise.
+	SpoolDir string
 }
 
 // TerraformAgent wraps the Eino ReAct agent with Terraform-specific behaviour,
@@ -201,17 +306,96 @@ type TerraformAgent struct {
 	// ragTopK is the number of RAG documents to inject per query.
 	ragTopK int
 
+	// reranker is the optional reranker applied to retrieved documents.
+	reranker rag.Reranker
+
+	// ragMinScore is the lowest score a document may have to be injected.
+	ragMinScore float32
+
+	// ragMaxChars caps the injected document content; negative for no cap.
+	ragMaxChars int
+
 	// history is the optional conversation store for multi-turn context.
 	history store.ConversationStore
 
 	// historyDepth is the number of recent messages to inject per query.
 	historyDepth int
 
+	// activity is the optional recorder of file-producing queries.
+	activity store.ActivityRecorder
+
 	// maxContextTokens is the estimated token budget for the full input context.
 	maxContextTokens int
+	// tokenCounter counts tokens against maxContextTokens.
+	tokenCounter budget.TokenCounter
+	// summarizeDropped enables summarising trimmed history turns.
+	summarizeDropped bool
+	// summarizeMinDropped is the number of dropped messages that must be
+	// exceeded before they are summarised.
+	summarizeMinDropped int
+
+	// chatModel is the model history summaries are generated with.
+	chatModel model.BaseChatModel
 
 	// workspaceRoot is the root directory for the workspace.
 	workspaceRoot string
+
+	// maxToolIterations is the per-query tool call cap enforced by the tool guard.
+	maxToolIterations int
+
+	// confirmTools holds the names of the tools whose calls are confirmed
+	// through QueryOptions.ConfirmTool.
+	confirmTools map[string]bool
+
+	// envelopeLimits bounds generated envelopes before they are applied.
+	envelopeLimits envelope.Limits
+
+	// metrics holds the agent's Prometheus metrics.
+	metrics *agentMetrics
+
+	// providerName labels persisted usage metadata.
+	providerName string
+
+	// modelName labels persisted usage metadata and activity.
+	modelName string
+
+	// secretScanner redacts credentials from workspace context.
+	secretScanner *secretscan.Scanner
+
+	// formatOnWrite formats generated HCL before it is written.
+	formatOnWrite bool
+
+	// terraformUnavailable is why the terraform tools are missing, or nil.
+	terraformUnavailable error
+
+	// disclosure is the label attached to every answer, or empty.
+	disclosure string
+
+	// workspaceCache invalidates workspace-derived caches after files are
+	// written.
+	workspaceCache *wscache.Group
+
+	// workspaceFiles caches listWorkspaceFiles per workspace and scope.
+	workspaceFiles *wscache.Cache[[]workspaceFile]
+
+	// workspaceLimits bounds the workspace context.
+	workspaceLimits WorkspaceLimits
+
+	// spoolThreshold is the in-memory size limit of a response; negative
+	// for none.
+	spoolThreshold int
+
+	// spoolDir is the configured directory for spilled responses, or empty.
+	spoolDir string
+
+	// jsonModeOptions constrain model calls to the envelope schema on
+	// queries that expect an envelope. Nil when the model has no native
+	// JSON mode.
+	jsonModeOptions []model.Option
+
+	// noticed records the workspace conversations already sent
+	// TerraformUnavailableNotice.
+	noticed sync.Map
 }
 
 // New constructs a TerraformAgent from the provided Config.
@@ -225,16 +409,9 @@ func New(ctx context.Context, cfg *Config) (*TerraformAgent, error) {
 		topK = 5
 	}
 
-	agentCfg := &react.AgentConfig{
-		ToolCallingModel: cfg.ChatModel,
-		ToolsConfig: compose.ToolsNodeConfig{
-			Tools: cfg.Tools,
-		},
-	}
-
-	reactAgent, err := react.NewAgent(ctx, agentCfg)
-	if err != nil {
-		return nil, fmt.Errorf("agent: failed to create ReAct agent: %w", err)
+	ragMaxChars := cfg.RAGMaxChars
+	if ragMaxChars == 0 {
+		ragMaxChars = DefaultRAGMaxChars
 	}
 
 	depth := cfg.HistoryDepth
@@ -247,133 +424,560 @@ func New(ctx context.Context, cfg *Config) (*TerraformAgent, error) {
 		maxCtx = budget.DefaultMaxContextTokens
 	}
 
-	return &TerraformAgent{
-		reactAgent:       reactAgent,
-		retriever:        cfg.Retriever,
-		ragTopK:          topK,
-		history:          cfg.History,
-		historyDepth:     depth,
-		maxContextTokens: maxCtx,
-		workspaceRoot:    cfg.WorkspaceRoot,
-	}, nil
-}
+	minDropped := cfg.SummarizeMinDropped
+	if minDropped <= 0 {
+		minDropped = DefaultSummarizeMinDropped
+	}
 
-// Query sends a user message to the agent and streams the response to the
-// provided writer. If a RAG retriever is configured, relevant documentation
-// context is prepended to the message before it reaches the LLM.
-// If a conversation store is configured, prior turns are injected and the
-// new user message and assistant response are persisted after completion.
-func (a *TerraformAgent) Query(ctx context.Context, userMessage, workspaceDir string, w io.Writer) (bool, error) {
-	filesWritten := false
-	messages, err := a.buildMessages(ctx, userMessage, workspaceDir)
+	counter := cfg.TokenCounter
+	if counter == nil {
+		counter = budget.CounterFor(cfg.ProviderName, cfg.ModelName)
+	}
+
+	maxIter := cfg.MaxToolIterations
+	if maxIter <= 0 {
+		maxIter = DefaultMaxToolIterations
+	}
+
+	scanner := cfg.SecretScanner
+	if scanner == nil {
+		scanner = secretscan.Default()
+	}
+
+	formatOnWrite := true
+	if cfg.FormatOnWrite != nil {
+		formatOnWrite = *cfg.FormatOnWrite
+	}
+
+	spoolThreshold := cfg.SpoolThreshold
+	if spoolThreshold == 0 {
+		spoolThreshold = DefaultSpoolThreshold
+	}
+
+	cache := cfg.WorkspaceCache
+	if cache == nil {
+		cache = wscache.NewGroup(nil)
+	}
+
+	confirm, err := confirmTools(ctx, cfg.Tools)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &TerraformAgent{
+		retriever:           cfg.Retriever,
+		ragTopK:             topK,
+		reranker:            cfg.Reranker,
+		ragMinScore:         cfg.RAGMinScore,
+		ragMaxChars:         ragMaxChars,
+		history:             cfg.History,
+		historyDepth:        depth,
+		activity:            cfg.Activity,
+		maxContextTokens:    maxCtx,
+		tokenCounter:        counter,
+		summarizeDropped:    cfg.SummarizeDroppedHistory,
+		summarizeMinDropped: minDropped,
+		chatModel:           cfg.ChatModel,
+		workspaceRoot:       cfg.WorkspaceRoot,
+		maxToolIterations:   maxIter,
+		confirmTools:        confirm,
+		envelopeLimits:      cfg.EnvelopeLimits.WithDefaults(),
+		metrics:             newAgentMetrics(cfg.MetricsRegistry),
+		providerName:        cfg.ProviderName,
+		modelName:           cfg.ModelName,
+		secretScanner:       scanner,
+		formatOnWrite:       formatOnWrite,
+		disclosure:          strings.TrimSpace(cfg.Disclosure),
+		workspaceCache:      cache,
+		workspaceFiles:      wscache.Register[[]workspaceFile](cache, "workspace_context", 0, workspaceContextFingerprint),
+		workspaceLimits:     cfg.WorkspaceLimits.WithDefaults(),
+		spoolThreshold:      spoolThreshold,
+		spoolDir:            cfg.SpoolDir,
+
+		terraformUnavailable: cfg.TerraformUnavailable,
+	}
+
+	if jm, ok := cfg.ChatModel.(provider.JSONModeModel); ok {
+		opts, err := jm.JSONModeOptions(envelope.SchemaName, envelope.Schema())
+		if err != nil {
+			return nil, fmt.Errorf("agent: failed to configure JSON mode: %w", err)
+		}
+		a.jsonModeOptions = opts
+	}
+
+	agentCfg := &react.AgentConfig{
+		// Metered so token usage across every ReAct step can be persisted
+		// with the assistant message.
+		ToolCallingModel: &meteredModel{inner: cfg.ChatModel},
+		ToolsConfig: compose.ToolsNodeConfig{
+			Tools:               cfg.Tools,
+			ToolCallMiddlewares: []compose.ToolMiddleware{toolEventsMiddleware(), a.toolGuardMiddleware(), a.toolConfirmMiddleware()},
+		},
+		// Each tool iteration costs two graph steps (model + tools). Leave room
+		// for the capped call and a final answer so the tool guard, not the
+		// graph step limit, is what ends a runaway loop.
+		MaxStep: 2*(maxIter+2) + 2,
+	}
+
+	reactAgent, err := react.NewAgent(ctx, agentCfg)
 	if err != nil {
-		return filesWritten, fmt.Errorf("agent: failed to build messages: %w", err)
+		return nil, fmt.Errorf("agent: failed to create ReAct agent: %w", err)
 	}
+	a.reactAgent = reactAgent
+
+	return a, nil
+}
 
-	sr, err := a.reactAgent.Stream(ctx, messages)
+// Run answers req.Message and streams the response to req.Output. If a RAG
+// retriever is configured, relevant documentation context is prepended to
+// the message before it reaches the LLM. If a conversation store is
+// configured, prior turns are injected and the new user message and
+// assistant response are persisted after completion, together with notes of
+// the tools the turn ran and the files it wrote. When req.WorkspaceDir
+// is set and the model returns a file envelope, the files are written there
+// and the summary is streamed instead of the raw envelope.
+//
+// The returned result is never nil; on error its ErrorCode says why.
+func (a *TerraformAgent) Run(ctx context.Context, req QueryRequest) (*QueryResult, error) {
+	start := time.Now()
+	tm := &Timings{}
+	res := &QueryResult{Disclosure: a.disclosure, Timings: tm}
+	fail := func(code ErrorCode, err error) (*QueryResult, error) {
+		res.ErrorCode = code
+		return res, err
+	}
+	w := req.Output
+	if w == nil {
+		w = io.Discard
+	}
+	recorder := newTurnRecorder(req.Events)
+	ctx = withEvents(ctx, recorder)
+	events := eventsFrom(ctx)
+
+	// Model and tool time are settled when the stream ends, or on return
+	// when the query fails before it does.
+	var modelStart time.Time
+	modelEnded := false
+	endModel := func() {
+		if modelStart.IsZero() || modelEnded {
+			return
+		}
+		modelEnded = true
+		tm.ToolCalls = recorder.toolTimings()
+		for _, c := range tm.ToolCalls {
+			tm.Tools += c.Elapsed
+		}
+		tm.Model = max(time.Since(modelStart)-tm.Tools, 0)
+	}
+	defer func() {
+		endModel()
+		tm.Total = time.Since(start)
+		a.metrics.observe(tm)
+		logging.FromContext(ctx).Debug("agent: query timings", tm.logAttrs()...)
+	}()
+
+	var cont *continuation
+	var messages []*schema.Message
+	if req.Options.Continue {
+		var err error
+		if cont, err = a.loadContinuation(ctx, req); err != nil {
+			return fail(CodeCannotContinue, err)
+		}
+		messages = cont.messages(a.prompt())
+	} else {
+		if a.terraformNotice(req.WorkspaceDir, req.Message) {
+			res.Notices = append(res.Notices, TerraformUnavailableNotice)
+			events.OnNotice(TerraformUnavailableNotice)
+		}
+		messages = a.buildMessages(ctx, req, res)
+	}
+	tm.Context = time.Since(start)
+
+	// Every query gets its own tool guard so concurrent requests never share
+	// iteration counts or call history.
+	ctx = withToolGuard(ctx, a.maxToolIterations)
+	ctx = tools.WithWorkspace(ctx, req.WorkspaceDir)
+	ctx = withToolConfirm(ctx, req.Options.ConfirmTool, req.Options.ConfirmTimeout)
+	ctx = withUsageMeter(ctx)
+	defer func() {
+		if prompt, completion, ok := usageMeterFrom(ctx).totals(); ok {
+			res.Usage = &Usage{PromptTokens: prompt, CompletionTokens: completion}
+			a.metrics.promptTokensTotal.WithLabelValues(a.providerName, a.modelName).Add(float64(prompt))
+			a.metrics.completionTokensTotal.WithLabelValues(a.providerName, a.modelName).Add(float64(completion))
+			events.OnUsage(*res.Usage)
+		}
+	}()
+
+	// Queries that expect an envelope use the model's native JSON mode when
+	// it has one; other models rely on the output contract in the prompt.
+	// A continuation does not: JSON mode would start a new document rather
+	// than finish the cut-off one. Per-query tuning is applied to each model
+	// call the same way, without rebuilding the model.
+	var modelOpts []model.Option
+	jsonMode := req.Options.ExpectEnvelope && len(a.jsonModeOptions) > 0 && cont == nil
+	if jsonMode {
+		modelOpts = append(modelOpts, a.jsonModeOptions...)
+	}
+	if req.Options.Temperature != nil {
+		modelOpts = append(modelOpts, model.WithTemperature(*req.Options.Temperature))
+	}
+	if req.Options.MaxTokens > 0 {
+		modelOpts = append(modelOpts, model.WithMaxTokens(req.Options.MaxTokens))
+	}
+	var agentOpts []einoagent.AgentOption
+	if len(modelOpts) > 0 {
+		agentOpts = append(agentOpts, einoagent.WithComposeOptions(compose.WithChatModelOption(modelOpts...)))
+	}
+
+	events.OnPhase(PhaseCallingModel)
+	modelStart = time.Now()
+	sr, err := a.reactAgent.Stream(ctx, messages, agentOpts...)
 	if err != nil {
-		return filesWritten, fmt.Errorf("agent: stream failed: %w", err)
+		if msg := guardMessage(ctx, err); msg != "" {
+			res.ToolLimitReached = true
+			_, _ = fmt.Fprint(w, msg)
+			return res, nil
+		}
+		if cerr := canceled(ctx); cerr != nil {
+			return fail(CodeCanceled, cerr)
+		}
+		return fail(CodeModel, fmt.Errorf("agent: stream failed: %w", err))
 	}
 	defer sr.Close()
 
-	// maxResponseBytes caps the in-memory accumulation of the LLM response to
-	// prevent unbounded memory growth from a runaway or adversarial model.
-	const maxResponseBytes = 4 << 20 // 4 MiB
-
-	var msgBuf strings.Builder
+	// Large responses spill to disk rather than being held in memory; the
+	// spool file is removed however the query ends.
+	msgBuf := newSpoolBuffer(a.spoolThreshold, func() string { return a.spoolDirFor(req) })
+	defer func() {
+		if err := msgBuf.Close(); err != nil {
+			logging.FromContext(ctx).Warn("agent: failed to remove spooled response", slog.Any("error", err))
+		}
+	}()
+	var finishReason string
 	for {
 		msg, err := sr.Recv()
 		if errors.Is(err, io.EOF) {
 			break
 		}
 		if err != nil {
-			return filesWritten, fmt.Errorf("agent: stream receive error: %w", err)
+			if msg := guardMessage(ctx, err); msg != "" {
+				res.ToolLimitReached = true
+				_, _ = fmt.Fprint(w, msg)
+				return res, nil
+			}
+			if cerr := canceled(ctx); cerr != nil {
+				return fail(CodeCanceled, cerr)
+			}
+			return fail(CodeModel, fmt.Errorf("agent: stream receive error: %w", err))
+		}
+		// Stop reading as soon as the caller goes away, even if the model
+		// keeps sending.
+		if cerr := canceled(ctx); cerr != nil {
+			return fail(CodeCanceled, cerr)
+		}
+		if msg != nil && msg.ResponseMeta != nil && msg.ResponseMeta.FinishReason != "" {
+			finishReason = msg.ResponseMeta.FinishReason
 		}
 		if msg != nil && msg.Content != "" {
-			if msgBuf.Len()+len(msg.Content) > maxResponseBytes {
-				return filesWritten, fmt.Errorf("agent: response exceeded maximum size (%d bytes)", maxResponseBytes)
+			if msgBuf.Len()+int64(len(msg.Content)) > maxResponseBytes {
+				return fail(CodeResponseTooLarge, fmt.Errorf("agent: response exceeded maximum size (%d bytes)", maxResponseBytes))
+			}
+			if msgBuf.Len() == 0 {
+				tm.FirstToken = time.Since(modelStart)
 			}
-			if _, err := fmt.Fprint(&msgBuf, msg.Content); err != nil {
-				return filesWritten, fmt.Errorf("agent: write error: %w", err)
+			spilled := msgBuf.Spilled()
+			if _, err := msgBuf.WriteString(msg.Content); err != nil {
+				return fail(CodeSpool, err)
 			}
+			if !spilled && msgBuf.Spilled() {
+				logging.FromContext(ctx).Info("agent: response spooled to disk",
+					slog.String("path", msgBuf.Path()),
+					slog.Int("threshold_bytes", a.spoolThreshold))
+			}
+		}
+	}
+	endModel()
+
+	res.Truncated = isTruncated(finishReason)
+	if res.Truncated {
+		logging.FromContext(ctx).Warn("agent: answer cut off at the output token limit",
+			slog.String("finish_reason", finishReason))
+	}
+	// A continuation's answer is the cut-off part with this one appended;
+	// only the new part is stored, as the cut-off part already is.
+	answer := func() io.Reader {
+		if cont != nil {
+			return io.MultiReader(strings.NewReader(cont.partial), msgBuf.Reader())
 		}
+		return msgBuf.Reader()
 	}
 
+	workspaceDir := req.WorkspaceDir
 	if a.workspaceRoot != "" {
-		root := filepath.Clean(a.workspaceRoot)
-		target := filepath.Clean(workspaceDir)
-		if !strings.HasPrefix(target+string(filepath.Separator), root+string(filepath.Separator)) {
-			return false, fmt.Errorf("agent: workspaceDir %q is outside permitted root %q", workspaceDir, a.workspaceRoot)
+		if !withinRoot(a.workspaceRoot, workspaceDir) {
+			return fail(CodeWorkspaceOutsideRoot, fmt.Errorf("agent: workspaceDir %q is outside permitted root %q", workspaceDir, a.workspaceRoot))
 		}
 	}
 	// If a workspace directory was provided, attempt to parse the buffered output
-	// as a terraform_generate JSON envelope. On success, write files to disk and
-	// stream the human-readable summary to the caller. On failure (regular text
+	// as a terraform_generate JSON envelope. On success, write files to disk, or
+	// only diff them against it with PreviewFiles, and stream the
+	// human-readable summary to the caller. On failure (regular text
 	// response), fall through and stream the raw buffer as normal.
-	if workspaceDir != "" {
-		result, err := parseAgentOutput(msgBuf.String())
+	if workspaceDir != "" && !req.Options.NoWrite {
+		parseStart := time.Now()
+		result, err := decodeAgentOutput(answer())
+		tm.Parse = time.Since(parseStart)
 		if err == nil && len(result.Files) > 0 {
-			if err := applyFiles(result, workspaceDir); err != nil {
-				return filesWritten, fmt.Errorf("agent: Query: failed to apply files: %w", err)
+			// Enforce size limits before touching the filesystem so an
+			// oversized envelope never writes a partial set of files.
+			if err := a.envelopeLimits.Check(result.files()); err != nil {
+				return fail(CodeEnvelopeRejected, fmt.Errorf("agent: generated output rejected: %w", err))
+			}
+			// Import blocks must target a declared resource; those whose
+			// ID is a placeholder are listed for the operator.
+			if res.InputsNeeded, err = checkImports(result, workspaceDir); err != nil {
+				return fail(CodeEnvelopeRejected, fmt.Errorf("agent: generated output rejected: %w", err))
+			}
+			if req.Options.PreviewFiles {
+				preview, err := previewFiles(result, workspaceDir, a.formatOnWrite)
+				if err != nil {
+					return fail(CodeApplyFailed, fmt.Errorf("agent: Run: failed to preview files: %w", err))
+				}
+				res.Preview, res.Envelope = preview, result
+			} else {
+				applyStart := time.Now()
+				err := a.writeEnvelope(ctx, result, workspaceDir, req.Options.ResolveConflict, res)
+				tm.Apply = time.Since(applyStart)
+				if err != nil {
+					return fail(CodeApplyFailed, fmt.Errorf("agent: Run: failed to apply files: %w", err))
+				}
+				a.recordActivity(ctx, req, workspaceDir, result.Summary, res)
 			}
-			filesWritten = true
 			// Stream the summary to the SSE writer, not stdout.
-			_, _ = fmt.Fprint(w, result.Summary)
-			return filesWritten, nil
+			summary := result.Summary + importNote(res.InputsNeeded) + backupNote(res.BackupDir) + conflictNote(res.Conflicts)
+			_, _ = fmt.Fprint(w, summary)
+			if a.history != nil && !req.Options.NoHistory {
+				tm.Total = time.Since(start)
+				a.persistTurn(ctx, req, summary, recorder, res, cont)
+			}
+			return res, nil
+		}
+		if req.Options.ExpectEnvelope {
+			a.metrics.envelopeParseFailuresTotal.WithLabelValues(strconv.FormatBool(jsonMode)).Inc()
 		}
 	}
 
 	// Not a terraform_generate result — stream the raw accumulated content.
-	if _, err := fmt.Fprint(w, msgBuf.String()); err != nil {
-		return filesWritten, fmt.Errorf("agent: write error: %w", err)
+	if _, err := io.Copy(w, answer()); err != nil {
+		return fail(CodeOutput, fmt.Errorf("agent: write error: %w", err))
 	}
 
 	// Persist the turn to the conversation store (non-fatal on error).
-	if a.history != nil {
-		if err := a.history.Append(ctx, workspaceDir, store.RoleUser, userMessage); err != nil {
-			logging.FromContext(ctx).Warn("history: failed to persist user message", slog.Any("error", err))
+	if a.history != nil && !req.Options.NoHistory {
+		tm.Total = time.Since(start)
+		reply, err := msgBuf.HistoryText()
+		if err != nil {
+			logging.FromContext(ctx).Warn("history: failed to read spooled response", slog.Any("error", err))
+		}
+		a.persistTurn(ctx, req, reply, recorder, res, cont)
+	}
+
+	return res, nil
+}
+
+// withinRoot reports whether dir is root or lies beneath it.
+func withinRoot(root, dir string) bool {
+	root, dir = filepath.Clean(root), filepath.Clean(dir)
+	return strings.HasPrefix(dir+string(filepath.Separator), root+string(filepath.Separator))
+}
+
+// spoolDirFor returns the directory for a spilled response to req:
+// Config.SpoolDir when set, else the workspace's .tfai/tmp when the query
+// may write to an existing workspace, else os.TempDir().
+func (a *TerraformAgent) spoolDirFor(req QueryRequest) string {
+	if a.spoolDir != "" {
+		return a.spoolDir
+	}
+	ws := req.WorkspaceDir
+	if ws == "" || req.Options.NoWrite || (a.workspaceRoot != "" && !withinRoot(a.workspaceRoot, ws)) {
+		return os.TempDir()
+	}
+	if info, err := os.Stat(ws); err != nil || !info.IsDir() {
+		return os.TempDir()
+	}
+	dir, err := tfaidir.TmpDir(ws)
+	if err != nil {
+		return os.TempDir()
+	}
+	return dir
+}
+
+// writeEnvelope writes env's files beneath workspaceDir, asking resolve
+// about the files the user changed since tfai last wrote them, and sets
+// res.Files to the paths written in envelope order, res.BackupDir to the
+// backup of the files they replaced, and res.Conflicts to the resolutions.
+// When the write fails part way, res reports what it got to.
+func (a *TerraformAgent) writeEnvelope(ctx context.Context, env *TerraformAgentOutput, workspaceDir string, resolve ConflictResolver, res *QueryResult) error {
+	applied, err := applyFiles(ctx, env, workspaceDir, a.formatOnWrite, resolve)
+	// Even a failed apply may have written some files.
+	a.workspaceCache.Invalidate(workspaceDir)
+	if applied != nil {
+		res.Files, res.BackupDir, res.Conflicts = applied.Files, applied.BackupDir, applied.Conflicts
+	}
+	return err
+}
+
+// backupNote returns the sentence appended to a file summary when the
+// write backed up the files it replaced; "" when there was no backup.
+func backupNote(backupDir string) string {
+	if backupDir == "" {
+		return ""
+	}
+	return "\n\nThe files it replaced were backed up to " + backupDir + "; `tfai restore` puts them back."
+}
+
+// ApplyEnvelope writes a file envelope previewed by Run with
+// QueryOptions.PreviewFiles to workspaceDir, with the same root, size, and
+// path checks as a query that writes it directly, and records the write in
+// the workspace activity feed under requestID. The result's Files and
+// BackupDir report the write. The returned result is never nil; on error
+// its ErrorCode says why.
+func (a *TerraformAgent) ApplyEnvelope(ctx context.Context, workspaceDir string, env *TerraformAgentOutput, requestID string) (*QueryResult, error) {
+	res := &QueryResult{}
+	fail := func(code ErrorCode, err error) (*QueryResult, error) {
+		res.ErrorCode = code
+		return res, err
+	}
+	if a.workspaceRoot != "" {
+		if !withinRoot(a.workspaceRoot, workspaceDir) {
+			return fail(CodeWorkspaceOutsideRoot, fmt.Errorf("agent: workspaceDir %q is outside permitted root %q", workspaceDir, a.workspaceRoot))
 		}
-		if err := a.history.Append(ctx, workspaceDir, store.RoleAssistant, msgBuf.String()); err != nil {
-			logging.FromContext(ctx).Warn("history: failed to persist assistant message", slog.Any("error", err))
+	}
+	if err := a.envelopeLimits.Check(env.files()); err != nil {
+		return fail(CodeEnvelopeRejected, fmt.Errorf("agent: generated output rejected: %w", err))
+	}
+	if err := a.writeEnvelope(ctx, env, workspaceDir, nil, res); err != nil {
+		return fail(CodeApplyFailed, fmt.Errorf("agent: failed to apply files: %w", err))
+	}
+	a.recordActivity(ctx, QueryRequest{RequestID: requestID}, workspaceDir, env.Summary, res)
+	return res, nil
+}
+
+// canceled returns a wrapped context.Canceled when the caller canceled ctx,
+// e.g. because the HTTP client disconnected, and nil otherwise. A deadline
+// is not a cancellation: it is reported as the model failure it causes.
+func canceled(ctx context.Context) error {
+	if err := ctx.Err(); errors.Is(err, context.Canceled) {
+		return fmt.Errorf("agent: query canceled: %w", err)
+	}
+	return nil
+}
+
+// Query streams the answer to userMessage to w and reports whether files
+// were written to workspaceDir.
+//
+// Deprecated: use Run, which also reports sources, usage, and events. Query
+// will be removed in the next release.
+func (a *TerraformAgent) Query(ctx context.Context, userMessage, workspaceDir string, w io.Writer) (bool, error) {
+	res, err := a.Run(ctx, QueryRequest{Message: userMessage, WorkspaceDir: workspaceDir, Output: w})
+	return res.FilesWritten(), err
+}
+
+// recordActivity records the files a query wrote, and how it resolved the
+// ones the user had changed, in the workspace activity feed. Failures are
+// logged and do not fail the query: the files are already written.
+func (a *TerraformAgent) recordActivity(ctx context.Context, req QueryRequest, workspaceDir, summary string, res *QueryResult) {
+	if a.activity == nil {
+		return
+	}
+	var conflicts map[string]string
+	for _, c := range res.Conflicts {
+		if conflicts == nil {
+			conflicts = make(map[string]string, len(res.Conflicts))
 		}
+		conflicts[c.Path] = string(c.Resolution)
+	}
+	err := a.activity.RecordActivity(ctx, store.Activity{
+		Workspace: workspaceDir,
+		Summary:   summary,
+		Files:     res.Files,
+		Conflicts: conflicts,
+		RequestID: req.RequestID,
+		Model:     a.modelName,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("agent: failed to record workspace activity", slog.Any("error", err))
 	}
+}
 
-	return filesWritten, nil
+// appendAssistant persists the assistant reply, together with the query's
+// token usage when the provider reported it and its timings, t, when the
+// store can record them. Messages without usage are later reported as
+// untracked.
+func (a *TerraformAgent) appendAssistant(ctx context.Context, workspaceDir, sessionID, content string, t *Timings) error {
+	var usage *store.Usage
+	if prompt, completion, reported := usageMeterFrom(ctx).totals(); reported {
+		usage = &store.Usage{
+			Provider:         a.providerName,
+			Model:            a.modelName,
+			PromptTokens:     prompt,
+			CompletionTokens: completion,
+		}
+	}
+	if rec, ok := a.history.(store.TimingsRecorder); ok {
+		return rec.AppendWithMeta(ctx, workspaceDir, sessionID, store.RoleAssistant, content, usage, t.record()) //nolint:wrapcheck // store errors are already prefixed
+	}
+	if rec, ok := a.history.(store.UsageRecorder); ok && usage != nil {
+		return rec.AppendWithUsage(ctx, workspaceDir, sessionID, store.RoleAssistant, content, *usage) //nolint:wrapcheck // store errors are already prefixed
+	}
+	return a.history.Append(ctx, workspaceDir, sessionID, store.RoleAssistant, content) //nolint:wrapcheck // store errors are already prefixed
 }
 
 // buildMessages constructs the message slice for the agent, optionally
-// prepending RAG context retrieved for the user's query.
-func (a *TerraformAgent) buildMessages(ctx context.Context, userMessage, workspaceDir string) ([]*schema.Message, error) {
+// prepending RAG context retrieved for the user's query. It records the
+// injected sources and any history trimming in res. Context that fails to
+// load is logged and left out; building never fails.
+func (a *TerraformAgent) buildMessages(ctx context.Context, req QueryRequest, res *QueryResult) []*schema.Message {
+	userMessage, workspaceDir := req.Message, req.WorkspaceDir
+	events := eventsFrom(ctx)
 	messages := []*schema.Message{
-		schema.SystemMessage(systemPrompt),
+		schema.SystemMessage(a.prompt()),
+	}
+	if req.Options.ImportMode || wantsImport(userMessage) {
+		messages[0] = schema.SystemMessage(a.prompt() + "\n\n" + importPrompt)
 	}
 
 	// Inject recent conversation history so the LLM has multi-turn context.
-	// History is trimmed oldest-first to stay within the token budget.
-	var historyMsgs []*schema.Message
-	if a.history != nil {
-		prior, err := a.history.Recent(ctx, workspaceDir, a.historyDepth*2)
+	// History is trimmed oldest-first, a whole turn at a time, to stay within
+	// the token budget.
+	var turns [][]*schema.Message
+	var turnIDs []string
+	if a.history != nil && !req.Options.NoHistory {
+		events.OnPhase(PhaseLoadingHistory)
+		prior, err := a.history.Recent(ctx, workspaceDir, req.SessionID, a.historyDepth*2)
 		if err != nil {
 			logging.FromContext(ctx).Warn("history: failed to load prior messages", slog.Any("error", err))
 		} else {
-			for _, m := range prior {
-				switch m.Role {
-				case store.RoleUser:
-					historyMsgs = append(historyMsgs, schema.UserMessage(m.Content))
-				case store.RoleAssistant:
-					historyMsgs = append(historyMsgs, schema.AssistantMessage(m.Content, nil))
-				}
-			}
+			turns, turnIDs = historyTurns(prior)
 		}
 	}
 
 	if a.retriever != nil {
-		docs, err := a.retriever.Retrieve(ctx, userMessage, a.ragTopK)
+		events.OnPhase(PhaseRetrievingDocs)
+		topK := a.ragTopK
+		if req.Options.RAGTopK > 0 {
+			topK = req.Options.RAGTopK
+		}
+		docs, err := a.retrieveDocs(ctx, userMessage, topK)
 		if err != nil {
 			// RAG failure is non-fatal — log and continue without context.
 			logging.FromContext(ctx).Warn("RAG retrieval failed, continuing without context", slog.Any("error", err))
-		} else if len(docs) > 0 {
+		} else if docs = selectRAGDocs(docs, a.ragMinScore, a.ragMaxChars); len(docs) > 0 {
 			ragContext := buildRAGContext(docs)
+			for _, doc := range docs {
+				res.Sources = append(res.Sources, doc.Source)
+			}
+			events.OnSources(res.Sources)
 			messages = append(messages, schema.SystemMessage(ragContext))
 		}
 	}
@@ -381,9 +985,14 @@ func (a *TerraformAgent) buildMessages(ctx context.Context, userMessage, workspa
 	// Inject current workspace file contents so the LLM can read and modify
 	// existing files, not just generate new ones from scratch.
 	if workspaceDir != "" {
-		wsContext, err := buildWorkspaceContext(workspaceDir)
-		if err == nil && wsContext != "" {
-			messages = append(messages, schema.SystemMessage(wsContext))
+		events.OnPhase(PhaseReadingWorkspace)
+		files, err := a.workspaceFiles.Get(workspaceDir, strings.Join(req.Scope, "\n"), func() ([]workspaceFile, error) {
+			return listWorkspaceFiles(workspaceDir, req.Scope, a.workspaceLimits)
+		})
+		if err == nil {
+			if wsContext := buildWorkspaceContext(ctx, workspaceDir, files, userMessage, a.workspaceLimits, a.secretScanner); wsContext != "" {
+				messages = append(messages, schema.SystemMessage(wsContext))
+			}
 		}
 	}
 
@@ -392,9 +1001,18 @@ func (a *TerraformAgent) buildMessages(ctx context.Context, userMessage, workspa
 
 	// Trim history oldest-first so the total estimated token count fits within
 	// the configured context budget.
-	before := len(historyMsgs)
-	historyMsgs = budget.TrimHistory(fixed, historyMsgs, a.maxContextTokens)
+	before := countMessages(turns)
+	kept := budget.TrimTurns(a.tokenCounter, fixed, turns, a.maxContextTokens)
+	var summary *schema.Message
+	if a.summarizeDropped && before-countMessages(kept) > a.summarizeMinDropped {
+		// Make room for the summary, then summarise every turn dropped.
+		kept = budget.TrimTurns(a.tokenCounter, fixed, turns, a.maxContextTokens-summaryMaxTokens)
+		summary = a.historySummary(ctx, workspaceDir, req.SessionID, turns, turnIDs, len(turns)-len(kept))
+		res.HistorySummarized = summary != nil
+	}
+	historyMsgs := slices.Concat(kept...)
 	if dropped := before - len(historyMsgs); dropped > 0 {
+		res.HistoryDropped = dropped
 		logging.FromContext(ctx).Warn("budget: dropped history messages to fit context window",
 			slog.Int("dropped", dropped),
 			slog.Int("retained", len(historyMsgs)),
@@ -405,92 +1023,259 @@ func (a *TerraformAgent) buildMessages(ctx context.Context, userMessage, workspa
 	// Insert trimmed history between the system prompt and the rest of the fixed
 	// messages (RAG context, workspace context, user message).
 	// messages currently holds: [system, ...rag, ...workspace]
-	// We want: [system, ...history, ...rag, ...workspace, user]
-	result := make([]*schema.Message, 0, 1+len(historyMsgs)+len(messages)-1+1)
-	result = append(result, messages[0])     // system prompt
+	// We want: [system, summary, ...history, ...rag, ...workspace, user]
+	result := make([]*schema.Message, 0, 2+len(historyMsgs)+len(messages)-1+1)
+	result = append(result, messages[0]) // system prompt
+	if summary != nil {
+		result = append(result, summary) // dropped history, summarised
+	}
 	result = append(result, historyMsgs...)  // trimmed history
 	result = append(result, messages[1:]...) // RAG + workspace
 	result = append(result, schema.UserMessage(userMessage))
-	return result, nil
+	return result
 }
 
-// Limits applied when building workspace context to prevent OOM on large repos.
-const (
-	// maxWorkspaceFiles is the maximum number of .tf files included in context.
-	maxWorkspaceFiles = 50
-	// maxWorkspaceFileBytes is the maximum size of a single .tf file included.
-	maxWorkspaceFileBytes = 100 * 1024 // 100 KiB
-	// maxWorkspaceTotalBytes is the maximum total size of all included files.
-	maxWorkspaceTotalBytes = 1024 * 1024 // 1 MiB
-)
+// prompt returns the system prompt, with a note on the missing terraform
+// tools when they are unavailable.
+func (a *TerraformAgent) prompt() string {
+	if a.terraformUnavailable != nil {
+		return systemPrompt + "\n\n" + capabilityNote(a.terraformUnavailable)
+	}
+	return systemPrompt
+}
+
+// buildWorkspaceContext formats files, as listed by listWorkspaceFiles, into
+// a system message so the LLM can inspect and modify existing Terraform
+// configurations. Files are included in order of relevance to message (see
+// rankWorkspaceFiles) up to limits, and the rest counted in a closing note.
+// Variable files (*.tfvars, *.tfvars.json) are included up to
+// maxTfvarsBytes, with the values of secret-looking variables masked (see
+// redactTfvars). Returns an empty string if no file could be included.
+// Unreadable files are skipped. UTF-16 files are transcoded and CRLF line
+// endings normalised to LF; files that are not text are skipped with a
+// warning. Credentials found by scanner are replaced with <redacted:TYPE>
+// markers, honouring the workspace's .tfai/secrets.allow, and logged by
+// file.
+func buildWorkspaceContext(ctx context.Context, workspaceDir string, files []workspaceFile, message string, limits WorkspaceLimits, scanner *secretscan.Scanner) string {
+	log := logging.FromContext(ctx)
+	allow, err := secretscan.LoadAllowlist(secretscan.AllowlistPath(workspaceDir))
+	if err != nil {
+		// A broken allowlist must not disable redaction; scan without it.
+		log.Warn("secretscan: ignoring unreadable allowlist", slog.Any("error", err))
+	} else {
+		scanner = scanner.WithAllowlist(allow)
+	}
 
-// buildWorkspaceContext reads .tf files in the workspace directory and
-// formats them into a system message so the LLM can inspect and modify
-// existing Terraform configurations. Returns an empty string if the directory
-// contains no .tf files. Non-fatal errors (unreadable files) are skipped.
-// File count, per-file size, and total size are capped to prevent OOM.
-func buildWorkspaceContext(workspaceDir string) (string, error) {
 	var sb strings.Builder
 	fileCount := 0
 	totalBytes := 0
+	tfvarsBytes := 0
+	omitted := 0
 
-	err := filepath.WalkDir(workspaceDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // skip unreadable entries
-		}
-		if d.IsDir() || !strings.HasSuffix(d.Name(), ".tf") {
-			return nil
+	for _, f := range rankWorkspaceFiles(files, message) {
+		if fileCount >= limits.MaxFiles || totalBytes+int(f.size) > limits.MaxTotalBytes {
+			omitted++
+			continue
 		}
-		if fileCount >= maxWorkspaceFiles {
-			return fs.SkipAll
-		}
-		info, err := d.Info()
+		name := path.Base(f.rel)
+		content, err := os.ReadFile(filepath.Join(workspaceDir, filepath.FromSlash(f.rel)))
 		if err != nil {
-			return nil
-		}
-		if info.Size() > maxWorkspaceFileBytes {
-			return nil // skip oversized files silently
+			continue // skip unreadable files
 		}
-		if totalBytes+int(info.Size()) > maxWorkspaceTotalBytes {
-			return fs.SkipAll
-		}
-		rel, err := filepath.Rel(workspaceDir, path)
+		text, err := textenc.Decode(content)
 		if err != nil {
-			return nil
+			log.Warn("agent: skipping undecodable workspace file", slog.String("file", f.rel), slog.Any("error", err))
+			continue
 		}
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil // skip unreadable files
+		body, lang := text.Content, "hcl"
+		if isTfvarsFile(name) {
+			vars, n, err := redactTfvars(name, body)
+			if err != nil {
+				// Sensitive values cannot be told apart; leave the file out.
+				log.Warn("agent: skipping unparseable variables file", slog.String("file", f.rel), slog.Any("error", err))
+				continue
+			}
+			if tfvarsBytes+len(vars) > maxTfvarsBytes {
+				log.Warn("agent: skipping variables file over the context cap", slog.String("file", f.rel), slog.Int("cap_bytes", maxTfvarsBytes))
+				continue
+			}
+			if n > 0 {
+				log.Info("agent: redacted sensitive variables from workspace context", slog.String("file", f.rel), slog.Int("values", n))
+			}
+			tfvarsBytes += len(vars)
+			body = vars
+			if strings.HasSuffix(name, ".json") {
+				lang = "json"
+			}
+		}
+		redacted, findings := scanner.Redact(f.rel, body)
+		if len(findings) > 0 {
+			log.Warn("secretscan: redacted secrets from workspace context",
+				slog.String("file", f.rel),
+				slog.String("findings", secretscan.Summary(findings)),
+			)
 		}
-		fmt.Fprintf(&sb, "### %s\n```hcl\n%s\n```\n\n", rel, content)
+		fmt.Fprintf(&sb, "### %s\n```%s\n%s\n```\n\n", f.rel, lang, redacted)
 		fileCount++
 		totalBytes += len(content)
-		return nil
-	})
-	if err != nil {
-		return "", fmt.Errorf("agent: workspace walk failed: %w", err)
+	}
+	if omitted > 0 {
+		log.Info("agent: omitted workspace files over the context limits", slog.Int("omitted", omitted), slog.Int("included", fileCount))
 	}
 
 	if sb.Len() == 0 {
-		return "", nil
+		return ""
+	}
+	if omitted > 0 {
+		sb.WriteString(omittedNote(omitted))
 	}
 
 	return "## Current Workspace Files\n\n" +
 		"The following Terraform files are currently in the workspace. " +
 		"When the user asks to modify, update, or extend the configuration, " +
 		"use these as the base and return the full updated file contents in the JSON envelope.\n\n" +
-		sb.String(), nil
+		sb.String() + stateContext(workspaceDir) + lockfileContext(ctx, workspaceDir)
+}
+
+// stateContext is a one-line note naming the workspace's state backend and
+// selected terraform workspace, so the model does not suggest local-state
+// fixes when state lives in a remote backend.
+func stateContext(workspaceDir string) string {
+	backend := hclinspect.BackendType(workspaceDir)
+	ws := hclinspect.ActiveWorkspace(workspaceDir)
+	if backend == hclinspect.BackendLocal {
+		return fmt.Sprintf("State: local backend (terraform.tfstate), terraform workspace %q.\n\n", ws)
+	}
+	return fmt.Sprintf("State: %q backend, terraform workspace %q. State is remote, so no local terraform.tfstate is expected.\n\n", backend, ws)
+}
+
+// workspaceContextFingerprint fingerprints the files buildWorkspaceContext
+// reads: every .tf and variables file, the lock file, the selected terraform
+// workspace, and the secrets allowlist.
+func workspaceContextFingerprint(workspaceDir string) (string, error) {
+	allowlist := filepath.ToSlash(filepath.Join(tfaidir.DirName, secretscan.AllowlistFile))
+	return wscache.TreeFingerprint(workspaceDir, func(rel string) bool { //nolint:wrapcheck // wscache errors are already prefixed
+		return strings.HasSuffix(rel, ".tf") || isTfvarsFile(rel) || rel == hclinspect.LockfileName || rel == hclinspect.EnvironmentFile || rel == allowlist
+	})
+}
+
+// lockfileContext renders the providers locked in the workspace's
+// .terraform.lock.hcl, or returns "" when there is no readable lock file.
+func lockfileContext(ctx context.Context, workspaceDir string) string {
+	providers, err := hclinspect.ReadLockfile(workspaceDir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logging.FromContext(ctx).Warn("agent: skipping unparseable lock file", slog.Any("error", err))
+		}
+		return ""
+	}
+	if len(providers) == 0 {
+		return ""
+	}
+	return "## Provider Lock File\n\n" +
+		"terraform init selected these provider versions (" + hclinspect.LockfileName + "):\n\n" +
+		hclinspect.RenderLockfile(providers) + "\n"
+}
+
+// inScope reports whether the slash-separated relative path rel matches one
+// of the scope patterns. An empty scope matches everything.
+func inScope(scope []string, rel string) bool {
+	if len(scope) == 0 {
+		return true
+	}
+	for _, pattern := range scope {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// rerankCandidates is how many documents per injected document are
+// retrieved when a reranker is configured.
+const rerankCandidates = 3
+
+// retrieveDocs returns the topK documents to inject for userMessage. With a
+// reranker it retrieves rerankCandidates*topK candidates and reranks them
+// down to topK; a failed rerank keeps the first topK in retrieval order.
+func (a *TerraformAgent) retrieveDocs(ctx context.Context, userMessage string, topK int) ([]rag.Document, error) {
+	if a.reranker == nil {
+		return a.retriever.Retrieve(ctx, userMessage, topK) //nolint:wrapcheck // callers log retrieval errors as-is
+	}
+	candidates, err := a.retriever.Retrieve(ctx, userMessage, topK*rerankCandidates)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // callers log retrieval errors as-is
+	}
+	docs, err := a.reranker.Rerank(ctx, userMessage, candidates, topK)
+	if err != nil {
+		logging.FromContext(ctx).Warn("RAG rerank failed, using retrieval order", slog.Any("error", err))
+		if len(candidates) > topK {
+			candidates = candidates[:topK]
+		}
+		return candidates, nil
+	}
+	return docs, nil
+}
+
+// DefaultRAGMaxChars is the default cap on the total content of the
+// documents injected into one prompt.
+const DefaultRAGMaxChars = 20000
+
+// selectRAGDocs returns the docs worth injecting, in their retrieval order:
+// those scoring at least minScore, minus the lowest-scoring ones while their
+// total content exceeds maxChars (negative for no cap). The last document
+// among equal scores is dropped first.
+func selectRAGDocs(docs []rag.Document, minScore float32, maxChars int) []rag.Document {
+	kept := make([]rag.Document, 0, len(docs))
+	total := 0
+	for _, d := range docs {
+		if d.Score < minScore {
+			continue
+		}
+		kept = append(kept, d)
+		total += len(d.Content)
+	}
+	for maxChars >= 0 && total > maxChars && len(kept) > 0 {
+		lowest := len(kept) - 1
+		for i := len(kept) - 2; i >= 0; i-- {
+			if kept[i].Score < kept[lowest].Score {
+				lowest = i
+			}
+		}
+		total -= len(kept[lowest].Content)
+		kept = append(kept[:lowest], kept[lowest+1:]...)
+	}
+	return kept
 }
 
 // buildRAGContext formats retrieved documents into a system message that
-// provides the LLM with relevant Terraform documentation context.
+// provides the LLM with relevant Terraform documentation context. Each
+// source header carries the document's score and provider and doc_type
+// metadata, when known, so the model can weigh the excerpts.
 func buildRAGContext(docs []rag.Document) string {
 	context := "## Relevant Terraform Documentation\n\n" +
-		"The following documentation excerpts are relevant to the user's query. " +
+		"The following documentation excerpts are relevant to the user's query, " +
+		"each with its relevance score (0–1, higher is better) where known. " +
 		"Use them to inform your response where applicable.\n\n"
 
 	for i, doc := range docs {
-		context += fmt.Sprintf("### Source %d: %s\n%s\n\n", i+1, doc.Source, doc.Content)
+		source := doc.Source
+		if section := doc.Metadata["section"]; section != "" {
+			source += " — " + section
+		}
+		var details []string
+		if doc.Score != 0 {
+			details = append(details, fmt.Sprintf("score %.2f", doc.Score))
+		}
+		for _, key := range []string{"provider", "doc_type"} {
+			if v := doc.Metadata[key]; v != "" {
+				details = append(details, key+": "+v)
+			}
+		}
+		if len(details) > 0 {
+			source += " (" + strings.Join(details, ", ") + ")"
+		}
+		context += fmt.Sprintf("### Source %d: %s\n%s\n\n", i+1, source, doc.Content)
 	}
 
 	return context
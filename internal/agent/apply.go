@@ -1,17 +1,174 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+
+	"github.com/54b3r/tfai-go/internal/filediff"
+	"github.com/54b3r/tfai-go/internal/textenc"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
 )
 
-func applyFiles(output *TerraformAgentOutput, workspaceDir string) error {
+// appliedFiles is what applyFiles did.
+type appliedFiles struct {
+	// Files lists the envelope paths written, in envelope order.
+	Files []string
+	// BackupDir is the backup of the files replaced, relative to the
+	// workspace; "" when no existing file was replaced.
+	BackupDir string
+	// Conflicts lists how each file the user had changed since tfai last
+	// wrote it was resolved, in envelope order.
+	Conflicts []ResolvedConflict
+}
+
+// applyFiles writes the generated files beneath workspaceDir. When format is
+// true, .tf and .tfvars content is rewritten into `terraform fmt` style first.
+// The content each file had before tfai first changed it is kept in the
+// workspace manifest for `tfai describe-changes`, and the files it replaces
+// are backed up first (see tfaidir.Backup). When resolve is not nil, it
+// decides how each file the user changed since tfai last wrote it is
+// written; otherwise such files are overwritten. When writing fails after
+// the backup was made, the returned appliedFiles still reports the backup
+// and the files written so far, and the error names the backup directory.
+func applyFiles(ctx context.Context, output *TerraformAgentOutput, workspaceDir string, format bool, resolve ConflictResolver) (*appliedFiles, error) {
 	// Clean the workspace root once so all comparisons are against a canonical path.
 	root := filepath.Clean(workspaceDir)
 
-	// Loop over output.Files output by the agent and add them to filesystem
+	// The workspace root must already exist — callers validate it up front.
+	// Only subdirectories beneath it are created here, so a mistyped root
+	// surfaces as an error instead of silently becoming a new directory.
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("agent::applyFiles: workspace %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("agent::applyFiles: workspace %s is not a directory", root)
+	}
+
+	// Resolve and check every path before writing anything, so the manifest
+	// records the baselines of the whole set up front.
+	files, err := resolveFiles(output, root)
+	if err != nil {
+		return nil, err
+	}
+	if format {
+		for i := range files {
+			files[i].Content = formatHCL(files[i].Rel, files[i].Content)
+		}
+	}
+	applied := &appliedFiles{}
+	if resolve != nil {
+		if files, applied.Conflicts, err = resolveConflicts(ctx, root, files, resolve); err != nil {
+			return nil, err
+		}
+	}
+
+	rels := make([]string, len(files))
+	for i, f := range files {
+		rels[i] = f.Rel
+	}
+	if err := tfaidir.RecordBaseline(root, rels); err != nil {
+		return nil, fmt.Errorf("agent::applyFiles: %w", err)
+	}
+	if applied.BackupDir, err = tfaidir.Backup(root, rels, time.Now()); err != nil {
+		return nil, fmt.Errorf("agent::applyFiles: %w", err)
+	}
+
+	written := make(map[string]string, len(files))
+	for _, f := range files {
+		filePath := filepath.Join(root, f.Rel)
+		// Create any subdirectories
+		dir := filepath.Dir(filePath)
+		if dir != root {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return applied, fmt.Errorf("agent::applyFiles: failed to create directory %s: %w%s", dir, err, backupHint(applied.BackupDir))
+			}
+		}
+
+		// Write file to disk, keeping CRLF line endings if the file being
+		// replaced used them. New files are written with LF.
+		if err := textenc.WriteFile(filePath, f.Content, 0644); err != nil {
+			return applied, fmt.Errorf("agent::applyFiles: failed to write file %s: %w%s", filePath, err, backupHint(applied.BackupDir))
+		}
+		written[f.Rel] = f.Content
+		applied.Files = append(applied.Files, f.Path)
+	}
+	if err := tfaidir.RecordWritten(root, written); err != nil {
+		return applied, fmt.Errorf("agent::applyFiles: %w", err)
+	}
+	return applied, nil
+}
+
+// backupHint returns the clause appended to a write error once the files
+// being replaced were backed up, so the user can find them; "" when there
+// was no backup.
+func backupHint(backupDir string) string {
+	if backupDir == "" {
+		return ""
+	}
+	return " (the files being replaced were backed up to " + backupDir + "; `tfai restore` puts them back)"
+}
+
+// resolveConflicts asks resolve about each of files the user changed since
+// tfai last wrote it, and returns files without the ones to leave alone and
+// with the content each resolution chose, and the resolutions made.
+func resolveConflicts(ctx context.Context, root string, files []resolvedFile, resolve ConflictResolver) ([]resolvedFile, []ResolvedConflict, error) {
+	rels := make([]string, len(files))
+	for i, f := range files {
+		rels[i] = f.Rel
+	}
+	mine, err := tfaidir.UserModified(root, rels)
+	if err != nil {
+		return nil, nil, fmt.Errorf("agent::applyFiles: %w", err)
+	}
+	if len(mine) == 0 {
+		return files, nil, nil
+	}
+	var conflicts []ResolvedConflict
+	var kept []resolvedFile
+	for _, f := range files {
+		current, ok := mine[f.Rel]
+		if !ok || current == f.Content {
+			kept = append(kept, f)
+			continue
+		}
+		c := FileConflict{Path: filepath.ToSlash(f.Rel), Mine: current, Generated: f.Content}
+		resolution := resolve(ctx, c)
+		content, write := resolveConflict(c, resolution)
+		if !write {
+			resolution = KeepMine
+		} else {
+			f.Content = content
+			kept = append(kept, f)
+		}
+		conflicts = append(conflicts, ResolvedConflict{Path: c.Path, Resolution: resolution})
+	}
+	return kept, conflicts, nil
+}
+
+// resolvedFile is a file of an envelope whose path was checked.
+type resolvedFile struct {
+	// Path is the path as given in the envelope.
+	Path string
+	// Rel is the cleaned path relative to the workspace root.
+	Rel string
+	// Content is the content to write.
+	Content string
+}
+
+// resolveFiles returns the files of output, in envelope order, with their
+// paths relative to the cleaned workspace root, rejecting any path that
+// escapes it.
+func resolveFiles(output *TerraformAgentOutput, root string) ([]resolvedFile, error) {
+	var files []resolvedFile
 	for _, file := range output.Files {
 		// Defensive: strip the workspace root prefix if the LLM echoed it back
 		// in the file path. Without this, --out /tmp/foo with an LLM path of
@@ -25,21 +182,69 @@ func applyFiles(output *TerraformAgentOutput, workspaceDir string) error {
 		filePath := filepath.Join(root, cleanPath)
 		// Separator-aware prefix check prevents /tmp/foo matching /tmp/foobar.
 		if !strings.HasPrefix(filePath+string(filepath.Separator), root+string(filepath.Separator)) {
-			return fmt.Errorf("agent::applyFiles: file path %s is outside workspace %s", filePath, root)
-		}
-		// Create any subdirectories
-		dir := filepath.Dir(filePath)
-		if dir != "" {
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				return fmt.Errorf("agent::applyFiles: failed to create directory %s: %w", dir, err)
-			}
+			return nil, fmt.Errorf("agent::applyFiles: file path %s is outside workspace %s", filePath, root)
 		}
+		files = append(files, resolvedFile{Path: file.Path, Rel: cleanPath, Content: file.Content})
+	}
+	return files, nil
+}
+
+// FilePreview is what writing one file of an envelope would change.
+type FilePreview struct {
+	// Path is the slash-separated path relative to the workspace.
+	Path string
+	// New is true when the file does not exist yet.
+	New bool
+	// Diff is the unified diff from the current content to the generated
+	// content, empty when they are equal.
+	Diff string
+}
 
-		// Write file to disk
-		if err := os.WriteFile(filePath, []byte(file.Content), 0644); err != nil {
-			return fmt.Errorf("agent::applyFiles: failed to write file %s: %w", filePath, err)
+// previewFiles returns what applyFiles would change beneath workspaceDir,
+// with the same path checks and formatting, without writing anything.
+func previewFiles(output *TerraformAgentOutput, workspaceDir string, format bool) ([]FilePreview, error) {
+	root := filepath.Clean(workspaceDir)
+	files, err := resolveFiles(output, root)
+	if err != nil {
+		return nil, err
+	}
+	previews := make([]FilePreview, 0, len(files))
+	for _, f := range files {
+		filePath := filepath.Join(root, f.Rel)
+		content := f.Content
+		if format {
+			content = formatHCL(filePath, content)
 		}
+		p := FilePreview{Path: filepath.ToSlash(f.Rel)}
+		var before string
+		b, err := os.ReadFile(filePath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			p.New = true
+		case err != nil:
+			return nil, fmt.Errorf("agent::previewFiles: failed to read %s: %w", filePath, err)
+		default:
+			text, err := textenc.Decode(b)
+			if err != nil {
+				return nil, fmt.Errorf("agent::previewFiles: %s: %w", filePath, err)
+			}
+			before = text.Content
+		}
+		p.Diff = filediff.Unified(p.Path, before, content)
+		previews = append(previews, p)
+	}
+	return previews, nil
+}
 
+// formatHCL returns content in canonical `terraform fmt` style when path is
+// a .tf or .tfvars file, and unchanged otherwise. The formatter only
+// re-spaces tokens, so content with syntax errors is still written and left
+// for terraform_validate to report.
+func formatHCL(path, content string) string {
+	switch filepath.Ext(path) {
+	case ".tf", ".tfvars":
+		return string(hclwrite.Format([]byte(content)))
+	default:
+		return content
 	}
-	return nil
 }
@@ -1,10 +1,19 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/envelope"
+	"github.com/54b3r/tfai-go/internal/testutil"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
 )
 
 const (
@@ -54,8 +63,8 @@ func TestApplyFilesTwoFiles(t *testing.T) {
 
 	// aoFiles := agentOutput.Files
 
-	dir := t.TempDir() // Use TempdDir instead to ensure proper cleanup and keep things self contained
-	err := applyFiles(agentOutput, dir)
+	dir := testutil.NewWorkspace(t).Dir()
+	_, err := applyFiles(context.Background(), agentOutput, dir, true, nil)
 	if err != nil {
 		t.Errorf("applyFiles() error = %v", err)
 	}
@@ -67,6 +76,9 @@ func TestApplyFilesTwoFiles(t *testing.T) {
 	}
 
 	for _, entry := range entries {
+		if entry.Name() == tfaidir.DirName {
+			continue // the change manifest, not a generated file
+		}
 		_, err := os.ReadFile(filepath.Join(dir, entry.Name()))
 		if err != nil {
 			t.Errorf("Failed to read file %s: %v", entry.Name(), err)
@@ -81,8 +93,8 @@ func TestApplyFilesModulePath(t *testing.T) {
 	// Not sure if this type of testing is acceptable, but it shows the true behavior with an actual
 	// agent output that has been parsed by the code
 	agentOutput := returnAgentOutput(t, agentOutputModulePath)
-	dir := t.TempDir() // Use TempdDir instead to ensure proper cleanup and keep things self contained
-	err := applyFiles(agentOutput, dir)
+	dir := testutil.NewWorkspace(t).Dir()
+	_, err := applyFiles(context.Background(), agentOutput, dir, true, nil)
 	if err != nil {
 		t.Errorf("applyFiles() error = %v", err)
 	}
@@ -146,7 +158,7 @@ func TestApplyFilesNoPathDoubling(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			dir := t.TempDir()
+			dir := testutil.NewWorkspace(t).Dir()
 			fp := tc.filePath
 			if tc.prefixWithRoot {
 				fp = filepath.Join(dir, tc.filePath)
@@ -156,7 +168,7 @@ func TestApplyFilesNoPathDoubling(t *testing.T) {
 				Files:   []GeneratedFile{{Path: fp, Content: "# content"}},
 			}
 
-			err := applyFiles(output, dir)
+			_, err := applyFiles(context.Background(), output, dir, true, nil)
 			if tc.wantError {
 				if err == nil {
 					t.Errorf("applyFiles() expected error, got nil")
@@ -186,8 +198,8 @@ func TestApplyFilesPathTraversal(t *testing.T) {
 
 	agentOutput := returnAgentOutput(t, agentOutputPathTraversal)
 
-	dir := t.TempDir() // Use TempdDir instead to ensure proper cleanup and keep things self contained
-	err := applyFiles(agentOutput, dir)
+	dir := testutil.NewWorkspace(t).Dir()
+	_, err := applyFiles(context.Background(), agentOutput, dir, true, nil)
 	contains := "agent::applyFiles: file path "
 	if err == nil || !strings.Contains(err.Error(), contains) {
 		t.Errorf("applyFiles() error = %v", err)
@@ -201,3 +213,329 @@ func TestApplyFilesPathTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestApplyFilesMissingWorkspace(t *testing.T) {
+	t.Parallel()
+
+	agentOutput := returnAgentOutput(t, agentOutputFilesOnly)
+
+	// A mistyped root must fail rather than be created implicitly.
+	dir := testutil.NewWorkspace(t).Path("does-not-exist")
+	if _, err := applyFiles(context.Background(), agentOutput, dir, true, nil); err == nil {
+		t.Fatal("applyFiles() expected error for nonexistent workspace, got nil")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("workspace %s should not have been created", dir)
+	}
+}
+
+func TestApplyFilesPreservesLineEndings(t *testing.T) {
+	t.Parallel()
+
+	// main.tf was checked out on Windows; new.tf does not exist yet.
+	dir := testutil.NewWorkspace(t).WithFile("main.tf", "# old\r\n").Dir()
+	output := &TerraformAgentOutput{Files: []GeneratedFile{
+		{Path: "main.tf", Content: "locals {\n  a = 1\n}\n"},
+		{Path: "new.tf", Content: "locals {\n  b = 2\n}\n"},
+	}}
+	if _, err := applyFiles(context.Background(), output, dir, true, nil); err != nil {
+		t.Fatalf("applyFiles() error = %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"main.tf": "locals {\r\n  a = 1\r\n}\r\n",
+		"new.tf":  "locals {\n  b = 2\n}\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestApplyFilesRecordsBaseline(t *testing.T) {
+	t.Parallel()
+
+	dir := testutil.NewWorkspace(t).WithFile("main.tf", "# before tfai\n").Dir()
+	output := &TerraformAgentOutput{Files: []GeneratedFile{
+		{Path: "main.tf", Content: "# first edit\n"},
+		{Path: filepath.Join(dir, "modules/vpc/main.tf"), Content: "# new\n"},
+	}}
+	if _, err := applyFiles(context.Background(), output, dir, false, nil); err != nil {
+		t.Fatalf("applyFiles() error = %v", err)
+	}
+	output.Files[0].Content = "# second edit\n"
+	if _, err := applyFiles(context.Background(), output, dir, false, nil); err != nil {
+		t.Fatalf("applyFiles() error = %v", err)
+	}
+
+	m, err := tfaidir.LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	if b := m.Files["main.tf"]; !b.Existed || b.Content != "# before tfai\n" {
+		t.Errorf("main.tf: want the content from before the first edit, got %+v", b)
+	}
+	if b, ok := m.Files["modules/vpc/main.tf"]; !ok || b.Existed {
+		t.Errorf("modules/vpc/main.tf: want a created-file baseline, got %+v (%v)", b, ok)
+	}
+}
+
+func TestApplyFilesDeepModuleTree(t *testing.T) {
+	t.Parallel()
+
+	ws := testutil.Fixture(t, testutil.FixtureModules)
+	const deep = "modules/platform/eks/nodegroups/spot/main.tf"
+	const deeper = "modules/platform/eks/nodegroups/spot/launch/template/main.tf"
+	output := &TerraformAgentOutput{Files: []GeneratedFile{
+		{Path: deep, Content: "# replaced\n"},
+		{Path: deeper, Content: "# new\n"},
+	}}
+	if _, err := applyFiles(context.Background(), output, ws.Dir(), false, nil); err != nil {
+		t.Fatalf("applyFiles() error = %v", err)
+	}
+	for rel, want := range map[string]string{deep: "# replaced\n", deeper: "# new\n"} {
+		got, err := os.ReadFile(ws.Path(rel))
+		if err != nil || string(got) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", rel, want, got, err)
+		}
+	}
+	m, err := tfaidir.LoadManifest(ws.Dir())
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	if b := m.Files[deep]; !b.Existed || !strings.Contains(b.Content, "aws_eks_node_group") {
+		t.Errorf("%s: want the fixture content as baseline, got %+v", deep, b)
+	}
+	if b, ok := m.Files[deeper]; !ok || b.Existed {
+		t.Errorf("%s: want a created-file baseline, got %+v (%v)", deeper, b, ok)
+	}
+}
+
+func TestQueryRejectsOversizedEnvelope(t *testing.T) {
+	t.Parallel()
+
+	// Three files against a two-file limit: the envelope must be rejected
+	// before applyFiles runs, leaving the workspace untouched.
+	m := &scriptedModel{script: func(int, []*schema.Message) *schema.Message {
+		return schema.AssistantMessage(agentOutputModulePath, nil)
+	}}
+	a, err := New(context.Background(), &Config{
+		ChatModel:       m,
+		EnvelopeLimits:  envelope.Limits{MaxFiles: 2},
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	dir := testutil.NewWorkspace(t).Dir()
+	var out strings.Builder
+	res, err := a.Run(context.Background(), QueryRequest{Message: "generate", WorkspaceDir: dir, Output: &out})
+	var le *envelope.LimitError
+	if !errors.As(err, &le) || le.Limit != envelope.LimitFiles {
+		t.Fatalf("expected max_files LimitError, got %v", err)
+	}
+	if res.FilesWritten() {
+		t.Error("no files may be reported for a rejected envelope")
+	}
+	if res.ErrorCode != CodeEnvelopeRejected {
+		t.Errorf("expected error code %s, got %q", CodeEnvelopeRejected, res.ErrorCode)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected empty workspace, found %d entries", len(entries))
+	}
+}
+
+func TestApplyFilesFormatsHCL(t *testing.T) {
+	t.Parallel()
+
+	const (
+		unformatted = "resource \"aws_instance\" \"web\" {\nami = \"ami-123\"\n    instance_type=\"t3.micro\"\n}\n"
+		formatted   = "resource \"aws_instance\" \"web\" {\n  ami           = \"ami-123\"\n  instance_type = \"t3.micro\"\n}\n"
+		tfvars      = "region=\"eu-west-1\"\nenvironment =  \"prod\"\n"
+		tfvarsFmt   = "region      = \"eu-west-1\"\nenvironment = \"prod\"\n"
+		readme      = "# Usage\nami = \"x\"\n"
+	)
+
+	tests := []struct {
+		name   string
+		format bool
+		want   map[string]string
+	}{
+		{
+			name:   "format on write",
+			format: true,
+			want:   map[string]string{"main.tf": formatted, "prod.tfvars": tfvarsFmt, "README.md": readme},
+		},
+		{
+			name:   "format disabled",
+			format: false,
+			want:   map[string]string{"main.tf": unformatted, "prod.tfvars": tfvars, "README.md": readme},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := testutil.NewWorkspace(t).Dir()
+			output := &TerraformAgentOutput{Files: []GeneratedFile{
+				{Path: "main.tf", Content: unformatted},
+				{Path: "prod.tfvars", Content: tfvars},
+				{Path: "README.md", Content: readme},
+			}}
+			if _, err := applyFiles(context.Background(), output, dir, tc.format, nil); err != nil {
+				t.Fatalf("applyFiles() error = %v", err)
+			}
+			for name, want := range tc.want {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s: expected %q, got %q", name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestPreviewFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := testutil.NewWorkspace(t).
+		WithFile("main.tf", "# one\n# two\n").
+		WithFile("same.tf", "# same\n").
+		Dir()
+	output := &TerraformAgentOutput{Files: []GeneratedFile{
+		{Path: "main.tf", Content: "# one\n# 2\n"},
+		{Path: "same.tf", Content: "# same\n"},
+		{Path: filepath.Join(dir, "modules/vpc/main.tf"), Content: "# new\n"},
+	}}
+	got, err := previewFiles(output, dir, false)
+	if err != nil {
+		t.Fatalf("previewFiles() error = %v", err)
+	}
+	want := []FilePreview{
+		{Path: "main.tf", Diff: "--- a/main.tf\n+++ b/main.tf\n@@ -1,2 +1,2 @@\n # one\n-# two\n+# 2\n"},
+		{Path: "same.tf"},
+		{Path: "modules/vpc/main.tf", New: true, Diff: "--- /dev/null\n+++ b/modules/vpc/main.tf\n@@ -0,0 +1 @@\n+# new\n"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("want %d previews, got %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("preview %d:\nwant %+v\ngot  %+v", i, want[i], got[i])
+		}
+	}
+
+	// A preview never touches the workspace, not even the change manifest.
+	if b, err := os.ReadFile(filepath.Join(dir, "main.tf")); err != nil || string(b) != "# one\n# two\n" {
+		t.Errorf("expected main.tf unchanged, got %q (%v)", b, err)
+	}
+	for _, rel := range []string{"modules", tfaidir.DirName} {
+		if _, err := os.Stat(filepath.Join(dir, rel)); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to be created, got %v", rel, err)
+		}
+	}
+
+	traversal := returnAgentOutput(t, agentOutputPathTraversal)
+	if _, err := previewFiles(traversal, dir, false); err == nil {
+		t.Error("expected a path outside the workspace to be rejected")
+	}
+}
+
+func TestApplyFilesBacksUpReplacedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := testutil.NewWorkspace(t).WithFile("main.tf", "# before\n").Dir()
+	output := &TerraformAgentOutput{Files: []GeneratedFile{
+		{Path: "main.tf", Content: "# after\n"},
+		{Path: "outputs.tf", Content: "# new\n"},
+	}}
+	applied, err := applyFiles(context.Background(), output, dir, false, nil)
+	if err != nil {
+		t.Fatalf("applyFiles() error = %v", err)
+	}
+	backupDir := applied.BackupDir
+	if !strings.HasPrefix(backupDir, ".tfai/backups/") {
+		t.Fatalf("expected a backup under .tfai/backups, got %q", backupDir)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, backupDir, "main.tf")); err != nil || string(b) != "# before\n" {
+		t.Errorf("expected the replaced main.tf backed up, got %q (%v)", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, backupDir, "outputs.tf")); !os.IsNotExist(err) {
+		t.Errorf("expected no backup of a new file, got %v", err)
+	}
+
+	// Writing only new files takes no backup.
+	output = &TerraformAgentOutput{Files: []GeneratedFile{{Path: "versions.tf", Content: "# new\n"}}}
+	if applied, err := applyFiles(context.Background(), output, dir, false, nil); err != nil || applied.BackupDir != "" {
+		t.Errorf("expected no backup, got %+v (%v)", applied, err)
+	}
+}
+
+func TestApplyFilesReportsBackupOnFailure(t *testing.T) {
+	t.Parallel()
+
+	// A dangling symlink where a generated file goes fails its write after
+	// main.tf was backed up and rewritten.
+	dir := testutil.NewWorkspace(t).WithFile("main.tf", "# before\n").Dir()
+	if err := os.Symlink(filepath.Join("missing", "blocked.tf"), filepath.Join(dir, "blocked.tf")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	output := &TerraformAgentOutput{Files: []GeneratedFile{
+		{Path: "main.tf", Content: "# after\n"},
+		{Path: "blocked.tf", Content: "# new\n"},
+	}}
+	applied, err := applyFiles(context.Background(), output, dir, false, nil)
+	if err == nil {
+		t.Fatal("expected writing through a dangling symlink to fail")
+	}
+	if applied == nil || !strings.HasPrefix(applied.BackupDir, ".tfai/backups/") {
+		t.Fatalf("expected the backup reported with the error, got %+v (%v)", applied, err)
+	}
+	if !strings.Contains(err.Error(), applied.BackupDir) {
+		t.Errorf("expected the error to name the backup %s, got %v", applied.BackupDir, err)
+	}
+	if strings.Join(applied.Files, ",") != "main.tf" {
+		t.Errorf("expected main.tf reported as written, got %v", applied.Files)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, applied.BackupDir, "main.tf")); err != nil || string(b) != "# before\n" {
+		t.Errorf("expected the replaced main.tf backed up, got %q (%v)", b, err)
+	}
+}
+
+func TestRunReportsBackup(t *testing.T) {
+	t.Parallel()
+
+	m := &scriptedModel{script: func(int, []*schema.Message) *schema.Message {
+		return schema.AssistantMessage(`{"files":[{"path":"main.tf","content":"# after"}],"summary":"Updated main.tf."}`, nil)
+	}}
+	a, err := New(context.Background(), &Config{ChatModel: m, MetricsRegistry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	dir := testutil.NewWorkspace(t).WithFile("main.tf", "# before\n").Dir()
+	var out strings.Builder
+	res, err := a.Run(context.Background(), QueryRequest{Message: "edit", WorkspaceDir: dir, Output: &out})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.BackupDir == "" {
+		t.Fatal("expected the result to report the backup")
+	}
+	if !strings.HasPrefix(out.String(), "Updated main.tf.") || !strings.Contains(out.String(), res.BackupDir) {
+		t.Errorf("expected the summary to name the backup %s, got %q", res.BackupDir, out.String())
+	}
+}
@@ -0,0 +1,51 @@
+package agent
+
+import "regexp"
+
+// TerraformUnavailableNotice is sent once per workspace conversation, through
+// EventSink.OnNotice and QueryResult.Notices, when a query needs the
+// terraform binary and Config.TerraformUnavailable is set.
+const TerraformUnavailableNotice = "terraform is not installed where TF-AI is running, so it cannot run " +
+	"plan, state, or validate for you. It will give you the exact commands to run instead."
+
+// capabilityNote returns the system prompt addendum that stops the model
+// from claiming to run terraform when the binary is unavailable. Without it
+// the model promises to "run terraform plan" and invents the output.
+func capabilityNote(reason error) string {
+	return "## Environment Limitations\n\n" +
+		"The terraform binary is not available in this environment (" + reason.Error() + "), " +
+		"so the terraform_init, terraform_plan, terraform_state, terraform_validate, terraform_fmt, and terraform_providers_lock " +
+		"tools are not registered. " +
+		"Do not claim to run plan, apply, state, or validate and never invent their output. " +
+		"Instead, give the user the exact commands to run and ask them to share the output."
+}
+
+// terraformRequestPattern matches requests that clearly need the terraform
+// binary: a terraform subcommand, asking to run a plan, asking about the
+// current plan, state, or drift, or asking why a plan or apply misbehaves.
+// Plain uses of "plan" ("plan a VPC layout") deliberately do not match.
+var terraformRequestPattern = regexp.MustCompile(`(?i)` +
+	`\bterraform\s+(plan|apply|state|validate|import|refresh)\b` +
+	`|\b(run|running|execute)\s+(a\s+|the\s+)?(plan|apply|validate)\b` +
+	`|\b(my|the|current)\s+(plan|state)\s+(output|file)\b` +
+	`|\bwhat('s|\s+is)\s+in\s+(my\s+|the\s+)?state\b` +
+	`|\bstate\s+(list|show|pull)\b` +
+	`|\bdrift(ed|ing)?\b` +
+	`|\bwhy\s+(does|did|is)\s+(my\s+|the\s+)?(plan|apply)\s+(fail|show|want|replac|destroy)`)
+
+// needsTerraform reports whether message clearly asks for something only
+// the terraform tools can do.
+func needsTerraform(message string) bool {
+	return terraformRequestPattern.MatchString(message)
+}
+
+// terraformNotice reports whether the query for workspaceDir should carry
+// TerraformUnavailableNotice: terraform is unavailable, the message needs
+// it, and the conversation has not been told yet.
+func (a *TerraformAgent) terraformNotice(workspaceDir, message string) bool {
+	if a.terraformUnavailable == nil || !needsTerraform(message) {
+		return false
+	}
+	_, told := a.noticed.LoadOrStore(workspaceDir, struct{}{})
+	return !told
+}
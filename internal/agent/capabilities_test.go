@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errNoTerraform is the NewExecRunner error used by these tests.
+var errNoTerraform = errors.New("tools: terraform binary not found on PATH")
+
+// newCapabilityTestAgent returns an agent whose model answers "ok" and
+// records the system prompt of the last call.
+func newCapabilityTestAgent(t *testing.T, unavailable error) (*TerraformAgent, func() string) {
+	t.Helper()
+	var (
+		mu     sync.Mutex
+		prompt string
+	)
+	m := &scriptedModel{script: func(_ int, in []*schema.Message) *schema.Message {
+		mu.Lock()
+		defer mu.Unlock()
+		prompt = in[0].Content
+		return schema.AssistantMessage("ok", nil)
+	}}
+	a, err := New(context.Background(), &Config{
+		ChatModel:            m,
+		TerraformUnavailable: unavailable,
+		MetricsRegistry:      prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return a, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return prompt
+	}
+}
+
+// ---------------------------------------------------------------------------
+// System prompt
+// ---------------------------------------------------------------------------
+
+func TestRunCapabilityNote(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		unavailable error
+		wantNote    bool
+	}{
+		{name: "terraform available", unavailable: nil, wantNote: false},
+		{name: "terraform unavailable", unavailable: errNoTerraform, wantNote: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, prompt := newCapabilityTestAgent(t, tc.unavailable)
+			if _, err := a.Run(context.Background(), QueryRequest{Message: "hi"}); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			got := prompt()
+			if !strings.HasPrefix(got, systemPrompt) {
+				t.Error("expected the base system prompt first")
+			}
+			for _, want := range []string{
+				"## Environment Limitations",
+				errNoTerraform.Error(),
+				"Do not claim to run plan, apply, state, or validate",
+				"share the output",
+			} {
+				if strings.Contains(got, want) != tc.wantNote {
+					t.Errorf("expected contains(%q)=%v in system prompt", want, tc.wantNote)
+				}
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Unavailable-tool notice
+// ---------------------------------------------------------------------------
+
+func TestRunTerraformNotice(t *testing.T) {
+	t.Parallel()
+
+	a, _ := newCapabilityTestAgent(t, errNoTerraform)
+	run := func(message, dir string) ([]string, []string) {
+		t.Helper()
+		sink := &recordingSink{}
+		res, err := a.Run(context.Background(), QueryRequest{Message: message, WorkspaceDir: dir, Events: sink})
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		return res.Notices, sink.notices
+	}
+
+	steps := []struct {
+		message, dir string
+		want         bool
+	}{
+		{"how do I write an S3 bucket module?", "/ws/a", false},
+		{"run terraform plan and tell me what changes", "/ws/a", true},
+		{"and what is in my state?", "/ws/a", false}, // already told in this conversation
+		{"why does my plan fail?", "/ws/b", true},
+		{"what is in state?", "", true},
+	}
+	for i, step := range steps {
+		notices, events := run(step.message, step.dir)
+		if got := len(notices) == 1 && notices[0] == TerraformUnavailableNotice; got != step.want {
+			t.Errorf("step %d %q: expected notice=%v, got result notices %q", i, step.message, step.want, notices)
+		}
+		if len(events) != len(notices) {
+			t.Errorf("step %d: expected the sink to get the same notices, got %q", i, events)
+		}
+	}
+
+	// With terraform available there is never a notice.
+	b, _ := newCapabilityTestAgent(t, nil)
+	res, err := b.Run(context.Background(), QueryRequest{Message: "run terraform plan", WorkspaceDir: "/ws/a"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(res.Notices) != 0 {
+		t.Errorf("expected no notice with terraform available, got %q", res.Notices)
+	}
+}
+
+func TestNeedsTerraform(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"run terraform plan in ./infra", true},
+		{"Terraform apply keeps failing", true},
+		{"can you run a plan for me?", true},
+		{"please validate, then run the plan", true},
+		{"paste my plan output here", true},
+		{"what's in my state?", true},
+		{"terraform state list shows nothing", true},
+		{"do a state show for aws_vpc.main", true},
+		{"has this workspace drifted?", true},
+		{"why does my plan want to replace the cluster?", true},
+		{"why did apply destroy my bucket?", true},
+		{"help me plan a multi-account VPC layout", false},
+		{"what is the best way to manage remote state backends?", false},
+		{"generate an EKS module", false},
+		{"explain for_each vs count", false},
+	}
+	for _, tc := range tests {
+		if got := needsTerraform(tc.message); got != tc.want {
+			t.Errorf("needsTerraform(%q) = %v, want %v", tc.message, got, tc.want)
+		}
+	}
+}
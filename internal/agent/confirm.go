@@ -0,0 +1,121 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/tools"
+)
+
+// DefaultToolConfirmTimeout is how long a tool call waits for
+// QueryOptions.ConfirmTool when QueryOptions.ConfirmTimeout is zero. A call
+// still waiting is denied.
+const DefaultToolConfirmTimeout = 5 * time.Minute
+
+// ToolCall is a tool invocation waiting for the user's approval.
+type ToolCall struct {
+	// Name is the tool name, e.g. "terraform_plan".
+	Name string
+	// CallID is the model's identifier for this call.
+	CallID string
+	// Arguments is the raw JSON arguments of the call.
+	Arguments string
+}
+
+// ToolConfirmer decides whether a tool call that requires confirmation may
+// run. It may block, e.g. on a terminal prompt; ctx is cancelled when the
+// confirmation times out or the query ends. It may be called from several
+// goroutines at once.
+type ToolConfirmer func(ctx context.Context, call ToolCall) bool
+
+// toolDeniedResult is the tool result returned to the model for a call the
+// user did not approve.
+func toolDeniedResult(name string) string {
+	return fmt.Sprintf("the user declined to run %s. Do not call it again for this request; "+
+		"answer with the information you already have, and say what running it would have shown.", name)
+}
+
+// confirmTools returns the names of the tools in ts that require
+// confirmation (see tools.Confirmable).
+func confirmTools(ctx context.Context, ts []tool.BaseTool) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, t := range ts {
+		c, ok := t.(tools.Confirmable)
+		if !ok || !c.RequiresConfirmation() {
+			continue
+		}
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("agent: failed to read tool info: %w", err)
+		}
+		names[info.Name] = true
+	}
+	return names, nil
+}
+
+// toolConfirm is the per-query confirmation hook stored in the context.
+type toolConfirm struct {
+	// confirm decides each call.
+	confirm ToolConfirmer
+	// timeout bounds how long a call waits for confirm.
+	timeout time.Duration
+}
+
+// toolConfirmKey is the context key under which the per-query toolConfirm
+// lives.
+type toolConfirmKey struct{}
+
+// withToolConfirm returns a context carrying confirm. A nil confirm leaves
+// ctx unchanged, so every call runs without asking.
+func withToolConfirm(ctx context.Context, confirm ToolConfirmer, timeout time.Duration) context.Context {
+	if confirm == nil {
+		return ctx
+	}
+	if timeout <= 0 {
+		timeout = DefaultToolConfirmTimeout
+	}
+	return context.WithValue(ctx, toolConfirmKey{}, &toolConfirm{confirm: confirm, timeout: timeout})
+}
+
+// approve asks c.confirm about call and waits for the answer, denying the
+// call when the timeout passes or ctx ends first.
+func (c *toolConfirm) approve(ctx context.Context, call ToolCall) bool {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	answer := make(chan bool, 1)
+	go func() { answer <- c.confirm(ctx, call) }()
+	select {
+	case ok := <-answer:
+		return ok
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// toolConfirmMiddleware asks the query's ToolConfirmer before running a tool
+// that requires confirmation. A denied call is not run; the model receives
+// toolDeniedResult instead. It sits inside the tool guard, so denied calls
+// still count towards the iteration cap.
+func (a *TerraformAgent) toolConfirmMiddleware() compose.ToolMiddleware {
+	return compose.ToolMiddleware{
+		Invokable: func(next compose.InvokableToolEndpoint) compose.InvokableToolEndpoint {
+			return func(ctx context.Context, in *compose.ToolInput) (*compose.ToolOutput, error) {
+				c, _ := ctx.Value(toolConfirmKey{}).(*toolConfirm)
+				if c == nil || !a.confirmTools[in.Name] {
+					return next(ctx, in)
+				}
+				if !c.approve(ctx, ToolCall{Name: in.Name, CallID: in.CallID, Arguments: in.Arguments}) {
+					logging.FromContext(ctx).Info("agent: tool call denied", slog.String("tool", in.Name))
+					return &compose.ToolOutput{Result: toolDeniedResult(in.Name)}, nil
+				}
+				return next(ctx, in)
+			}
+		},
+	}
+}
@@ -0,0 +1,109 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// confirmableTool is a countingTool that requires confirmation.
+type confirmableTool struct {
+	countingTool
+}
+
+func (t *confirmableTool) RequiresConfirmation() bool { return true }
+
+// echoToolResult calls fake_state once, then answers with the tool result
+// it received, so tests can assert what the model was told.
+func echoToolResult(turn int, input []*schema.Message) *schema.Message {
+	if turn == 0 {
+		return toolCall(turn, `{"dir":"/ws","subcommand":"list"}`)
+	}
+	return schema.AssistantMessage(input[len(input)-1].Content, nil)
+}
+
+func TestToolConfirm(t *testing.T) {
+	t.Parallel()
+
+	approve := func(context.Context, ToolCall) bool { return true }
+	deny := func(context.Context, ToolCall) bool { return false }
+	hang := func(ctx context.Context, _ ToolCall) bool {
+		<-ctx.Done()
+		return true
+	}
+	tests := []struct {
+		name        string
+		confirmable bool
+		confirm     ToolConfirmer
+		wantAsked   bool
+		wantRuns    int32
+		wantResult  string
+	}{
+		{name: "approved", confirmable: true, confirm: approve, wantAsked: true, wantRuns: 1, wantResult: "aws_s3_bucket.logs"},
+		{name: "denied", confirmable: true, confirm: deny, wantAsked: true, wantRuns: 0, wantResult: toolDeniedResult("fake_state")},
+		{name: "timed out", confirmable: true, confirm: hang, wantAsked: true, wantRuns: 0, wantResult: toolDeniedResult("fake_state")},
+		{name: "no confirmer", confirmable: true, wantRuns: 1, wantResult: "aws_s3_bucket.logs"},
+		{name: "tool without confirmation", confirm: deny, wantRuns: 1, wantResult: "aws_s3_bucket.logs"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ct := &confirmableTool{}
+			var ft tool.BaseTool = &ct.countingTool
+			if tc.confirmable {
+				ft = ct
+			}
+			a, err := New(context.Background(), &Config{
+				ChatModel:       &scriptedModel{script: echoToolResult},
+				Tools:           []tool.BaseTool{ft},
+				MetricsRegistry: prometheus.NewRegistry(),
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			asked := make(chan ToolCall, 1)
+			var confirm ToolConfirmer
+			if tc.confirm != nil {
+				confirm = func(ctx context.Context, call ToolCall) bool {
+					asked <- call
+					return tc.confirm(ctx, call)
+				}
+			}
+			var out strings.Builder
+			_, err = a.Run(context.Background(), QueryRequest{
+				Message: "what is in state?",
+				Output:  &out,
+				Options: QueryOptions{ConfirmTool: confirm, ConfirmTimeout: 20 * time.Millisecond},
+			})
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			select {
+			case got := <-asked:
+				if !tc.wantAsked {
+					t.Errorf("expected no confirmation, got %+v", got)
+				} else if got.Name != "fake_state" || got.Arguments != `{"dir":"/ws","subcommand":"list"}` || got.CallID != "call-0" {
+					t.Errorf("expected the call's name, ID, and arguments, got %+v", got)
+				}
+			default:
+				if tc.wantAsked {
+					t.Error("expected a confirmation")
+				}
+			}
+			if runs := ct.calls.Load(); runs != tc.wantRuns {
+				t.Errorf("expected the tool to run %d times, ran %d", tc.wantRuns, runs)
+			}
+			if out.String() != tc.wantResult {
+				t.Errorf("expected the model to receive %q, got %q", tc.wantResult, out.String())
+			}
+		})
+	}
+}
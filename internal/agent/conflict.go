@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/filediff"
+)
+
+// ConflictResolution is how a generated file that would overwrite the
+// user's changes is written.
+type ConflictResolution string
+
+// Conflict resolutions.
+const (
+	// KeepMine leaves the user's file as it is; the generated content is
+	// discarded.
+	KeepMine ConflictResolution = "keep-mine"
+	// TakeGenerated overwrites the user's changes with the generated
+	// content. The user's file is backed up first, like any replaced file.
+	TakeGenerated ConflictResolution = "take-generated"
+	// MergeMarkers writes both versions, each region where they differ
+	// delimited by git-style conflict markers, for an editor to resolve.
+	MergeMarkers ConflictResolution = "merge-markers"
+)
+
+// Conflict marker labels written by MergeMarkers.
+const (
+	mineLabel      = "mine"
+	generatedLabel = "generated"
+)
+
+// FileConflict is a generated file whose workspace copy the user changed
+// since tfai last wrote it (see tfaidir.UserModified).
+type FileConflict struct {
+	// Path is the slash-separated path relative to the workspace.
+	Path string
+	// Mine is the file's current content.
+	Mine string
+	// Generated is the content tfai would write, formatted as it would be.
+	Generated string
+}
+
+// Diff returns the unified diff from Mine to Generated.
+func (c FileConflict) Diff() string {
+	return filediff.Unified(c.Path, c.Mine, c.Generated)
+}
+
+// ConflictResolver decides how to write a generated file that would
+// overwrite the user's changes. It is called once per such file, in
+// envelope order, before anything is written, and may block, e.g. on a
+// terminal prompt. An unknown resolution keeps the user's file.
+type ConflictResolver func(ctx context.Context, c FileConflict) ConflictResolution
+
+// ResolvedConflict records how one FileConflict was resolved.
+type ResolvedConflict struct {
+	// Path is the slash-separated path relative to the workspace.
+	Path string
+	// Resolution is what was done with the file.
+	Resolution ConflictResolution
+}
+
+// resolveConflict returns the content to write for c as resolution decides,
+// and false when the file must be left alone.
+func resolveConflict(c FileConflict, resolution ConflictResolution) (string, bool) {
+	switch resolution {
+	case TakeGenerated:
+		return c.Generated, true
+	case MergeMarkers:
+		return filediff.ConflictMarkers(c.Mine, c.Generated, mineLabel, generatedLabel), true
+	default:
+		return "", false
+	}
+}
+
+// conflictNote returns the paragraph appended to a file summary that says
+// how the files the user had changed were resolved; "" when none were.
+func conflictNote(conflicts []ResolvedConflict) string {
+	if len(conflicts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nYou had changed these files since tfai last wrote them:")
+	for _, c := range conflicts {
+		fmt.Fprintf(&b, "\n- %s: %s", c.Path, resolutionText(c.Resolution))
+	}
+	return b.String()
+}
+
+// resolutionText describes a resolution in a file summary.
+func resolutionText(r ConflictResolution) string {
+	switch r {
+	case TakeGenerated:
+		return "replaced with the generated version"
+	case MergeMarkers:
+		return "both versions written with conflict markers to resolve"
+	default:
+		return "kept your version"
+	}
+}
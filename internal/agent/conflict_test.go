@@ -0,0 +1,199 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/testutil"
+)
+
+// ---------------------------------------------------------------------------
+// Resolving generated files the user changed
+// ---------------------------------------------------------------------------
+
+// envelopeOf returns a file envelope answer writing files, path to content.
+func envelopeOf(t *testing.T, files map[string]string) string {
+	t.Helper()
+	out := TerraformAgentOutput{Summary: "Updated the module."}
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		out.Files = append(out.Files, GeneratedFile{Path: path, Content: files[path]})
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRunResolvesConflicts(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	answer := ""
+	m := &scriptedModel{script: func(int, []*schema.Message) *schema.Message {
+		mu.Lock()
+		defer mu.Unlock()
+		return schema.AssistantMessage(answer, nil)
+	}}
+	log := &activityLog{}
+	a, err := New(context.Background(), &Config{ChatModel: m, Activity: log, MetricsRegistry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	dir := testutil.NewWorkspace(t).Dir()
+	read := func(rel string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	write := func(rel, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, rel), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// choices is the resolution the user picks per file; asked records the
+	// files the resolver was asked about, in order.
+	choices := map[string]ConflictResolution{
+		"keep.tf":  KeepMine,
+		"take.tf":  TakeGenerated,
+		"merge.tf": MergeMarkers,
+		"other.tf": "show-diff",
+	}
+	var asked []string
+	resolve := func(_ context.Context, c FileConflict) ConflictResolution {
+		asked = append(asked, c.Path)
+		if c.Path == "merge.tf" && c.Diff() == "" {
+			t.Error("expected the conflict to carry a diff")
+		}
+		return choices[c.Path]
+	}
+	run := func(files map[string]string) (*QueryResult, string) {
+		t.Helper()
+		mu.Lock()
+		answer = envelopeOf(t, files)
+		mu.Unlock()
+		asked = nil
+		var out strings.Builder
+		res, err := a.Run(context.Background(), QueryRequest{
+			Message:      "generate",
+			WorkspaceDir: dir,
+			Output:       &out,
+			Options:      QueryOptions{ResolveConflict: resolve},
+		})
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		return res, out.String()
+	}
+
+	names := []string{"keep.tf", "take.tf", "merge.tf", "other.tf", "same.tf"}
+	first := make(map[string]string)
+	for _, n := range names {
+		first[n] = "# v1\nlocals {}\n"
+	}
+	if res, _ := run(first); len(asked) != 0 || len(res.Conflicts) != 0 || len(res.Files) != 5 {
+		t.Fatalf("expected the first generation to write every file unasked, got asked %v, %+v", asked, res)
+	}
+
+	// The user edits every file but same.tf.
+	for _, n := range names[:4] {
+		write(n, "# v1\nlocals {}\n# mine\n")
+	}
+	second := map[string]string{"new.tf": "# new\n"}
+	for _, n := range names {
+		second[n] = "# v2\nlocals {}\n"
+	}
+	res, out := run(second)
+
+	if !slices.Equal(asked, []string{"keep.tf", "merge.tf", "other.tf", "take.tf"}) {
+		t.Errorf("expected the user-modified files asked about in envelope order, got %v", asked)
+	}
+	for rel, want := range map[string]string{
+		"keep.tf":  "# v1\nlocals {}\n# mine\n",
+		"other.tf": "# v1\nlocals {}\n# mine\n",
+		"take.tf":  "# v2\nlocals {}\n",
+		"merge.tf": "<<<<<<< mine\n# v1\n=======\n# v2\n>>>>>>> generated\nlocals {}\n<<<<<<< mine\n# mine\n=======\n>>>>>>> generated\n",
+		"same.tf":  "# v2\nlocals {}\n",
+		"new.tf":   "# new\n",
+	} {
+		if got := read(rel); got != want {
+			t.Errorf("%s: expected %q, got %q", rel, want, got)
+		}
+	}
+	wantConflicts := []ResolvedConflict{
+		{Path: "keep.tf", Resolution: KeepMine},
+		{Path: "merge.tf", Resolution: MergeMarkers},
+		{Path: "other.tf", Resolution: KeepMine},
+		{Path: "take.tf", Resolution: TakeGenerated},
+	}
+	if !slices.Equal(res.Conflicts, wantConflicts) {
+		t.Errorf("expected conflicts %+v, got %+v", wantConflicts, res.Conflicts)
+	}
+	if !slices.Equal(res.Files, []string{"merge.tf", "new.tf", "same.tf", "take.tf"}) {
+		t.Errorf("expected the kept files left out of Files, got %v", res.Files)
+	}
+	for _, line := range []string{
+		"You had changed these files since tfai last wrote them:",
+		"- keep.tf: kept your version",
+		"- other.tf: kept your version",
+		"- take.tf: replaced with the generated version",
+		"- merge.tf: both versions written with conflict markers to resolve",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("expected the summary to report %q, got:\n%s", line, out)
+		}
+	}
+	if len(log.entries) != 2 || !maps.Equal(log.entries[1].Conflicts, map[string]string{
+		"keep.tf": "keep-mine", "merge.tf": "merge-markers", "other.tf": "keep-mine", "take.tf": "take-generated",
+	}) {
+		t.Errorf("expected the resolutions recorded as activity, got %+v", log.entries)
+	}
+
+	// Files tfai wrote this time, merged ones included, are no longer
+	// conflicts; the kept ones still are.
+	run(second)
+	if !slices.Equal(asked, []string{"keep.tf", "other.tf"}) {
+		t.Errorf("expected only the kept files asked about again, got %v", asked)
+	}
+}
+
+func TestRunOverwritesWithoutResolver(t *testing.T) {
+	t.Parallel()
+
+	m := &scriptedModel{script: func(turn int, _ []*schema.Message) *schema.Message {
+		return schema.AssistantMessage(envelopeOf(t, map[string]string{"main.tf": "# v" + string(rune('1'+turn)) + "\n"}), nil)
+	}}
+	a, err := New(context.Background(), &Config{ChatModel: m, MetricsRegistry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	dir := testutil.NewWorkspace(t).Dir()
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "generate", WorkspaceDir: dir}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte("# mine\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := a.Run(context.Background(), QueryRequest{Message: "generate", WorkspaceDir: dir})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "main.tf")); string(b) != "# v2\n" || len(res.Conflicts) != 0 || res.BackupDir == "" {
+		t.Errorf("expected the user's file backed up and overwritten, got %q, %+v", b, res)
+	}
+}
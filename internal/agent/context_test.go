@@ -0,0 +1,219 @@
+package agent
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/secretscan"
+	"github.com/54b3r/tfai-go/internal/testutil"
+)
+
+// ---------------------------------------------------------------------------
+// Workspace context layouts
+// ---------------------------------------------------------------------------
+
+// workspaceContextFor lists the files of dir within scope and builds the
+// workspace context for message, as a query does.
+func workspaceContextFor(ctx context.Context, dir string, scope []string, message string, limits WorkspaceLimits) (string, error) {
+	limits = limits.WithDefaults()
+	files, err := listWorkspaceFiles(dir, scope, limits)
+	if err != nil {
+		return "", err
+	}
+	return buildWorkspaceContext(ctx, dir, files, message, limits, secretscan.Default()), nil
+}
+
+func TestBuildWorkspaceContextLayouts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		workspace func(t *testing.T) *testutil.Workspace
+		want      []string
+		wantNot   []string
+	}{
+		{
+			name:      "deep module tree",
+			workspace: func(t *testing.T) *testutil.Workspace { return testutil.Fixture(t, testutil.FixtureModules) },
+			want:      []string{"### main.tf\n", "### modules/network/vpc/subnets/main.tf\n", "### modules/platform/eks/nodegroups/spot/main.tf\n"},
+		},
+		{
+			name:      "terragrunt units",
+			workspace: func(t *testing.T) *testutil.Workspace { return testutil.Fixture(t, testutil.FixtureTerragrunt) },
+			want:      []string{"### modules/vpc/main.tf\n"},
+			wantNot:   []string{"terragrunt.hcl", "find_in_parent_folders"},
+		},
+		{
+			name:      "broken HCL is passed through",
+			workspace: func(t *testing.T) *testutil.Workspace { return testutil.Fixture(t, testutil.FixtureBroken) },
+			want:      []string{"### main.tf\n", "### network.tf\n"},
+		},
+		{
+			name: "symlinked file",
+			workspace: func(t *testing.T) *testutil.Workspace {
+				return testutil.NewWorkspace(t).
+					WithModule("modules/vpc").
+					WithSymlink("shared.tf", "modules/vpc/variables.tf").
+					WithSymlink("dangling.tf", "missing.tf")
+			},
+			want:    []string{"### shared.tf\n```hcl\nvariable \"name\""},
+			wantNot: []string{"dangling.tf"},
+		},
+		{
+			name: "large files are skipped",
+			workspace: func(t *testing.T) *testutil.Workspace {
+				return testutil.NewWorkspace(t).
+					WithFile("main.tf", "# small\n").
+					WithLargeFile("generated/huge.tf", DefaultMaxWorkspaceFileBytes+1).
+					WithSymlink("alias.tf", "generated/huge.tf")
+			},
+			want:    []string{"### main.tf\n"},
+			wantNot: []string{"huge.tf", "alias.tf", "generated padding"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := workspaceContextFor(context.Background(), tc.workspace(t).Dir(), nil, "", WorkspaceLimits{})
+			if err != nil {
+				t.Fatalf("buildWorkspaceContext: %v", err)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected %q in context:\n%s", want, got)
+				}
+			}
+			for _, bad := range tc.wantNot {
+				if strings.Contains(got, bad) {
+					t.Errorf("expected no %q in context:\n%s", bad, got)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildWorkspaceContextTotalCap(t *testing.T) {
+	t.Parallel()
+
+	// Eleven files just under the per-file cap exceed the 1 MiB total; the
+	// walk stops at the file that would cross it.
+	ws := testutil.NewWorkspace(t)
+	for i := 0; i < 11; i++ {
+		ws.WithLargeFile(string(rune('a'+i))+".tf", DefaultMaxWorkspaceFileBytes)
+	}
+	got, err := workspaceContextFor(context.Background(), ws.Dir(), nil, "", WorkspaceLimits{})
+	if err != nil {
+		t.Fatalf("buildWorkspaceContext: %v", err)
+	}
+	if n := strings.Count(got, "\n### "); n != 10 {
+		t.Errorf("expected 10 files within the total cap, got %d", n)
+	}
+	if len(got) < 10*DefaultMaxWorkspaceFileBytes {
+		t.Errorf("expected the included files in full, got %d bytes", len(got))
+	}
+}
+
+// reFileHeading matches the heading of a file in the workspace context.
+var reFileHeading = regexp.MustCompile(`(?m)^### (.+)$`)
+
+func TestBuildWorkspaceContextRelevance(t *testing.T) {
+	t.Parallel()
+
+	ws := testutil.NewWorkspace(t).
+		WithFile("main.tf", "module \"network\" {\n  source = \"./modules/network\"\n}\n").
+		WithFile("variables.tf", "variable \"region\" {}\n").
+		WithFile("modules/iam/roles.tf", "resource \"aws_iam_role\" \"ci\" {}\n").
+		WithFile("modules/network/vpc.tf", "resource \"aws_vpc\" \"main\" {}\n").
+		WithFile("modules/storage/main.tf", "data \"aws_caller_identity\" \"current\" {}\n\nresource \"aws_s3_bucket\" \"logs\" {}\n")
+
+	tests := []struct {
+		name        string
+		message     string
+		limits      WorkspaceLimits
+		wantFiles   []string
+		wantOmitted string
+	}{
+		{
+			name:      "root files first",
+			message:   "Add tags to everything",
+			wantFiles: []string{"main.tf", "variables.tf", "modules/iam/roles.tf", "modules/network/vpc.tf", "modules/storage/main.tf"},
+		},
+		{
+			name:        "overflow is omitted",
+			message:     "Add tags to everything",
+			limits:      WorkspaceLimits{MaxFiles: 2},
+			wantFiles:   []string{"main.tf", "variables.tf"},
+			wantOmitted: "3 files omitted",
+		},
+		{
+			name:        "resource type mentioned",
+			message:     "Enable versioning on the aws_s3_bucket",
+			limits:      WorkspaceLimits{MaxFiles: 2},
+			wantFiles:   []string{"modules/storage/main.tf", "main.tf"},
+			wantOmitted: "3 files omitted",
+		},
+		{
+			name:        "data source type mentioned",
+			message:     "Why is aws_caller_identity empty?",
+			limits:      WorkspaceLimits{MaxFiles: 1},
+			wantFiles:   []string{"modules/storage/main.tf"},
+			wantOmitted: "4 files omitted",
+		},
+		{
+			name:        "resource address mentioned",
+			message:     "Rename aws_vpc.main.",
+			limits:      WorkspaceLimits{MaxFiles: 1},
+			wantFiles:   []string{"modules/network/vpc.tf"},
+			wantOmitted: "4 files omitted",
+		},
+		{
+			name:        "file names mentioned",
+			message:     "Move the role in roles.tf next to modules/storage/main.tf.",
+			limits:      WorkspaceLimits{MaxFiles: 3},
+			wantFiles:   []string{"modules/iam/roles.tf", "modules/storage/main.tf", "main.tf"},
+			wantOmitted: "2 files omitted",
+		},
+		{
+			name:        "total cap skips the files that do not fit",
+			message:     "aws_s3_bucket",
+			limits:      WorkspaceLimits{MaxTotalBytes: 110},
+			wantFiles:   []string{"modules/storage/main.tf", "variables.tf"},
+			wantOmitted: "3 files omitted",
+		},
+		{
+			name:        "one file omitted",
+			message:     "",
+			limits:      WorkspaceLimits{MaxFiles: 4},
+			wantFiles:   []string{"main.tf", "variables.tf", "modules/iam/roles.tf", "modules/network/vpc.tf"},
+			wantOmitted: "1 file omitted",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := workspaceContextFor(context.Background(), ws.Dir(), nil, tc.message, tc.limits)
+			if err != nil {
+				t.Fatalf("buildWorkspaceContext: %v", err)
+			}
+			var files []string
+			for _, m := range reFileHeading.FindAllStringSubmatch(got, -1) {
+				files = append(files, m[1])
+			}
+			if !reflect.DeepEqual(files, tc.wantFiles) {
+				t.Errorf("expected files %v, got %v", tc.wantFiles, files)
+			}
+			if tc.wantOmitted == "" {
+				if strings.Contains(got, "omitted") {
+					t.Errorf("expected no omission note:\n%s", got)
+				}
+			} else if !strings.Contains(got, tc.wantOmitted+" to stay within the workspace context limits") {
+				t.Errorf("expected %q in context:\n%s", tc.wantOmitted, got)
+			}
+		})
+	}
+}
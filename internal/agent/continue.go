@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// MaxContinuations is how many times one answer cut off at the output token
+// limit can be continued through QueryOptions.Continue.
+const MaxContinuations = 2
+
+// continueInstruction is the user message that asks the model to carry on
+// with its cut-off answer.
+const continueInstruction = `Your previous answer was cut off at the output token limit. Continue it exactly where it stopped.
+Output only the rest of the answer: do not repeat anything already written and do not add a preamble or commentary.
+If the answer is a JSON file envelope, continue the JSON from the exact character where it stopped, so that the two parts joined form the complete document.`
+
+// continuationRows is how many of the session's latest history rows are
+// searched for the cut-off answer: every part of it and the event notes
+// between them.
+const continuationRows = 200
+
+// errNothingToContinue is returned by findContinuation when the session's
+// last answer was not cut off.
+var errNothingToContinue = errors.New("agent: nothing to continue: the last answer was not cut off")
+
+// continuation is a cut-off answer that a Continue query carries on.
+type continuation struct {
+	// question is the user message the answer responds to. Empty when it
+	// is no longer in the searched history.
+	question string
+	// partial is the answer so far: every stored part, stitched.
+	partial string
+	// count is the number of continuations the answer has had.
+	count int
+}
+
+// isTruncated reports whether a model finish reason means the output token
+// limit stopped the answer: "length" for OpenAI-compatible APIs and Ollama,
+// "max_tokens" for Anthropic models, and "MAX_TOKENS" for Gemini.
+func isTruncated(finishReason string) bool {
+	switch strings.ToLower(finishReason) {
+	case "length", "max_tokens":
+		return true
+	default:
+		return false
+	}
+}
+
+// loadContinuation returns the session's cut-off answer for a Continue
+// query.
+func (a *TerraformAgent) loadContinuation(ctx context.Context, req QueryRequest) (*continuation, error) {
+	if a.history == nil || req.Options.NoHistory {
+		return nil, errors.New("agent: nothing to continue: conversation history is disabled")
+	}
+	rows, err := a.history.Recent(ctx, req.WorkspaceDir, req.SessionID, continuationRows)
+	if err != nil {
+		return nil, fmt.Errorf("agent: continue: failed to load history: %w", err)
+	}
+	return findContinuation(rows)
+}
+
+// findContinuation finds the cut-off answer at the end of rows, oldest
+// first as Recent returns them. The answer's last part must be followed by
+// a KindTruncated note, whose count tells how many earlier parts, each
+// marked KindContinued, make up the answer.
+func findContinuation(rows []store.Message) (*continuation, error) {
+	i := len(rows) - 1
+	for i >= 0 && (rows[i].Kind == store.KindDisclosure || rows[i].Kind == store.KindContinued) {
+		i--
+	}
+	if i < 0 || rows[i].Kind != store.KindTruncated {
+		return nil, errNothingToContinue
+	}
+	count, err := strconv.Atoi(rows[i].Content)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("agent: continue: malformed truncation note %q", rows[i].Content)
+	}
+	if count >= MaxContinuations {
+		return nil, fmt.Errorf("agent: the answer has already been continued %d times, the most allowed", count)
+	}
+
+	// Collect the answer's parts, newest first, then the question.
+	var parts []string
+	c := &continuation{count: count}
+	for i--; i >= 0; i-- {
+		m := rows[i]
+		if m.Kind != "" && m.Kind != store.KindMessage {
+			continue
+		}
+		if m.Role == store.RoleUser {
+			c.question = m.Content
+			break
+		}
+		if len(parts) <= count {
+			parts = append(parts, m.Content)
+		}
+	}
+	if len(parts) != count+1 {
+		return nil, fmt.Errorf("agent: continue: found %d of the answer's %d parts in history", len(parts), count+1)
+	}
+	for l, r := 0, len(parts)-1; l < r; l, r = l+1, r-1 {
+		parts[l], parts[r] = parts[r], parts[l]
+	}
+	c.partial = strings.Join(parts, "")
+	return c, nil
+}
+
+// messages returns the model input of the continuation: the question, the
+// answer so far, and continueInstruction. Documentation and workspace
+// context are left out; the answer so far was written from them.
+func (c *continuation) messages(prompt string) []*schema.Message {
+	msgs := []*schema.Message{schema.SystemMessage(prompt)}
+	if c.question != "" {
+		msgs = append(msgs, schema.UserMessage(c.question))
+	}
+	return append(msgs,
+		schema.AssistantMessage(c.partial, nil),
+		schema.UserMessage(continueInstruction),
+	)
+}
@@ -0,0 +1,251 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// withFinish sets the finish reason reported with msg.
+func withFinish(msg *schema.Message, reason string) *schema.Message {
+	msg.ResponseMeta = &schema.ResponseMeta{FinishReason: reason}
+	return msg
+}
+
+// newContinueTestAgent returns an agent over an in-memory history store that
+// answers with m.
+func newContinueTestAgent(t *testing.T, m *scriptedModel) (*TerraformAgent, *store.SQLiteStore) {
+	t.Helper()
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+	a, err := New(context.Background(), &Config{
+		ChatModel:       m,
+		History:         hs,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return a, hs
+}
+
+// ---------------------------------------------------------------------------
+// Continuing a cut-off envelope
+// ---------------------------------------------------------------------------
+
+func TestRunContinueStitchesEnvelope(t *testing.T) {
+	t.Parallel()
+
+	envelope := `{"files":[{"path":"main.tf","content":"# main"}],"summary":"Created a VPC."}`
+	first, rest := envelope[:30], envelope[30:]
+	var input []*schema.Message
+	m := &scriptedModel{script: func(turn int, in []*schema.Message) *schema.Message {
+		input = in
+		if turn == 0 {
+			return withFinish(schema.AssistantMessage(first, nil), "length")
+		}
+		return withFinish(schema.AssistantMessage(rest, nil), "stop")
+	}}
+	a, hs := newContinueTestAgent(t, m)
+	dir := t.TempDir()
+
+	var out strings.Builder
+	res, err := a.Run(context.Background(), QueryRequest{Message: "create a vpc", WorkspaceDir: dir, Output: &out})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !res.Truncated || res.FilesWritten() {
+		t.Fatalf("expected a truncated answer with no files, got %+v", res)
+	}
+	if out.String() != first {
+		t.Errorf("expected the cut-off part streamed, got %q", out.String())
+	}
+
+	out.Reset()
+	res, err = a.Run(context.Background(), QueryRequest{WorkspaceDir: dir, Output: &out, Options: QueryOptions{Continue: true}})
+	if err != nil {
+		t.Fatalf("Run continue: %v", err)
+	}
+	if res.Truncated || strings.Join(res.Files, ",") != "main.tf" {
+		t.Fatalf("expected the stitched envelope applied, got %+v", res)
+	}
+	if out.String() != "Created a VPC." {
+		t.Errorf("expected the summary streamed, got %q", out.String())
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "main.tf")); err != nil || !strings.Contains(string(b), "# main") {
+		t.Errorf("expected main.tf written, got %q (%v)", b, err)
+	}
+
+	// The model sees the question, the cut-off part, and the instruction.
+	var roles []string
+	for _, msg := range input {
+		roles = append(roles, string(msg.Role))
+	}
+	if strings.Join(roles, ",") != "system,user,assistant,user" {
+		t.Fatalf("unexpected continuation input roles %v", roles)
+	}
+	if input[1].Content != "create a vpc" || input[2].Content != first || input[3].Content != continueInstruction {
+		t.Errorf("unexpected continuation input: %q, %q, %q", input[1].Content, input[2].Content, input[3].Content)
+	}
+
+	rows, err := hs.Recent(context.Background(), dir, "", 20)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	var got []string
+	for _, r := range rows {
+		got = append(got, string(r.Kind)+"="+r.Content)
+	}
+	want := []string{
+		"message=create a vpc", "message=" + first, "truncated=0",
+		"files_written=main.tf", "message=Created a VPC.", "continued=1",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("want rows %q, got %q", want, got)
+	}
+
+	// The answer is complete: there is nothing left to continue.
+	res, err = a.Run(context.Background(), QueryRequest{WorkspaceDir: dir, Output: &out, Options: QueryOptions{Continue: true}})
+	if !errors.Is(err, errNothingToContinue) || res.ErrorCode != CodeCannotContinue {
+		t.Errorf("expected %s, got %v (%q)", CodeCannotContinue, err, res.ErrorCode)
+	}
+}
+
+func TestRunContinueLimit(t *testing.T) {
+	t.Parallel()
+
+	m := &scriptedModel{script: func(_ int, _ []*schema.Message) *schema.Message {
+		return withFinish(schema.AssistantMessage("part ", nil), "max_tokens")
+	}}
+	a, _ := newContinueTestAgent(t, m)
+	const dir = "/ws/a"
+
+	var out strings.Builder
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "explain", WorkspaceDir: dir, Output: &out}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	for i := 1; i <= MaxContinuations; i++ {
+		out.Reset()
+		res, err := a.Run(context.Background(), QueryRequest{WorkspaceDir: dir, Output: &out, Options: QueryOptions{Continue: true}})
+		if err != nil {
+			t.Fatalf("continue %d: %v", i, err)
+		}
+		if !res.Truncated {
+			t.Errorf("continue %d: expected the answer still truncated", i)
+		}
+		if want := strings.Repeat("part ", i+1); out.String() != want {
+			t.Errorf("continue %d: want stitched answer %q, got %q", i, want, out.String())
+		}
+	}
+	res, err := a.Run(context.Background(), QueryRequest{WorkspaceDir: dir, Output: &out, Options: QueryOptions{Continue: true}})
+	if err == nil || res.ErrorCode != CodeCannotContinue {
+		t.Errorf("expected continue %d refused with %s, got %v (%q)", MaxContinuations+1, CodeCannotContinue, err, res.ErrorCode)
+	}
+}
+
+func TestRunContinueWithoutHistory(t *testing.T) {
+	t.Parallel()
+
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: []*schema.Message{schema.AssistantMessage("unused", nil)}},
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := a.Run(context.Background(), QueryRequest{Output: &strings.Builder{}, Options: QueryOptions{Continue: true}})
+	if err == nil || res.ErrorCode != CodeCannotContinue {
+		t.Errorf("expected %s without history, got %v (%q)", CodeCannotContinue, err, res.ErrorCode)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Finding the cut-off answer in history
+// ---------------------------------------------------------------------------
+
+func TestFindContinuation(t *testing.T) {
+	t.Parallel()
+
+	user := func(s string) store.Message { return store.Message{Role: store.RoleUser, Content: s} }
+	reply := func(s string) store.Message { return store.Message{Role: store.RoleAssistant, Content: s} }
+	note := func(k store.Kind, s string) store.Message {
+		return store.Message{Role: store.RoleAssistant, Kind: k, Content: s}
+	}
+
+	tests := []struct {
+		name         string
+		rows         []store.Message
+		wantQuestion string
+		wantPartial  string
+		wantCount    int
+		wantErr      bool
+	}{
+		{
+			name:         "first cut",
+			rows:         []store.Message{user("q"), reply("a"), note(store.KindTruncated, "0"), note(store.KindDisclosure, "AI")},
+			wantQuestion: "q", wantPartial: "a",
+		},
+		{
+			name: "continued once",
+			rows: []store.Message{
+				user("old"), reply("done"),
+				user("q"), note(store.KindToolRun, "plan"), reply("a"), note(store.KindTruncated, "0"),
+				reply("b"), note(store.KindContinued, "1"), note(store.KindTruncated, "1"),
+			},
+			wantQuestion: "q", wantPartial: "ab", wantCount: 1,
+		},
+		{
+			name:        "question trimmed",
+			rows:        []store.Message{reply("a"), note(store.KindTruncated, "0")},
+			wantPartial: "a",
+		},
+		{name: "not cut off", rows: []store.Message{user("q"), reply("a")}, wantErr: true},
+		{name: "empty", wantErr: true},
+		{name: "limit reached", rows: []store.Message{user("q"), reply("a"), reply("b"), reply("c"), note(store.KindTruncated, "2")}, wantErr: true},
+		{name: "parts missing", rows: []store.Message{user("q"), reply("b"), note(store.KindTruncated, "1")}, wantErr: true},
+		{name: "malformed", rows: []store.Message{user("q"), reply("a"), note(store.KindTruncated, "x")}, wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := findContinuation(tc.rows)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", c)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("findContinuation: %v", err)
+			}
+			if c.question != tc.wantQuestion || c.partial != tc.wantPartial || c.count != tc.wantCount {
+				t.Errorf("got %+v, want question %q, partial %q, count %d", c, tc.wantQuestion, tc.wantPartial, tc.wantCount)
+			}
+		})
+	}
+}
+
+func TestIsTruncated(t *testing.T) {
+	t.Parallel()
+
+	for reason, want := range map[string]bool{
+		"length": true, "max_tokens": true, "MAX_TOKENS": true,
+		"stop": false, "end_turn": false, "tool_calls": false, "": false,
+	} {
+		if got := isTruncated(reason); got != want {
+			t.Errorf("isTruncated(%q) = %v, want %v", reason, got, want)
+		}
+	}
+}
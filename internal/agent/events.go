@@ -0,0 +1,144 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cloudwego/eino/compose"
+)
+
+// Phase names a step of answering a query. Phases are reported to the
+// EventSink in the order they run so callers can show what a slow query is
+// doing before the first token arrives.
+type Phase string
+
+// Query phases, in the order Run reports them. Phases whose input is not
+// configured (no history store, no retriever, no workspace) are skipped.
+const (
+	// PhaseLoadingHistory is loading prior turns from the conversation store.
+	PhaseLoadingHistory Phase = "loading_history"
+	// PhaseRetrievingDocs is the RAG retrieval for the user's message.
+	PhaseRetrievingDocs Phase = "retrieving_docs"
+	// PhaseReadingWorkspace is reading the workspace's .tf files into context.
+	PhaseReadingWorkspace Phase = "reading_workspace"
+	// PhaseCallingModel is the start of the ReAct loop.
+	PhaseCallingModel Phase = "calling_model"
+)
+
+// EventSink receives events while a query runs. Methods are called
+// synchronously from the query and its tool calls, possibly from several
+// goroutines, so implementations must be safe for concurrent use and return
+// quickly. Embed NopEventSink to implement only the events you need.
+type EventSink interface {
+	// OnPhase reports that the query entered phase.
+	OnPhase(phase Phase)
+	// OnToolStart reports that the model invoked a tool. Elapsed and Error
+	// are unset.
+	OnToolStart(call ToolEvent)
+	// OnToolEnd reports that a tool call returned, including calls the tool
+	// guard refused. Every OnToolStart is followed by exactly one OnToolEnd
+	// with the same CallID.
+	OnToolEnd(call ToolEvent)
+	// OnSources reports the sources of the RAG documents injected as context.
+	OnSources(sources []string)
+	// OnUsage reports the query's token usage once the model is done. It is
+	// not called when the provider reported no usage.
+	OnUsage(usage Usage)
+	// OnNotice reports a message for the user about what the agent cannot
+	// do in this environment. It is called before the model is.
+	OnNotice(notice string)
+}
+
+// ToolEvent describes one tool invocation.
+type ToolEvent struct {
+	// Name is the tool name, e.g. "terraform_plan".
+	Name string
+	// CallID is the model's identifier for this call; it pairs the start and
+	// end events of concurrent calls.
+	CallID string
+	// Dir is the tool's "dir" argument, for the terraform tools that take
+	// one. Empty otherwise.
+	Dir string
+	// Elapsed is how long the call took. Set on OnToolEnd only.
+	Elapsed time.Duration
+	// Error is the call's error message. Set on OnToolEnd only, and empty
+	// when the call succeeded.
+	Error string
+}
+
+// NopEventSink ignores every event.
+type NopEventSink struct{}
+
+// OnPhase implements EventSink.
+func (NopEventSink) OnPhase(Phase) {}
+
+// OnToolStart implements EventSink.
+func (NopEventSink) OnToolStart(ToolEvent) {}
+
+// OnToolEnd implements EventSink.
+func (NopEventSink) OnToolEnd(ToolEvent) {}
+
+// OnSources implements EventSink.
+func (NopEventSink) OnSources([]string) {}
+
+// OnUsage implements EventSink.
+func (NopEventSink) OnUsage(Usage) {}
+
+// OnNotice implements EventSink.
+func (NopEventSink) OnNotice(string) {}
+
+// eventsKey is the context key under which a query's EventSink lives, so
+// that tool middleware can report tool calls.
+type eventsKey struct{}
+
+// withEvents returns a context carrying sink. A nil sink is stored as
+// NopEventSink so eventsFrom never returns nil.
+func withEvents(ctx context.Context, sink EventSink) context.Context {
+	if sink == nil {
+		sink = NopEventSink{}
+	}
+	return context.WithValue(ctx, eventsKey{}, sink)
+}
+
+// eventsFrom returns the EventSink stored in ctx, or NopEventSink.
+func eventsFrom(ctx context.Context) EventSink {
+	if sink, ok := ctx.Value(eventsKey{}).(EventSink); ok {
+		return sink
+	}
+	return NopEventSink{}
+}
+
+// toolEventsMiddleware reports every invokable tool call to the query's
+// EventSink as a start and an end event. It is the outermost tool
+// middleware, so calls refused by the tool guard are reported too.
+func toolEventsMiddleware() compose.ToolMiddleware {
+	return compose.ToolMiddleware{
+		Invokable: func(next compose.InvokableToolEndpoint) compose.InvokableToolEndpoint {
+			return func(ctx context.Context, in *compose.ToolInput) (*compose.ToolOutput, error) {
+				sink := eventsFrom(ctx)
+				ev := ToolEvent{Name: in.Name, CallID: in.CallID, Dir: toolDir(in.Arguments)}
+				sink.OnToolStart(ev)
+
+				start := time.Now()
+				out, err := next(ctx, in)
+				ev.Elapsed = time.Since(start)
+				if err != nil {
+					ev.Error = err.Error()
+				}
+				sink.OnToolEnd(ev)
+				return out, err
+			}
+		},
+	}
+}
+
+// toolDir extracts the "dir" argument from a tool call's JSON arguments, or
+// returns "" when there is none.
+func toolDir(arguments string) string {
+	var args struct {
+		Dir string `json:"dir"`
+	}
+	_ = json.Unmarshal([]byte(arguments), &args)
+	return args.Dir
+}
@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/hclinspect"
+	"github.com/54b3r/tfai-go/internal/logging"
+)
+
+// FocusScope selects the workspace files relevant to terraform plan or
+// apply output, for QueryRequest.Scope: the files defining the resources it
+// mentions (see hclinspect.ParseAddresses and hclinspect.LocateResources),
+// the files its diagnostics point at, and the root module's terraform and
+// provider settings. It returns nil, meaning every file, when the output
+// mentions nothing found in the workspace. Each selected file is logged with
+// the reason it was selected.
+func FocusScope(ctx context.Context, workspaceDir, output string) []string {
+	log := logging.FromContext(ctx)
+	var scope []string
+	reasons := make(map[string][]string)
+	add := func(file, reason string) {
+		if _, ok := reasons[file]; !ok {
+			scope = append(scope, file)
+		}
+		reasons[file] = append(reasons[file], reason)
+	}
+
+	addrs := hclinspect.ParseAddresses(output)
+	for _, loc := range hclinspect.LocateResources(workspaceDir, addrs) {
+		add(loc.File, "defines "+loc.Address.String())
+	}
+	for _, ref := range hclinspect.ParseFileRefs(output) {
+		if info, err := os.Stat(filepath.Join(workspaceDir, filepath.FromSlash(ref))); err == nil && !info.IsDir() {
+			add(ref, "referenced by a diagnostic")
+		}
+	}
+	if len(scope) == 0 {
+		log.Info("agent: focused context found no mentioned resources; using the whole workspace",
+			slog.Int("addresses", len(addrs)))
+		return nil
+	}
+	for _, file := range hclinspect.SettingsFiles(workspaceDir) {
+		add(file, "terraform and provider settings")
+	}
+	for _, file := range scope {
+		log.Info("agent: focused context file selected",
+			slog.String("file", file),
+			slog.String("reason", strings.Join(reasons[file], "; ")))
+	}
+	return scope
+}
@@ -0,0 +1,80 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/testutil"
+)
+
+// ---------------------------------------------------------------------------
+// Focused workspace context
+// ---------------------------------------------------------------------------
+
+func TestFocusScope(t *testing.T) {
+	t.Parallel()
+
+	const applyError = `module.vpc.module.subnets.aws_subnet.private[0]: Creating...
+╷
+│ Error: creating EC2 Subnet: InvalidSubnet.Conflict
+│
+│   with module.vpc.module.subnets.aws_subnet.private[0],
+│   on modules/network/vpc/subnets/main.tf line 6, in resource "aws_subnet" "private":
+│    6: resource "aws_subnet" "private" {
+╵
+`
+	ws := testutil.Fixture(t, testutil.FixtureModules).
+		WithFile("versions.tf", "terraform {\n  required_version = \">= 1.5\"\n}\n").
+		WithFile("unrelated.tf", "resource \"aws_iam_role\" \"ci\" {\n  name = \"ci\"\n}\n")
+	var logs strings.Builder
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewTextHandler(&logs, nil)))
+
+	scope := FocusScope(ctx, ws.Dir(), applyError)
+	if want := []string{"modules/network/vpc/subnets/main.tf", "versions.tf"}; !reflect.DeepEqual(scope, want) {
+		t.Fatalf("expected scope %v, got %v", want, scope)
+	}
+	if !strings.Contains(logs.String(), `reason="defines module.vpc.module.subnets.aws_subnet.private; referenced by a diagnostic"`) {
+		t.Errorf("expected each file to be logged with its reasons:\n%s", logs.String())
+	}
+
+	wsContext, err := workspaceContextFor(ctx, ws.Dir(), scope, "", WorkspaceLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"### modules/network/vpc/subnets/main.tf\n", "### versions.tf\n"} {
+		if !strings.Contains(wsContext, want) {
+			t.Errorf("expected %q in the focused context", want)
+		}
+	}
+	for _, unwanted := range []string{"### main.tf\n", "### unrelated.tf\n", "### modules/network/vpc/main.tf\n"} {
+		if strings.Contains(wsContext, unwanted) {
+			t.Errorf("expected %q to be left out of the focused context", unwanted)
+		}
+	}
+}
+
+func TestFocusScope_Fallback(t *testing.T) {
+	t.Parallel()
+
+	ws := testutil.Fixture(t, testutil.FixtureModules)
+	tests := []struct {
+		name   string
+		output string
+	}{
+		{name: "no addresses", output: "Error: Failed to query available provider packages"},
+		{name: "addresses not in the workspace", output: "with module.eks.aws_eks_cluster.this,\n  on modules/eks/main.tf line 1"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if scope := FocusScope(context.Background(), ws.Dir(), tc.output); scope != nil {
+				t.Errorf("expected no scope, got %v", scope)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// turnRecorder forwards every event to the caller's EventSink and notes the
+// tool calls of the turn so they can be persisted as history events. Only
+// the tool name and whether it succeeded are kept; tool output and error
+// text never reach the conversation store.
+type turnRecorder struct {
+	EventSink
+
+	mu      sync.Mutex
+	tools   []string
+	timings []ToolTiming
+}
+
+// newTurnRecorder wraps sink, which may be nil.
+func newTurnRecorder(sink EventSink) *turnRecorder {
+	if sink == nil {
+		sink = NopEventSink{}
+	}
+	return &turnRecorder{EventSink: sink}
+}
+
+// OnToolEnd implements EventSink.
+func (r *turnRecorder) OnToolEnd(call ToolEvent) {
+	outcome := "ok"
+	if call.Error != "" {
+		outcome = "failed"
+	}
+	r.mu.Lock()
+	r.tools = append(r.tools, call.Name+": "+outcome)
+	r.timings = append(r.timings, ToolTiming{Name: call.Name, Elapsed: call.Elapsed})
+	r.mu.Unlock()
+	r.EventSink.OnToolEnd(call)
+}
+
+// toolRuns returns the recorded tool calls in completion order.
+func (r *turnRecorder) toolRuns() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.tools...)
+}
+
+// toolTimings returns the durations of the recorded tool calls in
+// completion order.
+func (r *turnRecorder) toolTimings() []ToolTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ToolTiming(nil), r.timings...)
+}
+
+// persistTurn writes a completed turn to the conversation store: the user
+// message, the turn's tool runs and written files as event rows when the
+// store supports them, the assistant reply with its token usage and
+// timings, notes marking a continuation and a cut-off reply, and the
+// disclosure label after it when one is configured. A continuation, cont
+// non-nil, stores no user message: its reply is the next part of the
+// previous turn's answer. Errors are logged, never returned, so a store
+// failure cannot fail a query that already answered.
+func (a *TerraformAgent) persistTurn(ctx context.Context, req QueryRequest, reply string, rec *turnRecorder, res *QueryResult, cont *continuation) {
+	log := logging.FromContext(ctx)
+	workspaceDir, sessionID := req.WorkspaceDir, req.SessionID
+	// One turn ID groups the rows below, so that Recent never interleaves
+	// them with a turn another process writes to the same conversation.
+	if turn, err := store.NewTurnID(); err != nil {
+		log.Warn("history: failed to create turn id", slog.Any("error", err))
+	} else {
+		ctx = store.WithTurn(ctx, turn)
+	}
+	files := res.Files
+	if cont == nil {
+		if err := a.history.Append(ctx, workspaceDir, sessionID, store.RoleUser, req.Message); err != nil {
+			log.Warn("history: failed to persist user message", slog.Any("error", err))
+		}
+	}
+	if events, ok := a.history.(store.EventRecorder); ok {
+		for _, run := range rec.toolRuns() {
+			if err := events.AppendEvent(ctx, workspaceDir, sessionID, store.KindToolRun, run); err != nil {
+				log.Warn("history: failed to persist tool run", slog.Any("error", err))
+			}
+		}
+		if len(files) > 0 {
+			if err := events.AppendEvent(ctx, workspaceDir, sessionID, store.KindFilesWritten, strings.Join(files, "\n")); err != nil {
+				log.Warn("history: failed to persist written files", slog.Any("error", err))
+			}
+		}
+	}
+	if err := a.appendAssistant(ctx, workspaceDir, sessionID, reply, res.Timings); err != nil {
+		log.Warn("history: failed to persist assistant message", slog.Any("error", err))
+	}
+	if events, ok := a.history.(store.EventRecorder); ok {
+		continuations := 0
+		if cont != nil {
+			continuations = cont.count + 1
+			if err := events.AppendEvent(ctx, workspaceDir, sessionID, store.KindContinued, strconv.Itoa(continuations)); err != nil {
+				log.Warn("history: failed to persist continuation note", slog.Any("error", err))
+			}
+		}
+		if res.Truncated {
+			if err := events.AppendEvent(ctx, workspaceDir, sessionID, store.KindTruncated, strconv.Itoa(continuations)); err != nil {
+				log.Warn("history: failed to persist truncation note", slog.Any("error", err))
+			}
+		}
+	}
+	if events, ok := a.history.(store.EventRecorder); ok && a.disclosure != "" {
+		if err := events.AppendEvent(ctx, workspaceDir, sessionID, store.KindDisclosure, a.disclosure); err != nil {
+			log.Warn("history: failed to persist disclosure", slog.Any("error", err))
+		}
+	}
+}
+
+// historyMessages converts stored rows into model messages. Event rows
+// become compact bracketed notes, e.g. "[assistant wrote files: main.tf]",
+// prefixed to the assistant message that follows them so user and assistant
+// turns keep alternating. Notes with no assistant message after them are
+// sent as an assistant message of their own.
+func historyMessages(prior []store.Message) []*schema.Message {
+	var msgs []*schema.Message
+	var notes []string
+	flush := func() {
+		if len(notes) > 0 {
+			msgs = append(msgs, schema.AssistantMessage(strings.Join(notes, "\n"), nil))
+			notes = nil
+		}
+	}
+	for _, m := range prior {
+		switch {
+		case m.Kind != "" && m.Kind != store.KindMessage:
+			if note := eventNote(m); note != "" {
+				notes = append(notes, note)
+			}
+		case m.Role == store.RoleUser:
+			flush()
+			msgs = append(msgs, schema.UserMessage(m.Content))
+		case m.Role == store.RoleAssistant:
+			content := m.Content
+			if len(notes) > 0 {
+				content = strings.Join(notes, "\n") + "\n\n" + content
+				notes = nil
+			}
+			msgs = append(msgs, schema.AssistantMessage(content, nil))
+		}
+	}
+	flush()
+	return msgs
+}
+
+// historyTurns converts stored rows into model messages grouped by turn,
+// oldest first, each turn converted by historyMessages, and returns the
+// turn ID of each.
+func historyTurns(prior []store.Message) (turns [][]*schema.Message, ids []string) {
+	for start := 0; start < len(prior); {
+		end := start + 1
+		for end < len(prior) && prior[end].TurnID == prior[start].TurnID {
+			end++
+		}
+		if msgs := historyMessages(prior[start:end]); len(msgs) > 0 {
+			turns = append(turns, msgs)
+			ids = append(ids, prior[start].TurnID)
+		}
+		start = end
+	}
+	return turns, ids
+}
+
+// countMessages returns the number of messages in turns.
+func countMessages(turns [][]*schema.Message) int {
+	n := 0
+	for _, turn := range turns {
+		n += len(turn)
+	}
+	return n
+}
+
+// eventNote renders an event row as a bracketed note, or "" for kinds this
+// version does not know.
+func eventNote(m store.Message) string {
+	switch m.Kind {
+	case store.KindFilesWritten:
+		return "[assistant wrote files: " + strings.Join(strings.Split(m.Content, "\n"), ", ") + "]"
+	case store.KindToolRun:
+		return "[assistant ran " + m.Content + "]"
+	default:
+		return ""
+	}
+}
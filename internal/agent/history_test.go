@@ -0,0 +1,505 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/budget"
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// ---------------------------------------------------------------------------
+// historyMessages
+// ---------------------------------------------------------------------------
+
+func TestHistoryMessages(t *testing.T) {
+	t.Parallel()
+
+	user := func(c string) store.Message {
+		return store.Message{Role: store.RoleUser, Kind: store.KindMessage, Content: c}
+	}
+	asst := func(c string) store.Message {
+		return store.Message{Role: store.RoleAssistant, Kind: store.KindMessage, Content: c}
+	}
+	event := func(k store.Kind, c string) store.Message {
+		return store.Message{Role: store.RoleAssistant, Kind: k, Content: c}
+	}
+
+	tests := []struct {
+		name  string
+		prior []store.Message
+		want  []string
+	}{
+		{
+			name:  "plain turns",
+			prior: []store.Message{user("hi"), asst("hello")},
+			want:  []string{"user: hi", "assistant: hello"},
+		},
+		{
+			name: "notes prefix the assistant reply",
+			prior: []store.Message{
+				user("make a vpc"),
+				event(store.KindToolRun, "terraform_validate: ok"),
+				event(store.KindFilesWritten, "main.tf\nvariables.tf"),
+				asst("Wrote 2 files."),
+			},
+			want: []string{
+				"user: make a vpc",
+				"assistant: [assistant ran terraform_validate: ok]\n[assistant wrote files: main.tf, variables.tf]\n\nWrote 2 files.",
+			},
+		},
+		{
+			name:  "trailing notes stand alone",
+			prior: []store.Message{user("plan"), event(store.KindToolRun, "terraform_plan: failed")},
+			want:  []string{"user: plan", "assistant: [assistant ran terraform_plan: failed]"},
+		},
+		{
+			name:  "notes before a user message are flushed first",
+			prior: []store.Message{event(store.KindFilesWritten, "main.tf"), user("next")},
+			want:  []string{"assistant: [assistant wrote files: main.tf]", "user: next"},
+		},
+		{
+			name:  "unknown kinds are skipped",
+			prior: []store.Message{user("hi"), event("future_kind", "x"), asst("hello")},
+			want:  []string{"user: hi", "assistant: hello"},
+		},
+		{
+			name:  "rows without a kind are messages",
+			prior: []store.Message{{Role: store.RoleUser, Content: "hi"}},
+			want:  []string{"user: hi"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			msgs := historyMessages(tc.prior)
+			got := make([]string, 0, len(msgs))
+			for _, m := range msgs {
+				got = append(got, string(m.Role)+": "+m.Content)
+			}
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Turn events persisted through Run
+// ---------------------------------------------------------------------------
+
+// secretOutputTool is a tool whose output must never reach history.
+type secretOutputTool struct{}
+
+// secretToolOutput is the output of secretOutputTool.
+const secretToolOutput = "password = hunter2-do-not-store"
+
+func (secretOutputTool) Info(_ context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "fake_state", Desc: "fake state tool"}, nil
+}
+
+func (secretOutputTool) InvokableRun(_ context.Context, _ string, _ ...tool.Option) (string, error) {
+	return secretToolOutput, nil
+}
+
+func TestRunRecordsTurnEvents(t *testing.T) {
+	t.Parallel()
+
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+
+	envelope := `{"files":[{"path":"main.tf","content":"# main"},{"path":"variables.tf","content":"# vars"}],"summary":"Wrote 2 files."}`
+	var mu sync.Mutex
+	var lastInput []*schema.Message
+	m := &scriptedModel{script: func(turn int, input []*schema.Message) *schema.Message {
+		mu.Lock()
+		lastInput = input
+		mu.Unlock()
+		switch turn {
+		case 0:
+			return toolCall(turn, `{}`)
+		case 1:
+			return schema.AssistantMessage(envelope, nil)
+		default:
+			return schema.AssistantMessage("done", nil)
+		}
+	}}
+	a, err := New(context.Background(), &Config{
+		ChatModel:       m,
+		Tools:           []tool.BaseTool{secretOutputTool{}},
+		History:         hs,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	dir := t.TempDir()
+	sink := &recordingSink{}
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "make a vpc", WorkspaceDir: dir, Events: sink}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(sink.ends) != 1 {
+		t.Errorf("expected the caller's sink to still see the tool call, got %+v", sink.ends)
+	}
+
+	rows, err := hs.Recent(context.Background(), dir, "", 10)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	var got []string
+	for _, r := range rows {
+		got = append(got, string(r.Kind)+"="+r.Content)
+		if strings.Contains(r.Content, secretToolOutput) {
+			t.Errorf("tool output leaked into history: %+v", r)
+		}
+	}
+	want := []string{"message=make a vpc", "tool_run=fake_state: ok", "files_written=main.tf\nvariables.tf", "message=Wrote 2 files."}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("want rows %q, got %q", want, got)
+	}
+
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "what did you do?", WorkspaceDir: dir}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	var sent strings.Builder
+	for _, msg := range lastInput {
+		sent.WriteString(msg.Content + "\n")
+	}
+	for _, note := range []string{"[assistant ran fake_state: ok]", "[assistant wrote files: main.tf, variables.tf]"} {
+		if !strings.Contains(sent.String(), note) {
+			t.Errorf("expected %q in the injected history:\n%s", note, sent.String())
+		}
+	}
+}
+
+func TestRunTrimsEventNotes(t *testing.T) {
+	t.Parallel()
+
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+
+	ctx := context.Background()
+	const dir = "/ws/a"
+	oldFiles := strings.Repeat("modules/old/file.tf\n", 200) + "old.tf"
+	for _, step := range []func() error{
+		func() error { return hs.Append(ctx, dir, "", store.RoleUser, "first") },
+		func() error { return hs.AppendEvent(ctx, dir, "", store.KindFilesWritten, oldFiles) },
+		func() error { return hs.Append(ctx, dir, "", store.RoleAssistant, "wrote a lot") },
+		func() error { return hs.Append(ctx, dir, "", store.RoleUser, "second") },
+		func() error { return hs.AppendEvent(ctx, dir, "", store.KindToolRun, "terraform_plan: ok") },
+		func() error { return hs.Append(ctx, dir, "", store.RoleAssistant, "planned") },
+	} {
+		if err := step(); err != nil {
+			t.Fatalf("seed history: %v", err)
+		}
+	}
+
+	var input []*schema.Message
+	m := &scriptedModel{script: func(_ int, in []*schema.Message) *schema.Message {
+		input = in
+		return schema.AssistantMessage("ok", nil)
+	}}
+	a, err := New(context.Background(), &Config{
+		ChatModel:        m,
+		History:          hs,
+		MaxContextTokens: budget.Estimate(systemPrompt) + 200,
+		MetricsRegistry:  prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	res, err := a.Run(ctx, QueryRequest{Message: "third", WorkspaceDir: dir})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.HistoryDropped == 0 {
+		t.Fatal("expected history to be trimmed")
+	}
+	var sent strings.Builder
+	for _, msg := range input {
+		sent.WriteString(msg.Content + "\n")
+	}
+	if strings.Contains(sent.String(), "old.tf") {
+		t.Errorf("expected the oversized files note to be trimmed:\n%s", sent.String())
+	}
+	if !strings.Contains(sent.String(), "[assistant ran terraform_plan: ok]") {
+		t.Errorf("expected the recent tool note to be kept:\n%s", sent.String())
+	}
+}
+
+// TestRunKeepsTurnsWhole runs queries on one workspace from two agents with
+// their own stores on one database file, the way `tfai chat` and `tfai
+// serve` share ~/.tfai/history.db, then checks that the history replayed
+// to a third query, trimmed to a small budget, pairs every question with its
+// own answer.
+func TestRunKeepsTurnsWhole(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.db")
+	echo := func(_ int, in []*schema.Message) *schema.Message {
+		return schema.AssistantMessage("reply to "+in[len(in)-1].Content, nil)
+	}
+	newAgent := func(script func(int, []*schema.Message) *schema.Message, maxTokens int) *TerraformAgent {
+		hs, err := store.Open(ctx, path)
+		if err != nil {
+			t.Fatalf("store.Open: %v", err)
+		}
+		t.Cleanup(func() { _ = hs.Close() })
+		a, err := New(ctx, &Config{
+			ChatModel:        &scriptedModel{script: script},
+			History:          hs,
+			MaxContextTokens: maxTokens,
+			MetricsRegistry:  prometheus.NewRegistry(),
+		})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		return a
+	}
+
+	const dir, perWriter = "/ws/shared", 8
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*perWriter)
+	for i, a := range []*TerraformAgent{newAgent(echo, 0), newAgent(echo, 0)} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range perWriter {
+				if _, err := a.Run(ctx, QueryRequest{Message: fmt.Sprintf("question %d-%d", i, j), WorkspaceDir: dir}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var input []*schema.Message
+	reader := newAgent(func(_ int, in []*schema.Message) *schema.Message {
+		input = in
+		return schema.AssistantMessage("ok", nil)
+	}, budget.Estimate(systemPrompt)+100)
+	res, err := reader.Run(ctx, QueryRequest{Message: "last", WorkspaceDir: dir})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.HistoryDropped == 0 || res.HistoryDropped%2 != 0 {
+		t.Errorf("expected whole turns dropped, got %d messages", res.HistoryDropped)
+	}
+	history := input[1 : len(input)-1]
+	if len(history) == 0 || len(history)%2 != 0 {
+		t.Fatalf("expected whole turns of history, got %d messages", len(history))
+	}
+	for i := 0; i < len(history); i += 2 {
+		q, a := history[i], history[i+1]
+		if q.Role != schema.User || a.Role != schema.Assistant || a.Content != "reply to "+q.Content {
+			t.Errorf("turn %d split or interleaved: %s %q / %s %q", i/2, q.Role, q.Content, a.Role, a.Content)
+		}
+	}
+}
+
+// byteCounter is a budget.TokenCounter that counts one token per byte.
+type byteCounter struct{}
+
+func (byteCounter) CountTokens(s string) int { return len(s) }
+
+func TestRunUsesTokenCounter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	const dir = "/ws/a"
+	for _, tc := range []struct {
+		name        string
+		counter     budget.TokenCounter
+		wantDropped int
+	}{
+		// The budget fits the short turn by the character heuristic, but
+		// not when every byte is a token.
+		{name: "default", wantDropped: 0},
+		{name: "configured", counter: byteCounter{}, wantDropped: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			hs, err := store.Open(ctx, ":memory:")
+			if err != nil {
+				t.Fatalf("store.Open: %v", err)
+			}
+			t.Cleanup(func() { _ = hs.Close() })
+			if err := hs.Append(ctx, dir, "", store.RoleUser, "first"); err != nil {
+				t.Fatal(err)
+			}
+			if err := hs.Append(ctx, dir, "", store.RoleAssistant, "done"); err != nil {
+				t.Fatal(err)
+			}
+			a, err := New(ctx, &Config{
+				ChatModel:        &scriptedModel{script: func(int, []*schema.Message) *schema.Message { return schema.AssistantMessage("ok", nil) }},
+				History:          hs,
+				MaxContextTokens: budget.Estimate(systemPrompt) + 200,
+				TokenCounter:     tc.counter,
+				MetricsRegistry:  prometheus.NewRegistry(),
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			res, err := a.Run(ctx, QueryRequest{Message: "second", WorkspaceDir: dir})
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if res.HistoryDropped != tc.wantDropped {
+				t.Errorf("want %d history messages dropped, got %d", tc.wantDropped, res.HistoryDropped)
+			}
+		})
+	}
+}
+
+func TestRunSessionsAreIsolated(t *testing.T) {
+	t.Parallel()
+
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+
+	var mu sync.Mutex
+	seen := map[string]string{}
+	m := &scriptedModel{script: func(_ int, input []*schema.Message) *schema.Message {
+		var sent strings.Builder
+		for _, msg := range input[1:] {
+			sent.WriteString(msg.Content + "\n")
+		}
+		mu.Lock()
+		seen[input[len(input)-1].Content] = sent.String()
+		mu.Unlock()
+		return schema.AssistantMessage("ok", nil)
+	}}
+	a, err := New(context.Background(), &Config{ChatModel: m, History: hs, MetricsRegistry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	const dir = "/ws/a"
+	for _, req := range []QueryRequest{
+		{Message: "default first", WorkspaceDir: dir},
+		{Message: "session one first", WorkspaceDir: dir, SessionID: "one"},
+		{Message: "session two first", WorkspaceDir: dir, SessionID: "two"},
+		{Message: "session one second", WorkspaceDir: dir, SessionID: "one"},
+		{Message: "default second", WorkspaceDir: dir},
+	} {
+		if _, err := a.Run(context.Background(), req); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+
+	tests := []struct {
+		message string
+		want    string
+		notWant []string
+	}{
+		{message: "session one second", want: "session one first", notWant: []string{"default first", "session two first"}},
+		{message: "default second", want: "default first", notWant: []string{"session one first", "session two first"}},
+	}
+	for _, tc := range tests {
+		got := seen[tc.message]
+		if !strings.Contains(got, tc.want) {
+			t.Errorf("%q: expected %q in history:\n%s", tc.message, tc.want, got)
+		}
+		for _, nw := range tc.notWant {
+			if strings.Contains(got, nw) {
+				t.Errorf("%q: history leaked %q from another thread:\n%s", tc.message, nw, got)
+			}
+		}
+	}
+}
+
+func TestRunDisclosure(t *testing.T) {
+	t.Parallel()
+
+	const label = "AI-generated advice. Review before applying."
+	for _, disclosure := range []string{label, ""} {
+		disclosure := disclosure
+		t.Run("disclosure="+disclosure, func(t *testing.T) {
+			t.Parallel()
+			hs, err := store.Open(context.Background(), ":memory:")
+			if err != nil {
+				t.Fatalf("store.Open: %v", err)
+			}
+			t.Cleanup(func() { _ = hs.Close() })
+
+			var input []*schema.Message
+			m := &scriptedModel{script: func(_ int, in []*schema.Message) *schema.Message {
+				input = in
+				return schema.AssistantMessage("use for_each", nil)
+			}}
+			a, err := New(context.Background(), &Config{
+				ChatModel:       m,
+				History:         hs,
+				Disclosure:      disclosure,
+				MetricsRegistry: prometheus.NewRegistry(),
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			const dir = "/ws/a"
+			var out strings.Builder
+			for _, msg := range []string{"first", "second"} {
+				res, err := a.Run(context.Background(), QueryRequest{Message: msg, WorkspaceDir: dir, Output: &out})
+				if err != nil {
+					t.Fatalf("Run: %v", err)
+				}
+				if res.Disclosure != disclosure {
+					t.Errorf("expected result disclosure %q, got %q", disclosure, res.Disclosure)
+				}
+			}
+			if out.String() != "use for_eachuse for_each" {
+				t.Errorf("expected the disclosure kept out of the answer, got %q", out.String())
+			}
+
+			rows, err := hs.Recent(context.Background(), dir, "", 10)
+			if err != nil {
+				t.Fatalf("Recent: %v", err)
+			}
+			var got []string
+			for _, r := range rows {
+				got = append(got, string(r.Kind)+"="+r.Content)
+			}
+			want := []string{"message=first", "message=use for_each", "message=second", "message=use for_each"}
+			if disclosure != "" {
+				want = []string{"message=first", "message=use for_each", "disclosure=" + label,
+					"message=second", "message=use for_each", "disclosure=" + label}
+			}
+			if strings.Join(got, "|") != strings.Join(want, "|") {
+				t.Errorf("want rows %q, got %q", want, got)
+			}
+			for _, msg := range input {
+				if strings.Contains(msg.Content, "AI-generated") {
+					t.Errorf("expected the disclosure not replayed to the model, got %q", msg.Content)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/hclinspect"
+)
+
+// importRequestPattern matches requests to write Terraform for cloud
+// resources that already exist: asking to import them, saying they already
+// exist, were created outside Terraform, or should be brought under it.
+// Editing resources already in the workspace ("update the existing bucket")
+// and importing modules deliberately do not match.
+var importRequestPattern = regexp.MustCompile(`(?i)` +
+	`\bimport(ing)?\s+(it|them|existing|blocks?)\b` +
+	`|\bimport(ing)?\b.{0,60}\binto\s+(terraform|(the\s+)?state)\b` +
+	`|\bterraform\s+import\b` +
+	`|\balready\s+(exists?|created|deployed|provisioned|running)\b` +
+	`|\b(created|made|provisioned|deployed|built)\s+(manually|by\s+hand|in\s+the\s+(console|portal)|outside\s+(of\s+)?terraform)\b` +
+	`|\b(brownfield|click-?ops|unmanaged)\b` +
+	`|\b(adopt|bring)\b.{0,40}\bunder\s+terraform\b` +
+	`|\bnot\s+(yet\s+)?managed\s+by\s+terraform\b`)
+
+// wantsImport reports whether message asks for Terraform for resources that
+// already exist.
+func wantsImport(message string) bool {
+	return importRequestPattern.MatchString(message)
+}
+
+// importPrompt is the system message added in import-assist mode (see
+// QueryOptions.ImportMode). Without it the model writes configuration for
+// existing resources with no way to adopt them, so the first apply tries to
+// create duplicates.
+const importPrompt = `## Import Assist
+
+The user is describing cloud resources that ALREADY EXIST and must be brought
+under Terraform management, not created. When you generate files:
+
+- Write an ` + "`imports.tf`" + ` with one Terraform 1.5+ import block per existing
+  resource:
+
+      import {
+        to = aws_s3_bucket.logs
+        id = "<name of the existing bucket>"
+      }
+
+- Every import block's ` + "`to`" + ` must be a resource you declare in the generated
+  files of the root module, or one already in the workspace. Import into the
+  root module only; never into a data source.
+- Use the real ID when the user gave it. Otherwise write a placeholder in angle
+  brackets that says which ID the operator must look up and where, e.g.
+  ` + "`\"<bucket name, from the S3 console>\"`" + `. tfai lists every placeholder for
+  the operator, so never invent an ID.
+- Match the resource arguments to the existing resource as closely as the user
+  described it, so the first plan after import shows no unintended changes, and
+  set ` + "`required_version = \">= 1.5\"`" + ` in versions.tf.
+- Say in the summary that ` + "`terraform plan`" + ` will show the imports and any
+  remaining differences to review before apply.`
+
+// placeholderIDPattern matches an import ID the model left for the operator
+// to fill in, such as "<name of the existing bucket>".
+var placeholderIDPattern = regexp.MustCompile(`^<[^<>]+>$`)
+
+// ImportInput is an import block whose ID the operator has to fill in
+// before terraform can import the resource.
+type ImportInput struct {
+	// To is the address the block imports into, e.g. aws_s3_bucket.logs.
+	To string
+	// ID is the placeholder the model wrote, describing the ID to look up,
+	// e.g. "<name of the existing bucket>".
+	ID string
+	// Path is the workspace-relative file declaring the block.
+	Path string
+	// Line is the line the block starts on.
+	Line int
+}
+
+// checkImports checks the import blocks of env, whose files are relative to
+// workspaceDir, and returns those whose ID is a placeholder, in envelope
+// order. It fails when an import block is outside the root module, imports
+// into a data source, or its to address is declared neither in env nor in
+// the workspace's existing root files. Files that do not parse are skipped:
+// their errors are terraform's to report.
+func checkImports(env *TerraformAgentOutput, workspaceDir string) ([]ImportInput, error) {
+	type found struct {
+		path string
+		imp  hclinspect.Import
+	}
+	var imports []found
+	var errs []error
+	declared := make(map[string]bool)
+	generated := make(map[string]bool)
+	for p, content := range env.files() {
+		p = path.Clean(filepath.ToSlash(p))
+		if path.Ext(p) != ".tf" {
+			continue
+		}
+		root := !strings.Contains(p, "/")
+		if root {
+			generated[p] = true
+			resources, err := hclinspect.ParseResources([]byte(content), p)
+			if err != nil {
+				continue
+			}
+			for _, r := range resources {
+				declared[r.Address.String()] = true
+			}
+		}
+		imps, err := hclinspect.ParseImports([]byte(content), p)
+		if err != nil {
+			continue
+		}
+		for _, imp := range imps {
+			if !root {
+				errs = append(errs, fmt.Errorf("%s:%d: import blocks are only allowed in the root module", p, imp.Line))
+				continue
+			}
+			imports = append(imports, found{path: p, imp: imp})
+		}
+	}
+	if len(imports) == 0 && len(errs) == 0 {
+		return nil, nil
+	}
+	// Resources the workspace already declares in files the envelope does
+	// not replace can be imported into too.
+	if workspaceDir != "" {
+		entries, err := os.ReadDir(workspaceDir)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("agent: failed to read workspace: %w", err)
+		}
+		for _, e := range entries {
+			if e.IsDir() || filepath.Ext(e.Name()) != ".tf" || generated[e.Name()] {
+				continue
+			}
+			src, err := os.ReadFile(filepath.Join(workspaceDir, e.Name()))
+			if err != nil {
+				continue
+			}
+			resources, err := hclinspect.ParseResources(src, e.Name())
+			if err != nil {
+				continue
+			}
+			for _, r := range resources {
+				declared[r.Address.String()] = true
+			}
+		}
+	}
+
+	var inputs []ImportInput
+	for _, f := range imports {
+		addr := f.imp.Address
+		switch {
+		case addr.Type == "":
+			errs = append(errs, fmt.Errorf("%s:%d: import to %q is not a resource address", f.path, f.imp.Line, f.imp.To))
+			continue
+		case addr.Data:
+			errs = append(errs, fmt.Errorf("%s:%d: import to %s: data sources cannot be imported", f.path, f.imp.Line, addr))
+			continue
+		case addr.Module == nil && !declared[addr.String()]:
+			// Imports into a child module are left to terraform, which
+			// knows the module's resources.
+			errs = append(errs, fmt.Errorf("%s:%d: import to %s: no such resource is declared", f.path, f.imp.Line, addr))
+			continue
+		}
+		if placeholderIDPattern.MatchString(f.imp.ID) {
+			inputs = append(inputs, ImportInput{To: f.imp.To, ID: f.imp.ID, Path: f.path, Line: f.imp.Line})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("agent: invalid import blocks: %w", errors.Join(errs...))
+	}
+	return inputs, nil
+}
+
+// importNote returns the sentences appended to a file summary listing the
+// import IDs the operator has to fill in; "" when there are none.
+func importNote(inputs []ImportInput) string {
+	if len(inputs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nFill in these import IDs before running `terraform plan`:")
+	for _, in := range inputs {
+		fmt.Fprintf(&b, "\n- %s (%s:%d): %s", in.To, in.Path, in.Line, in.ID)
+	}
+	return b.String()
+}
@@ -0,0 +1,221 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/testutil"
+)
+
+// ---------------------------------------------------------------------------
+// Import assist
+// ---------------------------------------------------------------------------
+
+func TestWantsImport(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		message string
+		want    bool
+	}{
+		{"Write the Terraform for the S3 bucket that already exists", true},
+		{"Import the logs bucket into Terraform", true},
+		{"The VPC was created manually in the console, bring it under Terraform management", true},
+		{"Generate import blocks for our clickops RDS instance", true},
+		{"How do I use terraform import?", true},
+		{"These security groups are not managed by Terraform yet", true},
+		{"Generate an S3 bucket with versioning", false},
+		{"Update the existing bucket to enable encryption", false},
+		{"Import the module from the registry", false},
+	} {
+		if got := wantsImport(tc.message); got != tc.want {
+			t.Errorf("wantsImport(%q): expected %v, got %v", tc.message, tc.want, got)
+		}
+	}
+}
+
+const bucketTF = `resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
+`
+
+func TestCheckImports(t *testing.T) {
+	t.Parallel()
+
+	dir := testutil.NewWorkspace(t).
+		WithFile("network.tf", "resource \"aws_vpc\" \"main\" {\n  cidr_block = \"10.0.0.0/16\"\n}\n").
+		WithFile("replaced.tf", "resource \"aws_iam_role\" \"gone\" {}\n").
+		Dir()
+
+	env := &TerraformAgentOutput{Files: []GeneratedFile{
+		{Path: "main.tf", Content: bucketTF},
+		{Path: "imports.tf", Content: `import {
+  to = aws_s3_bucket.logs
+  id = "<name of the existing bucket>"
+}
+
+import {
+  to = aws_vpc.main
+  id = "vpc-0abc"
+}
+
+import {
+  to = module.db.aws_db_instance.this
+  id = "<RDS instance identifier>"
+}
+`},
+		{Path: "replaced.tf", Content: "locals {}\n"},
+	}}
+	inputs, err := checkImports(env, dir)
+	if err != nil {
+		t.Fatalf("checkImports: %v", err)
+	}
+	want := []ImportInput{
+		{To: "aws_s3_bucket.logs", ID: "<name of the existing bucket>", Path: "imports.tf", Line: 1},
+		{To: "module.db.aws_db_instance.this", ID: "<RDS instance identifier>", Path: "imports.tf", Line: 11},
+	}
+	if len(inputs) != len(want) {
+		t.Fatalf("expected inputs %+v, got %+v", want, inputs)
+	}
+	for i := range want {
+		if inputs[i] != want[i] {
+			t.Errorf("input %d: expected %+v, got %+v", i, want[i], inputs[i])
+		}
+	}
+
+	for _, tc := range []struct {
+		name, file, content, want string
+	}{
+		{
+			name:    "undeclared",
+			file:    "imports.tf",
+			content: "import {\n  to = aws_s3_bucket.other\n  id = \"other\"\n}\n",
+			want:    "imports.tf:1: import to aws_s3_bucket.other: no such resource is declared",
+		},
+		{
+			// The envelope replaces replaced.tf, so its role is gone.
+			name:    "replaced",
+			file:    "imports.tf",
+			content: "import {\n  to = aws_iam_role.gone\n  id = \"gone\"\n}\n",
+			want:    "import to aws_iam_role.gone: no such resource is declared",
+		},
+		{
+			name:    "data source",
+			file:    "imports.tf",
+			content: "import {\n  to = data.aws_s3_bucket.logs\n  id = \"logs\"\n}\n",
+			want:    "data sources cannot be imported",
+		},
+		{
+			name:    "not an address",
+			file:    "imports.tf",
+			content: "import {\n  to = local.bucket\n  id = \"logs\"\n}\n",
+			want:    `import to "local.bucket" is not a resource address`,
+		},
+		{
+			name:    "child module",
+			file:    "modules/s3/imports.tf",
+			content: "import {\n  to = aws_s3_bucket.logs\n  id = \"logs\"\n}\n",
+			want:    "modules/s3/imports.tf:1: import blocks are only allowed in the root module",
+		},
+	} {
+		bad := &TerraformAgentOutput{Files: []GeneratedFile{
+			{Path: "main.tf", Content: bucketTF},
+			{Path: "replaced.tf", Content: "locals {}\n"},
+			{Path: tc.file, Content: tc.content},
+		}}
+		if _, err := checkImports(bad, dir); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+
+	// Envelopes without import blocks, and files that do not parse, are
+	// left alone.
+	plain := &TerraformAgentOutput{Files: []GeneratedFile{{Path: "main.tf", Content: bucketTF}, {Path: "broken.tf", Content: "import {"}}}
+	if inputs, err := checkImports(plain, dir); err != nil || inputs != nil {
+		t.Errorf("expected no inputs and no error, got %v, %v", inputs, err)
+	}
+}
+
+func TestRunImportAssist(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	answer := ""
+	var systemPrompts []string
+	m := &scriptedModel{script: func(_ int, input []*schema.Message) *schema.Message {
+		mu.Lock()
+		defer mu.Unlock()
+		systemPrompts = append(systemPrompts, input[0].Content)
+		return schema.AssistantMessage(answer, nil)
+	}}
+	a, err := New(context.Background(), &Config{ChatModel: m, MetricsRegistry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	dir := testutil.NewWorkspace(t).Dir()
+	run := func(message string, opts QueryOptions, files map[string]string) (*QueryResult, string, error) {
+		t.Helper()
+		mu.Lock()
+		answer = envelopeOf(t, files)
+		mu.Unlock()
+		var out strings.Builder
+		res, err := a.Run(context.Background(), QueryRequest{Message: message, WorkspaceDir: dir, Output: &out, Options: opts})
+		return res, out.String(), err
+	}
+	lastPrompt := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return systemPrompts[len(systemPrompts)-1]
+	}
+
+	res, out, err := run("Write the Terraform for the logs bucket that already exists", QueryOptions{}, map[string]string{
+		"main.tf":    bucketTF,
+		"imports.tf": "import {\n  to = aws_s3_bucket.logs\n  id = \"<name of the existing bucket>\"\n}\n",
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(lastPrompt(), "## Import Assist") {
+		t.Error("expected the import prompt for a message about an existing resource")
+	}
+	if len(res.InputsNeeded) != 1 || res.InputsNeeded[0].To != "aws_s3_bucket.logs" {
+		t.Errorf("expected the placeholder ID in InputsNeeded, got %+v", res.InputsNeeded)
+	}
+	if want := "Fill in these import IDs before running `terraform plan`:\n- aws_s3_bucket.logs (imports.tf:1): <name of the existing bucket>"; !strings.Contains(out, want) {
+		t.Errorf("expected the summary to list the import ID, got:\n%s", out)
+	}
+
+	// ImportMode asks for imports whatever the message says.
+	if _, _, err := run("Generate the logs bucket", QueryOptions{ImportMode: true}, map[string]string{"main.tf": bucketTF}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(lastPrompt(), "## Import Assist") {
+		t.Error("expected the import prompt with ImportMode")
+	}
+	if _, _, err := run("Generate the logs bucket", QueryOptions{}, map[string]string{"main.tf": bucketTF}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if strings.Contains(lastPrompt(), "## Import Assist") {
+		t.Error("expected no import prompt for a new resource")
+	}
+
+	// An import into an undeclared resource rejects the whole envelope.
+	res, _, err = run("Import the bucket", QueryOptions{}, map[string]string{
+		"main.tf":    bucketTF,
+		"queue.tf":   "locals {}\n",
+		"imports.tf": "import {\n  to = aws_sqs_queue.jobs\n  id = \"<queue URL>\"\n}\n",
+	})
+	if err == nil || res.ErrorCode != CodeEnvelopeRejected || !strings.Contains(err.Error(), "aws_sqs_queue.jobs: no such resource is declared") {
+		t.Fatalf("expected the envelope rejected, got %v (%s)", err, res.ErrorCode)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "queue.tf")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing written from a rejected envelope, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Package agent — metrics.go registers Prometheus metrics owned by the agent.
+package agent
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// agentMetrics holds all Prometheus metrics owned by the TerraformAgent.
+// A single instance is created in New so tests can inject a fresh registry.
+type agentMetrics struct {
+	// toolGuardTripsTotal counts ReAct runs cut short by the tool guard,
+	// partitioned by reason: "iteration_limit" or "loop_detected".
+	toolGuardTripsTotal *prometheus.CounterVec
+	// envelopeParseFailuresTotal counts queries that expected a file
+	// envelope but got a response that is not one, partitioned by json_mode:
+	// "true" when the model's native JSON mode was active.
+	envelopeParseFailuresTotal *prometheus.CounterVec
+	// phaseDurationSeconds observes the duration of each query phase,
+	// partitioned by phase: context, first_token, model, tool (one
+	// observation per call), parse, apply, and total.
+	phaseDurationSeconds *prometheus.HistogramVec
+	// promptTokensTotal and completionTokensTotal count the tokens the
+	// provider reported for each query, partitioned by provider and model
+	// (Config.ProviderName and Config.ModelName).
+	promptTokensTotal     *prometheus.CounterVec
+	completionTokensTotal *prometheus.CounterVec
+}
+
+// newAgentMetrics registers all agent metrics against reg. When reg is nil a
+// private registry is used, so the metrics are recorded but never exported —
+// CLI commands that do not serve /metrics need not care about registration.
+func newAgentMetrics(reg prometheus.Registerer) *agentMetrics {
+	if reg == nil {
+		reg = prometheus.NewRegistry()
+	}
+	factory := promauto.With(reg)
+
+	return &agentMetrics{
+		toolGuardTripsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tfai",
+			Subsystem: "agent",
+			Name:      "tool_guard_trips_total",
+			Help:      "Total number of agent runs stopped by the tool guard, partitioned by reason.",
+		}, []string{"reason"}),
+		envelopeParseFailuresTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tfai",
+			Subsystem: "agent",
+			Name:      "envelope_parse_failures_total",
+			Help:      "Total number of envelope-expecting queries whose response was not a valid envelope, partitioned by whether native JSON mode was active.",
+		}, []string{"json_mode"}),
+		phaseDurationSeconds: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "tfai",
+			Subsystem: "agent",
+			Name:      "phase_duration_seconds",
+			Help:      "Duration of each phase of a query, partitioned by phase.",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		}, []string{"phase"}),
+		promptTokensTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tfai",
+			Subsystem: "llm",
+			Name:      "prompt_tokens_total",
+			Help:      "Total number of prompt tokens reported by the model provider, partitioned by provider and model.",
+		}, []string{"provider", "model"}),
+		completionTokensTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tfai",
+			Subsystem: "llm",
+			Name:      "completion_tokens_total",
+			Help:      "Total number of completion tokens reported by the model provider, partitioned by provider and model.",
+		}, []string{"provider", "model"}),
+	}
+}
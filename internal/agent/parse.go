@@ -2,19 +2,33 @@ package agent
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 )
 
 // parseAgentOutput takes an input string of generated text from the terrafrom agent tools
 // and extracts the file path, along with the raw HCL for each given file generated for the
 // returned tf solution
 func parseAgentOutput(output string) (*TerraformAgentOutput, error) {
+	return decodeAgentOutput(strings.NewReader(output))
+}
+
+// decodeAgentOutput is parseAgentOutput reading the generated text from r,
+// so a response spooled to disk is parsed without loading it into a string.
+// As with json.Unmarshal, anything but whitespace after the document is an
+// error.
+func decodeAgentOutput(r io.Reader) (*TerraformAgentOutput, error) {
 	agentOutput := &TerraformAgentOutput{}
 
-	err := json.Unmarshal([]byte(output), agentOutput)
-	if err != nil {
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(agentOutput); err != nil {
 		return nil, fmt.Errorf("agent::parseAgentOutput: failed to unmarshal agent output: %w", err)
 	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("agent::parseAgentOutput: failed to unmarshal agent output: unexpected data after the envelope")
+	}
 
 	return agentOutput, nil
 }
@@ -59,6 +59,14 @@ func TestParseAgentOutput(t *testing.T) {
 			name:    "bad json",
 			input:   agentOutputFail,
 			wantErr: true,
+		}, {
+			name:    "trailing data",
+			input:   agentOutputFull + "\nHope this helps!",
+			wantErr: true,
+		}, {
+			name:      "trailing whitespace",
+			input:     agentOutputFilesOnly + "\n\n",
+			wantFiles: 2,
 		},
 	}
 
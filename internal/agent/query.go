@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"io"
+	"time"
+)
+
+// QueryRequest is the input to TerraformAgent.Run, shared by the CLI
+// commands and the HTTP server. New per-query features belong here rather
+// than in additional Run parameters.
+type QueryRequest struct {
+	// Message is the user's natural language query.
+	Message string
+	// WorkspaceDir is the workspace the query works in. Its .tf files are
+	// injected as context and generated files are written to it. Empty means
+	// no workspace: nothing is read or written.
+	WorkspaceDir string
+	// SessionID selects the conversation thread of WorkspaceDir whose
+	// history is injected and extended. Empty uses the workspace's default
+	// thread (store.DefaultSession).
+	SessionID string
+	// Scope limits the workspace files injected as context to those whose
+	// slash-separated path relative to WorkspaceDir matches one of these
+	// path.Match patterns (e.g. "modules/vpc/*.tf"). Empty injects every file.
+	Scope []string
+	// Output receives the answer text, or the file summary when the agent
+	// writes files. Nil discards it.
+	Output io.Writer
+	// Events receives progress, tool, notice, sources, and usage events. Nil ignores
+	// them.
+	Events EventSink
+	// RequestID identifies the HTTP request that made the query, recorded
+	// with its workspace activity. Empty for the CLI.
+	RequestID string
+	// Options tunes this query.
+	Options QueryOptions
+}
+
+// QueryOptions are per-query overrides of the agent's Config.
+type QueryOptions struct {
+	// RAGTopK overrides Config.RAGTopK when positive.
+	RAGTopK int
+	// NoHistory skips loading prior turns and persisting this one, for
+	// one-shot queries that must not be influenced by or pollute the
+	// workspace conversation.
+	NoHistory bool
+	// ExpectEnvelope marks a generation query whose answer must be a file
+	// envelope. Models with a native JSON mode are then constrained to the
+	// envelope schema; others rely on the output contract in the prompt.
+	ExpectEnvelope bool
+	// ConfirmTool is asked before each call of a tool that requires
+	// confirmation (see tools.Confirmable); denied calls are not run and
+	// the model is told the user declined. Nil runs every call.
+	ConfirmTool ToolConfirmer
+	// ConfirmTimeout bounds how long a call waits for ConfirmTool before it
+	// is denied. Zero means DefaultToolConfirmTimeout.
+	ConfirmTimeout time.Duration
+	// Temperature overrides the model temperature for every model call of
+	// this query. Nil keeps the model's configured temperature.
+	Temperature *float32
+	// MaxTokens overrides the model's output token cap for every model call
+	// of this query when positive.
+	MaxTokens int
+	// NoWrite reads WorkspaceDir as context but never writes to it, for
+	// queries that analyse a workspace rather than change it. A file
+	// envelope answer is then output as is.
+	NoWrite bool
+	// Continue continues the session's last answer, which was cut off at
+	// the output token limit, instead of answering Message. The model is
+	// given the cut-off answer and asked to carry on, and the Output
+	// receives the stitched answer; a file envelope split between the parts
+	// is applied whole. It needs the conversation history, and an answer
+	// can be continued at most MaxContinuations times.
+	Continue bool
+	// ResolveConflict is asked how to write each generated file the user
+	// changed since tfai last wrote it (see tfaidir.UserModified). Nil
+	// overwrites them; they are backed up like any replaced file.
+	ResolveConflict ConflictResolver
+	// PreviewFiles parses a file envelope answer but does not write it:
+	// QueryResult.Preview reports what each file would change and
+	// QueryResult.Envelope holds the envelope for ApplyEnvelope. Ignored
+	// with NoWrite.
+	PreviewFiles bool
+	// ImportMode asks for import blocks that bring existing cloud resources
+	// under Terraform along with their configuration. It is also enabled
+	// when Message says the resources already exist (see wantsImport).
+	ImportMode bool
+}
+
+// QueryResult describes a finished query. Run always returns a non-nil
+// result, filled as far as the query got.
+type QueryResult struct {
+	// Files lists the workspace-relative paths written, in envelope order.
+	Files []string
+	// BackupDir is where the existing files the write replaced were backed
+	// up, relative to the workspace, e.g. ".tfai/backups/20260102T150405.000Z".
+	// Empty when no existing file was replaced.
+	BackupDir string
+	// Conflicts lists how each generated file the user had changed since
+	// tfai last wrote it was resolved, in envelope order. Files kept as the
+	// user left them are not in Files. Empty unless
+	// QueryOptions.ResolveConflict was set.
+	Conflicts []ResolvedConflict
+	// Sources lists the sources of the RAG documents injected as context.
+	Sources []string
+	// Usage is the token usage summed over every model call. Nil when the
+	// provider reported none.
+	Usage *Usage
+	// HistoryDropped is the number of prior messages left out to fit the
+	// context budget.
+	HistoryDropped int
+	// HistorySummarized is true when a summary of the dropped messages was
+	// injected in their place (see Config.SummarizeDroppedHistory).
+	HistorySummarized bool
+	// ToolLimitReached is true when the tool guard ended the run; the output
+	// then holds the guard's explanation instead of an answer.
+	ToolLimitReached bool
+	// Notices are messages for the user about what the agent cannot do in
+	// this environment, e.g. TerraformUnavailableNotice. They are also
+	// reported through EventSink.OnNotice before the model is called.
+	Notices []string
+	// ErrorCode classifies the error returned by Run. Empty on success.
+	ErrorCode ErrorCode
+	// Disclosure is Config.Disclosure, the label callers must show with the
+	// answer. Empty when none is configured.
+	Disclosure string
+	// Truncated is true when the model stopped at the output token limit,
+	// so the answer is incomplete; see QueryOptions.Continue.
+	Truncated bool
+	// Timings is where the query's time went. Set even when Run fails,
+	// covering the phases that ran.
+	Timings *Timings
+	// Preview lists what writing the answer's file envelope would change,
+	// in envelope order, when QueryOptions.PreviewFiles was set and the
+	// answer was an envelope. Nothing was written.
+	Preview []FilePreview
+	// Envelope is the previewed file envelope, to pass to ApplyEnvelope.
+	// Nil unless Preview is set.
+	Envelope *TerraformAgentOutput
+	// InputsNeeded lists the import blocks of the answer's file envelope
+	// whose ID the operator has to fill in, in envelope order. The summary
+	// lists them too.
+	InputsNeeded []ImportInput
+}
+
+// FilesWritten reports whether the query wrote any files. Safe on a nil result.
+func (r *QueryResult) FilesWritten() bool {
+	return r != nil && len(r.Files) > 0
+}
+
+// Usage is the token usage of one query.
+type Usage struct {
+	// PromptTokens is the number of input tokens.
+	PromptTokens int
+	// CompletionTokens is the number of generated tokens.
+	CompletionTokens int
+}
+
+// TimeoutError replaces the error of a query whose caller's time limit
+// passed, such as the server's chat timeout or a command's --timeout, so
+// users are told what happened rather than shown a context error.
+type TimeoutError struct {
+	// After is the time limit that passed.
+	After time.Duration
+	// Err is the error Run returned.
+	Err error
+}
+
+// Error implements error.
+func (e *TimeoutError) Error() string {
+	return "query timed out after " + e.After.String()
+}
+
+// Unwrap returns Err, so the error still matches context.DeadlineExceeded.
+func (e *TimeoutError) Unwrap() error { return e.Err }
+
+// ErrorCode is a machine-readable classification of a Run failure. Callers
+// branch on it rather than on error text.
+type ErrorCode string
+
+// Run error codes.
+const (
+	// CodeModel is a failure reported by the model provider or the ReAct
+	// loop, including the query context's deadline passing.
+	CodeModel ErrorCode = "model_error"
+	// CodeCanceled means the caller canceled the query context, e.g. an HTTP
+	// client disconnected. The error wraps context.Canceled.
+	CodeCanceled ErrorCode = "canceled"
+	// CodeResponseTooLarge means the model's answer exceeded the size cap.
+	CodeResponseTooLarge ErrorCode = "response_too_large"
+	// CodeWorkspaceOutsideRoot means WorkspaceDir is outside Config.WorkspaceRoot.
+	CodeWorkspaceOutsideRoot ErrorCode = "workspace_outside_root"
+	// CodeEnvelopeRejected means the generated file set exceeded its limits
+	// or has import blocks that target no declared resource.
+	CodeEnvelopeRejected ErrorCode = "envelope_rejected"
+	// CodeApplyFailed means generated files could not be written.
+	CodeApplyFailed ErrorCode = "apply_failed"
+	// CodeSpool means a large answer could not be spooled to disk.
+	CodeSpool ErrorCode = "spool_error"
+	// CodeOutput means writing to QueryRequest.Output failed.
+	CodeOutput ErrorCode = "output_error"
+	// CodeCannotContinue means QueryOptions.Continue was set but the
+	// session's last answer was not cut off, has been continued
+	// MaxContinuations times, or history is disabled.
+	CodeCannotContinue ErrorCode = "cannot_continue"
+)
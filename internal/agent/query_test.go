@@ -0,0 +1,965 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/54b3r/tfai-go/internal/envelope"
+	"github.com/54b3r/tfai-go/internal/rag"
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// staticRetriever returns fixed documents for every query and records the
+// topK it was last asked for.
+type staticRetriever struct {
+	docs []rag.Document
+	topK atomic.Int32
+}
+
+func (r *staticRetriever) Retrieve(_ context.Context, _ string, topK int) ([]rag.Document, error) {
+	r.topK.Store(int32(topK))
+	return r.docs, nil
+}
+
+// recordingSink is an EventSink that records every event it receives.
+type recordingSink struct {
+	mu      sync.Mutex
+	phases  []Phase
+	tools   []string
+	ends    []ToolEvent
+	sources []string
+	usage   []Usage
+	notices []string
+}
+
+func (s *recordingSink) OnPhase(p Phase) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phases = append(s.phases, p)
+}
+
+func (s *recordingSink) OnToolStart(ev ToolEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tools = append(s.tools, ev.Name)
+}
+
+func (s *recordingSink) OnToolEnd(ev ToolEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ends = append(s.ends, ev)
+}
+
+func (s *recordingSink) OnSources(sources []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources = append(s.sources, sources...)
+}
+
+func (s *recordingSink) OnUsage(u Usage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage = append(s.usage, u)
+}
+
+func (s *recordingSink) OnNotice(n string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notices = append(s.notices, n)
+}
+
+// ---------------------------------------------------------------------------
+// QueryResult
+// ---------------------------------------------------------------------------
+
+func TestRunFillsResult(t *testing.T) {
+	t.Parallel()
+
+	envelope := `{"files":[{"path":"main.tf","content":"# main"},{"path":"modules/vpc/vpc.tf","content":"# vpc"}],"summary":"Wrote 2 files."}`
+	a, err := New(context.Background(), &Config{
+		ChatModel: &chunkModel{chunks: []*schema.Message{withUsage(schema.AssistantMessage(envelope, nil), 80, 20)}},
+		Retriever: &staticRetriever{docs: []rag.Document{
+			{Source: "https://registry.terraform.io/a", Content: "a"},
+			{Source: "https://registry.terraform.io/b", Content: "b"},
+		}},
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	sink := &recordingSink{}
+	var out strings.Builder
+	res, err := a.Run(context.Background(), QueryRequest{
+		Message:      "vpc please",
+		WorkspaceDir: t.TempDir(),
+		Output:       &out,
+		Events:       sink,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if got := strings.Join(res.Files, ","); got != "main.tf,modules/vpc/vpc.tf" {
+		t.Errorf("unexpected files: %s", got)
+	}
+	if out.String() != "Wrote 2 files." {
+		t.Errorf("expected the summary on Output, got %q", out.String())
+	}
+	if len(res.Sources) != 2 || res.Sources[0] != "https://registry.terraform.io/a" {
+		t.Errorf("unexpected sources: %v", res.Sources)
+	}
+	if res.Usage == nil || *res.Usage != (Usage{PromptTokens: 80, CompletionTokens: 20}) {
+		t.Errorf("expected usage (80, 20), got %+v", res.Usage)
+	}
+	if res.ErrorCode != "" || res.ToolLimitReached {
+		t.Errorf("expected a clean result, got code=%q toolLimit=%v", res.ErrorCode, res.ToolLimitReached)
+	}
+
+	if fmt.Sprint(sink.sources) != fmt.Sprint(res.Sources) {
+		t.Errorf("OnSources: expected %v, got %v", res.Sources, sink.sources)
+	}
+	if len(sink.usage) != 1 || sink.usage[0] != *res.Usage {
+		t.Errorf("OnUsage: expected one call with %+v, got %v", *res.Usage, sink.usage)
+	}
+}
+
+func TestRunNoWrite(t *testing.T) {
+	t.Parallel()
+
+	envelope := `{"files":[{"path":"main.tf","content":"# main"}],"summary":"Wrote 1 file."}`
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: []*schema.Message{schema.AssistantMessage(envelope, nil)}},
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	dir := t.TempDir()
+	var out strings.Builder
+	res, err := a.Run(context.Background(), QueryRequest{
+		Message:      "what is wrong?",
+		WorkspaceDir: dir,
+		Output:       &out,
+		Options:      QueryOptions{NoWrite: true},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.FilesWritten() {
+		t.Errorf("expected no files written, got %v", res.Files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "main.tf")); !os.IsNotExist(err) {
+		t.Errorf("expected main.tf not to be written, got %v", err)
+	}
+	if out.String() != envelope {
+		t.Errorf("expected the envelope to be output as is, got %q", out.String())
+	}
+}
+
+func TestRunPreviewFiles(t *testing.T) {
+	t.Parallel()
+
+	envelope := `{"files":[{"path":"main.tf","content":"# generated\n"}],"summary":"Wrote 1 file."}`
+	log := &activityLog{}
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: []*schema.Message{schema.AssistantMessage(envelope, nil)}},
+		Activity:        log,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte("# mine\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	res, err := a.Run(context.Background(), QueryRequest{
+		Message:      "create a vpc",
+		WorkspaceDir: dir,
+		Output:       &out,
+		Options:      QueryOptions{PreviewFiles: true},
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.FilesWritten() || len(log.entries) != 0 {
+		t.Errorf("expected nothing written, got files %v and activity %+v", res.Files, log.entries)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "main.tf")); string(b) != "# mine\n" {
+		t.Errorf("expected main.tf untouched, got %q", b)
+	}
+	if out.String() != "Wrote 1 file." {
+		t.Errorf("expected the summary to be output, got %q", out.String())
+	}
+	if len(res.Preview) != 1 || res.Preview[0].New || !strings.Contains(res.Preview[0].Diff, "-# mine\n+# generated\n") {
+		t.Errorf("unexpected preview %+v", res.Preview)
+	}
+
+	applied, err := a.ApplyEnvelope(context.Background(), dir, res.Envelope, "req-7")
+	if err != nil {
+		t.Fatalf("ApplyEnvelope: %v (%s)", err, applied.ErrorCode)
+	}
+	if strings.Join(applied.Files, ",") != "main.tf" || applied.BackupDir == "" {
+		t.Errorf("expected main.tf written and backed up, got %+v", applied)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "main.tf")); string(b) != "# generated\n" {
+		t.Errorf("expected main.tf applied, got %q", b)
+	}
+	if len(log.entries) != 1 || log.entries[0].RequestID != "req-7" {
+		t.Errorf("expected the apply recorded as activity, got %+v", log.entries)
+	}
+}
+
+// activityLog is a store.ActivityRecorder that keeps entries in memory.
+type activityLog struct {
+	mu      sync.Mutex
+	entries []store.Activity
+}
+
+func (l *activityLog) RecordActivity(_ context.Context, a store.Activity) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, a)
+	return nil
+}
+
+func TestRunRecordsActivity(t *testing.T) {
+	t.Parallel()
+
+	envelope := `{"files":[{"path":"main.tf","content":"# main"},{"path":"outputs.tf","content":"# out"}],"summary":"Created a VPC."}`
+	tests := []struct {
+		name    string
+		answer  string
+		options QueryOptions
+		want    bool
+	}{
+		{name: "files written", answer: envelope, want: true},
+		{name: "files written without history", answer: envelope, options: QueryOptions{NoHistory: true}, want: true},
+		{name: "plain answer", answer: "use a data source"},
+		{name: "no write", answer: envelope, options: QueryOptions{NoWrite: true}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			log := &activityLog{}
+			a, err := New(context.Background(), &Config{
+				ChatModel:       &chunkModel{chunks: []*schema.Message{schema.AssistantMessage(tc.answer, nil)}},
+				Activity:        log,
+				ModelName:       "gpt-4o",
+				MetricsRegistry: prometheus.NewRegistry(),
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			dir := t.TempDir()
+			if _, err := a.Run(context.Background(), QueryRequest{
+				Message:      "create a vpc",
+				WorkspaceDir: dir,
+				RequestID:    "req-42",
+				Options:      tc.options,
+			}); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			if !tc.want {
+				if len(log.entries) != 0 {
+					t.Errorf("expected no activity, got %+v", log.entries)
+				}
+				return
+			}
+			if len(log.entries) != 1 {
+				t.Fatalf("expected 1 activity entry, got %+v", log.entries)
+			}
+			got := log.entries[0]
+			if got.Workspace != dir || got.Summary != "Created a VPC." || got.RequestID != "req-42" || got.Model != "gpt-4o" {
+				t.Errorf("unexpected entry: %+v", got)
+			}
+			if strings.Join(got.Files, ",") != "main.tf,outputs.tf" {
+				t.Errorf("expected files main.tf,outputs.tf, got %v", got.Files)
+			}
+		})
+	}
+}
+
+func TestRunErrorCodes(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: []*schema.Message{schema.AssistantMessage("ok", nil)}},
+		WorkspaceRoot:   root,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	res, err := a.Run(context.Background(), QueryRequest{Message: "hi", WorkspaceDir: t.TempDir()})
+	if err == nil {
+		t.Fatal("expected an error for a workspace outside the root")
+	}
+	if res == nil || res.ErrorCode != CodeWorkspaceOutsideRoot {
+		t.Errorf("expected error code %s, got %+v", CodeWorkspaceOutsideRoot, res)
+	}
+
+	res, err = a.Run(context.Background(), QueryRequest{Message: "hi", WorkspaceDir: root, Output: failingWriter{}})
+	if err == nil {
+		t.Fatal("expected an error from the failing writer")
+	}
+	if res.ErrorCode != CodeOutput {
+		t.Errorf("expected error code %s, got %q", CodeOutput, res.ErrorCode)
+	}
+}
+
+// hangingModel streams one chunk, signals started, and then sends nothing
+// more until the query context ends, like a provider mid-generation.
+type hangingModel struct {
+	chunkModel
+	started chan struct{}
+}
+
+func (m *hangingModel) Stream(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sw.Close()
+		sw.Send(schema.AssistantMessage("partial", nil), nil)
+		close(m.started)
+		<-ctx.Done()
+		sw.Send(nil, ctx.Err())
+	}()
+	return sr, nil
+}
+
+func (m *hangingModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func TestRunCanceled(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		end      func(context.Context) (context.Context, context.CancelFunc)
+		cancel   bool
+		wantCode ErrorCode
+		wantErr  error
+	}{
+		{name: "client gone", end: context.WithCancel, cancel: true, wantCode: CodeCanceled, wantErr: context.Canceled},
+		{
+			name: "deadline",
+			end: func(ctx context.Context) (context.Context, context.CancelFunc) {
+				return context.WithTimeout(ctx, 20*time.Millisecond)
+			},
+			wantCode: CodeModel,
+			wantErr:  context.DeadlineExceeded,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			m := &hangingModel{started: make(chan struct{})}
+			a, err := New(context.Background(), &Config{ChatModel: m, MetricsRegistry: prometheus.NewRegistry()})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			ctx, cancel := tc.end(context.Background())
+			defer cancel()
+			if tc.cancel {
+				go func() {
+					<-m.started
+					cancel()
+				}()
+			}
+			res, err := a.Run(ctx, QueryRequest{Message: "hi"})
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected an error wrapping %v, got %v", tc.wantErr, err)
+			}
+			if res.ErrorCode != tc.wantCode {
+				t.Errorf("expected error code %s, got %q", tc.wantCode, res.ErrorCode)
+			}
+		})
+	}
+}
+
+// failingWriter rejects every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("closed")
+}
+
+func TestQueryWrapsRun(t *testing.T) {
+	t.Parallel()
+
+	envelope := `{"files":[{"path":"main.tf","content":"# main"}],"summary":"Wrote 1 file."}`
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: []*schema.Message{schema.AssistantMessage(envelope, nil)}},
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var out strings.Builder
+	written, err := a.Query(context.Background(), "main please", t.TempDir(), &out)
+	if err != nil || !written {
+		t.Fatalf("Query: written=%v err=%v", written, err)
+	}
+	if out.String() != "Wrote 1 file." {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Events
+// ---------------------------------------------------------------------------
+
+func TestRunReportsPhasesInOrder(t *testing.T) {
+	t.Parallel()
+
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+
+	tests := []struct {
+		name string
+		cfg  Config
+		dir  bool
+		opts QueryOptions
+		want []Phase
+	}{
+		{
+			name: "all context sources",
+			cfg: Config{
+				History:   hs,
+				Retriever: &staticRetriever{docs: []rag.Document{{Source: "s", Content: "c"}}},
+			},
+			dir:  true,
+			want: []Phase{PhaseLoadingHistory, PhaseRetrievingDocs, PhaseReadingWorkspace, PhaseCallingModel},
+		},
+		{
+			name: "no history requested",
+			cfg:  Config{History: hs},
+			opts: QueryOptions{NoHistory: true},
+			want: []Phase{PhaseCallingModel},
+		},
+		{
+			name: "model only",
+			want: []Phase{PhaseCallingModel},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := tc.cfg
+			cfg.ChatModel = &chunkModel{chunks: []*schema.Message{schema.AssistantMessage("ok", nil)}}
+			cfg.MetricsRegistry = prometheus.NewRegistry()
+			a, err := New(context.Background(), &cfg)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			sink := &recordingSink{}
+			dir := ""
+			if tc.dir {
+				dir = t.TempDir()
+			}
+			if _, err := a.Run(context.Background(), QueryRequest{Message: "hi", WorkspaceDir: dir, Events: sink, Options: tc.opts}); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if fmt.Sprint(sink.phases) != fmt.Sprint(tc.want) {
+				t.Errorf("expected phases %v, got %v", tc.want, sink.phases)
+			}
+		})
+	}
+}
+
+func TestRunReportsToolCalls(t *testing.T) {
+	t.Parallel()
+
+	m := &scriptedModel{script: func(turn int, _ []*schema.Message) *schema.Message {
+		if turn == 0 {
+			return toolCall(turn, `{"subcommand":"list","dir":"/ws/a"}`)
+		}
+		return schema.AssistantMessage("one bucket", nil)
+	}}
+	a := newGuardTestAgent(t, m, &countingTool{}, 10)
+
+	sink := &recordingSink{}
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "what is in state?", Events: sink}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if fmt.Sprint(sink.tools) != "[fake_state]" {
+		t.Errorf("expected one fake_state start, got %v", sink.tools)
+	}
+	if len(sink.ends) != 1 {
+		t.Fatalf("expected one tool end, got %+v", sink.ends)
+	}
+	end := sink.ends[0]
+	if end.Name != "fake_state" || end.CallID != "call-0" || end.Dir != "/ws/a" || end.Error != "" {
+		t.Errorf("unexpected tool end: %+v", end)
+	}
+}
+
+func TestRunReportsRefusedToolCalls(t *testing.T) {
+	t.Parallel()
+
+	m := &scriptedModel{script: func(turn int, _ []*schema.Message) *schema.Message {
+		return toolCall(turn, `{"subcommand":"list"}`)
+	}}
+	a := newGuardTestAgent(t, m, &countingTool{}, 10)
+
+	sink := &recordingSink{}
+	res, err := a.Run(context.Background(), QueryRequest{Message: "what is in state?", Events: sink})
+	if err != nil || !res.ToolLimitReached {
+		t.Fatalf("Run: expected the loop guard to end the run, got err=%v res=%+v", err, res)
+	}
+	if len(sink.tools) != identicalCallLimit || len(sink.ends) != identicalCallLimit {
+		t.Fatalf("expected %d starts and ends, got %v and %+v", identicalCallLimit, sink.tools, sink.ends)
+	}
+	if last := sink.ends[len(sink.ends)-1]; !strings.Contains(last.Error, "same arguments") {
+		t.Errorf("expected the refused call to end with the guard error, got %+v", last)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// QueryRequest fields and options
+// ---------------------------------------------------------------------------
+
+func TestRunScopeLimitsWorkspaceContext(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for rel, content := range map[string]string{
+		"main.tf":             "# root module",
+		"modules/vpc/vpc.tf":  "# vpc module",
+		"modules/eks/main.tf": "# eks module",
+	} {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var input []*schema.Message
+	m := &scriptedModel{script: func(_ int, in []*schema.Message) *schema.Message {
+		input = in
+		return schema.AssistantMessage("ok", nil)
+	}}
+	a, err := New(context.Background(), &Config{ChatModel: m, MetricsRegistry: prometheus.NewRegistry()})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := a.Run(context.Background(), QueryRequest{
+		Message:      "review the vpc",
+		WorkspaceDir: dir,
+		Scope:        []string{"modules/vpc/*.tf"},
+	}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	var sent strings.Builder
+	for _, msg := range input {
+		sent.WriteString(msg.Content)
+	}
+	got := sent.String()
+	if !strings.Contains(got, "# vpc module") {
+		t.Errorf("expected the in-scope file in context:\n%s", got)
+	}
+	if strings.Contains(got, "# root module") || strings.Contains(got, "# eks module") {
+		t.Errorf("expected out-of-scope files to be left out:\n%s", got)
+	}
+}
+
+func TestRunOptions(t *testing.T) {
+	t.Parallel()
+
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+
+	r := &staticRetriever{}
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: []*schema.Message{schema.AssistantMessage("ok", nil)}},
+		History:         hs,
+		Retriever:       r,
+		RAGTopK:         5,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := a.Run(context.Background(), QueryRequest{
+		Message:      "one-shot",
+		WorkspaceDir: "/ws/a",
+		Options:      QueryOptions{RAGTopK: 2, NoHistory: true},
+	}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := r.topK.Load(); got != 2 {
+		t.Errorf("expected RAGTopK override 2, got %d", got)
+	}
+	msgs, err := hs.Recent(context.Background(), "/ws/a", "", 10)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("expected NoHistory to skip persisting the turn, got %d messages", len(msgs))
+	}
+
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "remember me", WorkspaceDir: "/ws/a"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := r.topK.Load(); got != 5 {
+		t.Errorf("expected the configured RAGTopK 5, got %d", got)
+	}
+	if msgs, _ = hs.Recent(context.Background(), "/ws/a", "", 10); len(msgs) != 2 {
+		t.Errorf("expected the turn to be persisted, got %d messages", len(msgs))
+	}
+}
+
+// optionsModel is a chunkModel that records the common options of its last
+// call.
+type optionsModel struct {
+	chunkModel
+	mu   sync.Mutex
+	opts *model.Options
+}
+
+func (m *optionsModel) record(opts []model.Option) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.opts = model.GetCommonOptions(nil, opts...)
+}
+
+func (m *optionsModel) Generate(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.record(opts)
+	return m.chunkModel.Generate(ctx, in, opts...)
+}
+
+func (m *optionsModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	m.record(opts)
+	return m.chunkModel.Stream(ctx, in, opts...)
+}
+
+func (m *optionsModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func TestRunModelOptions(t *testing.T) {
+	t.Parallel()
+
+	temp := float32(0.3)
+	tests := []struct {
+		name          string
+		options       QueryOptions
+		wantTemp      *float32
+		wantMaxTokens *int
+	}{
+		{name: "overrides", options: QueryOptions{Temperature: &temp, MaxTokens: 256}, wantTemp: &temp, wantMaxTokens: ptrInt(256)},
+		{name: "defaults", options: QueryOptions{}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			m := &optionsModel{chunkModel: chunkModel{chunks: []*schema.Message{schema.AssistantMessage("ok", nil)}}}
+			a, err := New(context.Background(), &Config{ChatModel: m, MetricsRegistry: prometheus.NewRegistry()})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if _, err := a.Run(context.Background(), QueryRequest{Message: "hi", Options: tc.options}); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if m.opts == nil {
+				t.Fatal("expected the model to be called")
+			}
+			if (m.opts.Temperature == nil) != (tc.wantTemp == nil) ||
+				m.opts.Temperature != nil && *m.opts.Temperature != *tc.wantTemp {
+				t.Errorf("expected temperature %v, got %v", tc.wantTemp, m.opts.Temperature)
+			}
+			if (m.opts.MaxTokens == nil) != (tc.wantMaxTokens == nil) ||
+				m.opts.MaxTokens != nil && *m.opts.MaxTokens != *tc.wantMaxTokens {
+				t.Errorf("expected maxTokens %v, got %v", tc.wantMaxTokens, m.opts.MaxTokens)
+			}
+		})
+	}
+}
+
+// ptrInt returns a pointer to v.
+func ptrInt(v int) *int { return &v }
+
+// failingReranker always fails.
+type failingReranker struct{}
+
+func (failingReranker) Rerank(context.Context, string, []rag.Document, int) ([]rag.Document, error) {
+	return nil, errors.New("rerank unavailable")
+}
+
+func TestRunReranksRetrievedDocs(t *testing.T) {
+	t.Parallel()
+
+	docs := []rag.Document{
+		{Source: "vpc", Content: "aws_vpc cidr blocks"},
+		{Source: "s3", Content: "aws_s3_bucket versioning"},
+		{Source: "nodegroup", Content: "aws_eks_node_group scaling_config for the nodegroup"},
+		{Source: "iam", Content: "aws_iam_role trust policy"},
+		{Source: "cluster", Content: "aws_eks_cluster and its nodegroup"},
+		{Source: "rds", Content: "aws_db_instance backups"},
+	}
+	tests := []struct {
+		name     string
+		reranker rag.Reranker
+		want     string
+	}{
+		{name: "lexical", reranker: rag.NewLexicalReranker(), want: "[nodegroup cluster]"},
+		{name: "failed rerank keeps retrieval order", reranker: failingReranker{}, want: "[vpc s3]"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var input []*schema.Message
+			m := &scriptedModel{script: func(_ int, in []*schema.Message) *schema.Message {
+				input = in
+				return schema.AssistantMessage("ok", nil)
+			}}
+			r := &staticRetriever{docs: docs}
+			a, err := New(context.Background(), &Config{
+				ChatModel:       m,
+				Retriever:       r,
+				RAGTopK:         2,
+				Reranker:        tc.reranker,
+				MetricsRegistry: prometheus.NewRegistry(),
+			})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+
+			res, err := a.Run(context.Background(), QueryRequest{Message: "scale the eks nodegroup"})
+			if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := r.topK.Load(); got != 6 {
+				t.Errorf("expected 3x2 candidates retrieved, got %d", got)
+			}
+			if got := fmt.Sprint(res.Sources); got != tc.want {
+				t.Errorf("expected sources %s, got %s", tc.want, got)
+			}
+			var sent strings.Builder
+			for _, msg := range input {
+				sent.WriteString(msg.Content)
+			}
+			for _, d := range docs {
+				in := strings.Contains(sent.String(), d.Content)
+				if want := strings.Contains(tc.want, d.Source); in != want {
+					t.Errorf("expected %s in RAG context=%v, got %v", d.Source, want, in)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectRAGDocs(t *testing.T) {
+	t.Parallel()
+
+	docs := []rag.Document{
+		{Source: "a", Content: strings.Repeat("a", 100), Score: 0.9},
+		{Source: "b", Content: strings.Repeat("b", 100), Score: 0.2},
+		{Source: "c", Content: strings.Repeat("c", 100), Score: 0.6},
+		{Source: "d", Content: strings.Repeat("d", 100), Score: 0.6},
+	}
+	tests := []struct {
+		name     string
+		minScore float32
+		maxChars int
+		want     string
+	}{
+		{name: "keep all", maxChars: -1, want: "[a b c d]"},
+		{name: "min score", minScore: 0.5, maxChars: -1, want: "[a c d]"},
+		{name: "char cap drops lowest first", maxChars: 300, want: "[a c d]"},
+		{name: "char cap drops later ties first", maxChars: 250, want: "[a c]"},
+		{name: "both", minScore: 0.7, maxChars: 1000, want: "[a]"},
+		{name: "nothing fits", maxChars: 50, want: "[]"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var got []string
+			for _, d := range selectRAGDocs(docs, tc.minScore, tc.maxChars) {
+				got = append(got, d.Source)
+			}
+			if fmt.Sprint(got) != tc.want {
+				t.Errorf("expected %s, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRunFiltersRAGDocs(t *testing.T) {
+	t.Parallel()
+
+	var input []*schema.Message
+	m := &scriptedModel{script: func(_ int, in []*schema.Message) *schema.Message {
+		input = in
+		return schema.AssistantMessage("ok", nil)
+	}}
+	a, err := New(context.Background(), &Config{
+		ChatModel: m,
+		Retriever: &staticRetriever{docs: []rag.Document{
+			{Source: "eks", Content: "node groups", Score: 0.82, Metadata: map[string]string{"provider": "aws", "doc_type": "resource"}},
+			{Source: "weak", Content: "unrelated", Score: 0.2},
+			{Source: "guide", Content: strings.Repeat("x", 60), Score: 0.5, Metadata: map[string]string{"doc_type": "guide"}},
+			{Source: "long", Content: strings.Repeat("y", 60), Score: 0.4},
+		}},
+		RAGMinScore:     0.3,
+		RAGMaxChars:     100,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	res, err := a.Run(context.Background(), QueryRequest{Message: "eks node groups"})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := fmt.Sprint(res.Sources); got != "[eks guide]" {
+		t.Errorf("expected the weak and lowest-scoring over-budget docs dropped, got %s", got)
+	}
+	var sent strings.Builder
+	for _, msg := range input {
+		sent.WriteString(msg.Content)
+	}
+	for _, want := range []string{
+		"### Source 1: eks (score 0.82, provider: aws, doc_type: resource)\nnode groups",
+		"### Source 2: guide (score 0.50, doc_type: guide)\n",
+	} {
+		if !strings.Contains(sent.String(), want) {
+			t.Errorf("expected %q in the RAG context:\n%s", want, sent.String())
+		}
+	}
+	if strings.Contains(sent.String(), "unrelated") || strings.Contains(sent.String(), "yyy") {
+		t.Errorf("expected dropped docs left out of the context:\n%s", sent.String())
+	}
+}
+
+// jsonModeOpts records the schema a jsonModeChunkModel was asked to follow.
+type jsonModeOpts struct {
+	schema string
+}
+
+// jsonModeChunkModel is a chunkModel with a native JSON mode. It records the
+// schema option of each Stream call.
+type jsonModeChunkModel struct {
+	chunkModel
+	mu      sync.Mutex
+	schemas []string
+}
+
+func (m *jsonModeChunkModel) JSONModeOptions(name string, s json.RawMessage) ([]model.Option, error) {
+	if name != envelope.SchemaName {
+		return nil, fmt.Errorf("unexpected schema name %q", name)
+	}
+	return []model.Option{model.WrapImplSpecificOptFn(func(o *jsonModeOpts) { o.schema = string(s) })}, nil
+}
+
+func (m *jsonModeChunkModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	o := model.GetImplSpecificOptions(&jsonModeOpts{}, opts...)
+	m.mu.Lock()
+	m.schemas = append(m.schemas, o.schema)
+	m.mu.Unlock()
+	return m.chunkModel.Stream(ctx, input, opts...)
+}
+
+func (m *jsonModeChunkModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func TestRunJSONMode(t *testing.T) {
+	t.Parallel()
+
+	prose := []*schema.Message{schema.AssistantMessage("not an envelope", nil)}
+	tests := []struct {
+		name           string
+		chatModel      model.ToolCallingChatModel
+		expectEnvelope bool
+		wantSchema     bool
+		wantFailures   map[string]float64
+	}{
+		{name: "capable model, generation", chatModel: &jsonModeChunkModel{chunkModel: chunkModel{chunks: prose}}, expectEnvelope: true, wantSchema: true, wantFailures: map[string]float64{"true": 1}},
+		{name: "capable model, other query", chatModel: &jsonModeChunkModel{chunkModel: chunkModel{chunks: prose}}},
+		{name: "prompt-only model, generation", chatModel: &chunkModel{chunks: prose}, expectEnvelope: true, wantFailures: map[string]float64{"false": 1}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := New(context.Background(), &Config{ChatModel: tc.chatModel, MetricsRegistry: prometheus.NewRegistry()})
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if _, err := a.Run(context.Background(), QueryRequest{
+				Message:      "generate a vpc",
+				WorkspaceDir: t.TempDir(),
+				Options:      QueryOptions{ExpectEnvelope: tc.expectEnvelope},
+			}); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			if jm, ok := tc.chatModel.(*jsonModeChunkModel); ok {
+				want := ""
+				if tc.wantSchema {
+					want = string(envelope.Schema())
+				}
+				if len(jm.schemas) != 1 || jm.schemas[0] != want {
+					t.Errorf("expected one model call with schema %q, got %q", want, jm.schemas)
+				}
+			}
+			for _, label := range []string{"true", "false"} {
+				got := testutil.ToFloat64(a.metrics.envelopeParseFailuresTotal.WithLabelValues(label))
+				if got != tc.wantFailures[label] {
+					t.Errorf("json_mode=%s: expected %v parse failures, got %v", label, tc.wantFailures[label], got)
+				}
+			}
+		})
+	}
+}
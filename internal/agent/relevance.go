@@ -0,0 +1,177 @@
+package agent
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/textenc"
+)
+
+// Default limits applied when a WorkspaceLimits field is zero. They keep
+// large repositories from exhausting memory or the context window.
+const (
+	// DefaultMaxWorkspaceFiles is the maximum number of files included in
+	// the workspace context.
+	DefaultMaxWorkspaceFiles = 50
+	// DefaultMaxWorkspaceFileBytes is the maximum size of a single file
+	// included; larger files are skipped.
+	DefaultMaxWorkspaceFileBytes = 100 * 1024 // 100 KiB
+	// DefaultMaxWorkspaceTotalBytes is the maximum total size of all
+	// included files.
+	DefaultMaxWorkspaceTotalBytes = 1024 * 1024 // 1 MiB
+)
+
+// WorkspaceLimits bounds the workspace files injected as context. Zero
+// fields use the defaults.
+type WorkspaceLimits struct {
+	// MaxFiles is the maximum number of files. Defaults to
+	// DefaultMaxWorkspaceFiles.
+	MaxFiles int
+	// MaxFileBytes is the maximum size of any single file. Defaults to
+	// DefaultMaxWorkspaceFileBytes.
+	MaxFileBytes int
+	// MaxTotalBytes is the maximum combined size of all files. Defaults to
+	// DefaultMaxWorkspaceTotalBytes.
+	MaxTotalBytes int
+}
+
+// WithDefaults returns a copy of l with zero fields replaced by defaults.
+func (l WorkspaceLimits) WithDefaults() WorkspaceLimits {
+	if l.MaxFiles <= 0 {
+		l.MaxFiles = DefaultMaxWorkspaceFiles
+	}
+	if l.MaxFileBytes <= 0 {
+		l.MaxFileBytes = DefaultMaxWorkspaceFileBytes
+	}
+	if l.MaxTotalBytes <= 0 {
+		l.MaxTotalBytes = DefaultMaxWorkspaceTotalBytes
+	}
+	return l
+}
+
+// workspaceFile is a file the workspace context may include.
+type workspaceFile struct {
+	// rel is the slash-separated path relative to the workspace.
+	rel string
+	// size is the size in bytes of the file, or of its symlink target.
+	size int64
+	// resourceTypes are the resource and data source types the file
+	// declares, e.g. "aws_s3_bucket". Empty for variable files.
+	resourceTypes []string
+}
+
+// reBlockType matches the type label of a resource or data block.
+var reBlockType = regexp.MustCompile(`(?m)^\s*(?:resource|data)\s+"([A-Za-z0-9_-]+)"`)
+
+// reMessageToken matches the words of a user message that can name a file
+// or a resource type.
+var reMessageToken = regexp.MustCompile(`[A-Za-z0-9_./-]+`)
+
+// listWorkspaceFiles returns the .tf and variable files under workspaceDir
+// that match scope and are no larger than limits.MaxFileBytes, in walk
+// order, with the resource types each declares. Unreadable entries and
+// dangling symlinks are skipped.
+func listWorkspaceFiles(workspaceDir string, scope []string, limits WorkspaceLimits) ([]workspaceFile, error) {
+	var files []workspaceFile
+	err := filepath.WalkDir(workspaceDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries
+		}
+		tf := strings.HasSuffix(d.Name(), ".tf")
+		if d.IsDir() || !(tf || isTfvarsFile(d.Name())) {
+			return nil
+		}
+		rel, err := filepath.Rel(workspaceDir, p)
+		if err != nil || !inScope(scope, filepath.ToSlash(rel)) {
+			return nil
+		}
+		// Stat, not d.Info: the size cap applies to the file a symlink points to.
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil // skip dangling symlinks
+		}
+		if info.Size() > int64(limits.MaxFileBytes) {
+			return nil // skip oversized files silently
+		}
+		f := workspaceFile{rel: filepath.ToSlash(rel), size: info.Size()}
+		if tf {
+			f.resourceTypes = declaredTypes(p)
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("agent: workspace walk failed: %w", err)
+	}
+	return files, nil
+}
+
+// declaredTypes returns the resource and data source types declared in the
+// .tf file at path, or nil when it cannot be read.
+func declaredTypes(path string) []string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	text, err := textenc.Decode(content)
+	if err != nil {
+		return nil
+	}
+	var types []string
+	for _, m := range reBlockType.FindAllStringSubmatch(text.Content, -1) {
+		if !slices.Contains(types, m[1]) {
+			types = append(types, m[1])
+		}
+	}
+	return types
+}
+
+// rankWorkspaceFiles returns files ordered by relevance to message: first
+// the files whose name or path, or one of whose resource types, the message
+// mentions; then the root module's files; then the rest. Walk order is kept
+// within each group.
+func rankWorkspaceFiles(files []workspaceFile, message string) []workspaceFile {
+	words := make(map[string]bool)
+	for _, w := range reMessageToken.FindAllString(message, -1) {
+		// "main.tf." at the end of a sentence, "aws_s3_bucket.logs" as an
+		// address.
+		w = strings.TrimRight(w, "./-")
+		words[w] = true
+		if typ, _, ok := strings.Cut(w, "."); ok {
+			words[typ] = true
+		}
+	}
+	rank := func(f workspaceFile) int {
+		if words[f.rel] || words[path.Base(f.rel)] {
+			return 0
+		}
+		for _, typ := range f.resourceTypes {
+			if words[typ] {
+				return 0
+			}
+		}
+		if !strings.Contains(f.rel, "/") {
+			return 1
+		}
+		return 2
+	}
+	ranked := slices.Clone(files)
+	slices.SortStableFunc(ranked, func(a, b workspaceFile) int { return rank(a) - rank(b) })
+	return ranked
+}
+
+// omittedNote is the line ending the workspace context when n files were
+// left out to stay within its limits.
+func omittedNote(n int) string {
+	files := "files"
+	if n == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("%d %s omitted to stay within the workspace context limits; ask about them by name to include them.\n\n", n, files)
+}
@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultSpoolThreshold is how much of a model response Run holds in memory
+// before spilling it to a temporary file. See Config.SpoolThreshold.
+const DefaultSpoolThreshold = 4 << 20 // 4 MiB
+
+// maxResponseBytes caps a model response, spooled or not, so a runaway or
+// adversarial model cannot fill the disk.
+const maxResponseBytes = 64 << 20 // 64 MiB
+
+// historyPreviewBytes is how much of a spooled response is persisted to the
+// conversation history.
+const historyPreviewBytes = 64 << 10 // 64 KiB
+
+// spoolBuffer accumulates a model response in memory until it grows past
+// threshold, then moves it to a temporary file and appends there. Either
+// way Reader reads the whole response back. Close removes the file.
+type spoolBuffer struct {
+	// threshold is the most bytes held in memory; negative never spills.
+	threshold int
+	// dir returns the directory to create the file in. It is only called
+	// when the buffer spills.
+	dir func() string
+
+	// mem holds the response until it spills.
+	mem bytes.Buffer
+	// file holds the response once it has spilled; nil before.
+	file *os.File
+	// size is the number of bytes written.
+	size int64
+}
+
+// newSpoolBuffer returns an empty spoolBuffer that spills past threshold
+// bytes into a file in dir().
+func newSpoolBuffer(threshold int, dir func() string) *spoolBuffer {
+	return &spoolBuffer{threshold: threshold, dir: dir}
+}
+
+// WriteString appends s, spilling to a file first if s would take the
+// in-memory response past the threshold.
+func (b *spoolBuffer) WriteString(s string) (int, error) {
+	if b.file == nil && b.threshold >= 0 && b.mem.Len()+len(s) > b.threshold {
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.WriteString(s)
+	} else {
+		n, err = b.mem.WriteString(s)
+	}
+	b.size += int64(n)
+	if err != nil {
+		return n, fmt.Errorf("agent: failed to write spool file: %w", err)
+	}
+	return n, nil
+}
+
+// spill moves the in-memory response to a new temporary file.
+func (b *spoolBuffer) spill() error {
+	f, err := os.CreateTemp(b.dir(), "response-*.spool")
+	if err != nil {
+		return fmt.Errorf("agent: failed to create spool file: %w", err)
+	}
+	if _, err := f.Write(b.mem.Bytes()); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("agent: failed to write spool file: %w", err)
+	}
+	b.file = f
+	b.mem = bytes.Buffer{}
+	return nil
+}
+
+// Len returns the number of bytes written.
+func (b *spoolBuffer) Len() int64 { return b.size }
+
+// Spilled reports whether the response has moved to a file.
+func (b *spoolBuffer) Spilled() bool { return b.file != nil }
+
+// Path returns the spool file's path, or "" before the buffer spills.
+func (b *spoolBuffer) Path() string {
+	if b.file == nil {
+		return ""
+	}
+	return b.file.Name()
+}
+
+// Reader returns a reader over the whole response written so far. Each call
+// starts from the beginning.
+func (b *spoolBuffer) Reader() io.Reader {
+	if b.file == nil {
+		return bytes.NewReader(b.mem.Bytes())
+	}
+	return io.NewSectionReader(b.file, 0, b.size)
+}
+
+// HistoryText returns the response as it is persisted to the conversation
+// history: all of it while it is in memory, otherwise its first
+// historyPreviewBytes followed by a note of the full size, so a spooled
+// response never lands in the history database whole.
+func (b *spoolBuffer) HistoryText() (string, error) {
+	if b.file == nil {
+		return b.mem.String(), nil
+	}
+	preview, err := io.ReadAll(io.LimitReader(b.Reader(), historyPreviewBytes))
+	if err != nil {
+		return "", fmt.Errorf("agent: failed to read spool file: %w", err)
+	}
+	// The cut may fall inside a multi-byte character.
+	text := strings.ToValidUTF8(string(preview), "")
+	return fmt.Sprintf("%s\n\n[response truncated in history: first %d of %d bytes kept]", text, len(text), b.size), nil
+}
+
+// Close removes the spool file, if any. The buffer must not be used after.
+func (b *spoolBuffer) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	err := b.file.Close()
+	if rerr := os.Remove(name); rerr != nil && !os.IsNotExist(rerr) {
+		return fmt.Errorf("agent: failed to remove spool file: %w", rerr)
+	}
+	if err != nil {
+		return fmt.Errorf("agent: failed to close spool file: %w", err)
+	}
+	return nil
+}
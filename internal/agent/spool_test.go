@@ -0,0 +1,251 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+)
+
+// ---------------------------------------------------------------------------
+// Response spooling
+// ---------------------------------------------------------------------------
+
+// spoolTestThreshold is the SpoolThreshold of the agents below, far smaller
+// than the synthetic responses they stream.
+const spoolTestThreshold = 1 << 10
+
+// chunked splits s into chunks of size bytes, as a model would stream it.
+func chunked(s string, size int) []*schema.Message {
+	var chunks []*schema.Message
+	for len(s) > size {
+		chunks = append(chunks, schema.AssistantMessage(s[:size], nil))
+		s = s[size:]
+	}
+	return append(chunks, schema.AssistantMessage(s, nil))
+}
+
+// assertEmptyDir fails unless dir exists and holds no entries.
+func assertEmptyDir(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir %s: %v", dir, err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected %s to be empty after the query, found %s", dir, entries[0].Name())
+	}
+}
+
+// spooled reports whether logs record a response spilling to disk.
+func spooled(logs *bytes.Buffer) bool {
+	return strings.Contains(logs.String(), `"msg":"agent: response spooled to disk"`)
+}
+
+func TestRunSpoolsLargeEnvelope(t *testing.T) {
+	t.Parallel()
+
+	content := strings.Repeat("# padding\n", 20_000) // 200 KB
+	envelope := fmt.Sprintf(`{"files":[{"path":"main.tf","content":%q}],"summary":"Wrote 1 file."}`, content)
+	spoolDir := t.TempDir()
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: chunked(envelope, 4096)},
+		SpoolThreshold:  spoolTestThreshold,
+		SpoolDir:        spoolDir,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var logs bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&logs, nil)))
+	dir := t.TempDir()
+	var out strings.Builder
+	res, err := a.Run(ctx, QueryRequest{Message: "big module", WorkspaceDir: dir, Output: &out})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !spooled(&logs) {
+		t.Errorf("expected the response to spill to disk, logs:\n%s", logs.String())
+	}
+	if strings.Join(res.Files, ",") != "main.tf" || out.String() != "Wrote 1 file." {
+		t.Errorf("expected the envelope parsed from the spool file, got files %v and output %q", res.Files, out.String())
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "main.tf")); err != nil || string(got) != content {
+		t.Errorf("expected main.tf written in full (%d bytes), got %d bytes, %v", len(content), len(got), err)
+	}
+	assertEmptyDir(t, spoolDir)
+}
+
+func TestRunSpoolsLargeAnswerToHistory(t *testing.T) {
+	t.Parallel()
+
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+
+	answer := strings.Repeat("é is two bytes; ", 10_000) // 170 KB
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: chunked(answer, 3000)},
+		History:         hs,
+		SpoolThreshold:  spoolTestThreshold,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	// Without SpoolDir the file goes in the workspace's .tfai/tmp.
+	var logs bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&logs, nil)))
+	dir := t.TempDir()
+	var out strings.Builder
+	if _, err := a.Run(ctx, QueryRequest{Message: "explain", WorkspaceDir: dir, Output: &out}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !spooled(&logs) || !strings.Contains(logs.String(), filepath.Join(dir, ".tfai", "tmp")) {
+		t.Errorf("expected the response to spill to the workspace's .tfai/tmp, logs:\n%s", logs.String())
+	}
+	if out.String() != answer {
+		t.Errorf("expected the whole answer on Output, got %d of %d bytes", out.Len(), len(answer))
+	}
+	assertEmptyDir(t, tfaidir.Path(dir, tfaidir.Tmp))
+
+	rows, err := hs.Recent(context.Background(), dir, "", 10)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected the question and answer in history, got %d rows", len(rows))
+	}
+	stored := rows[1].Content
+	note := fmt.Sprintf("[response truncated in history: first %d of %d bytes kept]", historyPreviewBytes-1, len(answer))
+	if !strings.HasPrefix(answer, strings.TrimSuffix(stored, "\n\n"+note)) || !strings.HasSuffix(stored, note) {
+		t.Errorf("expected a %d byte preview and a size note in history, got %d bytes ending %q",
+			historyPreviewBytes, len(stored), stored[len(stored)-80:])
+	}
+}
+
+func TestRunSmallAnswerIsNotSpooled(t *testing.T) {
+	t.Parallel()
+
+	spoolDir := t.TempDir()
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: chunked(strings.Repeat("x", spoolTestThreshold), 100)},
+		SpoolThreshold:  spoolTestThreshold,
+		SpoolDir:        spoolDir,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var logs bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&logs, nil)))
+	var out strings.Builder
+	if _, err := a.Run(ctx, QueryRequest{Message: "hi", Output: &out}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if spooled(&logs) || out.Len() != spoolTestThreshold {
+		t.Errorf("expected an answer of exactly the threshold to stay in memory, got %d bytes, logs:\n%s", out.Len(), logs.String())
+	}
+	assertEmptyDir(t, spoolDir)
+}
+
+// brokenStreamModel streams chunks and then fails, like a provider dropping
+// the connection mid-generation.
+type brokenStreamModel struct {
+	chunkModel
+}
+
+func (m *brokenStreamModel) Stream(_ context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, sw := schema.Pipe[*schema.Message](len(m.chunks) + 1)
+	for _, c := range m.chunks {
+		sw.Send(c, nil)
+	}
+	sw.Send(nil, errors.New("connection reset by peer"))
+	sw.Close()
+	return sr, nil
+}
+
+func (m *brokenStreamModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func TestRunRemovesSpoolOnFailure(t *testing.T) {
+	t.Parallel()
+
+	spoolDir := t.TempDir()
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &brokenStreamModel{chunkModel{chunks: chunked(strings.Repeat("y", 10*spoolTestThreshold), 512)}},
+		SpoolThreshold:  spoolTestThreshold,
+		SpoolDir:        spoolDir,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var logs bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&logs, nil)))
+	res, err := a.Run(ctx, QueryRequest{Message: "hi", Output: &strings.Builder{}})
+	if err == nil || res.ErrorCode != CodeModel {
+		t.Fatalf("expected a model error, got %v (%+v)", err, res)
+	}
+	if !spooled(&logs) {
+		t.Errorf("expected the response to spill before the stream failed, logs:\n%s", logs.String())
+	}
+	assertEmptyDir(t, spoolDir)
+}
+
+func TestSpoolBuffer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	b := newSpoolBuffer(8, func() string { return dir })
+	for _, s := range []string{"abc", "defgh", "ij", "klm"} {
+		if _, err := b.WriteString(s); err != nil {
+			t.Fatalf("WriteString(%q): %v", s, err)
+		}
+	}
+	if !b.Spilled() || b.Len() != 13 || filepath.Dir(b.Path()) != dir {
+		t.Fatalf("expected 13 bytes spilled into %s, got spilled=%v len=%d path=%s", dir, b.Spilled(), b.Len(), b.Path())
+	}
+	// Every Reader starts from the beginning.
+	for range 2 {
+		var got bytes.Buffer
+		if _, err := got.ReadFrom(b.Reader()); err != nil || got.String() != "abcdefghijklm" {
+			t.Errorf("expected the whole response back, got %q, %v", got.String(), err)
+		}
+	}
+	if text, err := b.HistoryText(); err != nil || !strings.HasPrefix(text, "abcdefghijklm\n\n[response truncated in history: first 13 of 13 bytes kept]") {
+		t.Errorf("unexpected history text %q, %v", text, err)
+	}
+	path := b.Path()
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the spool file removed, got %v", err)
+	}
+
+	// A negative threshold never spills.
+	mem := newSpoolBuffer(-1, func() string { t.Fatal("unexpected spill"); return "" })
+	if _, err := mem.WriteString(strings.Repeat("z", 1<<16)); err != nil || mem.Spilled() {
+		t.Errorf("expected a negative threshold to keep the response in memory, spilled=%v, %v", mem.Spilled(), err)
+	}
+}
@@ -1,5 +1,7 @@
 package agent
 
+import "iter"
+
 // GeneratedFile Struct is used to define the json schema for a file being used
 // to store generated terraform code from the agent execution output
 type GeneratedFile struct {
@@ -10,9 +12,22 @@ type GeneratedFile struct {
 	Content string `json:"content"`
 }
 
+// TerraformAgentOutput is the JSON envelope the agent emits when generating
+// files for a workspace.
 type TerraformAgentOutput struct {
 	// Files holds a slice of the generated files
 	Files []GeneratedFile `json:"files"`
 	// Summary holds the summary of the generated files
 	Summary string `json:"summary"`
 }
+
+// files yields each generated file as a path → content pair, in order.
+func (o *TerraformAgentOutput) files() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, f := range o.Files {
+			if !yield(f.Path, f.Content) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// DefaultSummarizeMinDropped is the number of dropped history messages that
+// must be exceeded before they are summarised, when
+// Config.SummarizeMinDropped is zero.
+const DefaultSummarizeMinDropped = 4
+
+// summaryMaxTokens caps the length of a history summary. The budget for the
+// history kept is reduced by as much when dropped turns are summarised.
+const summaryMaxTokens = 400
+
+// summaryPrompt instructs the model to summarise dropped history turns.
+const summaryPrompt = `You summarise the earlier part of a conversation between a user and a Terraform assistant, for the assistant to read in place of it.
+List, as short bullet points, the decisions made and the constraints agreed: regions, accounts, naming and tagging schemes, provider and module versions, backend settings, and anything the user ruled out or asked to avoid.
+Leave out greetings, explanations, and code unless a detail is needed to honour a decision. If a summary so far is given, merge it with the newer turns, keeping every decision the newer turns did not change.
+Reply with the bullet points only.`
+
+// summaryHeader introduces a history summary injected into the context.
+const summaryHeader = "Summary of the earlier conversation, whose turns were left out to fit the context window:\n\n"
+
+// historySummary returns the system message that replaces the first dropped
+// of turns, the history loaded for a query, oldest first, whose turn IDs are
+// ids. It reuses the session's cached summary (see store.SummaryStore)
+// unless more than a.summarizeMinDropped of the dropped messages are newer
+// than the turns it covers; then it summarises those, merged with the cached
+// summary, and caches the result. It returns nil when there is no summary to
+// inject; failures are logged.
+func (a *TerraformAgent) historySummary(ctx context.Context, workspaceDir, sessionID string, turns [][]*schema.Message, ids []string, dropped int) *schema.Message {
+	log := logging.FromContext(ctx)
+	cache, _ := a.history.(store.SummaryStore)
+	var prev store.HistorySummary
+	cached := false
+	if cache != nil {
+		var err error
+		if prev, cached, err = cache.HistorySummary(ctx, workspaceDir, sessionID); err != nil {
+			log.Warn("history: failed to load history summary", slog.Any("error", err))
+		}
+	}
+
+	// covered is the index of the newest loaded turn the cached summary
+	// covers; -1 when it only covers turns older than those loaded.
+	covered := -1
+	if cached {
+		covered = slices.Index(ids, prev.Through)
+	}
+	newer := turns[min(covered+1, dropped):dropped]
+	if cached && countMessages(newer) <= a.summarizeMinDropped {
+		return schema.SystemMessage(summaryHeader + prev.Content)
+	}
+
+	summary, err := a.summarize(ctx, prev.Content, newer)
+	if err != nil {
+		log.Warn("history: failed to summarise dropped history", slog.Any("error", err))
+		if cached {
+			return schema.SystemMessage(summaryHeader + prev.Content)
+		}
+		return nil
+	}
+	if cache != nil {
+		if err := cache.SaveHistorySummary(ctx, workspaceDir, sessionID, store.HistorySummary{Through: ids[dropped-1], Content: summary}); err != nil {
+			log.Warn("history: failed to cache history summary", slog.Any("error", err))
+		}
+	}
+	return schema.SystemMessage(summaryHeader + summary)
+}
+
+// summarize asks the chat model to summarise turns, merged with the summary
+// so far when previous is not empty.
+func (a *TerraformAgent) summarize(ctx context.Context, previous string, turns [][]*schema.Message) (string, error) {
+	var b strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&b, "Summary so far:\n%s\n\n", previous)
+	}
+	b.WriteString("Conversation:\n")
+	for _, m := range slices.Concat(turns...) {
+		fmt.Fprintf(&b, "%s: %s\n\n", m.Role, m.Content)
+	}
+	msg, err := a.chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(summaryPrompt),
+		schema.UserMessage(b.String()),
+	}, model.WithMaxTokens(summaryMaxTokens))
+	if err != nil {
+		return "", fmt.Errorf("agent: summarise history: %w", err)
+	}
+	summary := strings.TrimSpace(msg.Content)
+	if summary == "" {
+		return "", errors.New("agent: summarise history: the model returned an empty summary")
+	}
+	return summary, nil
+}
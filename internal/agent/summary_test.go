@@ -0,0 +1,199 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/budget"
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// ---------------------------------------------------------------------------
+// Summarising dropped history
+// ---------------------------------------------------------------------------
+
+// summaryModel answers summary requests with a numbered summary and every
+// other call with "ok", recording the input of each.
+type summaryModel struct {
+	scriptedModel
+	mu        sync.Mutex
+	summaries [][]*schema.Message
+	queries   [][]*schema.Message
+	fail      bool
+}
+
+func newSummaryModel() *summaryModel {
+	m := &summaryModel{}
+	m.script = func(_ int, in []*schema.Message) *schema.Message {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if in[0].Content == summaryPrompt {
+			m.summaries = append(m.summaries, in)
+			return schema.AssistantMessage(fmt.Sprintf("- region: eu-west-1 (summary %d)", len(m.summaries)), nil)
+		}
+		m.queries = append(m.queries, in)
+		return schema.AssistantMessage("ok", nil)
+	}
+	return m
+}
+
+func (m *summaryModel) Generate(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	if m.fail && in[0].Content == summaryPrompt {
+		return nil, errors.New("model unavailable")
+	}
+	return m.scriptedModel.Generate(ctx, in, opts...)
+}
+
+// longTurn is the content of a message costing about 55 tokens with the
+// character heuristic, so a turn of two costs about 110.
+func longTurn(tag string) string {
+	return tag + " " + strings.Repeat("x", 200-len(tag)-1)
+}
+
+// seedTurns appends a question and answer per tag to the workspace history,
+// each pair in a turn with the tag as its ID.
+func seedTurns(t *testing.T, hs *store.SQLiteStore, dir string, tags ...string) {
+	t.Helper()
+	for _, tag := range tags {
+		ctx := store.WithTurn(context.Background(), tag)
+		if err := hs.Append(ctx, dir, "", store.RoleUser, longTurn("question "+tag)); err != nil {
+			t.Fatal(err)
+		}
+		if err := hs.Append(ctx, dir, "", store.RoleAssistant, longTurn("answer "+tag)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunSummarizesDroppedHistory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	const dir = "/ws/a"
+	hs, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+	seedTurns(t, hs, dir, "t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7")
+
+	m := newSummaryModel()
+	fixed := budget.EstimateMessages([]*schema.Message{schema.SystemMessage(systemPrompt), schema.UserMessage("next")})
+	a, err := New(ctx, &Config{
+		ChatModel: m,
+		History:   hs,
+		// Five turns fit the whole budget, so three are dropped; with room
+		// left for the summary only the newest one does.
+		MaxContextTokens:        fixed + summaryMaxTokens + 150,
+		SummarizeDroppedHistory: true,
+		MetricsRegistry:         prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	res, err := a.Run(ctx, QueryRequest{Message: "next", WorkspaceDir: dir})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !res.HistorySummarized || res.HistoryDropped != 14 {
+		t.Errorf("expected 14 messages dropped and summarised, got %d, %v", res.HistoryDropped, res.HistorySummarized)
+	}
+	if len(m.summaries) != 1 {
+		t.Fatalf("expected one summary request, got %d", len(m.summaries))
+	}
+	transcript := m.summaries[0][1].Content
+	if !strings.Contains(transcript, "user: question t0") || !strings.Contains(transcript, "assistant: answer t6") ||
+		strings.Contains(transcript, "t7") || strings.Contains(transcript, "Summary so far") {
+		t.Errorf("expected turns t0 to t6 summarised, got:\n%.300s", transcript)
+	}
+	in := m.queries[0]
+	if len(in) != 5 || in[0].Content != systemPrompt ||
+		in[1].Role != schema.System || in[1].Content != summaryHeader+"- region: eu-west-1 (summary 1)" ||
+		!strings.HasPrefix(in[2].Content, "question t7") || !strings.HasPrefix(in[3].Content, "answer t7") || in[4].Content != "next" {
+		t.Fatalf("expected [system, summary, t7 question, t7 answer, user], got %d messages: %v", len(in), in)
+	}
+	sum, ok, err := hs.HistorySummary(ctx, dir, "")
+	if err != nil || !ok || sum.Through != "t6" || sum.Content != "- region: eu-west-1 (summary 1)" {
+		t.Errorf("expected the summary cached through t6, got %+v, %v, %v", sum, ok, err)
+	}
+
+	// The next query drops the same turns: the cached summary is reused.
+	if res, err = a.Run(ctx, QueryRequest{Message: "next", WorkspaceDir: dir}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(m.summaries) != 1 || !res.HistorySummarized {
+		t.Errorf("expected the cached summary reused, got %d summary requests", len(m.summaries))
+	}
+	if in := m.queries[1]; in[1].Content != summaryHeader+"- region: eu-west-1 (summary 1)" {
+		t.Errorf("expected the cached summary injected, got %q", in[1].Content)
+	}
+
+	// Once more turns than SummarizeMinDropped messages fall outside it,
+	// the summary is regenerated from the cached one and the newer turns.
+	seedTurns(t, hs, dir, "t8", "t9", "t10")
+	if _, err = a.Run(ctx, QueryRequest{Message: "next", WorkspaceDir: dir}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(m.summaries) != 2 {
+		t.Fatalf("expected the summary regenerated, got %d summary requests", len(m.summaries))
+	}
+	transcript = m.summaries[1][1].Content
+	if !strings.HasPrefix(transcript, "Summary so far:\n- region: eu-west-1 (summary 1)\n") ||
+		!strings.Contains(transcript, "question t7") || strings.Contains(transcript, "question t6") {
+		t.Errorf("expected the cached summary merged with the turns after t6, got:\n%.300s", transcript)
+	}
+	if sum, _, _ := hs.HistorySummary(ctx, dir, ""); sum.Content != "- region: eu-west-1 (summary 2)" {
+		t.Errorf("expected the new summary cached, got %+v", sum)
+	}
+}
+
+func TestRunSummaryFailureDropsHistory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	const dir = "/ws/a"
+	hs, err := store.Open(ctx, ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+	seedTurns(t, hs, dir, "t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7")
+
+	m := newSummaryModel()
+	m.fail = true
+	fixed := budget.EstimateMessages([]*schema.Message{schema.SystemMessage(systemPrompt), schema.UserMessage("next")})
+	for _, summarize := range []bool{false, true} {
+		a, err := New(ctx, &Config{
+			ChatModel:               m,
+			History:                 hs,
+			MaxContextTokens:        fixed + summaryMaxTokens + 150,
+			SummarizeDroppedHistory: summarize,
+			MetricsRegistry:         prometheus.NewRegistry(),
+		})
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		res, err := a.Run(ctx, QueryRequest{Message: "next", WorkspaceDir: dir})
+		if err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if res.HistorySummarized || res.HistoryDropped == 0 {
+			t.Errorf("summarize=%v: expected history dropped without a summary, got %+v", summarize, res)
+		}
+	}
+	if len(m.summaries) != 0 {
+		t.Errorf("expected no summary recorded, got %d", len(m.summaries))
+	}
+	if _, ok, _ := hs.HistorySummary(ctx, dir, ""); ok {
+		t.Error("expected no summary cached after a failure")
+	}
+}
@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// maxTfvarsBytes caps the variable files included in the workspace context,
+// after redaction. Files that would cross it are left out.
+const maxTfvarsBytes = 20 * 1024 // 20 KiB
+
+// redactedValue replaces the value of a sensitive variable.
+const redactedValue = "«redacted»"
+
+// sensitiveVarKey matches variable and object key names whose values are
+// never shown to the model.
+var sensitiveVarKey = regexp.MustCompile(`(?i)(secret|password|token|key|credential)`)
+
+// isTfvarsFile reports whether name is a variable definitions file:
+// *.tfvars, including *.auto.tfvars, or *.tfvars.json.
+func isTfvarsFile(name string) bool {
+	return strings.HasSuffix(name, ".tfvars") || strings.HasSuffix(name, ".tfvars.json")
+}
+
+// redactTfvars returns the variable definitions file name with the value of
+// every variable or object key matching sensitiveVarKey replaced by
+// redactedValue, at any depth, and the number of values replaced. A file
+// that does not parse is an error, as its values cannot be told apart.
+func redactTfvars(name, content string) (string, int, error) {
+	if strings.HasSuffix(name, ".json") {
+		return redactTfvarsJSON(content)
+	}
+	file, diags := hclsyntax.ParseConfig([]byte(content), name, hcl.InitialPos)
+	if diags.HasErrors() {
+		return "", 0, fmt.Errorf("agent: failed to parse %s: %w", name, diags)
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return "", 0, fmt.Errorf("agent: failed to parse %s: unexpected body type %T", name, file.Body)
+	}
+
+	var ranges []hcl.Range
+	for _, attr := range body.Attributes {
+		if sensitiveVarKey.MatchString(attr.Name) {
+			ranges = append(ranges, attr.Expr.Range())
+			continue
+		}
+		ranges = append(ranges, sensitiveItems(attr.Expr)...)
+	}
+	// Replace from the end so earlier offsets stay valid.
+	slices.SortFunc(ranges, func(a, b hcl.Range) int { return b.Start.Byte - a.Start.Byte })
+	out := content
+	for _, r := range ranges {
+		out = out[:r.Start.Byte] + `"` + redactedValue + `"` + out[r.End.Byte:]
+	}
+	return out, len(ranges), nil
+}
+
+// sensitiveItems returns the ranges of the values under sensitive keys of
+// the objects in expr, looking through nested objects and tuples.
+func sensitiveItems(expr hclsyntax.Expression) []hcl.Range {
+	var out []hcl.Range
+	switch e := expr.(type) {
+	case *hclsyntax.ObjectConsExpr:
+		for _, item := range e.Items {
+			if sensitiveVarKey.MatchString(objectKey(item.KeyExpr)) {
+				out = append(out, item.ValueExpr.Range())
+				continue
+			}
+			out = append(out, sensitiveItems(item.ValueExpr)...)
+		}
+	case *hclsyntax.TupleConsExpr:
+		for _, el := range e.Exprs {
+			out = append(out, sensitiveItems(el)...)
+		}
+	}
+	return out
+}
+
+// objectKey returns the name of an object key, bare or quoted, or "" when
+// it is computed.
+func objectKey(expr hclsyntax.Expression) string {
+	if kw := hcl.ExprAsKeyword(expr); kw != "" {
+		return kw
+	}
+	v, diags := expr.Value(nil)
+	if diags.HasErrors() || v.IsNull() || !v.Type().Equals(cty.String) {
+		return ""
+	}
+	return v.AsString()
+}
+
+// redactTfvarsJSON is redactTfvars for a .tfvars.json file. The result is
+// re-encoded with sorted keys.
+func redactTfvarsJSON(content string) (string, int, error) {
+	var v any
+	if err := json.Unmarshal([]byte(content), &v); err != nil {
+		return "", 0, fmt.Errorf("agent: failed to parse tfvars JSON: %w", err)
+	}
+	n := redactJSONValue(v)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return "", 0, fmt.Errorf("agent: failed to encode tfvars JSON: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), n, nil
+}
+
+// redactJSONValue replaces, in place, the values under sensitive keys of
+// every object in v and returns how many it replaced.
+func redactJSONValue(v any) int {
+	n := 0
+	switch t := v.(type) {
+	case map[string]any:
+		for k, el := range t {
+			if sensitiveVarKey.MatchString(k) {
+				t[k] = redactedValue
+				n++
+				continue
+			}
+			n += redactJSONValue(el)
+		}
+	case []any:
+		for _, el := range t {
+			n += redactJSONValue(el)
+		}
+	}
+	return n
+}
@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/testutil"
+)
+
+// ---------------------------------------------------------------------------
+// Variable files in workspace context
+// ---------------------------------------------------------------------------
+
+func TestRedactTfvars(t *testing.T) {
+	t.Parallel()
+
+	src := `environment   = "prod"
+instance_type = "t3.large"
+db_password   = "hunter2"
+api_token     = "abc123"
+ssh_key = <<EOT
+line one
+line two
+EOT
+tags = {
+  Owner  = "platform"
+  "Secret" = "shh"
+}
+users = [
+  { name = "alice", credentials = { user = "alice", pass = "x" } },
+  { name = "bob" },
+]
+replicas = 3
+`
+	want := `environment   = "prod"
+instance_type = "t3.large"
+db_password   = "«redacted»"
+api_token     = "«redacted»"
+ssh_key = "«redacted»"
+tags = {
+  Owner  = "platform"
+  "Secret" = "«redacted»"
+}
+users = [
+  { name = "alice", credentials = "«redacted»" },
+  { name = "bob" },
+]
+replicas = 3
+`
+	got, n, err := redactTfvars("terraform.tfvars", src)
+	if err != nil {
+		t.Fatalf("redactTfvars: %v", err)
+	}
+	if got != want || n != 5 {
+		t.Errorf("unexpected redaction (%d values):\n%s\nwant:\n%s", n, got, want)
+	}
+
+	if _, _, err := redactTfvars("broken.tfvars", `password = "unterminated`); err == nil {
+		t.Error("expected an unparseable file to be an error")
+	}
+}
+
+func TestRedactTfvarsJSON(t *testing.T) {
+	t.Parallel()
+
+	got, n, err := redactTfvars("prod.tfvars.json",
+		`{"environment":"prod","replicas":3,"GITHUB_TOKEN":"ghp_x","db":{"host":"db.internal","master_password":"p"},"kms_key_ids":["a","b"]}`)
+	if err != nil {
+		t.Fatalf("redactTfvars: %v", err)
+	}
+	want := `{
+  "GITHUB_TOKEN": "«redacted»",
+  "db": {
+    "host": "db.internal",
+    "master_password": "«redacted»"
+  },
+  "environment": "prod",
+  "kms_key_ids": "«redacted»",
+  "replicas": 3
+}`
+	if got != want || n != 3 {
+		t.Errorf("unexpected redaction (%d values):\n%s\nwant:\n%s", n, got, want)
+	}
+}
+
+func TestBuildWorkspaceContextTfvars(t *testing.T) {
+	t.Parallel()
+
+	ws := testutil.NewWorkspace(t).
+		WithFile("main.tf", `variable "environment" {}`+"\n").
+		WithFile("terraform.tfvars", "environment = \"\"\nclient_secret = \"s3cr3t\"\n").
+		WithFile("env/prod.auto.tfvars.json", `{"environment":"prod","access_key":"AKIA"}`).
+		WithFile("bad.tfvars", "region = \n")
+	got, err := workspaceContextFor(context.Background(), ws.Dir(), nil, "", WorkspaceLimits{})
+	if err != nil {
+		t.Fatalf("buildWorkspaceContext: %v", err)
+	}
+	for _, want := range []string{
+		"### terraform.tfvars\n```hcl\nenvironment = \"\"\nclient_secret = \"«redacted»\"\n",
+		"### env/prod.auto.tfvars.json\n```json\n",
+		`"environment": "prod"`,
+		`"access_key": "«redacted»"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in context:\n%s", want, got)
+		}
+	}
+	for _, bad := range []string{"s3cr3t", "AKIA", "bad.tfvars"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("expected no %q in context:\n%s", bad, got)
+		}
+	}
+}
+
+func TestBuildWorkspaceContextTfvarsCap(t *testing.T) {
+	t.Parallel()
+
+	// Each file is a little over a third of the cap: two fit, the third
+	// does not, and .tf files are still read after it.
+	line := fmt.Sprintf("padding = %q\n", strings.Repeat("x", maxTfvarsBytes/3))
+	ws := testutil.NewWorkspace(t).
+		WithFile("a.tfvars", line).
+		WithFile("b.tfvars", line).
+		WithFile("c.tfvars", line).
+		WithFile("d.tf", "# after\n")
+	got, err := workspaceContextFor(context.Background(), ws.Dir(), nil, "", WorkspaceLimits{})
+	if err != nil {
+		t.Fatalf("buildWorkspaceContext: %v", err)
+	}
+	if !strings.Contains(got, "### a.tfvars\n") || !strings.Contains(got, "### b.tfvars\n") ||
+		strings.Contains(got, "### c.tfvars\n") || !strings.Contains(got, "### d.tf\n") {
+		t.Errorf("expected a.tfvars, b.tfvars, and d.tf within the variables cap, got:\n%.400s", got)
+	}
+}
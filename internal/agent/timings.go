@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// Timings is where the time of one query went, so a slow answer can be
+// explained: building the context, waiting on the model, running tools, and
+// parsing and writing a file envelope. Phases that did not run are zero.
+type Timings struct {
+	// Context is building the model input: loading history, retrieving
+	// documentation, and reading the workspace.
+	Context time.Duration
+	// FirstToken is the time from the first model call to the first
+	// response text, tool calls included. Zero when no text arrived.
+	FirstToken time.Duration
+	// Model is the time spent in the ReAct loop less the tool calls: the
+	// model thinking and streaming.
+	Model time.Duration
+	// Tools is the total time of the tool calls.
+	Tools time.Duration
+	// ToolCalls lists each tool call, in completion order.
+	ToolCalls []ToolTiming
+	// Parse is parsing the answer as a file envelope.
+	Parse time.Duration
+	// Apply is writing the envelope's files to the workspace.
+	Apply time.Duration
+	// Total is the whole query, from Run being called.
+	Total time.Duration
+}
+
+// ToolTiming is the duration of one tool call.
+type ToolTiming struct {
+	// Name is the tool name, e.g. "terraform_plan".
+	Name string
+	// Elapsed is how long the call took.
+	Elapsed time.Duration
+}
+
+// record returns the timings in milliseconds for the history store; nil
+// when t is nil.
+func (t *Timings) record() *store.Timings {
+	if t == nil {
+		return nil
+	}
+	return &store.Timings{
+		ContextMs:    t.Context.Milliseconds(),
+		FirstTokenMs: t.FirstToken.Milliseconds(),
+		ModelMs:      t.Model.Milliseconds(),
+		ToolsMs:      t.Tools.Milliseconds(),
+		ToolCalls:    len(t.ToolCalls),
+		ParseMs:      t.Parse.Milliseconds(),
+		ApplyMs:      t.Apply.Milliseconds(),
+		TotalMs:      t.Total.Milliseconds(),
+	}
+}
+
+// logAttrs returns the timings as log attributes.
+func (t *Timings) logAttrs() []any {
+	return []any{
+		slog.Duration("context", t.Context),
+		slog.Duration("first_token", t.FirstToken),
+		slog.Duration("model", t.Model),
+		slog.Duration("tools", t.Tools),
+		slog.Int("tool_calls", len(t.ToolCalls)),
+		slog.Duration("parse", t.Parse),
+		slog.Duration("apply", t.Apply),
+		slog.Duration("total", t.Total),
+	}
+}
+
+// observe records the phases that ran in the phase duration histogram,
+// each tool call as one "tool" observation.
+func (m *agentMetrics) observe(t *Timings) {
+	h := m.phaseDurationSeconds
+	h.WithLabelValues("context").Observe(t.Context.Seconds())
+	if t.Model > 0 {
+		h.WithLabelValues("model").Observe(t.Model.Seconds())
+	}
+	if t.FirstToken > 0 {
+		h.WithLabelValues("first_token").Observe(t.FirstToken.Seconds())
+	}
+	for _, c := range t.ToolCalls {
+		h.WithLabelValues("tool").Observe(c.Elapsed.Seconds())
+	}
+	if t.Parse > 0 {
+		h.WithLabelValues("parse").Observe(t.Parse.Seconds())
+	}
+	if t.Apply > 0 {
+		h.WithLabelValues("apply").Observe(t.Apply.Seconds())
+	}
+	h.WithLabelValues("total").Observe(t.Total.Seconds())
+}
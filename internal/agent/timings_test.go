@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// sleepTool is a fake_state tool that takes d to run.
+type sleepTool struct{ d time.Duration }
+
+func (sleepTool) Info(_ context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "fake_state", Desc: "fake state tool"}, nil
+}
+
+func (s sleepTool) InvokableRun(ctx context.Context, _ string, _ ...tool.Option) (string, error) {
+	select {
+	case <-time.After(s.d):
+		return "ok", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Per-phase timings
+// ---------------------------------------------------------------------------
+
+func TestRunTimings(t *testing.T) {
+	t.Parallel()
+
+	const (
+		modelDelay = 20 * time.Millisecond
+		toolDelay  = 50 * time.Millisecond
+	)
+	envelope := `{"files":[{"path":"main.tf","content":"# main"}],"summary":"Wrote 1 file."}`
+	m := &scriptedModel{script: func(turn int, _ []*schema.Message) *schema.Message {
+		time.Sleep(modelDelay)
+		if turn == 0 {
+			return toolCall(turn, `{}`)
+		}
+		return schema.AssistantMessage(envelope, nil)
+	}}
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+	a, err := New(context.Background(), &Config{
+		ChatModel:       m,
+		Tools:           []tool.BaseTool{sleepTool{d: toolDelay}},
+		History:         hs,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	dir := t.TempDir()
+	res, err := a.Run(context.Background(), QueryRequest{Message: "make a vpc", WorkspaceDir: dir})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	tm := res.Timings
+	if tm == nil {
+		t.Fatal("expected timings on the result")
+	}
+	if len(tm.ToolCalls) != 1 || tm.ToolCalls[0].Name != "fake_state" || tm.ToolCalls[0].Elapsed < toolDelay {
+		t.Errorf("expected one fake_state call of at least %v, got %+v", toolDelay, tm.ToolCalls)
+	}
+	if tm.Tools < toolDelay {
+		t.Errorf("expected tool time of at least %v, got %v", toolDelay, tm.Tools)
+	}
+	// Both model turns count as model time; the tool call does not.
+	if tm.Model < 2*modelDelay {
+		t.Errorf("expected model time of at least %v, got %v", 2*modelDelay, tm.Model)
+	}
+	// The first text comes after the tool call and the second model turn.
+	if tm.FirstToken < tm.Tools+2*modelDelay {
+		t.Errorf("expected the first token after %v, got %v", tm.Tools+2*modelDelay, tm.FirstToken)
+	}
+	if tm.Apply <= 0 {
+		t.Errorf("expected apply time for the written envelope, got %v", tm.Apply)
+	}
+	if sum := tm.Context + tm.Model + tm.Tools + tm.Parse + tm.Apply; sum > tm.Total {
+		t.Errorf("expected the phases (%v) to fit in the total %v", sum, tm.Total)
+	}
+
+	// Every phase that ran is observed once.
+	if n := testutil.CollectAndCount(a.metrics.phaseDurationSeconds); n != 7 {
+		t.Errorf("expected 7 phase series, got %d", n)
+	}
+
+	// The timings are stored with the assistant message.
+	rows, err := hs.Recent(context.Background(), dir, "", 10)
+	if err != nil {
+		t.Fatalf("Recent: %v", err)
+	}
+	last := rows[len(rows)-1]
+	if last.Role != store.RoleAssistant || last.Timings == nil {
+		t.Fatalf("expected timings stored with the assistant message, got %+v", last)
+	}
+	if last.Timings.ToolCalls != 1 || last.Timings.ToolsMs < toolDelay.Milliseconds() || last.Timings.TotalMs < last.Timings.ToolsMs {
+		t.Errorf("unexpected stored timings %+v", last.Timings)
+	}
+	if rows[0].Timings != nil {
+		t.Errorf("expected no timings on the user message, got %+v", rows[0].Timings)
+	}
+}
+
+func TestRunTimingsOnFailure(t *testing.T) {
+	t.Parallel()
+
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: []*schema.Message{schema.AssistantMessage("unused", nil)}},
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	// Continuing without history fails before the model is called.
+	res, err := a.Run(context.Background(), QueryRequest{Options: QueryOptions{Continue: true}})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if res.Timings == nil || res.Timings.Total <= 0 || res.Timings.Model != 0 {
+		t.Errorf("expected a total and no model time, got %+v", res.Timings)
+	}
+}
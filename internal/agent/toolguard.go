@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/compose"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+)
+
+// DefaultMaxToolIterations is the number of tool calls allowed per query when
+// Config.MaxToolIterations is zero.
+const DefaultMaxToolIterations = 10
+
+// identicalCallLimit is the number of consecutive identical tool calls (same
+// name and arguments) that is treated as a loop.
+const identicalCallLimit = 3
+
+// Reasons recorded when the tool guard stops a run. Used as metric labels.
+const (
+	// guardReasonIterationLimit means the per-query tool call cap was exceeded.
+	guardReasonIterationLimit = "iteration_limit"
+	// guardReasonIterationLimitIgnored means the model kept calling tools
+	// after the cap was reported to it, and the run was aborted.
+	guardReasonIterationLimitIgnored = "iteration_limit_ignored"
+	// guardReasonLoopDetected means the model repeated an identical call.
+	guardReasonLoopDetected = "loop_detected"
+)
+
+// ErrToolIterationLimit is returned (wrapped) when the model keeps calling
+// tools after it has been told the iteration limit was reached.
+var ErrToolIterationLimit = errors.New("agent: tool iteration limit reached")
+
+// ErrToolLoopDetected is returned (wrapped) when the model makes the same
+// tool call with the same arguments several times in a row.
+var ErrToolLoopDetected = errors.New("agent: repeated identical tool call detected")
+
+// iterationLimitResult is the tool result returned for the first call over
+// the cap. It gives the model one chance to answer without more tools before
+// the run is aborted.
+const iterationLimitResult = "iteration limit reached: no more tool calls are allowed for this request. " +
+	"Answer the user now using only the information you already have."
+
+// toolCallRecord is one entry in a query's tool call history.
+type toolCallRecord struct {
+	// name is the tool name.
+	name string
+	// argsHash is a short hash of the raw JSON arguments.
+	argsHash string
+}
+
+// toolGuard tracks tool calls for a single query. It is stored in the
+// request context so concurrent queries on the same agent never share state.
+type toolGuard struct {
+	// mu guards all fields below; eino may run tool calls in parallel.
+	mu sync.Mutex
+	// maxIterations is the number of tool calls allowed.
+	maxIterations int
+	// history is every tool call made so far, in order.
+	history []toolCallRecord
+	// limitNotified is true once the model has been sent iterationLimitResult.
+	limitNotified bool
+}
+
+// toolGuardKey is the context key under which the per-query toolGuard lives.
+type toolGuardKey struct{}
+
+// withToolGuard returns a context carrying a fresh toolGuard.
+func withToolGuard(ctx context.Context, maxIterations int) context.Context {
+	return context.WithValue(ctx, toolGuardKey{}, &toolGuard{maxIterations: maxIterations})
+}
+
+// toolGuardFrom returns the toolGuard stored in ctx, or nil.
+func toolGuardFrom(ctx context.Context) *toolGuard {
+	g, _ := ctx.Value(toolGuardKey{}).(*toolGuard)
+	return g
+}
+
+// hashArgs returns a short, stable hash of raw tool arguments.
+func hashArgs(args string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(args)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// summary returns a compact, deterministic description of the call history,
+// e.g. "terraform_plan x1, terraform_state x5", for logs and user messages.
+func (g *toolGuard) summary() string {
+	counts := make(map[string]int)
+	for _, c := range g.history {
+		counts[c.name]++
+	}
+	names := make([]string, 0, len(counts))
+	for n := range counts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, n := range names {
+		parts = append(parts, fmt.Sprintf("%s x%d", n, counts[n]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// guardVerdict is the outcome of recording a tool call.
+type guardVerdict int
+
+const (
+	// verdictAllow lets the call run normally.
+	verdictAllow guardVerdict = iota
+	// verdictLimitResult short-circuits the call with iterationLimitResult.
+	verdictLimitResult
+	// verdictAbortLimit aborts the run: the cap was ignored.
+	verdictAbortLimit
+	// verdictAbortLoop aborts the run: identical calls repeated.
+	verdictAbortLoop
+)
+
+// record registers a call and decides whether it may proceed.
+func (g *toolGuard) record(name, args string) guardVerdict {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rec := toolCallRecord{name: name, argsHash: hashArgs(args)}
+	g.history = append(g.history, rec)
+
+	if n := len(g.history); n >= identicalCallLimit {
+		identical := true
+		for _, prev := range g.history[n-identicalCallLimit : n-1] {
+			if prev != rec {
+				identical = false
+				break
+			}
+		}
+		if identical {
+			return verdictAbortLoop
+		}
+	}
+
+	if len(g.history) > g.maxIterations {
+		if g.limitNotified {
+			return verdictAbortLimit
+		}
+		g.limitNotified = true
+		return verdictLimitResult
+	}
+	return verdictAllow
+}
+
+// toolGuardMiddleware enforces the per-query tool call cap and loop
+// detection around every invokable tool call. It is a no-op when the context
+// carries no toolGuard (e.g. the tool node is used outside Run).
+func (a *TerraformAgent) toolGuardMiddleware() compose.ToolMiddleware {
+	return compose.ToolMiddleware{
+		Invokable: func(next compose.InvokableToolEndpoint) compose.InvokableToolEndpoint {
+			return func(ctx context.Context, in *compose.ToolInput) (*compose.ToolOutput, error) {
+				g := toolGuardFrom(ctx)
+				if g == nil {
+					return next(ctx, in)
+				}
+
+				switch g.record(in.Name, in.Arguments) {
+				case verdictLimitResult:
+					a.logGuardTrip(ctx, g, guardReasonIterationLimit, in.Name)
+					return &compose.ToolOutput{Result: iterationLimitResult}, nil
+				case verdictAbortLimit:
+					a.logGuardTrip(ctx, g, guardReasonIterationLimitIgnored, in.Name)
+					return nil, fmt.Errorf("%w (%d calls)", ErrToolIterationLimit, g.maxIterations)
+				case verdictAbortLoop:
+					a.logGuardTrip(ctx, g, guardReasonLoopDetected, in.Name)
+					return nil, fmt.Errorf("%w: %s called %d times with the same arguments",
+						ErrToolLoopDetected, in.Name, identicalCallLimit)
+				}
+				return next(ctx, in)
+			}
+		},
+	}
+}
+
+// logGuardTrip increments the trip counter and logs the call history.
+func (a *TerraformAgent) logGuardTrip(ctx context.Context, g *toolGuard, reason, tool string) {
+	a.metrics.toolGuardTripsTotal.WithLabelValues(reason).Inc()
+
+	g.mu.Lock()
+	calls := len(g.history)
+	history := g.summary()
+	g.mu.Unlock()
+
+	logging.FromContext(ctx).Warn("agent: tool guard tripped",
+		slog.String("reason", reason),
+		slog.String("tool", tool),
+		slog.Int("calls", calls),
+		slog.Int("max_tool_iterations", g.maxIterations),
+		slog.String("tool_history", history),
+	)
+}
+
+// guardMessage returns the explanatory text streamed to the user when the
+// tool guard aborts a run, or "" if err is not a tool guard error.
+func guardMessage(ctx context.Context, err error) string {
+	var history string
+	if g := toolGuardFrom(ctx); g != nil {
+		g.mu.Lock()
+		history = g.summary()
+		g.mu.Unlock()
+	}
+	switch {
+	case errors.Is(err, ErrToolLoopDetected):
+		return "Stopped: the assistant repeated the same tool call without making progress " +
+			"(tool calls: " + history + "). Try rephrasing the request or giving more specific details."
+	case errors.Is(err, ErrToolIterationLimit):
+		return "Stopped: the assistant reached the tool call limit for a single request " +
+			"(tool calls: " + history + "). Try splitting the request into smaller steps."
+	}
+	return ""
+}
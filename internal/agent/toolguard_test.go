@@ -0,0 +1,245 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// ---------------------------------------------------------------------------
+// Fakes: scripted model and counting tool
+// ---------------------------------------------------------------------------
+
+// scriptedModel is a ToolCallingChatModel whose reply to each turn is
+// produced by script. turn counts model calls from zero.
+type scriptedModel struct {
+	mu     sync.Mutex
+	turn   int
+	script func(turn int, input []*schema.Message) *schema.Message
+}
+
+func (m *scriptedModel) next(input []*schema.Message) *schema.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg := m.script(m.turn, input)
+	m.turn++
+	return msg
+}
+
+func (m *scriptedModel) Generate(_ context.Context, input []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	return m.next(input), nil
+}
+
+func (m *scriptedModel) Stream(_ context.Context, input []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{m.next(input)}), nil
+}
+
+func (m *scriptedModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// countingTool is an InvokableTool that records how often it ran.
+type countingTool struct {
+	calls atomic.Int32
+}
+
+func (t *countingTool) Info(_ context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: "fake_state", Desc: "fake state tool"}, nil
+}
+
+func (t *countingTool) InvokableRun(_ context.Context, _ string, _ ...tool.Option) (string, error) {
+	t.calls.Add(1)
+	return "aws_s3_bucket.logs", nil
+}
+
+// toolCall builds an assistant message requesting one fake_state call.
+func toolCall(turn int, args string) *schema.Message {
+	return schema.AssistantMessage("", []schema.ToolCall{{
+		ID:       fmt.Sprintf("call-%d", turn),
+		Type:     "function",
+		Function: schema.FunctionCall{Name: "fake_state", Arguments: args},
+	}})
+}
+
+// lastIsLimitResult reports whether the most recent message is the terminal
+// iteration-limit tool result.
+func lastIsLimitResult(input []*schema.Message) bool {
+	last := input[len(input)-1]
+	return last.Role == schema.Tool && last.Content == iterationLimitResult
+}
+
+// newGuardTestAgent builds an agent wired to the scripted model and counting
+// tool with an isolated metrics registry.
+func newGuardTestAgent(t *testing.T, m *scriptedModel, ct *countingTool, maxIter int) *TerraformAgent {
+	t.Helper()
+	a, err := New(context.Background(), &Config{
+		ChatModel:         m,
+		Tools:             []tool.BaseTool{ct},
+		MaxToolIterations: maxIter,
+		MetricsRegistry:   prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return a
+}
+
+// ---------------------------------------------------------------------------
+// Tool guard behaviour through Run
+// ---------------------------------------------------------------------------
+
+func TestToolGuard_IdenticalCallsShortCircuit(t *testing.T) {
+	t.Parallel()
+
+	m := &scriptedModel{script: func(turn int, _ []*schema.Message) *schema.Message {
+		return toolCall(turn, `{"subcommand":"list"}`)
+	}}
+	ct := &countingTool{}
+	a := newGuardTestAgent(t, m, ct, 10)
+
+	var out strings.Builder
+	res, err := a.Run(context.Background(), QueryRequest{Message: "what is in state?", Output: &out})
+	if err != nil {
+		t.Fatalf("Run: expected the guard message, got error %v", err)
+	}
+	if !res.ToolLimitReached {
+		t.Error("expected ToolLimitReached")
+	}
+	if !strings.Contains(out.String(), "repeated the same tool call") {
+		t.Errorf("expected loop explanation, got %q", out.String())
+	}
+	if got := ct.calls.Load(); got != identicalCallLimit-1 {
+		t.Errorf("expected the tool to run %d times, ran %d", identicalCallLimit-1, got)
+	}
+	if got := testutil.ToFloat64(a.metrics.toolGuardTripsTotal.WithLabelValues(guardReasonLoopDetected)); got != 1 {
+		t.Errorf("loop_detected counter: expected 1, got %v", got)
+	}
+}
+
+func TestToolGuard_IterationLimitTerminalResult(t *testing.T) {
+	t.Parallel()
+
+	// Distinct arguments each turn so only the cap applies. Once the model
+	// sees the terminal result it answers.
+	m := &scriptedModel{script: func(turn int, input []*schema.Message) *schema.Message {
+		if lastIsLimitResult(input) {
+			return schema.AssistantMessage("final answer from what I have", nil)
+		}
+		return toolCall(turn, fmt.Sprintf(`{"subcommand":"show","address":"r%d"}`, turn))
+	}}
+	ct := &countingTool{}
+	a := newGuardTestAgent(t, m, ct, 3)
+
+	var out strings.Builder
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "inspect everything", Output: &out}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out.String() != "final answer from what I have" {
+		t.Errorf("expected model's final answer, got %q", out.String())
+	}
+	if got := ct.calls.Load(); got != 3 {
+		t.Errorf("expected the tool to run 3 times, ran %d", got)
+	}
+	if got := testutil.ToFloat64(a.metrics.toolGuardTripsTotal.WithLabelValues(guardReasonIterationLimit)); got != 1 {
+		t.Errorf("iteration_limit counter: expected 1, got %v", got)
+	}
+}
+
+func TestToolGuard_IterationLimitIgnoredAborts(t *testing.T) {
+	t.Parallel()
+
+	m := &scriptedModel{script: func(turn int, _ []*schema.Message) *schema.Message {
+		return toolCall(turn, fmt.Sprintf(`{"subcommand":"show","address":"r%d"}`, turn))
+	}}
+	ct := &countingTool{}
+	a := newGuardTestAgent(t, m, ct, 2)
+
+	var out strings.Builder
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "inspect everything", Output: &out}); err != nil {
+		t.Fatalf("Run: expected the guard message, got error %v", err)
+	}
+	if !strings.Contains(out.String(), "tool call limit") {
+		t.Errorf("expected limit explanation, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "fake_state x4") {
+		t.Errorf("expected call history summary, got %q", out.String())
+	}
+	if got := ct.calls.Load(); got != 2 {
+		t.Errorf("expected the tool to run 2 times, ran %d", got)
+	}
+	// The cap is reported once, then ignoring it aborts the run.
+	if got := testutil.ToFloat64(a.metrics.toolGuardTripsTotal.WithLabelValues(guardReasonIterationLimit)); got != 1 {
+		t.Errorf("iteration_limit counter: expected 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(a.metrics.toolGuardTripsTotal.WithLabelValues(guardReasonIterationLimitIgnored)); got != 1 {
+		t.Errorf("iteration_limit_ignored counter: expected 1, got %v", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// toolGuard.record — pure logic
+// ---------------------------------------------------------------------------
+
+func TestToolGuard_Record(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		max   int
+		calls [][2]string // name, args
+		want  []guardVerdict
+	}{
+		{
+			name:  "distinct calls under cap",
+			max:   5,
+			calls: [][2]string{{"a", "1"}, {"a", "2"}, {"b", "1"}},
+			want:  []guardVerdict{verdictAllow, verdictAllow, verdictAllow},
+		},
+		{
+			name:  "identical calls trip on third",
+			max:   5,
+			calls: [][2]string{{"a", "1"}, {"a", "1"}, {"a", "1"}},
+			want:  []guardVerdict{verdictAllow, verdictAllow, verdictAbortLoop},
+		},
+		{
+			name:  "interleaved repeats are not a loop",
+			max:   5,
+			calls: [][2]string{{"a", "1"}, {"b", "1"}, {"a", "1"}, {"b", "1"}},
+			want:  []guardVerdict{verdictAllow, verdictAllow, verdictAllow, verdictAllow},
+		},
+		{
+			name:  "whitespace-only argument differences are identical",
+			max:   5,
+			calls: [][2]string{{"a", "{}"}, {"a", " {}"}, {"a", "{} "}},
+			want:  []guardVerdict{verdictAllow, verdictAllow, verdictAbortLoop},
+		},
+		{
+			name:  "cap then abort",
+			max:   1,
+			calls: [][2]string{{"a", "1"}, {"a", "2"}, {"a", "3"}},
+			want:  []guardVerdict{verdictAllow, verdictLimitResult, verdictAbortLimit},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			g := &toolGuard{maxIterations: tc.max}
+			for i, c := range tc.calls {
+				if got := g.record(c[0], c[1]); got != tc.want[i] {
+					t.Errorf("call %d (%s %s): expected verdict %d, got %d", i, c[0], c[1], tc.want[i], got)
+				}
+			}
+		})
+	}
+}
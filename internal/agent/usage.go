@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// usageMeter accumulates the token usage reported by every model call made
+// while answering one query. Like toolGuard it lives in the request context
+// so concurrent queries never share counts.
+type usageMeter struct {
+	// mu guards all fields below.
+	mu sync.Mutex
+	// promptTokens is the summed prompt token count.
+	promptTokens int
+	// completionTokens is the summed completion token count.
+	completionTokens int
+	// reported is true once any model call returned usage metadata.
+	reported bool
+}
+
+// usageMeterKey is the context key under which the per-query usageMeter lives.
+type usageMeterKey struct{}
+
+// withUsageMeter returns a context carrying a fresh usageMeter.
+func withUsageMeter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, usageMeterKey{}, &usageMeter{})
+}
+
+// usageMeterFrom returns the usageMeter stored in ctx, or nil.
+func usageMeterFrom(ctx context.Context) *usageMeter {
+	m, _ := ctx.Value(usageMeterKey{}).(*usageMeter)
+	return m
+}
+
+// add records prompt and completion token counts.
+func (m *usageMeter) add(prompt, completion int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.promptTokens += prompt
+	m.completionTokens += completion
+	m.reported = true
+}
+
+// totals returns the accumulated counts and whether any usage was reported.
+func (m *usageMeter) totals() (prompt, completion int, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.promptTokens, m.completionTokens, m.reported
+}
+
+// meteredModel wraps a ToolCallingChatModel and records the usage metadata of
+// every response into the usageMeter carried by the call's context. Calls
+// without a meter in the context pass straight through.
+type meteredModel struct {
+	// inner is the wrapped model.
+	inner model.ToolCallingChatModel
+}
+
+// Generate implements model.BaseChatModel.
+func (m *meteredModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	msg, err := m.inner.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // transparent wrapper
+	}
+	if meter := usageMeterFrom(ctx); meter != nil && msg != nil && msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil {
+		meter.add(msg.ResponseMeta.Usage.PromptTokens, msg.ResponseMeta.Usage.CompletionTokens)
+	}
+	return msg, nil
+}
+
+// Stream implements model.BaseChatModel. Providers differ in whether usage
+// arrives once on the final chunk or cumulatively on several chunks, so the
+// per-field maximum is taken (matching schema.ConcatMessages) and only the
+// increase over the previous maximum is added to the meter.
+func (m *meteredModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, err := m.inner.Stream(ctx, input, opts...)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // transparent wrapper
+	}
+	meter := usageMeterFrom(ctx)
+	if meter == nil {
+		return sr, nil
+	}
+	var maxPrompt, maxCompletion int
+	return schema.StreamReaderWithConvert(sr, func(msg *schema.Message) (*schema.Message, error) {
+		if msg != nil && msg.ResponseMeta != nil && msg.ResponseMeta.Usage != nil {
+			u := msg.ResponseMeta.Usage
+			dp, dc := max(u.PromptTokens-maxPrompt, 0), max(u.CompletionTokens-maxCompletion, 0)
+			maxPrompt, maxCompletion = max(maxPrompt, u.PromptTokens), max(maxCompletion, u.CompletionTokens)
+			meter.add(dp, dc)
+		}
+		return msg, nil
+	}), nil
+}
+
+// WithTools implements model.ToolCallingChatModel, keeping the returned
+// model metered.
+func (m *meteredModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	inner, err := m.inner.WithTools(tools)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // transparent wrapper
+	}
+	return &meteredModel{inner: inner}, nil
+}
+
+// GetType reports the wrapped model's component type so traces keep showing
+// the real backend name.
+func (m *meteredModel) GetType() string {
+	if t, ok := components.GetType(m.inner); ok {
+		return t
+	}
+	return "MeteredChatModel"
+}
@@ -0,0 +1,188 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/54b3r/tfai-go/internal/store"
+)
+
+// withUsage attaches token usage metadata to msg.
+func withUsage(msg *schema.Message, prompt, completion int) *schema.Message {
+	msg.ResponseMeta = &schema.ResponseMeta{Usage: &schema.TokenUsage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}}
+	return msg
+}
+
+// chunkModel streams a fixed sequence of chunks.
+type chunkModel struct {
+	chunks []*schema.Message
+}
+
+func (m *chunkModel) Generate(_ context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	return schema.ConcatMessages(m.chunks)
+}
+
+func (m *chunkModel) Stream(_ context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray(m.chunks), nil
+}
+
+func (m *chunkModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// ---------------------------------------------------------------------------
+// Usage persisted through Query
+// ---------------------------------------------------------------------------
+
+func TestQueryPersistsUsageAcrossReActSteps(t *testing.T) {
+	t.Parallel()
+
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+
+	m := &scriptedModel{script: func(turn int, _ []*schema.Message) *schema.Message {
+		if turn == 0 {
+			return withUsage(toolCall(turn, `{"subcommand":"list"}`), 100, 10)
+		}
+		return withUsage(schema.AssistantMessage("two buckets", nil), 150, 20)
+	}}
+	a, err := New(context.Background(), &Config{
+		ChatModel:       m,
+		Tools:           []tool.BaseTool{&countingTool{}},
+		History:         hs,
+		MetricsRegistry: prometheus.NewRegistry(),
+		ProviderName:    "openai",
+		ModelName:       "gpt-4o",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var out strings.Builder
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "what is in state?", WorkspaceDir: "/ws/a", Output: &out}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	records, err := hs.UsageRecords(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("UsageRecords: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("expected 1 assistant record, got %d", len(records))
+	}
+	want := store.Usage{Provider: "openai", Model: "gpt-4o", PromptTokens: 250, CompletionTokens: 30}
+	if !records[0].Tracked || records[0].Usage != want {
+		t.Errorf("expected tracked usage %+v, got %+v", want, records[0])
+	}
+	prompt := testutil.ToFloat64(a.metrics.promptTokensTotal.WithLabelValues("openai", "gpt-4o"))
+	completion := testutil.ToFloat64(a.metrics.completionTokensTotal.WithLabelValues("openai", "gpt-4o"))
+	if prompt != 250 || completion != 30 {
+		t.Errorf("expected the token counters at 250 and 30, got %v and %v", prompt, completion)
+	}
+}
+
+func TestQueryWithoutReportedUsageIsUntracked(t *testing.T) {
+	t.Parallel()
+
+	hs, err := store.Open(context.Background(), ":memory:")
+	if err != nil {
+		t.Fatalf("store.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+
+	a, err := New(context.Background(), &Config{
+		ChatModel:       &chunkModel{chunks: []*schema.Message{schema.AssistantMessage("hello", nil)}},
+		History:         hs,
+		MetricsRegistry: prometheus.NewRegistry(),
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := a.Run(context.Background(), QueryRequest{Message: "hi", WorkspaceDir: "/ws/a"}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	records, err := hs.UsageRecords(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("UsageRecords: %v", err)
+	}
+	if len(records) != 1 || records[0].Tracked {
+		t.Errorf("expected 1 untracked record, got %+v", records)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// meteredModel — streaming usage
+// ---------------------------------------------------------------------------
+
+func TestMeteredModelStreamUsage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		chunks         []*schema.Message
+		wantPrompt     int
+		wantCompletion int
+		wantReported   bool
+	}{
+		{
+			name: "usage on final chunk only",
+			chunks: []*schema.Message{
+				schema.AssistantMessage("a", nil),
+				withUsage(schema.AssistantMessage("b", nil), 40, 7),
+			},
+			wantPrompt: 40, wantCompletion: 7, wantReported: true,
+		},
+		{
+			name: "cumulative usage on every chunk",
+			chunks: []*schema.Message{
+				withUsage(schema.AssistantMessage("a", nil), 40, 1),
+				withUsage(schema.AssistantMessage("b", nil), 40, 2),
+				withUsage(schema.AssistantMessage("c", nil), 40, 3),
+			},
+			wantPrompt: 40, wantCompletion: 3, wantReported: true,
+		},
+		{
+			name:   "no usage",
+			chunks: []*schema.Message{schema.AssistantMessage("a", nil)},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := withUsageMeter(context.Background())
+			mm := &meteredModel{inner: &chunkModel{chunks: tc.chunks}}
+			sr, err := mm.Stream(ctx, nil)
+			if err != nil {
+				t.Fatalf("Stream: %v", err)
+			}
+			for {
+				if _, err := sr.Recv(); err != nil {
+					break
+				}
+			}
+			prompt, completion, reported := usageMeterFrom(ctx).totals()
+			if prompt != tc.wantPrompt || completion != tc.wantCompletion || reported != tc.wantReported {
+				t.Errorf("expected (%d, %d, %v), got (%d, %d, %v)",
+					tc.wantPrompt, tc.wantCompletion, tc.wantReported, prompt, completion, reported)
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+
+	"github.com/54b3r/tfai-go/internal/logging"
 )
 
 // secretEnvKeys lists environment variable names whose values must never be
@@ -21,6 +23,7 @@ var secretEnvKeys = map[string]bool{
 	"EMBEDDING_API_KEY":     true,
 	"QDRANT_API_KEY":        true,
 	"TFAI_API_KEY":          true,
+	"TFAI_ADMIN_API_KEY":    true,
 	"LANGFUSE_PUBLIC_KEY":   true,
 	"LANGFUSE_SECRET_KEY":   true,
 	"AWS_SECRET_ACCESS_KEY": true,
@@ -28,8 +31,9 @@ var secretEnvKeys = map[string]bool{
 }
 
 // LogCommandStart emits a structured audit log entry when a CLI command begins.
-// It records the command name, config file source, and sanitised environment.
-func LogCommandStart(log *slog.Logger, command string, configPath string) {
+// It records the command name, config file source, and sanitised environment,
+// through the logger of ctx (see logging.FromContext).
+func LogCommandStart(ctx context.Context, command string, configPath string) {
 	attrs := []slog.Attr{
 		slog.String("command", command),
 		slog.String("config_file", sanitiseConfigPath(configPath)),
@@ -45,7 +49,36 @@ func LogCommandStart(log *slog.Logger, command string, configPath string) {
 		}
 	}
 
-	log.LogAttrs(context.TODO(), slog.LevelInfo, "audit: command start", attrs...)
+	logging.FromContext(ctx).LogAttrs(ctx, slog.LevelInfo, "audit: command start", attrs...)
+}
+
+// Event is a change made on behalf of a client, such as a file written
+// through the HTTP API.
+type Event struct {
+	// Action names the change in the log message, e.g. "file write".
+	Action string
+	// Kind classifies the change: "file_write" or "file_delete".
+	Kind string
+	// Path is the file or directory changed.
+	Path string
+	// Actor identifies the client, e.g. its remote address.
+	Actor string
+	// Level is the level the entry is logged at. The zero value is
+	// slog.LevelInfo; changes that reach real infrastructure use
+	// slog.LevelWarn.
+	Level slog.Level
+}
+
+// LogEvent emits a structured audit log entry for e, followed by attrs,
+// through the logger of ctx. On the server path that logger carries the
+// request ID, which ties the entry to the request that made the change.
+func LogEvent(ctx context.Context, e Event, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{
+		slog.String("event", e.Kind),
+		slog.String("path", e.Path),
+		slog.String("actor", e.Actor),
+	}, attrs...)
+	logging.FromContext(ctx).LogAttrs(ctx, e.Level, "audit: "+e.Action, attrs...)
 }
 
 // auditEntry defines an env var to include in the audit log.
@@ -80,7 +113,9 @@ var auditKeys = []auditEntry{
 	{"QDRANT_COLLECTION", false},
 	{"QDRANT_API_KEY", true},
 	{"TFAI_API_KEY", true},
+	{"TFAI_ADMIN_API_KEY", true},
 	{"TFAI_HISTORY_DB", false},
+	{"TFAI_ALLOW_APPLY", false},
 	{"LOG_LEVEL", false},
 	{"LOG_FORMAT", false},
 	{"LANGFUSE_PUBLIC_KEY", true},
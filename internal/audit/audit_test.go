@@ -1,10 +1,67 @@
 package audit
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
 	"os"
 	"testing"
+
+	"github.com/54b3r/tfai-go/internal/logging"
 )
 
+// captureContext returns a context whose logger carries request_id and
+// writes JSON lines to the returned buffer.
+func captureContext() (context.Context, *bytes.Buffer) {
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, nil)).With(slog.String("request_id", "req-1"))
+	return logging.WithLogger(context.Background(), log), &buf
+}
+
+// decodeLine decodes the single JSON log line in buf.
+func decodeLine(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("decode log line %q: %v", buf.String(), err)
+	}
+	return line
+}
+
+func TestLogEvent_UsesContextLogger(t *testing.T) {
+	t.Parallel()
+	ctx, buf := captureContext()
+	LogEvent(ctx, Event{Action: "file delete", Kind: "file_delete", Path: "/ws/main.tf", Actor: "10.0.0.1:5000"},
+		slog.String("workspace", "/ws"))
+
+	line := decodeLine(t, buf)
+	want := map[string]any{
+		"msg":        "audit: file delete",
+		"request_id": "req-1",
+		"event":      "file_delete",
+		"path":       "/ws/main.tf",
+		"actor":      "10.0.0.1:5000",
+		"workspace":  "/ws",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s: want %v, got %v", k, v, line[k])
+		}
+	}
+}
+
+func TestLogCommandStart_UsesContextLogger(t *testing.T) {
+	t.Parallel()
+	ctx, buf := captureContext()
+	LogCommandStart(ctx, "generate", "")
+
+	line := decodeLine(t, buf)
+	if line["msg"] != "audit: command start" || line["command"] != "generate" || line["request_id"] != "req-1" {
+		t.Errorf("unexpected audit line: %v", line)
+	}
+}
+
 func TestSanitiseKey_Secret(t *testing.T) {
 	t.Parallel()
 	if got := SanitiseKey("OPENAI_API_KEY", "sk-abc123"); got != "set" {
@@ -13,6 +70,9 @@ func TestSanitiseKey_Secret(t *testing.T) {
 	if got := SanitiseKey("OPENAI_API_KEY", ""); got != "unset" {
 		t.Errorf("expected 'unset', got %q", got)
 	}
+	if got := SanitiseKey("TFAI_ADMIN_API_KEY", "admin-abc123"); got != "set" {
+		t.Errorf("expected 'set', got %q", got)
+	}
 }
 
 func TestSanitiseKey_NonSecret(t *testing.T) {
@@ -51,3 +111,13 @@ func TestSanitiseConfigPath(t *testing.T) {
 		}
 	}
 }
+
+func TestLogEvent_Level(t *testing.T) {
+	t.Parallel()
+	ctx, buf := captureContext()
+	LogEvent(ctx, Event{Action: "terraform apply", Kind: "terraform_apply", Path: "/ws", Actor: "agent", Level: slog.LevelWarn})
+
+	if line := decodeLine(t, buf); line["level"] != "WARN" || line["event"] != "terraform_apply" {
+		t.Errorf("expected a WARN terraform_apply entry, got %v", line)
+	}
+}
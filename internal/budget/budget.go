@@ -1,8 +1,9 @@
 // Package budget provides token budget estimation and message trimming for the
-// TF-AI agent. Because the agent supports multiple LLM backends with different
-// tokenizers, this package uses a conservative character-based heuristic:
-// 1 token ≈ 4 characters (English prose and code). This deliberately
-// under-estimates token counts to leave headroom for model-specific overhead.
+// TF-AI agent. Tokens are counted by a TokenCounter matching the configured
+// provider: OpenAI and Azure OpenAI models use their byte pair encoding
+// (BPE), and every other backend, whose tokenizer is not available locally,
+// uses a conservative character-based heuristic: 1 token ≈ 4 characters
+// (English prose and code).
 package budget
 
 import (
@@ -31,20 +32,26 @@ func Estimate(s string) int {
 }
 
 // EstimateMessages returns the estimated total token count for a slice of
-// schema.Message values, summing role + content for each message.
+// schema.Message values using the character heuristic.
 func EstimateMessages(msgs []*schema.Message) int {
+	return CountMessages(Heuristic{}, msgs)
+}
+
+// CountMessages returns the total token count for a slice of schema.Message
+// values as counted by c, summing role + content for each message.
+func CountMessages(c TokenCounter, msgs []*schema.Message) int {
 	total := 0
 	for _, m := range msgs {
 		// Each message has a small per-message overhead (~4 tokens in most APIs).
 		total += 4
-		total += Estimate(string(m.Role))
-		total += Estimate(m.Content)
+		total += c.CountTokens(string(m.Role))
+		total += c.CountTokens(m.Content)
 	}
 	return total
 }
 
-// TrimHistory removes the oldest messages from history until the total
-// estimated token count of fixed + history + current fits within maxTokens.
+// TrimHistory removes the oldest messages from history until the total token
+// count of fixed + history, as counted by c, fits within maxTokens.
 // fixed contains messages that must not be trimmed (system prompt, RAG context,
 // workspace context, current user message). history contains prior conversation
 // turns that may be dropped oldest-first.
@@ -52,17 +59,17 @@ func EstimateMessages(msgs []*schema.Message) int {
 // Returns the trimmed history slice. If even an empty history exceeds the
 // budget, the empty slice is returned (fixed messages are never dropped here —
 // callers should warn separately if fixed alone exceeds the budget).
-func TrimHistory(fixed, history []*schema.Message, maxTokens int) []*schema.Message {
+func TrimHistory(c TokenCounter, fixed, history []*schema.Message, maxTokens int) []*schema.Message {
 	if len(history) == 0 {
 		return history
 	}
 
-	fixedTokens := EstimateMessages(fixed)
+	fixedTokens := CountMessages(c, fixed)
 
 	// Binary search would be more efficient but history is typically ≤20 msgs;
 	// linear scan from the front (dropping oldest) is clear and correct.
 	for len(history) > 0 {
-		if fixedTokens+EstimateMessages(history) <= maxTokens {
+		if fixedTokens+CountMessages(c, history) <= maxTokens {
 			break
 		}
 		// Drop the oldest message.
@@ -70,3 +77,21 @@ func TrimHistory(fixed, history []*schema.Message, maxTokens int) []*schema.Mess
 	}
 	return history
 }
+
+// TrimTurns is TrimHistory for history grouped into turns: a user message
+// and the replies to it. Whole turns are dropped oldest-first, so the
+// history kept never starts with a reply whose question was dropped.
+//
+// Returns the trimmed turns; like TrimHistory, none when fixed alone exceeds
+// the budget.
+func TrimTurns(c TokenCounter, fixed []*schema.Message, turns [][]*schema.Message, maxTokens int) [][]*schema.Message {
+	total := CountMessages(c, fixed)
+	for _, turn := range turns {
+		total += CountMessages(c, turn)
+	}
+	for len(turns) > 0 && total > maxTokens {
+		total -= CountMessages(c, turns[0])
+		turns = turns[1:]
+	}
+	return turns
+}
@@ -49,7 +49,7 @@ func Test_TrimHistory_NoTrimNeeded(t *testing.T) {
 		schema.UserMessage("hi"),
 		schema.UserMessage("there"),
 	}
-	got := TrimHistory(fixed, history, DefaultMaxContextTokens)
+	got := TrimHistory(Heuristic{}, fixed, history, DefaultMaxContextTokens)
 	if len(got) != 2 {
 		t.Errorf("want 2 history messages, got %d", len(got))
 	}
@@ -66,7 +66,7 @@ func Test_TrimHistory_DropsOldest(t *testing.T) {
 	// Set fixed to an empty slice and budget to 7 — fits exactly one message (6 ≤ 7)
 	// but not two (12 > 7). The oldest should be dropped.
 	fixed := []*schema.Message{}
-	got := TrimHistory(fixed, history, 7)
+	got := TrimHistory(Heuristic{}, fixed, history, 7)
 	if len(got) != 1 {
 		t.Errorf("want 1 history message after trim, got %d", len(got))
 	}
@@ -78,7 +78,7 @@ func Test_TrimHistory_DropsOldest(t *testing.T) {
 func Test_TrimHistory_EmptyHistory(t *testing.T) {
 	t.Parallel()
 	fixed := []*schema.Message{schema.SystemMessage("sys")}
-	got := TrimHistory(fixed, nil, DefaultMaxContextTokens)
+	got := TrimHistory(Heuristic{}, fixed, nil, DefaultMaxContextTokens)
 	if len(got) != 0 {
 		t.Errorf("want empty, got %d", len(got))
 	}
@@ -94,8 +94,31 @@ func Test_TrimHistory_AllDroppedWhenFixedExceedsBudget(t *testing.T) {
 		schema.UserMessage("a"),
 		schema.UserMessage("b"),
 	}
-	got := TrimHistory(fixed, history, 6000)
+	got := TrimHistory(Heuristic{}, fixed, history, 6000)
 	if len(got) != 0 {
 		t.Errorf("want 0 history messages, got %d", len(got))
 	}
 }
+
+func Test_TrimTurns_DropsWholeTurns(t *testing.T) {
+	t.Parallel()
+	turns := [][]*schema.Message{
+		{schema.UserMessage("q1"), schema.AssistantMessage("a1", nil)},
+		{schema.UserMessage("q2"), schema.AssistantMessage("a2", nil)},
+		{schema.UserMessage("q3"), schema.AssistantMessage(strings.Repeat("x", 40), nil)},
+	}
+	// Each short message costs 4 + 1 + 1 = 6 tokens (assistant: 4 + 2 + 1 =
+	// 7); the long reply 4 + 2 + 10 = 16. The newest turn is 22 tokens, the
+	// two older 13 each. A budget of 40 fits the newest turn and one other;
+	// the oldest turn is dropped whole even though its reply alone would fit.
+	got := TrimTurns(Heuristic{}, nil, turns, 40)
+	if len(got) != 2 || got[0][0].Content != "q2" || len(got[0]) != 2 {
+		t.Errorf("want the two newest turns whole, got %v", got)
+	}
+	if got := TrimTurns(Heuristic{}, nil, turns, 21); len(got) != 0 {
+		t.Errorf("want no turns when the newest does not fit, got %d", len(got))
+	}
+	if got := TrimTurns(Heuristic{}, nil, turns, 1000); len(got) != 3 {
+		t.Errorf("want every turn within the budget, got %d", len(got))
+	}
+}
@@ -0,0 +1,118 @@
+package budget
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// Byte pair encodings understood by NewBPE.
+const (
+	// EncodingCL100K is the encoding of GPT-4, GPT-3.5, and the
+	// text-embedding-3 models.
+	EncodingCL100K = "cl100k_base"
+	// EncodingO200K is the encoding of GPT-4o, GPT-4.1, GPT-5, and the
+	// o-series reasoning models.
+	EncodingO200K = "o200k_base"
+)
+
+func init() {
+	// Encodings are embedded in the binary, so counting never downloads
+	// them and works without network access.
+	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+}
+
+// TokenCounter counts the tokens a text costs in a model's context window.
+// Implementations must be safe for concurrent use.
+type TokenCounter interface {
+	// CountTokens returns the number of tokens in s.
+	CountTokens(s string) int
+}
+
+// Heuristic is the TokenCounter for models whose tokenizer is unknown: the
+// character heuristic of Estimate.
+type Heuristic struct{}
+
+// CountTokens returns Estimate(s).
+func (Heuristic) CountTokens(s string) int { return Estimate(s) }
+
+// BPE is a TokenCounter that encodes text with one of OpenAI's byte pair
+// encodings, giving the exact count OpenAI and Azure OpenAI models see.
+type BPE struct {
+	encoding string
+	// load parses the encoding's ranks on first use; they take tens of
+	// megabytes, so each encoding is loaded once per process.
+	load func() (*tiktoken.Tiktoken, error)
+}
+
+// bpes holds the shared counter for each encoding NewBPE accepts.
+var bpes = map[string]*BPE{
+	EncodingCL100K: newBPE(EncodingCL100K),
+	EncodingO200K:  newBPE(EncodingO200K),
+}
+
+// newBPE returns a counter that loads encoding on first use.
+func newBPE(encoding string) *BPE {
+	return &BPE{
+		encoding: encoding,
+		load: sync.OnceValues(func() (*tiktoken.Tiktoken, error) {
+			return tiktoken.GetEncoding(encoding)
+		}),
+	}
+}
+
+// NewBPE returns the counter for encoding, EncodingCL100K or EncodingO200K,
+// or nil for any other name. Counters are shared: the encoding is loaded the
+// first time any of them counts.
+func NewBPE(encoding string) *BPE {
+	return bpes[encoding]
+}
+
+// Encoding returns the name of the encoding b counts with.
+func (b *BPE) Encoding() string { return b.encoding }
+
+// CountTokens returns the number of tokens in s. Special tokens such as
+// <|endoftext|> are counted as the plain text they are in a message. If the
+// encoding fails to load, it falls back to Estimate.
+func (b *BPE) CountTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+	enc, err := b.load()
+	if err != nil {
+		return Estimate(s)
+	}
+	return len(enc.EncodeOrdinary(s))
+}
+
+// o200kModels are the model name prefixes that use EncodingO200K.
+var o200kModels = []string{"gpt-4o", "chatgpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4", "codex-"}
+
+// EncodingForModel returns the encoding of the OpenAI model named model:
+// EncodingO200K for GPT-4o and newer, EncodingCL100K otherwise. Azure
+// deployment names are matched too when they contain the model name, e.g.
+// "prod-gpt-4o"; any other deployment gets EncodingCL100K, which counts at
+// least as many tokens as EncodingO200K for typical text.
+func EncodingForModel(model string) string {
+	model = strings.ToLower(model)
+	for _, prefix := range o200kModels {
+		if strings.HasPrefix(model, prefix) || (strings.HasPrefix(prefix, "gpt-") && strings.Contains(model, prefix)) {
+			return EncodingO200K
+		}
+	}
+	return EncodingCL100K
+}
+
+// CounterFor returns the TokenCounter matching a provider backend ("openai",
+// "azure", ...) and model name: a BPE counter for OpenAI and Azure OpenAI,
+// Heuristic for every other backend.
+func CounterFor(provider, model string) TokenCounter {
+	switch provider {
+	case "openai", "azure":
+		return NewBPE(EncodingForModel(model))
+	default:
+		return Heuristic{}
+	}
+}
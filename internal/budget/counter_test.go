@@ -0,0 +1,141 @@
+package budget
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// hclSample is a typical Terraform snippet, the kind of code-heavy content
+// the character heuristic undercounts.
+const hclSample = `resource "aws_s3_bucket" "logs" {
+  bucket = "acme-logs"
+
+  tags = {
+    Environment = var.environment
+  }
+}
+`
+
+// Known counts from OpenAI's tiktoken for the same text.
+func Test_BPE_KnownCounts(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		encoding string
+		input    string
+		want     int
+	}{
+		{EncodingCL100K, "", 0},
+		{EncodingCL100K, "hello world", 2},
+		{EncodingCL100K, "tiktoken is great!", 6},
+		{EncodingCL100K, "antidisestablishmentarianism", 6},
+		{EncodingCL100K, "2 + 2 = 4", 7},
+		{EncodingCL100K, "お誕生日おめでとう", 9},
+		{EncodingCL100K, hclSample, 33},
+		{EncodingO200K, "hello world", 2},
+		{EncodingO200K, "tiktoken is great!", 6},
+		{EncodingO200K, "2 + 2 = 4", 7},
+		{EncodingO200K, "お誕生日おめでとう", 8},
+		{EncodingO200K, hclSample, 33},
+		// Special tokens in a message are plain text.
+		{EncodingO200K, "<|endoftext|>", 7},
+	}
+	for _, tc := range cases {
+		if got := NewBPE(tc.encoding).CountTokens(tc.input); got != tc.want {
+			t.Errorf("%s: CountTokens(%q) = %d, want %d", tc.encoding, tc.input, got, tc.want)
+		}
+	}
+}
+
+func Test_NewBPE_UnknownEncoding(t *testing.T) {
+	t.Parallel()
+	if b := NewBPE("p50k_base"); b != nil {
+		t.Errorf("want nil for an unsupported encoding, got %s", b.Encoding())
+	}
+}
+
+func Test_EncodingForModel(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		model string
+		want  string
+	}{
+		{"gpt-4o", EncodingO200K},
+		{"gpt-4o-mini", EncodingO200K},
+		{"gpt-4.1-nano", EncodingO200K},
+		{"gpt-5.2-codex", EncodingO200K},
+		{"o3-mini", EncodingO200K},
+		{"prod-GPT-4o", EncodingO200K},
+		{"gpt-4", EncodingCL100K},
+		{"gpt-4-turbo", EncodingCL100K},
+		{"gpt-35-turbo", EncodingCL100K},
+		{"my-deployment", EncodingCL100K},
+		{"", EncodingCL100K},
+	}
+	for _, tc := range cases {
+		if got := EncodingForModel(tc.model); got != tc.want {
+			t.Errorf("EncodingForModel(%q) = %s, want %s", tc.model, got, tc.want)
+		}
+	}
+}
+
+func Test_CounterFor(t *testing.T) {
+	t.Parallel()
+	if c, ok := CounterFor("azure", "gpt-4o").(*BPE); !ok || c.Encoding() != EncodingO200K {
+		t.Errorf("want the o200k counter for Azure gpt-4o, got %#v", CounterFor("azure", "gpt-4o"))
+	}
+	if c, ok := CounterFor("openai", "gpt-4").(*BPE); !ok || c.Encoding() != EncodingCL100K {
+		t.Errorf("want the cl100k counter for OpenAI gpt-4, got %#v", CounterFor("openai", "gpt-4"))
+	}
+	for _, provider := range []string{"ollama", "bedrock", "gemini", ""} {
+		if _, ok := CounterFor(provider, "gpt-4o").(Heuristic); !ok {
+			t.Errorf("want the heuristic for %q, got %#v", provider, CounterFor(provider, "gpt-4o"))
+		}
+	}
+}
+
+func Test_TrimHistory_CountsWithCounter(t *testing.T) {
+	t.Parallel()
+	history := []*schema.Message{
+		schema.UserMessage(hclSample),
+		schema.UserMessage("newest"),
+	}
+	// The heuristic counts the snippet as 27 tokens, the encoding as 33:
+	// a budget between the two totals keeps it only by the heuristic.
+	c := NewBPE(EncodingCL100K)
+	budget := CountMessages(c, history) - 1
+	if got := TrimHistory(Heuristic{}, nil, history, budget); len(got) != 2 {
+		t.Errorf("want the heuristic to keep both messages, got %d", len(got))
+	}
+	if got := TrimHistory(c, nil, history, budget); len(got) != 1 || got[0].Content != "newest" {
+		t.Errorf("want the BPE counter to drop the snippet, got %d messages", len(got))
+	}
+}
+
+// benchmarkText is about 4 KiB of mixed prose and Terraform.
+var benchmarkText = strings.Repeat("Create an S3 bucket for access logs with versioning enabled.\n"+hclSample, 24)
+
+func Benchmark_Heuristic(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkText)))
+	for b.Loop() {
+		Heuristic{}.CountTokens(benchmarkText)
+	}
+}
+
+func Benchmark_BPE_CL100K(b *testing.B) {
+	benchmarkBPE(b, EncodingCL100K)
+}
+
+func Benchmark_BPE_O200K(b *testing.B) {
+	benchmarkBPE(b, EncodingO200K)
+}
+
+func benchmarkBPE(b *testing.B, encoding string) {
+	c := NewBPE(encoding)
+	c.CountTokens("warm up") // load the encoding outside the timed loop
+	b.SetBytes(int64(len(benchmarkText)))
+	for b.Loop() {
+		c.CountTokens(benchmarkText)
+	}
+}
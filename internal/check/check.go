@@ -0,0 +1,249 @@
+// Package check runs tfai's deterministic checks over Terraform files:
+// canonical formatting, variable declarations, and hardcoded secrets. None
+// of them needs a model or the terraform binary, so they are cheap enough to
+// run before every commit; `tfai check` and the git hook written by
+// `tfai hook install` use this package.
+package check
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+
+	"github.com/54b3r/tfai-go/internal/secretscan"
+)
+
+// Names of the checks, as accepted by ParseChecks.
+const (
+	// Fmt reports files that differ from `terraform fmt` output.
+	Fmt = "fmt"
+	// Vars reports HCL syntax errors, which block, and variable blocks
+	// without a type or description, which warn.
+	Vars = "vars"
+	// Secrets reports hardcoded credentials found by secretscan.
+	Secrets = "secrets"
+)
+
+// All lists every check in the order they run.
+var All = []string{Fmt, Vars, Secrets}
+
+// File is one Terraform file to check.
+type File struct {
+	// Path is the slash-separated path reported in findings.
+	Path string
+	// Content is the file content.
+	Content []byte
+}
+
+// Finding is one problem reported by a check.
+type Finding struct {
+	// File is the File.Path the finding is in.
+	File string
+	// Line is the 1-based line of the problem, or 0 for the whole file.
+	Line int
+	// Check is the name of the check that reported it.
+	Check string
+	// Message describes the problem.
+	Message string
+	// Blocking is true for problems that should stop a commit; false for
+	// warnings.
+	Blocking bool
+}
+
+// ParseChecks parses a comma-separated list of check names. An empty list
+// selects All.
+func ParseChecks(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return All, nil
+	}
+	var checks []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if !slices.Contains(All, name) {
+			return nil, fmt.Errorf("check: unknown check %q (available: %s)", name, strings.Join(All, ", "))
+		}
+		if !slices.Contains(checks, name) {
+			checks = append(checks, name)
+		}
+	}
+	return checks, nil
+}
+
+// Run runs checks over files and returns the findings sorted by file, line,
+// and check order. scanner is used by the secrets check; nil selects
+// secretscan.Default().
+func Run(files []File, checks []string, scanner *secretscan.Scanner) []Finding {
+	if scanner == nil {
+		scanner = secretscan.Default()
+	}
+	var findings []Finding
+	for _, f := range files {
+		for _, c := range checks {
+			switch c {
+			case Fmt:
+				findings = append(findings, checkFmt(f)...)
+			case Vars:
+				findings = append(findings, checkVars(f)...)
+			case Secrets:
+				findings = append(findings, checkSecrets(f, scanner)...)
+			}
+		}
+	}
+	order := make(map[string]int, len(All))
+	for i, c := range All {
+		order[c] = i
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return order[a.Check] < order[b.Check]
+	})
+	return findings
+}
+
+// Blocking returns the number of blocking findings.
+func Blocking(findings []Finding) int {
+	n := 0
+	for _, f := range findings {
+		if f.Blocking {
+			n++
+		}
+	}
+	return n
+}
+
+// IsTerraform reports whether path names a file the checks apply to.
+func IsTerraform(path string) bool {
+	return strings.HasSuffix(path, ".tf")
+}
+
+// DirFiles reads the Terraform files under dir, skipping hidden directories
+// such as .terraform and .tfai. Paths are relative to dir.
+func DirFiles(dir string) ([]File, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !IsTerraform(d.Name()) {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Path: filepath.ToSlash(rel), Content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("check: failed to read %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// checkFmt reports a file whose canonical formatting differs from its
+// content, at the first line that changes.
+func checkFmt(f File) []Finding {
+	formatted := hclwrite.Format(f.Content)
+	if bytes.Equal(formatted, f.Content) {
+		return nil
+	}
+	return []Finding{{
+		File:     f.Path,
+		Line:     firstDifferentLine(f.Content, formatted),
+		Check:    Fmt,
+		Message:  "not formatted; run terraform fmt",
+		Blocking: true,
+	}}
+}
+
+// firstDifferentLine returns the 1-based number of the first line that
+// differs between a and b.
+func firstDifferentLine(a, b []byte) int {
+	al, bl := bytes.Split(a, []byte("\n")), bytes.Split(b, []byte("\n"))
+	for i := 0; i < len(al) && i < len(bl); i++ {
+		if !bytes.Equal(al[i], bl[i]) {
+			return i + 1
+		}
+	}
+	return min(len(al), len(bl)) + 1
+}
+
+// checkVars reports syntax errors and variable blocks missing a type or a
+// description.
+func checkVars(f File) []Finding {
+	file, diags := hclsyntax.ParseConfig(f.Content, f.Path, hcl.InitialPos)
+	var findings []Finding
+	for _, d := range diags {
+		if d.Severity != hcl.DiagError {
+			continue
+		}
+		line := 0
+		if d.Subject != nil {
+			line = d.Subject.Start.Line
+		}
+		findings = append(findings, Finding{File: f.Path, Line: line, Check: Vars, Message: "invalid HCL: " + d.Summary, Blocking: true})
+	}
+	if diags.HasErrors() {
+		return findings
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return findings
+	}
+	for _, block := range body.Blocks {
+		if block.Type != "variable" || len(block.Labels) != 1 {
+			continue
+		}
+		for _, attr := range []string{"type", "description"} {
+			if _, ok := block.Body.Attributes[attr]; !ok {
+				findings = append(findings, Finding{
+					File:    f.Path,
+					Line:    block.DefRange().Start.Line,
+					Check:   Vars,
+					Message: fmt.Sprintf("variable %q has no %s", block.Labels[0], attr),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// checkSecrets reports the credentials scanner finds in the file.
+func checkSecrets(f File, scanner *secretscan.Scanner) []Finding {
+	var findings []Finding
+	for _, s := range scanner.Scan(f.Path, string(f.Content)) {
+		findings = append(findings, Finding{
+			File:     f.Path,
+			Line:     s.Line,
+			Check:    Secrets,
+			Message:  fmt.Sprintf("%s: %s", s.Detector, s.Preview),
+			Blocking: true,
+		})
+	}
+	return findings
+}
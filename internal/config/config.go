@@ -44,6 +44,12 @@ type Config struct {
 
 	// Tracing configures Langfuse tracing integration.
 	Tracing TracingConfig `yaml:"tracing"`
+
+	// Budget configures cost estimation for usage reports.
+	Budget BudgetConfig `yaml:"budget"`
+
+	// Workspace configures the files tfai keeps in Terraform workspaces.
+	Workspace WorkspaceConfig `yaml:"workspace"`
 }
 
 // ModelConfig holds LLM chat model settings.
@@ -119,7 +125,7 @@ type GeminiConfig struct {
 
 // EmbeddingConfig holds embedding provider settings for RAG.
 type EmbeddingConfig struct {
-	// Provider selects the embedding backend (ollama, openai, azure).
+	// Provider selects the embedding backend (ollama, openai, azure, gemini).
 	Provider string `yaml:"provider"`
 	// Model is the embedding model name.
 	Model string `yaml:"model"`
@@ -143,6 +149,21 @@ type QdrantConfig struct {
 	APIKey string `yaml:"api_key"`
 	// TLS enables TLS for the Qdrant connection.
 	TLS bool `yaml:"tls"`
+	// DialTimeout is the time allowed per gRPC connection attempt, as a Go
+	// duration. Env: QDRANT_DIAL_TIMEOUT.
+	DialTimeout string `yaml:"dial_timeout"`
+	// Hybrid fuses keyword matches into vector search results. Env:
+	// RAG_HYBRID.
+	Hybrid bool `yaml:"hybrid"`
+	// Reranker reorders retrieved documents before they are injected: none,
+	// lexical, or llm. Env: RAG_RERANKER.
+	Reranker string `yaml:"reranker"`
+	// MinScore drops retrieved documents scoring below it. Env:
+	// RAG_MIN_SCORE.
+	MinScore float32 `yaml:"min_score"`
+	// MaxChars caps the total documentation text injected per query. Env:
+	// RAG_MAX_CHARS.
+	MaxChars int `yaml:"max_chars"`
 }
 
 // ServerConfig holds HTTP server settings.
@@ -153,6 +174,18 @@ type ServerConfig struct {
 	Port int `yaml:"port"`
 	// APIKey is the Bearer token for API authentication. Prefer env var TFAI_API_KEY.
 	APIKey string `yaml:"api_key"`
+	// BlockSecrets rejects file saves whose content contains credentials
+	// instead of only warning. Env: TFAI_BLOCK_SECRETS.
+	BlockSecrets bool `yaml:"block_secrets"`
+	// Disclosure labels every answer as AI-generated in chat streams, CLI
+	// output, and history. Env: TFAI_DISCLOSURE.
+	Disclosure string `yaml:"disclosure"`
+	// MaxTokensLimit is the largest maxTokens a chat request may ask for.
+	// Env: TFAI_MAX_TOKENS_LIMIT.
+	MaxTokensLimit int `yaml:"max_tokens_limit"`
+	// BasePath is the path prefix the server is reachable under behind a
+	// reverse proxy, e.g. /tfai. Env: TFAI_BASE_PATH.
+	BasePath string `yaml:"base_path"`
 }
 
 // LoggingConfig holds structured logging settings.
@@ -179,6 +212,44 @@ type TracingConfig struct {
 	Host string `yaml:"host"`
 }
 
+// WorkspaceConfig holds settings for the files tfai keeps in workspaces.
+type WorkspaceConfig struct {
+	// ManageGitignore keeps a tfai block in the workspace .gitignore, in
+	// addition to .tfai/.gitignore. Env: TFAI_MANAGE_GITIGNORE.
+	ManageGitignore bool `yaml:"manage_gitignore"`
+	// SpoolThreshold is how many bytes of a model response are held in
+	// memory before the rest spills to a temporary file. Env:
+	// TFAI_SPOOL_THRESHOLD.
+	SpoolThreshold int `yaml:"spool_threshold"`
+	// SpoolDir is where spilled responses are written instead of the
+	// workspace's .tfai/tmp. Env: TFAI_SPOOL_DIR.
+	SpoolDir string `yaml:"spool_dir"`
+}
+
+// BudgetConfig holds cost estimation settings. It has no env var mapping;
+// commands that need it read the YAML file with Read.
+type BudgetConfig struct {
+	// Prices maps provider → model → price per 1K tokens. A model key of "*"
+	// prices every model of that provider that has no entry of its own.
+	Prices map[string]map[string]PriceConfig `yaml:"prices"`
+}
+
+// PriceConfig is the price in dollars of 1,000 tokens.
+type PriceConfig struct {
+	// Prompt is the price of 1K prompt (input) tokens.
+	Prompt float64 `yaml:"prompt"`
+	// Completion is the price of 1K completion (output) tokens.
+	Completion float64 `yaml:"completion"`
+}
+
+// Options controls how a config file is validated.
+type Options struct {
+	// Lenient downgrades unknown keys and invalid values from errors to
+	// logged warnings, for running an older binary against a config file
+	// written for a newer one.
+	Lenient bool
+}
+
 // envMapping maps YAML config fields to their corresponding env var names.
 // Only non-empty YAML values are applied; env vars always take precedence.
 var envMapping = []struct {
@@ -210,9 +281,21 @@ var envMapping = []struct {
 	{"QDRANT_COLLECTION", func(c *Config) string { return c.Qdrant.Collection }},
 	{"QDRANT_API_KEY", func(c *Config) string { return c.Qdrant.APIKey }},
 	{"QDRANT_TLS", func(c *Config) string { return boolStr(c.Qdrant.TLS) }},
+	{"QDRANT_DIAL_TIMEOUT", func(c *Config) string { return c.Qdrant.DialTimeout }},
+	{"RAG_HYBRID", func(c *Config) string { return boolStr(c.Qdrant.Hybrid) }},
+	{"RAG_RERANKER", func(c *Config) string { return c.Qdrant.Reranker }},
+	{"RAG_MIN_SCORE", func(c *Config) string { return float32Str(c.Qdrant.MinScore) }},
+	{"RAG_MAX_CHARS", func(c *Config) string { return intStr(c.Qdrant.MaxChars) }},
 	{"LOG_LEVEL", func(c *Config) string { return c.Logging.Level }},
 	{"LOG_FORMAT", func(c *Config) string { return c.Logging.Format }},
 	{"TFAI_HISTORY_DB", func(c *Config) string { return c.History.DBPath }},
+	{"TFAI_BLOCK_SECRETS", func(c *Config) string { return boolStr(c.Server.BlockSecrets) }},
+	{"TFAI_DISCLOSURE", func(c *Config) string { return c.Server.Disclosure }},
+	{"TFAI_MAX_TOKENS_LIMIT", func(c *Config) string { return intStr(c.Server.MaxTokensLimit) }},
+	{"TFAI_BASE_PATH", func(c *Config) string { return c.Server.BasePath }},
+	{"TFAI_MANAGE_GITIGNORE", func(c *Config) string { return boolStr(c.Workspace.ManageGitignore) }},
+	{"TFAI_SPOOL_THRESHOLD", func(c *Config) string { return intStr(c.Workspace.SpoolThreshold) }},
+	{"TFAI_SPOOL_DIR", func(c *Config) string { return c.Workspace.SpoolDir }},
 	{"LANGFUSE_PUBLIC_KEY", func(c *Config) string { return c.Tracing.PublicKey }},
 	{"LANGFUSE_SECRET_KEY", func(c *Config) string { return c.Tracing.SecretKey }},
 	{"LANGFUSE_HOST", func(c *Config) string { return c.Tracing.Host }},
@@ -221,26 +304,22 @@ var envMapping = []struct {
 // Load reads a YAML config file and applies non-empty values as environment
 // variables. Existing env vars are never overwritten (env always wins).
 // Returns the path that was loaded, or empty string if no file was found.
-func Load(explicitPath string, log *slog.Logger) (string, error) {
+// The file is validated as described on Read.
+func Load(explicitPath string, opts Options, log *slog.Logger) (string, error) {
 	path := resolveConfigPath(explicitPath)
 	if path == "" {
 		log.Debug("config: no YAML config file found, using env vars only")
 		return "", nil
 	}
 
-	data, err := os.ReadFile(path)
+	cfg, err := Read(path, opts, log)
 	if err != nil {
-		return "", fmt.Errorf("config: failed to read %s: %w", path, err)
-	}
-
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return "", fmt.Errorf("config: failed to parse %s: %w", path, err)
+		return "", err
 	}
 
 	applied := 0
 	for _, m := range envMapping {
-		yamlVal := m.value(&cfg)
+		yamlVal := m.value(cfg)
 		if yamlVal == "" || yamlVal == "0" || yamlVal == "false" {
 			continue
 		}
@@ -259,6 +338,45 @@ func Load(explicitPath string, log *slog.Logger) (string, error) {
 	return path, nil
 }
 
+// Read parses the YAML config file at path without touching the environment.
+// Used for settings that have no env var equivalent, such as budget prices.
+//
+// Unknown keys (usually typos such as "modle") and invalid values of
+// enum-like settings are returned as a *ValidationError naming each key
+// with its line and column. With opts.Lenient they are logged as warnings
+// instead and the rest of the file is used.
+func Read(path string, opts Options, log *slog.Logger) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to read %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("config: failed to parse %s: %w", path, err)
+	}
+	var cfg Config
+	if err := doc.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("config: failed to parse %s: %w", path, err)
+	}
+
+	if problems := validate(&doc); len(problems) > 0 {
+		if !opts.Lenient {
+			return nil, &ValidationError{Path: path, Problems: problems}
+		}
+		for _, p := range problems {
+			log.Warn("config: ignoring invalid setting",
+				slog.String("path", path),
+				slog.String("key", p.Key),
+				slog.Int("line", p.Line),
+				slog.Int("column", p.Column),
+				slog.String("problem", p.Message),
+			)
+		}
+	}
+	return &cfg, nil
+}
+
 // resolveConfigPath returns the first config file path that exists.
 func resolveConfigPath(explicit string) string {
 	if explicit != "" {
@@ -11,7 +11,7 @@ func TestLoad_NoFile(t *testing.T) {
 	t.Parallel()
 
 	log := slog.Default()
-	path, err := Load("/nonexistent/path/config.yaml", log)
+	path, err := Load("/nonexistent/path/config.yaml", Options{}, log)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -63,7 +63,7 @@ logging:
 	}
 
 	log := slog.Default()
-	loaded, err := Load(cfgPath, log)
+	loaded, err := Load(cfgPath, Options{}, log)
 	if err != nil {
 		t.Fatalf("Load failed: %v", err)
 	}
@@ -109,7 +109,7 @@ model:
 	t.Setenv("MODEL_PROVIDER", "azure")
 
 	log := slog.Default()
-	_, err := Load(cfgPath, log)
+	_, err := Load(cfgPath, Options{}, log)
 	if err != nil {
 		t.Fatalf("Load failed: %v", err)
 	}
@@ -128,12 +128,46 @@ func TestLoad_InvalidYAML(t *testing.T) {
 	}
 
 	log := slog.Default()
-	_, err := Load(cfgPath, log)
+	_, err := Load(cfgPath, Options{}, log)
 	if err == nil {
 		t.Fatal("expected error for invalid YAML")
 	}
 }
 
+func TestRead_BudgetPrices(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	content := []byte(`
+budget:
+  prices:
+    openai:
+      gpt-4o:
+        prompt: 0.0025
+        completion: 0.01
+    ollama:
+      "*":
+        prompt: 0
+        completion: 0
+`)
+	if err := os.WriteFile(cfgPath, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Read(cfgPath, Options{}, slog.Default())
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	got := cfg.Budget.Prices["openai"]["gpt-4o"]
+	if got.Prompt != 0.0025 || got.Completion != 0.01 {
+		t.Errorf("openai/gpt-4o: expected {0.0025 0.01}, got %+v", got)
+	}
+	if _, ok := cfg.Budget.Prices["ollama"]["*"]; !ok {
+		t.Errorf("expected ollama wildcard price, got %+v", cfg.Budget.Prices)
+	}
+}
+
 func TestFloat32Str(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
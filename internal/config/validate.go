@@ -0,0 +1,215 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is one unknown key or invalid value found in a config file.
+type Problem struct {
+	// Key is the dotted path of the offending key, e.g. "model.ollama.hots".
+	Key string
+	// Line and Column locate the key in the file (1-based).
+	Line, Column int
+	// Message describes the problem, including any did-you-mean suggestion.
+	Message string
+}
+
+// String renders the problem as "line:column: message".
+func (p Problem) String() string {
+	return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+}
+
+// ValidationError lists every problem found in a config file. Read returns
+// it (unwrapped) unless Options.Lenient is set.
+type ValidationError struct {
+	// Path is the config file that was validated.
+	Path string
+	// Problems are in file order.
+	Problems []Problem
+}
+
+// Error renders one problem per line, prefixed with the file path.
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "config: invalid config file %s", e.Path)
+	for _, p := range e.Problems {
+		fmt.Fprintf(&sb, "\n  %s:%s", e.Path, p)
+	}
+	sb.WriteString("\n  (run with --lenient to report these as warnings)")
+	return sb.String()
+}
+
+// enumFields lists the accepted values of enum-like settings, keyed by
+// dotted path. Values are compared case-insensitively; empty is always
+// accepted and means "use the default".
+var enumFields = map[string][]string{
+	"model.provider":     {"ollama", "openai", "azure", "bedrock", "gemini", "replay"},
+	"embedding.provider": {"ollama", "openai", "azure", "bedrock", "gemini"},
+	"logging.level":      {"debug", "info", "warn", "warning", "error"},
+	"logging.format":     {"json", "text"},
+}
+
+// validate checks the parsed document against the Config schema and returns
+// every unknown key and invalid enum value it finds.
+func validate(doc *yaml.Node) []Problem {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	var problems []Problem
+	walk(doc.Content[0], reflect.TypeOf(Config{}), "", &problems)
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+	return problems
+}
+
+// walk validates node against t. prefix is the dotted path of node.
+func walk(node *yaml.Node, t reflect.Type, prefix string, problems *[]Problem) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case node.Kind == yaml.AliasNode:
+		walk(node.Alias, t, prefix, problems)
+	case node.Kind == yaml.ScalarNode:
+		checkEnum(node, prefix, problems)
+	case node.Kind != yaml.MappingNode:
+		// Type mismatches are reported by yaml.Unmarshal with their own line.
+	case t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue // merge key; the merged mapping is validated where it is defined
+			}
+			path := join(prefix, key.Value)
+			field, ok := fields[key.Value]
+			if !ok {
+				*problems = append(*problems, Problem{
+					Key:     path,
+					Line:    key.Line,
+					Column:  key.Column,
+					Message: unknownKeyMessage(path, key.Value, fields),
+				})
+				continue
+			}
+			walk(value, field, path, problems)
+		}
+	case t.Kind() == reflect.Map:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			walk(node.Content[i+1], t.Elem(), join(prefix, node.Content[i].Value), problems)
+		}
+	}
+}
+
+// checkEnum reports a scalar at an enum-like path whose value is not one of
+// the accepted values.
+func checkEnum(node *yaml.Node, path string, problems *[]Problem) {
+	allowed, ok := enumFields[path]
+	if !ok || node.Value == "" {
+		return
+	}
+	for _, v := range allowed {
+		if strings.EqualFold(node.Value, v) {
+			return
+		}
+	}
+	*problems = append(*problems, Problem{
+		Key:     path,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf("invalid value %q for %s (valid values: %s)", node.Value, path, strings.Join(allowed, ", ")),
+	})
+}
+
+// yamlFields maps the yaml key of each exported field of struct type t to
+// the field's type.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// unknownKeyMessage describes an unknown key, suggesting the closest known
+// key at the same level when it is a plausible typo.
+func unknownKeyMessage(path, key string, fields map[string]reflect.Type) string {
+	msg := fmt.Sprintf("unknown key %q", path)
+	if s := suggest(key, fields); s != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", s)
+	}
+	return msg
+}
+
+// suggest returns the known key closest to key by edit distance, or "" when
+// none is close enough to be a typo: at most two edits, and fewer edits
+// than the key is long.
+func suggest(key string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for name := range fields {
+		d := editDistance(strings.ToLower(key), name)
+		if d < bestDist || (d == bestDist && name < best) {
+			best, bestDist = name, d
+		}
+	}
+	if best == "" || bestDist >= len(key) {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the Damerau–Levenshtein (optimal string alignment)
+// distance between a and b, so a transposition like "modle" → "model"
+// counts as one edit.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// join appends key to the dotted path prefix.
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
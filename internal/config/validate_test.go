@@ -0,0 +1,196 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes content to a config.yaml in a fresh temp dir.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// ---------------------------------------------------------------------------
+// Strict validation
+// ---------------------------------------------------------------------------
+
+func TestRead_ReportsProblems(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    []Problem
+	}{
+		{
+			name:    "unknown top-level key with suggestion",
+			content: "modle:\n  provider: openai\n",
+			want: []Problem{{Key: "modle", Line: 1, Column: 1,
+				Message: `unknown key "modle" (did you mean "model"?)`}},
+		},
+		{
+			name:    "unknown nested key with suggestion",
+			content: "model:\n  provider: ollama\n  ollama:\n    hots: http://localhost:11434\n",
+			want: []Problem{{Key: "model.ollama.hots", Line: 4, Column: 5,
+				Message: `unknown key "model.ollama.hots" (did you mean "host"?)`}},
+		},
+		{
+			name:    "unknown key without a close match",
+			content: "server:\n  port: 8080\n  tls_certificate: cert.pem\n",
+			want: []Problem{{Key: "server.tls_certificate", Line: 3, Column: 3,
+				Message: `unknown key "server.tls_certificate"`}},
+		},
+		{
+			name:    "unknown key inside a map value",
+			content: "budget:\n  prices:\n    openai:\n      gpt-4o:\n        promt: 0.0025\n",
+			want: []Problem{{Key: "budget.prices.openai.gpt-4o.promt", Line: 5, Column: 9,
+				Message: `unknown key "budget.prices.openai.gpt-4o.promt" (did you mean "prompt"?)`}},
+		},
+		{
+			name:    "invalid enum values",
+			content: "model:\n  provider: anthropic\nlogging:\n  level: verbose\n  format: TEXT\n",
+			want: []Problem{
+				{Key: "model.provider", Line: 2, Column: 13,
+					Message: `invalid value "anthropic" for model.provider (valid values: ollama, openai, azure, bedrock, gemini, replay)`},
+				{Key: "logging.level", Line: 4, Column: 10,
+					Message: `invalid value "verbose" for logging.level (valid values: debug, info, warn, warning, error)`},
+			},
+		},
+		{
+			name:    "problems in file order",
+			content: "qdrant:\n  hostt: q\nmodle: {}\n",
+			want: []Problem{
+				{Key: "qdrant.hostt", Line: 2, Column: 3, Message: `unknown key "qdrant.hostt" (did you mean "host"?)`},
+				{Key: "modle", Line: 3, Column: 1, Message: `unknown key "modle" (did you mean "model"?)`},
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := writeConfig(t, tc.content)
+			_, err := Read(path, Options{}, slog.Default())
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("expected *ValidationError, got %v", err)
+			}
+			if len(ve.Problems) != len(tc.want) {
+				t.Fatalf("expected %d problems, got %+v", len(tc.want), ve.Problems)
+			}
+			for i, want := range tc.want {
+				if ve.Problems[i] != want {
+					t.Errorf("problem %d: expected %+v, got %+v", i, want, ve.Problems[i])
+				}
+			}
+			if !strings.Contains(err.Error(), path+":"+tc.want[0].String()) {
+				t.Errorf("expected error to locate the first problem, got:\n%s", err)
+			}
+		})
+	}
+}
+
+func TestRead_ValidConfigs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "empty file", content: ""},
+		{name: "mixed-case enum", content: "logging:\n  level: DEBUG\n  format: Text\n"},
+		{
+			name: "anchors and merge keys",
+			content: "budget:\n  prices:\n    openai:\n      gpt-4o: &p\n        prompt: 1\n        completion: 2\n" +
+				"      gpt-4o-mini:\n        <<: *p\n        prompt: 0.5\n",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := Read(writeConfig(t, tc.content), Options{}, slog.Default()); err != nil {
+				t.Errorf("Read: %v", err)
+			}
+		})
+	}
+}
+
+// TestRead_ExampleConfig keeps config.yaml.example in step with the schema.
+func TestRead_ExampleConfig(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Read(filepath.Join("..", "..", "config.yaml.example"), Options{}, slog.Default()); err != nil {
+		t.Errorf("config.yaml.example: %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Lenient mode
+// ---------------------------------------------------------------------------
+
+func TestRead_LenientWarns(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfig(t, "modle:\n  provider: openai\nserver:\n  port: 9090\n")
+	var logs bytes.Buffer
+	cfg, err := Read(path, Options{Lenient: true}, slog.New(slog.NewTextHandler(&logs, nil)))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if cfg.Server.Port != 9090 {
+		t.Errorf("expected known keys to be applied, got port %d", cfg.Server.Port)
+	}
+	out := logs.String()
+	for _, want := range []string{"level=WARN", "key=modle", "line=1", `did you mean \"model\"?`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in warning, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestLoad_StrictByDefault(t *testing.T) {
+	t.Setenv("MODEL_PROVIDER", "")
+
+	path := writeConfig(t, "model:\n  provder: openai\n")
+	if _, err := Load(path, Options{}, slog.Default()); err == nil {
+		t.Fatal("expected Load to reject the misspelled key")
+	}
+	if got := os.Getenv("MODEL_PROVIDER"); got != "" {
+		t.Errorf("expected no env vars applied from a rejected file, got MODEL_PROVIDER=%q", got)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	t.Parallel()
+
+	fields := yamlFields(reflect.TypeOf(Config{}))
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"modle", "model"},
+		{"Model", "model"},
+		{"serverr", "server"},
+		{"tracng", "tracing"},
+		{"x", ""},
+		{"completely_unrelated", ""},
+	}
+	for _, tc := range tests {
+		if got := suggest(tc.key, fields); got != tc.want {
+			t.Errorf("suggest(%q) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+}
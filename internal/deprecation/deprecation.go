@@ -0,0 +1,145 @@
+// Package deprecation is the registry of deprecated settings and API fields.
+// Each deprecation has a stable ID, used in log lines, in the deprecations
+// array of API responses, and in TFAI_SUPPRESS_DEPRECATIONS, which silences
+// the warnings of the IDs it lists (comma-separated).
+//
+// A deprecated setting keeps working until it is removed; reading it logs one
+// structured WARN per process.
+package deprecation
+
+import (
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SuppressEnv is the environment variable listing the deprecation IDs whose
+// warnings are silenced.
+const SuppressEnv = "TFAI_SUPPRESS_DEPRECATIONS"
+
+// Kind says where a deprecated name is used.
+type Kind string
+
+const (
+	// KindEnv is an environment variable.
+	KindEnv Kind = "env"
+	// KindAPI is a field or parameter of an HTTP API request.
+	KindAPI Kind = "api"
+)
+
+// Deprecation is one deprecated name and what replaces it.
+type Deprecation struct {
+	// ID identifies the deprecation, e.g. "env-generate-model-id".
+	ID string
+	// Kind says where Name is used.
+	Kind Kind
+	// Name is the deprecated name, e.g. "GENERATE_MODEL_ID".
+	Name string
+	// Replacement is the name to use instead.
+	Replacement string
+	// Message explains the change in one sentence.
+	Message string
+}
+
+// Registered deprecation IDs.
+const (
+	// GenerateModelID is the Bedrock-only GENERATE_MODEL_ID variable.
+	GenerateModelID = "env-generate-model-id"
+	// WorkspaceDirParam is the dir query parameter of the workspace
+	// endpoints.
+	WorkspaceDirParam = "api-workspace-dir-param"
+)
+
+// registry is every registered deprecation, in ID order.
+var registry = []Deprecation{
+	{
+		ID:          WorkspaceDirParam,
+		Kind:        KindAPI,
+		Name:        "dir",
+		Replacement: "workspaceDir",
+		Message:     "the dir query parameter of GET /api/workspace and /api/workspace/summary is renamed workspaceDir, as on every other endpoint",
+	},
+	{
+		ID:          GenerateModelID,
+		Kind:        KindEnv,
+		Name:        "GENERATE_MODEL_ID",
+		Replacement: "GENERATE_MODEL",
+		Message:     "GENERATE_MODEL now sets the generation model for every provider, including Bedrock",
+	},
+}
+
+// All returns every registered deprecation, in ID order.
+func All() []Deprecation {
+	return slices.Clone(registry)
+}
+
+// Lookup returns the deprecation with id.
+func Lookup(id string) (Deprecation, bool) {
+	for _, d := range registry {
+		if d.ID == id {
+			return d, true
+		}
+	}
+	return Deprecation{}, false
+}
+
+// Suppressed reports whether id is listed in TFAI_SUPPRESS_DEPRECATIONS.
+func Suppressed(id string) bool {
+	for _, s := range strings.Split(os.Getenv(SuppressEnv), ",") {
+		if strings.TrimSpace(s) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Used returns the registered environment variable deprecations whose
+// deprecated variable is set and not suppressed, for `tfai doctor`.
+func Used() []Deprecation {
+	var used []Deprecation
+	for _, d := range registry {
+		if d.Kind == KindEnv && os.Getenv(d.Name) != "" && !Suppressed(d.ID) {
+			used = append(used, d)
+		}
+	}
+	return used
+}
+
+// warned records the IDs already warned about in this process.
+var warned sync.Map
+
+// Warn logs a structured WARN for the deprecation id the first time it is
+// called for id in this process, unless id is suppressed. Unknown IDs are
+// ignored.
+func Warn(log *slog.Logger, id string) {
+	d, ok := Lookup(id)
+	if !ok || Suppressed(id) {
+		return
+	}
+	if _, seen := warned.LoadOrStore(id, true); seen {
+		return
+	}
+	log.Warn("deprecated: "+d.Message,
+		slog.String("deprecation_id", d.ID),
+		slog.String("kind", string(d.Kind)),
+		slog.String("name", d.Name),
+		slog.String("replacement", d.Replacement),
+		slog.String("suppress_with", SuppressEnv+"="+d.ID),
+	)
+}
+
+// Getenv returns the value of the environment variable of the deprecation
+// id, warning once (see Warn) when it is set.
+func Getenv(log *slog.Logger, id string) string {
+	d, ok := Lookup(id)
+	if !ok || d.Kind != KindEnv {
+		return ""
+	}
+	v := os.Getenv(d.Name)
+	if v != "" {
+		Warn(log, id)
+	}
+	return v
+}
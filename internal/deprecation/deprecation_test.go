@@ -0,0 +1,115 @@
+package deprecation
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// capture returns a logger writing text lines to the returned buffer, and
+// forgets every earlier warning so each test starts as a fresh process.
+func capture(t *testing.T) (*slog.Logger, *bytes.Buffer) {
+	t.Helper()
+	warned.Clear()
+	t.Cleanup(warned.Clear)
+	var buf bytes.Buffer
+	return slog.New(slog.NewTextHandler(&buf, nil)), &buf
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	seen := make(map[string]bool)
+	for i, d := range All() {
+		if d.ID == "" || d.Name == "" || d.Replacement == "" || d.Message == "" {
+			t.Errorf("incomplete deprecation %+v", d)
+		}
+		if seen[d.ID] {
+			t.Errorf("duplicate ID %s", d.ID)
+		}
+		seen[d.ID] = true
+		if i > 0 && All()[i-1].ID > d.ID {
+			t.Errorf("registry not in ID order at %s", d.ID)
+		}
+		if !strings.HasPrefix(d.ID, string(d.Kind)+"-") {
+			t.Errorf("expected %s to start with its kind %q", d.ID, d.Kind)
+		}
+	}
+	if _, ok := Lookup("no-such-id"); ok {
+		t.Error("expected an unknown ID not to be found")
+	}
+}
+
+func TestWarn_OncePerProcess(t *testing.T) {
+	t.Setenv(SuppressEnv, "")
+	log, buf := capture(t)
+
+	for i := 0; i < 3; i++ {
+		Warn(log, GenerateModelID)
+	}
+	Warn(log, WorkspaceDirParam)
+	Warn(log, "no-such-id")
+
+	out := buf.String()
+	if n := strings.Count(out, "deprecation_id="+GenerateModelID); n != 1 {
+		t.Errorf("expected one warning for %s, got %d:\n%s", GenerateModelID, n, out)
+	}
+	if n := strings.Count(out, "level=WARN"); n != 2 {
+		t.Errorf("expected one warning per known ID, got %d:\n%s", n, out)
+	}
+	for _, want := range []string{"name=GENERATE_MODEL_ID", "replacement=GENERATE_MODEL", `suppress_with="` + SuppressEnv + "=" + GenerateModelID + `"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in the warning:\n%s", want, out)
+		}
+	}
+}
+
+func TestWarn_Suppressed(t *testing.T) {
+	t.Setenv(SuppressEnv, "other, "+GenerateModelID)
+	log, buf := capture(t)
+
+	Warn(log, GenerateModelID)
+	Warn(log, WorkspaceDirParam)
+	if out := buf.String(); strings.Contains(out, GenerateModelID) || !strings.Contains(out, WorkspaceDirParam) {
+		t.Errorf("expected only the unsuppressed warning:\n%s", out)
+	}
+}
+
+func TestGetenv(t *testing.T) {
+	t.Setenv(SuppressEnv, "")
+	log, buf := capture(t)
+
+	t.Setenv("GENERATE_MODEL_ID", "")
+	if v := Getenv(log, GenerateModelID); v != "" || buf.Len() != 0 {
+		t.Errorf("expected an unset variable to read empty without a warning, got %q:\n%s", v, buf)
+	}
+	t.Setenv("GENERATE_MODEL_ID", "anthropic.claude-v2")
+	if v := Getenv(log, GenerateModelID); v != "anthropic.claude-v2" {
+		t.Errorf("expected the deprecated value, got %q", v)
+	}
+	if !strings.Contains(buf.String(), "level=WARN") {
+		t.Errorf("expected a warning when the deprecated variable is read:\n%s", buf)
+	}
+	if v := Getenv(log, WorkspaceDirParam); v != "" {
+		t.Errorf("expected nothing for an API deprecation, got %q", v)
+	}
+}
+
+func TestUsed(t *testing.T) {
+	t.Setenv("GENERATE_MODEL_ID", "")
+	t.Setenv(SuppressEnv, "")
+	if used := Used(); len(used) != 0 {
+		t.Errorf("expected no deprecated variables in use, got %+v", used)
+	}
+
+	t.Setenv("GENERATE_MODEL_ID", "anthropic.claude-v2")
+	if used := Used(); len(used) != 1 || used[0].ID != GenerateModelID {
+		t.Errorf("expected %s in use, got %+v", GenerateModelID, used)
+	}
+
+	t.Setenv(SuppressEnv, GenerateModelID)
+	if used := Used(); len(used) != 0 {
+		t.Errorf("expected a suppressed deprecation not to be reported, got %+v", used)
+	}
+}
@@ -0,0 +1,172 @@
+// Package describe implements `tfai describe-changes`: it gathers the diff
+// of a workspace, either against the .tfai manifest of files tfai changed or
+// from git, asks the agent for a structured description of it, and renders
+// that as a pull request description or commit message.
+package describe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/filediff"
+	"github.com/54b3r/tfai-go/internal/gitutil"
+	"github.com/54b3r/tfai-go/internal/textenc"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+)
+
+// DefaultMaxDiffBytes is the diff size sent to the model when
+// Describer.MaxDiffBytes is zero.
+const DefaultMaxDiffBytes = 48 << 10 // 48 KiB
+
+// minFileBytes is the smallest slice of a file's diff worth sending; a file
+// that would get less is left out entirely.
+const minFileBytes = 1 << 10 // 1 KiB
+
+// ErrNoChanges is returned by Gather when there is nothing to describe.
+var ErrNoChanges = errors.New("describe: no changes to describe")
+
+// Source selects where Gather reads the changes from.
+type Source string
+
+const (
+	// SourceAuto uses the manifest when tfai has recorded one, and git
+	// otherwise.
+	SourceAuto Source = ""
+	// SourceManifest diffs the workspace against the baselines in the .tfai
+	// manifest: every file tfai changed since the manifest was last reset.
+	SourceManifest Source = "manifest"
+	// SourceGit diffs the workspace against git HEAD, including untracked
+	// files.
+	SourceGit Source = "git"
+)
+
+// Querier is the subset of agent.TerraformAgent used by the describer.
+type Querier interface {
+	Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error)
+}
+
+// Gather returns the changes in dir from src, one entry per file sorted by
+// path, and the source actually used. It returns ErrNoChanges when the diff
+// is empty.
+func Gather(ctx context.Context, dir string, src Source) ([]filediff.FileDiff, Source, error) {
+	if src == SourceAuto {
+		src = SourceGit
+		if _, err := tfaidir.LoadManifest(dir); err == nil {
+			src = SourceManifest
+		}
+	}
+	var (
+		diffs []filediff.FileDiff
+		err   error
+	)
+	switch src {
+	case SourceManifest:
+		diffs, err = ManifestDiff(dir)
+	case SourceGit:
+		diffs, err = gitutil.Diff(ctx, dir)
+	default:
+		return nil, src, fmt.Errorf("describe: unknown source %q", src)
+	}
+	if err != nil {
+		return nil, src, err //nolint:wrapcheck // tfaidir and gitutil errors are already prefixed
+	}
+	if len(diffs) == 0 {
+		return nil, src, ErrNoChanges
+	}
+	return diffs, src, nil
+}
+
+// ManifestDiff diffs every file recorded in the manifest of dir against its
+// current content. Files that are back to their baseline are left out.
+func ManifestDiff(dir string) ([]filediff.FileDiff, error) {
+	m, err := tfaidir.LoadManifest(dir)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // tfaidir errors are already prefixed
+	}
+	var diffs []filediff.FileDiff
+	for rel, base := range m.Files {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		exists := err == nil
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("describe: failed to read %s: %w", rel, err)
+		}
+		text, decodeErr := textenc.Decode(b)
+		switch {
+		case !base.Existed && !exists:
+			continue
+		case base.Binary || (exists && decodeErr != nil):
+			diffs = append(diffs, filediff.FileDiff{Path: rel, Diff: "Binary file " + rel + " changed\n"})
+		default:
+			if d := filediff.Unified(rel, base.Content, text.Content); d != "" {
+				diffs = append(diffs, filediff.FileDiff{Path: rel, Diff: d})
+			}
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+// Truncate fits diffs into maxBytes at file granularity. Files are taken in
+// order while they fit; the first file that does not is cut at a line
+// boundary if at least minFileBytes of it fit, and every file after that is
+// left out. It returns the diffs to send and the paths left out.
+func Truncate(diffs []filediff.FileDiff, maxBytes int) (kept []filediff.FileDiff, omitted []string) {
+	remaining := maxBytes
+	for _, d := range diffs {
+		switch {
+		case len(d.Diff) <= remaining:
+			kept = append(kept, d)
+			remaining -= len(d.Diff)
+		case remaining >= minFileBytes:
+			cut := d.Diff[:remaining]
+			if i := strings.LastIndexByte(cut, '\n'); i >= 0 {
+				cut = cut[:i+1]
+			}
+			cut += fmt.Sprintf("... (diff truncated, %d more bytes)\n", len(d.Diff)-len(cut))
+			kept = append(kept, filediff.FileDiff{Path: d.Path, Diff: cut})
+			remaining = 0
+		default:
+			omitted = append(omitted, d.Path)
+			remaining = 0
+		}
+	}
+	return kept, omitted
+}
+
+// Describer asks the agent to describe a set of changes.
+type Describer struct {
+	// Querier produces the description.
+	Querier Querier
+	// MaxDiffBytes caps the diff sent to the model. Defaults to
+	// DefaultMaxDiffBytes.
+	MaxDiffBytes int
+}
+
+// Describe truncates diffs to MaxDiffBytes, asks the agent for a
+// description, and parses its answer. The query neither reads nor extends
+// the workspace conversation.
+func (d *Describer) Describe(ctx context.Context, diffs []filediff.FileDiff) (*Description, error) {
+	maxBytes := d.MaxDiffBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxDiffBytes
+	}
+	kept, omitted := Truncate(diffs, maxBytes)
+
+	var out bytes.Buffer
+	_, err := d.Querier.Run(ctx, agent.QueryRequest{
+		Message: BuildPrompt(kept, omitted),
+		Output:  &out,
+		Options: agent.QueryOptions{NoHistory: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("describe: agent query failed: %w", err)
+	}
+	return Parse(out.String())
+}
@@ -0,0 +1,222 @@
+package describe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/filediff"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+)
+
+// ---------------------------------------------------------------------------
+// Fakes and helpers
+// ---------------------------------------------------------------------------
+
+// fakeQuerier answers every query with answer and records the request.
+type fakeQuerier struct {
+	answer string
+	err    error
+	req    agent.QueryRequest
+}
+
+func (q *fakeQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	q.req = req
+	if q.err != nil {
+		return nil, q.err
+	}
+	_, _ = io.WriteString(req.Output, q.answer)
+	return &agent.QueryResult{}, nil
+}
+
+// write writes content to rel under dir.
+func write(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, rel), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// git runs a git command in dir, failing the test on error.
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", args[0], err, out)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Gather
+// ---------------------------------------------------------------------------
+
+func TestGather_Manifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write(t, dir, "main.tf", "a\nb\n")
+	write(t, dir, "same.tf", "unchanged\n")
+	if err := tfaidir.RecordBaseline(dir, []string{"main.tf", "new.tf", "same.tf", "never.tf"}); err != nil {
+		t.Fatal(err)
+	}
+	write(t, dir, "main.tf", "a\nc\n")
+	write(t, dir, "new.tf", "x\n")
+
+	diffs, src, err := Gather(context.Background(), dir, SourceAuto)
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	if src != SourceManifest {
+		t.Errorf("expected the manifest to be picked, got %q", src)
+	}
+	if len(diffs) != 2 || diffs[0].Path != "main.tf" || diffs[1].Path != "new.tf" {
+		t.Fatalf("expected diffs of main.tf and new.tf, got %+v", diffs)
+	}
+	if !strings.Contains(diffs[0].Diff, "-b\n+c\n") {
+		t.Errorf("unexpected main.tf diff:\n%s", diffs[0].Diff)
+	}
+	if !strings.Contains(diffs[1].Diff, "--- /dev/null\n+++ b/new.tf\n") {
+		t.Errorf("unexpected new.tf diff:\n%s", diffs[1].Diff)
+	}
+}
+
+func TestGather_Git(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git(t, dir, "init", "--quiet")
+	git(t, dir, "config", "user.name", "tfai test")
+	git(t, dir, "config", "user.email", "test@example.com")
+	git(t, dir, "config", "commit.gpgsign", "false")
+	write(t, dir, "main.tf", "a\n")
+	git(t, dir, "add", ".")
+	git(t, dir, "commit", "--quiet", "-m", "init")
+
+	if _, _, err := Gather(context.Background(), dir, SourceAuto); !errors.Is(err, ErrNoChanges) {
+		t.Fatalf("expected ErrNoChanges for a clean tree, got %v", err)
+	}
+
+	write(t, dir, "main.tf", "b\n")
+	diffs, src, err := Gather(context.Background(), dir, SourceAuto)
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	if src != SourceGit {
+		t.Errorf("expected git without a manifest, got %q", src)
+	}
+	if len(diffs) != 1 || !strings.Contains(diffs[0].Diff, "-a\n+b\n") {
+		t.Errorf("unexpected diffs: %+v", diffs)
+	}
+}
+
+func TestGather_ManifestMissing(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := Gather(context.Background(), t.TempDir(), SourceManifest)
+	if !errors.Is(err, tfaidir.ErrNoManifest) {
+		t.Fatalf("expected ErrNoManifest, got %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Truncate
+// ---------------------------------------------------------------------------
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+
+	big := strings.Repeat("+0123456789abcde\n", 200) // 3400 bytes
+	diffs := []filediff.FileDiff{
+		{Path: "a.tf", Diff: "+a\n"},
+		{Path: "b.tf", Diff: big},
+		{Path: "c.tf", Diff: "+c\n"},
+	}
+
+	tests := []struct {
+		name        string
+		maxBytes    int
+		wantKept    []string
+		wantOmitted []string
+		wantCut     bool
+	}{
+		{name: "everything fits", maxBytes: 1 << 20, wantKept: []string{"a.tf", "b.tf", "c.tf"}},
+		{name: "large file cut", maxBytes: 2000, wantKept: []string{"a.tf", "b.tf"}, wantOmitted: []string{"c.tf"}, wantCut: true},
+		{name: "too little room to cut", maxBytes: 500, wantKept: []string{"a.tf"}, wantOmitted: []string{"b.tf", "c.tf"}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			kept, omitted := Truncate(diffs, tc.maxBytes)
+			var paths []string
+			size := 0
+			for _, d := range kept {
+				paths = append(paths, d.Path)
+				size += len(d.Diff)
+			}
+			if strings.Join(paths, ",") != strings.Join(tc.wantKept, ",") {
+				t.Errorf("kept %v, want %v", paths, tc.wantKept)
+			}
+			if strings.Join(omitted, ",") != strings.Join(tc.wantOmitted, ",") {
+				t.Errorf("omitted %v, want %v", omitted, tc.wantOmitted)
+			}
+			cut := strings.Contains(kept[len(kept)-1].Diff, "diff truncated")
+			if cut != tc.wantCut {
+				t.Errorf("truncation marker present = %v, want %v", cut, tc.wantCut)
+			}
+			if tc.wantCut && size > tc.maxBytes+100 {
+				t.Errorf("kept %d bytes, well over the %d limit", size, tc.maxBytes)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Describe
+// ---------------------------------------------------------------------------
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	q := &fakeQuerier{answer: "```json\n" + `{"title":"Rename the VPC","summary":"s","security_notes":[],"breaking_changes":["Replaces aws_vpc.main"],"test_plan":["plan"]}` + "\n```"}
+	d := &Describer{Querier: q}
+	got, err := d.Describe(context.Background(), []filediff.FileDiff{{Path: "main.tf", Diff: "-old\n+new\n"}})
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if got.Title != "Rename the VPC" || len(got.BreakingChanges) != 1 {
+		t.Errorf("unexpected description: %+v", got)
+	}
+	if !q.req.Options.NoHistory || q.req.WorkspaceDir != "" {
+		t.Errorf("expected a history-free query without a workspace, got %+v", q.req)
+	}
+	if !strings.Contains(q.req.Message, "-old\n+new\n") {
+		t.Errorf("prompt does not contain the diff:\n%s", q.req.Message)
+	}
+}
+
+func TestDescribe_Errors(t *testing.T) {
+	t.Parallel()
+
+	diffs := []filediff.FileDiff{{Path: "main.tf", Diff: "+x\n"}}
+
+	_, err := (&Describer{Querier: &fakeQuerier{err: errors.New("model down")}}).Describe(context.Background(), diffs)
+	if err == nil || !strings.Contains(err.Error(), "model down") {
+		t.Errorf("expected the agent error, got %v", err)
+	}
+
+	_, err = (&Describer{Querier: &fakeQuerier{answer: "Sure! Looks good."}}).Describe(context.Background(), diffs)
+	if err == nil || !strings.Contains(err.Error(), "no JSON object") {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package describe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/filediff"
+)
+
+// Description is the agent's structured description of a set of changes.
+type Description struct {
+	// Title is a one-line summary in the imperative mood.
+	Title string `json:"title"`
+	// Summary explains what changed and why, in a few sentences.
+	Summary string `json:"summary"`
+	// SecurityNotes lists changes to IAM, network exposure, encryption, or
+	// secrets handling.
+	SecurityNotes []string `json:"security_notes"`
+	// BreakingChanges lists resource replacements, destroys, and changed
+	// module interfaces.
+	BreakingChanges []string `json:"breaking_changes"`
+	// TestPlan lists the steps to verify the change.
+	TestPlan []string `json:"test_plan"`
+}
+
+// BuildPrompt assembles the agent prompt describing diffs. omitted lists the
+// files whose diffs were left out to fit the size limit.
+func BuildPrompt(diffs []filediff.FileDiff, omitted []string) string {
+	var b strings.Builder
+	b.WriteString(`Describe the following changes to a Terraform workspace for a commit message and pull request.
+
+Respond with only a JSON object of this form, with no other text:
+{"title": "...", "summary": "...", "security_notes": ["..."], "breaking_changes": ["..."], "test_plan": ["..."]}
+
+- title: one line in the imperative mood, under 72 characters.
+- summary: what changed and why, in a few sentences.
+- security_notes: changes to IAM, network exposure, encryption, or secrets handling; [] if none.
+- breaking_changes: resources that will be replaced or destroyed, and renamed or removed variables, outputs, or module inputs; [] if none.
+- test_plan: concrete steps to verify the change, such as what terraform plan should show.
+
+`)
+	for _, d := range diffs {
+		fmt.Fprintf(&b, "File %s:\n```diff\n%s```\n\n", d.Path, d.Diff)
+	}
+	if len(omitted) > 0 {
+		b.WriteString("These files also changed, but their diffs were left out to fit the size limit:\n")
+		for _, p := range omitted {
+			fmt.Fprintf(&b, "- %s\n", p)
+		}
+	}
+	return b.String()
+}
+
+// rawDescription mirrors Description with the list fields left raw, so a
+// model that answers a list with a single string is still understood.
+type rawDescription struct {
+	Title           string          `json:"title"`
+	Summary         string          `json:"summary"`
+	SecurityNotes   json.RawMessage `json:"security_notes"`
+	BreakingChanges json.RawMessage `json:"breaking_changes"`
+	TestPlan        json.RawMessage `json:"test_plan"`
+}
+
+// Parse extracts the Description from the agent's answer. Markdown code
+// fences and any text around the JSON object are ignored.
+func Parse(answer string) (*Description, error) {
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("describe: the agent's answer contains no JSON object")
+	}
+	var raw rawDescription
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("describe: invalid JSON in the agent's answer: %w", err)
+	}
+	d := &Description{
+		Title:   strings.TrimSpace(raw.Title),
+		Summary: strings.TrimSpace(raw.Summary),
+	}
+	if d.Title == "" {
+		return nil, errors.New("describe: the agent's answer has no title")
+	}
+	var err error
+	if d.SecurityNotes, err = parseList("security_notes", raw.SecurityNotes); err != nil {
+		return nil, err
+	}
+	if d.BreakingChanges, err = parseList("breaking_changes", raw.BreakingChanges); err != nil {
+		return nil, err
+	}
+	if d.TestPlan, err = parseList("test_plan", raw.TestPlan); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// parseList decodes a list field that may be missing, null, a single
+// string, or a list of strings. Blank entries are dropped.
+func parseList(field string, raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return []string{}, nil
+	}
+	var items []string
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			return nil, fmt.Errorf("describe: %s must be a list of strings: %w", field, err)
+		}
+		items = []string{s}
+	}
+	out := []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+// Markdown renders d as a pull request description.
+func (d *Description) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", d.Title)
+	if d.Summary != "" {
+		fmt.Fprintf(&b, "%s\n\n", d.Summary)
+	}
+	writeSection(&b, "Security notes", d.SecurityNotes)
+	writeSection(&b, "Breaking changes", d.BreakingChanges)
+	writeSection(&b, "Test plan", d.TestPlan)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeSection writes a markdown section listing items, or "None." when
+// there are none.
+func writeSection(b *strings.Builder, heading string, items []string) {
+	fmt.Fprintf(b, "## %s\n\n", heading)
+	if len(items) == 0 {
+		b.WriteString("None.\n\n")
+		return
+	}
+	for _, item := range items {
+		fmt.Fprintf(b, "- %s\n", item)
+	}
+	b.WriteString("\n")
+}
+
+// CommitMessage renders d as a git commit message: the title, a blank line,
+// the summary, and any breaking changes.
+func (d *Description) CommitMessage() string {
+	var b strings.Builder
+	b.WriteString(d.Title)
+	b.WriteString("\n")
+	if d.Summary != "" {
+		fmt.Fprintf(&b, "\n%s\n", d.Summary)
+	}
+	if len(d.BreakingChanges) > 0 {
+		b.WriteString("\nBreaking changes:\n")
+		for _, item := range d.BreakingChanges {
+			fmt.Fprintf(&b, "- %s\n", item)
+		}
+	}
+	return b.String()
+}
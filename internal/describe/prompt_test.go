@@ -0,0 +1,160 @@
+package describe
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/filediff"
+)
+
+func TestBuildPrompt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		diffs   []filediff.FileDiff
+		omitted []string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:  "diffs only",
+			diffs: []filediff.FileDiff{{Path: "main.tf", Diff: "--- a/main.tf\n+++ b/main.tf\n@@ -1 +1 @@\n-a\n+b\n"}},
+			want: []string{
+				`"security_notes"`,
+				`"breaking_changes"`,
+				`"test_plan"`,
+				"File main.tf:\n```diff\n--- a/main.tf\n",
+				"+b\n```",
+			},
+			notWant: []string{"left out"},
+		},
+		{
+			name:    "omitted files",
+			diffs:   []filediff.FileDiff{{Path: "a.tf", Diff: "+x\n"}},
+			omitted: []string{"b.tf", "c.tf"},
+			want:    []string{"left out to fit the size limit", "- b.tf\n- c.tf\n"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := BuildPrompt(tc.diffs, tc.omitted)
+			for _, w := range tc.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("prompt missing %q:\n%s", w, got)
+				}
+			}
+			for _, w := range tc.notWant {
+				if strings.Contains(got, w) {
+					t.Errorf("prompt unexpectedly contains %q:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		answer  string
+		want    Description
+		wantErr string
+	}{
+		{
+			name:   "plain JSON",
+			answer: `{"title":"Add a bucket","summary":"Adds logs.","security_notes":["Bucket is private"],"breaking_changes":[],"test_plan":["Run plan"]}`,
+			want: Description{
+				Title: "Add a bucket", Summary: "Adds logs.",
+				SecurityNotes: []string{"Bucket is private"}, BreakingChanges: []string{}, TestPlan: []string{"Run plan"},
+			},
+		},
+		{
+			name:   "fenced with prose",
+			answer: "Here it is:\n```json\n{\"title\": \" Add a bucket \", \"summary\": \"s\"}\n```\nDone.",
+			want: Description{
+				Title: "Add a bucket", Summary: "s",
+				SecurityNotes: []string{}, BreakingChanges: []string{}, TestPlan: []string{},
+			},
+		},
+		{
+			name:   "string instead of list",
+			answer: `{"title":"t","breaking_changes":"Replaces the VPC","test_plan":["", " plan "],"security_notes":null}`,
+			want: Description{
+				Title: "t", SecurityNotes: []string{}, BreakingChanges: []string{"Replaces the VPC"}, TestPlan: []string{"plan"},
+			},
+		},
+		{name: "no JSON", answer: "I cannot help with that.", wantErr: "no JSON object"},
+		{name: "invalid JSON", answer: `{"title": }`, wantErr: "invalid JSON"},
+		{name: "missing title", answer: `{"summary":"s"}`, wantErr: "no title"},
+		{name: "bad list", answer: `{"title":"t","test_plan":3}`, wantErr: "test_plan must be a list"},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := Parse(tc.answer)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got.Title != tc.want.Title || got.Summary != tc.want.Summary ||
+				!equal(got.SecurityNotes, tc.want.SecurityNotes) ||
+				!equal(got.BreakingChanges, tc.want.BreakingChanges) ||
+				!equal(got.TestPlan, tc.want.TestPlan) {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+// equal reports whether a and b hold the same strings; nil and empty differ.
+func equal(a, b []string) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDescriptionRendering(t *testing.T) {
+	t.Parallel()
+
+	d := &Description{
+		Title:           "Add a logging bucket",
+		Summary:         "Adds an S3 bucket for access logs.",
+		BreakingChanges: []string{"Replaces aws_s3_bucket.old"},
+		TestPlan:        []string{"terraform plan shows 1 to add"},
+	}
+
+	md := d.Markdown()
+	for _, w := range []string{
+		"# Add a logging bucket\n\nAdds an S3 bucket for access logs.\n\n",
+		"## Security notes\n\nNone.\n",
+		"## Breaking changes\n\n- Replaces aws_s3_bucket.old\n",
+		"## Test plan\n\n- terraform plan shows 1 to add",
+	} {
+		if !strings.Contains(md, w) {
+			t.Errorf("markdown missing %q:\n%s", w, md)
+		}
+	}
+
+	want := "Add a logging bucket\n\nAdds an S3 bucket for access logs.\n\nBreaking changes:\n- Replaces aws_s3_bucket.old\n"
+	if got := d.CommitMessage(); got != want {
+		t.Errorf("CommitMessage() = %q, want %q", got, want)
+	}
+}
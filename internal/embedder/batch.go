@@ -0,0 +1,41 @@
+package embedder
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// openaiMaxBatch is the most inputs the embeddings endpoint accepts per
+	// call.
+	openaiMaxBatch = 2048
+	// ollamaMaxBatch bounds the inputs sent to /api/embed per call, so one
+	// request never holds the model for long and cancellation is noticed
+	// between requests.
+	ollamaMaxBatch = 256
+)
+
+// embedBatches splits texts into batches of at most size and embeds them in
+// order with embed, one request at a time. ctx is checked before every
+// batch. When ctx is done, before or during a batch, the context error is
+// returned wrapped with the batch reached, so callers can tell how far a
+// cancelled call got; other errors are returned as embed produced them.
+func embedBatches(ctx context.Context, backend string, texts []string, size int, embed func(context.Context, []string) ([][]float32, error)) ([][]float32, error) {
+	batches := (len(texts) + size - 1) / size
+	embeddings := make([][]float32, 0, len(texts))
+	for i := 0; i < batches; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("%s embedder: cancelled at batch %d of %d: %w", backend, i+1, batches, err)
+		}
+		start := i * size
+		vecs, err := embed(ctx, texts[start:min(start+size, len(texts))])
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("%s embedder: cancelled at batch %d of %d: %w", backend, i+1, batches, ctxErr)
+			}
+			return nil, err
+		}
+		embeddings = append(embeddings, vecs...)
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,159 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// slowEmbedServer serves the Ollama /api/embed and OpenAI /embeddings
+// endpoints, embedding each text "t<N>" as the one-element vector {N}. The
+// request numbered hang (1-based) signals started and then blocks until the
+// client goes away.
+type slowEmbedServer struct {
+	mu      sync.Mutex
+	batches []int
+	hang    int
+	started chan struct{}
+}
+
+func (f *slowEmbedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input []string `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.batches = append(f.batches, len(req.Input))
+	n := len(f.batches)
+	f.mu.Unlock()
+	if n == f.hang {
+		close(f.started)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+		return
+	}
+
+	vecs := make([][]float32, len(req.Input))
+	for i, text := range req.Input {
+		v, _ := strconv.Atoi(strings.TrimPrefix(text, "t"))
+		vecs[i] = []float32{float32(v)}
+	}
+	if strings.HasSuffix(r.URL.Path, "/api/embed") {
+		_ = json.NewEncoder(w).Encode(ollamaEmbedResponse{Embeddings: vecs})
+		return
+	}
+	var resp openaiEmbedResponse
+	for i, v := range vecs {
+		resp.Data = append(resp.Data, struct {
+			Embedding []float32 `json:"embedding"`
+			Index     int       `json:"index"`
+		}{Embedding: v, Index: i})
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// batchedEmbedders returns an Ollama and an OpenAI embedder pointed at srv,
+// each sending at most two texts per request.
+func batchedEmbedders(t *testing.T, srv http.Handler) map[string]rag.Embedder {
+	t.Helper()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	ollama := NewOllamaEmbedder(&OllamaConfig{Host: ts.URL, Model: "m"})
+	ollama.maxBatch = 2
+	openai := NewOpenAIEmbedder(&OpenAIConfig{BaseURL: ts.URL, APIKey: "k", Model: "m"})
+	openai.maxBatch = 2
+	return map[string]rag.Embedder{"ollama": ollama, "openai": openai}
+}
+
+func TestEmbedBatches(t *testing.T) {
+	t.Parallel()
+
+	srv := &slowEmbedServer{}
+	for name, e := range batchedEmbedders(t, srv) {
+		srv.mu.Lock()
+		srv.batches = nil
+		srv.mu.Unlock()
+
+		vecs, err := e.Embed(context.Background(), texts(5))
+		if err != nil {
+			t.Fatalf("%s: Embed: %v", name, err)
+		}
+		for i, v := range vecs {
+			if len(v) != 1 || v[0] != float32(i) {
+				t.Errorf("%s: embedding %d: got %v", name, i, v)
+			}
+		}
+		if got := srv.batches; len(got) != 3 || got[0] != 2 || got[2] != 1 {
+			t.Errorf("%s: expected batches of 2, 2, and 1, got %v", name, got)
+		}
+	}
+}
+
+func TestEmbedBatches_CancelledMidBatch(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"ollama", "openai"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := &slowEmbedServer{hang: 2, started: make(chan struct{})}
+			e := batchedEmbedders(t, srv)[name]
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				<-srv.started
+				cancel()
+			}()
+
+			// The hung batch would hold the call for 10s.
+			begin := time.Now()
+			_, err := e.Embed(ctx, texts(5))
+			elapsed := time.Since(begin)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+			if !strings.Contains(err.Error(), "batch 2 of 3") {
+				t.Errorf("expected the batch reached in %q", err)
+			}
+			if elapsed > 2*time.Second {
+				t.Errorf("expected a prompt return after cancel, took %v", elapsed)
+			}
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			if len(srv.batches) != 2 {
+				t.Errorf("expected no batch after the cancelled one, got %v", srv.batches)
+			}
+		})
+	}
+}
+
+func TestEmbedBatches_CancelledBeforeStart(t *testing.T) {
+	t.Parallel()
+
+	srv := &slowEmbedServer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, e := range batchedEmbedders(t, srv) {
+		_, err := e.Embed(ctx, texts(3))
+		if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "batch 1 of 2") {
+			t.Errorf("%s: expected cancellation at batch 1 of 2, got %v", name, err)
+		}
+	}
+	if len(srv.batches) != 0 {
+		t.Errorf("expected no requests, got %v", srv.batches)
+	}
+}
@@ -20,6 +20,8 @@ const (
 	defaultOllamaDimensions = 768
 	// defaultOpenAIDimensions is the output dimension of text-embedding-3-small.
 	defaultOpenAIDimensions = 1536
+	// defaultGeminiDimensions is the output dimension of text-embedding-004.
+	defaultGeminiDimensions = 768
 )
 
 // DefaultDimensions returns the correct default embedding vector size for the
@@ -33,6 +35,8 @@ func DefaultDimensions(backend string) int {
 	switch backend {
 	case "ollama":
 		return defaultOllamaDimensions
+	case "gemini":
+		return defaultGeminiDimensions
 	default:
 		return defaultOpenAIDimensions
 	}
@@ -49,7 +53,7 @@ func DefaultDimensions(backend string) int {
 //  3. EMBEDDING_MODEL — overrides the default model for the resolved backend
 //  4. EMBEDDING_API_KEY — overrides the inherited API key
 //  5. EMBEDDING_ENDPOINT — overrides the inherited endpoint
-//  6. EMBEDDING_DIMENSIONS — overrides the default dimensions (ollama: 768, openai/azure: 1536)
+//  6. EMBEDDING_DIMENSIONS — overrides the default dimensions (ollama/gemini: 768, openai/azure: 1536)
 func NewFromEnv() (rag.Embedder, error) {
 	// 1. Resolve provider — fall back to MODEL_PROVIDER, then "ollama".
 	backend := getEnv("EMBEDDING_PROVIDER")
@@ -122,14 +126,51 @@ func NewFromEnv() (rag.Embedder, error) {
 		return nil, fmt.Errorf("embedder: bedrock embedding support is not yet implemented (model: %s)", defaultBedrockModel)
 
 	case "gemini":
-		// Future: implement GeminiEmbedder. For now, return an error.
-		return nil, fmt.Errorf("embedder: gemini embedding support is not yet implemented (model: %s)", defaultGeminiModel)
+		dims := getEnvInt("EMBEDDING_DIMENSIONS", defaultGeminiDimensions)
+		apiKey := getEnv("EMBEDDING_API_KEY")
+		if apiKey == "" {
+			apiKey = getEnv("GOOGLE_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("embedder: gemini requires GOOGLE_API_KEY or EMBEDDING_API_KEY")
+		}
+		model := getEnvOrDefault("EMBEDDING_MODEL", defaultGeminiModel)
+		return NewGeminiEmbedder(&GeminiConfig{
+			BaseURL:    getEnv("EMBEDDING_ENDPOINT"),
+			APIKey:     apiKey,
+			Model:      model,
+			Dimensions: dims,
+		}), nil
 
 	default:
 		return nil, fmt.Errorf("embedder: unknown backend %q — valid values: ollama, openai, azure, bedrock, gemini", backend)
 	}
 }
 
+// IDFromEnv identifies the embedder NewFromEnv would construct as
+// "<backend>/<model>@<dimensions>", resolving the same defaults. Ingestion
+// records it so a run is never resumed with a different embedding model.
+func IDFromEnv() string {
+	backend := getEnv("EMBEDDING_PROVIDER")
+	if backend == "" {
+		backend = getEnvOrDefault("MODEL_PROVIDER", "ollama")
+	}
+	model := getEnv("EMBEDDING_MODEL")
+	if model == "" {
+		switch backend {
+		case "ollama":
+			model = defaultOllamaModel
+		case "bedrock":
+			model = defaultBedrockModel
+		case "gemini":
+			model = defaultGeminiModel
+		default:
+			model = defaultOpenAIModel
+		}
+	}
+	return fmt.Sprintf("%s/%s@%d", backend, model, DefaultDimensions(backend))
+}
+
 // getEnv returns the value of the named environment variable, or empty string.
 func getEnv(key string) string {
 	return os.Getenv(key)
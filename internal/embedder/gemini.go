@@ -0,0 +1,205 @@
+package embedder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/httpx"
+)
+
+const (
+	// defaultGeminiBaseURL is the Generative Language API base URL.
+	defaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	// geminiMaxBatch is the most texts batchEmbedContents accepts per call.
+	geminiMaxBatch = 100
+	// geminiConcurrency bounds the batch requests in flight for one Embed
+	// call, so a large ingestion run stays well inside per-minute quotas.
+	geminiConcurrency = 4
+)
+
+// GeminiEmbedder implements rag.Embedder using the Generative Language API
+// batchEmbedContents endpoint. It is safe for concurrent use.
+type GeminiEmbedder struct {
+	// baseURL is the API base (e.g. "https://generativelanguage.googleapis.com/v1beta").
+	baseURL string
+	// apiKey is the Google API key, sent in the x-goog-api-key header.
+	apiKey string
+	// model is the embedding model name (e.g. "text-embedding-004").
+	model string
+	// dimensions is the desired embedding vector length (0 = model default).
+	dimensions int
+	// client is the shared HTTP client with a sensible timeout.
+	client *http.Client
+}
+
+// GeminiConfig holds the settings for constructing a GeminiEmbedder.
+type GeminiConfig struct {
+	// BaseURL is the API base URL. Empty selects the public endpoint.
+	BaseURL string
+	// APIKey is the Google API key.
+	APIKey string
+	// Model is the embedding model name (e.g. "text-embedding-004").
+	Model string
+	// Dimensions is the desired vector length (0 = model default).
+	Dimensions int
+}
+
+// NewGeminiEmbedder constructs a GeminiEmbedder from the given config.
+func NewGeminiEmbedder(cfg *GeminiConfig) *GeminiEmbedder {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultGeminiBaseURL
+	}
+	return &GeminiEmbedder{
+		baseURL:    baseURL,
+		apiKey:     cfg.APIKey,
+		model:      cfg.Model,
+		dimensions: cfg.Dimensions,
+		client:     httpx.NewClient(httpx.Options{Component: "embedder", Timeout: 30 * time.Second}),
+	}
+}
+
+// geminiPart is one part of a Gemini content object.
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// geminiContent is the content to embed.
+type geminiContent struct {
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiEmbedContentRequest is one entry of a batchEmbedContents request.
+type geminiEmbedContentRequest struct {
+	Model                string        `json:"model"`
+	Content              geminiContent `json:"content"`
+	OutputDimensionality int           `json:"outputDimensionality,omitempty"`
+}
+
+// geminiBatchRequest is the JSON body sent to batchEmbedContents.
+type geminiBatchRequest struct {
+	Requests []geminiEmbedContentRequest `json:"requests"`
+}
+
+// geminiBatchResponse is the JSON body returned from batchEmbedContents.
+// Embeddings are in request order.
+type geminiBatchResponse struct {
+	Embeddings []struct {
+		Values []float32 `json:"values"`
+	} `json:"embeddings"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Embed converts a batch of texts into their corresponding embeddings.
+// The returned slice is parallel to the input slice. Inputs larger than the
+// API's batch limit are split into several requests, sent concurrently, and
+// reassembled in order. Once ctx is done no further batch is started, and
+// the context error is returned wrapped with the number of batches begun.
+func (e *GeminiEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, geminiConcurrency)
+	batches := (len(texts) + geminiMaxBatch - 1) / geminiMaxBatch
+	started := 0
+	for start := 0; start < len(texts); start += geminiMaxBatch {
+		end := min(start+geminiMaxBatch, len(texts))
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+		started++
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			vecs, err := e.embedBatch(ctx, texts[start:end])
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			copy(embeddings[start:end], vecs)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("gemini embedder: cancelled after starting %d of %d batches: %w", started, batches, err)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return embeddings, nil
+}
+
+// embedBatch embeds at most geminiMaxBatch texts with one batchEmbedContents
+// call.
+func (e *GeminiEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	model := "models/" + e.model
+	body := geminiBatchRequest{Requests: make([]geminiEmbedContentRequest, len(texts))}
+	for i, text := range texts {
+		body.Requests[i] = geminiEmbedContentRequest{
+			Model:                model,
+			Content:              geminiContent{Parts: []geminiPart{{Text: text}}},
+			OutputDimensionality: e.dimensions,
+		}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("gemini embedder: marshal request: %w", err)
+	}
+
+	url := e.baseURL + "/" + model + ":batchEmbedContents"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("gemini embedder: create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", e.apiKey)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gemini embedder: request failed: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var result geminiBatchResponse
+	decodeErr := json.NewDecoder(resp.Body).Decode(&result)
+
+	// Check status before the decode error so a 429 or 503 stays retryable
+	// even when its body is not JSON.
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := ""
+		if result.Error != nil {
+			msg = result.Error.Message
+		}
+		return nil, newStatusError("gemini", resp, msg)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("gemini embedder: decode response: %w", decodeErr)
+	}
+
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("gemini embedder: expected %d embeddings, got %d", len(texts), len(result.Embeddings))
+	}
+
+	embeddings := make([][]float32, len(texts))
+	for i, emb := range result.Embeddings {
+		embeddings[i] = emb.Values
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,215 @@
+package embedder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGemini serves batchEmbedContents, embedding each text "t<N>" as the
+// one-element vector {N}. delay holds back the response for batches
+// starting with a given text; a batch starting with fail is rejected.
+type fakeGemini struct {
+	mu      sync.Mutex
+	batches []int
+	delay   map[string]time.Duration
+	fail    string
+}
+
+func (f *fakeGemini) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/models/text-embedding-004:batchEmbedContents" || r.Header.Get("x-goog-api-key") != "test-key" {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintf(w, `{"error":{"message":"unexpected request %s"}}`, r.URL.Path)
+		return
+	}
+	var req geminiBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Requests) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(req.Requests) > geminiMaxBatch {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, `{"error":{"message":"at most %d requests can be in one batch"}}`, geminiMaxBatch)
+		return
+	}
+	first := req.Requests[0].Content.Parts[0].Text
+	if first == f.fail {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, `{"error":{"message":"text too long"}}`)
+		return
+	}
+	f.mu.Lock()
+	f.batches = append(f.batches, len(req.Requests))
+	f.mu.Unlock()
+	time.Sleep(f.delay[first])
+
+	var resp geminiBatchResponse
+	for _, r := range req.Requests {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.Content.Parts[0].Text, "t"))
+		resp.Embeddings = append(resp.Embeddings, struct {
+			Values []float32 `json:"values"`
+		}{Values: []float32{float32(n)}})
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// newTestGemini returns an embedder pointed at handler.
+func newTestGemini(t *testing.T, handler http.Handler) *GeminiEmbedder {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	return NewGeminiEmbedder(&GeminiConfig{BaseURL: ts.URL, APIKey: "test-key", Model: defaultGeminiModel})
+}
+
+// texts returns "t0" through "t<n-1>".
+func texts(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = "t" + strconv.Itoa(i)
+	}
+	return out
+}
+
+func TestGeminiEmbedder_Batching(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		n           int
+		wantBatches []int
+	}{
+		{name: "single batch", n: 3, wantBatches: []int{3}},
+		{name: "exactly the limit", n: geminiMaxBatch, wantBatches: []int{geminiMaxBatch}},
+		{name: "split", n: 2*geminiMaxBatch + 50, wantBatches: []int{50, geminiMaxBatch, geminiMaxBatch}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			f := &fakeGemini{}
+			got, err := newTestGemini(t, f).Embed(context.Background(), texts(tc.n))
+			if err != nil {
+				t.Fatalf("Embed: %v", err)
+			}
+			if len(got) != tc.n {
+				t.Fatalf("want %d embeddings, got %d", tc.n, len(got))
+			}
+			for i, vec := range got {
+				if len(vec) != 1 || vec[0] != float32(i) {
+					t.Fatalf("embedding %d belongs to another text: %v", i, vec)
+				}
+			}
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			sizes := fmt.Sprint(sortedInts(f.batches))
+			if want := fmt.Sprint(tc.wantBatches); sizes != want {
+				t.Errorf("want batch sizes %s, got %s", want, sizes)
+			}
+		})
+	}
+}
+
+// TestGeminiEmbedder_OutOfOrderBatches delays the first batch so the later
+// ones complete before it.
+func TestGeminiEmbedder_OutOfOrderBatches(t *testing.T) {
+	t.Parallel()
+
+	f := &fakeGemini{delay: map[string]time.Duration{"t0": 100 * time.Millisecond}}
+	got, err := newTestGemini(t, f).Embed(context.Background(), texts(3*geminiMaxBatch))
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	for i, vec := range got {
+		if len(vec) != 1 || vec[0] != float32(i) {
+			t.Fatalf("embedding %d belongs to another text: %v", i, vec)
+		}
+	}
+}
+
+func TestGeminiEmbedder_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "api error", status: http.StatusTooManyRequests, body: `{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}`, wantErr: "Resource has been exhausted"},
+		{name: "error without message", status: http.StatusInternalServerError, body: `{}`, wantErr: "HTTP 500"},
+		{name: "not json", status: http.StatusBadGateway, body: `<html>bad gateway</html>`, wantErr: "gemini embedder: HTTP 502"},
+		{name: "malformed success", status: http.StatusOK, body: `<html>ok</html>`, wantErr: "decode response"},
+		{name: "missing embeddings", status: http.StatusOK, body: `{"embeddings":[{"values":[1]}]}`, wantErr: "expected 2 embeddings, got 1"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			e := newTestGemini(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = fmt.Fprint(w, tc.body)
+			}))
+			_, err := e.Embed(context.Background(), texts(2))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("want error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGeminiEmbedder_FailedBatchFailsEmbed(t *testing.T) {
+	t.Parallel()
+
+	e := newTestGemini(t, &fakeGemini{fail: "t" + strconv.Itoa(geminiMaxBatch)})
+	got, err := e.Embed(context.Background(), texts(geminiMaxBatch+1))
+	if err == nil || !strings.Contains(err.Error(), "text too long") {
+		t.Errorf("want the second batch's error, got %v (%d embeddings)", err, len(got))
+	}
+}
+
+func TestNewFromEnv_Gemini(t *testing.T) {
+	// t.Setenv forbids t.Parallel.
+	t.Setenv("EMBEDDING_PROVIDER", "gemini")
+	t.Setenv("EMBEDDING_API_KEY", "")
+	t.Setenv("EMBEDDING_MODEL", "")
+	t.Setenv("EMBEDDING_DIMENSIONS", "")
+	t.Setenv("GOOGLE_API_KEY", "")
+
+	if _, err := NewFromEnv(); err == nil || !strings.Contains(err.Error(), "GOOGLE_API_KEY") {
+		t.Errorf("want a missing key error, got %v", err)
+	}
+
+	t.Setenv("GOOGLE_API_KEY", "AIza-test")
+	emb, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("NewFromEnv: %v", err)
+	}
+	g, ok := emb.(*GeminiEmbedder)
+	if !ok {
+		t.Fatalf("want *GeminiEmbedder, got %T", emb)
+	}
+	if g.apiKey != "AIza-test" || g.model != defaultGeminiModel || g.dimensions != defaultGeminiDimensions || g.baseURL != defaultGeminiBaseURL {
+		t.Errorf("unexpected defaults: %+v", g)
+	}
+	if got := DefaultDimensions("gemini"); got != defaultGeminiDimensions {
+		t.Errorf("want %d default dimensions, got %d", defaultGeminiDimensions, got)
+	}
+	if got, want := IDFromEnv(), "gemini/text-embedding-004@768"; got != want {
+		t.Errorf("want ID %q, got %q", want, got)
+	}
+}
+
+// sortedInts returns a sorted copy of s.
+func sortedInts(s []int) []int {
+	out := append([]int(nil), s...)
+	slices.Sort(out)
+	return out
+}
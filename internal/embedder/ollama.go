@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/54b3r/tfai-go/internal/httpx"
 )
 
 // OllamaEmbedder implements rag.Embedder using the Ollama /api/embed endpoint.
@@ -18,6 +20,8 @@ type OllamaEmbedder struct {
 	model string
 	// client is the shared HTTP client with a sensible timeout.
 	client *http.Client
+	// maxBatch is the most texts sent per request; tests lower it.
+	maxBatch int
 }
 
 // OllamaConfig holds the settings for constructing an OllamaEmbedder.
@@ -31,9 +35,10 @@ type OllamaConfig struct {
 // NewOllamaEmbedder constructs an OllamaEmbedder from the given config.
 func NewOllamaEmbedder(cfg *OllamaConfig) *OllamaEmbedder {
 	return &OllamaEmbedder{
-		host:   cfg.Host,
-		model:  cfg.Model,
-		client: &http.Client{Timeout: 60 * time.Second},
+		host:     cfg.Host,
+		model:    cfg.Model,
+		client:   httpx.NewClient(httpx.Options{Component: "embedder", Timeout: 60 * time.Second}),
+		maxBatch: ollamaMaxBatch,
 	}
 }
 
@@ -50,8 +55,15 @@ type ollamaEmbedResponse struct {
 }
 
 // Embed converts a batch of texts into their corresponding embeddings.
-// The returned slice is parallel to the input slice.
+// The returned slice is parallel to the input slice. Inputs larger than
+// ollamaMaxBatch are split into several requests, sent one after another; a
+// cancelled ctx stops the call between or during them.
 func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedBatches(ctx, "ollama", texts, e.maxBatch, e.embedBatch)
+}
+
+// embedBatch embeds at most maxBatch texts with one request.
+func (e *OllamaEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
 	body := ollamaEmbedRequest{
 		Model: e.model,
 		Input: texts,
@@ -76,16 +88,13 @@ func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32
 	defer func() { _ = resp.Body.Close() }()
 
 	var result ollamaEmbedResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("ollama embedder: decode response: %w", err)
-	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&result)
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		msg := fmt.Sprintf("HTTP %d", resp.StatusCode)
-		if result.Error != "" {
-			msg = result.Error
-		}
-		return nil, fmt.Errorf("ollama embedder: %s", msg)
+		return nil, newStatusError("ollama", resp, result.Error)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("ollama embedder: decode response: %w", decodeErr)
 	}
 
 	if len(result.Embeddings) != len(texts) {
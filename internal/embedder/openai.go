@@ -1,6 +1,6 @@
 // Package embedder provides implementations of the rag.Embedder interface for
 // converting text into dense vector embeddings. Each implementation talks to a
-// different backend (OpenAI, Azure OpenAI, Ollama) via plain HTTP — no
+// different backend (OpenAI, Azure OpenAI, Ollama, Gemini) via plain HTTP — no
 // additional SDK dependencies are required.
 package embedder
 
@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/54b3r/tfai-go/internal/httpx"
 )
 
 // OpenAIEmbedder implements rag.Embedder using the OpenAI (or Azure OpenAI)
@@ -30,6 +32,8 @@ type OpenAIEmbedder struct {
 	apiVersion string
 	// client is the shared HTTP client with a sensible timeout.
 	client *http.Client
+	// maxBatch is the most texts sent per request; tests lower it.
+	maxBatch int
 }
 
 // OpenAIConfig holds the settings for constructing an OpenAIEmbedder.
@@ -59,7 +63,8 @@ func NewOpenAIEmbedder(cfg *OpenAIConfig) *OpenAIEmbedder {
 		dimensions: cfg.Dimensions,
 		azure:      cfg.Azure,
 		apiVersion: cfg.APIVersion,
-		client:     &http.Client{Timeout: 30 * time.Second},
+		client:     httpx.NewClient(httpx.Options{Component: "embedder", Timeout: 30 * time.Second}),
+		maxBatch:   openaiMaxBatch,
 	}
 }
 
@@ -82,8 +87,15 @@ type openaiEmbedResponse struct {
 }
 
 // Embed converts a batch of texts into their corresponding embeddings.
-// The returned slice is parallel to the input slice.
+// The returned slice is parallel to the input slice. Inputs larger than
+// openaiMaxBatch are split into several requests, sent one after another; a
+// cancelled ctx stops the call between or during them.
 func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return embedBatches(ctx, "openai", texts, e.maxBatch, e.embedBatch)
+}
+
+// embedBatch embeds at most maxBatch texts with one request.
+func (e *OpenAIEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
 	body := openaiEmbedRequest{
 		Input: texts,
 		Model: e.model,
@@ -120,17 +132,19 @@ func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32
 	defer func() { _ = resp.Body.Close() }()
 
 	var result openaiEmbedResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("openai embedder: decode response (HTTP %d): %w", resp.StatusCode, err)
-	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&result)
 
-	// Check status after decode so we can surface the API error message.
+	// Check status first, surfacing the API error message when the body
+	// decoded; proxies in front of the API may answer errors with HTML.
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		msg := fmt.Sprintf("HTTP %d", resp.StatusCode)
-		if result.Error != nil && result.Error.Message != "" {
+		msg := ""
+		if result.Error != nil {
 			msg = result.Error.Message
 		}
-		return nil, fmt.Errorf("openai embedder: %s", msg)
+		return nil, newStatusError("openai", resp, msg)
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("openai embedder: decode response: %w", decodeErr)
 	}
 
 	if len(result.Data) != len(texts) {
@@ -0,0 +1,190 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// Default retry policy values, used for zero RetryPolicy fields.
+const (
+	// DefaultMaxRetries is the number of retries after the first attempt.
+	DefaultMaxRetries = 3
+	// defaultRetryBaseDelay is the delay before the first retry; it doubles
+	// with every further retry.
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	// defaultRetryMaxDelay caps the exponential backoff.
+	defaultRetryMaxDelay = 30 * time.Second
+	// defaultRetryJitter spreads retries from concurrent callers apart.
+	defaultRetryJitter = 0.2
+)
+
+// StatusError is returned by the HTTP embedders when the backend answers
+// with a non-2xx status. The retrying embedder uses it to tell transient
+// failures from permanent ones.
+type StatusError struct {
+	// Backend names the embedder (e.g. "openai").
+	Backend string
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+	// Message is the backend's error message, or "HTTP <status>".
+	Message string
+	// RetryAfter is the delay requested by a Retry-After header (0 = none).
+	RetryAfter time.Duration
+}
+
+// Error implements error.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s embedder: %s", e.Backend, e.Message)
+}
+
+// Temporary reports whether the request may succeed when retried: rate
+// limiting (429) and server errors (5xx). Other 4xx responses are final.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// newStatusError builds the StatusError for resp. msg is the error message
+// decoded from the body, if any.
+func newStatusError(backend string, resp *http.Response, msg string) *StatusError {
+	if msg == "" {
+		msg = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	return &StatusError{
+		Backend:    backend,
+		StatusCode: resp.StatusCode,
+		Message:    msg,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter parses a Retry-After header, given either in seconds or as
+// an HTTP date. It returns 0 when the header is absent or invalid.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+// RetryPolicy configures how failed Embed calls are retried. Zero fields
+// take their defaults.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt. Defaults
+	// to DefaultMaxRetries; negative disables retries.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry; it doubles with every
+	// further retry. Defaults to 500ms.
+	BaseDelay time.Duration
+	// MaxDelay caps the exponential backoff. It does not cap a delay
+	// requested by a Retry-After header. Defaults to 30s.
+	MaxDelay time.Duration
+	// Jitter randomises each backoff delay by up to this fraction of it, in
+	// either direction. Defaults to 0.2; negative disables jitter.
+	Jitter float64
+}
+
+// withDefaults returns p with zero fields replaced by their defaults.
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxRetries == 0 {
+		p.MaxRetries = DefaultMaxRetries
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = defaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = defaultRetryMaxDelay
+	}
+	if p.Jitter == 0 {
+		p.Jitter = defaultRetryJitter
+	}
+	return p
+}
+
+// backoff returns the delay before retry number n (0-based) of err.
+func (p RetryPolicy) backoff(n int, err error) time.Duration {
+	var se *StatusError
+	if errors.As(err, &se) && se.RetryAfter > 0 {
+		return se.RetryAfter
+	}
+	d := p.BaseDelay
+	for i := 0; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if p.Jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(d)) //nolint:gosec // jitter needs no cryptographic randomness
+	}
+	return max(d, 0)
+}
+
+// RetryingEmbedder wraps any rag.Embedder and retries Embed calls that fail
+// transiently: rate limiting, server errors, and network errors. It is safe
+// for concurrent use when the wrapped embedder is.
+type RetryingEmbedder struct {
+	// next is the wrapped embedder.
+	next rag.Embedder
+	// policy is the resolved retry policy.
+	policy RetryPolicy
+	// sleep waits between attempts; tests replace it.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryingEmbedder wraps next with policy. Zero policy fields take their
+// defaults.
+func NewRetryingEmbedder(next rag.Embedder, policy RetryPolicy) *RetryingEmbedder {
+	return &RetryingEmbedder{next: next, policy: policy.withDefaults(), sleep: sleepCtx}
+}
+
+// Embed calls the wrapped embedder, retrying transient failures with
+// exponential backoff. It returns the last error once the retries are
+// exhausted, and stops early when ctx is done.
+func (e *RetryingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	for n := 0; ; n++ {
+		vecs, err := e.next.Embed(ctx, texts)
+		if err == nil || n >= e.policy.MaxRetries || !retryable(ctx, err) {
+			return vecs, err //nolint:wrapcheck // the wrapped embedder's errors are already prefixed
+		}
+		if sleepErr := e.sleep(ctx, e.policy.backoff(n, err)); sleepErr != nil {
+			return nil, fmt.Errorf("%w (retry abandoned: %w)", err, sleepErr)
+		}
+	}
+}
+
+// retryable reports whether err is worth retrying.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Temporary()
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// sleepCtx waits for d, returning the context error if ctx is done first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err() //nolint:wrapcheck // returned as-is to the caller
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,203 @@
+package embedder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyOpenAI serves /embeddings, answering the first len(failures)
+// requests with the given statuses and every later one with a one-element
+// vector per input.
+type flakyOpenAI struct {
+	mu         sync.Mutex
+	calls      int
+	failures   []int
+	retryAfter string
+	htmlErrors bool
+}
+
+func (f *flakyOpenAI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	call := f.calls
+	f.calls++
+	f.mu.Unlock()
+
+	if call < len(f.failures) {
+		if f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+		w.WriteHeader(f.failures[call])
+		if f.htmlErrors {
+			_, _ = fmt.Fprint(w, "<html>Service Unavailable</html>")
+		} else {
+			_, _ = fmt.Fprintf(w, `{"error":{"message":"status %d"}}`, f.failures[call])
+		}
+		return
+	}
+	_, _ = fmt.Fprint(w, `{"data":[{"embedding":[1],"index":0}]}`)
+}
+
+func (f *flakyOpenAI) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// newFlaky returns a RetryingEmbedder over an OpenAIEmbedder talking to
+// server, recording its sleeps instead of waiting.
+func newFlaky(t *testing.T, server *flakyOpenAI, policy RetryPolicy) (*RetryingEmbedder, *[]time.Duration) {
+	t.Helper()
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+	e := NewRetryingEmbedder(NewOpenAIEmbedder(&OpenAIConfig{BaseURL: srv.URL, APIKey: "k", Model: "m"}), policy)
+	var sleeps []time.Duration
+	e.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return e, &sleeps
+}
+
+func TestRetryingEmbedder(t *testing.T) {
+	t.Parallel()
+
+	noJitter := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond, Jitter: -1}
+
+	tests := []struct {
+		name       string
+		server     *flakyOpenAI
+		policy     RetryPolicy
+		wantCalls  int
+		wantErr    string
+		wantSleeps []time.Duration
+	}{
+		{
+			name:       "succeeds after transient failures",
+			server:     &flakyOpenAI{failures: []int{429, 503, 500}},
+			policy:     noJitter,
+			wantCalls:  4,
+			wantSleeps: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 250 * time.Millisecond},
+		},
+		{
+			name:       "non-JSON error bodies are still retried",
+			server:     &flakyOpenAI{failures: []int{502}, htmlErrors: true},
+			policy:     noJitter,
+			wantCalls:  2,
+			wantSleeps: []time.Duration{100 * time.Millisecond},
+		},
+		{
+			name:       "honors Retry-After",
+			server:     &flakyOpenAI{failures: []int{429}, retryAfter: "7"},
+			policy:     noJitter,
+			wantCalls:  2,
+			wantSleeps: []time.Duration{7 * time.Second},
+		},
+		{
+			name:       "gives up after MaxRetries",
+			server:     &flakyOpenAI{failures: []int{503, 503, 503}},
+			policy:     RetryPolicy{MaxRetries: 2, Jitter: -1},
+			wantCalls:  3,
+			wantErr:    "openai embedder: status 503",
+			wantSleeps: []time.Duration{500 * time.Millisecond, time.Second},
+		},
+		{
+			name:      "other 4xx are not retried",
+			server:    &flakyOpenAI{failures: []int{400}},
+			policy:    noJitter,
+			wantCalls: 1,
+			wantErr:   "openai embedder: status 400",
+		},
+		{
+			name:      "negative MaxRetries disables retries",
+			server:    &flakyOpenAI{failures: []int{503}},
+			policy:    RetryPolicy{MaxRetries: -1},
+			wantCalls: 1,
+			wantErr:   "openai embedder: status 503",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			e, sleeps := newFlaky(t, tc.server, tc.policy)
+			vecs, err := e.Embed(context.Background(), []string{"hello"})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error %q, got %v", tc.wantErr, err)
+				}
+			} else if err != nil || len(vecs) != 1 {
+				t.Errorf("expected one embedding, got %v, %v", vecs, err)
+			}
+			if got := tc.server.callCount(); got != tc.wantCalls {
+				t.Errorf("expected %d calls, got %d", tc.wantCalls, got)
+			}
+			if !slices.Equal(*sleeps, tc.wantSleeps) {
+				t.Errorf("expected sleeps %v, got %v", tc.wantSleeps, *sleeps)
+			}
+		})
+	}
+}
+
+func TestRetryingEmbedder_ContextCancelled(t *testing.T) {
+	t.Parallel()
+
+	server := &flakyOpenAI{failures: []int{503, 503, 503, 503}}
+	srv := httptest.NewServer(server)
+	t.Cleanup(srv.Close)
+	e := NewRetryingEmbedder(NewOpenAIEmbedder(&OpenAIConfig{BaseURL: srv.URL, APIKey: "k", Model: "m"}),
+		RetryPolicy{BaseDelay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := e.Embed(ctx, []string{"hello"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+	var se *StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the last status error to be kept, got %v", err)
+	}
+	if got := server.callCount(); got != 1 {
+		t.Errorf("expected 1 call before the backoff was cancelled, got %d", got)
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	t.Parallel()
+
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Minute, Jitter: 0.5}.withDefaults()
+	for n := 0; n < 100; n++ {
+		d := p.backoff(2, errors.New("boom"))
+		if d < 2*time.Second || d > 6*time.Second {
+			t.Fatalf("backoff %v outside [2s, 6s]", d)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"-5":                            0,
+		"soon":                          0,
+		"Sat, 01 Jun 2024 12:00:10 GMT": 10 * time.Second,
+		"Sat, 01 Jun 2024 11:59:00 GMT": 0,
+	}
+	for in, want := range tests {
+		if got := parseRetryAfter(in, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
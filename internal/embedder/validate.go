@@ -75,7 +75,7 @@ func ValidateForRAG(log *slog.Logger) error {
 		log.Warn("embedder: QDRANT_HOST is set but EMBEDDING_PROVIDER is not — "+
 			"inheriting MODEL_PROVIDER as embedding backend",
 			slog.String("backend", backend),
-			slog.String("hint", "set EMBEDDING_PROVIDER=ollama (or openai/azure) to be explicit"),
+			slog.String("hint", "set EMBEDDING_PROVIDER=ollama (or openai/azure/gemini) to be explicit"),
 		)
 	}
 
@@ -107,10 +107,16 @@ func ValidateForRAG(log *slog.Logger) error {
 		}
 
 	case "bedrock":
-		return fmt.Errorf("embedder: QDRANT_HOST is set but bedrock embedding is not yet implemented — set EMBEDDING_PROVIDER to ollama, openai, or azure")
+		return fmt.Errorf("embedder: QDRANT_HOST is set but bedrock embedding is not yet implemented — set EMBEDDING_PROVIDER to ollama, openai, azure, or gemini")
 
 	case "gemini":
-		return fmt.Errorf("embedder: QDRANT_HOST is set but gemini embedding is not yet implemented — set EMBEDDING_PROVIDER to ollama, openai, or azure")
+		apiKey := os.Getenv("EMBEDDING_API_KEY")
+		if apiKey == "" {
+			apiKey = os.Getenv("GOOGLE_API_KEY")
+		}
+		if apiKey == "" {
+			return fmt.Errorf("embedder: QDRANT_HOST is set but no Google API key found — set GOOGLE_API_KEY or EMBEDDING_API_KEY")
+		}
 	}
 
 	// Warn if EMBEDDING_MODEL looks like a chat model.
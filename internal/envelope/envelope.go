@@ -0,0 +1,112 @@
+// Package envelope enforces hard size limits on sets of generated files
+// before anything is written to disk. Both the agent's JSON output envelope
+// and the terraform_generate tool input are checked against the same limits
+// so a confused model cannot fill the disk through either path.
+package envelope
+
+import (
+	"fmt"
+	"iter"
+)
+
+// Default limits applied when a Limits field is zero.
+const (
+	// DefaultMaxFiles is the maximum number of files in one envelope.
+	DefaultMaxFiles = 64
+	// DefaultMaxFileBytes is the maximum size of a single file's content.
+	DefaultMaxFileBytes = 512 << 10 // 512 KiB
+	// DefaultMaxTotalBytes is the maximum combined size of all file contents.
+	DefaultMaxTotalBytes = 4 << 20 // 4 MiB
+)
+
+// Limit names reported in LimitError.Limit.
+const (
+	// LimitFiles is reported when the file count exceeds MaxFiles.
+	LimitFiles = "max_files"
+	// LimitFileBytes is reported when one file exceeds MaxFileBytes.
+	LimitFileBytes = "max_file_bytes"
+	// LimitTotalBytes is reported when the combined size exceeds MaxTotalBytes.
+	LimitTotalBytes = "max_total_bytes"
+)
+
+// Limits bounds a set of generated files. Zero fields use the defaults.
+type Limits struct {
+	// MaxFiles is the maximum number of files. Defaults to DefaultMaxFiles.
+	MaxFiles int
+	// MaxFileBytes is the maximum content size of any single file.
+	// Defaults to DefaultMaxFileBytes.
+	MaxFileBytes int
+	// MaxTotalBytes is the maximum combined content size of all files.
+	// Defaults to DefaultMaxTotalBytes.
+	MaxTotalBytes int
+}
+
+// WithDefaults returns a copy of l with zero fields replaced by defaults.
+func (l Limits) WithDefaults() Limits {
+	if l.MaxFiles <= 0 {
+		l.MaxFiles = DefaultMaxFiles
+	}
+	if l.MaxFileBytes <= 0 {
+		l.MaxFileBytes = DefaultMaxFileBytes
+	}
+	if l.MaxTotalBytes <= 0 {
+		l.MaxTotalBytes = DefaultMaxTotalBytes
+	}
+	return l
+}
+
+// LimitError reports which limit a file set violated. It is returned
+// before any file is written.
+type LimitError struct {
+	// Limit is one of LimitFiles, LimitFileBytes, or LimitTotalBytes.
+	Limit string
+	// Path is the offending file for LimitFileBytes; empty otherwise.
+	Path string
+	// Actual is the observed value (file count or bytes). For
+	// LimitTotalBytes it is the running total at the point the limit was
+	// crossed, not necessarily the full size of the set.
+	Actual int
+	// Max is the configured limit.
+	Max int
+}
+
+// Error implements the error interface. The message includes guidance
+// because it is streamed to the user unchanged.
+func (e *LimitError) Error() string {
+	const hint = "nothing was written; split the request into smaller pieces (e.g. one module at a time)"
+	switch e.Limit {
+	case LimitFiles:
+		return fmt.Sprintf("envelope: generated %d files, limit is %d — %s", e.Actual, e.Max, hint)
+	case LimitFileBytes:
+		return fmt.Sprintf("envelope: file %q is %d bytes, limit is %d — %s", e.Path, e.Actual, e.Max, hint)
+	default:
+		return fmt.Sprintf("envelope: generated content exceeds %d bytes total — %s", e.Max, hint)
+	}
+}
+
+// Check validates files (path → content pairs) against l, applying defaults
+// for zero fields. It returns a *LimitError for the first violation found,
+// or nil. Check never touches the filesystem.
+func (l Limits) Check(files iter.Seq2[string, string]) error {
+	l = l.WithDefaults()
+
+	count, total := 0, 0
+	for path, content := range files {
+		count++
+		if count > l.MaxFiles {
+			// Keep counting so the error reports the real size of the set.
+			continue
+		}
+		if len(content) > l.MaxFileBytes {
+			return &LimitError{Limit: LimitFileBytes, Path: path, Actual: len(content), Max: l.MaxFileBytes}
+		}
+		total += len(content)
+		if total > l.MaxTotalBytes {
+			return &LimitError{Limit: LimitTotalBytes, Actual: total, Max: l.MaxTotalBytes}
+		}
+	}
+	if count > l.MaxFiles {
+		return &LimitError{Limit: LimitFiles, Actual: count, Max: l.MaxFiles}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLimits_Check(t *testing.T) {
+	t.Parallel()
+
+	small := Limits{MaxFiles: 2, MaxFileBytes: 10, MaxTotalBytes: 15}
+
+	tests := []struct {
+		name      string
+		limits    Limits
+		files     map[string]string
+		wantLimit string // empty means no error
+	}{
+		{
+			name:   "within limits",
+			limits: small,
+			files:  map[string]string{"main.tf": "12345", "variables.tf": "12345"},
+		},
+		{
+			name:      "too many files",
+			limits:    small,
+			files:     map[string]string{"a.tf": "", "b.tf": "", "c.tf": ""},
+			wantLimit: LimitFiles,
+		},
+		{
+			name:      "single file too large",
+			limits:    small,
+			files:     map[string]string{"main.tf": strings.Repeat("x", 11)},
+			wantLimit: LimitFileBytes,
+		},
+		{
+			name:      "total too large",
+			limits:    small,
+			files:     map[string]string{"a.tf": strings.Repeat("x", 8), "b.tf": strings.Repeat("x", 8)},
+			wantLimit: LimitTotalBytes,
+		},
+		{
+			name:   "zero limits use defaults",
+			limits: Limits{},
+			files:  map[string]string{"main.tf": strings.Repeat("x", DefaultMaxFileBytes)},
+		},
+		{
+			name:      "default file limit enforced",
+			limits:    Limits{},
+			files:     map[string]string{"main.tf": strings.Repeat("x", DefaultMaxFileBytes+1)},
+			wantLimit: LimitFileBytes,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			err := tc.limits.Check(maps.All(tc.files))
+			if tc.wantLimit == "" {
+				if err != nil {
+					t.Fatalf("Check() unexpected error: %v", err)
+				}
+				return
+			}
+			var le *LimitError
+			if !errors.As(err, &le) {
+				t.Fatalf("Check() expected *LimitError, got %T: %v", err, err)
+			}
+			if le.Limit != tc.wantLimit {
+				t.Errorf("Limit: expected %q, got %q", tc.wantLimit, le.Limit)
+			}
+			if !strings.Contains(le.Error(), "split the request") {
+				t.Errorf("error should carry guidance, got %q", le.Error())
+			}
+		})
+	}
+}
+
+func TestLimits_CheckReportsFullFileCount(t *testing.T) {
+	t.Parallel()
+
+	files := make(map[string]string)
+	for _, n := range []string{"a", "b", "c", "d", "e"} {
+		files[n+".tf"] = ""
+	}
+	var le *LimitError
+	if !errors.As(Limits{MaxFiles: 2}.Check(maps.All(files)), &le) {
+		t.Fatal("expected *LimitError")
+	}
+	if le.Actual != 5 {
+		t.Errorf("Actual: expected 5, got %d", le.Actual)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	var doc struct {
+		Type       string                     `json:"type"`
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(Schema(), &doc); err != nil {
+		t.Fatalf("embedded schema is not valid JSON: %v", err)
+	}
+	if doc.Type != "object" || !slices.Equal(doc.Required, []string{"files", "summary"}) {
+		t.Errorf("unexpected schema root: type=%q required=%v", doc.Type, doc.Required)
+	}
+	if _, ok := doc.Properties["files"]; !ok {
+		t.Error("expected a files property")
+	}
+
+	s := Schema()
+	s[0] = 'x'
+	if Schema()[0] != '{' {
+		t.Error("expected Schema to return a copy")
+	}
+}
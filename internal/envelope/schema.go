@@ -0,0 +1,24 @@
+package envelope
+
+import (
+	_ "embed"
+	"encoding/json"
+)
+
+// SchemaName names Schema for providers that require a name alongside a
+// structured output schema.
+const SchemaName = "terraform_envelope"
+
+// schemaJSON is the JSON Schema of the generation envelope.
+//
+//go:embed schema.json
+var schemaJSON []byte
+
+// Schema returns the JSON Schema of the generation envelope: an object with
+// a files array of {path, content} objects and a summary string. Providers
+// with a native JSON mode are asked to conform to it; the system prompt
+// describes the same shape for those without one. The returned slice is a
+// copy and may be modified.
+func Schema() json.RawMessage {
+	return append(json.RawMessage(nil), schemaJSON...)
+}
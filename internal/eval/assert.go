@@ -0,0 +1,177 @@
+package eval
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/check"
+	"github.com/54b3r/tfai-go/internal/hclinspect"
+)
+
+// Assertion names as reported in Result.Assertion. Resource assertions are
+// named "resource " followed by ResourceAssertion.String.
+const (
+	AssertFiles       = "files"
+	AssertPolicyClean = "policy_clean"
+	AssertFmtClean    = "fmt_clean"
+	AssertMaxFiles    = "max_files"
+)
+
+// maxDetails is the number of problems listed in a failed Result's detail.
+const maxDetails = 3
+
+// Result is the outcome of one assertion.
+type Result struct {
+	// Assertion names the assertion; see the Assert constants.
+	Assertion string `json:"assertion"`
+	// Passed is true when the generated files satisfied it.
+	Passed bool `json:"passed"`
+	// Detail says why the assertion failed; empty when it passed.
+	Detail string `json:"detail,omitempty"`
+}
+
+// assertionNames returns the names of the assertions a sets, in the order
+// Check reports them. Every run of a suite is scored on the same list, so
+// reports of different runs line up.
+func assertionNames(a Assertions) []string {
+	var names []string
+	if len(a.Files) > 0 {
+		names = append(names, AssertFiles)
+	}
+	for _, r := range a.Resources {
+		names = append(names, "resource "+r.String())
+	}
+	if a.PolicyClean {
+		names = append(names, AssertPolicyClean)
+	}
+	if a.FmtClean {
+		names = append(names, AssertFmtClean)
+	}
+	if a.MaxFiles > 0 {
+		names = append(names, AssertMaxFiles)
+	}
+	return names
+}
+
+// Check scores files, the generated files with workspace-relative paths,
+// against a. It needs neither a model nor the terraform binary.
+func Check(a Assertions, files []check.File) []Result {
+	var tf []check.File
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path)
+		if check.IsTerraform(f.Path) {
+			tf = append(tf, f)
+		}
+	}
+	var results []Result
+	if len(a.Files) > 0 {
+		var missing []string
+		for _, want := range a.Files {
+			if !slices.Contains(paths, want) {
+				missing = append(missing, want)
+			}
+		}
+		results = append(results, result(AssertFiles, missing, "missing "+strings.Join(missing, ", ")))
+	}
+	resources := declaredResources(tf)
+	for _, r := range a.Resources {
+		results = append(results, checkResource(r, resources))
+	}
+	if a.PolicyClean {
+		findings := check.Run(tf, []string{check.Vars, check.Secrets}, nil)
+		results = append(results, result(AssertPolicyClean, findings, describeFindings(findings)))
+	}
+	if a.FmtClean {
+		findings := check.Run(tf, []string{check.Fmt}, nil)
+		results = append(results, result(AssertFmtClean, findings, describeFindings(findings)))
+	}
+	if a.MaxFiles > 0 {
+		r := Result{Assertion: AssertMaxFiles, Passed: len(files) <= a.MaxFiles}
+		if !r.Passed {
+			r.Detail = fmt.Sprintf("%d files, at most %d allowed", len(files), a.MaxFiles)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// failAll returns a failed Result with detail for every assertion a sets,
+// for a suite whose files could not be generated.
+func failAll(a Assertions, detail string) []Result {
+	names := assertionNames(a)
+	results := make([]Result, 0, len(names))
+	for _, name := range names {
+		results = append(results, Result{Assertion: name, Detail: detail})
+	}
+	return results
+}
+
+// result returns the Result of the assertion name, which passed when
+// problems is empty and otherwise failed with detail.
+func result[T any](name string, problems []T, detail string) Result {
+	if len(problems) == 0 {
+		return Result{Assertion: name, Passed: true}
+	}
+	return Result{Assertion: name, Detail: detail}
+}
+
+// declaredResources returns the resource and data blocks of files. Files
+// that fail to parse declare nothing; the policy assertion reports them.
+func declaredResources(files []check.File) []hclinspect.Resource {
+	var out []hclinspect.Resource
+	for _, f := range files {
+		resources, err := hclinspect.ParseResources(f.Content, f.Path)
+		if err != nil {
+			continue
+		}
+		out = append(out, resources...)
+	}
+	return out
+}
+
+// checkResource scores r against the declared resources: it passes when a
+// matching block sets every required argument. Otherwise the detail names
+// the arguments missing from the closest match.
+func checkResource(r ResourceAssertion, resources []hclinspect.Resource) Result {
+	name := "resource " + r.String()
+	var best []string
+	found := false
+	for _, res := range resources {
+		a := res.Address
+		if a.Type != r.Type || a.Data != r.Data || (r.Name != "" && a.Name != r.Name) {
+			continue
+		}
+		var missing []string
+		for _, arg := range r.Arguments {
+			if !res.HasArgument(arg) {
+				missing = append(missing, arg)
+			}
+		}
+		if len(missing) == 0 {
+			return Result{Assertion: name, Passed: true}
+		}
+		if !found || len(missing) < len(best) {
+			best = missing
+			found = true
+		}
+	}
+	if !found {
+		return Result{Assertion: name, Detail: "not declared"}
+	}
+	return Result{Assertion: name, Detail: "missing arguments " + strings.Join(best, ", ")}
+}
+
+// describeFindings renders the first maxDetails findings as one line.
+func describeFindings(findings []check.Finding) string {
+	parts := make([]string, 0, maxDetails+1)
+	for i, f := range findings {
+		if i == maxDetails {
+			parts = append(parts, fmt.Sprintf("and %d more", len(findings)-maxDetails))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d: %s", f.File, f.Line, f.Message))
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,108 @@
+package eval
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/check"
+)
+
+// envelopeFiles returns the files of the file envelope JSON data, the
+// answer a generation writes to the workspace.
+func envelopeFiles(t *testing.T, data []byte) []check.File {
+	t.Helper()
+	var env struct {
+		Files []struct {
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &env); err != nil {
+		t.Fatalf("failed to decode envelope: %v", err)
+	}
+	files := make([]check.File, 0, len(env.Files))
+	for _, f := range env.Files {
+		files = append(files, check.File{Path: f.Path, Content: []byte(f.Content)})
+	}
+	return files
+}
+
+// fixture returns the files of the envelope testdata/<name>.json.
+func fixture(t *testing.T, name string) []check.File {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return envelopeFiles(t, data)
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	a := Assertions{
+		Files: []string{"main.tf", "variables.tf", "versions.tf"},
+		Resources: []ResourceAssertion{
+			{Type: "aws_s3_bucket", Arguments: []string{"bucket"}},
+			{Type: "aws_s3_bucket_versioning", Name: "this", Arguments: []string{"bucket", "versioning_configuration.status"}},
+			{Type: "aws_kms_key"},
+		},
+		PolicyClean: true,
+		FmtClean:    true,
+		MaxFiles:    3,
+	}
+	tests := []struct {
+		name    string
+		fixture string
+		want    []Result
+	}{
+		{
+			name:    "complete",
+			fixture: "complete",
+			want: []Result{
+				{Assertion: AssertFiles, Passed: true},
+				{Assertion: "resource aws_s3_bucket", Passed: true},
+				{Assertion: "resource aws_s3_bucket_versioning.this", Passed: true},
+				{Assertion: "resource aws_kms_key", Detail: "not declared"},
+				{Assertion: AssertPolicyClean, Passed: true},
+				{Assertion: AssertFmtClean, Passed: true},
+				{Assertion: AssertMaxFiles, Passed: true},
+			},
+		},
+		{
+			name:    "flawed",
+			fixture: "flawed",
+			want: []Result{
+				{Assertion: AssertFiles, Detail: "missing versions.tf"},
+				{Assertion: "resource aws_s3_bucket", Passed: true},
+				{Assertion: "resource aws_s3_bucket_versioning.this", Detail: "missing arguments versioning_configuration.status"},
+				{Assertion: "resource aws_kms_key", Detail: "not declared"},
+				{Assertion: AssertPolicyClean, Detail: `variables.tf:1: variable "name" has no description`},
+				{Assertion: AssertFmtClean, Detail: "main.tf:2: not formatted; run terraform fmt"},
+				{Assertion: AssertMaxFiles, Detail: "4 files, at most 3 allowed"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := Check(a, fixture(t, tc.fixture))
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("Check() =\n%+v\nwant\n%+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckScoresUnparseableFiles(t *testing.T) {
+	t.Parallel()
+
+	files := []check.File{{Path: "main.tf", Content: []byte("resource \"aws_s3_bucket\" \"this\" {\n")}}
+	got := Check(Assertions{Resources: []ResourceAssertion{{Type: "aws_s3_bucket"}}, PolicyClean: true}, files)
+	if len(got) != 2 || got[0].Passed || got[0].Detail != "not declared" || got[1].Passed {
+		t.Errorf("expected a file that does not parse to fail both assertions, got %+v", got)
+	}
+}
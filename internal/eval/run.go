@@ -0,0 +1,178 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/check"
+)
+
+// Generator generates s.Spec into dir, an empty scratch workspace, the way
+// `tfai generate` would. An error fails every assertion of the suite.
+type Generator func(ctx context.Context, s *Suite, dir string) error
+
+// Report is the scored outcome of a run. Score is the fraction of all
+// assertions that passed; a suite whose generation failed counts all of
+// its assertions as failed, so runs of the same suites always score out of
+// the same Total.
+type Report struct {
+	// Suites holds the outcome of each suite, in run order.
+	Suites []SuiteResult `json:"suites"`
+	// Passed is the number of assertions that passed across all suites.
+	Passed int `json:"passed"`
+	// Total is the number of assertions across all suites.
+	Total int `json:"total"`
+	// Score is Passed divided by Total; 0 when Total is 0.
+	Score float64 `json:"score"`
+	// SuitesPassed is the number of suites that passed every assertion.
+	SuitesPassed int `json:"suitesPassed"`
+}
+
+// SuiteResult is the outcome of one suite.
+type SuiteResult struct {
+	// Name is Suite.Name.
+	Name string `json:"name"`
+	// Path is the suite file.
+	Path string `json:"path"`
+	// Error is why generation failed; empty when it succeeded.
+	Error string `json:"error,omitempty"`
+	// Files lists the generated files' slash-separated paths.
+	Files []string `json:"files"`
+	// Results holds one entry per assertion, in the order the suite file
+	// lists them.
+	Results []Result `json:"results"`
+	// Passed is the number of Results that passed.
+	Passed int `json:"passed"`
+	// DurationMs is how long generation took, in milliseconds.
+	DurationMs int64 `json:"durationMs"`
+}
+
+// OK reports whether every assertion of the suite passed.
+func (s SuiteResult) OK() bool {
+	return s.Error == "" && s.Passed == len(s.Results)
+}
+
+// Run generates each suite with gen in a scratch workspace that is removed
+// afterwards and scores the files it wrote. It stops early only when ctx
+// is done or a workspace cannot be created.
+func Run(ctx context.Context, suites []*Suite, gen Generator) (*Report, error) {
+	report := &Report{Suites: make([]SuiteResult, 0, len(suites))}
+	for _, s := range suites {
+		sr, err := runSuite(ctx, s, gen)
+		if err != nil {
+			return nil, err
+		}
+		report.Suites = append(report.Suites, sr)
+		report.Passed += sr.Passed
+		report.Total += len(sr.Results)
+		if sr.OK() {
+			report.SuitesPassed++
+		}
+	}
+	if report.Total > 0 {
+		report.Score = float64(report.Passed) / float64(report.Total)
+	}
+	return report, nil
+}
+
+// runSuite generates and scores one suite.
+func runSuite(ctx context.Context, s *Suite, gen Generator) (SuiteResult, error) {
+	sr := SuiteResult{Name: s.Name, Path: s.Path, Files: []string{}}
+	dir, err := os.MkdirTemp("", "tfai-eval-*")
+	if err != nil {
+		return sr, fmt.Errorf("eval: failed to create workspace: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	start := time.Now()
+	err = gen(ctx, s, dir)
+	sr.DurationMs = time.Since(start).Milliseconds()
+	if ctx.Err() != nil {
+		return sr, fmt.Errorf("eval: %s: %w", s.Name, ctx.Err())
+	}
+	var files []check.File
+	if err == nil {
+		files, err = readFiles(dir)
+	}
+	if err != nil {
+		sr.Error = err.Error()
+		sr.Results = failAll(s.Assert, "not generated")
+		return sr, nil
+	}
+	for _, f := range files {
+		sr.Files = append(sr.Files, f.Path)
+	}
+	sr.Results = Check(s.Assert, files)
+	for _, r := range sr.Results {
+		if r.Passed {
+			sr.Passed++
+		}
+	}
+	return sr, nil
+}
+
+// readFiles reads every file under dir, skipping hidden directories such
+// as .tfai, with slash-separated paths relative to dir.
+func readFiles(dir string) ([]check.File, error) {
+	var files []check.File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, check.File{Path: filepath.ToSlash(rel), Content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("eval: failed to read generated files: %w", err)
+	}
+	return files, nil
+}
+
+// WriteText writes the report for a terminal: each suite with its
+// assertions, then the summary score.
+func (r *Report) WriteText(w io.Writer) error {
+	var b strings.Builder
+	for _, s := range r.Suites {
+		status := "PASS"
+		if !s.OK() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "%s %s (%d/%d, %s)\n", status, s.Name, s.Passed, len(s.Results), time.Duration(s.DurationMs)*time.Millisecond)
+		if s.Error != "" {
+			fmt.Fprintf(&b, "  generation failed: %s\n", s.Error)
+		}
+		for _, res := range s.Results {
+			if res.Passed {
+				fmt.Fprintf(&b, "  ok    %s\n", res.Assertion)
+			} else {
+				fmt.Fprintf(&b, "  fail  %s: %s\n", res.Assertion, res.Detail)
+			}
+		}
+	}
+	fmt.Fprintf(&b, "\nScore: %.1f%% (%d/%d assertions, %d/%d suites passed)\n",
+		r.Score*100, r.Passed, r.Total, r.SuitesPassed, len(r.Suites))
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("eval: failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	a := Assertions{
+		Files:     []string{"main.tf"},
+		Resources: []ResourceAssertion{{Type: "aws_s3_bucket_versioning", Arguments: []string{"versioning_configuration.status"}}},
+		FmtClean:  true,
+	}
+	suites := []*Suite{
+		{Name: "complete", Spec: "complete", Assert: a},
+		{Name: "flawed", Spec: "flawed", Assert: a},
+		{Name: "broken", Spec: "broken", Assert: a},
+	}
+	// gen writes the fixture envelope named by the spec, as the agent
+	// would, along with the manifest the agent keeps under .tfai.
+	gen := func(_ context.Context, s *Suite, dir string) error {
+		if s.Spec == "broken" {
+			return errors.New("provider: replay: script has no more recorded turns")
+		}
+		for _, f := range append(fixture(t, s.Spec), envelopeFiles(t, []byte(`{"files":[{"path":".tfai/manifest.json","content":"{}"}]}`))...) {
+			path := filepath.Join(dir, filepath.FromSlash(f.Path))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, f.Content, 0o644); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	report, err := Run(context.Background(), suites, gen)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if report.Total != 9 || report.Passed != 4 || report.SuitesPassed != 1 {
+		t.Errorf("expected 4/9 assertions and 1 suite passed, got %+v", report)
+	}
+	if want := 4.0 / 9; report.Score != want {
+		t.Errorf("expected score %v, got %v", want, report.Score)
+	}
+	if got := report.Suites[1].Files; len(got) != 4 || got[0] != "README.md" {
+		t.Errorf("expected the flawed files read without .tfai, got %v", got)
+	}
+	broken := report.Suites[2]
+	if broken.Error == "" || broken.Passed != 0 || len(broken.Results) != 3 || broken.Results[0].Detail != "not generated" {
+		t.Errorf("expected a failed generation to fail every assertion, got %+v", broken)
+	}
+
+	var out strings.Builder
+	if err := report.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"PASS complete (3/3, ",
+		"FAIL flawed (1/3, ",
+		"generation failed: provider: replay: script has no more recorded turns",
+		"fail  fmt_clean: main.tf:2: not formatted",
+		"Score: 44.4% (4/9 assertions, 1/3 suites passed)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected the report to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunStopsWhenCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	suites := []*Suite{{Name: "a", Spec: "a", Assert: Assertions{FmtClean: true}}, {Name: "b", Spec: "b", Assert: Assertions{FmtClean: true}}}
+	calls := 0
+	_, err := Run(ctx, suites, func(ctx context.Context, _ *Suite, _ string) error {
+		calls++
+		cancel()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("expected the run to stop after the cancelled suite, got %v after %d calls", err, calls)
+	}
+}
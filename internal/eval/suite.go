@@ -0,0 +1,166 @@
+// Package eval scores generation quality against golden module specs. A
+// suite file pairs a generation spec with deterministic assertions on the
+// files generated for it (required files and resource arguments, a clean
+// policy scan, canonical formatting, a file budget); Run generates each
+// spec into a scratch workspace and Check scores the result, so the
+// Report of two branches can be compared. `tfai eval generate` uses this
+// package.
+package eval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is one golden module spec and the assertions its generated files
+// must satisfy, as read from a suite file:
+//
+//	name: s3-bucket
+//	spec: |
+//	  Private S3 bucket with versioning and SSE-KMS encryption.
+//	replay: s3-bucket.replay.json
+//	assert:
+//	  files: [main.tf, variables.tf, outputs.tf, versions.tf]
+//	  resources:
+//	    - type: aws_s3_bucket_versioning
+//	      arguments: [versioning_configuration.status]
+//	  policy_clean: true
+//	  fmt_clean: true
+//	  max_files: 6
+type Suite struct {
+	// Name identifies the suite in reports; it defaults to the file name
+	// without its extension.
+	Name string `yaml:"name"`
+	// Spec is the description the module is generated from, as passed to
+	// `tfai generate`.
+	Spec string `yaml:"spec"`
+	// Replay is the recorded script (see provider.Script) that answers the
+	// spec without a model. LoadSuite resolves it relative to the suite
+	// file. Optional.
+	Replay string `yaml:"replay"`
+	// Assert lists what the generated files must satisfy.
+	Assert Assertions `yaml:"assert"`
+	// Path is the file the suite was loaded from.
+	Path string `yaml:"-"`
+}
+
+// Assertions are the checks run on a suite's generated files. Each set
+// field is one or more scored assertions; see Check.
+type Assertions struct {
+	// Files lists the slash-separated paths that must be generated.
+	Files []string `yaml:"files"`
+	// Resources lists the resource and data blocks that must be declared,
+	// each scored separately.
+	Resources []ResourceAssertion `yaml:"resources"`
+	// PolicyClean requires the vars and secrets checks of package check to
+	// report nothing, warnings included.
+	PolicyClean bool `yaml:"policy_clean"`
+	// FmtClean requires every .tf file to be canonically formatted.
+	FmtClean bool `yaml:"fmt_clean"`
+	// MaxFiles is the most files the generation may write; 0 means no
+	// limit.
+	MaxFiles int `yaml:"max_files"`
+}
+
+// ResourceAssertion requires a resource or data block, matched by type and
+// optionally name, that sets every listed argument.
+type ResourceAssertion struct {
+	// Type is the resource type, e.g. "aws_s3_bucket".
+	Type string `yaml:"type"`
+	// Name is the block name; empty matches any block of Type.
+	Name string `yaml:"name"`
+	// Data selects a data block instead of a resource block.
+	Data bool `yaml:"data"`
+	// Arguments lists the attributes and nested blocks the block must set,
+	// as dotted paths for nested ones (see hclinspect.Resource).
+	Arguments []string `yaml:"arguments"`
+}
+
+// String names the block the assertion looks for, e.g. "aws_s3_bucket" or
+// "data.aws_iam_policy_document.read".
+func (r ResourceAssertion) String() string {
+	s := r.Type
+	if r.Name != "" {
+		s += "." + r.Name
+	}
+	if r.Data {
+		s = "data." + s
+	}
+	return s
+}
+
+// LoadSuite reads and validates the suite file at path. Unknown keys are
+// rejected so a misspelt assertion fails loudly instead of never running.
+func LoadSuite(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("eval: failed to read suite: %w", err)
+	}
+	var s Suite
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("eval: failed to parse %s: %w", path, err)
+	}
+	s.Path = path
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if s.Replay != "" && !filepath.IsAbs(s.Replay) {
+		s.Replay = filepath.Join(filepath.Dir(path), s.Replay)
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("eval: %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// validate reports a suite that cannot be run or scored.
+func (s *Suite) validate() error {
+	if strings.TrimSpace(s.Spec) == "" {
+		return errors.New("spec is empty")
+	}
+	for i, r := range s.Assert.Resources {
+		if r.Type == "" {
+			return fmt.Errorf("resources[%d] has no type", i)
+		}
+	}
+	if s.Assert.MaxFiles < 0 {
+		return errors.New("max_files is negative")
+	}
+	if len(assertionNames(s.Assert)) == 0 {
+		return errors.New("no assertions")
+	}
+	return nil
+}
+
+// LoadSuites loads the suite files matching each glob pattern, in pattern
+// order and name order within a pattern. A pattern that matches nothing is
+// an error, so a mistyped path is not scored as an empty run.
+func LoadSuites(patterns []string) ([]*Suite, error) {
+	var suites []*Suite
+	for _, p := range patterns {
+		paths, err := filepath.Glob(p)
+		if err != nil {
+			return nil, fmt.Errorf("eval: invalid suite pattern %q: %w", p, err)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("eval: no suite files match %q", p)
+		}
+		for _, path := range paths {
+			s, err := LoadSuite(path)
+			if err != nil {
+				return nil, err
+			}
+			suites = append(suites, s)
+		}
+	}
+	return suites, nil
+}
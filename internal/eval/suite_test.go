@@ -0,0 +1,90 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/54b3r/tfai-go/internal/provider"
+)
+
+func TestLoadSuite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "valid", content: "spec: A bucket.\nreplay: golden.json\nassert:\n  fmt_clean: true\n"},
+		{name: "empty spec", content: "assert:\n  fmt_clean: true\n", wantErr: "spec is empty"},
+		{name: "no assertions", content: "spec: A bucket.\n", wantErr: "no assertions"},
+		{name: "misspelt assertion", content: "spec: A bucket.\nassert:\n  fmt_clan: true\n", wantErr: "field fmt_clan not found"},
+		{name: "resource without type", content: "spec: A bucket.\nassert:\n  resources:\n    - name: this\n", wantErr: "resources[0] has no type"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			path := filepath.Join(dir, "bucket.yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			s, err := LoadSuite(path)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadSuite: %v", err)
+			}
+			if s.Name != "bucket" || s.Replay != filepath.Join(dir, "golden.json") {
+				t.Errorf("expected the name from the file and the replay script beside it, got %+v", s)
+			}
+		})
+	}
+}
+
+func TestLoadSuitesNoMatch(t *testing.T) {
+	t.Parallel()
+
+	if _, err := LoadSuites([]string{filepath.Join(t.TempDir(), "*.yaml")}); err == nil || !strings.Contains(err.Error(), "no suite files match") {
+		t.Errorf("expected a pattern matching nothing to fail, got %v", err)
+	}
+}
+
+// TestGoldenSuites checks that the bundled suites load and that the answer
+// recorded in each replay script passes every assertion, so the replay run
+// in CI scores 100% until generation changes.
+func TestGoldenSuites(t *testing.T) {
+	t.Parallel()
+
+	suites, err := LoadSuites([]string{filepath.Join("..", "..", "testdata", "eval", "*.yaml")})
+	if err != nil {
+		t.Fatalf("LoadSuites: %v", err)
+	}
+	if len(suites) == 0 {
+		t.Fatal("expected bundled suites")
+	}
+	for _, s := range suites {
+		script, err := provider.LoadScript(s.Replay)
+		if err != nil {
+			t.Fatalf("%s: %v", s.Name, err)
+		}
+		last := script.Turns[len(script.Turns)-1]
+		msg, err := schema.ConcatMessages(last.Chunks)
+		if err != nil {
+			t.Fatalf("%s: %v", s.Name, err)
+		}
+		for _, r := range Check(s.Assert, envelopeFiles(t, []byte(msg.Content))) {
+			if !r.Passed {
+				t.Errorf("%s: %s failed: %s", s.Name, r.Assertion, r.Detail)
+			}
+		}
+	}
+}
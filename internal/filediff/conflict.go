@@ -0,0 +1,49 @@
+package filediff
+
+import "strings"
+
+// ConflictMarkers returns a file holding both ours and theirs the way git
+// leaves a merge conflict: lines the two share are written once, and each
+// region where they differ is written as
+//
+//	<<<<<<< oursLabel
+//	ours' lines
+//	=======
+//	theirs' lines
+//	>>>>>>> theirsLabel
+//
+// so an editor's merge tools can resolve it. It returns ours unchanged when
+// the two are equal.
+func ConflictMarkers(ours, theirs, oursLabel, theirsLabel string) string {
+	if ours == theirs {
+		return ours
+	}
+	ops := diffLines(splitLines(ours), splitLines(theirs))
+	var sb strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			sb.WriteString(ops[i].text + "\n")
+			i++
+			continue
+		}
+		end := i
+		for end < len(ops) && ops[end].kind != ' ' {
+			end++
+		}
+		sb.WriteString("<<<<<<< " + oursLabel + "\n")
+		for _, op := range ops[i:end] {
+			if op.kind == '-' {
+				sb.WriteString(op.text + "\n")
+			}
+		}
+		sb.WriteString("=======\n")
+		for _, op := range ops[i:end] {
+			if op.kind == '+' {
+				sb.WriteString(op.text + "\n")
+			}
+		}
+		sb.WriteString(">>>>>>> " + theirsLabel + "\n")
+		i = end
+	}
+	return sb.String()
+}
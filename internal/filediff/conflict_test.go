@@ -0,0 +1,48 @@
+package filediff
+
+import "testing"
+
+func TestConflictMarkers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		ours, theirs string
+		want         string
+	}{
+		{name: "equal", ours: "a\nb\n", theirs: "a\nb\n", want: "a\nb\n"},
+		{
+			name:   "one change",
+			ours:   numbered(5, map[int]string{3: "mine"}),
+			theirs: numbered(5, map[int]string{3: "generated"}),
+			want:   "1\n2\n<<<<<<< mine\nmine\n=======\ngenerated\n>>>>>>> generated\n4\n5\n",
+		},
+		{
+			name:   "separate changes",
+			ours:   numbered(5, nil),
+			theirs: numbered(5, map[int]string{1: "one", 5: "five"}),
+			want: "<<<<<<< mine\n1\n=======\none\n>>>>>>> generated\n2\n3\n4\n" +
+				"<<<<<<< mine\n5\n=======\nfive\n>>>>>>> generated\n",
+		},
+		{
+			name:   "only added",
+			ours:   "a\nc\n",
+			theirs: "a\nb\nc\n",
+			want:   "a\n<<<<<<< mine\n=======\nb\n>>>>>>> generated\nc\n",
+		},
+		{
+			name:   "only removed",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nc\n",
+			want:   "a\n<<<<<<< mine\nb\n=======\n>>>>>>> generated\nc\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := ConflictMarkers(tt.ours, tt.theirs, "mine", "generated"); got != tt.want {
+				t.Errorf("ConflictMarkers() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Package filediff snapshots the Terraform files in a directory and
+// summarises how two snapshots differ. It backs the per-iteration summary of
+// `tfai generate --watch` and the dry-run preview of `tfai upgrade`.
+package filediff
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ANSI escape sequences for diff summaries.
+const (
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiRed    = "\x1b[31m"
+	ansiReset  = "\x1b[0m"
+)
+
+// Kind classifies a file in a diff summary.
+type Kind int
+
+const (
+	// Added is a file that did not exist in the first snapshot.
+	Added Kind = iota
+	// Modified is a file whose content changed.
+	Modified
+	// Removed is a file that no longer exists in the second snapshot.
+	Removed
+)
+
+// Change is one line of a diff summary.
+type Change struct {
+	// Path is relative to the snapshot directory.
+	Path string
+	// Kind is the type of change.
+	Kind Kind
+	// Added is the number of lines present after but not before.
+	Added int
+	// Removed is the number of lines present before but not after.
+	Removed int
+}
+
+// Snapshot returns the content of every .tf and .tfvars file under dir,
+// keyed by path relative to dir. Hidden directories (e.g. .terraform) are
+// skipped.
+func Snapshot(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(d.Name())
+		if ext != ".tf" && ext != ".tfvars" {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		files[rel] = string(b)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("filediff: failed to snapshot %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+// Compare compares two snapshots and returns the changes sorted by path.
+func Compare(before, after map[string]string) []Change {
+	var changes []Change
+	for path, now := range after {
+		prev, existed := before[path]
+		switch {
+		case !existed:
+			changes = append(changes, Change{Path: path, Kind: Added, Added: countLines(now)})
+		case prev != now:
+			added, removed := lineDelta(prev, now)
+			changes = append(changes, Change{Path: path, Kind: Modified, Added: added, Removed: removed})
+		}
+	}
+	for path, prev := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, Change{Path: path, Kind: Removed, Removed: countLines(prev)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// countLines returns the number of lines in s.
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	return len(strings.Split(strings.TrimSuffix(s, "\n"), "\n"))
+}
+
+// lineDelta approximates a line diff by comparing line multisets: lines that
+// appear more often after count as added, lines that appear more often
+// before count as removed. Moved lines are not reported, which is what a
+// quick per-iteration summary wants.
+func lineDelta(before, after string) (added, removed int) {
+	counts := make(map[string]int)
+	for _, l := range strings.Split(before, "\n") {
+		counts[l]--
+	}
+	for _, l := range strings.Split(after, "\n") {
+		counts[l]++
+	}
+	for _, n := range counts {
+		if n > 0 {
+			added += n
+		} else {
+			removed -= n
+		}
+	}
+	return added, removed
+}
+
+// WriteSummary prints one line per changed file: "+ path (+N)" for new
+// files, "~ path (+N -M)" for modified files, and "- path (-M)" for removed
+// files. Colors are green, yellow, and red respectively when enabled.
+func WriteSummary(w io.Writer, changes []Change, color bool) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "no file changes")
+		return
+	}
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + ansiReset
+	}
+	for _, c := range changes {
+		switch c.Kind {
+		case Added:
+			fmt.Fprintln(w, paint(ansiGreen, fmt.Sprintf("+ %s (+%d)", c.Path, c.Added)))
+		case Modified:
+			fmt.Fprintln(w, paint(ansiYellow, fmt.Sprintf("~ %s (+%d -%d)", c.Path, c.Added, c.Removed)))
+		case Removed:
+			fmt.Fprintln(w, paint(ansiRed, fmt.Sprintf("- %s (-%d)", c.Path, c.Removed)))
+		}
+	}
+}
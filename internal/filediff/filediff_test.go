@@ -0,0 +1,83 @@
+package filediff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Diff summary
+// ---------------------------------------------------------------------------
+
+func TestDiffSnapshots(t *testing.T) {
+	t.Parallel()
+
+	before := map[string]string{
+		"main.tf":     "a\nb\n",
+		"old.tf":      "x\ny\nz\n",
+		"same.tf":     "unchanged\n",
+		"vars.tfvars": "k = 1\n",
+	}
+	after := map[string]string{
+		"main.tf":     "a\nc\nd\n",
+		"new.tf":      "n\n",
+		"same.tf":     "unchanged\n",
+		"vars.tfvars": "k = 1\n",
+	}
+
+	var sb strings.Builder
+	WriteSummary(&sb, Compare(before, after), false)
+	want := "~ main.tf (+2 -1)\n+ new.tf (+1)\n- old.tf (-3)\n"
+	if sb.String() != want {
+		t.Errorf("summary:\nwant %q\ngot  %q", want, sb.String())
+	}
+
+	sb.Reset()
+	WriteSummary(&sb, Compare(after, after), false)
+	if sb.String() != "no file changes\n" {
+		t.Errorf("expected no-change line, got %q", sb.String())
+	}
+
+	sb.Reset()
+	WriteSummary(&sb, Compare(nil, map[string]string{"a.tf": "x\n"}), true)
+	if !strings.Contains(sb.String(), ansiGreen) {
+		t.Errorf("expected colored output, got %q", sb.String())
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	mustWrite := func(rel, content string) {
+		t.Helper()
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustWrite("main.tf", "a")
+	mustWrite("modules/vpc/main.tf", "b")
+	mustWrite("prod.tfvars", "c")
+	mustWrite("README.md", "ignored")
+	mustWrite(".terraform/modules/x.tf", "ignored")
+
+	snap, err := Snapshot(dir)
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	want := map[string]string{"main.tf": "a", filepath.Join("modules", "vpc", "main.tf"): "b", "prod.tfvars": "c"}
+	if len(snap) != len(want) {
+		t.Fatalf("expected %d files, got %v", len(want), snap)
+	}
+	for k, v := range want {
+		if snap[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, snap[k])
+		}
+	}
+}
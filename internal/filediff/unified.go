@@ -0,0 +1,163 @@
+package filediff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// unifiedContext is the number of unchanged lines shown around each change
+// in a unified diff, as with `diff -u`.
+const unifiedContext = 3
+
+// maxDiffCells bounds the line-matching table Unified builds. Larger files
+// are shown as a complete removal followed by a complete addition.
+const maxDiffCells = 4 << 20
+
+// FileDiff is the unified diff of one file.
+type FileDiff struct {
+	// Path is relative to the workspace.
+	Path string
+	// Diff is the unified diff text, including the ---/+++ headers.
+	Diff string
+}
+
+// lineOp is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type lineOp struct {
+	kind byte
+	text string
+}
+
+// Unified returns a unified diff of the file at path from before to after,
+// with three lines of context. An empty side is shown as /dev/null, so
+// added and removed files get the usual headers. It returns "" when the
+// contents are equal.
+func Unified(path, before, after string) string {
+	if before == after {
+		return ""
+	}
+	ops := diffLines(splitLines(before), splitLines(after))
+
+	// aPos[k] and bPos[k] are the lines of each side consumed before ops[k].
+	aPos, bPos := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for k, op := range ops {
+		aPos[k+1], bPos[k+1] = aPos[k], bPos[k]
+		if op.kind != '+' {
+			aPos[k+1]++
+		}
+		if op.kind != '-' {
+			bPos[k+1]++
+		}
+	}
+
+	from, to := "a/"+path, "b/"+path
+	if before == "" {
+		from = "/dev/null"
+	}
+	if after == "" {
+		to = "/dev/null"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", from, to)
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// Extend the hunk over every change closer than two contexts apart.
+		end := i + 1
+		for j := end; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j + 1
+			} else if j-end >= 2*unifiedContext {
+				break
+			}
+		}
+		start, stop := max(i-unifiedContext, 0), min(end+unifiedContext, len(ops))
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(aPos[start], aPos[stop]-aPos[start]),
+			hunkRange(bPos[start], bPos[stop]-bPos[start]))
+		for _, op := range ops[start:stop] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+		i = stop
+	}
+	return sb.String()
+}
+
+// hunkRange formats one side of a hunk header the way diff does: a
+// one-line range omits its count and an empty range names the line before
+// it.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+// splitLines splits s into lines without their terminators.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns a shortest edit script from a to b, found through their
+// longest common subsequence of lines.
+func diffLines(a, b []string) []lineOp {
+	n, m := len(a), len(b)
+	ops := make([]lineOp, 0, n+m)
+	if n*m > maxDiffCells {
+		for _, l := range a {
+			ops = append(ops, lineOp{'-', l})
+		}
+		for _, l := range b {
+			ops = append(ops, lineOp{'+', l})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, lineOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, lineOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, lineOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, lineOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, lineOp{'+', b[j]})
+	}
+	return ops
+}
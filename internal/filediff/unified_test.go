@@ -0,0 +1,80 @@
+package filediff
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines "1" through "n", with replacements applied.
+func numbered(n int, replace map[int]string) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		line := strconv.Itoa(i)
+		if r, ok := replace[i]; ok {
+			line = r
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+func TestUnified(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+	}{
+		{name: "unchanged", before: "a\n", after: "a\n", want: ""},
+		{
+			name:   "one change",
+			before: numbered(10, nil),
+			after:  numbered(10, map[int]string{5: "five"}),
+			want:   "--- a/main.tf\n+++ b/main.tf\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:   "distant changes",
+			before: numbered(20, nil),
+			after:  numbered(20, map[int]string{2: "two", 18: "eighteen"}),
+			want: "--- a/main.tf\n+++ b/main.tf\n" +
+				"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n",
+		},
+		{
+			name:   "nearby changes share a hunk",
+			before: numbered(20, nil),
+			after:  numbered(20, map[int]string{4: "four", 10: "ten"}),
+			want: "--- a/main.tf\n+++ b/main.tf\n" +
+				"@@ -1,13 +1,13 @@\n 1\n 2\n 3\n-4\n+four\n 5\n 6\n 7\n 8\n 9\n-10\n+ten\n 11\n 12\n 13\n",
+		},
+		{
+			name:   "added file",
+			before: "",
+			after:  "a\nb\n",
+			want:   "--- /dev/null\n+++ b/main.tf\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:   "one-line file",
+			before: "a\n",
+			after:  "b\n",
+			want:   "--- a/main.tf\n+++ b/main.tf\n@@ -1 +1 @@\n-a\n+b\n",
+		},
+		{
+			name:   "removed file",
+			before: "a\nb\n",
+			after:  "",
+			want:   "--- a/main.tf\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := Unified("main.tf", tc.before, tc.after); got != tc.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// Package githook installs and removes the git pre-commit hook that runs
+// `tfai check --staged`. A hook tfai did not write is never overwritten or
+// removed unless the caller forces it.
+package githook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/gitutil"
+)
+
+// Marker is the line that identifies a hook written by Install.
+const Marker = "# tfai-managed hook"
+
+// ErrForeignHook is returned when the pre-commit hook exists but was not
+// written by Install.
+var ErrForeignHook = errors.New("githook: pre-commit hook was not written by tfai")
+
+// ErrNotInstalled is returned by Uninstall when there is no pre-commit hook.
+var ErrNotInstalled = errors.New("githook: no pre-commit hook installed")
+
+// Script returns the pre-commit hook that runs checks on the staged files
+// under prefix, the workspace path relative to the top of the work tree
+// ("" for the top level). Git runs hooks from the top level, so the path
+// stays valid wherever the repository is cloned.
+func Script(checks []string, prefix string) string {
+	dir := strings.TrimSuffix(prefix, "/")
+	if dir == "" {
+		dir = "."
+	}
+	return "#!/bin/sh\n" +
+		Marker + ": written by `tfai hook install`, remove with `tfai hook uninstall`.\n" +
+		"# Runs tfai's deterministic checks on the staged Terraform files.\n" +
+		fmt.Sprintf("exec tfai check --staged --dir %s --checks %s\n", shellQuote(dir), strings.Join(checks, ","))
+}
+
+// Install writes the pre-commit hook of the repository containing dir and
+// returns its path. An existing hook not written by tfai is replaced only
+// when force is set; otherwise ErrForeignHook is returned.
+func Install(ctx context.Context, dir string, checks []string, force bool) (string, error) {
+	path, err := gitutil.HookPath(ctx, dir, "pre-commit")
+	if err != nil {
+		return "", err //nolint:wrapcheck // gitutil errors are already prefixed
+	}
+	prefix, err := gitutil.Prefix(ctx, dir)
+	if err != nil {
+		return "", err //nolint:wrapcheck // gitutil errors are already prefixed
+	}
+	if err := checkOwned(path, force); err != nil && !errors.Is(err, ErrNotInstalled) {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("githook: failed to create hooks directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(Script(checks, prefix)), 0o755); err != nil { //nolint:gosec // hooks must be executable
+		return "", fmt.Errorf("githook: failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// Uninstall removes the pre-commit hook of the repository containing dir
+// and returns its path. A hook not written by tfai is removed only when
+// force is set; otherwise ErrForeignHook is returned.
+func Uninstall(ctx context.Context, dir string, force bool) (string, error) {
+	path, err := gitutil.HookPath(ctx, dir, "pre-commit")
+	if err != nil {
+		return "", err //nolint:wrapcheck // gitutil errors are already prefixed
+	}
+	if err := checkOwned(path, force); err != nil {
+		return "", err
+	}
+	if err := os.Remove(path); err != nil {
+		return "", fmt.Errorf("githook: failed to remove %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// checkOwned returns ErrNotInstalled when path does not exist, and
+// ErrForeignHook when it lacks Marker and force is not set.
+func checkOwned(path string, force bool) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotInstalled, path)
+	}
+	if err != nil {
+		return fmt.Errorf("githook: failed to read %s: %w", path, err)
+	}
+	if !force && !strings.Contains(string(b), Marker) {
+		return fmt.Errorf("%w: %s (use --force to override)", ErrForeignHook, path)
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,122 @@
+package githook
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepo creates a git repository with an "infra" subdirectory and returns
+// both paths. The test is skipped when git is not installed.
+func newRepo(t *testing.T) (repo, ws string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo = t.TempDir()
+	ws = filepath.Join(repo, "infra")
+	if err := os.Mkdir(ws, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", repo, "init", "--quiet").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	return repo, ws
+}
+
+func TestScript(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "", want: "exec tfai check --staged --dir '.' --checks fmt,secrets\n"},
+		{prefix: "infra/", want: "exec tfai check --staged --dir 'infra' --checks fmt,secrets\n"},
+		{prefix: "it's/", want: `exec tfai check --staged --dir 'it'\''s' --checks fmt,secrets` + "\n"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.prefix, func(t *testing.T) {
+			t.Parallel()
+			got := Script([]string{"fmt", "secrets"}, tc.prefix)
+			if !strings.HasPrefix(got, "#!/bin/sh\n"+Marker) || !strings.HasSuffix(got, tc.want) {
+				t.Errorf("unexpected script:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestInstallUninstall(t *testing.T) {
+	t.Parallel()
+
+	repo, ws := newRepo(t)
+	ctx := context.Background()
+	hook := filepath.Join(repo, ".git", "hooks", "pre-commit")
+
+	path, err := Install(ctx, ws, []string{"fmt", "vars", "secrets"}, false)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if filepath.Clean(path) != hook {
+		t.Errorf("expected %s, got %s", hook, path)
+	}
+	info, err := os.Stat(hook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("expected an executable hook, got mode %v", info.Mode())
+	}
+	b, _ := os.ReadFile(hook)
+	if !strings.Contains(string(b), "--dir 'infra' --checks fmt,vars,secrets") {
+		t.Errorf("expected the workspace and checks in the hook:\n%s", b)
+	}
+
+	// Reinstalling over our own hook is allowed.
+	if _, err := Install(ctx, ws, []string{"fmt"}, false); err != nil {
+		t.Fatalf("reinstall: %v", err)
+	}
+	if _, err := Uninstall(ctx, ws, false); err != nil {
+		t.Fatalf("Uninstall: %v", err)
+	}
+	if _, err := os.Stat(hook); !os.IsNotExist(err) {
+		t.Errorf("expected the hook removed, got %v", err)
+	}
+	if _, err := Uninstall(ctx, ws, false); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("expected ErrNotInstalled, got %v", err)
+	}
+}
+
+func TestForeignHook(t *testing.T) {
+	t.Parallel()
+
+	repo, ws := newRepo(t)
+	ctx := context.Background()
+	hook := filepath.Join(repo, ".git", "hooks", "pre-commit")
+	foreign := "#!/bin/sh\nnpm test\n"
+	if err := os.WriteFile(hook, []byte(foreign), 0o755); err != nil { //nolint:gosec // hooks must be executable
+		t.Fatal(err)
+	}
+
+	if _, err := Install(ctx, ws, []string{"fmt"}, false); !errors.Is(err, ErrForeignHook) {
+		t.Errorf("Install: expected ErrForeignHook, got %v", err)
+	}
+	if _, err := Uninstall(ctx, ws, false); !errors.Is(err, ErrForeignHook) {
+		t.Errorf("Uninstall: expected ErrForeignHook, got %v", err)
+	}
+	if b, _ := os.ReadFile(hook); string(b) != foreign {
+		t.Fatalf("expected the foreign hook untouched, got:\n%s", b)
+	}
+
+	if _, err := Install(ctx, ws, []string{"fmt"}, true); err != nil {
+		t.Fatalf("Install --force: %v", err)
+	}
+	if b, _ := os.ReadFile(hook); !strings.Contains(string(b), Marker) {
+		t.Errorf("expected the hook replaced, got:\n%s", b)
+	}
+}
@@ -0,0 +1,221 @@
+// Package gitutil runs the few git commands tfai needs directly, without the
+// tools.Runner used for terraform: reading the uncommitted changes of a
+// workspace and committing them. Every command is scoped to the workspace
+// directory, which may be a subdirectory of the repository.
+package gitutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/filediff"
+	"github.com/54b3r/tfai-go/internal/textenc"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+)
+
+// ErrNotRepository is returned when the workspace is not inside a git work
+// tree, or git is not installed.
+var ErrNotRepository = errors.New("gitutil: not a git repository")
+
+// ErrNothingToCommit is returned by Commit when the workspace has no changes.
+var ErrNothingToCommit = errors.New("gitutil: nothing to commit")
+
+// excludeTfai is the pathspec that keeps tfai's own working directory out of
+// every diff and commit, whether or not it is ignored.
+const excludeTfai = ":(exclude)" + tfaidir.DirName
+
+// run runs git in dir with stdin and returns its standard output.
+func run(ctx context.Context, dir string, stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdin = stdin
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("gitutil: git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// checkRepository returns ErrNotRepository unless dir is inside a work tree.
+func checkRepository(ctx context.Context, dir string) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		return fmt.Errorf("%w: git is not installed", ErrNotRepository)
+	}
+	if _, err := run(ctx, dir, nil, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return fmt.Errorf("%w: %s", ErrNotRepository, dir)
+	}
+	return nil
+}
+
+// Diff returns the uncommitted changes under dir against HEAD, one entry
+// per file, sorted by path. Staged, unstaged, and untracked (but not
+// ignored) files are all included, except the .tfai directory; paths are
+// relative to dir. In a
+// repository without commits every file counts as added.
+func Diff(ctx context.Context, dir string) ([]filediff.FileDiff, error) {
+	if err := checkRepository(ctx, dir); err != nil {
+		return nil, err
+	}
+	base := "HEAD"
+	if _, err := run(ctx, dir, nil, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		// No commits yet: diff against the empty tree of this repository's
+		// hash algorithm.
+		tree, err := run(ctx, dir, strings.NewReader(""), "hash-object", "-t", "tree", "--stdin")
+		if err != nil {
+			return nil, err
+		}
+		base = strings.TrimSpace(tree)
+	}
+
+	out, err := run(ctx, dir, nil, "diff", "--no-color", "--no-ext-diff", "--relative", base, "--", ".", excludeTfai)
+	if err != nil {
+		return nil, err
+	}
+	diffs := splitDiff(out)
+
+	untracked, err := run(ctx, dir, nil, "ls-files", "--others", "--exclude-standard", "-z", "--", ".", excludeTfai)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range strings.Split(untracked, "\x00") {
+		if rel == "" {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			return nil, fmt.Errorf("gitutil: failed to read %s: %w", rel, err)
+		}
+		rel = filepath.ToSlash(rel)
+		text, err := textenc.Decode(b)
+		if err != nil {
+			diffs = append(diffs, filediff.FileDiff{Path: rel, Diff: "Binary file " + rel + " added\n"})
+			continue
+		}
+		diffs = append(diffs, filediff.FileDiff{Path: rel, Diff: filediff.Unified(rel, "", text.Content)})
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+// splitDiff splits the output of git diff into one entry per file.
+func splitDiff(out string) []filediff.FileDiff {
+	var diffs []filediff.FileDiff
+	for _, chunk := range strings.SplitAfter(out, "\n") {
+		if strings.HasPrefix(chunk, "diff --git ") {
+			diffs = append(diffs, filediff.FileDiff{Path: diffPath(chunk)})
+		}
+		if len(diffs) > 0 {
+			diffs[len(diffs)-1].Diff += chunk
+		}
+	}
+	return diffs
+}
+
+// diffPath extracts the new path from a "diff --git a/x b/x" header.
+func diffPath(header string) string {
+	header = strings.TrimSpace(strings.TrimPrefix(header, "diff --git "))
+	if i := strings.LastIndex(header, " b/"); i >= 0 {
+		return header[i+len(" b/"):]
+	}
+	return header
+}
+
+// Commit stages every change under dir, including new and deleted files but
+// not the .tfai directory, and commits it with message. It returns the
+// abbreviated commit hash, or ErrNothingToCommit when there were no changes.
+func Commit(ctx context.Context, dir, message string) (string, error) {
+	if err := checkRepository(ctx, dir); err != nil {
+		return "", err
+	}
+	if _, err := run(ctx, dir, nil, "add", "--all", "--", ".", excludeTfai); err != nil {
+		return "", err
+	}
+	if _, err := run(ctx, dir, nil, "diff", "--cached", "--quiet", "--", "."); err == nil {
+		return "", ErrNothingToCommit
+	}
+	if _, err := run(ctx, dir, strings.NewReader(message), "commit", "--quiet", "--file=-", "--", ".", excludeTfai); err != nil {
+		return "", err
+	}
+	hash, err := run(ctx, dir, nil, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(hash), nil
+}
+
+// StagedFile is the staged (index) content of one file.
+type StagedFile struct {
+	// Path is slash-separated and relative to the directory passed to
+	// Staged.
+	Path string
+	// Content is the file as staged, which may differ from the working
+	// tree.
+	Content []byte
+}
+
+// Staged returns the files under dir that are added, copied, modified, or
+// renamed in the index, sorted by path, with their staged content. Deleted
+// files are left out. match filters the paths (relative to dir); nil keeps
+// every file.
+func Staged(ctx context.Context, dir string, match func(rel string) bool) ([]StagedFile, error) {
+	if err := checkRepository(ctx, dir); err != nil {
+		return nil, err
+	}
+	out, err := run(ctx, dir, nil, "diff", "--cached", "--name-only", "--diff-filter=ACMR", "--relative", "-z", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	var files []StagedFile
+	for _, rel := range strings.Split(out, "\x00") {
+		if rel == "" || (match != nil && !match(rel)) {
+			continue
+		}
+		content, err := run(ctx, dir, nil, "show", ":./"+rel)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, StagedFile{Path: rel, Content: []byte(content)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Prefix returns the slash-separated path of dir relative to the top of its
+// work tree, with a trailing slash, or "" at the top level.
+func Prefix(ctx context.Context, dir string) (string, error) {
+	if err := checkRepository(ctx, dir); err != nil {
+		return "", err
+	}
+	out, err := run(ctx, dir, nil, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// HookPath returns the absolute path of the named hook (e.g. "pre-commit")
+// of the repository containing dir, honouring core.hooksPath. The file may
+// not exist.
+func HookPath(ctx context.Context, dir, name string) (string, error) {
+	if err := checkRepository(ctx, dir); err != nil {
+		return "", err
+	}
+	out, err := run(ctx, dir, nil, "rev-parse", "--git-path", "hooks/"+name)
+	if err != nil {
+		return "", err
+	}
+	path := strings.TrimSpace(out)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path, nil
+}
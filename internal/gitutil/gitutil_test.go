@@ -0,0 +1,215 @@
+package gitutil
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepo creates a git repository with a "infra" workspace directory and
+// returns both paths. The test is skipped when git is not installed.
+func newRepo(t *testing.T) (repo, ws string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo = t.TempDir()
+	ws = filepath.Join(repo, "infra")
+	if err := os.Mkdir(ws, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	git(t, repo, "init", "--quiet")
+	git(t, repo, "config", "user.name", "tfai test")
+	git(t, repo, "config", "user.email", "test@example.com")
+	git(t, repo, "config", "commit.gpgsign", "false")
+	return repo, ws
+}
+
+// git runs a git command in dir, failing the test on error.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := run(context.Background(), dir, nil, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// write writes content to rel under dir.
+func write(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	repo, ws := newRepo(t)
+	write(t, ws, "main.tf", "a\nb\n")
+	write(t, ws, "old.tf", "gone\n")
+	write(t, repo, "README.md", "outside the workspace\n")
+	git(t, repo, "add", ".")
+	git(t, repo, "commit", "--quiet", "-m", "initial")
+
+	write(t, ws, "main.tf", "a\nc\n")
+	write(t, ws, "modules/vpc/main.tf", "new\n")
+	write(t, ws, "staged.tf", "staged\n")
+	write(t, ws, ".gitignore", "*.tfstate\n")
+	write(t, ws, "terraform.tfstate", "ignored\n")
+	write(t, ws, ".tfai/manifest.json", "{}\n")
+	write(t, repo, "README.md", "changed outside the workspace\n")
+	if err := os.Remove(filepath.Join(ws, "old.tf")); err != nil {
+		t.Fatal(err)
+	}
+	git(t, ws, "add", "staged.tf")
+
+	diffs, err := Diff(context.Background(), ws)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	var paths []string
+	for _, d := range diffs {
+		paths = append(paths, d.Path)
+	}
+	if got, want := strings.Join(paths, ","), ".gitignore,main.tf,modules/vpc/main.tf,old.tf,staged.tf"; got != want {
+		t.Fatalf("want files %s, got %s", want, got)
+	}
+	for _, d := range diffs {
+		var want string
+		switch d.Path {
+		case "main.tf":
+			want = "-b\n+c\n"
+		case "modules/vpc/main.tf":
+			want = "+++ b/modules/vpc/main.tf\n@@ -0,0 +1 @@\n+new\n"
+		case "old.tf":
+			want = "deleted file mode"
+		case "staged.tf":
+			want = "+staged\n"
+		}
+		if !strings.Contains(d.Diff, want) {
+			t.Errorf("%s: want diff containing %q, got:\n%s", d.Path, want, d.Diff)
+		}
+	}
+}
+
+func TestDiff_NoCommits(t *testing.T) {
+	t.Parallel()
+
+	_, ws := newRepo(t)
+	write(t, ws, "main.tf", "a\n")
+	git(t, ws, "add", "main.tf")
+	write(t, ws, "vars.tf", "v\n")
+
+	diffs, err := Diff(context.Background(), ws)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(diffs) != 2 || !strings.Contains(diffs[0].Diff, "+a") || !strings.Contains(diffs[1].Diff, "+v") {
+		t.Errorf("want both files added, got %+v", diffs)
+	}
+}
+
+func TestDiff_NotRepository(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Diff(context.Background(), t.TempDir()); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("want ErrNotRepository, got %v", err)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	t.Parallel()
+
+	repo, ws := newRepo(t)
+	write(t, ws, "main.tf", "a\n")
+	write(t, repo, "README.md", "not part of the workspace\n")
+	write(t, ws, ".tfai/manifest.json", "{}\n")
+
+	hash, err := Commit(context.Background(), ws, "Add main.tf\n\nGenerated summary.\n")
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if hash == "" {
+		t.Error("want the commit hash")
+	}
+	if got := git(t, repo, "log", "-1", "--format=%B"); !strings.HasPrefix(got, "Add main.tf\n\nGenerated summary.") {
+		t.Errorf("unexpected commit message %q", got)
+	}
+	if got := git(t, repo, "show", "--name-only", "--format=", "HEAD"); strings.TrimSpace(got) != "infra/main.tf" {
+		t.Errorf("want only the workspace committed, got %q", got)
+	}
+
+	if _, err := Commit(context.Background(), ws, "again"); !errors.Is(err, ErrNothingToCommit) {
+		t.Errorf("want ErrNothingToCommit, got %v", err)
+	}
+}
+
+func TestStaged(t *testing.T) {
+	t.Parallel()
+
+	repo, ws := newRepo(t)
+	write(t, ws, "main.tf", "committed\n")
+	write(t, ws, "old.tf", "to delete\n")
+	git(t, repo, "add", ".")
+	git(t, repo, "commit", "--quiet", "-m", "initial")
+
+	write(t, ws, "main.tf", "staged\n")
+	write(t, ws, "modules/vpc/vpc.tf", "new\n")
+	write(t, ws, "README.md", "not terraform\n")
+	write(t, repo, "root.tf", "outside the workspace\n")
+	git(t, repo, "add", ".")
+	git(t, repo, "rm", "--quiet", "infra/old.tf")
+	write(t, ws, "main.tf", "edited after staging\n")
+	write(t, ws, "unstaged.tf", "untracked\n")
+
+	files, err := Staged(context.Background(), ws, func(rel string) bool { return strings.HasSuffix(rel, ".tf") })
+	if err != nil {
+		t.Fatalf("Staged: %v", err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Path+"="+strings.TrimSpace(string(f.Content)))
+	}
+	want := []string{"main.tf=staged", "modules/vpc/vpc.tf=new"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("want %v, got %v", want, got)
+	}
+
+	if _, err := Staged(context.Background(), t.TempDir(), nil); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("want ErrNotRepository, got %v", err)
+	}
+}
+
+func TestPrefixAndHookPath(t *testing.T) {
+	t.Parallel()
+
+	repo, ws := newRepo(t)
+	if got, err := Prefix(context.Background(), ws); err != nil || got != "infra/" {
+		t.Errorf("want prefix infra/, got %q, %v", got, err)
+	}
+	if got, err := Prefix(context.Background(), repo); err != nil || got != "" {
+		t.Errorf("want an empty prefix at the top level, got %q, %v", got, err)
+	}
+
+	got, err := HookPath(context.Background(), ws, "pre-commit")
+	if err != nil {
+		t.Fatalf("HookPath: %v", err)
+	}
+	if want := filepath.Join(repo, ".git", "hooks", "pre-commit"); filepath.Clean(got) != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	git(t, repo, "config", "core.hooksPath", "githooks")
+	if got, _ := HookPath(context.Background(), ws, "pre-commit"); filepath.Clean(got) != filepath.Join(repo, "githooks", "pre-commit") {
+		t.Errorf("want core.hooksPath honoured, got %s", got)
+	}
+}
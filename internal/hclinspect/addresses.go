@@ -0,0 +1,254 @@
+package hclinspect
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// ResourceAddress is a resource or data source address as terraform prints
+// it, e.g. module.vpc.aws_subnet.private[0], without the instance keys.
+type ResourceAddress struct {
+	// Module is the module path, outermost first: ["vpc", "subnets"] for
+	// module.vpc.module.subnets. Nil for the root module.
+	Module []string
+	// Data is true for a data source.
+	Data bool
+	// Type is the resource type, e.g. "aws_subnet".
+	Type string
+	// Name is the resource name, e.g. "private".
+	Name string
+}
+
+// String renders the address as terraform does, without instance keys.
+func (a ResourceAddress) String() string {
+	var b strings.Builder
+	for _, m := range a.Module {
+		b.WriteString("module." + m + ".")
+	}
+	if a.Data {
+		b.WriteString("data.")
+	}
+	b.WriteString(a.Type + "." + a.Name)
+	return b.String()
+}
+
+// localKey is the address within its module, e.g. "data.aws_ami.ubuntu".
+func (a ResourceAddress) localKey() string {
+	return ResourceAddress{Data: a.Data, Type: a.Type, Name: a.Name}.String()
+}
+
+var (
+	// addressRe matches a resource address in terraform output. Resource
+	// types always contain an underscore (provider_type), which keeps
+	// references such as var.name and local.tags out.
+	addressRe = regexp.MustCompile(`((?:module\.[A-Za-z_][\w-]*(?:\[[^\]\s]*\])?\.)*)(data\.)?([a-z][a-z0-9]*_[a-z0-9_]+)\.([A-Za-z_][\w-]*)`)
+	// moduleStepRe matches one module.NAME[KEY] step of addressRe's prefix.
+	moduleStepRe = regexp.MustCompile(`module\.([A-Za-z_][\w-]*)`)
+	// blockHeaderRe matches the block named in terraform diagnostics, e.g.
+	// `in resource "aws_instance" "web":`.
+	blockHeaderRe = regexp.MustCompile(`\b(resource|data) "([\w-]+)" "([\w-]+)"`)
+	// fileRefRe matches the source location of terraform diagnostics, e.g.
+	// "on modules/vpc/main.tf line 12".
+	fileRefRe = regexp.MustCompile(`\bon (\S+\.tf) line \d+`)
+)
+
+// fileExtensions are the names addressRe would take from file names such as
+// "vpc_endpoints.tf".
+var fileExtensions = []string{"tf", "tfvars", "tfstate", "hcl", "json"}
+
+// ParseAddresses returns the resource addresses mentioned in terraform plan
+// or apply output, each once, in order of first mention. Both full addresses
+// (module.vpc.aws_subnet.private[0]) and the block headers of diagnostics
+// (in resource "aws_instance" "web") are recognised; the latter always name
+// a root module address, since terraform reports the module in the file
+// path instead.
+func ParseAddresses(text string) []ResourceAddress {
+	var out []ResourceAddress
+	seen := make(map[string]bool)
+	add := func(a ResourceAddress) {
+		if key := a.String(); !seen[key] {
+			seen[key] = true
+			out = append(out, a)
+		}
+	}
+	for _, m := range addressRe.FindAllStringSubmatchIndex(text, -1) {
+		// Part of a longer name: var.aws_region, vpc/aws_vpc.tf.
+		if m[0] > 0 && partOfName(text[m[0]-1]) {
+			continue
+		}
+		name := text[m[8]:m[9]]
+		if slices.Contains(fileExtensions, name) {
+			continue
+		}
+		a := ResourceAddress{Data: m[4] >= 0, Type: text[m[6]:m[7]], Name: name}
+		for _, step := range moduleStepRe.FindAllStringSubmatch(text[m[2]:m[3]], -1) {
+			a.Module = append(a.Module, step[1])
+		}
+		add(a)
+	}
+	for _, m := range blockHeaderRe.FindAllStringSubmatch(text, -1) {
+		add(ResourceAddress{Data: m[1] == "data", Type: m[2], Name: m[3]})
+	}
+	return out
+}
+
+// partOfName reports whether an address preceded by c is the tail of a
+// longer name or path rather than an address of its own.
+func partOfName(c byte) bool {
+	return strings.IndexByte("./-_", c) >= 0 || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// ParseFileRefs returns the .tf files terraform diagnostics point at ("on
+// main.tf line 12"), each once, in order of first mention. The paths are as
+// printed: relative to the directory terraform ran in.
+func ParseFileRefs(text string) []string {
+	var out []string
+	for _, m := range fileRefRe.FindAllStringSubmatch(text, -1) {
+		if p := path.Clean(filepath.ToSlash(m[1])); !slices.Contains(out, p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Location is the file defining a resource address.
+type Location struct {
+	// Address is the resource address.
+	Address ResourceAddress
+	// File is the defining file's slash-separated path relative to the
+	// workspace.
+	File string
+}
+
+// moduleIndex is what one module directory defines.
+type moduleIndex struct {
+	// resources maps each resource's module-local key to its file.
+	resources map[string]string
+	// calls maps each module call's name to its literal source.
+	calls map[string]string
+	// settings lists the files with terraform or provider blocks.
+	settings []string
+}
+
+// LocateResources maps each of addrs to the file of the workspace at dir
+// that defines it, following module calls from the root module: local
+// sources ("./modules/vpc") directly and other sources through the
+// .terraform/modules/modules.json that terraform init writes. Addresses that
+// cannot be found are left out. Files that fail to parse contribute the
+// blocks that could be read.
+func LocateResources(dir string, addrs []ResourceAddress) []Location {
+	indexes := make(map[string]*moduleIndex)
+	installed := installedModules(dir)
+	var out []Location
+	for _, a := range addrs {
+		rel := "."
+		for i, name := range a.Module {
+			source, ok := indexModule(dir, rel, indexes).calls[name]
+			if !ok {
+				rel = ""
+				break
+			}
+			if strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../") {
+				rel = path.Join(rel, source)
+			} else if rel, ok = installed[strings.Join(a.Module[:i+1], ".")]; !ok {
+				rel = ""
+				break
+			}
+		}
+		// A module outside the workspace is not part of its context.
+		if rel == "" || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		if file, ok := indexModule(dir, rel, indexes).resources[a.localKey()]; ok {
+			out = append(out, Location{Address: a, File: file})
+		}
+	}
+	return out
+}
+
+// SettingsFiles returns the root module files of the workspace at dir that
+// hold terraform or provider blocks (conventionally versions.tf and
+// providers.tf), in name order.
+func SettingsFiles(dir string) []string {
+	return indexModule(dir, ".", make(map[string]*moduleIndex)).settings
+}
+
+// indexModule parses the .tf files of the module at the slash-separated rel
+// under dir, memoised in indexes. File paths in the index are relative to
+// dir.
+func indexModule(dir, rel string, indexes map[string]*moduleIndex) *moduleIndex {
+	if idx, ok := indexes[rel]; ok {
+		return idx
+	}
+	idx := &moduleIndex{resources: make(map[string]string), calls: make(map[string]string)}
+	indexes[rel] = idx
+	files, _ := filepath.Glob(filepath.Join(dir, filepath.FromSlash(rel), "*.tf"))
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		file := path.Join(rel, filepath.Base(f))
+		parsed, _ := hclsyntax.ParseConfig(src, file, hcl.InitialPos)
+		if parsed == nil {
+			continue
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		settings := false
+		for _, block := range body.Blocks {
+			switch {
+			case block.Type == "resource" && len(block.Labels) == 2:
+				idx.resources[block.Labels[0]+"."+block.Labels[1]] = file
+			case block.Type == "data" && len(block.Labels) == 2:
+				idx.resources["data."+block.Labels[0]+"."+block.Labels[1]] = file
+			case block.Type == "module" && len(block.Labels) == 1:
+				if source, err := stringAttr(block.Body, "source"); err == nil && source != "" {
+					idx.calls[block.Labels[0]] = source
+				}
+			case block.Type == "terraform" || block.Type == "provider":
+				settings = true
+			}
+		}
+		if settings {
+			idx.settings = append(idx.settings, file)
+		}
+	}
+	return idx
+}
+
+// installedModules reads the module directories terraform init recorded in
+// .terraform/modules/modules.json, by key (the dotted module path, e.g.
+// "vpc.subnets"). It returns an empty map when there is none.
+func installedModules(dir string) map[string]string {
+	out := make(map[string]string)
+	b, err := os.ReadFile(filepath.Join(dir, ".terraform", "modules", "modules.json"))
+	if err != nil {
+		return out
+	}
+	var manifest struct {
+		Modules []struct {
+			Key string `json:"Key"`
+			Dir string `json:"Dir"`
+		} `json:"Modules"`
+	}
+	if json.Unmarshal(b, &manifest) != nil {
+		return out
+	}
+	for _, m := range manifest.Modules {
+		if m.Key != "" {
+			out[m.Key] = path.Clean(filepath.ToSlash(m.Dir))
+		}
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+package hclinspect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/testutil"
+)
+
+// planOutput reads the plan output fixture testdata/plan/name.
+func planOutput(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", "plan", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// ---------------------------------------------------------------------------
+// ParseAddresses
+// ---------------------------------------------------------------------------
+
+func TestParseAddresses(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		fixture string
+		want    []string
+	}{
+		{fixture: "invalid_reference.txt", want: []string{"aws_s3_bucket.log", "aws_s3_bucket_versioning.logs"}},
+		{fixture: "module_apply.txt", want: []string{
+			"module.vpc.module.subnets.aws_subnet.private",
+			"module.spot_nodes.aws_eks_node_group.spot",
+			"aws_subnet.private",
+			"aws_eks_node_group.spot",
+		}},
+		{fixture: "data_source.txt", want: []string{"data.aws_ami.ubuntu"}},
+		{fixture: "no_addresses.txt"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.fixture, func(t *testing.T) {
+			t.Parallel()
+			var got []string
+			for _, a := range ParseAddresses(planOutput(t, tc.fixture)) {
+				got = append(got, a.String())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseAddresses_Parts(t *testing.T) {
+	t.Parallel()
+
+	got := ParseAddresses(`module.eks["prod"].module.nodes[0].data.aws_iam_policy_document.assume`)
+	want := []ResourceAddress{{Module: []string{"eks", "nodes"}, Data: true, Type: "aws_iam_policy_document", Name: "assume"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestParseFileRefs(t *testing.T) {
+	t.Parallel()
+
+	got := ParseFileRefs(planOutput(t, "module_apply.txt"))
+	want := []string{"modules/network/vpc/subnets/main.tf", "modules/platform/eks/nodegroups/spot/main.tf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// LocateResources
+// ---------------------------------------------------------------------------
+
+func TestLocateResources(t *testing.T) {
+	t.Parallel()
+
+	ws := testutil.Fixture(t, testutil.FixtureModules).
+		WithFile("data.tf", "data \"aws_ami\" \"ubuntu\" {\n  most_recent = true\n}\n").
+		WithFile("main.tf", "module \"vpc\" {\n  source = \"./modules/network/vpc\"\n}\n\n"+
+			"module \"spot_nodes\" {\n  source = \"./modules/platform/eks/nodegroups/spot\"\n}\n\n"+
+			"module \"registry\" {\n  source = \"terraform-aws-modules/iam/aws\"\n}\n\n"+
+			"module \"remote\" {\n  source = \"git::https://example.com/remote.git\"\n}\n").
+		WithModule(".terraform/modules/registry").
+		WithFile(".terraform/modules/modules.json", `{"Modules":[{"Key":"","Dir":"."},{"Key":"registry","Source":"registry.terraform.io/terraform-aws-modules/iam/aws","Dir":".terraform/modules/registry"}]}`)
+
+	addrs := []ResourceAddress{
+		{Module: []string{"vpc", "subnets"}, Type: "aws_subnet", Name: "private"},
+		{Module: []string{"spot_nodes"}, Type: "aws_eks_node_group", Name: "spot"},
+		{Module: []string{"vpc"}, Type: "aws_vpc", Name: "this"},
+		{Data: true, Type: "aws_ami", Name: "ubuntu"},
+		{Module: []string{"registry"}, Type: "terraform_data", Name: "this"},
+		// Not found: wrong module, unknown module, not installed, root.
+		{Module: []string{"spot_nodes"}, Type: "aws_vpc", Name: "this"},
+		{Module: []string{"missing"}, Type: "aws_vpc", Name: "this"},
+		{Module: []string{"remote"}, Type: "aws_vpc", Name: "this"},
+		{Type: "aws_subnet", Name: "private"},
+	}
+	var got []string
+	for _, loc := range LocateResources(ws.Dir(), addrs) {
+		got = append(got, fmt.Sprintf("%s=%s", loc.Address, loc.File))
+	}
+	want := []string{
+		"module.vpc.module.subnets.aws_subnet.private=modules/network/vpc/subnets/main.tf",
+		"module.spot_nodes.aws_eks_node_group.spot=modules/platform/eks/nodegroups/spot/main.tf",
+		"module.vpc.aws_vpc.this=modules/network/vpc/main.tf",
+		"data.aws_ami.ubuntu=data.tf",
+		"module.registry.terraform_data.this=.terraform/modules/registry/main.tf",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestLocateResources_BrokenFile(t *testing.T) {
+	t.Parallel()
+
+	ws := testutil.Fixture(t, testutil.FixtureBroken)
+	got := LocateResources(ws.Dir(), []ResourceAddress{{Type: "aws_s3_bucket", Name: "ok"}, {Type: "aws_vpc", Name: "broken"}})
+	var files []string
+	for _, loc := range got {
+		files = append(files, loc.File)
+	}
+	if want := []string{"main.tf", "network.tf"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("expected the blocks read before the syntax error to be located in %v, got %v", want, files)
+	}
+}
+
+func TestSettingsFiles(t *testing.T) {
+	t.Parallel()
+
+	ws := testutil.Fixture(t, testutil.FixtureBasic).WithModule("modules/vpc").
+		WithFile("modules/vpc/versions.tf", "terraform {}\n")
+	if got, want := SettingsFiles(ws.Dir()), []string{"main.tf", "versions.tf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
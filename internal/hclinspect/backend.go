@@ -0,0 +1,79 @@
+package hclinspect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// EnvironmentFile is where terraform records the selected workspace,
+// relative to the working directory.
+const EnvironmentFile = ".terraform/environment"
+
+// DefaultWorkspace is the workspace terraform uses until another is
+// selected.
+const DefaultWorkspace = "default"
+
+// Backend types BackendType reports besides the declared backend labels.
+const (
+	// BackendLocal means no backend is configured, so state is the local
+	// terraform.tfstate.
+	BackendLocal = "local"
+	// BackendCloud means a terraform { cloud {} } block stores state in
+	// HCP Terraform.
+	BackendCloud = "cloud"
+)
+
+// BackendType returns the state backend the root module in dir configures:
+// the label of its terraform { backend "<type>" {} } block, BackendCloud
+// for a cloud block, or BackendLocal when neither is present. Only the .tf
+// files directly in dir are read, and files that fail to parse are
+// skipped, so a broken file never hides a backend declared elsewhere.
+func BackendType(dir string) string {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		parsed, _ := hclsyntax.ParseConfig(src, filepath.Base(f), hcl.InitialPos)
+		if parsed == nil {
+			continue
+		}
+		body, ok := parsed.Body.(*hclsyntax.Body)
+		if !ok {
+			continue
+		}
+		for _, block := range body.Blocks {
+			if block.Type != "terraform" {
+				continue
+			}
+			for _, inner := range block.Body.Blocks {
+				switch {
+				case inner.Type == "backend" && len(inner.Labels) == 1:
+					return inner.Labels[0]
+				case inner.Type == "cloud":
+					return BackendCloud
+				}
+			}
+		}
+	}
+	return BackendLocal
+}
+
+// ActiveWorkspace returns the terraform workspace selected in dir, read from
+// EnvironmentFile. It is DefaultWorkspace when the file is missing, which
+// includes a directory terraform init has never run in.
+func ActiveWorkspace(dir string) string {
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(EnvironmentFile)))
+	if err != nil {
+		return DefaultWorkspace
+	}
+	if name := strings.TrimSpace(string(b)); name != "" {
+		return name
+	}
+	return DefaultWorkspace
+}
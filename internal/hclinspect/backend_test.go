@@ -0,0 +1,99 @@
+package hclinspect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// BackendType and ActiveWorkspace
+// ---------------------------------------------------------------------------
+
+func TestBackendType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "local",
+			files: map[string]string{"main.tf": "terraform {\n  required_version = \">= 1.5\"\n}\n"},
+			want:  BackendLocal,
+		},
+		{
+			name: "s3",
+			files: map[string]string{
+				"main.tf":    "resource \"aws_vpc\" \"main\" {}\n",
+				"backend.tf": "terraform {\n  backend \"s3\" {\n    bucket = \"state\"\n    key    = \"vpc.tfstate\"\n  }\n}\n",
+			},
+			want: "s3",
+		},
+		{
+			name: "azurerm beside a broken file",
+			files: map[string]string{
+				"broken.tf":   "resource \"x\" {\n",
+				"versions.tf": "terraform {\n  required_version = \">= 1.5\"\n  backend \"azurerm\" {}\n}\n",
+			},
+			want: "azurerm",
+		},
+		{
+			name:  "cloud",
+			files: map[string]string{"main.tf": "terraform {\n  cloud {\n    organization = \"acme\"\n  }\n}\n"},
+			want:  BackendCloud,
+		},
+		{
+			name:  "backend in a module only",
+			files: map[string]string{"modules/vpc/main.tf": "terraform {\n  backend \"s3\" {}\n}\n"},
+			want:  BackendLocal,
+		},
+		{
+			name: "empty directory",
+			want: BackendLocal,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			for rel, content := range tc.files {
+				p := filepath.Join(dir, filepath.FromSlash(rel))
+				if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := BackendType(dir); got != tc.want {
+				t.Errorf("expected backend %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestActiveWorkspace(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if got := ActiveWorkspace(dir); got != DefaultWorkspace {
+		t.Errorf("without .terraform: expected %q, got %q", DefaultWorkspace, got)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ".terraform"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := ActiveWorkspace(dir); got != DefaultWorkspace {
+		t.Errorf("without an environment file: expected %q, got %q", DefaultWorkspace, got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(EnvironmentFile)), []byte("prod\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := ActiveWorkspace(dir); got != "prod" {
+		t.Errorf("expected %q, got %q", "prod", got)
+	}
+}
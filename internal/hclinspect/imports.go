@@ -0,0 +1,55 @@
+package hclinspect
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Import is an import block declared in a Terraform file.
+type Import struct {
+	// To is the block's to argument as written, e.g. aws_s3_bucket.logs or
+	// aws_s3_bucket.this["a"].
+	To string
+	// Address is To without instance keys. Its Type is empty when To is not
+	// a resource address.
+	Address ResourceAddress
+	// ID is the literal value of the id argument. Empty when it is not a
+	// literal string, such as a reference to a variable.
+	ID string
+	// Line is the line the block starts on.
+	Line int
+}
+
+// ParseImports returns the import blocks of the Terraform file src, in
+// declaration order. filename is used in error messages.
+func ParseImports(src []byte, filename string) ([]Import, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("hclinspect: failed to parse %s: %w", filename, diags)
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, nil
+	}
+	var out []Import
+	for _, block := range body.Blocks {
+		if block.Type != "import" {
+			continue
+		}
+		imp := Import{Line: block.DefRange().Start.Line}
+		if attr, ok := block.Body.Attributes["to"]; ok {
+			imp.To = strings.TrimSpace(string(attr.Expr.Range().SliceBytes(src)))
+			if addrs := ParseAddresses(imp.To); len(addrs) > 0 {
+				imp.Address = addrs[0]
+			}
+		}
+		// An id that is not a literal, such as var.bucket_name, is left
+		// empty rather than failing the file.
+		imp.ID, _ = stringAttr(block.Body, "id")
+		out = append(out, imp)
+	}
+	return out, nil
+}
@@ -0,0 +1,57 @@
+package hclinspect
+
+import "testing"
+
+// ---------------------------------------------------------------------------
+// ParseImports
+// ---------------------------------------------------------------------------
+
+func TestParseImports(t *testing.T) {
+	t.Parallel()
+
+	src := `import {
+  to = aws_s3_bucket.logs
+  id = "<name of the existing bucket>"
+}
+
+import {
+  to = aws_iam_role.this["reader"]
+  id = var.role_name
+}
+
+import {
+  to = module.vpc.aws_vpc.this
+  id = "vpc-0abc"
+}
+
+resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
+`
+	got, err := ParseImports([]byte(src), "imports.tf")
+	if err != nil {
+		t.Fatalf("ParseImports: %v", err)
+	}
+	want := []struct {
+		to, address, id string
+		line            int
+	}{
+		{to: "aws_s3_bucket.logs", address: "aws_s3_bucket.logs", id: "<name of the existing bucket>", line: 1},
+		{to: `aws_iam_role.this["reader"]`, address: "aws_iam_role.this", id: "", line: 6},
+		{to: "module.vpc.aws_vpc.this", address: "module.vpc.aws_vpc.this", id: "vpc-0abc", line: 11},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d imports, got %+v", len(want), got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.To != w.to || g.Address.String() != w.address || g.ID != w.id || g.Line != w.line {
+			t.Errorf("import %d: expected %s (%s) id %q line %d, got %s (%s) id %q line %d",
+				i, w.to, w.address, w.id, w.line, g.To, g.Address, g.ID, g.Line)
+		}
+	}
+
+	if _, err := ParseImports([]byte("import {\n  to = \n"), "broken.tf"); err == nil {
+		t.Error("expected a syntax error to fail")
+	}
+}
@@ -0,0 +1,301 @@
+// Package hclinspect extracts structured facts from Terraform workspaces by
+// parsing their HCL directly, without running terraform.
+package hclinspect
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// LockfileName is the dependency lock file terraform init writes.
+const LockfileName = ".terraform.lock.hcl"
+
+// LockedProvider is one provider block of a dependency lock file.
+type LockedProvider struct {
+	// Source is the provider's fully-qualified address, e.g.
+	// "registry.terraform.io/hashicorp/aws".
+	Source string
+	// Version is the selected version.
+	Version string
+	// Constraints is the version constraint the selection satisfied, if any.
+	Constraints string
+	// Hashes are the recorded package checksums ("h1:" and "zh:" schemes).
+	Hashes []string
+	// Platforms are the platforms (e.g. "linux_amd64") whose installed
+	// package matches a recorded h1 hash. The lock file does not name
+	// platforms, so only packages installed under .terraform/providers can
+	// be identified; see IdentifyPlatforms.
+	Platforms []string
+	// Unrecorded are the platforms whose installed package matches none of
+	// the recorded hashes.
+	Unrecorded []string
+}
+
+// h1Count returns the number of recorded h1 hashes. terraform records one
+// h1 hash per locked platform.
+func (p LockedProvider) h1Count() int {
+	n := 0
+	for _, h := range p.Hashes {
+		if strings.HasPrefix(h, "h1:") {
+			n++
+		}
+	}
+	return n
+}
+
+// MissingPlatform reports whether the lock file is known to have no hash for
+// platform. It is true when the installed package for platform matches none
+// of the recorded hashes, or when every recorded h1 hash is accounted for by
+// other platforms. It is false when platform is recorded or nothing is known.
+func (p LockedProvider) MissingPlatform(platform string) bool {
+	switch {
+	case slices.Contains(p.Platforms, platform):
+		return false
+	case slices.Contains(p.Unrecorded, platform):
+		return true
+	default:
+		return len(p.Platforms) > 0 && len(p.Platforms) >= p.h1Count()
+	}
+}
+
+// HostPlatform returns the terraform platform name of this machine, e.g.
+// "linux_arm64".
+func HostPlatform() string {
+	return runtime.GOOS + "_" + runtime.GOARCH
+}
+
+// ParseLockfile parses the contents of a dependency lock file. filename is
+// used in error messages only.
+func ParseLockfile(src []byte, filename string) ([]LockedProvider, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("hclinspect: failed to parse %s: %w", filename, diags)
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("hclinspect: failed to parse %s: unexpected body type %T", filename, file.Body)
+	}
+
+	var providers []LockedProvider
+	for _, block := range body.Blocks {
+		if block.Type != "provider" {
+			continue
+		}
+		if len(block.Labels) != 1 {
+			return nil, fmt.Errorf("hclinspect: %s: provider block at %s must have exactly one label", filename, block.DefRange())
+		}
+		p := LockedProvider{Source: block.Labels[0]}
+		var err error
+		if p.Version, err = stringAttr(block.Body, "version"); err != nil {
+			return nil, fmt.Errorf("hclinspect: %s: provider %q: %w", filename, p.Source, err)
+		}
+		if p.Version == "" {
+			return nil, fmt.Errorf("hclinspect: %s: provider %q has no version", filename, p.Source)
+		}
+		if p.Constraints, err = stringAttr(block.Body, "constraints"); err != nil {
+			return nil, fmt.Errorf("hclinspect: %s: provider %q: %w", filename, p.Source, err)
+		}
+		if p.Hashes, err = stringListAttr(block.Body, "hashes"); err != nil {
+			return nil, fmt.Errorf("hclinspect: %s: provider %q: %w", filename, p.Source, err)
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+// ReadLockfile parses the lock file in dir. The error wraps os.ErrNotExist
+// when dir has no lock file.
+func ReadLockfile(dir string) ([]LockedProvider, error) {
+	path := filepath.Join(dir, LockfileName)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("hclinspect: failed to read lock file: %w", err)
+	}
+	return ParseLockfile(src, path)
+}
+
+// IdentifyPlatforms fills in Platforms and Unrecorded for each provider by
+// hashing the packages terraform init installed under dir/.terraform/providers.
+// Provider packages can be hundreds of megabytes, so callers should not run
+// it on every query.
+func IdentifyPlatforms(dir string, providers []LockedProvider) {
+	for i := range providers {
+		classifyInstalled(dir, &providers[i])
+	}
+}
+
+// classifyInstalled sorts the platforms p has installed in dir into
+// Platforms and Unrecorded by comparing each package's h1 hash with the
+// recorded hashes. Unreadable packages are ignored.
+func classifyInstalled(dir string, p *LockedProvider) {
+	versionDir := filepath.Join(dir, ".terraform", "providers", filepath.FromSlash(p.Source), p.Version)
+	entries, err := os.ReadDir(versionDir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		// Packages linked from a plugin cache are symlinks to directories.
+		pkg, err := filepath.EvalSymlinks(filepath.Join(versionDir, e.Name()))
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(pkg); err != nil || !info.IsDir() {
+			continue
+		}
+		hash, err := dirhash.HashDir(pkg, "", dirhash.Hash1)
+		if err != nil {
+			continue
+		}
+		if slices.Contains(p.Hashes, hash) {
+			p.Platforms = append(p.Platforms, e.Name())
+		} else {
+			p.Unrecorded = append(p.Unrecorded, e.Name())
+		}
+	}
+}
+
+// MissingPlatform returns the providers whose lock entry is known to have no
+// hash for platform (see LockedProvider.MissingPlatform).
+func MissingPlatform(providers []LockedProvider, platform string) []LockedProvider {
+	var missing []LockedProvider
+	for _, p := range providers {
+		if p.MissingPlatform(platform) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// PlatformFinding reports providers whose lock entry has no hash for a
+// platform, which makes terraform init fail there with a checksum error.
+type PlatformFinding struct {
+	// Platform is the platform checked, e.g. "linux_arm64".
+	Platform string
+	// Missing are the providers without a hash for Platform.
+	Missing []LockedProvider
+	// Command records the missing hashes (see LockCommand).
+	Command string
+}
+
+// String renders the finding for a terminal or a model prompt.
+func (f *PlatformFinding) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s has no hashes for %s for these providers:\n", LockfileName, f.Platform)
+	for _, p := range f.Missing {
+		fmt.Fprintf(&b, "  - %s %s\n", p.Source, p.Version)
+	}
+	fmt.Fprintf(&b, "Fix: %s", f.Command)
+	return b.String()
+}
+
+// CheckPlatform reads the lock file in dir, identifies the installed
+// platforms, and reports the providers known to lack a hash for platform. It
+// returns nil and no error when dir has no lock file or nothing is missing.
+func CheckPlatform(dir, platform string) (*PlatformFinding, error) {
+	providers, err := ReadLockfile(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	IdentifyPlatforms(dir, providers)
+	missing := MissingPlatform(providers, platform)
+	if len(missing) == 0 {
+		return nil, nil
+	}
+	return &PlatformFinding{Platform: platform, Missing: missing, Command: LockCommand(providers, platform)}, nil
+}
+
+// LockCommand returns the terraform command that records hashes for
+// platform while keeping every platform already recorded for providers.
+func LockCommand(providers []LockedProvider, platform string) string {
+	platforms := []string{platform}
+	for _, p := range providers {
+		platforms = append(platforms, p.Platforms...)
+	}
+	slices.Sort(platforms)
+	platforms = slices.Compact(platforms)
+
+	var b strings.Builder
+	b.WriteString("terraform providers lock")
+	for _, pl := range platforms {
+		b.WriteString(" -platform=" + pl)
+	}
+	return b.String()
+}
+
+// RenderLockfile formats providers as a compact markdown list for the
+// model's workspace context.
+func RenderLockfile(providers []LockedProvider) string {
+	var b strings.Builder
+	for _, p := range providers {
+		fmt.Fprintf(&b, "- %s %s", p.Source, p.Version)
+		var details []string
+		if p.Constraints != "" {
+			details = append(details, "constraints "+p.Constraints)
+		}
+		if len(p.Platforms) > 0 {
+			details = append(details, "platforms "+strings.Join(p.Platforms, ", "))
+		}
+		if len(p.Unrecorded) > 0 {
+			details = append(details, "no hashes for installed "+strings.Join(p.Unrecorded, ", "))
+		}
+		details = append(details, fmt.Sprintf("%d hashes", len(p.Hashes)))
+		fmt.Fprintf(&b, " (%s)\n", strings.Join(details, "; "))
+	}
+	return b.String()
+}
+
+// stringAttr returns the literal string value of the named attribute, or ""
+// when it is absent.
+func stringAttr(body *hclsyntax.Body, name string) (string, error) {
+	attr, ok := body.Attributes[name]
+	if !ok {
+		return "", nil
+	}
+	v, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return "", fmt.Errorf("%s: %w", name, diags)
+	}
+	if v.IsNull() || !v.Type().Equals(cty.String) {
+		return "", fmt.Errorf("%s must be a string", name)
+	}
+	return v.AsString(), nil
+}
+
+// stringListAttr returns the literal string elements of the named list
+// attribute, or nil when it is absent.
+func stringListAttr(body *hclsyntax.Body, name string) ([]string, error) {
+	attr, ok := body.Attributes[name]
+	if !ok {
+		return nil, nil
+	}
+	v, diags := attr.Expr.Value(nil)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("%s: %w", name, diags)
+	}
+	if v.IsNull() || !(v.Type().IsTupleType() || v.Type().IsListType()) {
+		return nil, fmt.Errorf("%s must be a list of strings", name)
+	}
+	var out []string
+	for it := v.ElementIterator(); it.Next(); {
+		_, el := it.Element()
+		if el.IsNull() || !el.Type().Equals(cty.String) {
+			return nil, fmt.Errorf("%s must be a list of strings", name)
+		}
+		out = append(out, el.AsString())
+	}
+	return out, nil
+}
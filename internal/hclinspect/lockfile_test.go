@@ -0,0 +1,266 @@
+package hclinspect
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/sumdb/dirhash"
+
+	"github.com/54b3r/tfai-go/internal/testutil"
+)
+
+// installProvider writes a fake provider package for platform under
+// dir/.terraform/providers and returns its h1 hash.
+func installProvider(t *testing.T, dir, source, version, platform string) string {
+	t.Helper()
+	pkg := filepath.Join(dir, ".terraform", "providers", filepath.FromSlash(source), version, platform)
+	if err := os.MkdirAll(pkg, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pkg, "terraform-provider"), []byte(platform), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := dirhash.HashDir(pkg, "", dirhash.Hash1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+// writeLockfile writes a lock file for one provider with the given hashes.
+func writeLockfile(t *testing.T, dir, source, version string, hashes ...string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("provider \"" + source + "\" {\n  version = \"" + version + "\"\n  hashes = [\n")
+	for _, h := range hashes {
+		b.WriteString("    \"" + h + "\",\n")
+	}
+	b.WriteString("  ]\n}\n")
+	if err := os.WriteFile(filepath.Join(dir, LockfileName), []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ParseLockfile
+// ---------------------------------------------------------------------------
+
+func TestParseLockfile(t *testing.T) {
+	t.Parallel()
+
+	src, err := os.ReadFile("testdata/multi.lock.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseLockfile(src, "multi.lock.hcl")
+	if err != nil {
+		t.Fatalf("ParseLockfile: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 providers, got %d", len(got))
+	}
+	aws, random := got[0], got[1]
+	if aws.Source != "registry.terraform.io/hashicorp/aws" || aws.Version != "5.31.0" || aws.Constraints != "~> 5.0" || len(aws.Hashes) != 3 {
+		t.Errorf("unexpected aws entry: %+v", aws)
+	}
+	if random.Source != "registry.terraform.io/hashicorp/random" || random.Version != "3.6.0" || random.Constraints != "" || random.h1Count() != 2 {
+		t.Errorf("unexpected random entry: %+v", random)
+	}
+}
+
+func TestParseLockfile_Errors(t *testing.T) {
+	t.Parallel()
+
+	malformed, err := os.ReadFile("testdata/malformed.lock.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		src  string
+	}{
+		{name: "malformed", src: string(malformed)},
+		{name: "missing version", src: `provider "registry.terraform.io/hashicorp/aws" {}`},
+		{name: "no label", src: `provider { version = "1.0.0" }`},
+		{name: "hashes not a list", src: `provider "a/b/c" { version = "1.0.0"` + "\n" + `hashes = "h1:x" }`},
+		{name: "non-literal version", src: `provider "a/b/c" { version = var.v }`},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := ParseLockfile([]byte(tc.src), "test.lock.hcl"); err == nil || !strings.HasPrefix(err.Error(), "hclinspect: ") {
+				t.Errorf("expected an hclinspect error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReadLockfile(t *testing.T) {
+	t.Parallel()
+
+	got, err := ReadLockfile(testutil.Fixture(t, testutil.FixtureBasic).Dir())
+	if err != nil {
+		t.Fatalf("ReadLockfile: %v", err)
+	}
+	if len(got) != 1 || got[0].Source != "registry.terraform.io/hashicorp/aws" || got[0].Version != "5.31.0" {
+		t.Errorf("unexpected providers: %+v", got)
+	}
+}
+
+func TestReadLockfile_NotExist(t *testing.T) {
+	t.Parallel()
+
+	if _, err := ReadLockfile(t.TempDir()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// IdentifyPlatforms
+// ---------------------------------------------------------------------------
+
+func TestIdentifyPlatforms(t *testing.T) {
+	t.Parallel()
+
+	const source, version = "registry.terraform.io/hashicorp/aws", "5.31.0"
+	const zh = "zh:0cdb9c2083bf0902442384f7309367791e4640581652dda456f2d6d7abf0de8d"
+
+	tests := []struct {
+		name           string
+		setup          func(t *testing.T, dir string)
+		wantPlatforms  []string
+		wantUnrecorded []string
+		wantMissing    bool
+	}{
+		{
+			name: "host recorded",
+			setup: func(t *testing.T, dir string) {
+				writeLockfile(t, dir, source, version, installProvider(t, dir, source, version, "linux_amd64"), zh)
+			},
+			wantPlatforms: []string{"linux_amd64"},
+		},
+		{
+			name: "host installed without a hash",
+			setup: func(t *testing.T, dir string) {
+				installProvider(t, dir, source, version, "linux_amd64")
+				writeLockfile(t, dir, source, version, "h1:ltxyuBWIy9cq0kIKDJH1jeWJy/y7XJLjS4QrsQK4plA=", zh)
+			},
+			wantUnrecorded: []string{"linux_amd64"},
+			wantMissing:    true,
+		},
+		{
+			name: "only another platform recorded",
+			setup: func(t *testing.T, dir string) {
+				writeLockfile(t, dir, source, version, installProvider(t, dir, source, version, "darwin_arm64"), zh)
+			},
+			wantPlatforms: []string{"darwin_arm64"},
+			wantMissing:   true,
+		},
+		{
+			name: "unidentified h1 hash may be the host",
+			setup: func(t *testing.T, dir string) {
+				writeLockfile(t, dir, source, version, installProvider(t, dir, source, version, "darwin_arm64"), "h1:ltxyuBWIy9cq0kIKDJH1jeWJy/y7XJLjS4QrsQK4plA=")
+			},
+			wantPlatforms: []string{"darwin_arm64"},
+		},
+		{
+			name: "not initialised",
+			setup: func(t *testing.T, dir string) {
+				writeLockfile(t, dir, source, version, "h1:ltxyuBWIy9cq0kIKDJH1jeWJy/y7XJLjS4QrsQK4plA=")
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			tc.setup(t, dir)
+			got, err := ReadLockfile(dir)
+			if err != nil {
+				t.Fatalf("ReadLockfile: %v", err)
+			}
+			IdentifyPlatforms(dir, got)
+			if len(got) != 1 {
+				t.Fatalf("expected 1 provider, got %d", len(got))
+			}
+			p := got[0]
+			if !reflect.DeepEqual(p.Platforms, tc.wantPlatforms) || !reflect.DeepEqual(p.Unrecorded, tc.wantUnrecorded) {
+				t.Errorf("expected platforms %v unrecorded %v, got %v %v", tc.wantPlatforms, tc.wantUnrecorded, p.Platforms, p.Unrecorded)
+			}
+			missing := MissingPlatform(got, "linux_amd64")
+			if (len(missing) == 1) != tc.wantMissing {
+				t.Errorf("expected missing=%v for linux_amd64, got %v", tc.wantMissing, missing)
+			}
+		})
+	}
+}
+
+func TestLockCommand(t *testing.T) {
+	t.Parallel()
+
+	providers := []LockedProvider{
+		{Source: "a", Platforms: []string{"darwin_arm64"}},
+		{Source: "b", Platforms: []string{"darwin_arm64", "windows_amd64"}},
+	}
+	want := "terraform providers lock -platform=darwin_arm64 -platform=linux_arm64 -platform=windows_amd64"
+	if got := LockCommand(providers, "linux_arm64"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRenderLockfile(t *testing.T) {
+	t.Parallel()
+
+	got := RenderLockfile([]LockedProvider{{
+		Source:      "registry.terraform.io/hashicorp/aws",
+		Version:     "5.31.0",
+		Constraints: "~> 5.0",
+		Hashes:      []string{"h1:a", "zh:b"},
+		Platforms:   []string{"darwin_arm64"},
+		Unrecorded:  []string{"linux_amd64"},
+	}})
+	want := "- registry.terraform.io/hashicorp/aws 5.31.0 (constraints ~> 5.0; platforms darwin_arm64; no hashes for installed linux_amd64; 2 hashes)\n"
+	if got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestCheckPlatform(t *testing.T) {
+	t.Parallel()
+
+	const source, version = "registry.terraform.io/hashicorp/aws", "5.31.0"
+
+	dir := t.TempDir()
+	if f, err := CheckPlatform(dir, "linux_amd64"); f != nil || err != nil {
+		t.Errorf("expected nothing for a workspace without a lock file, got %v, %v", f, err)
+	}
+
+	writeLockfile(t, dir, source, version, installProvider(t, dir, source, version, "darwin_arm64"))
+	f, err := CheckPlatform(dir, "linux_arm64")
+	if err != nil {
+		t.Fatalf("CheckPlatform: %v", err)
+	}
+	want := ".terraform.lock.hcl has no hashes for linux_arm64 for these providers:\n" +
+		"  - registry.terraform.io/hashicorp/aws 5.31.0\n" +
+		"Fix: terraform providers lock -platform=darwin_arm64 -platform=linux_arm64"
+	if f == nil || f.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%v", want, f)
+	}
+	if f, err := CheckPlatform(dir, "darwin_arm64"); f != nil || err != nil {
+		t.Errorf("expected nothing for a recorded platform, got %v, %v", f, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, LockfileName), []byte(`provider "x" {`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CheckPlatform(dir, "linux_arm64"); err == nil {
+		t.Error("expected an error for a malformed lock file")
+	}
+}
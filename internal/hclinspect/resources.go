@@ -0,0 +1,78 @@
+package hclinspect
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Resource is a resource or data block declared in a Terraform file.
+type Resource struct {
+	// Address is the block's root module address.
+	Address ResourceAddress
+	// Arguments lists the attributes and nested blocks set in the block,
+	// sorted. Those inside a nested block are joined to its type with a
+	// dot, e.g. "versioning_configuration" and
+	// "versioning_configuration.status"; dynamic blocks count as the block
+	// they generate.
+	Arguments []string
+}
+
+// HasArgument reports whether r sets the attribute or nested block named by
+// the dotted path arg.
+func (r Resource) HasArgument(arg string) bool {
+	_, found := slices.BinarySearch(r.Arguments, arg)
+	return found
+}
+
+// ParseResources returns the resource and data blocks of the Terraform
+// file src, in declaration order. filename is used in error messages.
+func ParseResources(src []byte, filename string) ([]Resource, error) {
+	file, diags := hclsyntax.ParseConfig(src, filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("hclinspect: failed to parse %s: %w", filename, diags)
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, nil
+	}
+	var out []Resource
+	for _, block := range body.Blocks {
+		if (block.Type != "resource" && block.Type != "data") || len(block.Labels) != 2 {
+			continue
+		}
+		args := blockArguments(block.Body, "")
+		slices.Sort(args)
+		out = append(out, Resource{
+			Address:   ResourceAddress{Data: block.Type == "data", Type: block.Labels[0], Name: block.Labels[1]},
+			Arguments: slices.Compact(args),
+		})
+	}
+	return out, nil
+}
+
+// blockArguments returns the dotted paths of the attributes and nested
+// blocks of body, each prefixed with prefix.
+func blockArguments(body *hclsyntax.Body, prefix string) []string {
+	var args []string
+	for name := range body.Attributes {
+		args = append(args, prefix+name)
+	}
+	for _, block := range body.Blocks {
+		inner := block.Body
+		name := block.Type
+		if block.Type == "dynamic" && len(block.Labels) == 1 {
+			name = block.Labels[0]
+			for _, b := range block.Body.Blocks {
+				if b.Type == "content" {
+					inner = b.Body
+				}
+			}
+		}
+		args = append(args, prefix+name)
+		args = append(args, blockArguments(inner, prefix+name+".")...)
+	}
+	return args
+}
@@ -0,0 +1,68 @@
+package hclinspect
+
+import (
+	"slices"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// ParseResources
+// ---------------------------------------------------------------------------
+
+func TestParseResources(t *testing.T) {
+	t.Parallel()
+
+	src := `variable "name" {
+  type = string
+}
+
+resource "aws_s3_bucket" "this" {
+  bucket = var.name
+  tags   = {}
+}
+
+resource "aws_s3_bucket_versioning" "this" {
+  bucket = aws_s3_bucket.this.id
+
+  versioning_configuration {
+    status = "Enabled"
+  }
+}
+
+data "aws_iam_policy_document" "read" {
+  dynamic "statement" {
+    for_each = var.readers
+    content {
+      actions = ["s3:GetObject"]
+    }
+  }
+}
+`
+	got, err := ParseResources([]byte(src), "main.tf")
+	if err != nil {
+		t.Fatalf("ParseResources: %v", err)
+	}
+	want := []struct {
+		address string
+		args    []string
+	}{
+		{address: "aws_s3_bucket.this", args: []string{"bucket", "tags"}},
+		{address: "aws_s3_bucket_versioning.this", args: []string{"bucket", "versioning_configuration", "versioning_configuration.status"}},
+		{address: "data.aws_iam_policy_document.read", args: []string{"statement", "statement.actions"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d resources, got %+v", len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Address.String() != w.address || !slices.Equal(got[i].Arguments, w.args) {
+			t.Errorf("resource %d: expected %s %v, got %s %v", i, w.address, w.args, got[i].Address, got[i].Arguments)
+		}
+	}
+	if !got[1].HasArgument("versioning_configuration.status") || got[1].HasArgument("status") {
+		t.Errorf("expected HasArgument to match dotted paths only, got %v", got[1].Arguments)
+	}
+
+	if _, err := ParseResources([]byte("resource \"x\" {\n"), "broken.tf"); err == nil {
+		t.Error("expected an error for invalid HCL")
+	}
+}
@@ -0,0 +1,230 @@
+// Package httpx builds the *http.Client every outbound tfai call goes
+// through: embedders, model health checks, documentation fetches, and the Go
+// client. Each client takes the proxy from the environment, trusts the extra
+// CA bundle named by TFAI_CA_BUNDLE, sends a tfai User-Agent, and records
+// each request in tfai_outbound_requests_total and
+// tfai_outbound_request_duration_seconds, labelled by the component that
+// made it and the destination host.
+//
+// Constructing an http.Client directly skips all of that, so a guard test
+// fails on any http.Client literal outside this package.
+package httpx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/54b3r/tfai-go/internal/version"
+)
+
+// EnvCABundle names the PEM file of extra CA certificates to trust on top
+// of the system pool, for egress through a TLS-intercepting proxy.
+const EnvCABundle = "TFAI_CA_BUNDLE"
+
+// DefaultMaxIdleConnsPerHost is the idle connections kept per host when
+// Options.MaxIdleConnsPerHost is zero. net/http's default of 2 makes
+// concurrent embedding batches and page fetches reconnect constantly.
+const DefaultMaxIdleConnsPerHost = 16
+
+// DefaultUserAgent is the User-Agent sent when neither Options.UserAgent nor
+// the request sets one.
+var DefaultUserAgent = "tfai-go/" + version.Version
+
+// Options configures NewClient. The zero value is a client without a
+// timeout, labelled component "unknown".
+type Options struct {
+	// Component labels the client's metrics, e.g. "embedder" or
+	// "ingestion". Defaults to "unknown".
+	Component string
+	// Timeout is the client's overall per-request timeout. Zero means no
+	// limit, for callers that bound requests with a context instead.
+	Timeout time.Duration
+	// UserAgent is set on requests that carry none. Defaults to
+	// DefaultUserAgent.
+	UserAgent string
+	// CABundle is a PEM file of extra CA certificates to trust. Defaults to
+	// $TFAI_CA_BUNDLE; when neither is set only the system pool is trusted.
+	CABundle string
+	// MaxIdleConnsPerHost caps the idle connections kept per host. Defaults
+	// to DefaultMaxIdleConnsPerHost.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost caps all connections per host. Zero means no limit.
+	MaxConnsPerHost int
+	// Wrap, when set, wraps the network transport before instrumentation,
+	// so a guard that refuses a request, such as an offline mode, is still
+	// counted in the metrics.
+	Wrap func(http.RoundTripper) http.RoundTripper
+	// Metrics receives the request metrics. Defaults to metrics registered
+	// once against prometheus.DefaultRegisterer.
+	Metrics *Metrics
+}
+
+// NewClient returns an *http.Client configured by opts. When the CA bundle
+// cannot be loaded the client still constructs, but every request it sends
+// fails with the load error, so a misconfigured bundle surfaces on first use
+// rather than changing every constructor that makes a client.
+func NewClient(opts Options) *http.Client {
+	if opts.Component == "" {
+		opts.Component = "unknown"
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	if opts.CABundle == "" {
+		opts.CABundle = os.Getenv(EnvCABundle)
+	}
+	if opts.MaxIdleConnsPerHost <= 0 {
+		opts.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = defaultMetrics()
+	}
+
+	var rt http.RoundTripper
+	t, err := newTransport(opts)
+	if err != nil {
+		rt = failingTransport{err: err}
+	} else {
+		rt = t
+	}
+	if opts.Wrap != nil {
+		rt = opts.Wrap(rt)
+	}
+	return &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &instrumentedTransport{
+			next:      rt,
+			component: opts.Component,
+			userAgent: opts.UserAgent,
+			metrics:   opts.Metrics,
+		},
+	}
+}
+
+// newTransport clones net/http's default transport, which already takes
+// the proxy from HTTPS_PROXY, HTTP_PROXY, and NO_PROXY, and applies the
+// pool limits and CA bundle of opts.
+func newTransport(opts Options) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	t.MaxConnsPerHost = opts.MaxConnsPerHost
+	if opts.CABundle != "" {
+		pool, err := loadCABundle(opts.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		t.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return t, nil
+}
+
+// loadCABundle returns the system pool with the certificates of the PEM
+// file at path added.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("httpx: failed to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("httpx: CA bundle %s holds no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// failingTransport fails every request with err.
+type failingTransport struct {
+	err error
+}
+
+// RoundTrip implements http.RoundTripper.
+func (f failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return nil, f.err
+}
+
+// instrumentedTransport sets the default User-Agent and records each
+// request's outcome and latency.
+type instrumentedTransport struct {
+	next      http.RoundTripper
+	component string
+	userAgent string
+	metrics   *Metrics
+}
+
+// RoundTrip implements http.RoundTripper. The request is cloned before its
+// header is changed, as the RoundTripper contract requires.
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	host := req.URL.Host
+	t.metrics.requestsTotal.WithLabelValues(t.component, host, code).Inc()
+	t.metrics.durationSeconds.WithLabelValues(t.component, host).Observe(time.Since(start).Seconds())
+	return resp, err //nolint:wrapcheck // a RoundTripper returns its transport's errors as-is
+}
+
+// Metrics holds the outbound request metrics.
+type Metrics struct {
+	// requestsTotal counts outbound requests, partitioned by component,
+	// host, and code: the response status, or "error" when none arrived.
+	requestsTotal *prometheus.CounterVec
+	// durationSeconds records the latency of outbound requests up to the
+	// response headers, partitioned by component and host.
+	durationSeconds *prometheus.HistogramVec
+}
+
+// NewMetrics registers the outbound request metrics against reg. When reg
+// is nil a private registry is used, so the metrics are recorded but never
+// exported.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	if reg == nil {
+		reg = prometheus.NewRegistry()
+	}
+	factory := promauto.With(reg)
+
+	return &Metrics{
+		requestsTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tfai",
+			Subsystem: "outbound",
+			Name:      "requests_total",
+			Help:      "Total number of outbound HTTP requests, partitioned by component, destination host, and status code.",
+		}, []string{"component", "host", "code"}),
+		durationSeconds: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "tfai",
+			Subsystem: "outbound",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of outbound HTTP requests up to the response headers, partitioned by component and destination host.",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		}, []string{"component", "host"}),
+	}
+}
+
+// defaultMetrics returns the metrics registered against
+// prometheus.DefaultRegisterer, registering them on first use so that
+// importing the package registers nothing.
+var defaultMetrics = sync.OnceValue(func() *Metrics {
+	return NewMetrics(prometheus.DefaultRegisterer)
+})
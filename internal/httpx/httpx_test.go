@@ -0,0 +1,218 @@
+package httpx
+
+import (
+	"encoding/pem"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewClient_Options(t *testing.T) {
+	t.Parallel()
+
+	c := NewClient(Options{Component: "test", Timeout: 7 * time.Second, MaxConnsPerHost: 3, Metrics: NewMetrics(nil)})
+	if c.Timeout != 7*time.Second {
+		t.Errorf("expected the timeout applied, got %v", c.Timeout)
+	}
+	it, ok := c.Transport.(*instrumentedTransport)
+	if !ok {
+		t.Fatalf("expected an instrumented transport, got %T", c.Transport)
+	}
+	if it.component != "test" || it.userAgent != DefaultUserAgent {
+		t.Errorf("expected component test and the default User-Agent, got %q and %q", it.component, it.userAgent)
+	}
+	tr, ok := it.next.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected an *http.Transport underneath, got %T", it.next)
+	}
+	if tr.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost || tr.MaxConnsPerHost != 3 {
+		t.Errorf("expected the pool limits applied, got idle %d, max %d", tr.MaxIdleConnsPerHost, tr.MaxConnsPerHost)
+	}
+	if tr.Proxy == nil {
+		t.Error("expected the proxy taken from the environment")
+	}
+	if tr.TLSClientConfig != nil && tr.TLSClientConfig.RootCAs != nil {
+		t.Error("expected only the system pool without a CA bundle")
+	}
+
+	// Wrap sits under the instrumentation.
+	wrapped := NewClient(Options{Metrics: NewMetrics(nil), Wrap: func(next http.RoundTripper) http.RoundTripper {
+		return failingTransport{err: errors.New("offline")}
+	}})
+	if _, ok := wrapped.Transport.(*instrumentedTransport).next.(failingTransport); !ok {
+		t.Errorf("expected Wrap to replace the network transport, got %T", wrapped.Transport.(*instrumentedTransport).next)
+	}
+	if wrapped.Transport.(*instrumentedTransport).component != "unknown" {
+		t.Error("expected the component to default to unknown")
+	}
+}
+
+func TestNewClient_CABundle(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	ts.Config.ErrorLog = log.New(io.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	// Without the server's certificate the handshake fails.
+	if resp, err := NewClient(Options{Metrics: NewMetrics(nil)}).Get(ts.URL); err == nil {
+		_ = resp.Body.Close()
+		t.Error("expected an untrusted certificate to fail")
+	}
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := NewClient(Options{CABundle: bundle, Metrics: NewMetrics(nil)}).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("expected the bundle's certificate to be trusted, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	// A bundle that cannot be loaded fails each request with the reason.
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewClient(Options{CABundle: empty, Metrics: NewMetrics(nil)}).Get(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "holds no PEM certificates") {
+		t.Errorf("expected the bundle error on request, got %v", err)
+	}
+	if _, err := loadCABundle(filepath.Join(t.TempDir(), "missing.pem")); err == nil || !strings.Contains(err.Error(), "failed to read CA bundle") {
+		t.Errorf("expected a missing bundle to fail, got %v", err)
+	}
+}
+
+func TestNewClient_Metrics(t *testing.T) {
+	t.Parallel()
+
+	var gotUA []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = append(gotUA, r.UserAgent())
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	host := strings.TrimPrefix(ts.URL, "http://")
+
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg)
+	c := NewClient(Options{Component: "embedder", Metrics: m})
+	for _, path := range []string{"/", "/", "/missing"} {
+		resp, err := c.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+	}
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("User-Agent", "custom/1.0")
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if req.Header.Get("User-Agent") != "custom/1.0" {
+		t.Error("expected the caller's request left unchanged")
+	}
+
+	// A request that never gets a response is counted as an error.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL, _ := url.Parse(closed.URL)
+	closed.Close()
+	if _, err := c.Get(closed.URL); err == nil {
+		t.Fatal("expected a closed server to fail")
+	}
+
+	if got := testutil.ToFloat64(m.requestsTotal.WithLabelValues("embedder", host, "200")); got != 3 {
+		t.Errorf("expected 3 requests with code 200, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.requestsTotal.WithLabelValues("embedder", host, "404")); got != 1 {
+		t.Errorf("expected 1 request with code 404, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.requestsTotal.WithLabelValues("embedder", closedURL.Host, "error")); got != 1 {
+		t.Errorf("expected 1 failed request, got %v", got)
+	}
+	if n := testutil.CollectAndCount(m.durationSeconds); n != 2 {
+		t.Errorf("expected latency series for both hosts, got %d", n)
+	}
+	want := []string{DefaultUserAgent, DefaultUserAgent, DefaultUserAgent, "custom/1.0"}
+	if strings.Join(gotUA, ",") != strings.Join(want, ",") {
+		t.Errorf("expected User-Agents %v, got %v", want, gotUA)
+	}
+}
+
+// TestNoClientLiterals fails on any http.Client or http.Transport literal
+// in non-test code outside this package: such a client skips the proxy, CA
+// bundle, User-Agent, and metrics NewClient applies. The public packages
+// under pkg are exempt: importing them must not register tfai's metrics or
+// read its environment.
+func TestNoClientLiterals(t *testing.T) {
+	t.Parallel()
+
+	root := filepath.Join("..", "..")
+	self, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			if abs == self || (path != root && strings.HasPrefix(d.Name(), ".")) || d.Name() == "testdata" || path == filepath.Join(root, "pkg") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.CompositeLit)
+			if !ok {
+				return true
+			}
+			sel, ok := lit.Type.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "http" && (sel.Sel.Name == "Client" || sel.Sel.Name == "Transport") {
+				t.Errorf("%s: http.%s literal; use httpx.NewClient", fset.Position(lit.Pos()), sel.Sel.Name)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
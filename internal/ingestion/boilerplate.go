@@ -0,0 +1,298 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DefaultBoilerplatePercent is the share of a run's pages a block of lines
+// must appear on to be stripped as boilerplate when
+// Config.BoilerplatePercent is zero.
+const DefaultBoilerplatePercent = 50
+
+// Boilerplate detection parameters.
+const (
+	// shingleLines is the number of consecutive lines hashed together. A
+	// line is boilerplate when a shingle covering it repeats across pages:
+	// next to another repeated line or at the start or end of the page, but
+	// not merely because it is common.
+	shingleLines = 2
+	// boilerplateMinPages is the number of pages a run must have fetched
+	// before any block is judged repeated. It matches DefaultConcurrency, so
+	// with the default settings the warm-up pages are judged too.
+	boilerplateMinPages = 4
+	// boilerplateWarmup is the most pages fetched before the first is
+	// ingested, so the first pages of a run are judged against more than
+	// themselves.
+	boilerplateWarmup = 8
+	// sketchDepth and sketchWidth size the count-min sketch of shingle page
+	// counts: 4 × 16384 counters, 256 KiB however large the crawl.
+	sketchDepth = 4
+	sketchWidth = 1 << 14
+)
+
+// Markers standing in for the start and end of a page and for a code block
+// in the shingle sequence. They are hashed but never stripped.
+const (
+	markPageStart = "\x00start"
+	markPageEnd   = "\x00end"
+	markCode      = "\x00code"
+)
+
+// staticBoilerplate matches whole lines of navigation, cookie banner, and
+// footer text common to documentation sites. It is applied to every page,
+// including single-URL ingestion. Lines are matched after normalizeLine.
+var staticBoilerplate = regexp.MustCompile(`^(` + strings.Join([]string{
+	`skip to (main )?content`,
+	`(accept|reject|allow|deny|manage)( all)? cookies`,
+	`cookie (settings|preferences|policy|consent)`,
+	`(this|our) (site|website) uses cookies\b.{0,200}`,
+	`we use cookies\b.{0,200}`,
+	`by (continuing to use|using) (this|our) (site|website)\b.{0,200}`,
+	`on this page`,
+	`edit this page( on github)?`,
+	`was this (page|article) helpful\??( yes no)?`,
+	`(back|return) to top`,
+	`(copyright )?(©|\(c\)|copyright) ?\d{4}\b.{0,200}`,
+	`privacy( policy)?`,
+	`terms( of (use|service))?`,
+}, "|") + `)$`)
+
+// reMarkdownLink matches a markdown link and captures its text.
+var reMarkdownLink = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+
+// normalizeLine prepares a line for hashing and static matching: list
+// markers, link targets, case, and spacing are dropped.
+func normalizeLine(line string) string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimLeft(line, "-*+ ")
+	line = reMarkdownLink.ReplaceAllString(line, "$1")
+	return strings.ToLower(strings.Join(strings.Fields(line), " "))
+}
+
+// pageLine is one element of a page's shingle sequence.
+type pageLine struct {
+	// index is the line's position in the page, or -1 for a marker.
+	index int
+	// text is the normalized line, or a marker.
+	text string
+}
+
+// pageSequence returns the lines of a page that take part in boilerplate
+// detection, in order, between start and end markers. Blank lines are
+// skipped and each fenced code block is one markCode element.
+func pageSequence(lines []string) []pageLine {
+	seq := []pageLine{{index: -1, text: markPageStart}}
+	inFence := false
+	for i, line := range lines {
+		switch {
+		case isFence(line):
+			if !inFence {
+				seq = append(seq, pageLine{index: -1, text: markCode})
+			}
+			inFence = !inFence
+		case inFence, strings.TrimSpace(line) == "":
+		default:
+			seq = append(seq, pageLine{index: i, text: normalizeLine(line)})
+		}
+	}
+	return append(seq, pageLine{index: -1, text: markPageEnd})
+}
+
+// shingleHashes returns the hash of each window of shingleLines
+// consecutive elements of seq; a shorter seq is a single window.
+func shingleHashes(seq []pageLine) []uint64 {
+	n := max(len(seq)-shingleLines+1, 1)
+	hashes := make([]uint64, n)
+	for k := range hashes {
+		h := fnv.New64a()
+		for _, l := range seq[k:min(k+shingleLines, len(seq))] {
+			_, _ = h.Write([]byte(l.text))
+			_, _ = h.Write([]byte{'\n'})
+		}
+		hashes[k] = h.Sum64()
+	}
+	return hashes
+}
+
+// boilerplateDetector estimates, in fixed memory, how many of a run's pages
+// each shingle appears on. It is safe for concurrent use.
+type boilerplateDetector struct {
+	// percent is the share of pages above which a shingle is boilerplate.
+	percent int
+
+	// mu guards the fields below.
+	mu sync.Mutex
+	// sketch is a count-min sketch of per-shingle page counts. Estimates
+	// can only err high, which at worst strips a block that repeats on
+	// slightly fewer pages than percent.
+	sketch [sketchDepth][sketchWidth]uint32
+	// pages is the number of pages observed.
+	pages int
+}
+
+// newBoilerplateDetector returns a detector for shingles on more than
+// percent of the pages.
+func newBoilerplateDetector(percent int) *boilerplateDetector {
+	return &boilerplateDetector{percent: percent}
+}
+
+// cells returns the counter of h in each row of the sketch.
+func cells(h uint64) [sketchDepth]uint32 {
+	var out [sketchDepth]uint32
+	h1, h2 := uint32(h), uint32(h>>32)|1
+	for i := range out {
+		out[i] = (h1 + uint32(i)*h2) % sketchWidth
+	}
+	return out
+}
+
+// observe counts each distinct shingle of a page once.
+func (d *boilerplateDetector) observe(hashes []uint64) {
+	seen := make(map[uint64]bool, len(hashes))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pages++
+	for _, h := range hashes {
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		for row, col := range cells(h) {
+			d.sketch[row][col]++
+		}
+	}
+}
+
+// repeated returns, for each of hashes, whether it appears on more than
+// percent of the pages observed so far. Nothing is repeated before
+// boilerplateMinPages pages.
+func (d *boilerplateDetector) repeated(hashes []uint64) []bool {
+	out := make([]bool, len(hashes))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.pages < boilerplateMinPages {
+		return out
+	}
+	for i, h := range hashes {
+		est := uint32(0)
+		for row, col := range cells(h) {
+			if c := d.sketch[row][col]; row == 0 || c < est {
+				est = c
+			}
+		}
+		out[i] = int(est)*100 > d.percent*d.pages
+	}
+	return out
+}
+
+// hygiene strips boilerplate from the pages of one Ingest or Run call. For
+// a run of several pages it learns the blocks they repeat; a single page
+// only gets the static list.
+type hygiene struct {
+	// detector is nil for a single page or when detection is disabled.
+	detector *boilerplateDetector
+
+	// warm is closed once the first warmup pages have been fetched, so
+	// they are judged against each other rather than only themselves.
+	warm chan struct{}
+	// mu guards arrived.
+	mu sync.Mutex
+	// warmup is the number of pages that close warm.
+	warmup int
+	// arrived is the number of fetches finished, failed ones included.
+	arrived int
+}
+
+// newHygiene returns the hygiene for a run that will fetch urls. With
+// several urls, the first pages wait in fetchPage until the first
+// boilerplateWarmup of them, or fewer with less concurrency, have arrived.
+// Waiting for more than Config.Concurrency would deadlock the workers.
+func (p *Pipeline) newHygiene(urls []string) *hygiene {
+	h := &hygiene{warm: make(chan struct{})}
+	if len(urls) < 2 || p.cfg.BoilerplatePercent < 0 {
+		close(h.warm)
+		return h
+	}
+	h.detector = newBoilerplateDetector(p.cfg.BoilerplatePercent)
+	h.warmup = min(len(urls), boilerplateWarmup, p.cfg.Concurrency)
+	return h
+}
+
+// arrive records a finished fetch.
+func (h *hygiene) arrive() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.arrived++
+	if h.arrived == h.warmup {
+		close(h.warm)
+	}
+}
+
+// fetchPage fetches url and adds it to the detector, if any. During the
+// warm-up a successful fetch returns only once the warm-up is over or ctx
+// is done.
+func (p *Pipeline) fetchPage(ctx context.Context, h *hygiene, url string, progress func(string)) (string, error) {
+	progress(fmt.Sprintf("fetching %s", url))
+	content, err := p.fetch(ctx, url)
+	if h.detector == nil {
+		return content, err
+	}
+	if err == nil {
+		h.detector.observe(shingleHashes(pageSequence(strings.Split(content, "\n"))))
+	}
+	h.arrive()
+	if err != nil {
+		return "", err
+	}
+	select {
+	case <-h.warm:
+		return content, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// clean returns content with its boilerplate lines, and the blank lines they
+// leave, removed, and the number of bytes removed. A line is removed when it
+// matches staticBoilerplate or a repeated shingle covers it. Headings and
+// code blocks are always kept, so sections and examples survive whatever the
+// pages share.
+func (h *hygiene) clean(content string) (string, int) {
+	lines := strings.Split(content, "\n")
+	seq := pageSequence(lines)
+	drop := make(map[int]bool)
+	for _, l := range seq {
+		if l.index >= 0 && staticBoilerplate.MatchString(l.text) {
+			drop[l.index] = true
+		}
+	}
+	if h.detector != nil {
+		for k, rep := range h.detector.repeated(shingleHashes(seq)) {
+			if !rep {
+				continue
+			}
+			for _, l := range seq[k:min(k+shingleLines, len(seq))] {
+				if l.index >= 0 {
+					drop[l.index] = true
+				}
+			}
+		}
+	}
+	if len(drop) == 0 {
+		return content, 0
+	}
+
+	kept := make([]string, 0, len(lines)-len(drop))
+	for i, line := range lines {
+		if !drop[i] || reHeading.MatchString(line) {
+			kept = append(kept, line)
+		}
+	}
+	cleaned := strings.TrimSpace(reBlankLines.ReplaceAllString(strings.Join(kept, "\n"), "\n\n"))
+	return cleaned, len(content) - len(cleaned)
+}
@@ -0,0 +1,177 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// navBlock is the navigation every page of docSite shares.
+const navBlock = `- [Home](/)
+- [Providers](/providers)
+- [Modules](/modules)
+- [Policy Libraries](/policies)
+
+Search the Terraform Registry`
+
+// docSite serves /doc/<n>: the shared navBlock, a cookie banner, a page
+// specific body with a heading and an example, and a shared footer line.
+func docSite() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := strings.TrimPrefix(r.URL.Path, "/doc/")
+		_, _ = fmt.Fprintf(w, `%s
+
+We use cookies to improve your experience.
+
+# aws_resource_%s
+
+## Example Usage
+
+`+"```hcl\nresource \"aws_resource_%s\" \"example\" {}\n```"+`
+
+Resource %s manages widget number %s in your account.
+
+Lifecycle notes unique to resource %s.
+
+Theme: Light Dark`, navBlock, n, n, n, n, n)
+	})
+}
+
+// ingestDocs ingests /doc/1 … /doc/n of srv and returns the stored content
+// per source and the progress messages.
+func ingestDocs(t *testing.T, srv *httptest.Server, n int) (map[string]string, []string) {
+	t.Helper()
+	store := &fakeStore{}
+	p, err := NewPipeline(fakeEmbedder{}, store, &Config{ChunkSize: 4000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := make([]Source, n)
+	for i := range sources {
+		sources[i] = Source{URL: fmt.Sprintf("%s/doc/%d", srv.URL, i+1)}
+	}
+	var (
+		mu       sync.Mutex
+		messages []string
+	)
+	if err := p.Ingest(context.Background(), sources, func(msg string) {
+		mu.Lock()
+		messages = append(messages, msg)
+		mu.Unlock()
+	}); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	content := map[string]string{}
+	for _, d := range store.docs {
+		content[strings.TrimPrefix(d.Source, srv.URL)] += d.Content + "\n\n"
+	}
+	return content, messages
+}
+
+func TestIngest_StripsCrawlBoilerplate(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(docSite())
+	defer srv.Close()
+
+	content, messages := ingestDocs(t, srv, 12)
+	if len(content) != 12 {
+		t.Fatalf("expected 12 pages stored, got %d", len(content))
+	}
+	for i := 1; i <= 12; i++ {
+		page := content[fmt.Sprintf("/doc/%d", i)]
+		for _, gone := range []string{"Providers", "Policy Libraries", "Search the Terraform Registry", "We use cookies", "Theme: Light Dark"} {
+			if strings.Contains(page, gone) {
+				t.Errorf("/doc/%d: expected %q stripped, got:\n%s", i, gone, page)
+			}
+		}
+		for _, kept := range []string{
+			fmt.Sprintf("# aws_resource_%d", i),
+			"## Example Usage",
+			fmt.Sprintf(`resource "aws_resource_%d" "example" {}`, i),
+			fmt.Sprintf("Resource %d manages widget number %d in your account.", i, i),
+			fmt.Sprintf("Lifecycle notes unique to resource %d.", i),
+		} {
+			if !strings.Contains(page, kept) {
+				t.Errorf("/doc/%d: expected %q kept, got:\n%s", i, kept, page)
+			}
+		}
+	}
+
+	reStripped := regexp.MustCompile(`^stripped (\d+) bytes of boilerplate from .*/doc/\d+$`)
+	stripped := 0
+	for _, msg := range messages {
+		if reStripped.MatchString(msg) {
+			stripped++
+		}
+	}
+	if stripped != 12 {
+		t.Errorf("expected a stripped-bytes message per source, got %d in %v", stripped, messages)
+	}
+}
+
+func TestIngest_SinglePageStaticOnly(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(docSite())
+	defer srv.Close()
+
+	content, messages := ingestDocs(t, srv, 1)
+	page := content["/doc/1"]
+	if strings.Contains(page, "We use cookies") {
+		t.Errorf("expected the cookie banner stripped by the static list, got:\n%s", page)
+	}
+	if !strings.Contains(page, "Policy Libraries") || !strings.Contains(page, "Theme: Light Dark") {
+		t.Errorf("expected a single page to keep text only repetition would flag, got:\n%s", page)
+	}
+	// The line and the blank line after it.
+	want := fmt.Sprintf("stripped %d bytes of boilerplate from %s/doc/1", len("We use cookies to improve your experience.\n\n"), srv.URL)
+	found := false
+	for _, msg := range messages {
+		found = found || msg == want
+	}
+	if !found {
+		t.Errorf("expected %q, got %v", want, messages)
+	}
+}
+
+func TestHygiene_Clean(t *testing.T) {
+	t.Parallel()
+
+	// Below boilerplateMinPages nothing is judged repeated.
+	d := newBoilerplateDetector(DefaultBoilerplatePercent)
+	h := &hygiene{detector: d}
+	page := func(body string) string {
+		return "Skip to main content\n\n[Docs](/docs)\n[Blog](/blog)\n[Pricing](/pricing)\n\n" + body + "\n\n© 2024 Example, Inc."
+	}
+	observe := func(content string) {
+		d.observe(shingleHashes(pageSequence(strings.Split(content, "\n"))))
+	}
+	for i := range boilerplateMinPages - 1 {
+		observe(page(fmt.Sprintf("body %d", i)))
+	}
+	got, _ := h.clean(page("body 0"))
+	if got != "[Docs](/docs)\n[Blog](/blog)\n[Pricing](/pricing)\n\nbody 0" {
+		t.Errorf("expected only static boilerplate stripped before the minimum, got %q", got)
+	}
+
+	observe(page("body x"))
+	got, removed := h.clean(page("body 0"))
+	if got != "body 0" || removed != len(page("body 0"))-len("body 0") {
+		t.Errorf("expected the shared links stripped, got %q (%d bytes removed)", got, removed)
+	}
+
+	// A page that only shares a heading and a code block keeps them.
+	shared := "## Argument Reference\n\n```hcl\nterraform {}\n```"
+	for range 10 {
+		observe(shared)
+	}
+	if got, removed := h.clean(shared); got != shared || removed != 0 {
+		t.Errorf("expected headings and code blocks kept, got %q (%d bytes removed)", got, removed)
+	}
+}
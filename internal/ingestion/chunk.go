@@ -0,0 +1,255 @@
+package ingestion
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Chunking strategies accepted by Config.ChunkStrategy.
+const (
+	// ChunkStrategyHeading splits pages at markdown or HTML headings, then
+	// at paragraph boundaries, and cuts text mid-paragraph only when a single
+	// paragraph exceeds ChunkSize. Code blocks are never split at a blank
+	// line. Each chunk records its nearest heading as its section.
+	ChunkStrategyHeading = "heading"
+	// ChunkStrategyFixed cuts pages every ChunkSize characters, overlapping
+	// by ChunkOverlap, regardless of their structure.
+	ChunkStrategyFixed = "fixed"
+)
+
+// chunk is one piece of a page, ready to embed.
+type chunk struct {
+	// text is the chunk content.
+	text string
+	// section is the nearest heading above the chunk; empty before the
+	// first heading and for fixed-size chunks.
+	section string
+}
+
+// chunk splits text according to cfg.ChunkStrategy.
+func (p *Pipeline) chunk(text string) []chunk {
+	text = strings.TrimSpace(text)
+	if len(text) == 0 {
+		return nil
+	}
+	if p.cfg.ChunkStrategy == ChunkStrategyFixed {
+		var chunks []chunk
+		for _, c := range cutFixed(text, p.cfg.ChunkSize, p.cfg.ChunkOverlap) {
+			chunks = append(chunks, chunk{text: c})
+		}
+		return chunks
+	}
+	return chunkByHeading(text, p.cfg.ChunkSize, p.cfg.ChunkOverlap)
+}
+
+// cutFixed splits text into chunks of size characters overlapping by
+// overlap.
+func cutFixed(text string, size, overlap int) []string {
+	var chunks []string
+	for start := 0; start < len(text); start += size - overlap {
+		end := min(start+size, len(text))
+		chunks = append(chunks, text[start:end])
+		if end == len(text) {
+			break
+		}
+	}
+	return chunks
+}
+
+// reHeading matches a markdown ATX heading line and captures its text.
+var reHeading = regexp.MustCompile(`^#{1,6}[ \t]+(.*?)[ \t#]*$`)
+
+// isFence reports whether line opens or closes a fenced code block.
+func isFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// section is the text under one heading, heading line included.
+type section struct {
+	// title is the heading text; empty for text before the first heading.
+	title string
+	// paragraphs are the blank-line separated blocks of the section. A
+	// fenced code block is always a single paragraph.
+	paragraphs []string
+}
+
+// splitSections splits markdown text into sections at headings and each
+// section into paragraphs. Lines inside fenced code blocks are never
+// treated as headings or paragraph breaks.
+func splitSections(text string) []section {
+	sections := []section{{}}
+	var para []string
+	inFence := false
+	flush := func() {
+		if p := strings.TrimSpace(strings.Join(para, "\n")); p != "" {
+			cur := &sections[len(sections)-1]
+			cur.paragraphs = append(cur.paragraphs, p)
+		}
+		para = para[:0]
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case isFence(line):
+			inFence = !inFence
+			para = append(para, line)
+		case inFence:
+			para = append(para, line)
+		case strings.TrimSpace(line) == "":
+			flush()
+		default:
+			if m := reHeading.FindStringSubmatch(line); m != nil {
+				flush()
+				sections = append(sections, section{title: m[1]})
+			}
+			para = append(para, line)
+		}
+	}
+	flush()
+
+	// A heading directly followed by a subheading ("## Example Usage" then
+	// "### With Versioning Enabled") would be a chunk of its own; move it
+	// into the subsection instead.
+	out := sections[:0]
+	var carry []string
+	for i, s := range sections {
+		s.paragraphs = append(carry, s.paragraphs...)
+		carry = nil
+		if s.title != "" && len(s.paragraphs) == 1 && i < len(sections)-1 {
+			carry = s.paragraphs
+			continue
+		}
+		if len(s.paragraphs) > 0 {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// chunkByHeading implements ChunkStrategyHeading: one chunk per section
+// when it fits in size, otherwise its paragraphs packed greedily into chunks
+// of at most size. A paragraph longer than size, such as a long argument
+// list or code block, is packed line by line instead, and only a single line
+// longer than size is cut by cutFixed.
+func chunkByHeading(text string, size, overlap int) []chunk {
+	var chunks []chunk
+	for _, s := range splitSections(text) {
+		for _, c := range pack(s.paragraphs, "\n\n", size, func(p string) []string {
+			return pack(strings.Split(p, "\n"), "\n", size, func(line string) []string {
+				return cutFixed(line, size, overlap)
+			})
+		}) {
+			chunks = append(chunks, chunk{text: c, section: s.title})
+		}
+	}
+	return chunks
+}
+
+// pack joins consecutive parts with sep into chunks of at most size
+// characters. A part longer than size is passed to split, and the pieces it
+// returns become chunks of their own.
+func pack(parts []string, sep string, size int, split func(string) []string) []string {
+	var (
+		out []string
+		cur strings.Builder
+	)
+	emit := func() {
+		if cur.Len() > 0 {
+			out = append(out, cur.String())
+			cur.Reset()
+		}
+	}
+	for _, part := range parts {
+		if len(part) > size {
+			emit()
+			out = append(out, split(part)...)
+			continue
+		}
+		if cur.Len() > 0 && cur.Len()+len(sep)+len(part) > size {
+			emit()
+		}
+		if cur.Len() > 0 {
+			cur.WriteString(sep)
+		}
+		cur.WriteString(part)
+	}
+	emit()
+	return out
+}
+
+// HTML conversion patterns used by htmlToMarkdown.
+var (
+	// reDropBlock matches elements whose content is never documentation.
+	reDropBlock = []*regexp.Regexp{
+		regexp.MustCompile(`(?is)<head\b.*?</head>`),
+		regexp.MustCompile(`(?is)<script\b.*?</script>`),
+		regexp.MustCompile(`(?is)<style\b.*?</style>`),
+		regexp.MustCompile(`(?is)<nav\b.*?</nav>`),
+		regexp.MustCompile(`(?is)<footer\b.*?</footer>`),
+	}
+	// rePre matches a preformatted block.
+	rePre = regexp.MustCompile(`(?is)<pre\b[^>]*>(.*?)</pre>`)
+	// reHTMLHeading matches an <h1>…<h6> element.
+	reHTMLHeading = regexp.MustCompile(`(?is)<h([1-6])\b[^>]*>(.*?)</h[1-6]>`)
+	// reListItem matches an opening <li> tag and the whitespace before it,
+	// so the items of a list stay one paragraph.
+	reListItem = regexp.MustCompile(`(?i)\s*<li\b[^>]*>`)
+	// reBlockTag matches tags that end a line of text.
+	reBlockTag = regexp.MustCompile(`(?i)</?(p|div|section|article|main|ul|ol|table|thead|tbody|tr|br|hr|blockquote|dl|dt|dd)\b[^>]*>`)
+	// reSpaces matches runs of horizontal whitespace.
+	reSpaces = regexp.MustCompile(`[ \t\r\f\v]+`)
+	// reBlankLines matches two or more blank lines.
+	reBlankLines = regexp.MustCompile(`\n{3,}`)
+	// rePlaceholder matches the marker a <pre> block is parked under.
+	rePlaceholder = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// htmlToMarkdown converts an HTML page into markdown-like text that keeps
+// the structure ChunkStrategyHeading splits on: headings become "#" lines,
+// block elements become paragraphs, list items become "- " lines, and <pre>
+// blocks become fenced code blocks with their line breaks intact.
+func htmlToMarkdown(raw string) string {
+	for _, re := range reDropBlock {
+		raw = re.ReplaceAllString(raw, " ")
+	}
+
+	// Park code blocks so whitespace normalisation below leaves them alone.
+	var code []string
+	raw = rePre.ReplaceAllStringFunc(raw, func(m string) string {
+		inner := rePre.FindStringSubmatch(m)[1]
+		code = append(code, strings.Trim(html.UnescapeString(reHTMLTag.ReplaceAllString(inner, "")), "\n"))
+		return "\n\n\x00" + strconv.Itoa(len(code)-1) + "\x00\n\n"
+	})
+	raw = reHTMLHeading.ReplaceAllStringFunc(raw, func(m string) string {
+		sub := reHTMLHeading.FindStringSubmatch(m)
+		level, _ := strconv.Atoi(sub[1])
+		title := strings.TrimSpace(reSpaces.ReplaceAllString(strings.ReplaceAll(reHTMLTag.ReplaceAllString(sub[2], ""), "\n", " "), " "))
+		return "\n\n" + strings.Repeat("#", level) + " " + title + "\n\n"
+	})
+	raw = reListItem.ReplaceAllString(raw, "\n- ")
+	raw = reBlockTag.ReplaceAllString(raw, "\n")
+	raw = html.UnescapeString(reHTMLTag.ReplaceAllString(raw, ""))
+
+	lines := strings.Split(raw, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(reSpaces.ReplaceAllString(line, " "))
+	}
+	text := reBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	text = rePlaceholder.ReplaceAllStringFunc(text, func(m string) string {
+		n, _ := strconv.Atoi(rePlaceholder.FindStringSubmatch(m)[1])
+		return "```\n" + code[n] + "\n```"
+	})
+	return strings.TrimSpace(text)
+}
+
+// validChunkStrategy returns an error for an unknown strategy.
+func validChunkStrategy(s string) error {
+	if s != ChunkStrategyHeading && s != ChunkStrategyFixed {
+		return fmt.Errorf("ingestion: unknown chunk strategy %q (want %q or %q)", s, ChunkStrategyHeading, ChunkStrategyFixed)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// boundary summarises a chunk for comparison: its section and first line.
+type boundary struct {
+	section string
+	first   string
+}
+
+// boundaries returns the boundary of each chunk.
+func boundaries(chunks []chunk) []boundary {
+	out := make([]boundary, len(chunks))
+	for i, c := range chunks {
+		first, _, _ := strings.Cut(c.text, "\n")
+		out[i] = boundary{section: c.section, first: first}
+	}
+	return out
+}
+
+// registryPage returns testdata/registry_page.html.
+func registryPage(t *testing.T) string {
+	t.Helper()
+	b, err := os.ReadFile("testdata/registry_page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestChunk_RegistryPage(t *testing.T) {
+	t.Parallel()
+
+	page := registryPage(t)
+	tests := []struct {
+		name string
+		size int
+		want []boundary
+	}{
+		{
+			name: "sections fit",
+			size: 1000,
+			want: []boundary{
+				{"Resource: aws_s3_bucket_versioning", "# Resource: aws_s3_bucket_versioning"},
+				{"With Versioning Enabled", "## Example Usage"},
+				{"Argument Reference", "## Argument Reference"},
+				{"versioning_configuration", "### versioning_configuration"},
+				{"Attribute Reference", "## Attribute Reference"},
+			},
+		},
+		{
+			name: "long lists split between items",
+			size: 400,
+			want: []boundary{
+				{"Resource: aws_s3_bucket_versioning", "# Resource: aws_s3_bucket_versioning"},
+				{"With Versioning Enabled", "## Example Usage"},
+				{"Argument Reference", "## Argument Reference"},
+				{"Argument Reference", "- bucket - (Required, Forces new resource) Name of the S3 bucket."},
+				{"Argument Reference", "- mfa - (Optional, Required if versioning_configuration mfa_delete is enabled) Concatenation of the authentication device's serial number, a space, and the value that is displayed on your authentication device."},
+				{"versioning_configuration", "### versioning_configuration"},
+				{"versioning_configuration", "- status - (Required) Versioning state of the bucket. Valid values: Enabled, Suspended, or Disabled. Disabled should only be used when creating or importing resources that correspond to unversioned S3 buckets."},
+				{"Attribute Reference", "## Attribute Reference"},
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p, err := NewPipeline(fakeEmbedder{}, &fakeStore{}, &Config{ChunkSize: tc.size})
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunks := p.chunk(htmlToMarkdown(page))
+			got := boundaries(chunks)
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("chunk boundaries differ\n got: %q\nwant: %q", got, tc.want)
+			}
+			for _, c := range chunks {
+				if len(c.text) > tc.size {
+					t.Errorf("chunk of %d bytes exceeds %d: %q", len(c.text), tc.size, c.text)
+				}
+			}
+		})
+	}
+}
+
+func TestChunk_Fixed(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPipeline(fakeEmbedder{}, &fakeStore{}, &Config{ChunkSize: 10, ChunkOverlap: 2, ChunkStrategy: ChunkStrategyFixed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[{# T\n\nxxxxx } {xxxxxxxxxx } {xxxxxxxxx }]"
+	if got := fmt.Sprint(p.chunk("# T\n\n" + strings.Repeat("x", 20))); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestChunkByHeading_Markdown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []boundary
+	}{
+		{
+			name: "text before the first heading has no section",
+			text: "Intro line.\n\n## Usage\n\nCall it.",
+			size: 100,
+			want: []boundary{{"", "Intro line."}, {"Usage", "## Usage"}},
+		},
+		{
+			name: "hash lines inside code fences are not headings",
+			text: "## Example\n\n```hcl\n# the bucket\nresource \"x\" \"y\" {}\n\n# more\n```",
+			size: 100,
+			want: []boundary{{"Example", "## Example"}},
+		},
+		{
+			name: "paragraphs are packed up to size",
+			text: "# Guide\n\naaaa\n\nbbbb\n\ncccc",
+			size: 20,
+			want: []boundary{{"Guide", "# Guide"}, {"Guide", "cccc"}},
+		},
+		{
+			name: "a single over-long line is cut",
+			text: "# T\n\n" + strings.Repeat("x", 25),
+			size: 10,
+			want: []boundary{{"T", "# T"}, {"T", "xxxxxxxxxx"}, {"T", "xxxxxxxxxx"}, {"T", "xxxxxxxxx"}},
+		},
+		{
+			name: "trailing heading is kept",
+			text: "# A\n\nbody\n\n## B",
+			size: 100,
+			want: []boundary{{"A", "# A"}, {"B", "## B"}},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := boundaries(chunkByHeading(tc.text, tc.size, 2))
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("chunk boundaries differ\n got: %q\nwant: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToMarkdown_KeepsCode(t *testing.T) {
+	t.Parallel()
+
+	md := htmlToMarkdown(registryPage(t))
+	for _, want := range []string{
+		"```\nresource \"aws_s3_bucket\" \"example\" {\n  bucket = \"example-bucket\"\n}\n",
+		"- expected_bucket_owner - (Optional, Forces new resource)",
+		"authentication device's serial number",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in:\n%s", want, md)
+		}
+	}
+	for _, unwanted := range []string{"window.registry", "margin-top", "AWS Provider", "<code>"} {
+		if strings.Contains(md, unwanted) {
+			t.Errorf("expected %q dropped from:\n%s", unwanted, md)
+		}
+	}
+}
+
+func TestIngest_SectionMetadata(t *testing.T) {
+	t.Parallel()
+
+	page := registryPage(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, page)
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{}
+	p, err := NewPipeline(fakeEmbedder{}, store, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Ingest(context.Background(), []Source{{URL: srv.URL}}, nil); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	var sections []string
+	for _, d := range store.docs {
+		sections = append(sections, d.Metadata["section"])
+	}
+	want := "[Resource: aws_s3_bucket_versioning With Versioning Enabled Argument Reference versioning_configuration Attribute Reference]"
+	if fmt.Sprint(sections) != want {
+		t.Errorf("expected sections %s, got %v", want, sections)
+	}
+}
+
+func TestNewPipeline_ChunkStrategy(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPipeline(fakeEmbedder{}, &fakeStore{}, &Config{})
+	if err != nil || p.cfg.ChunkStrategy != ChunkStrategyHeading {
+		t.Errorf("expected the heading strategy by default, got %v, %v", p, err)
+	}
+	if _, err := NewPipeline(fakeEmbedder{}, &fakeStore{}, &Config{ChunkStrategy: "semantic"}); err == nil {
+		t.Error("expected an unknown strategy to be rejected")
+	}
+}
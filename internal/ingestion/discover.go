@@ -0,0 +1,271 @@
+package ingestion
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/httpx"
+)
+
+// DefaultDiscoverLimit caps the number of URLs Discover returns when
+// DiscoverOptions.Limit is zero.
+const DefaultDiscoverLimit = 200
+
+// maxSitemapBytes bounds a single sitemap or index page read by Discover.
+const maxSitemapBytes = 50 << 20
+
+// DiscoverOptions configures Discover.
+type DiscoverOptions struct {
+	// CrawlDepth, when positive, treats the root URL as an HTML index page
+	// and follows same-host links up to this many levels deep. When zero
+	// the root URL is read as a sitemap.xml or sitemap index.
+	CrawlDepth int
+
+	// Include keeps only the URLs it matches. Nil keeps every URL.
+	Include *regexp.Regexp
+
+	// Limit caps the number of URLs returned, and the number of pages
+	// fetched while crawling. Defaults to DefaultDiscoverLimit if zero;
+	// negative means no limit.
+	Limit int
+
+	// HTTPClient fetches sitemaps and index pages. Defaults to an
+	// httpx client with a 30s timeout.
+	HTTPClient *http.Client
+
+	// UserAgent is sent with every request. Defaults to DefaultUserAgent.
+	UserAgent string
+}
+
+// Discovery is the result of Discover.
+type Discovery struct {
+	// URLs are the discovered documentation URLs, deduplicated, in the
+	// order they were found, at most Limit of them.
+	URLs []string
+
+	// Truncated is true when more matching URLs were found than Limit.
+	Truncated bool
+}
+
+// Discover finds the documentation pages under root, either by reading it
+// as a sitemap (following a sitemap index one level down) or, with
+// CrawlDepth, by crawling it as an index page. The URLs are filtered by
+// Include and capped at Limit, so a provider with hundreds of resource pages
+// can be ingested without listing each one.
+func Discover(ctx context.Context, root string, opts DiscoverOptions) (*Discovery, error) {
+	if opts.Limit == 0 {
+		opts.Limit = DefaultDiscoverLimit
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = httpx.NewClient(httpx.Options{Component: "ingestion", Timeout: 30 * time.Second})
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	d := &discoverer{opts: opts, seen: map[string]bool{}, out: &Discovery{URLs: []string{}}}
+
+	var err error
+	if opts.CrawlDepth > 0 {
+		err = d.crawl(ctx, root)
+	} else {
+		err = d.sitemap(ctx, root, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.out, nil
+}
+
+// discoverer holds the state of one Discover call.
+type discoverer struct {
+	// opts is the resolved configuration.
+	opts DiscoverOptions
+	// seen holds every URL already considered, matching or not.
+	seen map[string]bool
+	// out accumulates the result.
+	out *Discovery
+}
+
+// full reports whether the limit has been reached.
+func (d *discoverer) full() bool {
+	return d.opts.Limit > 0 && len(d.out.URLs) >= d.opts.Limit
+}
+
+// add records u if it is new and matches Include. Once the limit is reached
+// further matches only mark the result truncated.
+func (d *discoverer) add(u string) {
+	if d.seen[u] {
+		return
+	}
+	d.seen[u] = true
+	if d.opts.Include != nil && !d.opts.Include.MatchString(u) {
+		return
+	}
+	if d.full() {
+		d.out.Truncated = true
+		return
+	}
+	d.out.URLs = append(d.out.URLs, u)
+}
+
+// sitemap adds the URLs listed in the sitemap at loc. When follow is true
+// and loc is a sitemap index, each child sitemap is read in turn.
+func (d *discoverer) sitemap(ctx context.Context, loc string, follow bool) error {
+	body, err := d.get(ctx, loc)
+	if err != nil {
+		return err
+	}
+	urls, children, err := ParseSitemap(strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("ingestion: sitemap %s: %w", loc, err)
+	}
+	for _, u := range urls {
+		d.add(u)
+	}
+	if !follow {
+		return nil
+	}
+	for _, child := range children {
+		if d.full() {
+			d.out.Truncated = true
+			return nil
+		}
+		if err := d.sitemap(ctx, child, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// crawl adds the same-host pages linked from root, breadth first, up to
+// CrawlDepth levels of links. The root itself is not added: it is the index,
+// not a documentation page.
+func (d *discoverer) crawl(ctx context.Context, root string) error {
+	base, err := url.Parse(root)
+	if err != nil {
+		return fmt.Errorf("ingestion: crawl root %q: %w", root, err)
+	}
+	d.seen[base.String()] = true
+
+	frontier := []*url.URL{base}
+	fetched := 0
+	for depth := 0; depth < d.opts.CrawlDepth && len(frontier) > 0; depth++ {
+		var next []*url.URL
+		for _, page := range frontier {
+			if d.full() || (d.opts.Limit > 0 && fetched >= d.opts.Limit) {
+				return nil
+			}
+			body, err := d.get(ctx, page.String())
+			if err != nil {
+				if page == base {
+					return err
+				}
+				// One broken link must not abort the whole crawl.
+				continue
+			}
+			fetched++
+			for _, link := range ExtractLinks(page, body) {
+				if link.Host != base.Host || d.seen[link.String()] {
+					continue
+				}
+				d.add(link.String())
+				next = append(next, link)
+			}
+		}
+		frontier = next
+	}
+	return nil
+}
+
+// get fetches u and returns its body.
+func (d *discoverer) get(ctx context.Context, u string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", fmt.Errorf("ingestion: creating request for %s: %w", u, err)
+	}
+	req.Header.Set("User-Agent", d.opts.UserAgent)
+
+	resp, err := d.opts.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ingestion: fetching %s: %w", u, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("ingestion: unexpected status %d for %s", resp.StatusCode, u)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSitemapBytes))
+	if err != nil {
+		return "", fmt.Errorf("ingestion: reading %s: %w", u, err)
+	}
+	return string(body), nil
+}
+
+// sitemapDoc decodes both a <urlset> sitemap and a <sitemapindex>.
+type sitemapDoc struct {
+	// XMLName is the root element: urlset or sitemapindex.
+	XMLName xml.Name
+	// URLs are the <url><loc> entries of a urlset.
+	URLs []sitemapLoc `xml:"url"`
+	// Sitemaps are the <sitemap><loc> entries of a sitemap index.
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// sitemapLoc is one <url> or <sitemap> element.
+type sitemapLoc struct {
+	// Loc is the absolute URL of the page or child sitemap.
+	Loc string `xml:"loc"`
+}
+
+// ParseSitemap reads a sitemap (https://www.sitemaps.org/protocol.html). It
+// returns the page URLs of a <urlset> and the child sitemap URLs of a
+// <sitemapindex>; one of the two is normally empty. Any other document,
+// such as an HTML page, is an error.
+func ParseSitemap(r io.Reader) (urls, sitemaps []string, err error) {
+	var doc sitemapDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("parsing sitemap: %w", err)
+	}
+	if root := doc.XMLName.Local; root != "urlset" && root != "sitemapindex" {
+		return nil, nil, fmt.Errorf("parsing sitemap: unexpected root element <%s>", root)
+	}
+	for _, u := range doc.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			urls = append(urls, loc)
+		}
+	}
+	for _, s := range doc.Sitemaps {
+		if loc := strings.TrimSpace(s.Loc); loc != "" {
+			sitemaps = append(sitemaps, loc)
+		}
+	}
+	return urls, sitemaps, nil
+}
+
+// reHref matches the target of an href attribute.
+var reHref = regexp.MustCompile(`(?i)href\s*=\s*["']([^"'#]+)`)
+
+// ExtractLinks returns the http(s) links in the HTML page body, resolved
+// against page and stripped of fragments, in document order.
+func ExtractLinks(page *url.URL, body string) []*url.URL {
+	var links []*url.URL
+	for _, m := range reHref.FindAllStringSubmatch(body, -1) {
+		ref, err := url.Parse(strings.TrimSpace(m[1]))
+		if err != nil {
+			continue
+		}
+		link := page.ResolveReference(ref)
+		if link.Scheme != "http" && link.Scheme != "https" {
+			continue
+		}
+		link.Fragment = ""
+		links = append(links, link)
+	}
+	return links
+}
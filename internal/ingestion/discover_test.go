@@ -0,0 +1,249 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// awsDocs is the prefix of every URL in testdata/sitemap.xml.
+const awsDocs = "https://registry.terraform.io/providers/hashicorp/aws/latest/docs"
+
+// sitemapServer serves the fixtures in testdata, with {{base}} replaced by
+// the server's own URL, plus a small linked site under /docs for crawling.
+func sitemapServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml", "/sitemap_index.xml":
+			body, err := os.ReadFile("testdata" + r.URL.Path)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			_, _ = fmt.Fprint(w, strings.ReplaceAll(string(body), "{{base}}", srv.URL))
+		case "/sitemap-guides.xml":
+			_, _ = fmt.Fprint(w, `<urlset><url><loc>`+awsDocs+`/guides/version-6-upgrade</loc></url></urlset>`)
+		case "/docs":
+			_, _ = fmt.Fprint(w, `<html><a href="/docs/resources">Resources</a> <a href="docs/guides/intro#top">Intro</a>
+				<a href="https://elsewhere.example.com/x">Away</a> <a href="mailto:docs@example.com">Mail</a></html>`)
+		case "/docs/resources":
+			_, _ = fmt.Fprint(w, `<html><a href="/docs/resources/eks_cluster">EKS</a> <a href='/docs/resources/vpc'>VPC</a>
+				<a href="/docs">Back</a></html>`)
+		case "/docs/resources/eks_cluster", "/docs/resources/vpc", "/docs/guides/intro":
+			_, _ = fmt.Fprint(w, `<html>leaf</html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestParseSitemap(t *testing.T) {
+	t.Parallel()
+
+	f, err := os.Open("testdata/sitemap.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	urls, sitemaps, err := ParseSitemap(f)
+	if err != nil {
+		t.Fatalf("ParseSitemap: %v", err)
+	}
+	if len(urls) != 8 || len(sitemaps) != 0 {
+		t.Fatalf("expected 8 urls and no child sitemaps, got %d and %v", len(urls), sitemaps)
+	}
+	if urls[6] != awsDocs+"/resources/iam_role" {
+		t.Errorf("expected whitespace around <loc> trimmed, got %q", urls[6])
+	}
+
+	if _, _, err := ParseSitemap(strings.NewReader("<urlset><url>")); err == nil {
+		t.Error("expected an error for a truncated sitemap")
+	}
+}
+
+func TestDiscover_Sitemap(t *testing.T) {
+	t.Parallel()
+
+	srv := sitemapServer(t)
+	tests := []struct {
+		name          string
+		path          string
+		include       string
+		limit         int
+		want          []string
+		wantTruncated bool
+	}{
+		{
+			name:    "include pattern",
+			path:    "/sitemap.xml",
+			include: `/docs/resources/`,
+			want: []string{
+				awsDocs + "/resources/eks_cluster",
+				awsDocs + "/resources/s3_bucket",
+				awsDocs + "/resources/vpc",
+				awsDocs + "/resources/iam_role",
+			},
+		},
+		{
+			name:  "limit caps the list",
+			path:  "/sitemap.xml",
+			limit: 2,
+			want: []string{
+				awsDocs,
+				awsDocs + "/resources/eks_cluster",
+			},
+			wantTruncated: true,
+		},
+		{
+			name:  "duplicates do not count towards the limit",
+			path:  "/sitemap.xml",
+			limit: 7,
+			want: []string{
+				awsDocs,
+				awsDocs + "/resources/eks_cluster",
+				awsDocs + "/resources/s3_bucket",
+				awsDocs + "/resources/vpc",
+				awsDocs + "/data-sources/ami",
+				awsDocs + "/guides/version-5-upgrade",
+				awsDocs + "/resources/iam_role",
+			},
+		},
+		{
+			name:    "sitemap index",
+			path:    "/sitemap_index.xml",
+			include: `/guides/`,
+			want: []string{
+				awsDocs + "/guides/version-5-upgrade",
+				awsDocs + "/guides/version-6-upgrade",
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			opts := DiscoverOptions{Limit: tc.limit}
+			if tc.include != "" {
+				opts.Include = regexp.MustCompile(tc.include)
+			}
+			got, err := Discover(context.Background(), srv.URL+tc.path, opts)
+			if err != nil {
+				t.Fatalf("Discover: %v", err)
+			}
+			if !slices.Equal(got.URLs, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got.URLs)
+			}
+			if got.Truncated != tc.wantTruncated {
+				t.Errorf("expected truncated=%v, got %v", tc.wantTruncated, got.Truncated)
+			}
+		})
+	}
+}
+
+func TestDiscover_DefaultLimit(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	b.WriteString("<urlset>")
+	for i := 0; i < DefaultDiscoverLimit+50; i++ {
+		fmt.Fprintf(&b, "<url><loc>%s/resources/r%d</loc></url>", awsDocs, i)
+	}
+	b.WriteString("</urlset>")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, b.String())
+	}))
+	defer srv.Close()
+
+	got, err := Discover(context.Background(), srv.URL, DiscoverOptions{})
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if len(got.URLs) != DefaultDiscoverLimit || !got.Truncated {
+		t.Errorf("expected %d URLs and truncation, got %d (truncated=%v)", DefaultDiscoverLimit, len(got.URLs), got.Truncated)
+	}
+
+	got, err = Discover(context.Background(), srv.URL, DiscoverOptions{Limit: -1})
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if len(got.URLs) != DefaultDiscoverLimit+50 || got.Truncated {
+		t.Errorf("expected no limit, got %d URLs", len(got.URLs))
+	}
+}
+
+func TestDiscover_Crawl(t *testing.T) {
+	t.Parallel()
+
+	srv := sitemapServer(t)
+	tests := []struct {
+		name    string
+		depth   int
+		include string
+		want    []string
+	}{
+		{
+			name:  "depth 1 follows the index links only",
+			depth: 1,
+			want:  []string{srv.URL + "/docs/resources", srv.URL + "/docs/guides/intro"},
+		},
+		{
+			name:  "depth 2 follows links of linked pages",
+			depth: 2,
+			want: []string{
+				srv.URL + "/docs/resources",
+				srv.URL + "/docs/guides/intro",
+				srv.URL + "/docs/resources/eks_cluster",
+				srv.URL + "/docs/resources/vpc",
+			},
+		},
+		{
+			name:    "non-matching index pages are still followed",
+			depth:   2,
+			include: `/resources/\w+$`,
+			want:    []string{srv.URL + "/docs/resources/eks_cluster", srv.URL + "/docs/resources/vpc"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			opts := DiscoverOptions{CrawlDepth: tc.depth}
+			if tc.include != "" {
+				opts.Include = regexp.MustCompile(tc.include)
+			}
+			got, err := Discover(context.Background(), srv.URL+"/docs", opts)
+			if err != nil {
+				t.Fatalf("Discover: %v", err)
+			}
+			if !slices.Equal(got.URLs, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got.URLs)
+			}
+		})
+	}
+}
+
+func TestDiscover_RootErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := sitemapServer(t)
+	if _, err := Discover(context.Background(), srv.URL+"/missing.xml", DiscoverOptions{}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected the sitemap status error, got %v", err)
+	}
+	if _, err := Discover(context.Background(), srv.URL+"/docs", DiscoverOptions{}); err == nil || !strings.Contains(err.Error(), "parsing sitemap") {
+		t.Errorf("expected an HTML page read as a sitemap to fail, got %v", err)
+	}
+	if _, err := Discover(context.Background(), srv.URL+"/missing", DiscoverOptions{CrawlDepth: 1}); err == nil {
+		t.Error("expected a missing crawl root to fail")
+	}
+}
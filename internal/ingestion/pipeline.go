@@ -7,34 +7,45 @@ package ingestion
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/sync/errgroup"
+
+	"github.com/54b3r/tfai-go/internal/embedder"
+	"github.com/54b3r/tfai-go/internal/httpx"
 	"github.com/54b3r/tfai-go/internal/rag"
 )
 
 // Source describes a documentation source to be ingested.
 type Source struct {
 	// URL is the HTTP(S) URL of the documentation page to fetch.
-	URL string
+	URL string `json:"url"`
 
 	// Provider identifies the cloud provider (aws, azure, gcp, generic).
-	Provider string
+	Provider string `json:"provider,omitempty"`
 
 	// ResourceType is the Terraform resource type this doc covers (e.g. "aws_eks_cluster").
-	ResourceType string
+	ResourceType string `json:"resource_type,omitempty"`
 
 	// Framework is the IaC framework this doc belongs to (e.g. terraform, atmos, terragrunt, cdktf).
 	// Used as a Qdrant payload field to enable framework-scoped retrieval.
-	Framework string
+	Framework string `json:"framework,omitempty"`
 
 	// DocType classifies the kind of documentation (reference, tutorial, guide, api, changelog).
 	// Used as a Qdrant payload field to enable doc-type-scoped retrieval.
-	DocType string
+	DocType string `json:"doc_type,omitempty"`
+
+	// Preset names the built-in preset the source came from, if any. It is
+	// stored as a Qdrant payload field so rag status can report coverage
+	// per preset.
+	Preset string `json:"preset,omitempty"`
 }
 
 // Config holds the configuration for the ingestion pipeline.
@@ -44,17 +55,54 @@ type Config struct {
 	ChunkSize int
 
 	// ChunkOverlap is the number of characters to overlap between consecutive chunks.
-	// Defaults to 100 if zero.
+	// Defaults to 100 if zero. With ChunkStrategyHeading it applies only
+	// where a paragraph longer than ChunkSize has to be cut.
 	ChunkOverlap int
 
+	// ChunkStrategy selects how pages are split: ChunkStrategyHeading or
+	// ChunkStrategyFixed. Defaults to ChunkStrategyHeading if empty.
+	ChunkStrategy string
+
 	// HTTPTimeout is the timeout for each documentation fetch request.
 	// Defaults to 30s if zero.
 	HTTPTimeout time.Duration
 
 	// UserAgent is the HTTP User-Agent header sent with fetch requests.
 	UserAgent string
+
+	// EmbedderID identifies the embedding backend and model (e.g.
+	// "openai/text-embedding-3-small"). It is part of the fingerprint that
+	// stops a run from being resumed with a different embedder.
+	EmbedderID string
+
+	// EmbedRetry controls how Embed calls that fail transiently (429, 5xx,
+	// network errors) are retried. Zero fields take the embedder package
+	// defaults; a negative MaxRetries disables retries.
+	EmbedRetry embedder.RetryPolicy
+
+	// Concurrency is the number of sources fetched, embedded, and stored in
+	// parallel. Defaults to DefaultConcurrency if zero.
+	Concurrency int
+
+	// FailFast makes Ingest stop at the first failed source and return its
+	// error, instead of ingesting the rest and returning every error.
+	FailFast bool
+
+	// BoilerplatePercent is the share of a multi-page run's pages a block of
+	// lines must repeat on to be stripped before chunking, like a shared
+	// navigation menu or footer. Defaults to DefaultBoilerplatePercent if
+	// zero; negative disables the detection. Known boilerplate phrases are
+	// stripped from every page regardless.
+	BoilerplatePercent int
 }
 
+// DefaultUserAgent is the User-Agent sent when Config.UserAgent is empty.
+const DefaultUserAgent = "tfai-go/1.0 (terraform documentation ingestion)"
+
+// DefaultConcurrency is the number of sources processed in parallel when
+// Config.Concurrency is zero.
+const DefaultConcurrency = 4
+
 // Pipeline orchestrates the fetch → chunk → embed → upsert flow for a set
 // of documentation sources.
 type Pipeline struct {
@@ -72,8 +120,8 @@ type Pipeline struct {
 }
 
 // NewPipeline constructs a Pipeline from the provided dependencies and config.
-func NewPipeline(embedder rag.Embedder, store rag.VectorStore, cfg *Config) (*Pipeline, error) {
-	if embedder == nil {
+func NewPipeline(emb rag.Embedder, store rag.VectorStore, cfg *Config) (*Pipeline, error) {
+	if emb == nil {
 		return nil, fmt.Errorf("ingestion: embedder must not be nil")
 	}
 	if store == nil {
@@ -91,75 +139,193 @@ func NewPipeline(embedder rag.Embedder, store rag.VectorStore, cfg *Config) (*Pi
 	if cfg.ChunkOverlap >= cfg.ChunkSize {
 		cfg.ChunkOverlap = cfg.ChunkSize / 10
 	}
+	if cfg.ChunkStrategy == "" {
+		cfg.ChunkStrategy = ChunkStrategyHeading
+	}
+	if err := validChunkStrategy(cfg.ChunkStrategy); err != nil {
+		return nil, err
+	}
 	if cfg.HTTPTimeout <= 0 {
 		cfg.HTTPTimeout = 30 * time.Second
 	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = DefaultConcurrency
+	}
 	if cfg.UserAgent == "" {
-		cfg.UserAgent = "tfai-go/1.0 (terraform documentation ingestion)"
+		cfg.UserAgent = DefaultUserAgent
+	}
+	if cfg.BoilerplatePercent == 0 {
+		cfg.BoilerplatePercent = DefaultBoilerplatePercent
 	}
 
 	return &Pipeline{
-		embedder: embedder,
-		store:    store,
-		cfg:      cfg,
-		httpClient: &http.Client{
-			Timeout: cfg.HTTPTimeout,
-		},
+		embedder:   embedder.NewRetryingEmbedder(emb, cfg.EmbedRetry),
+		store:      store,
+		cfg:        cfg,
+		httpClient: httpx.NewClient(httpx.Options{Component: "ingestion", Timeout: cfg.HTTPTimeout, UserAgent: cfg.UserAgent}),
 	}, nil
 }
 
-// Ingest fetches, chunks, embeds, and stores all provided sources.
-// It processes sources sequentially and returns the first error encountered.
-// Progress is reported via the optional progress callback.
+// Ingest fetches, chunks, embeds, and stores all provided sources, up to
+// Config.Concurrency at a time. A source that fails is reported through
+// progress and the others are still ingested; the errors of every failed
+// source are returned joined, in source order. With Config.FailFast the first
+// error cancels the sources in flight and is returned alone. When ctx is
+// cancelled no further sources are started. Progress is reported via the
+// optional progress callback, which is never called concurrently.
+//
+// Boilerplate is stripped from each page before chunking; see hygiene.
 func (p *Pipeline) Ingest(ctx context.Context, sources []Source, progress func(msg string)) error {
-	if progress == nil {
-		progress = func(string) {}
+	progress = serialize(progress)
+	urls := make([]string, len(sources))
+	for i, src := range sources {
+		urls[i] = src.URL
 	}
+	h := p.newHygiene(urls)
 
-	for _, src := range sources {
-		progress(fmt.Sprintf("fetching %s", src.URL))
+	g, runCtx := &errgroup.Group{}, ctx
+	if p.cfg.FailFast {
+		g, runCtx = errgroup.WithContext(ctx)
+	}
+	g.SetLimit(p.cfg.Concurrency)
 
-		content, err := p.fetch(ctx, src.URL)
-		if err != nil {
-			return fmt.Errorf("ingestion: fetch failed for %s: %w", src.URL, err)
+	errs := make([]error, len(sources))
+	for i, src := range sources {
+		if runCtx.Err() != nil {
+			break
 		}
+		g.Go(func() error {
+			if runCtx.Err() != nil {
+				// Cancelled while waiting for a free worker.
+				return nil
+			}
+			content, err := p.fetchPage(runCtx, h, src.URL, progress)
+			if err != nil {
+				err = fmt.Errorf("ingestion: fetch failed for %s: %w", src.URL, err)
+			} else {
+				var n int
+				if n, err = p.ingestContent(runCtx, h, src, content, progress); err == nil {
+					progress(fmt.Sprintf("ingested %d chunks from %s", n, src.URL))
+					return nil
+				}
+			}
+			if p.cfg.FailFast {
+				return err
+			}
+			errs[i] = err
+			progress(fmt.Sprintf("failed %s: %v", src.URL, err))
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err //nolint:wrapcheck // already prefixed by the worker
+	}
+	return errors.Join(ctx.Err(), errors.Join(errs...))
+}
 
-		chunks := p.chunk(content)
-		progress(fmt.Sprintf("chunked %s into %d chunks", src.URL, len(chunks)))
+// serialize returns a progress callback that calls progress under a mutex,
+// so workers can report concurrently. A nil progress discards messages.
+func serialize(progress func(msg string)) func(msg string) {
+	if progress == nil {
+		return func(string) {}
+	}
+	var mu sync.Mutex
+	return func(msg string) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress(msg)
+	}
+}
 
-		texts := chunks
+// ingestContent strips boilerplate from the fetched content of one source
+// with h, then chunks, embeds, and upserts it and returns the number of
+// chunks stored. Chunks whose text an earlier ingestion of the source
+// already embedded keep their stored embedding (see reuseEmbeddings), and
+// only the new and changed ones are embedded. Chunks stored by an earlier
+// ingestion that the new content no longer produces, such as the tail of a
+// page that shrank, are deleted after the upsert.
+func (p *Pipeline) ingestContent(ctx context.Context, h *hygiene, src Source, content string, progress func(msg string)) (int, error) {
+	content, removed := h.clean(content)
+	if removed > 0 {
+		progress(fmt.Sprintf("stripped %d bytes of boilerplate from %s", removed, src.URL))
+	}
+	chunks := p.chunk(content)
+	progress(fmt.Sprintf("chunked %s into %d chunks", src.URL, len(chunks)))
 
-		embeddings, err := p.embedder.Embed(ctx, texts)
-		if err != nil {
-			return fmt.Errorf("ingestion: embedding failed for %s: %w", src.URL, err)
-		}
+	existing, err := p.storedChunks(ctx, src.URL)
+	if err != nil {
+		return 0, fmt.Errorf("ingestion: listing stored chunks failed for %s: %w", src.URL, err)
+	}
 
-		docs := make([]rag.Document, 0, len(chunks))
-		for i, chunk := range chunks {
-			id := chunkID(src.URL, i)
-			doc := rag.Document{
-				ID:      id,
-				Content: chunk,
-				Source:  src.URL,
-				Metadata: map[string]string{
-					"provider":      src.Provider,
-					"resource_type": src.ResourceType,
-					"framework":     src.Framework,
-					"doc_type":      src.DocType,
-					"chunk_index":   fmt.Sprintf("%d", i),
-				},
-			}
-			docs = append(docs, doc)
+	docs := make([]rag.Document, 0, len(chunks))
+	texts := make([]string, len(chunks))
+	ids := make([]string, len(chunks))
+	hashes := make([]string, len(chunks))
+	for i, c := range chunks {
+		texts[i] = c.text
+		ids[i] = chunkID(src.URL, i)
+		hashes[i] = chunkHash(p.cfg.EmbedderID, c.text)
+		doc := rag.Document{
+			ID:      ids[i],
+			Content: c.text,
+			Source:  src.URL,
+			Metadata: map[string]string{
+				"provider":      src.Provider,
+				"resource_type": src.ResourceType,
+				"framework":     src.Framework,
+				"doc_type":      src.DocType,
+				"chunk_index":   fmt.Sprintf("%d", i),
+				"chunk_hash":    hashes[i],
+			},
 		}
-
-		if err := p.store.Upsert(ctx, docs, embeddings); err != nil {
-			return fmt.Errorf("ingestion: upsert failed for %s: %w", src.URL, err)
+		if c.section != "" {
+			doc.Metadata["section"] = c.section
 		}
+		if src.Preset != "" {
+			doc.Metadata["preset"] = src.Preset
+		}
+		docs = append(docs, doc)
+	}
+
+	reused, shifted := reuseEmbeddings(ids, hashes, existing)
+	if shifted {
+		progress(fmt.Sprintf("chunk boundaries of %s shifted; embedding every chunk again", src.URL))
+	}
+	embeddings, embedded, err := p.embedChunks(ctx, texts, reused)
+	if err != nil {
+		return 0, fmt.Errorf("ingestion: embedding failed for %s: %w", src.URL, err)
+	}
+	if saved := len(chunks) - embedded; saved > 0 {
+		progress(fmt.Sprintf("reused %d unchanged chunk embeddings from %s, embedded %d", saved, src.URL, embedded))
+	}
 
-		progress(fmt.Sprintf("ingested %d chunks from %s", len(chunks), src.URL))
+	if err := p.store.Upsert(ctx, docs, embeddings); err != nil {
+		return 0, fmt.Errorf("ingestion: upsert failed for %s: %w", src.URL, err)
 	}
 
-	return nil
+	if stale := staleChunkIDs(existing, docs); len(stale) > 0 {
+		if err := p.store.Delete(ctx, stale); err != nil {
+			return 0, fmt.Errorf("ingestion: deleting stale chunks failed for %s: %w", src.URL, err)
+		}
+		progress(fmt.Sprintf("removed %d stale chunks from %s", len(stale), src.URL))
+	}
+	return len(chunks), nil
+}
+
+// staleChunkIDs returns the IDs of the chunks in existing that are not the
+// ID of any of docs, in the order of existing.
+func staleChunkIDs(existing []rag.StoredChunk, docs []rag.Document) []string {
+	current := make(map[string]bool, len(docs))
+	for _, d := range docs {
+		current[d.ID] = true
+	}
+	var stale []string
+	for _, c := range existing {
+		if !current[c.ID] {
+			stale = append(stale, c.ID)
+		}
+	}
+	return stale
 }
 
 // reHTMLTag matches any HTML tag.
@@ -201,38 +367,17 @@ func (p *Pipeline) fetch(ctx context.Context, url string) (string, error) {
 	}
 
 	text := string(body)
-	// Strip HTML tags if the response looks like an HTML page.
+	// Convert the response if it looks like an HTML page: to markdown when
+	// chunking by heading, so the headings survive, else to plain text.
 	if strings.Contains(text, "<html") || strings.Contains(text, "<!DOCTYPE") {
+		if p.cfg.ChunkStrategy == ChunkStrategyHeading {
+			return htmlToMarkdown(text), nil
+		}
 		text = stripHTML(text)
 	}
 	return text, nil
 }
 
-// chunk splits text into overlapping chunks of cfg.ChunkSize characters.
-func (p *Pipeline) chunk(text string) []string {
-	text = strings.TrimSpace(text)
-	if len(text) == 0 {
-		return nil
-	}
-
-	var chunks []string
-	size := p.cfg.ChunkSize
-	overlap := p.cfg.ChunkOverlap
-
-	for start := 0; start < len(text); start += size - overlap {
-		end := start + size
-		if end > len(text) {
-			end = len(text)
-		}
-		chunks = append(chunks, text[start:end])
-		if end == len(text) {
-			break
-		}
-	}
-
-	return chunks
-}
-
 // chunkID generates a deterministic UUID-format ID for a document chunk based
 // on its source URL and chunk index. The format (8-4-4-4-12 hex) satisfies
 // qdrant.NewIDUUID without requiring the google/uuid dependency.
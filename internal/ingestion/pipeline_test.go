@@ -0,0 +1,255 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gateEmbedder blocks every Embed call until release is closed (or ctx is
+// done) and records the peak number of calls in flight.
+type gateEmbedder struct {
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func newGateEmbedder() *gateEmbedder {
+	return &gateEmbedder{started: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (e *gateEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.inFlight++
+	e.peak = max(e.peak, e.inFlight)
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.inFlight--
+		e.mu.Unlock()
+	}()
+
+	e.started <- struct{}{}
+	select {
+	case <-e.release:
+		return fakeEmbedder{}.Embed(ctx, texts)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// waitStarted waits for n Embed calls to start.
+func (e *gateEmbedder) waitStarted(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-e.started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d embed calls started", i, n)
+		}
+	}
+}
+
+// pages returns sources for /page/1 … /page/n of srv.
+func pages(srv *httptest.Server, n int) []Source {
+	sources := make([]Source, n)
+	for i := range sources {
+		sources[i] = Source{URL: fmt.Sprintf("%s/page/%d", srv.URL, i+1)}
+	}
+	return sources
+}
+
+func TestIngest_Parallel(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(&fakeSite{fetches: map[string]int{}})
+	defer srv.Close()
+
+	emb := newGateEmbedder()
+	store := &fakeStore{}
+	p, err := NewPipeline(emb, store, &Config{Concurrency: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- p.Ingest(context.Background(), pages(srv, 6), nil) }()
+
+	// Three sources must be embedding at once before any is let through.
+	emb.waitStarted(t, 3)
+	close(emb.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if emb.peak != 3 {
+		t.Errorf("expected 3 sources in flight at most, got %d", emb.peak)
+	}
+	if len(store.sources) != 6 {
+		t.Errorf("expected 6 sources stored, got %v", store.sources)
+	}
+}
+
+func TestIngest_CollectsErrors(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(&fakeSite{fetches: map[string]int{}})
+	defer srv.Close()
+
+	store := &fakeStore{}
+	p, err := NewPipeline(fakeEmbedder{}, store, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := append(pages(srv, 9), Source{URL: srv.URL + "/page/7?again"})
+
+	var (
+		mu       sync.Mutex
+		failures []string
+	)
+	err = p.Ingest(context.Background(), sources, func(msg string) {
+		if strings.HasPrefix(msg, "failed ") {
+			mu.Lock()
+			failures = append(failures, msg)
+			mu.Unlock()
+		}
+	})
+	if err == nil {
+		t.Fatal("expected the failed sources to be reported")
+	}
+	if got := strings.Count(err.Error(), "fetch failed for "+srv.URL+"/page/7"); got != 2 {
+		t.Errorf("expected both page 7 errors joined, got %v", err)
+	}
+	if len(failures) != 2 {
+		t.Errorf("expected 2 failure progress messages, got %v", failures)
+	}
+	if len(store.sources) != 8 {
+		t.Errorf("expected the 8 other sources stored, got %v", store.sources)
+	}
+}
+
+func TestIngest_FailFast(t *testing.T) {
+	t.Parallel()
+
+	site := &fakeSite{fetches: map[string]int{}}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+
+	store := &fakeStore{}
+	p, err := NewPipeline(fakeEmbedder{}, store, &Config{Concurrency: 1, FailFast: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Ingest(context.Background(), pages(srv, 9), nil)
+	if err == nil || !strings.Contains(err.Error(), "/page/7") {
+		t.Fatalf("expected the page 7 error, got %v", err)
+	}
+	if len(store.sources) != 6 || site.fetches["/page/8"] != 0 {
+		t.Errorf("expected the run to stop at page 7, stored %v", store.sources)
+	}
+}
+
+func TestIngest_ContextCancelled(t *testing.T) {
+	t.Parallel()
+
+	site := &fakeSite{fetches: map[string]int{}}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+
+	emb := newGateEmbedder()
+	store := &fakeStore{}
+	p, err := NewPipeline(emb, store, &Config{Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Ingest(ctx, pages(srv, 10), nil) }()
+
+	emb.waitStarted(t, 2)
+	cancel()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Ingest did not return after cancellation")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if len(store.sources) != 0 {
+		t.Errorf("expected in-flight sources to stop before storing, got %v", store.sources)
+	}
+	site.mu.Lock()
+	defer site.mu.Unlock()
+	if len(site.fetches) > 2 {
+		t.Errorf("expected no sources started after cancellation, fetched %v", site.fetches)
+	}
+}
+
+func TestIngest_RemovesStaleChunks(t *testing.T) {
+	t.Parallel()
+
+	var page atomic.Value
+	page.Store("aaaaaaaaaabbbbbbbbbbccccccccccdddddddddd")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, page.Load())
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{}
+	p, err := NewPipeline(fakeEmbedder{}, store, &Config{ChunkSize: 10, ChunkStrategy: ChunkStrategyFixed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := Source{URL: srv.URL + "/other"}
+	if err := p.Ingest(context.Background(), []Source{{URL: srv.URL}, other}, nil); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if len(store.docs) != 8 {
+		t.Fatalf("expected 4 chunks per source, got %d", len(store.docs))
+	}
+
+	// The page shrinks to two chunks; the other source is not re-ingested.
+	page.Store("aaaaaaaaaaBBBBBBBBBB")
+	var msgs []string
+	if err := p.Ingest(context.Background(), []Source{{URL: srv.URL}}, func(msg string) { msgs = append(msgs, msg) }); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if len(store.deleted) != 2 {
+		t.Errorf("expected the 2 chunks past the new end deleted, got %v", store.deleted)
+	}
+	if !slices.Contains(msgs, fmt.Sprintf("removed 2 stale chunks from %s", srv.URL)) {
+		t.Errorf("expected the removal reported, got %v", msgs)
+	}
+	found, _ := store.Search(context.Background(), nil, 100)
+	var contents []string
+	for _, d := range found {
+		if d.Source == srv.URL {
+			contents = append(contents, d.Content)
+		}
+	}
+	if fmt.Sprint(contents) != "[aaaaaaaaaa BBBBBBBBBB]" {
+		t.Errorf("expected only the new content searchable, got %v", contents)
+	}
+	if len(found) != 6 {
+		t.Errorf("expected the other source's 4 chunks kept, got %d docs in total", len(found))
+	}
+
+	// Re-ingesting unchanged content deletes nothing.
+	if err := p.Ingest(context.Background(), []Source{{URL: srv.URL}}, nil); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if len(store.deleted) != 2 {
+		t.Errorf("expected nothing more deleted, got %v", store.deleted)
+	}
+}
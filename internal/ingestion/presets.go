@@ -0,0 +1,145 @@
+package ingestion
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PresetUpgradeGuides is the preset name for the built-in provider
+// major-version upgrade guides.
+const PresetUpgradeGuides = "upgrade-guides"
+
+// UpgradeGuide describes the official upgrade guide for one provider major
+// version.
+type UpgradeGuide struct {
+	// Provider is the Terraform provider name as used in required_providers
+	// (e.g. "aws", "azurerm", "google").
+	Provider string
+	// Major is the target major version the guide describes upgrading to.
+	Major int
+	// URL is the registry page for the guide.
+	URL string
+}
+
+// upgradeGuides is the built-in list of provider upgrade guides. Keep it
+// sorted by provider, then major version.
+var upgradeGuides = []UpgradeGuide{
+	{Provider: "aws", Major: 4, URL: "https://registry.terraform.io/providers/hashicorp/aws/latest/docs/guides/version-4-upgrade"},
+	{Provider: "aws", Major: 5, URL: "https://registry.terraform.io/providers/hashicorp/aws/latest/docs/guides/version-5-upgrade"},
+	{Provider: "aws", Major: 6, URL: "https://registry.terraform.io/providers/hashicorp/aws/latest/docs/guides/version-6-upgrade"},
+	{Provider: "azurerm", Major: 3, URL: "https://registry.terraform.io/providers/hashicorp/azurerm/latest/docs/guides/3.0-upgrade-guide"},
+	{Provider: "azurerm", Major: 4, URL: "https://registry.terraform.io/providers/hashicorp/azurerm/latest/docs/guides/4.0-upgrade-guide"},
+	{Provider: "google", Major: 4, URL: "https://registry.terraform.io/providers/hashicorp/google/latest/docs/guides/version_4_upgrade"},
+	{Provider: "google", Major: 5, URL: "https://registry.terraform.io/providers/hashicorp/google/latest/docs/guides/version_5_upgrade"},
+	{Provider: "google", Major: 6, URL: "https://registry.terraform.io/providers/hashicorp/google/latest/docs/guides/version_6_upgrade"},
+}
+
+// presets maps a preset name built in code to the function that expands it
+// into sources. The other presets are the data files in presetFS.
+var presets = map[string]func() []Source{
+	PresetUpgradeGuides: upgradeGuideSources,
+}
+
+// presetFS holds the curated source lists, one presets/<name>.yaml file
+// per preset, so they can be updated without touching code. Each source
+// declares its metadata, which must agree with InferMetadata for its URL.
+//
+//go:embed presets/*.yaml
+var presetFS embed.FS
+
+// presetFile is the layout of a preset data file.
+type presetFile struct {
+	Sources []struct {
+		URL       string `yaml:"url"`
+		Provider  string `yaml:"provider"`
+		Framework string `yaml:"framework"`
+		DocType   string `yaml:"doc_type"`
+	} `yaml:"sources"`
+}
+
+// PresetNames returns the names accepted by ExpandPreset, sorted.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for n := range presets {
+		names = append(names, n)
+	}
+	files, _ := fs.Glob(presetFS, "presets/*.yaml")
+	for _, f := range files {
+		names = append(names, strings.TrimSuffix(path.Base(f), ".yaml"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExpandPreset returns the sources for the named preset, each with Preset
+// set to name so the ingested chunks record where they came from.
+func ExpandPreset(name string) ([]Source, error) {
+	var sources []Source
+	if expand, ok := presets[name]; ok {
+		sources = expand()
+	} else {
+		b, err := presetFS.ReadFile("presets/" + name + ".yaml")
+		if err != nil {
+			return nil, fmt.Errorf("ingestion: unknown preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+		}
+		if sources, err = parsePreset(b); err != nil {
+			return nil, fmt.Errorf("ingestion: preset %q: %w", name, err)
+		}
+	}
+	for i := range sources {
+		sources[i].Preset = name
+	}
+	return sources, nil
+}
+
+// parsePreset decodes a preset data file. Unknown fields, and sources
+// without a URL or metadata, are errors.
+func parsePreset(b []byte) ([]Source, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	var f presetFile
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("invalid preset file: %w", err)
+	}
+	sources := make([]Source, 0, len(f.Sources))
+	for i, s := range f.Sources {
+		if s.URL == "" || s.Provider == "" || s.Framework == "" || s.DocType == "" {
+			return nil, fmt.Errorf("source %d: url, provider, framework, and doc_type are required", i+1)
+		}
+		sources = append(sources, Source{URL: s.URL, Provider: s.Provider, Framework: s.Framework, DocType: s.DocType})
+	}
+	return sources, nil
+}
+
+// LookupUpgradeGuide returns the built-in upgrade guide for provider's major
+// version, if one is known.
+func LookupUpgradeGuide(provider string, major int) (UpgradeGuide, bool) {
+	for _, g := range upgradeGuides {
+		if g.Provider == provider && g.Major == major {
+			return g, true
+		}
+	}
+	return UpgradeGuide{}, false
+}
+
+// upgradeGuideSources expands PresetUpgradeGuides.
+func upgradeGuideSources() []Source {
+	sources := make([]Source, 0, len(upgradeGuides))
+	for _, g := range upgradeGuides {
+		m := InferMetadata(g.URL)
+		sources = append(sources, Source{
+			URL:       g.URL,
+			Provider:  m.Provider,
+			Framework: m.Framework,
+			DocType:   "guide",
+		})
+	}
+	return sources
+}
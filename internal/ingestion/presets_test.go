@@ -0,0 +1,138 @@
+package ingestion
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestExpandPreset_UpgradeGuides(t *testing.T) {
+	t.Parallel()
+
+	sources, err := ExpandPreset(PresetUpgradeGuides)
+	if err != nil {
+		t.Fatalf("ExpandPreset: %v", err)
+	}
+	if len(sources) != len(upgradeGuides) {
+		t.Fatalf("expected %d sources, got %d", len(upgradeGuides), len(sources))
+	}
+
+	byProvider := make(map[string]int)
+	for _, s := range sources {
+		if !strings.HasPrefix(s.URL, "https://registry.terraform.io/providers/hashicorp/") {
+			t.Errorf("unexpected URL %q", s.URL)
+		}
+		if s.Framework != "terraform" {
+			t.Errorf("%s: expected framework terraform, got %q", s.URL, s.Framework)
+		}
+		if s.DocType != "guide" {
+			t.Errorf("%s: expected doc type guide, got %q", s.URL, s.DocType)
+		}
+		byProvider[s.Provider]++
+	}
+	for _, p := range []string{"aws", "azure", "gcp"} {
+		if byProvider[p] == 0 {
+			t.Errorf("expected at least one %s guide, got %v", p, byProvider)
+		}
+	}
+}
+
+// TestPresetFiles checks every source of every preset: the URL parses as
+// an absolute https URL, appears once, and InferMetadata agrees with the
+// metadata the preset declares.
+func TestPresetFiles(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"aws-core", "azure-core", "gcp-core", "terraform-lang"} {
+		if !strings.Contains(strings.Join(PresetNames(), ","), name) {
+			t.Errorf("expected preset %s to be listed, got %v", name, PresetNames())
+		}
+	}
+	for _, name := range PresetNames() {
+		sources, err := ExpandPreset(name)
+		if err != nil {
+			t.Errorf("ExpandPreset(%s): %v", name, err)
+			continue
+		}
+		if len(sources) == 0 {
+			t.Errorf("%s: expected sources", name)
+		}
+		seen := make(map[string]bool, len(sources))
+		for _, s := range sources {
+			u, err := url.Parse(s.URL)
+			if err != nil || u.Scheme != "https" || u.Host == "" {
+				t.Errorf("%s: invalid URL %q (%v)", name, s.URL, err)
+			}
+			if seen[s.URL] {
+				t.Errorf("%s: duplicate URL %q", name, s.URL)
+			}
+			seen[s.URL] = true
+			if s.Preset != name {
+				t.Errorf("%s: expected Preset %q, got %q", s.URL, name, s.Preset)
+			}
+			m := InferMetadata(s.URL)
+			if m.Provider != s.Provider || m.Framework != s.Framework || m.DocType != s.DocType {
+				t.Errorf("%s: declared %s/%s/%s, inferred %s/%s/%s", s.URL,
+					s.Provider, s.Framework, s.DocType, m.Provider, m.Framework, m.DocType)
+			}
+		}
+	}
+}
+
+func TestParsePreset_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, doc := range []string{
+		"sources: [{url: https://example.com, provider: aws}]",
+		"sources: [{url: https://example.com, provider: aws, framework: terraform, doc_type: guide, extra: x}]",
+		"sources: {url: https://example.com}",
+	} {
+		if _, err := parsePreset([]byte(doc)); err == nil {
+			t.Errorf("parsePreset(%q): expected an error", doc)
+		}
+	}
+}
+
+func TestExpandPreset_Unknown(t *testing.T) {
+	t.Parallel()
+
+	_, err := ExpandPreset("nope")
+	if err == nil {
+		t.Fatal("expected error for unknown preset")
+	}
+	if !strings.Contains(err.Error(), PresetUpgradeGuides) {
+		t.Errorf("expected error to list available presets, got %q", err)
+	}
+}
+
+func TestLookupUpgradeGuide(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		provider string
+		major    int
+		wantOK   bool
+		wantPath string
+	}{
+		{name: "aws 5", provider: "aws", major: 5, wantOK: true, wantPath: "/aws/latest/docs/guides/version-5-upgrade"},
+		{name: "azurerm 4", provider: "azurerm", major: 4, wantOK: true, wantPath: "/azurerm/latest/docs/guides/4.0-upgrade-guide"},
+		{name: "google 6", provider: "google", major: 6, wantOK: true, wantPath: "/google/latest/docs/guides/version_6_upgrade"},
+		{name: "unknown version", provider: "aws", major: 2},
+		{name: "unknown provider", provider: "cloudflare", major: 4},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			g, ok := LookupUpgradeGuide(tc.provider, tc.major)
+			if ok != tc.wantOK {
+				t.Fatalf("expected ok=%v, got %v", tc.wantOK, ok)
+			}
+			if ok && !strings.HasSuffix(g.URL, tc.wantPath) {
+				t.Errorf("expected URL ending %q, got %q", tc.wantPath, g.URL)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package ingestion
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// chunkHash is the "chunk_hash" metadata of a chunk: the SHA-256 of its text
+// and the embedder it is embedded with, so a chunk stored by a different
+// embedder never has its vector reused.
+func chunkHash(embedderID, text string) string {
+	sum := sha256.Sum256([]byte(embedderID + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
+// storedChunks returns the chunks stored for source by an earlier ingestion.
+// Stores that cannot list embeddings (see rag.ChunkLister) return IDs only,
+// so every chunk is embedded again.
+func (p *Pipeline) storedChunks(ctx context.Context, source string) ([]rag.StoredChunk, error) {
+	if lister, ok := p.store.(rag.ChunkLister); ok {
+		return lister.ListChunks(ctx, source) //nolint:wrapcheck // wrapped by the caller
+	}
+	ids, err := p.store.ListBySource(ctx, source)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // wrapped by the caller
+	}
+	chunks := make([]rag.StoredChunk, len(ids))
+	for i, id := range ids {
+		chunks[i] = rag.StoredChunk{ID: id}
+	}
+	return chunks, nil
+}
+
+// reuseEmbeddings returns, parallel to ids and hashes, the stored embedding
+// of a chunk with the same hash, or nil for a chunk that has to be embedded.
+// shifted reports that more than half the chunks would reuse an embedding
+// stored under another ID, as when an insertion early in a page moves every
+// later chunk to a new index; no embedding is reused then, so a page whose
+// chunking drifted is embedded afresh rather than pieced together.
+func reuseEmbeddings(ids, hashes []string, stored []rag.StoredChunk) (embeddings [][]float32, shifted bool) {
+	byID := make(map[string]rag.StoredChunk, len(stored))
+	byHash := make(map[string]rag.StoredChunk, len(stored))
+	for _, c := range stored {
+		if c.Hash == "" || len(c.Embedding) == 0 {
+			continue
+		}
+		byID[c.ID] = c
+		byHash[c.Hash] = c
+	}
+	embeddings = make([][]float32, len(hashes))
+	moved := 0
+	for i, h := range hashes {
+		if c, ok := byID[ids[i]]; ok && c.Hash == h {
+			embeddings[i] = c.Embedding
+		} else if c, ok := byHash[h]; ok {
+			embeddings[i] = c.Embedding
+			moved++
+		}
+	}
+	if 2*moved > len(hashes) {
+		return make([][]float32, len(hashes)), true
+	}
+	return embeddings, false
+}
+
+// embedChunks returns the embeddings of texts, reusing the ones in reused
+// and embedding the rest in one call, and the number it embedded.
+func (p *Pipeline) embedChunks(ctx context.Context, texts []string, reused [][]float32) ([][]float32, int, error) {
+	var missing []string
+	var at []int
+	for i, e := range reused {
+		if e == nil {
+			missing = append(missing, texts[i])
+			at = append(at, i)
+		}
+	}
+	embeddings := append([][]float32(nil), reused...)
+	if len(missing) == 0 {
+		return embeddings, 0, nil
+	}
+	fresh, err := p.embedder.Embed(ctx, missing)
+	if err != nil {
+		return nil, 0, err //nolint:wrapcheck // wrapped by the caller
+	}
+	if len(fresh) != len(missing) {
+		return nil, 0, fmt.Errorf("embedder returned %d embeddings for %d chunks", len(fresh), len(missing))
+	}
+	for j, i := range at {
+		embeddings[i] = fresh[j]
+	}
+	return embeddings, len(missing), nil
+}
@@ -0,0 +1,309 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// ---------------------------------------------------------------------------
+// Chunk-level re-embedding
+// ---------------------------------------------------------------------------
+
+func Test_reuseEmbeddings(t *testing.T) {
+	t.Parallel()
+
+	vec := func(h string) []float32 { return []float32{float32(h[0])} }
+	stored := func(pairs ...string) []rag.StoredChunk {
+		var out []rag.StoredChunk
+		for i := 0; i < len(pairs); i += 2 {
+			out = append(out, rag.StoredChunk{ID: pairs[i], Hash: pairs[i+1], Embedding: vec(pairs[i+1])})
+		}
+		return out
+	}
+	cases := []struct {
+		name        string
+		ids, hashes []string
+		stored      []rag.StoredChunk
+		// want lists the hash whose embedding each chunk reuses, or "".
+		want        []string
+		wantShifted bool
+	}{
+		{
+			name: "unchanged", ids: []string{"0", "1", "2"}, hashes: []string{"a", "b", "c"},
+			stored: stored("0", "a", "1", "b", "2", "c"), want: []string{"a", "b", "c"},
+		},
+		{
+			name: "one changed", ids: []string{"0", "1", "2"}, hashes: []string{"a", "x", "c"},
+			stored: stored("0", "a", "1", "b", "2", "c"), want: []string{"a", "", "c"},
+		},
+		{
+			name: "appended", ids: []string{"0", "1", "2"}, hashes: []string{"a", "b", "c"},
+			stored: stored("0", "a", "1", "b"), want: []string{"a", "b", ""},
+		},
+		{
+			name: "half moved", ids: []string{"0", "1", "2", "3"}, hashes: []string{"a", "x", "b", "c"},
+			stored: stored("0", "a", "1", "b", "2", "c"), want: []string{"a", "", "b", "c"},
+		},
+		{
+			name: "most moved", ids: []string{"0", "1", "2", "3"}, hashes: []string{"x", "a", "b", "c"},
+			stored: stored("0", "a", "1", "b", "2", "c"), want: []string{"", "", "", ""}, wantShifted: true,
+		},
+		{
+			name: "repeated chunk", ids: []string{"0", "1"}, hashes: []string{"a", "a"},
+			stored: stored("0", "a", "1", "a"), want: []string{"a", "a"},
+		},
+		{
+			name: "stored without hashes", ids: []string{"0", "1"}, hashes: []string{"a", "b"},
+			stored: []rag.StoredChunk{{ID: "0"}, {ID: "1"}}, want: []string{"", ""},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, shifted := reuseEmbeddings(tc.ids, tc.hashes, tc.stored)
+			if shifted != tc.wantShifted {
+				t.Errorf("shifted = %v, want %v", shifted, tc.wantShifted)
+			}
+			for i, h := range tc.want {
+				var want []float32
+				if h != "" {
+					want = vec(h)
+				}
+				if !slices.Equal(got[i], want) {
+					t.Errorf("chunk %d: got %v, want %v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
+
+// textEmbedder embeds a text as its length and first byte, and records
+// every text it is asked to embed.
+type textEmbedder struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (e *textEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.texts = append(e.texts, texts...)
+	e.mu.Unlock()
+	out := make([][]float32, len(texts))
+	for i, s := range texts {
+		out[i] = textVector(s)
+	}
+	return out, nil
+}
+
+// textVector is the embedding textEmbedder returns for s.
+func textVector(s string) []float32 {
+	return []float32{float32(len(s)), float32(s[0])}
+}
+
+// take returns and forgets the texts embedded so far.
+func (e *textEmbedder) take() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	texts := e.texts
+	e.texts = nil
+	return texts
+}
+
+// vectorStore is a fakeStore that keeps embeddings and lists them like
+// QdrantStore.ListChunks.
+type vectorStore struct {
+	fakeStore
+	vectors sync.Map
+}
+
+func (s *vectorStore) Upsert(ctx context.Context, docs []rag.Document, embeddings [][]float32) error {
+	for i, d := range docs {
+		s.vectors.Store(d.ID, embeddings[i])
+	}
+	return s.fakeStore.Upsert(ctx, docs, embeddings)
+}
+
+func (s *vectorStore) ListChunks(_ context.Context, source string) ([]rag.StoredChunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var chunks []rag.StoredChunk
+	for _, d := range s.docs {
+		if d.Source == source {
+			v, _ := s.vectors.Load(d.ID)
+			chunks = append(chunks, rag.StoredChunk{ID: d.ID, Hash: d.Metadata["chunk_hash"], Embedding: v.([]float32)})
+		}
+	}
+	return chunks, nil
+}
+
+// checkStored fails unless the store holds exactly the chunks of page's
+// latest ingestion, in index order, each with the embedding of its text.
+func checkStored(t *testing.T, s *vectorStore, source string, want []string) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	got := make([]string, len(want))
+	n := 0
+	for _, d := range s.docs {
+		if d.Source != source {
+			continue
+		}
+		n++
+		var i int
+		if _, err := fmt.Sscan(d.Metadata["chunk_index"], &i); err != nil || i >= len(want) || d.ID != chunkID(source, i) {
+			t.Errorf("unexpected chunk %s at index %s", d.ID, d.Metadata["chunk_index"])
+			continue
+		}
+		got[i] = d.Content
+		if v, _ := s.vectors.Load(d.ID); !slices.Equal(v.([]float32), textVector(d.Content)) {
+			t.Errorf("chunk %d %q has the embedding of other text: %v", i, d.Content, v)
+		}
+	}
+	if n != len(want) || !slices.Equal(got, want) {
+		t.Errorf("want stored chunks %q, got %q", want, got)
+	}
+}
+
+// sections renders a page with one "## title" section per title.
+func sections(titles ...string) string {
+	var b strings.Builder
+	for _, title := range titles {
+		fmt.Fprintf(&b, "## %s\n\nAll about %s.\n\n", title, title)
+	}
+	return b.String()
+}
+
+func TestIngest_ReembedsOnlyChangedChunks(t *testing.T) {
+	t.Parallel()
+
+	var page atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, page.Load())
+	}))
+	defer srv.Close()
+
+	emb := &textEmbedder{}
+	store := &vectorStore{}
+	p, err := NewPipeline(emb, store, &Config{ChunkSize: 40, EmbedderID: "fake/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	ingest := func(content string) {
+		t.Helper()
+		page.Store(content)
+		msgs = nil
+		if err := p.Ingest(context.Background(), []Source{{URL: srv.URL}}, func(msg string) { msgs = append(msgs, msg) }); err != nil {
+			t.Fatalf("Ingest: %v", err)
+		}
+	}
+	want := func(titles ...string) []string {
+		var out []string
+		for _, title := range titles {
+			out = append(out, fmt.Sprintf("## %s\n\nAll about %s.", title, title))
+		}
+		return out
+	}
+
+	ingest(sections("alpha", "bravo", "delta", "gamma"))
+	if got := emb.take(); len(got) != 4 {
+		t.Fatalf("expected every chunk embedded on first ingestion, got %q", got)
+	}
+	checkStored(t, store, srv.URL, want("alpha", "bravo", "delta", "gamma"))
+
+	// One section changes: only its chunk is embedded.
+	ingest(sections("alpha", "BRAVO", "delta", "gamma"))
+	if got := emb.take(); !slices.Equal(got, want("BRAVO")) {
+		t.Errorf("expected only the changed chunk embedded, got %q", got)
+	}
+	if !slices.Contains(msgs, fmt.Sprintf("reused 3 unchanged chunk embeddings from %s, embedded 1", srv.URL)) {
+		t.Errorf("expected the savings reported, got %q", msgs)
+	}
+	checkStored(t, store, srv.URL, want("alpha", "BRAVO", "delta", "gamma"))
+
+	// Unchanged content calls the embedder not at all.
+	ingest(sections("alpha", "BRAVO", "delta", "gamma"))
+	if got := emb.take(); len(got) != 0 {
+		t.Errorf("expected no embedding for unchanged content, got %q", got)
+	}
+
+	// A section appended at the end moves nothing.
+	ingest(sections("alpha", "BRAVO", "delta", "gamma", "omega"))
+	if got := emb.take(); !slices.Equal(got, want("omega")) {
+		t.Errorf("expected only the new chunk embedded, got %q", got)
+	}
+	checkStored(t, store, srv.URL, want("alpha", "BRAVO", "delta", "gamma", "omega"))
+
+	// A section inserted first moves every later chunk to a new index:
+	// the page is embedded afresh.
+	ingest(sections("zero", "alpha", "BRAVO", "delta", "gamma", "omega"))
+	if got := emb.take(); len(got) != 6 {
+		t.Errorf("expected every chunk embedded after the boundaries shifted, got %q", got)
+	}
+	if !slices.Contains(msgs, fmt.Sprintf("chunk boundaries of %s shifted; embedding every chunk again", srv.URL)) {
+		t.Errorf("expected the shift reported, got %q", msgs)
+	}
+	checkStored(t, store, srv.URL, want("zero", "alpha", "BRAVO", "delta", "gamma", "omega"))
+
+	// Removing the first section again shifts everything back, and the
+	// chunk past the new end is deleted.
+	ingest(sections("alpha", "BRAVO", "delta", "gamma", "omega"))
+	if got := emb.take(); len(got) != 5 {
+		t.Errorf("expected every chunk embedded after the boundaries shifted, got %q", got)
+	}
+	checkStored(t, store, srv.URL, want("alpha", "BRAVO", "delta", "gamma", "omega"))
+
+	// A different embedder never reuses the stored vectors.
+	other, err := NewPipeline(emb, store, &Config{ChunkSize: 40, EmbedderID: "fake/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Ingest(context.Background(), []Source{{URL: srv.URL}}, nil); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if got := emb.take(); len(got) != 5 {
+		t.Errorf("expected every chunk embedded with another embedder, got %q", got)
+	}
+}
+
+func TestIngest_FixedChunksShiftedByInsertion(t *testing.T) {
+	t.Parallel()
+
+	var page atomic.Value
+	page.Store("aaaaaaaaaabbbbbbbbbbccccccccccdddddddddd")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprint(w, page.Load())
+	}))
+	defer srv.Close()
+
+	emb := &textEmbedder{}
+	store := &vectorStore{}
+	p, err := NewPipeline(emb, store, &Config{ChunkSize: 10, ChunkStrategy: ChunkStrategyFixed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Ingest(context.Background(), []Source{{URL: srv.URL}}, nil); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	emb.take()
+
+	// Three bytes inserted at the start move every fixed-size boundary, so
+	// no chunk's text survives and every one is embedded again.
+	page.Store("xyzaaaaaaaaaabbbbbbbbbbccccccccccdddddddddd")
+	if err := p.Ingest(context.Background(), []Source{{URL: srv.URL}}, nil); err != nil {
+		t.Fatalf("Ingest: %v", err)
+	}
+	if got := emb.take(); len(got) != 5 {
+		t.Errorf("expected all 5 chunks embedded, got %q", got)
+	}
+	checkStored(t, store, srv.URL, []string{"xyzaaaaaaa", "aaabbbbbbb", "bbbccccccc", "cccddddddd", "ddd"})
+}
@@ -0,0 +1,165 @@
+package ingestion
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultCheckpointEvery is how many pages Run processes between state
+// checkpoints when RunOptions.CheckpointEvery is zero.
+const DefaultCheckpointEvery = 25
+
+// RunOptions configures a checkpointed ingestion run.
+type RunOptions struct {
+	// StatePath is where the run state is checkpointed. Empty disables
+	// checkpointing; the state is then only updated in memory.
+	StatePath string
+	// CheckpointEvery is the number of pages processed between checkpoints.
+	// Defaults to DefaultCheckpointEvery if zero.
+	CheckpointEvery int
+	// Progress receives human-readable progress messages. May be nil.
+	Progress func(msg string)
+}
+
+// Report summarises a Run.
+type Report struct {
+	// New is the number of pages ingested by this run.
+	New int
+	// Skipped is the number of pages not ingested again: pages finished by
+	// an earlier run of the same state, and pages whose content was already
+	// ingested under another URL.
+	Skipped int
+	// Failed lists the pages that failed in this run. They stay in the
+	// state and are retried on resume.
+	Failed []PageState
+	// Chunks is the number of chunks stored for the pages counted in New.
+	Chunks int
+}
+
+// Run ingests every page in state's frontier that is not done yet, up to
+// Config.Concurrency at a time, recording each page's status and
+// checkpointing the state to opts.StatePath. Unlike Ingest, a page that fails
+// is recorded and the run continues. When ctx is cancelled the state is
+// checkpointed and ctx's error returned; pages in flight stay queued and are
+// fetched again on resume, while finished pages never are.
+func (p *Pipeline) Run(ctx context.Context, state *State, opts RunOptions) (*Report, error) {
+	progress := serialize(opts.Progress)
+	every := opts.CheckpointEvery
+	if every <= 0 {
+		every = DefaultCheckpointEvery
+	}
+	// mu guards state, report, and processed against the workers.
+	var mu sync.Mutex
+	checkpoint := func() error {
+		if opts.StatePath == "" {
+			return nil
+		}
+		return state.Save(opts.StatePath)
+	}
+
+	var pending []string
+	for _, pg := range state.Pages {
+		if pg.Status != StatusDone {
+			pending = append(pending, pg.Source.URL)
+		}
+	}
+	h := p.newHygiene(pending)
+
+	report := &Report{}
+	processed := 0
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(p.cfg.Concurrency)
+	for i := range state.Pages {
+		mu.Lock()
+		pg := &state.Pages[i]
+		done := pg.Status == StatusDone
+		if done {
+			report.Skipped++
+		}
+		mu.Unlock()
+		if done {
+			continue
+		}
+		if gctx.Err() != nil {
+			break
+		}
+
+		g.Go(func() error {
+			if gctx.Err() != nil {
+				// Cancelled while waiting for a free worker.
+				return nil
+			}
+			skipped, err := p.runPage(gctx, h, state, pg, &mu, progress)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil && gctx.Err() != nil:
+				// Interrupted, not failed: leave the page queued for resume.
+				return nil
+			case err != nil:
+				pg.Status, pg.Error = StatusFailed, err.Error()
+				report.Failed = append(report.Failed, *pg)
+				progress(fmt.Sprintf("failed %s: %v", pg.Source.URL, err))
+			case skipped:
+				report.Skipped++
+				progress(fmt.Sprintf("skipped %s: content already ingested", pg.Source.URL))
+			default:
+				report.New++
+				report.Chunks += pg.Chunks
+				progress(fmt.Sprintf("ingested %d chunks from %s", pg.Chunks, pg.Source.URL))
+			}
+
+			processed++
+			if processed%every == 0 {
+				return checkpoint()
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return report, err
+	}
+	if err := ctx.Err(); err != nil {
+		return report, errors.Join(err, checkpoint())
+	}
+	return report, checkpoint()
+}
+
+// runPage fetches and ingests one page, marking it done on success. It
+// reports skipped when the page's content was already ingested under
+// another URL, in which case nothing is embedded. mu guards state and pg;
+// it is not held while fetching or embedding.
+func (p *Pipeline) runPage(ctx context.Context, h *hygiene, state *State, pg *PageState, mu *sync.Mutex, progress func(string)) (skipped bool, err error) {
+	content, err := p.fetchPage(ctx, h, pg.Source.URL, progress)
+	if err != nil {
+		return false, fmt.Errorf("ingestion: fetch failed for %s: %w", pg.Source.URL, err)
+	}
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+
+	mu.Lock()
+	duplicate := state.hasContent(hash)
+	if duplicate {
+		pg.Status, pg.ContentHash, pg.Error = StatusDone, hash, ""
+	}
+	mu.Unlock()
+	if duplicate {
+		return true, nil
+	}
+
+	n, err := p.ingestContent(ctx, h, pg.Source, content, progress)
+	if err != nil {
+		return false, err
+	}
+	mu.Lock()
+	pg.Status, pg.ContentHash, pg.Chunks, pg.Error = StatusDone, hash, n, ""
+	mu.Unlock()
+	return false, nil
+}
@@ -0,0 +1,262 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/rag"
+)
+
+// ---------------------------------------------------------------------------
+// Fakes
+// ---------------------------------------------------------------------------
+
+// fakeEmbedder returns a one-dimensional vector per text.
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = []float32{float32(i)}
+	}
+	return out, nil
+}
+
+// fakeStore records the sources and documents upserted and calls onUpsert
+// after each source. Documents are replaced by ID like in a real store, and
+// Search returns every stored document.
+type fakeStore struct {
+	mu       sync.Mutex
+	sources  []string
+	docs     []rag.Document
+	deleted  []string
+	onUpsert func(n int)
+}
+
+func (s *fakeStore) Upsert(_ context.Context, docs []rag.Document, _ [][]float32) error {
+	s.mu.Lock()
+	s.sources = append(s.sources, docs[0].Source)
+	for _, d := range docs {
+		if i := s.index(d.ID); i >= 0 {
+			s.docs[i] = d
+		} else {
+			s.docs = append(s.docs, d)
+		}
+	}
+	n := len(s.sources)
+	s.mu.Unlock()
+	if s.onUpsert != nil {
+		s.onUpsert(n)
+	}
+	return nil
+}
+
+// index returns the position of the document with id, or -1. s.mu must be
+// held.
+func (s *fakeStore) index(id string) int {
+	for i, d := range s.docs {
+		if d.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *fakeStore) Search(ctx context.Context, q []float32, topK int) ([]rag.Document, error) {
+	return s.SearchWithFilter(ctx, q, topK, rag.SearchFilter{})
+}
+
+func (s *fakeStore) SearchWithFilter(context.Context, []float32, int, rag.SearchFilter) ([]rag.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]rag.Document(nil), s.docs...), nil
+}
+
+func (s *fakeStore) Delete(_ context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if i := s.index(id); i >= 0 {
+			s.docs = append(s.docs[:i], s.docs[i+1:]...)
+		}
+	}
+	s.deleted = append(s.deleted, ids...)
+	return nil
+}
+
+func (s *fakeStore) ListBySource(_ context.Context, source string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var ids []string
+	for _, d := range s.docs {
+		if d.Source == source {
+			ids = append(ids, d.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (s *fakeStore) Close() error { return nil }
+
+// fakeSite serves /page/1 … /page/10 and counts fetches per path. /page/7
+// always fails and /page/10 has the same content as /page/1.
+type fakeSite struct {
+	mu      sync.Mutex
+	fetches map[string]int
+}
+
+func (f *fakeSite) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.fetches[r.URL.Path]++
+	f.mu.Unlock()
+	switch r.URL.Path {
+	case "/page/7":
+		http.Error(w, "boom", http.StatusInternalServerError)
+	case "/page/10":
+		_, _ = fmt.Fprint(w, "content of page 1")
+	default:
+		_, _ = fmt.Fprintf(w, "content of page %s", r.URL.Path[len("/page/"):])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Run / resume
+// ---------------------------------------------------------------------------
+
+func TestRun_InterruptAndResume(t *testing.T) {
+	t.Parallel()
+
+	site := &fakeSite{fetches: map[string]int{}}
+	srv := httptest.NewServer(site)
+	defer srv.Close()
+
+	statePath := filepath.Join(t.TempDir(), "ingest-state.json")
+	// Sequential, so no page is in flight when the run is cancelled.
+	cfg := &Config{EmbedderID: "fake/model", Concurrency: 1}
+
+	// First run: cancelled once the fifth page is stored.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &fakeStore{onUpsert: func(n int) {
+		if n == 5 {
+			cancel()
+		}
+	}}
+	p, err := NewPipeline(fakeEmbedder{}, store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := NewState(p.Fingerprint())
+	for i := 1; i <= 10; i++ {
+		state.Enqueue(Source{URL: fmt.Sprintf("%s/page/%d", srv.URL, i), Provider: "aws"})
+	}
+	if state.Enqueue(Source{URL: srv.URL + "/page/1"}) {
+		t.Error("expected a duplicate URL not to be queued twice")
+	}
+
+	report, err := p.Run(ctx, state, RunOptions{StatePath: statePath, CheckpointEvery: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if report.New != 5 || report.Skipped != 0 || len(report.Failed) != 0 {
+		t.Errorf("first run: unexpected report %+v", report)
+	}
+
+	// Second run: resumed from the checkpoint with a fresh pipeline.
+	store2 := &fakeStore{}
+	p2, err := NewPipeline(fakeEmbedder{}, store2, &Config{EmbedderID: "fake/model"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := LoadState(statePath, p2.Fingerprint())
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	report, err = p2.Run(context.Background(), resumed, RunOptions{StatePath: statePath})
+	if err != nil {
+		t.Fatalf("resumed Run: %v", err)
+	}
+
+	// Pages 1-5 skipped as resumed, 10 skipped as a duplicate of 1, 7 failed.
+	if report.New != 3 || report.Skipped != 6 || len(report.Failed) != 1 {
+		t.Errorf("resumed run: unexpected report %+v", report)
+	}
+	if report.Chunks != len(store2.docs) {
+		t.Errorf("expected %d chunks reported, got %d", len(store2.docs), report.Chunks)
+	}
+	if len(report.Failed) == 1 && report.Failed[0].Source.URL != srv.URL+"/page/7" {
+		t.Errorf("expected page 7 to fail, got %+v", report.Failed[0])
+	}
+	for i := 1; i <= 10; i++ {
+		if n := site.fetches[fmt.Sprintf("/page/%d", i)]; n != 1 {
+			t.Errorf("/page/%d fetched %d times, want 1", i, n)
+		}
+	}
+	if len(store.sources)+len(store2.sources) != 8 {
+		t.Errorf("expected 8 pages stored across both runs, got %v and %v", store.sources, store2.sources)
+	}
+
+	// The final checkpoint keeps the failure for the next resume.
+	final, err := LoadState(statePath, p2.Fingerprint())
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if pg := final.Pages[6]; pg.Status != StatusFailed || pg.Error == "" || pg.Source.Provider != "aws" {
+		t.Errorf("expected page 7 recorded as failed with its metadata, got %+v", pg)
+	}
+}
+
+func TestLoadState_RejectsIncompatible(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewPipeline(fakeEmbedder{}, &fakeStore{}, &Config{ChunkSize: 500, EmbedderID: "ollama/nomic-embed-text"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := NewState(p.Fingerprint()).Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{name: "chunk size", cfg: &Config{ChunkSize: 1000, EmbedderID: "ollama/nomic-embed-text"}},
+		{name: "chunk overlap", cfg: &Config{ChunkSize: 500, ChunkOverlap: 10, EmbedderID: "ollama/nomic-embed-text"}},
+		{name: "embedder", cfg: &Config{ChunkSize: 500, EmbedderID: "openai/text-embedding-3-small"}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			other, err := NewPipeline(fakeEmbedder{}, &fakeStore{}, tc.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadState(path, other.Fingerprint()); !errors.Is(err, ErrIncompatibleState) {
+				t.Errorf("expected ErrIncompatibleState, got %v", err)
+			}
+		})
+	}
+
+	if _, err := LoadState(path, p.Fingerprint()); err != nil {
+		t.Errorf("expected the matching fingerprint to load, got %v", err)
+	}
+
+	future := filepath.Join(t.TempDir(), "future.json")
+	s := NewState(p.Fingerprint())
+	s.Version = StateVersion + 1
+	if err := s.Save(future); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadState(future, p.Fingerprint()); !errors.Is(err, ErrIncompatibleState) {
+		t.Errorf("expected ErrIncompatibleState for a future version, got %v", err)
+	}
+}
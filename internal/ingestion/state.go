@@ -0,0 +1,148 @@
+package ingestion
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// StateVersion is the current run state file format version. LoadState
+// rejects files written with any other version.
+const StateVersion = 1
+
+// ErrIncompatibleState is returned by LoadState when a state file was written
+// by a pipeline with a different chunking or embedding configuration, or in
+// a different file format. Resuming it would mix incompatible chunks in the
+// vector store.
+var ErrIncompatibleState = errors.New("ingestion: state file is incompatible with this configuration")
+
+// PageStatus is the progress of one page in a run.
+type PageStatus string
+
+const (
+	// StatusQueued pages have not been ingested yet.
+	StatusQueued PageStatus = "queued"
+	// StatusDone pages were ingested, or skipped because their content was
+	// already ingested under another URL.
+	StatusDone PageStatus = "done"
+	// StatusFailed pages could not be fetched, embedded, or stored. They are
+	// retried when the run is resumed.
+	StatusFailed PageStatus = "failed"
+)
+
+// PageState is the progress of one page in a run.
+type PageState struct {
+	// Source is the page and the metadata it is ingested with.
+	Source Source `json:"source"`
+	// Status is the page's progress.
+	Status PageStatus `json:"status"`
+	// ContentHash is the SHA-256 of the fetched text, set once the page is done.
+	ContentHash string `json:"content_hash,omitempty"`
+	// Chunks is the number of chunks stored for the page.
+	Chunks int `json:"chunks,omitempty"`
+	// Error describes the last failure of a failed page.
+	Error string `json:"error,omitempty"`
+}
+
+// State is the checkpointed frontier of an ingestion run: every page queued
+// so far, in order, with its status. It is written to disk by Pipeline.Run so
+// an interrupted run can be resumed without fetching finished pages again.
+type State struct {
+	// Version is the file format version (StateVersion).
+	Version int `json:"version"`
+	// Fingerprint identifies the chunking and embedding configuration the
+	// pages were ingested with (see Pipeline.Fingerprint).
+	Fingerprint string `json:"fingerprint"`
+	// Pages are in the order they were queued.
+	Pages []PageState `json:"pages"`
+
+	// index maps a URL to its position in Pages.
+	index map[string]int
+}
+
+// NewState returns an empty state for a pipeline with the given fingerprint.
+func NewState(fingerprint string) *State {
+	return &State{Version: StateVersion, Fingerprint: fingerprint, index: map[string]int{}}
+}
+
+// LoadState reads the state file at path. It returns ErrIncompatibleState
+// when the file's version or fingerprint does not match.
+func LoadState(path, fingerprint string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ingestion: failed to read state: %w", err)
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("ingestion: failed to parse state %s: %w", path, err)
+	}
+	if s.Version != StateVersion {
+		return nil, fmt.Errorf("%w: %s has version %d, want %d", ErrIncompatibleState, path, s.Version, StateVersion)
+	}
+	if s.Fingerprint != fingerprint {
+		return nil, fmt.Errorf("%w: %s was written with different chunking or embedding settings", ErrIncompatibleState, path)
+	}
+	s.index = make(map[string]int, len(s.Pages))
+	for i, pg := range s.Pages {
+		s.index[pg.Source.URL] = i
+	}
+	return &s, nil
+}
+
+// Save writes the state to path atomically, so a run killed mid-write still
+// leaves the previous checkpoint intact.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ingestion: failed to encode state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tfai-ingest-*")
+	if err != nil {
+		return fmt.Errorf("ingestion: failed to write state: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("ingestion: failed to write state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("ingestion: failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("ingestion: failed to write state: %w", err)
+	}
+	return nil
+}
+
+// Enqueue adds src to the frontier unless its URL is already known, and
+// reports whether it was added.
+func (s *State) Enqueue(src Source) bool {
+	if _, ok := s.index[src.URL]; ok {
+		return false
+	}
+	s.index[src.URL] = len(s.Pages)
+	s.Pages = append(s.Pages, PageState{Source: src, Status: StatusQueued})
+	return true
+}
+
+// hasContent reports whether a done page already has the given content hash.
+func (s *State) hasContent(hash string) bool {
+	for _, pg := range s.Pages {
+		if pg.Status == StatusDone && pg.ContentHash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// Fingerprint identifies the settings that determine how pages are chunked
+// and embedded. Resuming a run is only safe when it matches.
+func (p *Pipeline) Fingerprint() string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("chunk_size=%d chunk_overlap=%d chunk_strategy=%s embedder=%s",
+		p.cfg.ChunkSize, p.cfg.ChunkOverlap, p.cfg.ChunkStrategy, p.cfg.EmbedderID)))
+	return hex.EncodeToString(h[:8])
+}
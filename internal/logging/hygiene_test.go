@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hygieneAllowlist lists the repo-relative files that may call the
+// functions TestContextHygiene flags.
+var hygieneAllowlist = map[string]bool{
+	// FromContext falls back to slog.Default when ctx carries no logger.
+	"internal/logging/logging.go": true,
+}
+
+// slogPackageLoggers lists the package-level slog functions that log
+// through the default logger rather than the request's.
+var slogPackageLoggers = map[string]bool{
+	"Default": true,
+	"Debug":   true, "DebugContext": true,
+	"Info": true, "InfoContext": true,
+	"Warn": true, "WarnContext": true,
+	"Error": true, "ErrorContext": true,
+	"Log": true, "LogAttrs": true,
+}
+
+// TestContextHygiene scans the repo's non-test sources for calls that lose
+// the request-scoped logger: context.TODO and the slog package-level
+// functions. Code that logs should take a ctx and use FromContext, so its
+// lines carry the request and trace IDs the server middleware attaches.
+func TestContextHygiene(t *testing.T) {
+	t.Parallel()
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatalf("resolve repo root: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name := d.Name(); path != root && (strings.HasPrefix(name, ".") || name == "vendor" || name == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if hygieneAllowlist[rel] {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			pkg, ok := sel.X.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			switch {
+			case pkg.Name == "context" && sel.Sel.Name == "TODO",
+				pkg.Name == "slog" && slogPackageLoggers[sel.Sel.Name]:
+				t.Errorf("%s:%d: %s.%s loses the request-scoped logger; take a ctx and use logging.FromContext",
+					rel, fset.Position(call.Pos()).Line, pkg.Name, sel.Sel.Name)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("scan sources: %v", err)
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+
+	"github.com/54b3r/tfai-go/internal/timefmt"
 )
 
 // contextKey is an unexported type for context keys in this package.
@@ -27,7 +29,7 @@ type contextKey struct{}
 func New() *slog.Logger {
 	level := parseLevel(os.Getenv("LOG_LEVEL"))
 
-	opts := &slog.HandlerOptions{Level: level}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: timefmt.ReplaceAttr}
 
 	var handler slog.Handler
 	if strings.ToLower(os.Getenv("LOG_FORMAT")) == "text" {
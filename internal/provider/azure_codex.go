@@ -12,8 +12,14 @@ import (
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
+
+	"github.com/54b3r/tfai-go/internal/httpx"
+	"github.com/54b3r/tfai-go/internal/logging"
 )
 
+// codexHTTPTimeout is the HTTP client timeout of each Codex request.
+const codexHTTPTimeout = 5 * time.Minute
+
 // azureCodexClient implements model.ToolCallingChatModel for GPT-5.2-Codex via raw HTTP.
 // This uses the /openai/responses endpoint with Bearer auth instead of the standard
 // Azure OpenAI chat completions endpoint.
@@ -109,7 +115,7 @@ type codexError struct {
 // This uses raw HTTP since SDKs don't yet support the /openai/responses endpoint.
 // It reuses the AzureOpenAI config fields (APIKey, Endpoint, APIVersion) and adds
 // CodexModel for the model name.
-func newAzureCodex(_ context.Context, cfg *Config) (model.ToolCallingChatModel, error) {
+func newAzureCodex(ctx context.Context, cfg *Config) (model.ToolCallingChatModel, error) {
 	modelName := cfg.AzureOpenAI.Codex.Model
 	if modelName == "" {
 		modelName = "gpt-5.2-codex"
@@ -119,7 +125,7 @@ func newAzureCodex(_ context.Context, cfg *Config) (model.ToolCallingChatModel,
 		apiVersion = "2025-04-01-preview"
 	}
 
-	slog.Info("azure codex mode enabled",
+	logging.FromContext(ctx).Info("azure codex mode enabled",
 		slog.String("model", modelName),
 		slog.String("endpoint", cfg.AzureOpenAI.Endpoint),
 		slog.String("api_version", apiVersion),
@@ -131,9 +137,7 @@ func newAzureCodex(_ context.Context, cfg *Config) (model.ToolCallingChatModel,
 		apiVersion:        apiVersion,
 		modelName:         modelName,
 		maxCompletionToks: cfg.Tuning.MaxTokens,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Minute,
-		},
+		httpClient:        httpx.NewClient(httpx.Options{Component: "provider", Timeout: codexHTTPTimeout}),
 	}, nil
 }
 
@@ -190,6 +194,11 @@ func (c *azureCodexClient) Generate(ctx context.Context, messages []*schema.Mess
 	if options.ToolChoice != nil {
 		req.ToolChoice = options.ToolChoice
 	}
+	// Codex reasoning models reject temperature, so only the token cap is
+	// taken from the per-call options.
+	if options.MaxTokens != nil {
+		req.MaxCompletionTokens = *options.MaxTokens
+	}
 
 	resp, err := c.doRequest(ctx, req)
 	if err != nil {
@@ -3,6 +3,7 @@ package provider
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestConfigValidate(t *testing.T) {
@@ -214,6 +215,17 @@ func TestConfigValidate(t *testing.T) {
 			wantErr: "GEMINI_MODEL",
 		},
 
+		// ── Replay ────────────────────────────────────────────────────────────
+		{
+			name: "replay/valid",
+			cfg:  Config{Backend: BackendReplay, Replay: ProviderReplay{File: "session.json"}},
+		},
+		{
+			name:    "replay/missing file",
+			cfg:     Config{Backend: BackendReplay},
+			wantErr: "TFAI_REPLAY_FILE",
+		},
+
 		// ── Unknown backend ───────────────────────────────────────────────────
 		{
 			name:    "unknown backend",
@@ -283,6 +295,65 @@ func TestIsCodexEnabled(t *testing.T) {
 	}
 }
 
+func TestModelName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{name: "ollama", cfg: Config{Backend: BackendOllama, Ollama: ProviderOllama{Model: "llama3"}}, want: "llama3"},
+		{name: "openai", cfg: Config{Backend: BackendOpenAI, OpenAI: ProviderOpenAI{Model: "gpt-4o"}}, want: "gpt-4o"},
+		{name: "azure deployment", cfg: Config{Backend: BackendAzure, AzureOpenAI: ProviderAzureOpenAI{Deployment: "prod-gpt4o"}}, want: "prod-gpt4o"},
+		{
+			name: "azure codex",
+			cfg:  Config{Backend: BackendAzure, AzureOpenAI: ProviderAzureOpenAI{Deployment: "prod-gpt4o", Codex: &Codex{Enabled: true, Model: "gpt-5.2-codex"}}},
+			want: "gpt-5.2-codex",
+		},
+		{name: "bedrock", cfg: Config{Backend: BackendBedrock, Bedrock: ProviderBedrock{ModelID: "anthropic.claude-v2"}}, want: "anthropic.claude-v2"},
+		{name: "gemini", cfg: Config{Backend: BackendGemini, Gemini: ProviderGemini{Model: "gemini-1.5-pro"}}, want: "gemini-1.5-pro"},
+		{name: "replay", cfg: Config{Backend: BackendReplay, Replay: ProviderReplay{File: "session.json"}}, want: "replay"},
+		{name: "unknown", cfg: Config{Backend: "nope"}, want: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tc.cfg.ModelName(); got != tc.want {
+				t.Errorf("ModelName() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHTTPTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want time.Duration
+	}{
+		{name: "openai has no client timeout", cfg: Config{Backend: BackendOpenAI}, want: 0},
+		{name: "azure deployment", cfg: Config{Backend: BackendAzure, AzureOpenAI: ProviderAzureOpenAI{Deployment: "prod-gpt4o"}}, want: 0},
+		{
+			name: "azure codex",
+			cfg:  Config{Backend: BackendAzure, AzureOpenAI: ProviderAzureOpenAI{Codex: &Codex{Enabled: true}}},
+			want: codexHTTPTimeout,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tc.cfg.HTTPTimeout(); got != tc.want {
+				t.Errorf("HTTPTimeout() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestCodexConstants(t *testing.T) {
 	t.Parallel()
 
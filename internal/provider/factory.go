@@ -3,11 +3,13 @@ package provider
 import (
 	"context"
 	"fmt"
-	"log/slog"
 	"os"
 	"strconv"
 
 	"github.com/cloudwego/eino/components/model"
+
+	"github.com/54b3r/tfai-go/internal/deprecation"
+	"github.com/54b3r/tfai-go/internal/logging"
 )
 
 // NewFromEnv constructs a ChatModel by reading provider configuration from
@@ -16,7 +18,7 @@ import (
 //
 // Environment variables:
 //
-//	MODEL_PROVIDER              = ollama | openai | azure | bedrock | gemini (default: ollama)
+//	MODEL_PROVIDER              = ollama | openai | azure | bedrock | gemini | replay (default: ollama)
 //
 //	Ollama:  OLLAMA_HOST (default: http://localhost:11434), OLLAMA_MODEL (default: llama3)
 //	OpenAI:  OPENAI_API_KEY, OPENAI_MODEL (default: gpt-4o)
@@ -25,6 +27,10 @@ import (
 //	Bedrock: AWS credential chain (AWS_PROFILE / AWS_ACCESS_KEY_ID+AWS_SECRET_ACCESS_KEY /
 //	         instance profile), AWS_REGION (default: us-east-1), BEDROCK_MODEL_ID
 //	Gemini:  GOOGLE_API_KEY, GEMINI_MODEL (default: gemini-1.5-pro)
+//	Replay:  TFAI_REPLAY_FILE, TFAI_REPLAY_STRICT (default: true)
+//
+//	Recording: TFAI_RECORD_FILE wraps any other backend and writes every call
+//	           to a script that the replay backend can play back.
 //
 //	Shared:  MODEL_MAX_TOKENS (default: 4096), MODEL_TEMPERATURE (default: 0.2)
 
@@ -49,7 +55,7 @@ func NewFromEnv(ctx context.Context) (*ModelCfg, error) {
 	// If cfg.Generate is present and the generate backend does not match the config backend (different model providers)
 	// we will override the generate values
 	if cfg.Generate != nil && cfg.Generate.Backend != cfg.Backend {
-		genCfg = cfg.WithGenerateOverrides()
+		genCfg = cfg.WithGenerateOverrides(ctx)
 		genModel, err = New(ctx, genCfg)
 		if err != nil {
 			return mc, fmt.Errorf("generate: failed to initialize generation model provider: %w", err)
@@ -91,8 +97,8 @@ func ConfigFromEnv() *Config {
 			Backend:    defaultGenBackend,                                                 // Backend Confiuration
 			Deployment: os.Getenv("AZURE_OPENAI_DEPLOYMENT"),                              // Azure OpenAI Extracted Value
 			Version:    getEnvOrDefault("AZURE_OPENAI_API_VERSION", "2025-04-01-preview"), // Azure OpenAI Extracted Value
-			Model:      os.Getenv("GENERATE_MODEL"),                                       // OpenAI/Ollama/Gemini Extracted Value
-			ModelID:    os.Getenv("GENERATE_MODEL_ID"),                                    // Bedrock Extracted Value
+			Model:      os.Getenv("GENERATE_MODEL"),                                       // Every provider's Extracted Value
+			ModelID:    os.Getenv("GENERATE_MODEL_ID"),                                    // Deprecated Bedrock Extracted Value
 		},
 		AzureOpenAI: ProviderAzureOpenAI{
 			APIKey:            os.Getenv("AZURE_OPENAI_API_KEY"),
@@ -125,6 +131,11 @@ func ConfigFromEnv() *Config {
 			Host:  getEnvOrDefault("OLLAMA_HOST", "http://localhost:11434"),
 			Model: getEnvOrDefault("OLLAMA_MODEL", "llama3"),
 		},
+		Replay: ProviderReplay{
+			File:   os.Getenv("TFAI_REPLAY_FILE"),
+			Strict: os.Getenv("TFAI_REPLAY_STRICT") != "false",
+		},
+		RecordFile: os.Getenv("TFAI_RECORD_FILE"),
 		Tuning: SharedTuning{
 			MaxTokens:   getEnvInt("MODEL_MAX_TOKENS", 4096),
 			Temperature: getEnvFloat32("MODEL_TEMPERATURE", 0.2),
@@ -133,15 +144,16 @@ func ConfigFromEnv() *Config {
 	return cfg
 }
 
-func (c *Config) WithGenerateOverrides() *Config {
+func (c *Config) WithGenerateOverrides(ctx context.Context) *Config {
+	log := logging.FromContext(ctx)
 	// Tells us if the operator is explicityly wanting to override the generate model provider
 	// ie, we do NOT want to use the same chat model for code generation
 
-	genBackend := os.Getenv("GENERATE_MODEL_PROVIDER")      // Override the default configured code generation model
-	genDeployment := os.Getenv("GENERATE_AZURE_DEPLOYMENT") // Use different model deployed in Azure OpenAI/Foundry
-	genVersion := os.Getenv("GENERATE_AZURE_VERSION")       // Use a different API Version for an Azure OpenAI Deployment
-	genModelName := os.Getenv("GENERATE_MODEL")             // Use a different model for OpenAI/Ollama/Gemini providers
-	genModelID := os.Getenv("GENERATE_MODEL_ID")            // Use a different modelBedrock
+	genBackend := os.Getenv("GENERATE_MODEL_PROVIDER")                 // Override the default configured code generation model
+	genDeployment := os.Getenv("GENERATE_AZURE_DEPLOYMENT")            // Use different model deployed in Azure OpenAI/Foundry
+	genVersion := os.Getenv("GENERATE_AZURE_VERSION")                  // Use a different API Version for an Azure OpenAI Deployment
+	genModelName := os.Getenv("GENERATE_MODEL")                        // Use a different model for any provider but Azure
+	genModelID := deprecation.Getenv(log, deprecation.GenerateModelID) // Deprecated: Bedrock only, use GENERATE_MODEL
 
 	// If no override values are extracted, noOverrideSet will be true.
 	// This in combo with the empty backend extract will just return the original config object.
@@ -149,7 +161,7 @@ func (c *Config) WithGenerateOverrides() *Config {
 
 	// Delete this will never be nil - we always set sain defaults
 	if genBackend == "" && noOverrideSet {
-		slog.Info("WithGenerate: No Overrides values have been set, if you are intending to override the generate models please set and retry")
+		log.Info("WithGenerate: No Overrides values have been set, if you are intending to override the generate models please set and retry")
 		return c // no overrides configured, return original
 	}
 
@@ -159,10 +171,10 @@ func (c *Config) WithGenerateOverrides() *Config {
 	// Check if Backends match, Override backend if specified
 	// this should always be true - need to revalidate the code to make sure we cant just put it top level
 	if c.Generate.Backend != "" {
-		slog.Info("Generate model override not set") // Might be too verbose?
+		log.Info("Generate model override not set") // Might be too verbose?
 		if genBackend == "" {
 			if c.Backend == c.Generate.Backend {
-				slog.Info("Provider backends match, using " + string(c.Backend) + " provider.\nIf overriding other generate values, ensure you are setting the appropriate environment/yaml variables for configuration")
+				log.Info("Provider backends match, using " + string(c.Backend) + " provider.\nIf overriding other generate values, ensure you are setting the appropriate environment/yaml variables for configuration")
 			}
 		}
 		modified.Backend = c.Generate.Backend
@@ -190,7 +202,9 @@ func (c *Config) WithGenerateOverrides() *Config {
 			modified.Gemini.Model = genModelName
 		}
 	case BackendBedrock:
-		if genModelID != "" {
+		if genModelName != "" {
+			modified.Bedrock.ModelID = genModelName
+		} else if genModelID != "" {
 			modified.Bedrock.ModelID = genModelID
 		}
 	}
@@ -201,7 +215,21 @@ func (c *Config) WithGenerateOverrides() *Config {
 // New constructs a ChatModel from an explicit Config, delegating to the
 // appropriate backend factory function. It validates the config first so
 // callers get a clear error at startup rather than on the first request.
+// When cfg.RecordFile is set the model is wrapped in a recorder. Models of
+// backends with native JSON output implement JSONModeModel.
 func New(ctx context.Context, cfg *Config) (model.ToolCallingChatModel, error) {
+	m, err := newBackend(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RecordFile != "" && cfg.Backend != BackendReplay {
+		m = NewRecorder(m, cfg.RecordFile)
+	}
+	return withJSONMode(m, cfg), nil
+}
+
+// newBackend validates cfg and constructs the selected backend's model.
+func newBackend(ctx context.Context, cfg *Config) (model.ToolCallingChatModel, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
@@ -216,8 +244,10 @@ func New(ctx context.Context, cfg *Config) (model.ToolCallingChatModel, error) {
 		return newBedrock(ctx, cfg)
 	case BackendGemini:
 		return newGemini(ctx, cfg)
+	case BackendReplay:
+		return newReplay(ctx, cfg)
 	default:
-		return nil, fmt.Errorf("provider: unknown backend %q — valid values: ollama, openai, azure, bedrock, gemini", cfg.Backend)
+		return nil, fmt.Errorf("provider: unknown backend %q — valid values: ollama, openai, azure, bedrock, gemini, replay", cfg.Backend)
 	}
 }
 
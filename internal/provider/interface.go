@@ -7,9 +7,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/cloudwego/eino/components/model"
+
+	"github.com/54b3r/tfai-go/internal/httpx"
 )
 
 /*
@@ -32,6 +36,7 @@ const (
 	BackendAzure   Backend = "azure"   // BackendAzure selects Azure OpenAI Service.
 	BackendBedrock Backend = "bedrock" // BackendBedrock selects AWS Bedrock.
 	BackendGemini  Backend = "gemini"  // BackendGemini selects Google Gemini via Vertex AI or AI Studio.
+	BackendReplay  Backend = "replay"  // BackendReplay plays back a recorded script; see Script.
 )
 
 // ProviderAzureOpenAI holds configuration for Azure OpenAI Service.
@@ -89,6 +94,15 @@ type ProviderOllama struct {
 	Model string
 }
 
+// ProviderReplay holds configuration for the replay backend.
+type ProviderReplay struct {
+	// File is the recorded script to play back (TFAI_REPLAY_FILE).
+	File string
+	// Strict fails any call whose input differs from the next recorded turn
+	// (TFAI_REPLAY_STRICT, default true).
+	Strict bool
+}
+
 // SharedTuning holds generation parameters shared across all backends.
 type SharedTuning struct {
 	// MaxTokens caps the number of tokens the model may generate per response.
@@ -108,6 +122,8 @@ type Config struct {
 	Gemini      ProviderGemini      // Gemini holds config for Google Gemini (AI Studio or Vertex AI).
 	OpenAI      ProviderOpenAI      // OpenAI holds config for the OpenAI API.
 	Ollama      ProviderOllama      // Ollama holds config for a locally running Ollama instance.
+	Replay      ProviderReplay      // Replay holds config for the replay backend.
+	RecordFile  string              // RecordFile, when set, records every call of a real backend to this script (TFAI_RECORD_FILE).
 	Tuning      SharedTuning        // Tuning holds shared generation parameters applied to all backends.
 }
 
@@ -153,6 +169,12 @@ func (h *healthCheckCfg) GetURL() string                        { return h.url }
 func (h *healthCheckCfg) GetProviderType() Backend              { return h.providerType }
 func (h *healthCheckCfg) HealthCheck(ctx context.Context) error { return h.check(ctx, h.url, h.apiKey) }
 
+// healthClient is shared by every health check, so repeated readiness probes
+// reuse its keep-alive connections instead of each leaving one idle behind.
+var healthClient = sync.OnceValue(func() *http.Client {
+	return httpx.NewClient(httpx.Options{Component: "health", Timeout: 5 * time.Second})
+})
+
 // doHealthGet sends a GET request and returns nil on 2xx, error otherwise.
 func doHealthGet(ctx context.Context, url string, headers map[string]string) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -162,8 +184,7 @@ func doHealthGet(ctx context.Context, url string, headers map[string]string) err
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := healthClient().Do(req)
 	if err != nil {
 		return fmt.Errorf("health check: %w", err)
 	}
@@ -182,6 +203,14 @@ func bearerAuthCheck(ctx context.Context, url, apiKey string) error {
 	return doHealthGet(ctx, url, map[string]string{"Authorization": "Bearer " + apiKey})
 }
 
+// Replay script must be readable
+func fileCheck(_ context.Context, path, _ string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	return nil
+}
+
 // Azure api-key header
 func azureAPIKeyCheck(ctx context.Context, url, apiKey string) error {
 	return doHealthGet(ctx, url, map[string]string{"api-key": apiKey})
@@ -230,6 +259,13 @@ func NewHealthCheckConfig(b Backend, cfg *Config) HealthCheckConfig {
 			providerType: b,
 			check:        httpGetCheck,
 		}
+	case BackendReplay:
+		// Never fall back to a Generate ping: it would consume a recorded turn.
+		return &healthCheckCfg{
+			url:          cfg.Replay.File,
+			providerType: b,
+			check:        fileCheck,
+		}
 	default:
 		return nil
 	}
@@ -276,12 +312,51 @@ func (c *Config) Validate() error {
 		if c.Gemini.Model == "" {
 			return fmt.Errorf("provider: %q requires GEMINI_MODEL to be set", c.Backend)
 		}
+	case BackendReplay:
+		if c.Replay.File == "" {
+			return fmt.Errorf("provider: %q requires TFAI_REPLAY_FILE to be set", c.Backend)
+		}
 	default:
-		return fmt.Errorf("provider: unknown backend %q — valid values: ollama, openai, azure, bedrock, gemini", c.Backend)
+		return fmt.Errorf("provider: unknown backend %q — valid values: ollama, openai, azure, bedrock, gemini, replay", c.Backend)
 	}
 	return nil
 }
 
+// HTTPTimeout returns the client-side timeout of a single model request for
+// the selected backend, or zero when its HTTP client sets none and a request
+// is bounded only by its context. The server checks it against its chat
+// timeout at startup.
+func (c *Config) HTTPTimeout() time.Duration {
+	if c.Backend == BackendAzure && c.AzureOpenAI.isCodexEnabled() {
+		return codexHTTPTimeout
+	}
+	return 0
+}
+
+// ModelName returns the model identifier used by the selected backend: the
+// model name, Bedrock model ID, or Azure deployment (the Codex model when
+// Codex mode is enabled). Used to label usage metadata.
+func (c *Config) ModelName() string {
+	switch c.Backend {
+	case BackendOllama:
+		return c.Ollama.Model
+	case BackendOpenAI:
+		return c.OpenAI.Model
+	case BackendAzure:
+		if c.AzureOpenAI.isCodexEnabled() {
+			return c.AzureOpenAI.Codex.Model
+		}
+		return c.AzureOpenAI.Deployment
+	case BackendBedrock:
+		return c.Bedrock.ModelID
+	case BackendGemini:
+		return c.Gemini.Model
+	case BackendReplay:
+		return "replay"
+	}
+	return ""
+}
+
 // Factory is the interface for constructing a ToolCallingChatModel from a Config.
 // Implementations must be safe to call from multiple goroutines.
 type Factory interface {
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+
+	einogemini "github.com/cloudwego/eino-ext/components/model/gemini"
+	einoopenai "github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// JSONModeModel is a chat model whose backend can constrain a response to a
+// JSON document natively: OpenAI and Azure OpenAI through response_format,
+// Gemini through a response schema. Models returned by New implement it
+// when their backend does; callers type-assert for it and fall back to
+// describing the output format in the prompt when it is absent.
+type JSONModeModel interface {
+	model.ToolCallingChatModel
+	// JSONModeOptions returns the per-request options that make the model
+	// reply with a JSON document matching schema, a JSON Schema. name
+	// identifies the schema to backends that require one.
+	JSONModeOptions(name string, schema json.RawMessage) ([]model.Option, error)
+}
+
+// jsonModeFunc builds the JSON mode request options for one backend.
+type jsonModeFunc func(name string, schema json.RawMessage) ([]model.Option, error)
+
+// jsonModeModel adds JSONModeOptions to a model whose backend supports it.
+type jsonModeModel struct {
+	model.ToolCallingChatModel
+	// options builds the backend's request options.
+	options jsonModeFunc
+}
+
+// JSONModeOptions implements JSONModeModel.
+func (m *jsonModeModel) JSONModeOptions(name string, schema json.RawMessage) ([]model.Option, error) {
+	return m.options(name, schema)
+}
+
+// WithTools implements model.ToolCallingChatModel, keeping the capability on
+// the tool-bound copy.
+func (m *jsonModeModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	inner, err := m.ToolCallingChatModel.WithTools(tools)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // transparent wrapper
+	}
+	return &jsonModeModel{ToolCallingChatModel: inner, options: m.options}, nil
+}
+
+// withJSONMode wraps m as a JSONModeModel when cfg's backend supports native
+// JSON output, and returns it unchanged otherwise.
+func withJSONMode(m model.ToolCallingChatModel, cfg *Config) model.ToolCallingChatModel {
+	var fn jsonModeFunc
+	switch cfg.Backend {
+	case BackendOpenAI:
+		fn = openAIJSONMode
+	case BackendAzure:
+		// The Codex client talks to the responses API, which ignores
+		// chat completions options.
+		if !cfg.AzureOpenAI.isCodexEnabled() {
+			fn = openAIJSONMode
+		}
+	case BackendGemini:
+		fn = geminiJSONMode
+	}
+	if fn == nil {
+		return m
+	}
+	return &jsonModeModel{ToolCallingChatModel: m, options: fn}
+}
+
+// openAIJSONMode requests strict json_schema output through response_format.
+func openAIJSONMode(name string, schema json.RawMessage) ([]model.Option, error) {
+	if !json.Valid(schema) {
+		return nil, fmt.Errorf("provider: JSON mode schema %q is not valid JSON", name)
+	}
+	return []model.Option{einoopenai.WithExtraFields(map[string]any{
+		"response_format": map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   name,
+				"strict": true,
+				"schema": schema,
+			},
+		},
+	})}, nil
+}
+
+// geminiJSONMode sets the response JSON schema, which also switches the
+// response MIME type to application/json.
+func geminiJSONMode(name string, schema json.RawMessage) ([]model.Option, error) {
+	s := &jsonschema.Schema{}
+	if err := json.Unmarshal(schema, s); err != nil {
+		return nil, fmt.Errorf("provider: failed to parse JSON mode schema %q: %w", name, err)
+	}
+	return []model.Option{einogemini.WithResponseJSONSchema(s)}, nil
+}
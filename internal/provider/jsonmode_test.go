@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// ---------------------------------------------------------------------------
+// JSON mode
+// ---------------------------------------------------------------------------
+
+func TestWithJSONMode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		capable bool
+	}{
+		{name: "openai", cfg: &Config{Backend: BackendOpenAI}, capable: true},
+		{name: "azure", cfg: &Config{Backend: BackendAzure}, capable: true},
+		{name: "azure codex", cfg: &Config{Backend: BackendAzure, AzureOpenAI: ProviderAzureOpenAI{Codex: &Codex{Enabled: true}}}},
+		{name: "gemini", cfg: &Config{Backend: BackendGemini}, capable: true},
+		{name: "ollama", cfg: &Config{Backend: BackendOllama}},
+		{name: "bedrock", cfg: &Config{Backend: BackendBedrock}},
+		{name: "replay", cfg: &Config{Backend: BackendReplay}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := withJSONMode(&sessionModel{}, tc.cfg)
+			jm, ok := m.(JSONModeModel)
+			if ok != tc.capable {
+				t.Fatalf("expected JSONModeModel=%v, got %v", tc.capable, ok)
+			}
+			if !ok {
+				return
+			}
+			opts, err := jm.JSONModeOptions("envelope", json.RawMessage(`{"type":"object"}`))
+			if err != nil || len(opts) != 1 {
+				t.Errorf("expected one option, got %d (err %v)", len(opts), err)
+			}
+			bound, err := jm.WithTools([]*schema.ToolInfo{{Name: "terraform_plan"}})
+			if err != nil {
+				t.Fatalf("WithTools: %v", err)
+			}
+			if _, ok := bound.(JSONModeModel); !ok {
+				t.Error("expected the tool-bound model to keep JSON mode")
+			}
+		})
+	}
+}
+
+func TestJSONModeOptions_InvalidSchema(t *testing.T) {
+	t.Parallel()
+
+	for _, fn := range []jsonModeFunc{openAIJSONMode, geminiJSONMode} {
+		if _, err := fn("envelope", json.RawMessage(`{not json`)); err == nil {
+			t.Error("expected an error for an invalid schema")
+		}
+	}
+}
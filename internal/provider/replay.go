@@ -0,0 +1,367 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ScriptVersion is the format version written to recorded scripts.
+const ScriptVersion = 1
+
+// Script is a recorded chat session: the model calls of one or more queries,
+// in call order. It is written by the recorder (TFAI_RECORD_FILE) and played
+// back by the replay backend (MODEL_PROVIDER=replay, TFAI_REPLAY_FILE).
+type Script struct {
+	// Version is the script format version; see ScriptVersion.
+	Version int `json:"version"`
+	// Turns are the recorded model calls in the order they were made.
+	Turns []Turn `json:"turns"`
+}
+
+// Turn is one recorded model call.
+type Turn struct {
+	// Input fingerprints each input message, in order. Replay matches calls
+	// against it and reports a diff when they differ.
+	Input []MessagePrint `json:"input"`
+	// Chunks is the streamed response. Generate calls record a single chunk;
+	// replaying a Generate call concatenates the chunks.
+	Chunks []*schema.Message `json:"chunks"`
+}
+
+// MessagePrint identifies one input message without storing it in full.
+type MessagePrint struct {
+	// Role is the message role (system, user, assistant, tool).
+	Role schema.RoleType `json:"role"`
+	// Hash is a truncated SHA-256 over the role, content, tool calls, and
+	// tool call ID.
+	Hash string `json:"hash"`
+	// Preview is the start of the content, for readable mismatch diffs.
+	Preview string `json:"preview"`
+}
+
+// previewLen is the number of content runes kept in MessagePrint.Preview.
+const previewLen = 60
+
+// fingerprint returns the MessagePrint of every message in input.
+func fingerprint(input []*schema.Message) []MessagePrint {
+	prints := make([]MessagePrint, 0, len(input))
+	for _, m := range input {
+		h := sha256.New()
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", m.Role, m.Content, m.ToolCallID)
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(h, "%s\x00%s\x00", tc.Function.Name, tc.Function.Arguments)
+		}
+		preview := []rune(strings.Join(strings.Fields(m.Content), " "))
+		if len(preview) > previewLen {
+			preview = append(preview[:previewLen], '…')
+		}
+		prints = append(prints, MessagePrint{
+			Role:    m.Role,
+			Hash:    hex.EncodeToString(h.Sum(nil))[:16],
+			Preview: string(preview),
+		})
+	}
+	return prints
+}
+
+// samePrints reports whether a and b fingerprint the same input.
+func samePrints(a, b []MessagePrint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Role != b[i].Role || a[i].Hash != b[i].Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// MismatchError is returned by a strict replay model when a call's input
+// differs from the next recorded turn.
+type MismatchError struct {
+	// Turn is the zero-based index of the recorded turn that was expected.
+	Turn int
+	// Expected and Actual are the recorded and received input fingerprints.
+	Expected, Actual []MessagePrint
+}
+
+// Error renders a line-by-line diff of the expected and actual fingerprints.
+// Unchanged messages are prefixed with two spaces, recorded ones with "-",
+// received ones with "+".
+func (e *MismatchError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "provider: replay: input of call %d does not match the recording:", e.Turn+1)
+	for i := 0; i < max(len(e.Expected), len(e.Actual)); i++ {
+		var want, got *MessagePrint
+		if i < len(e.Expected) {
+			want = &e.Expected[i]
+		}
+		if i < len(e.Actual) {
+			got = &e.Actual[i]
+		}
+		if want != nil && got != nil && want.Role == got.Role && want.Hash == got.Hash {
+			fmt.Fprintf(&sb, "\n    %s", formatPrint(*want))
+			continue
+		}
+		if want != nil {
+			fmt.Fprintf(&sb, "\n  - %s", formatPrint(*want))
+		}
+		if got != nil {
+			fmt.Fprintf(&sb, "\n  + %s", formatPrint(*got))
+		}
+	}
+	return sb.String()
+}
+
+// formatPrint renders p as one diff line.
+func formatPrint(p MessagePrint) string {
+	return fmt.Sprintf("%-9s %s %q", p.Role, p.Hash, p.Preview)
+}
+
+// ErrScriptExhausted is returned when a replay model is called more times
+// than the script has turns.
+var ErrScriptExhausted = errors.New("provider: replay: script has no more recorded turns")
+
+// LoadScript reads a script written by the recorder.
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("provider: replay: failed to read script: %w", err)
+	}
+	var s Script
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("provider: replay: failed to parse %s: %w", path, err)
+	}
+	if s.Version != ScriptVersion {
+		return nil, fmt.Errorf("provider: replay: %s has script version %d, expected %d", path, s.Version, ScriptVersion)
+	}
+	return &s, nil
+}
+
+// ---------------------------------------------------------------------------
+// Replay
+// ---------------------------------------------------------------------------
+
+// replayModel plays a Script back as a ToolCallingChatModel. Calls consume
+// turns in order; concurrent calls are serialised on the cursor.
+type replayModel struct {
+	// state is shared by every model derived through WithTools so a session
+	// consumes one script.
+	state *replayState
+}
+
+// replayState is the script and playback cursor shared by a replay session.
+type replayState struct {
+	mu     sync.Mutex
+	script *Script
+	// used marks the turns already played.
+	used []bool
+	// strict requires each call to match the next unused turn exactly.
+	strict bool
+}
+
+// NewReplay returns a model that plays script back. In strict mode each call
+// must match the next recorded turn and a mismatch returns a *MismatchError.
+// Otherwise a call plays the first unused turn with the same input, falling
+// back to the next unused turn, so scripted demos survive small prompt
+// changes.
+func NewReplay(script *Script, strict bool) model.ToolCallingChatModel {
+	return &replayModel{state: &replayState{
+		script: script,
+		used:   make([]bool, len(script.Turns)),
+		strict: strict,
+	}}
+}
+
+// newReplay constructs the replay backend from cfg.
+func newReplay(_ context.Context, cfg *Config) (model.ToolCallingChatModel, error) {
+	script, err := LoadScript(cfg.Replay.File)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplay(script, cfg.Replay.Strict), nil
+}
+
+// next returns the chunks of the turn that answers input.
+func (s *replayState) next(input []*schema.Message) ([]*schema.Message, error) {
+	prints := fingerprint(input)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	first := -1
+	for i, used := range s.used {
+		if !used {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return nil, ErrScriptExhausted
+	}
+
+	pick := first
+	if !samePrints(s.script.Turns[first].Input, prints) {
+		if s.strict {
+			return nil, &MismatchError{Turn: first, Expected: s.script.Turns[first].Input, Actual: prints}
+		}
+		for i := first + 1; i < len(s.used); i++ {
+			if !s.used[i] && samePrints(s.script.Turns[i].Input, prints) {
+				pick = i
+				break
+			}
+		}
+	}
+	s.used[pick] = true
+	return s.script.Turns[pick].Chunks, nil
+}
+
+// Generate returns the recorded response, concatenating streamed chunks.
+func (m *replayModel) Generate(_ context.Context, input []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	chunks, err := m.state.next(input)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := schema.ConcatMessages(chunks)
+	if err != nil {
+		return nil, fmt.Errorf("provider: replay: invalid recorded chunks: %w", err)
+	}
+	return msg, nil
+}
+
+// Stream returns the recorded chunks as a stream.
+func (m *replayModel) Stream(_ context.Context, input []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	chunks, err := m.state.next(input)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray(chunks), nil
+}
+
+// WithTools returns a model sharing this session's script. The recorded
+// responses already contain whatever tool calls the model made.
+func (m *replayModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// ---------------------------------------------------------------------------
+// Record
+// ---------------------------------------------------------------------------
+
+// recordModel wraps a real model and appends every call to a script file.
+type recordModel struct {
+	// inner is the wrapped provider model.
+	inner model.ToolCallingChatModel
+	// rec is shared by every model derived through WithTools.
+	rec *recording
+}
+
+// recording is the script being written by a record session.
+type recording struct {
+	mu     sync.Mutex
+	path   string
+	script Script
+}
+
+// NewRecorder wraps inner so that every call and its response is appended to
+// the script at path. The file is rewritten after each call, so a session
+// that is interrupted still leaves a valid script of the calls it finished.
+func NewRecorder(inner model.ToolCallingChatModel, path string) model.ToolCallingChatModel {
+	return &recordModel{inner: inner, rec: &recording{path: path, script: Script{Version: ScriptVersion}}}
+}
+
+// add appends a turn and rewrites the script file atomically.
+func (r *recording) add(input []*schema.Message, chunks []*schema.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.script.Turns = append(r.script.Turns, Turn{Input: fingerprint(input), Chunks: chunks})
+
+	data, err := json.MarshalIndent(r.script, "", "  ")
+	if err != nil {
+		return fmt.Errorf("provider: record: failed to encode script: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".tfai-record-*")
+	if err != nil {
+		return fmt.Errorf("provider: record: failed to write script: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("provider: record: failed to write script: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("provider: record: failed to write script: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("provider: record: failed to write script: %w", err)
+	}
+	return nil
+}
+
+// Generate calls the wrapped model and records the response.
+func (m *recordModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	msg, err := m.inner.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // provider errors pass through unchanged
+	}
+	if err := m.rec.add(input, []*schema.Message{msg}); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Stream calls the wrapped model and passes its chunks through as they
+// arrive, recording the turn once the stream completes. A stream that fails
+// or is closed early is not recorded.
+func (m *recordModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	in, err := m.inner.Stream(ctx, input, opts...)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // provider errors pass through unchanged
+	}
+	out, w := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer w.Close()
+		defer in.Close()
+		var chunks []*schema.Message
+		for {
+			msg, err := in.Recv()
+			if errors.Is(err, io.EOF) {
+				if err := m.rec.add(input, chunks); err != nil {
+					w.Send(nil, err)
+				}
+				return
+			}
+			if err != nil {
+				w.Send(nil, err)
+				return
+			}
+			chunks = append(chunks, msg)
+			if closed := w.Send(msg, nil); closed {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// WithTools binds tools on the wrapped model and keeps recording into the
+// same script.
+func (m *recordModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	inner, err := m.inner.WithTools(tools)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // provider errors pass through unchanged
+	}
+	return &recordModel{inner: inner, rec: m.rec}, nil
+}
@@ -0,0 +1,237 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ---------------------------------------------------------------------------
+// Fake provider
+// ---------------------------------------------------------------------------
+
+// sessionModel stands in for a real provider: the first call asks for a
+// terraform_plan tool call, later calls stream a two-chunk answer.
+type sessionModel struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (m *sessionModel) reply() []*schema.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls++
+	if m.calls == 1 {
+		return []*schema.Message{schema.AssistantMessage("", []schema.ToolCall{{
+			ID:       "call-1",
+			Type:     "function",
+			Function: schema.FunctionCall{Name: "terraform_plan", Arguments: `{"dir":"/ws/a"}`},
+		}})}
+	}
+	last := schema.AssistantMessage("is broken.", nil)
+	last.ResponseMeta = &schema.ResponseMeta{Usage: &schema.TokenUsage{PromptTokens: 90, CompletionTokens: 12, TotalTokens: 102}}
+	return []*schema.Message{schema.AssistantMessage("The plan ", nil), last}
+}
+
+func (m *sessionModel) Generate(_ context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	return schema.ConcatMessages(m.reply())
+}
+
+func (m *sessionModel) Stream(_ context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray(m.reply()), nil
+}
+
+func (m *sessionModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// drain reads every chunk of sr.
+func drain(t *testing.T, sr *schema.StreamReader[*schema.Message]) []*schema.Message {
+	t.Helper()
+	defer sr.Close()
+	var chunks []*schema.Message
+	for {
+		msg, err := sr.Recv()
+		if errors.Is(err, io.EOF) {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		chunks = append(chunks, msg)
+	}
+}
+
+// sessionInputs are the inputs of the two calls of a recorded query.
+func sessionInputs() (first, second []*schema.Message) {
+	first = []*schema.Message{
+		schema.SystemMessage("You are an expert Terraform engineer."),
+		schema.UserMessage("why does plan fail?"),
+	}
+	second = append(append([]*schema.Message{}, first...),
+		schema.AssistantMessage("", []schema.ToolCall{{ID: "call-1", Function: schema.FunctionCall{Name: "terraform_plan", Arguments: `{"dir":"/ws/a"}`}}}),
+		schema.ToolMessage("Error: missing required argument", "call-1"),
+	)
+	return first, second
+}
+
+// recordSession records the two-call session to a script file and returns
+// its path and the responses the caller saw.
+func recordSession(t *testing.T) (string, *schema.Message, []*schema.Message) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.json")
+	rec, err := NewRecorder(&sessionModel{}, path).WithTools(nil)
+	if err != nil {
+		t.Fatalf("WithTools: %v", err)
+	}
+	first, second := sessionInputs()
+	toolCall, err := rec.Generate(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	sr, err := rec.Stream(context.Background(), second)
+	if err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	return path, toolCall, drain(t, sr)
+}
+
+// ---------------------------------------------------------------------------
+// Record and replay
+// ---------------------------------------------------------------------------
+
+func TestRecordReplay_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	path, recordedCall, recordedChunks := recordSession(t)
+	if len(recordedChunks) != 2 {
+		t.Fatalf("recorder must pass chunks through, got %d", len(recordedChunks))
+	}
+
+	script, err := LoadScript(path)
+	if err != nil {
+		t.Fatalf("LoadScript: %v", err)
+	}
+	if len(script.Turns) != 2 || len(script.Turns[0].Input) != 2 || len(script.Turns[1].Input) != 4 {
+		t.Fatalf("unexpected script shape: %+v", script)
+	}
+
+	m, err := New(context.Background(), &Config{Backend: BackendReplay, Replay: ProviderReplay{File: path, Strict: true}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	first, second := sessionInputs()
+
+	got, err := m.Generate(context.Background(), first)
+	if err != nil {
+		t.Fatalf("replay Generate: %v", err)
+	}
+	if len(got.ToolCalls) != 1 || got.ToolCalls[0].Function.Name != "terraform_plan" ||
+		got.ToolCalls[0].Function.Arguments != recordedCall.ToolCalls[0].Function.Arguments {
+		t.Errorf("expected the recorded tool call, got %+v", got.ToolCalls)
+	}
+
+	sr, err := m.Stream(context.Background(), second)
+	if err != nil {
+		t.Fatalf("replay Stream: %v", err)
+	}
+	chunks := drain(t, sr)
+	if len(chunks) != len(recordedChunks) {
+		t.Fatalf("expected %d chunks, got %d", len(recordedChunks), len(chunks))
+	}
+	for i := range chunks {
+		if chunks[i].Content != recordedChunks[i].Content {
+			t.Errorf("chunk %d: expected %q, got %q", i, recordedChunks[i].Content, chunks[i].Content)
+		}
+	}
+	if u := chunks[1].ResponseMeta; u == nil || u.Usage == nil || u.Usage.PromptTokens != 90 {
+		t.Errorf("expected recorded usage to survive replay, got %+v", u)
+	}
+
+	if _, err := m.Generate(context.Background(), first); !errors.Is(err, ErrScriptExhausted) {
+		t.Errorf("expected ErrScriptExhausted, got %v", err)
+	}
+}
+
+func TestReplay_StrictMismatch(t *testing.T) {
+	t.Parallel()
+
+	path, _, _ := recordSession(t)
+	script, err := LoadScript(path)
+	if err != nil {
+		t.Fatalf("LoadScript: %v", err)
+	}
+	m := NewReplay(script, true)
+
+	_, err = m.Generate(context.Background(), []*schema.Message{
+		schema.SystemMessage("You are an expert Terraform engineer."),
+		schema.UserMessage("why does apply fail?"),
+	})
+	var mm *MismatchError
+	if !errors.As(err, &mm) {
+		t.Fatalf("expected *MismatchError, got %v", err)
+	}
+	if mm.Turn != 0 {
+		t.Errorf("expected turn 0, got %d", mm.Turn)
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"input of call 1 does not match",
+		`    system    ` + mm.Expected[0].Hash + ` "You are an expert Terraform engineer."`,
+		`  - user      ` + mm.Expected[1].Hash + ` "why does plan fail?"`,
+		`  + user      ` + mm.Actual[1].Hash + ` "why does apply fail?"`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected diff line %q in:\n%s", want, msg)
+		}
+	}
+
+	// A mismatch does not consume the turn.
+	first, _ := sessionInputs()
+	if _, err := m.Generate(context.Background(), first); err != nil {
+		t.Errorf("expected the matching call to replay after a mismatch, got %v", err)
+	}
+}
+
+func TestReplay_Lenient(t *testing.T) {
+	t.Parallel()
+
+	path, _, _ := recordSession(t)
+	script, err := LoadScript(path)
+	if err != nil {
+		t.Fatalf("LoadScript: %v", err)
+	}
+	m := NewReplay(script, false)
+	_, second := sessionInputs()
+
+	// Out of order: the second recorded turn is found by its fingerprint.
+	got, err := m.Generate(context.Background(), second)
+	if err != nil || got.Content != "The plan is broken." {
+		t.Fatalf("expected the matching turn, got %+v, %v", got, err)
+	}
+	// Unknown input: falls back to the next unused turn.
+	got, err = m.Generate(context.Background(), []*schema.Message{schema.UserMessage("something else")})
+	if err != nil || len(got.ToolCalls) != 1 {
+		t.Fatalf("expected the remaining turn, got %+v, %v", got, err)
+	}
+}
+
+func TestLoadScript_RejectsUnknownVersion(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := os.WriteFile(path, []byte(`{"version":99,"turns":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScript(path); err == nil || !strings.Contains(err.Error(), "script version 99") {
+		t.Errorf("expected a version error, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package rag
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// Connection defaults applied by NewClientFromConfig.
+const (
+	// DefaultQdrantHost is used when QdrantConfig.Host is empty.
+	DefaultQdrantHost = "localhost"
+	// DefaultQdrantPort is the Qdrant gRPC port, used when QdrantConfig.Port
+	// is zero.
+	DefaultQdrantPort = 6334
+	// DefaultQdrantCollection is the collection name used when
+	// QDRANT_COLLECTION is unset.
+	DefaultQdrantCollection = "tfai-docs"
+)
+
+// QdrantConfigFromEnv returns the Qdrant connection settings shared by the
+// retriever, the readiness probe, and the ingest and rag commands:
+// QDRANT_HOST, QDRANT_PORT, QDRANT_COLLECTION, QDRANT_API_KEY, QDRANT_TLS
+// ("true" enables it), and QDRANT_DIAL_TIMEOUT (a Go duration). Host is left
+// empty when QDRANT_HOST is unset so callers can tell Qdrant is not
+// configured. VectorSize is left for the caller, which knows the embedder.
+func QdrantConfigFromEnv() (*QdrantConfig, error) {
+	cfg := &QdrantConfig{
+		Host:       os.Getenv("QDRANT_HOST"),
+		Port:       DefaultQdrantPort,
+		Collection: DefaultQdrantCollection,
+		APIKey:     os.Getenv("QDRANT_API_KEY"),
+		UseTLS:     os.Getenv("QDRANT_TLS") == "true",
+	}
+	if v := os.Getenv("QDRANT_PORT"); v != "" {
+		if port, err := strconv.Atoi(v); err == nil {
+			cfg.Port = port
+		}
+	}
+	if v := os.Getenv("QDRANT_COLLECTION"); v != "" {
+		cfg.Collection = v
+	}
+	if v := os.Getenv("QDRANT_DIAL_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("qdrant: invalid QDRANT_DIAL_TIMEOUT %q: want a non-negative duration such as 10s", v)
+		}
+		cfg.DialTimeout = d
+	}
+	return cfg, nil
+}
+
+// NewClientFromConfig creates a Qdrant gRPC client for cfg, with its API
+// key, TLS, and dial timeout. Every Qdrant connection is made through it so
+// none can drop a credential the others send. An empty Host or zero Port in
+// cfg is set to the default.
+func NewClientFromConfig(cfg *QdrantConfig) (*qdrant.Client, error) {
+	client, err := qdrant.NewClient(clientConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("qdrant: failed to create client: %w", err)
+	}
+	return client, nil
+}
+
+// clientConfig applies the connection defaults to cfg and returns the
+// go-client configuration for it.
+func clientConfig(cfg *QdrantConfig) *qdrant.Config {
+	if cfg.Host == "" {
+		cfg.Host = DefaultQdrantHost
+	}
+	if cfg.Port == 0 {
+		cfg.Port = DefaultQdrantPort
+	}
+	clientCfg := &qdrant.Config{
+		Host:   cfg.Host,
+		Port:   cfg.Port,
+		APIKey: cfg.APIKey,
+		UseTLS: cfg.UseTLS,
+	}
+	if cfg.DialTimeout > 0 {
+		clientCfg.GrpcOptions = append(clientCfg.GrpcOptions,
+			grpc.WithConnectParams(grpc.ConnectParams{MinConnectTimeout: cfg.DialTimeout}))
+	}
+	return clientCfg
+}
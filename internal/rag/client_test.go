@@ -0,0 +1,81 @@
+package rag
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		cfg         QdrantConfig
+		wantHost    string
+		wantPort    int
+		wantOptions int
+	}{
+		{
+			name:     "defaults",
+			wantHost: DefaultQdrantHost,
+			wantPort: DefaultQdrantPort,
+		},
+		{
+			name:        "credentials, tls and dial timeout",
+			cfg:         QdrantConfig{Host: "qdrant.example.com", Port: 6335, APIKey: "secret", UseTLS: true, DialTimeout: 5 * time.Second},
+			wantHost:    "qdrant.example.com",
+			wantPort:    6335,
+			wantOptions: 1,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := tc.cfg
+			got := clientConfig(&cfg)
+			if got.Host != tc.wantHost || got.Port != tc.wantPort {
+				t.Errorf("want %s:%d, got %s:%d", tc.wantHost, tc.wantPort, got.Host, got.Port)
+			}
+			if cfg.Host != tc.wantHost || cfg.Port != tc.wantPort {
+				t.Errorf("want the defaults applied to cfg, got %s:%d", cfg.Host, cfg.Port)
+			}
+			if got.APIKey != tc.cfg.APIKey || got.UseTLS != tc.cfg.UseTLS {
+				t.Errorf("want api key %q and tls %v passed through, got %q and %v", tc.cfg.APIKey, tc.cfg.UseTLS, got.APIKey, got.UseTLS)
+			}
+			if len(got.GrpcOptions) != tc.wantOptions {
+				t.Errorf("want %d gRPC options, got %d", tc.wantOptions, len(got.GrpcOptions))
+			}
+		})
+	}
+}
+
+func TestQdrantConfigFromEnv(t *testing.T) {
+	t.Setenv("QDRANT_HOST", "qdrant.example.com")
+	t.Setenv("QDRANT_PORT", "6335")
+	t.Setenv("QDRANT_COLLECTION", "")
+	t.Setenv("QDRANT_API_KEY", "secret")
+	t.Setenv("QDRANT_TLS", "true")
+	t.Setenv("QDRANT_DIAL_TIMEOUT", "5s")
+
+	cfg, err := QdrantConfigFromEnv()
+	if err != nil {
+		t.Fatalf("QdrantConfigFromEnv: %v", err)
+	}
+	want := QdrantConfig{
+		Host:        "qdrant.example.com",
+		Port:        6335,
+		Collection:  DefaultQdrantCollection,
+		APIKey:      "secret",
+		UseTLS:      true,
+		DialTimeout: 5 * time.Second,
+	}
+	if *cfg != want {
+		t.Errorf("want %+v, got %+v", want, *cfg)
+	}
+
+	t.Setenv("QDRANT_DIAL_TIMEOUT", "soon")
+	if _, err := QdrantConfigFromEnv(); err == nil {
+		t.Error("expected an error for an invalid QDRANT_DIAL_TIMEOUT")
+	}
+}
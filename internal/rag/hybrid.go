@@ -0,0 +1,148 @@
+package rag
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// rrfK is the rank constant of reciprocal rank fusion. 60 is the value from
+// the original paper and dampens the weight of the very first ranks.
+const rrfK = 60
+
+// maxKeywordTerms caps the number of query terms used for keyword search.
+const maxKeywordTerms = 8
+
+// keywordStopwords are query words too common to be worth matching.
+var keywordStopwords = map[string]bool{
+	"and": true, "are": true, "can": true, "does": true, "for": true,
+	"from": true, "how": true, "into": true, "not": true, "should": true,
+	"that": true, "the": true, "this": true, "use": true, "using": true,
+	"what": true, "when": true, "where": true, "which": true, "why": true,
+	"will": true, "with": true, "you": true, "your": true,
+}
+
+// keywordTerms returns the distinct lower-case words of query worth matching
+// literally: at least three characters, not a stopword, at most
+// maxKeywordTerms. Underscores and hyphens are kept, so resource types such
+// as aws_eks_node_group stay one term.
+func keywordTerms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+	})
+	var terms []string
+	seen := make(map[string]bool)
+	for _, w := range words {
+		w = strings.Trim(w, "_-")
+		if len(w) < 3 || keywordStopwords[w] || seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+		if len(terms) == maxKeywordTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// rankBM25 orders docs by their Okapi BM25 score for terms, most relevant
+// first, with document frequencies taken from docs themselves. Documents
+// containing no term are dropped; ties keep their input order. Each
+// document's Score is set to its BM25 score.
+func rankBM25(docs []Document, terms []string) []Document {
+	const k1, b = 1.2, 0.75
+	if len(docs) == 0 || len(terms) == 0 {
+		return nil
+	}
+
+	counts := make([]map[string]int, len(docs))
+	df := make(map[string]int, len(terms))
+	totalLen := 0
+	for i, d := range docs {
+		content := strings.ToLower(d.Content)
+		totalLen += len(content)
+		counts[i] = make(map[string]int, len(terms))
+		for _, t := range terms {
+			if n := strings.Count(content, t); n > 0 {
+				counts[i][t] = n
+				df[t]++
+			}
+		}
+	}
+	avgLen := float64(totalLen) / float64(len(docs))
+	if avgLen == 0 {
+		avgLen = 1
+	}
+
+	n := float64(len(docs))
+	out := make([]Document, 0, len(docs))
+	for i, d := range docs {
+		score := 0.0
+		docLen := float64(len(d.Content))
+		for _, t := range terms {
+			tf := float64(counts[i][t])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (n-float64(df[t])+0.5)/(float64(df[t])+0.5))
+			score += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*docLen/avgLen))
+		}
+		if score == 0 {
+			continue
+		}
+		d.Score = float32(score)
+		out = append(out, d)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
+
+// fuseRRF merges ranked lists with reciprocal rank fusion: a document scores
+// the sum of 1/(rrfK+rank) over the lists it appears in, ranks starting at 1.
+// Documents are deduplicated by ID, keeping the first occurrence (a repeat
+// within one list is ignored), and the result is the topK best, most
+// relevant first. Ties are broken by the earliest list and rank a document
+// appeared at, so the order is stable.
+// Each document's Score is set to its fused score.
+func fuseRRF(lists [][]Document, topK int) []Document {
+	type fused struct {
+		doc   Document
+		score float64
+		order int
+	}
+	byID := make(map[string]*fused)
+	var all []*fused
+	for _, list := range lists {
+		seen := make(map[string]bool, len(list))
+		for rank, d := range list {
+			if seen[d.ID] {
+				continue
+			}
+			seen[d.ID] = true
+			f, ok := byID[d.ID]
+			if !ok {
+				f = &fused{doc: d, order: len(all)}
+				byID[d.ID] = f
+				all = append(all, f)
+			}
+			f.score += 1 / float64(rrfK+rank+1)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+		return all[i].order < all[j].order
+	})
+	if topK > 0 && len(all) > topK {
+		all = all[:topK]
+	}
+	out := make([]Document, len(all))
+	for i, f := range all {
+		out[i] = f.doc
+		out[i].Score = float32(f.score)
+	}
+	return out
+}
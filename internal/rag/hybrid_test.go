@@ -0,0 +1,153 @@
+package rag
+
+import (
+	"slices"
+	"testing"
+)
+
+// docs returns documents with the given IDs.
+func docs(ids ...string) []Document {
+	out := make([]Document, len(ids))
+	for i, id := range ids {
+		out[i] = Document{ID: id}
+	}
+	return out
+}
+
+// ids returns the IDs of docs.
+func ids(docs []Document) []string {
+	out := make([]string, len(docs))
+	for i, d := range docs {
+		out[i] = d.ID
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Reciprocal rank fusion
+// ---------------------------------------------------------------------------
+
+func TestFuseRRF(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		lists [][]Document
+		topK  int
+		want  []string
+	}{
+		{
+			name:  "documents in both lists rise",
+			lists: [][]Document{docs("a", "b", "c"), docs("c", "d", "b")},
+			topK:  4,
+			want:  []string{"c", "b", "a", "d"},
+		},
+		{
+			name:  "ties keep dense order first",
+			lists: [][]Document{docs("a", "b"), docs("x", "y")},
+			topK:  4,
+			want:  []string{"a", "x", "b", "y"},
+		},
+		{
+			name:  "truncated to topK",
+			lists: [][]Document{docs("a", "b", "c"), docs("c", "d", "b")},
+			topK:  2,
+			want:  []string{"c", "b"},
+		},
+		{
+			name:  "repeats within a list count once",
+			lists: [][]Document{docs("a", "a", "b"), docs("b")},
+			topK:  5,
+			want:  []string{"b", "a"},
+		},
+		{
+			name:  "one list empty",
+			lists: [][]Document{nil, docs("k1", "k2")},
+			topK:  5,
+			want:  []string{"k1", "k2"},
+		},
+		{name: "both empty", lists: [][]Document{nil, nil}, topK: 5, want: []string{}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := fuseRRF(tc.lists, tc.topK)
+			if !slices.Equal(ids(got), tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, ids(got))
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Score > got[i-1].Score {
+					t.Errorf("expected scores in descending order, got %v before %v", got[i-1].Score, got[i].Score)
+				}
+			}
+		})
+	}
+}
+
+func TestFuseRRF_Stable(t *testing.T) {
+	t.Parallel()
+
+	lists := [][]Document{docs("a", "b", "c", "d", "e"), docs("e", "d", "c", "b", "a")}
+	first := ids(fuseRRF(lists, 5))
+	for i := 0; i < 50; i++ {
+		if got := ids(fuseRRF(lists, 5)); !slices.Equal(got, first) {
+			t.Fatalf("expected a stable order %v, got %v", first, got)
+		}
+	}
+	// a and e, and b and d, tie; the dense list's order breaks the ties.
+	if want := []string{"a", "e", "b", "d", "c"}; !slices.Equal(first, want) {
+		t.Errorf("expected %v, got %v", want, first)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Keyword terms and BM25
+// ---------------------------------------------------------------------------
+
+func TestKeywordTerms(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "How do I scale an aws_eks_node_group?", want: []string{"scale", "aws_eks_node_group"}},
+		{query: "What is the for_each meta-argument", want: []string{"for_each", "meta-argument"}},
+		{query: "S3 s3 bucket BUCKET", want: []string{"bucket"}},
+		{query: "how to use the with for", want: nil},
+		{query: "one two three four five six seven eight nine ten", want: []string{"one", "two", "three", "four", "five", "six", "seven", "eight"}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.query, func(t *testing.T) {
+			t.Parallel()
+			if got := keywordTerms(tc.query); !slices.Equal(got, tc.want) {
+				t.Errorf("keywordTerms(%q) = %q, want %q", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRankBM25(t *testing.T) {
+	t.Parallel()
+
+	in := []Document{
+		{ID: "none", Content: "an unrelated page about state locking"},
+		{ID: "once", Content: "resource aws_eks_cluster and an aws_eks_node_group example with many other words around it"},
+		{ID: "twice", Content: "aws_eks_node_group: aws_eks_node_group arguments"},
+		{ID: "other", Content: "scaling a node group"},
+	}
+	got := rankBM25(in, []string{"aws_eks_node_group"})
+	if want := []string{"twice", "once"}; !slices.Equal(ids(got), want) {
+		t.Errorf("expected %v, got %v", want, ids(got))
+	}
+	// A term found in fewer documents weighs more.
+	got = rankBM25(in, []string{"aws_eks_node_group", "scaling"})
+	if want := []string{"other", "twice", "once"}; !slices.Equal(ids(got), want) {
+		t.Errorf("expected %v, got %v", want, ids(got))
+	}
+	if rankBM25(nil, []string{"x"}) != nil || rankBM25(in, nil) != nil {
+		t.Error("expected no results without documents or terms")
+	}
+}
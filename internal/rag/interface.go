@@ -27,6 +27,28 @@ type Document struct {
 	Score float32
 }
 
+// SearchFilter restricts a search to documents whose metadata matches every
+// non-empty field. The zero value matches all documents.
+type SearchFilter struct {
+	// Provider is the cloud provider label written at ingestion (aws, azure,
+	// gcp, kubernetes, generic).
+	Provider string
+
+	// Framework is the IaC framework (terraform, atmos, terragrunt, cdktf).
+	Framework string
+
+	// DocType is the documentation kind (reference, tutorial, guide, api).
+	DocType string
+
+	// Source is the exact origin URI of the document.
+	Source string
+}
+
+// IsZero reports whether f matches all documents.
+func (f SearchFilter) IsZero() bool {
+	return f == SearchFilter{}
+}
+
 // VectorStore is the interface for persisting and searching document embeddings.
 // Implementations must be safe to call from multiple goroutines.
 type VectorStore interface {
@@ -38,13 +60,53 @@ type VectorStore interface {
 	// most relevant documents for the given query embedding.
 	Search(ctx context.Context, queryEmbedding []float32, topK int) ([]Document, error)
 
+	// SearchWithFilter is Search restricted to documents matching filter.
+	// A zero filter behaves like Search.
+	SearchWithFilter(ctx context.Context, queryEmbedding []float32, topK int, filter SearchFilter) ([]Document, error)
+
 	// Delete removes documents by their IDs.
 	Delete(ctx context.Context, ids []string) error
 
+	// ListBySource returns the IDs of every stored document whose Source is
+	// source, in no particular order. source must not be empty.
+	ListBySource(ctx context.Context, source string) ([]string, error)
+
 	// Close releases any resources held by the store.
 	Close() error
 }
 
+// StoredChunk is a stored document as listed by ChunkLister: enough to
+// reuse its embedding when the same content is ingested again.
+type StoredChunk struct {
+	// ID is the document ID.
+	ID string
+
+	// Hash is the "chunk_hash" metadata written at ingestion, or empty for
+	// documents stored before chunk hashes were.
+	Hash string
+
+	// Embedding is the stored vector.
+	Embedding []float32
+}
+
+// ChunkLister is implemented by vector stores that can list the documents
+// of a source with their embeddings. The ingestion pipeline uses it to embed
+// only the chunks of a page that changed since it was last ingested.
+type ChunkLister interface {
+	// ListChunks returns every stored document whose Source is source, in
+	// no particular order. source must not be empty.
+	ListChunks(ctx context.Context, source string) ([]StoredChunk, error)
+}
+
+// KeywordSearcher is implemented by vector stores that can also find
+// documents by the words they contain. DefaultRetriever uses it for hybrid
+// retrieval.
+type KeywordSearcher interface {
+	// KeywordSearch returns up to limit documents matching filter whose
+	// content contains at least one of terms, most relevant first.
+	KeywordSearch(ctx context.Context, terms []string, limit int, filter SearchFilter) ([]Document, error)
+}
+
 // Embedder is the interface for converting text into dense vector embeddings.
 // Implementations must be safe to call from multiple goroutines.
 type Embedder interface {
@@ -0,0 +1,74 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Count returns the exact number of points whose payload matches every
+// non-empty field of filter; a zero filter counts the whole collection.
+func (s *QdrantStore) Count(ctx context.Context, filter SearchFilter) (uint64, error) {
+	exact := true
+	n, err := s.client.Count(ctx, &qdrant.CountPoints{
+		CollectionName: s.cfg.Collection,
+		Filter:         qdrantFilter(filter),
+		Exact:          &exact,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("qdrant: count failed: %w", err)
+	}
+	return n, nil
+}
+
+// DeleteByFilter deletes the points whose payload matches every non-empty
+// field of filter in a single filtered delete, and returns how many matched.
+// A zero filter is an error rather than a way to empty the collection; use
+// Recreate for that.
+func (s *QdrantStore) DeleteByFilter(ctx context.Context, filter SearchFilter) (uint64, error) {
+	f := qdrantFilter(filter)
+	if f == nil {
+		return 0, fmt.Errorf("qdrant: delete by filter: filter must not be empty")
+	}
+	n, err := s.Count(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	wait := true
+	_, err = s.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: s.cfg.Collection,
+		Points:         qdrant.NewPointsSelectorFilter(f),
+		Wait:           &wait,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("qdrant: delete by filter failed: %w", err)
+	}
+	return n, nil
+}
+
+// Recreate drops the collection and creates it again, empty, with the
+// configured VectorSize. It returns the number of points dropped; a
+// collection that does not exist is simply created.
+func (s *QdrantStore) Recreate(ctx context.Context) (uint64, error) {
+	if s.cfg.VectorSize == 0 {
+		return 0, fmt.Errorf("qdrant: recreate: vector size must be set")
+	}
+	exists, err := s.client.CollectionExists(ctx, s.cfg.Collection)
+	if err != nil {
+		return 0, fmt.Errorf("qdrant: failed to check collection existence: %w", err)
+	}
+	var n uint64
+	if exists {
+		if n, err = s.Count(ctx, SearchFilter{}); err != nil {
+			return 0, err
+		}
+		if err := s.client.DeleteCollection(ctx, s.cfg.Collection); err != nil {
+			return 0, fmt.Errorf("qdrant: failed to drop collection %q: %w", s.cfg.Collection, err)
+		}
+	}
+	return n, s.ensureCollection(ctx)
+}
@@ -0,0 +1,152 @@
+package rag
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// purgeClient records the count, delete, and collection requests of a purge.
+// count is returned by every Count call.
+type purgeClient struct {
+	qdrantClient
+	count   uint64
+	exists  bool
+	counts  []*qdrant.CountPoints
+	deletes []*qdrant.DeletePoints
+	// calls lists the collection-level calls in order.
+	calls []string
+}
+
+func (c *purgeClient) Count(_ context.Context, req *qdrant.CountPoints) (uint64, error) {
+	c.counts = append(c.counts, req)
+	return c.count, nil
+}
+
+func (c *purgeClient) Delete(_ context.Context, req *qdrant.DeletePoints) (*qdrant.UpdateResult, error) {
+	c.deletes = append(c.deletes, req)
+	return &qdrant.UpdateResult{}, nil
+}
+
+func (c *purgeClient) CollectionExists(context.Context, string) (bool, error) {
+	c.calls = append(c.calls, "exists")
+	return c.exists, nil
+}
+
+func (c *purgeClient) DeleteCollection(_ context.Context, name string) error {
+	c.calls = append(c.calls, "drop "+name)
+	c.exists = false
+	return nil
+}
+
+func (c *purgeClient) CreateCollection(_ context.Context, req *qdrant.CreateCollection) error {
+	c.calls = append(c.calls, "create "+req.GetCollectionName())
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// DeleteByFilter
+// ---------------------------------------------------------------------------
+
+func TestQdrantStore_DeleteByFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		filter SearchFilter
+		count  uint64
+		// want lists the expected Must conditions of the delete as "key=value".
+		want []string
+	}{
+		{name: "source", filter: SearchFilter{Source: "https://example.com/changelog"}, count: 4, want: []string{"source=https://example.com/changelog"}},
+		{name: "provider", filter: SearchFilter{Provider: "gcp"}, count: 9, want: []string{"provider=gcp"}},
+		{name: "both", filter: SearchFilter{Provider: "aws", Source: "https://example.com/a"}, count: 1, want: []string{"provider=aws", "source=https://example.com/a"}},
+		{name: "nothing matches", filter: SearchFilter{Provider: "azure"}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			client := &purgeClient{count: tc.count}
+			s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs"}}
+
+			n, err := s.DeleteByFilter(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("DeleteByFilter: %v", err)
+			}
+			if n != tc.count {
+				t.Errorf("expected %d deleted, got %d", tc.count, n)
+			}
+			if len(client.counts) != 1 || !client.counts[0].GetExact() {
+				t.Errorf("expected one exact count, got %v", client.counts)
+			}
+			if tc.want == nil {
+				if len(client.deletes) != 0 {
+					t.Errorf("expected no delete when nothing matches, got %v", client.deletes)
+				}
+				return
+			}
+			if len(client.deletes) != 1 {
+				t.Fatalf("expected one delete request, got %d", len(client.deletes))
+			}
+			req := client.deletes[0]
+			if req.GetCollectionName() != "docs" || !req.GetWait() {
+				t.Errorf("expected a waited delete in docs, got %v", req)
+			}
+			var got []string
+			for _, c := range req.GetPoints().GetFilter().GetMust() {
+				got = append(got, c.GetField().GetKey()+"="+c.GetField().GetMatch().GetKeyword())
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expected filter %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	client := &purgeClient{count: 3}
+	s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs"}}
+	if _, err := s.DeleteByFilter(context.Background(), SearchFilter{}); err == nil {
+		t.Error("expected an error for an empty filter, which would delete the whole collection")
+	}
+	if len(client.counts)+len(client.deletes) != 0 {
+		t.Error("expected no request for an empty filter")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Recreate
+// ---------------------------------------------------------------------------
+
+func TestQdrantStore_Recreate(t *testing.T) {
+	t.Parallel()
+
+	client := &purgeClient{count: 12, exists: true}
+	s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs", VectorSize: 768}}
+	n, err := s.Recreate(context.Background())
+	if err != nil {
+		t.Fatalf("Recreate: %v", err)
+	}
+	if n != 12 {
+		t.Errorf("expected 12 points dropped, got %d", n)
+	}
+	if want := []string{"exists", "drop docs", "exists", "create docs"}; !slices.Equal(client.calls, want) {
+		t.Errorf("expected %v, got %v", want, client.calls)
+	}
+
+	client = &purgeClient{}
+	s = &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs", VectorSize: 768}}
+	if n, err := s.Recreate(context.Background()); err != nil || n != 0 {
+		t.Errorf("expected a missing collection to be created, got %d, %v", n, err)
+	}
+	if want := []string{"exists", "exists", "create docs"}; !slices.Equal(client.calls, want) {
+		t.Errorf("expected %v, got %v", want, client.calls)
+	}
+
+	s = &QdrantStore{client: &purgeClient{}, cfg: &QdrantConfig{Collection: "docs"}}
+	if _, err := s.Recreate(context.Background()); err == nil {
+		t.Error("expected an error without a vector size")
+	}
+}
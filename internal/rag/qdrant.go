@@ -3,6 +3,7 @@ package rag
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/qdrant/go-client/qdrant"
 )
@@ -26,12 +27,32 @@ type QdrantConfig struct {
 
 	// UseTLS enables TLS for the gRPC connection.
 	UseTLS bool
+
+	// DialTimeout is the least time each gRPC connection attempt is given
+	// before it is retried. Zero uses the gRPC default of 20 seconds.
+	DialTimeout time.Duration
+}
+
+// qdrantClient is the subset of *qdrant.Client used by QdrantStore, so tests
+// can record the requests it sends without a running Qdrant.
+type qdrantClient interface {
+	CollectionExists(ctx context.Context, collectionName string) (bool, error)
+	GetCollectionInfo(ctx context.Context, collectionName string) (*qdrant.CollectionInfo, error)
+	CreateCollection(ctx context.Context, request *qdrant.CreateCollection) error
+	DeleteCollection(ctx context.Context, collectionName string) error
+	Upsert(ctx context.Context, request *qdrant.UpsertPoints) (*qdrant.UpdateResult, error)
+	Query(ctx context.Context, request *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error)
+	Scroll(ctx context.Context, request *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, error)
+	Count(ctx context.Context, request *qdrant.CountPoints) (uint64, error)
+	Delete(ctx context.Context, request *qdrant.DeletePoints) (*qdrant.UpdateResult, error)
+	HealthCheck(ctx context.Context) (*qdrant.HealthCheckReply, error)
+	Close() error
 }
 
 // QdrantStore implements VectorStore backed by a Qdrant instance.
 type QdrantStore struct {
 	// client is the underlying Qdrant gRPC client.
-	client *qdrant.Client
+	client qdrantClient
 
 	// cfg holds the resolved configuration for this store.
 	cfg *QdrantConfig
@@ -40,33 +61,26 @@ type QdrantStore struct {
 // NewQdrantStore creates a new QdrantStore, ensuring the target collection
 // exists (creating it if necessary), and returns a ready-to-use VectorStore.
 func NewQdrantStore(ctx context.Context, cfg *QdrantConfig) (*QdrantStore, error) {
-	if cfg.Host == "" {
-		cfg.Host = "localhost"
-	}
-	if cfg.Port == 0 {
-		cfg.Port = 6334
-	}
-
-	clientCfg := &qdrant.Config{
-		Host:   cfg.Host,
-		Port:   cfg.Port,
-		APIKey: cfg.APIKey,
-		UseTLS: cfg.UseTLS,
-	}
-
-	client, err := qdrant.NewClient(clientCfg)
+	store, err := OpenQdrantStore(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("qdrant: failed to create client: %w", err)
+		return nil, err
 	}
-
-	store := &QdrantStore{client: client, cfg: cfg}
 	if err := store.ensureCollection(ctx); err != nil {
 		return nil, err
 	}
-
 	return store, nil
 }
 
+// OpenQdrantStore creates a QdrantStore without creating the collection, for
+// read-only inspection such as Status.
+func OpenQdrantStore(cfg *QdrantConfig) (*QdrantStore, error) {
+	client, err := NewClientFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &QdrantStore{client: client, cfg: cfg}, nil
+}
+
 // ensureCollection creates the Qdrant collection if it does not already exist.
 func (s *QdrantStore) ensureCollection(ctx context.Context) error {
 	exists, err := s.client.CollectionExists(ctx, s.cfg.Collection)
@@ -93,13 +107,21 @@ func (s *QdrantStore) ensureCollection(ctx context.Context) error {
 
 // Upsert stores or updates a batch of documents with their pre-computed embeddings.
 // The embeddings slice must be parallel to docs — embeddings[i] is the vector for docs[i].
+// Every embedding must have VectorSize dimensions when VectorSize is set; a
+// mismatched batch is rejected before anything is sent.
 func (s *QdrantStore) Upsert(ctx context.Context, docs []Document, embeddings [][]float32) error {
 	if len(embeddings) != len(docs) {
 		return fmt.Errorf("qdrant: embeddings length %d does not match docs length %d", len(embeddings), len(docs))
 	}
+	if len(docs) == 0 {
+		return nil
+	}
 
 	points := make([]*qdrant.PointStruct, 0, len(docs))
 	for i, doc := range docs {
+		if n := uint64(len(embeddings[i])); n == 0 || (s.cfg.VectorSize > 0 && n != s.cfg.VectorSize) {
+			return fmt.Errorf("qdrant: embedding for document %q has %d dimensions, collection %q expects %d", doc.ID, n, s.cfg.Collection, s.cfg.VectorSize)
+		}
 		payload := map[string]interface{}{
 			"content": doc.Content,
 			"source":  doc.Source,
@@ -128,6 +150,13 @@ func (s *QdrantStore) Upsert(ctx context.Context, docs []Document, embeddings []
 
 // Search performs a cosine similarity search and returns the top-k results.
 func (s *QdrantStore) Search(ctx context.Context, queryEmbedding []float32, topK int) ([]Document, error) {
+	return s.SearchWithFilter(ctx, queryEmbedding, topK, SearchFilter{})
+}
+
+// SearchWithFilter performs a cosine similarity search over the points whose
+// payload matches every non-empty field of filter and returns the top-k
+// results.
+func (s *QdrantStore) SearchWithFilter(ctx context.Context, queryEmbedding []float32, topK int, filter SearchFilter) ([]Document, error) {
 	if topK < 0 {
 		topK = 0
 	}
@@ -135,6 +164,7 @@ func (s *QdrantStore) Search(ctx context.Context, queryEmbedding []float32, topK
 	results, err := s.client.Query(ctx, &qdrant.QueryPoints{
 		CollectionName: s.cfg.Collection,
 		Query:          qdrant.NewQuery(queryEmbedding...),
+		Filter:         qdrantFilter(filter),
 		Limit:          &limit,
 		WithPayload:    qdrant.NewWithPayload(true),
 	})
@@ -144,30 +174,99 @@ func (s *QdrantStore) Search(ctx context.Context, queryEmbedding []float32, topK
 
 	docs := make([]Document, 0, len(results))
 	for _, r := range results {
-		doc := Document{
-			ID:       r.Id.GetUuid(),
-			Score:    r.Score,
-			Metadata: make(map[string]string),
-		}
-		if p := r.Payload; p != nil {
-			if v, ok := p["content"]; ok {
-				doc.Content = v.GetStringValue()
-			}
-			if v, ok := p["source"]; ok {
-				doc.Source = v.GetStringValue()
-			}
-			for k, v := range p {
-				if k != "content" && k != "source" {
-					doc.Metadata[k] = v.GetStringValue()
-				}
-			}
-		}
+		doc := documentFromPayload(r.GetId(), r.GetPayload())
+		doc.Score = r.Score
 		docs = append(docs, doc)
 	}
 
 	return docs, nil
 }
 
+// keywordScrollFactor is how many candidate points per requested result
+// KeywordSearch fetches before ranking them, up to maxKeywordScroll.
+const (
+	keywordScrollFactor = 5
+	maxKeywordScroll    = 256
+)
+
+// KeywordSearch scrolls the points matching filter whose content contains
+// any of terms, ranks them with BM25 (see rankBM25), and returns the best
+// limit. Without a full-text index on "content", Qdrant matches each term
+// as a case-sensitive substring, so terms should be lower case like the
+// resource names they target.
+func (s *QdrantStore) KeywordSearch(ctx context.Context, terms []string, limit int, filter SearchFilter) ([]Document, error) {
+	if len(terms) == 0 || limit <= 0 {
+		return nil, nil
+	}
+	f := qdrantFilter(filter)
+	if f == nil {
+		f = &qdrant.Filter{}
+	}
+	for _, t := range terms {
+		f.Should = append(f.Should, qdrant.NewMatchText("content", t))
+	}
+	scroll := uint32(min(limit*keywordScrollFactor, maxKeywordScroll)) //nolint:gosec // bounded by maxKeywordScroll
+	points, err := s.client.Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: s.cfg.Collection,
+		Filter:         f,
+		Limit:          &scroll,
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qdrant: keyword search failed: %w", err)
+	}
+
+	docs := make([]Document, 0, len(points))
+	for _, p := range points {
+		docs = append(docs, documentFromPayload(p.GetId(), p.GetPayload()))
+	}
+	docs = rankBM25(docs, terms)
+	if len(docs) > limit {
+		docs = docs[:limit]
+	}
+	return docs, nil
+}
+
+// documentFromPayload converts a point's ID and payload into a Document.
+func documentFromPayload(id *qdrant.PointId, payload map[string]*qdrant.Value) Document {
+	doc := Document{
+		ID:       id.GetUuid(),
+		Metadata: make(map[string]string),
+	}
+	for k, v := range payload {
+		switch k {
+		case "content":
+			doc.Content = v.GetStringValue()
+		case "source":
+			doc.Source = v.GetStringValue()
+		default:
+			doc.Metadata[k] = v.GetStringValue()
+		}
+	}
+	return doc
+}
+
+// qdrantFilter translates f into a Qdrant filter with one Must keyword match
+// per non-empty field, keyed by the payload fields ingestion writes. It
+// returns nil for a zero filter so the query is unfiltered.
+func qdrantFilter(f SearchFilter) *qdrant.Filter {
+	var must []*qdrant.Condition
+	for _, field := range []struct{ key, value string }{
+		{"provider", f.Provider},
+		{"framework", f.Framework},
+		{"doc_type", f.DocType},
+		{"source", f.Source},
+	} {
+		if field.value != "" {
+			must = append(must, qdrant.NewMatch(field.key, field.value))
+		}
+	}
+	if len(must) == 0 {
+		return nil
+	}
+	return &qdrant.Filter{Must: must}
+}
+
 // Delete removes documents from the collection by their IDs.
 func (s *QdrantStore) Delete(ctx context.Context, ids []string) error {
 	pointIDs := make([]*qdrant.PointId, 0, len(ids))
@@ -186,6 +285,90 @@ func (s *QdrantStore) Delete(ctx context.Context, ids []string) error {
 	return nil
 }
 
+// listPageSize is the number of points ListBySource and ListChunks fetch
+// per scroll.
+const listPageSize = 256
+
+// ListBySource scrolls the IDs of the points whose "source" payload is
+// source, one page of listPageSize at a time.
+func (s *QdrantStore) ListBySource(ctx context.Context, source string) ([]string, error) {
+	if source == "" {
+		return nil, fmt.Errorf("qdrant: list by source: source must not be empty")
+	}
+	var ids []string
+	err := s.scrollSource(ctx, source, qdrant.NewWithPayload(false), false, func(p *qdrant.RetrievedPoint) {
+		ids = append(ids, p.GetId().GetUuid())
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qdrant: list by source failed: %w", err)
+	}
+	return ids, nil
+}
+
+// ListChunks scrolls the IDs, "chunk_hash" payloads, and vectors of the
+// points whose "source" payload is source, one page of listPageSize at a
+// time.
+func (s *QdrantStore) ListChunks(ctx context.Context, source string) ([]StoredChunk, error) {
+	if source == "" {
+		return nil, fmt.Errorf("qdrant: list chunks: source must not be empty")
+	}
+	var chunks []StoredChunk
+	err := s.scrollSource(ctx, source, qdrant.NewWithPayloadInclude("chunk_hash"), true, func(p *qdrant.RetrievedPoint) {
+		chunks = append(chunks, StoredChunk{
+			ID:        p.GetId().GetUuid(),
+			Hash:      p.GetPayload()["chunk_hash"].GetStringValue(),
+			Embedding: denseVector(p.GetVectors()),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qdrant: list chunks failed: %w", err)
+	}
+	return chunks, nil
+}
+
+// scrollSource calls visit with every point whose "source" payload is
+// source, with the payload fields selected by payload and, if vectors, the
+// vector.
+func (s *QdrantStore) scrollSource(ctx context.Context, source string, payload *qdrant.WithPayloadSelector, vectors bool, visit func(*qdrant.RetrievedPoint)) error {
+	var offset *qdrant.PointId
+	limit := uint32(listPageSize)
+	for {
+		points, err := s.client.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: s.cfg.Collection,
+			Filter:         qdrantFilter(SearchFilter{Source: source}),
+			Offset:         offset,
+			Limit:          &limit,
+			WithPayload:    payload,
+			WithVectors:    qdrant.NewWithVectors(vectors),
+		})
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped by the caller
+		}
+		for i, p := range points {
+			// The offset is inclusive, so each page after the first
+			// starts with the last point of the previous one.
+			if i == 0 && offset != nil && p.GetId().GetUuid() == offset.GetUuid() {
+				continue
+			}
+			visit(p)
+		}
+		if len(points) < listPageSize {
+			return nil
+		}
+		offset = points[len(points)-1].GetId()
+	}
+}
+
+// denseVector returns the unnamed dense vector of a point, or nil.
+func denseVector(v *qdrant.VectorsOutput) []float32 {
+	out := v.GetVector()
+	if dense := out.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+	// Older Qdrant servers fill only the deprecated Data field.
+	return out.GetData() //nolint:staticcheck // see above
+}
+
 // Ping calls the Qdrant HealthCheck RPC to verify the instance is reachable.
 // Returns nil on success, a descriptive error otherwise.
 func (s *QdrantStore) Ping(ctx context.Context) error {
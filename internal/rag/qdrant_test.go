@@ -0,0 +1,368 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// recordingClient is a qdrantClient that records upsert and query requests.
+// Other methods panic through the nil embedded interface.
+type recordingClient struct {
+	qdrantClient
+	upserts []*qdrant.UpsertPoints
+	queries []*qdrant.QueryPoints
+	scrolls []*qdrant.ScrollPoints
+	// points is returned by Scroll.
+	points []*qdrant.RetrievedPoint
+}
+
+func (c *recordingClient) Upsert(_ context.Context, req *qdrant.UpsertPoints) (*qdrant.UpdateResult, error) {
+	c.upserts = append(c.upserts, req)
+	return &qdrant.UpdateResult{}, nil
+}
+
+func (c *recordingClient) Scroll(_ context.Context, req *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, error) {
+	c.scrolls = append(c.scrolls, req)
+	return c.points, nil
+}
+
+func (c *recordingClient) Query(_ context.Context, req *qdrant.QueryPoints) ([]*qdrant.ScoredPoint, error) {
+	c.queries = append(c.queries, req)
+	return nil, nil
+}
+
+// ---------------------------------------------------------------------------
+// Upsert
+// ---------------------------------------------------------------------------
+
+func TestQdrantStore_UpsertSendsVectors(t *testing.T) {
+	t.Parallel()
+
+	client := &recordingClient{}
+	s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs", VectorSize: 3}}
+	docs := []Document{
+		{ID: "6f1c3a4e-0000-4000-8000-000000000001", Content: "a", Source: "https://example.com/a", Metadata: map[string]string{"provider": "aws"}},
+		{ID: "6f1c3a4e-0000-4000-8000-000000000002", Content: "b", Source: "https://example.com/b"},
+	}
+	embeddings := [][]float32{{0.1, 0.2, 0.3}, {0.4, 0.5, 0.6}}
+
+	if err := s.Upsert(context.Background(), docs, embeddings); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if len(client.upserts) != 1 {
+		t.Fatalf("expected one upsert request, got %d", len(client.upserts))
+	}
+	req := client.upserts[0]
+	if req.CollectionName != "docs" || len(req.Points) != 2 {
+		t.Fatalf("expected 2 points in docs, got %d in %q", len(req.Points), req.CollectionName)
+	}
+	for i, p := range req.Points {
+		if got := p.GetVectors().GetVector().GetDense().GetData(); !slices.Equal(got, embeddings[i]) {
+			t.Errorf("point %d: expected vector %v, got %v", i, embeddings[i], got)
+		}
+		if got := p.GetId().GetUuid(); got != docs[i].ID {
+			t.Errorf("point %d: expected id %s, got %s", i, docs[i].ID, got)
+		}
+		if got := p.GetPayload()["source"].GetStringValue(); got != docs[i].Source {
+			t.Errorf("point %d: expected source %s, got %s", i, docs[i].Source, got)
+		}
+	}
+	if got := req.Points[0].GetPayload()["provider"].GetStringValue(); got != "aws" {
+		t.Errorf("expected metadata in the payload, got provider %q", got)
+	}
+}
+
+func TestQdrantStore_UpsertRejectsBadEmbeddings(t *testing.T) {
+	t.Parallel()
+
+	doc := Document{ID: "6f1c3a4e-0000-4000-8000-000000000001"}
+	tests := []struct {
+		name       string
+		docs       []Document
+		embeddings [][]float32
+		wantErr    string
+	}{
+		{
+			name:       "fewer embeddings than docs",
+			docs:       []Document{doc, doc},
+			embeddings: [][]float32{{1, 2, 3}},
+			wantErr:    "embeddings length 1 does not match docs length 2",
+		},
+		{
+			name:       "wrong dimensions",
+			docs:       []Document{doc},
+			embeddings: [][]float32{{1, 2}},
+			wantErr:    "has 2 dimensions, collection \"docs\" expects 3",
+		},
+		{
+			name:       "empty vector",
+			docs:       []Document{doc},
+			embeddings: [][]float32{nil},
+			wantErr:    "has 0 dimensions",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			client := &recordingClient{}
+			s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs", VectorSize: 3}}
+			err := s.Upsert(context.Background(), tc.docs, tc.embeddings)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+			if len(client.upserts) != 0 {
+				t.Error("expected nothing sent to Qdrant")
+			}
+		})
+	}
+}
+
+func TestQdrantStore_UpsertEmptyBatch(t *testing.T) {
+	t.Parallel()
+
+	client := &recordingClient{}
+	s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs", VectorSize: 3}}
+	if err := s.Upsert(context.Background(), nil, nil); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if len(client.upserts) != 0 {
+		t.Error("expected an empty batch not to be sent")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Search filters
+// ---------------------------------------------------------------------------
+
+func TestQdrantFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		filter SearchFilter
+		// want lists the expected Must conditions as "key=value".
+		want []string
+	}{
+		{name: "zero", filter: SearchFilter{}},
+		{name: "provider", filter: SearchFilter{Provider: "azure"}, want: []string{"provider=azure"}},
+		{
+			name:   "every field",
+			filter: SearchFilter{Provider: "aws", Framework: "terraform", DocType: "guide", Source: "https://example.com/a"},
+			want:   []string{"provider=aws", "framework=terraform", "doc_type=guide", "source=https://example.com/a"},
+		},
+		{name: "framework and doc type", filter: SearchFilter{Framework: "atmos", DocType: "reference"}, want: []string{"framework=atmos", "doc_type=reference"}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			f := qdrantFilter(tc.filter)
+			if tc.want == nil {
+				if f != nil {
+					t.Fatalf("expected no filter, got %v", f)
+				}
+				return
+			}
+			if len(f.GetShould()) != 0 || len(f.GetMustNot()) != 0 {
+				t.Errorf("expected only Must conditions, got %v", f)
+			}
+			var got []string
+			for _, c := range f.GetMust() {
+				field := c.GetField()
+				got = append(got, field.GetKey()+"="+field.GetMatch().GetKeyword())
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestQdrantStore_SearchWithFilterSendsFilter(t *testing.T) {
+	t.Parallel()
+
+	client := &recordingClient{}
+	s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs"}}
+	ctx := context.Background()
+	if _, err := s.SearchWithFilter(ctx, []float32{1, 2}, 3, SearchFilter{Provider: "gcp"}); err != nil {
+		t.Fatalf("SearchWithFilter: %v", err)
+	}
+	if _, err := s.Search(ctx, []float32{1, 2}, 3); err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(client.queries) != 2 {
+		t.Fatalf("expected 2 queries, got %d", len(client.queries))
+	}
+	if must := client.queries[0].GetFilter().GetMust(); len(must) != 1 || must[0].GetField().GetMatch().GetKeyword() != "gcp" {
+		t.Errorf("expected a provider=gcp filter, got %v", client.queries[0].GetFilter())
+	}
+	if f := client.queries[1].GetFilter(); f != nil {
+		t.Errorf("expected Search to be unfiltered, got %v", f)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Keyword search
+// ---------------------------------------------------------------------------
+
+func TestQdrantStore_KeywordSearch(t *testing.T) {
+	t.Parallel()
+
+	point := func(id, content string) *qdrant.RetrievedPoint {
+		return &qdrant.RetrievedPoint{
+			Id:      qdrant.NewIDUUID(id),
+			Payload: qdrant.NewValueMap(map[string]any{"content": content, "source": "https://example.com/" + id, "provider": "aws"}),
+		}
+	}
+	client := &recordingClient{points: []*qdrant.RetrievedPoint{
+		point("6f1c3a4e-0000-4000-8000-000000000001", "aws_eks_cluster and aws_eks_node_group"),
+		point("6f1c3a4e-0000-4000-8000-000000000002", "aws_eks_node_group: aws_eks_node_group arguments"),
+		point("6f1c3a4e-0000-4000-8000-000000000003", "unrelated"),
+	}}
+	s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs"}}
+
+	got, err := s.KeywordSearch(context.Background(), []string{"aws_eks_node_group", "scaling"}, 1, SearchFilter{Provider: "aws"})
+	if err != nil {
+		t.Fatalf("KeywordSearch: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "6f1c3a4e-0000-4000-8000-000000000002" || got[0].Metadata["provider"] != "aws" {
+		t.Errorf("expected the best BM25 match with its payload, got %+v", got)
+	}
+
+	if len(client.scrolls) != 1 {
+		t.Fatalf("expected one scroll request, got %d", len(client.scrolls))
+	}
+	req := client.scrolls[0]
+	if req.GetLimit() != keywordScrollFactor {
+		t.Errorf("expected %d candidates, got %d", keywordScrollFactor, req.GetLimit())
+	}
+	var should []string
+	for _, c := range req.GetFilter().GetShould() {
+		should = append(should, c.GetField().GetKey()+"~"+c.GetField().GetMatch().GetText())
+	}
+	if want := []string{"content~aws_eks_node_group", "content~scaling"}; !slices.Equal(should, want) {
+		t.Errorf("expected Should %v, got %v", want, should)
+	}
+	if must := req.GetFilter().GetMust(); len(must) != 1 || must[0].GetField().GetMatch().GetKeyword() != "aws" {
+		t.Errorf("expected the provider filter as Must, got %v", must)
+	}
+
+	if docs, err := s.KeywordSearch(context.Background(), nil, 5, SearchFilter{}); err != nil || docs != nil || len(client.scrolls) != 1 {
+		t.Errorf("expected no request without terms, got %v, %v", docs, err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ListBySource
+// ---------------------------------------------------------------------------
+
+// scrollClient serves Scroll from ids like Qdrant does: in ID order, from an
+// inclusive offset, at most Limit points per page.
+type scrollClient struct {
+	qdrantClient
+	ids     []string
+	scrolls []*qdrant.ScrollPoints
+	// payloads holds the payload of each point, by ID.
+	payloads map[string]map[string]any
+	// vectors holds the vector of each point, by ID.
+	vectors map[string][]float32
+}
+
+func (c *scrollClient) Scroll(_ context.Context, req *qdrant.ScrollPoints) ([]*qdrant.RetrievedPoint, error) {
+	c.scrolls = append(c.scrolls, req)
+	start := 0
+	if req.GetOffset() != nil {
+		start, _ = slices.BinarySearch(c.ids, req.GetOffset().GetUuid())
+	}
+	end := min(start+int(req.GetLimit()), len(c.ids))
+	var points []*qdrant.RetrievedPoint
+	for _, id := range c.ids[start:end] {
+		p := &qdrant.RetrievedPoint{Id: qdrant.NewIDUUID(id), Payload: qdrant.NewValueMap(c.payloads[id])}
+		if v, ok := c.vectors[id]; ok && req.GetWithVectors().GetEnable() {
+			p.Vectors = &qdrant.VectorsOutput{VectorsOptions: &qdrant.VectorsOutput_Vector{
+				Vector: &qdrant.VectorOutput{Vector: &qdrant.VectorOutput_Dense{Dense: &qdrant.DenseVector{Data: v}}},
+			}}
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func TestQdrantStore_ListBySource(t *testing.T) {
+	t.Parallel()
+
+	for _, n := range []int{0, 3, listPageSize, 2*listPageSize + 5} {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("6f1c3a4e-0000-4000-8000-%012d", i)
+		}
+		client := &scrollClient{ids: ids}
+		s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs"}}
+
+		got, err := s.ListBySource(context.Background(), "https://example.com/a")
+		if err != nil {
+			t.Fatalf("ListBySource: %v", err)
+		}
+		if !slices.Equal(got, ids) {
+			t.Errorf("%d points: expected every ID once, got %d IDs", n, len(got))
+		}
+		if want := n/listPageSize + 1; len(client.scrolls) != want {
+			t.Errorf("%d points: expected %d pages, got %d", n, want, len(client.scrolls))
+		}
+		must := client.scrolls[0].GetFilter().GetMust()
+		if len(must) != 1 || must[0].GetField().GetKey() != "source" || must[0].GetField().GetMatch().GetKeyword() != "https://example.com/a" {
+			t.Errorf("expected a source filter, got %v", must)
+		}
+	}
+
+	s := &QdrantStore{client: &scrollClient{}, cfg: &QdrantConfig{Collection: "docs"}}
+	if _, err := s.ListBySource(context.Background(), ""); err == nil {
+		t.Error("expected an error for an empty source, which would list the whole collection")
+	}
+}
+
+func TestQdrantStore_ListChunks(t *testing.T) {
+	t.Parallel()
+
+	ids := make([]string, listPageSize+2)
+	client := &scrollClient{ids: ids, payloads: map[string]map[string]any{}, vectors: map[string][]float32{}}
+	for i := range ids {
+		ids[i] = fmt.Sprintf("6f1c3a4e-0000-4000-8000-%012d", i)
+		client.vectors[ids[i]] = []float32{float32(i), 1}
+		if i%2 == 0 {
+			client.payloads[ids[i]] = map[string]any{"chunk_hash": fmt.Sprintf("hash-%d", i)}
+		}
+	}
+	s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs"}}
+
+	got, err := s.ListChunks(context.Background(), "https://example.com/a")
+	if err != nil {
+		t.Fatalf("ListChunks: %v", err)
+	}
+	if len(got) != len(ids) || len(client.scrolls) != 2 {
+		t.Fatalf("expected %d chunks in 2 pages, got %d in %d", len(ids), len(got), len(client.scrolls))
+	}
+	for i, c := range got {
+		wantHash := ""
+		if i%2 == 0 {
+			wantHash = fmt.Sprintf("hash-%d", i)
+		}
+		if c.ID != ids[i] || c.Hash != wantHash || !slices.Equal(c.Embedding, []float32{float32(i), 1}) {
+			t.Errorf("chunk %d: got %+v", i, c)
+		}
+	}
+	req := client.scrolls[0]
+	if !req.GetWithVectors().GetEnable() || !slices.Equal(req.GetWithPayload().GetInclude().GetFields(), []string{"chunk_hash"}) {
+		t.Errorf("expected vectors and only the chunk hash requested, got %v", req)
+	}
+
+	if _, err := s.ListChunks(context.Background(), ""); err == nil {
+		t.Error("expected an error for an empty source, which would list the whole collection")
+	}
+}
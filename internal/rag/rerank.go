@@ -0,0 +1,147 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Reranker reorders retrieved documents by their relevance to a query. The
+// agent retrieves more candidates than it injects and lets a Reranker pick
+// the best of them.
+type Reranker interface {
+	// Rerank returns at most topN of docs, most relevant to query first.
+	Rerank(ctx context.Context, query string, docs []Document, topN int) ([]Document, error)
+}
+
+// LexicalReranker scores each document by the fraction of the query's
+// keyword terms (see keywordTerms) its content contains. It needs no model
+// and no network, so it is cheap enough to run on every query.
+type LexicalReranker struct{}
+
+// NewLexicalReranker returns a LexicalReranker.
+func NewLexicalReranker() *LexicalReranker {
+	return &LexicalReranker{}
+}
+
+// Rerank implements Reranker. Each document's Score is set to its overlap
+// (0.0–1.0); ties keep the retrieval order. A query with no keyword terms
+// leaves the order unchanged.
+func (r *LexicalReranker) Rerank(_ context.Context, query string, docs []Document, topN int) ([]Document, error) {
+	terms := keywordTerms(query)
+	if len(terms) == 0 {
+		return truncateDocs(docs, topN), nil
+	}
+	out := make([]Document, len(docs))
+	for i, d := range docs {
+		content := strings.ToLower(d.Content)
+		matched := 0
+		for _, t := range terms {
+			if strings.Contains(content, t) {
+				matched++
+			}
+		}
+		out[i] = d
+		out[i].Score = float32(matched) / float32(len(terms))
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return truncateDocs(out, topN), nil
+}
+
+// maxRerankDocChars caps how much of each document the LLM reranker puts in
+// its prompt.
+const maxRerankDocChars = 1500
+
+// LLMReranker asks a chat model to score every document's relevance from 0
+// to 10, in a single call per query.
+type LLMReranker struct {
+	// model scores the documents.
+	model model.BaseChatModel
+}
+
+// NewLLMReranker returns an LLMReranker backed by m.
+func NewLLMReranker(m model.BaseChatModel) (*LLMReranker, error) {
+	if m == nil {
+		return nil, fmt.Errorf("rag: reranker model must not be nil")
+	}
+	return &LLMReranker{model: m}, nil
+}
+
+// Rerank implements Reranker. Each document's Score is set to the model's
+// score divided by 10; ties keep the retrieval order. An error is returned
+// when the model fails or its reply cannot be parsed.
+func (r *LLMReranker) Rerank(ctx context.Context, query string, docs []Document, topN int) ([]Document, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+	reply, err := r.model.Generate(ctx, []*schema.Message{
+		schema.SystemMessage("You rank documentation excerpts by how useful they are for answering a Terraform question. " +
+			"Reply with only a JSON array of integers from 0 (irrelevant) to 10 (directly answers the question), " +
+			"one per excerpt, in the order the excerpts are given."),
+		schema.UserMessage(rerankPrompt(query, docs)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rag: rerank model call failed: %w", err)
+	}
+	scores, err := parseRerankScores(reply.Content, len(docs))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Document, len(docs))
+	for i, d := range docs {
+		out[i] = d
+		out[i].Score = float32(scores[i]) / 10
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return truncateDocs(out, topN), nil
+}
+
+// rerankPrompt lists query and the numbered, truncated docs for the LLM
+// reranker.
+func rerankPrompt(query string, docs []Document) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Question: %s\n\n", query)
+	for i, d := range docs {
+		content := d.Content
+		if len(content) > maxRerankDocChars {
+			content = content[:maxRerankDocChars]
+		}
+		fmt.Fprintf(&b, "Excerpt %d (%s):\n%s\n\n", i+1, d.Source, content)
+	}
+	fmt.Fprintf(&b, "Reply with a JSON array of %d scores.", len(docs))
+	return b.String()
+}
+
+// parseRerankScores extracts the JSON array of n scores from an LLM reply,
+// ignoring any text around it. Scores are clamped to 0–10.
+func parseRerankScores(reply string, n int) ([]float64, error) {
+	start, end := strings.IndexByte(reply, '['), strings.LastIndexByte(reply, ']')
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("rag: rerank reply has no JSON array: %q", reply)
+	}
+	var scores []float64
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &scores); err != nil {
+		return nil, fmt.Errorf("rag: failed to parse rerank scores: %w", err)
+	}
+	if len(scores) != n {
+		return nil, fmt.Errorf("rag: rerank reply has %d scores, expected %d", len(scores), n)
+	}
+	for i, s := range scores {
+		scores[i] = min(max(s, 0), 10)
+	}
+	return scores, nil
+}
+
+// truncateDocs returns the first n docs, or all of them when n is not
+// positive or exceeds len(docs).
+func truncateDocs(docs []Document, n int) []Document {
+	if n > 0 && len(docs) > n {
+		return docs[:n]
+	}
+	return docs
+}
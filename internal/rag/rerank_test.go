@@ -0,0 +1,136 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// replyModel answers every Generate call with a fixed reply and records the
+// prompt it was sent.
+type replyModel struct {
+	reply  string
+	err    error
+	prompt string
+}
+
+func (m *replyModel) Generate(_ context.Context, in []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	for _, msg := range in {
+		m.prompt += msg.Content
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return schema.AssistantMessage(m.reply, nil), nil
+}
+
+func (m *replyModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return nil, errors.New("not implemented")
+}
+
+// ---------------------------------------------------------------------------
+// LexicalReranker
+// ---------------------------------------------------------------------------
+
+func TestLexicalReranker(t *testing.T) {
+	t.Parallel()
+
+	candidates := []Document{
+		{ID: "vpc", Content: "aws_vpc with cidr blocks"},
+		{ID: "nodegroup", Content: "aws_eks_node_group scaling_config for an EKS nodegroup"},
+		{ID: "cluster", Content: "aws_eks_cluster: EKS control plane"},
+		{ID: "iam", Content: "aws_iam_role trust policy"},
+	}
+	tests := []struct {
+		name  string
+		query string
+		topN  int
+		want  string
+	}{
+		{name: "most terms first", query: "scale the EKS nodegroup", topN: 0, want: "nodegroup,cluster,vpc,iam"},
+		{name: "ties keep retrieval order", query: "iam vpc", topN: 0, want: "vpc,iam,nodegroup,cluster"},
+		{name: "truncated to topN", query: "scale the EKS nodegroup", topN: 2, want: "nodegroup,cluster"},
+		{name: "no terms keeps order", query: "how do I", topN: 3, want: "vpc,nodegroup,cluster"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := NewLexicalReranker().Rerank(context.Background(), tc.query, candidates, tc.topN)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(ids(got), ",") != tc.want {
+				t.Errorf("expected %s, got %v", tc.want, ids(got))
+			}
+		})
+	}
+
+	got, _ := NewLexicalReranker().Rerank(context.Background(), "EKS nodegroup scaling_config", candidates, 1)
+	if got[0].Score != 1 {
+		t.Errorf("expected a document matching every term to score 1, got %v", got[0].Score)
+	}
+	if candidates[1].Score != 0 {
+		t.Error("expected the input documents to be left unchanged")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// LLMReranker
+// ---------------------------------------------------------------------------
+
+func TestLLMReranker(t *testing.T) {
+	t.Parallel()
+
+	candidates := docs("a", "b", "c")
+	m := &replyModel{reply: "Scores:\n```json\n[2, 9, 14]\n```"}
+	r, err := NewLLMReranker(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Rerank(context.Background(), "which one?", candidates, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(ids(got), ",") != "c,b" {
+		t.Errorf("expected c,b, got %v", ids(got))
+	}
+	if got[0].Score != 1 || got[1].Score != 0.9 {
+		t.Errorf("expected scores clamped and scaled to 1 and 0.9, got %v and %v", got[0].Score, got[1].Score)
+	}
+	if !strings.Contains(m.prompt, "which one?") || !strings.Contains(m.prompt, "Excerpt 3") {
+		t.Errorf("expected the query and every excerpt in the prompt:\n%s", m.prompt)
+	}
+}
+
+func TestLLMReranker_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		model *replyModel
+	}{
+		{name: "model error", model: &replyModel{err: errors.New("boom")}},
+		{name: "no array", model: &replyModel{reply: "b is best"}},
+		{name: "wrong count", model: &replyModel{reply: "[1, 2]"}},
+		{name: "not numbers", model: &replyModel{reply: `["high", "low", "low"]`}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			r, _ := NewLLMReranker(tc.model)
+			if _, err := r.Rerank(context.Background(), "q", docs("a", "b", "c"), 2); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if _, err := NewLLMReranker(nil); err == nil {
+		t.Error("expected an error for a nil model")
+	}
+}
@@ -3,8 +3,29 @@ package rag
 import (
 	"context"
 	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/sync/errgroup"
 )
 
+// RetrieverConfig holds the settings of a DefaultRetriever.
+type RetrieverConfig struct {
+	// TopK is the number of results to return when Retrieve is called with
+	// topK 0 (default 5).
+	TopK int
+
+	// Hybrid merges keyword matches on document content into the dense
+	// results with reciprocal rank fusion, so exact names such as
+	// aws_eks_node_group are found even when the embedding drifts. The store
+	// must implement KeywordSearcher.
+	Hybrid bool
+}
+
+// hybridCandidates is how many results per requested result each search of
+// a hybrid retrieval fetches before fusion.
+const hybridCandidates = 2
+
 // DefaultRetriever implements the Retriever interface by combining an Embedder
 // and a VectorStore. It embeds the query at retrieval time and delegates
 // similarity search to the store.
@@ -15,31 +36,48 @@ type DefaultRetriever struct {
 	// store performs the vector similarity search.
 	store VectorStore
 
+	// keywords performs the keyword search; nil unless hybrid retrieval is
+	// enabled.
+	keywords KeywordSearcher
+
 	// defaultTopK is the number of results to return when the caller passes 0.
 	defaultTopK int
 }
 
-// NewRetriever constructs a DefaultRetriever from the given Embedder and VectorStore.
-// defaultTopK sets the fallback result count when Retrieve is called with topK=0.
-func NewRetriever(embedder Embedder, store VectorStore, defaultTopK int) (*DefaultRetriever, error) {
+// NewRetriever constructs a DefaultRetriever from the given Embedder and
+// VectorStore. A nil cfg uses the defaults.
+func NewRetriever(embedder Embedder, store VectorStore, cfg *RetrieverConfig) (*DefaultRetriever, error) {
 	if embedder == nil {
 		return nil, fmt.Errorf("rag: embedder must not be nil")
 	}
 	if store == nil {
 		return nil, fmt.Errorf("rag: store must not be nil")
 	}
-	if defaultTopK <= 0 {
-		defaultTopK = 5
+	if cfg == nil {
+		cfg = &RetrieverConfig{}
 	}
-	return &DefaultRetriever{
+	r := &DefaultRetriever{
 		embedder:    embedder,
 		store:       store,
-		defaultTopK: defaultTopK,
-	}, nil
+		defaultTopK: cfg.TopK,
+	}
+	if r.defaultTopK <= 0 {
+		r.defaultTopK = 5
+	}
+	if cfg.Hybrid {
+		ks, ok := store.(KeywordSearcher)
+		if !ok {
+			return nil, fmt.Errorf("rag: hybrid retrieval requires a store that supports keyword search, got %T", store)
+		}
+		r.keywords = ks
+	}
+	return r, nil
 }
 
 // Retrieve embeds the query and returns the top-k most relevant documents.
 // If topK is 0 the defaultTopK configured at construction time is used.
+// Queries that name a single cloud provider (see detectProvider) search only
+// that provider's documents first.
 func (r *DefaultRetriever) Retrieve(ctx context.Context, query string, topK int) ([]Document, error) {
 	if topK <= 0 {
 		topK = r.defaultTopK
@@ -53,10 +91,94 @@ func (r *DefaultRetriever) Retrieve(ctx context.Context, query string, topK int)
 		return nil, fmt.Errorf("rag: embedder returned empty result for query")
 	}
 
-	docs, err := r.store.Search(ctx, embeddings[0], topK)
-	if err != nil {
-		return nil, fmt.Errorf("rag: vector search failed: %w", err)
+	// Restrict the search to the provider the query names, if exactly one.
+	// When that provider has no documents ingested, search everything.
+	if provider := detectProvider(query); provider != "" {
+		docs, err := r.search(ctx, query, embeddings[0], topK, SearchFilter{Provider: provider})
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) > 0 {
+			return docs, nil
+		}
 	}
 
-	return docs, nil
+	return r.search(ctx, query, embeddings[0], topK, SearchFilter{})
+}
+
+// search returns the topK documents matching filter: the dense results, or
+// in hybrid mode the dense and keyword results fused with fuseRRF.
+func (r *DefaultRetriever) search(ctx context.Context, query string, embedding []float32, topK int, filter SearchFilter) ([]Document, error) {
+	terms := keywordTerms(query)
+	if r.keywords == nil || len(terms) == 0 {
+		docs, err := r.store.SearchWithFilter(ctx, embedding, topK, filter)
+		if err != nil {
+			return nil, fmt.Errorf("rag: vector search failed: %w", err)
+		}
+		return docs, nil
+	}
+
+	var dense, keyword []Document
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		var err error
+		dense, err = r.store.SearchWithFilter(gctx, embedding, topK*hybridCandidates, filter)
+		if err != nil {
+			return fmt.Errorf("rag: vector search failed: %w", err)
+		}
+		return nil
+	})
+	g.Go(func() error {
+		var err error
+		keyword, err = r.keywords.KeywordSearch(gctx, terms, topK*hybridCandidates, filter)
+		if err != nil {
+			return fmt.Errorf("rag: keyword search failed: %w", err)
+		}
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err //nolint:wrapcheck // both searches wrap their errors
+	}
+	return fuseRRF([][]Document{dense, keyword}, topK), nil
+}
+
+// providerKeywords maps query words to the provider label written at
+// ingestion. A keyword ending in "_" matches any word it prefixes, so
+// "aws_" matches resource types such as aws_s3_bucket.
+var providerKeywords = map[string]string{
+	"aws":      "aws",
+	"aws_":     "aws",
+	"eks":      "aws",
+	"azure":    "azure",
+	"azurerm":  "azure",
+	"azurerm_": "azure",
+	"aks":      "azure",
+	"gcp":      "gcp",
+	"google_":  "gcp",
+	"gke":      "gcp",
+}
+
+// detectProvider returns the cloud provider a query refers to, or "" when
+// it names none or more than one.
+func detectProvider(query string) string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	found := ""
+	for _, w := range words {
+		provider := providerKeywords[w]
+		if provider == "" {
+			if i := strings.IndexByte(w, '_'); i > 0 {
+				provider = providerKeywords[w[:i+1]]
+			}
+		}
+		switch {
+		case provider == "":
+		case found == "":
+			found = provider
+		case found != provider:
+			return ""
+		}
+	}
+	return found
 }
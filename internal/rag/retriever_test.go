@@ -0,0 +1,212 @@
+package rag
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// fakeEmbedder returns a fixed one-dimensional vector for every text.
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range texts {
+		out[i] = []float32{1}
+	}
+	return out, nil
+}
+
+// filterStore is a VectorStore holding documents labelled by provider. Its
+// searches return the first topK matching documents in order and record
+// their filter.
+type filterStore struct {
+	docs    []Document
+	filters []SearchFilter
+}
+
+func (s *filterStore) Upsert(context.Context, []Document, [][]float32) error  { return nil }
+func (s *filterStore) Delete(context.Context, []string) error                 { return nil }
+func (s *filterStore) Close() error                                           { return nil }
+func (s *filterStore) ListBySource(context.Context, string) ([]string, error) { return nil, nil }
+
+func (s *filterStore) Search(ctx context.Context, q []float32, topK int) ([]Document, error) {
+	return s.SearchWithFilter(ctx, q, topK, SearchFilter{})
+}
+
+func (s *filterStore) SearchWithFilter(_ context.Context, _ []float32, topK int, f SearchFilter) ([]Document, error) {
+	s.filters = append(s.filters, f)
+	var out []Document
+	for _, d := range s.docs {
+		if len(out) < topK && (f.Provider == "" || d.Metadata["provider"] == f.Provider) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// Provider detection
+// ---------------------------------------------------------------------------
+
+func TestDetectProvider(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "How do I enable autoscaling on AKS?", want: "azure"},
+		{query: "azurerm_kubernetes_cluster node pools", want: "azure"},
+		{query: "configure the azurerm provider features block", want: "azure"},
+		{query: "EKS managed node group with launch template", want: "aws"},
+		{query: "aws_s3_bucket versioning", want: "aws"},
+		{query: "GKE private cluster", want: "gcp"},
+		{query: "google_container_cluster release channel", want: "gcp"},
+		{query: "Migrate from EKS to GKE", want: ""},
+		{query: "aws_iam_role and azurerm_role_assignment", want: ""},
+		{query: "EKS cluster with aws_eks_node_group", want: "aws"},
+		{query: "how does remote state locking work", want: ""},
+		{query: "this breaks when terraform makes a plan", want: ""},
+		{query: "", want: ""},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.query, func(t *testing.T) {
+			t.Parallel()
+			if got := detectProvider(tc.query); got != tc.want {
+				t.Errorf("detectProvider(%q) = %q, want %q", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Retrieve
+// ---------------------------------------------------------------------------
+
+func TestRetrieve_FiltersByProvider(t *testing.T) {
+	t.Parallel()
+
+	docs := []Document{
+		{ID: "1", Metadata: map[string]string{"provider": "aws"}},
+		{ID: "2", Metadata: map[string]string{"provider": "azure"}},
+		{ID: "3", Metadata: map[string]string{"provider": "generic"}},
+	}
+	tests := []struct {
+		name        string
+		query       string
+		docs        []Document
+		wantFilters []SearchFilter
+		wantIDs     []string
+	}{
+		{
+			name:        "single provider",
+			query:       "AKS upgrade channel",
+			docs:        docs,
+			wantFilters: []SearchFilter{{Provider: "azure"}},
+			wantIDs:     []string{"2"},
+		},
+		{
+			name:        "ambiguous",
+			query:       "EKS vs AKS",
+			docs:        docs,
+			wantFilters: []SearchFilter{{}},
+			wantIDs:     []string{"1", "2", "3"},
+		},
+		{
+			name:        "no documents for the provider",
+			query:       "google_storage_bucket lifecycle",
+			docs:        docs,
+			wantFilters: []SearchFilter{{Provider: "gcp"}, {}},
+			wantIDs:     []string{"1", "2", "3"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			store := &filterStore{docs: tc.docs}
+			r, err := NewRetriever(fakeEmbedder{}, store, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.Retrieve(context.Background(), tc.query, 0)
+			if err != nil {
+				t.Fatalf("Retrieve: %v", err)
+			}
+			if !slices.Equal(ids(got), tc.wantIDs) {
+				t.Errorf("expected documents %v, got %v", tc.wantIDs, ids(got))
+			}
+			if !slices.Equal(store.filters, tc.wantFilters) {
+				t.Errorf("expected filters %v, got %v", tc.wantFilters, store.filters)
+			}
+		})
+	}
+}
+
+// keywordStore is a filterStore whose dense search returns docs in order and
+// whose keyword search ranks docs with rankBM25.
+type keywordStore struct {
+	filterStore
+	terms [][]string
+}
+
+func (s *keywordStore) KeywordSearch(_ context.Context, terms []string, limit int, f SearchFilter) ([]Document, error) {
+	s.terms = append(s.terms, terms)
+	var candidates []Document
+	for _, d := range s.docs {
+		if f.Provider == "" || d.Metadata["provider"] == f.Provider {
+			candidates = append(candidates, d)
+		}
+	}
+	out := rankBM25(candidates, terms)
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func TestRetrieve_Hybrid(t *testing.T) {
+	t.Parallel()
+
+	// The dense search ranks the exact resource page last.
+	store := &keywordStore{filterStore: filterStore{docs: []Document{
+		{ID: "cluster", Content: "aws_eks_cluster arguments"},
+		{ID: "addon", Content: "aws_eks_addon arguments"},
+		{ID: "fargate", Content: "aws_eks_fargate_profile arguments"},
+		{ID: "nodegroup", Content: "aws_eks_node_group arguments"},
+	}}}
+	r, err := NewRetriever(fakeEmbedder{}, store, &RetrieverConfig{TopK: 2, Hybrid: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.Retrieve(context.Background(), "aws_eks_node_group scaling_config", 0)
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	// The exact match is only fourth in the dense results, but first in the
+	// keyword results, so it outranks the dense top result.
+	if want := []string{"nodegroup", "cluster"}; !slices.Equal(ids(got), want) {
+		t.Errorf("expected %v, got %v", want, ids(got))
+	}
+	if want := []string{"aws_eks_node_group", "scaling_config"}; len(store.terms) == 0 || !slices.Equal(store.terms[0], want) {
+		t.Errorf("expected keyword terms %v, got %v", want, store.terms)
+	}
+
+	// Without hybrid mode the keyword search is never used.
+	plain, _ := NewRetriever(fakeEmbedder{}, store, &RetrieverConfig{TopK: 2})
+	store.terms = nil
+	got, _ = plain.Retrieve(context.Background(), "aws_eks_node_group scaling_config", 0)
+	if want := []string{"cluster", "addon"}; !slices.Equal(ids(got), want) || store.terms != nil {
+		t.Errorf("expected dense results %v only, got %v (keyword searches %v)", want, ids(got), store.terms)
+	}
+}
+
+func TestNewRetriever_HybridNeedsKeywordSearch(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewRetriever(fakeEmbedder{}, &filterStore{}, &RetrieverConfig{Hybrid: true}); err == nil {
+		t.Error("expected an error for a store without keyword search")
+	}
+}
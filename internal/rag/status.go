@@ -0,0 +1,87 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// StatusFields are the payload fields CollectionStatus breaks points down by,
+// in display order.
+var StatusFields = []string{"provider", "framework", "doc_type", "preset"}
+
+// NoValue is the Breakdown key for points without the field.
+const NoValue = "(none)"
+
+// CollectionStatus describes what a collection holds.
+type CollectionStatus struct {
+	// Collection is the collection name.
+	Collection string `json:"collection"`
+	// VectorSize is the dimensionality of the collection's vectors.
+	VectorSize uint64 `json:"vector_size"`
+	// Points is the number of points in the collection.
+	Points uint64 `json:"points"`
+	// Breakdown counts points by value for each of StatusFields, e.g.
+	// Breakdown["provider"]["aws"].
+	Breakdown map[string]map[string]uint64 `json:"breakdown"`
+}
+
+// Status reports the collection's vector size, point count, and the number
+// of points per value of each of StatusFields. It scrolls every point's
+// payload, listPageSize at a time, so the counts are exact. A collection
+// that does not exist is an error; Status never creates one.
+func (s *QdrantStore) Status(ctx context.Context) (*CollectionStatus, error) {
+	exists, err := s.client.CollectionExists(ctx, s.cfg.Collection)
+	if err != nil {
+		return nil, fmt.Errorf("qdrant: failed to check collection existence: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("qdrant: collection %q does not exist; run tfai ingest first", s.cfg.Collection)
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.cfg.Collection)
+	if err != nil {
+		return nil, fmt.Errorf("qdrant: failed to read collection %q: %w", s.cfg.Collection, err)
+	}
+
+	status := &CollectionStatus{
+		Collection: s.cfg.Collection,
+		VectorSize: info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize(),
+		Breakdown:  make(map[string]map[string]uint64, len(StatusFields)),
+	}
+	for _, field := range StatusFields {
+		status.Breakdown[field] = make(map[string]uint64)
+	}
+
+	var offset *qdrant.PointId
+	limit := uint32(listPageSize)
+	for {
+		points, err := s.client.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: s.cfg.Collection,
+			Offset:         offset,
+			Limit:          &limit,
+			WithPayload:    qdrant.NewWithPayloadInclude(StatusFields...),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("qdrant: status scroll failed: %w", err)
+		}
+		for i, p := range points {
+			// The offset is inclusive; see ListBySource.
+			if i == 0 && offset != nil && p.GetId().GetUuid() == offset.GetUuid() {
+				continue
+			}
+			status.Points++
+			for _, field := range StatusFields {
+				value := p.GetPayload()[field].GetStringValue()
+				if value == "" {
+					value = NoValue
+				}
+				status.Breakdown[field][value]++
+			}
+		}
+		if len(points) < listPageSize {
+			return status, nil
+		}
+		offset = points[len(points)-1].GetId()
+	}
+}
@@ -0,0 +1,103 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// statusClient is a scrollClient that also reports whether the collection
+// exists and its vector size.
+type statusClient struct {
+	*scrollClient
+	exists bool
+	size   uint64
+	err    error
+}
+
+func (c *statusClient) CollectionExists(context.Context, string) (bool, error) {
+	return c.exists, c.err
+}
+
+func (c *statusClient) GetCollectionInfo(context.Context, string) (*qdrant.CollectionInfo, error) {
+	return &qdrant.CollectionInfo{Config: &qdrant.CollectionConfig{Params: &qdrant.CollectionParams{
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{Size: c.size, Distance: qdrant.Distance_Cosine}),
+	}}}, nil
+}
+
+func TestQdrantStore_Status(t *testing.T) {
+	t.Parallel()
+
+	// More points than one page, so the breakdown must span pages without
+	// counting the repeated offset point twice.
+	n := listPageSize + 10
+	client := &statusClient{scrollClient: &scrollClient{payloads: make(map[string]map[string]any)}, exists: true, size: 768}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("6f1c3a4e-0000-4000-8000-%012d", i)
+		client.ids = append(client.ids, id)
+		payload := map[string]any{"framework": "terraform", "doc_type": "resource"}
+		switch {
+		case i < 200:
+			payload["provider"] = "aws"
+			if i < 40 {
+				payload["preset"] = "aws-core"
+			}
+		case i < 250:
+			payload["provider"] = "azurerm"
+			payload["doc_type"] = "guide"
+		}
+		client.payloads[id] = payload
+	}
+
+	s := &QdrantStore{client: client, cfg: &QdrantConfig{Collection: "docs"}}
+	got, err := s.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	want := &CollectionStatus{
+		Collection: "docs",
+		VectorSize: 768,
+		Points:     uint64(n),
+		Breakdown: map[string]map[string]uint64{
+			"provider":  {"aws": 200, "azurerm": 50, NoValue: uint64(n - 250)},
+			"framework": {"terraform": uint64(n)},
+			"doc_type":  {"resource": uint64(n - 50), "guide": 50},
+			"preset":    {"aws-core": 40, NoValue: uint64(n - 40)},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if len(client.scrolls) != 2 || client.scrolls[0].GetFilter() != nil {
+		t.Errorf("expected two unfiltered pages, got %d", len(client.scrolls))
+	}
+}
+
+func TestQdrantStore_StatusErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		client *statusClient
+	}{
+		{name: "missing collection", client: &statusClient{scrollClient: &scrollClient{}}},
+		{name: "unreachable", client: &statusClient{scrollClient: &scrollClient{}, err: errors.New("connection refused")}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := &QdrantStore{client: tc.client, cfg: &QdrantConfig{Collection: "docs"}}
+			if _, err := s.Status(context.Background()); err == nil {
+				t.Error("expected an error")
+			}
+			if len(tc.client.scrolls) != 0 {
+				t.Error("expected no scroll before the collection is found")
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+// Package secretscan finds hardcoded credentials in Terraform sources so they
+// can be redacted before workspace files are sent to a model, flagged when
+// files are saved through the API, and listed by `tfai scan`.
+//
+// A Scanner runs a list of Detectors. The defaults cover AWS access keys,
+// Azure connection strings, GCP service-account keys, PEM private keys, and
+// generic high-entropy strings; callers may supply their own. False positives
+// are suppressed with an Allowlist.
+package secretscan
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Detector finds one kind of secret.
+type Detector interface {
+	// Name is the finding type, used in redaction markers and reports.
+	Name() string
+	// Find returns the [start, end) byte offsets of every match in content.
+	Find(content string) [][2]int
+}
+
+// Finding is one detected secret.
+type Finding struct {
+	// Detector is the Name of the detector that matched.
+	Detector string
+	// File is the path the content came from, as passed by the caller.
+	File string
+	// Line is the 1-based line on which the match starts.
+	Line int
+	// Preview is a masked form of the match that is safe to log.
+	Preview string
+	// start and end are the byte offsets of the match in the scanned content.
+	start, end int
+	// value is the matched text, used for allowlist comparison.
+	value string
+	// order is the index of the detector in the scanner, for tie-breaking.
+	order int
+}
+
+// Scanner runs detectors over content. A Scanner is immutable and safe for
+// concurrent use.
+type Scanner struct {
+	// detectors run in order; on overlapping matches the earlier one wins.
+	detectors []Detector
+	// allow suppresses known false positives. Nil allows nothing.
+	allow *Allowlist
+}
+
+// New returns a Scanner running detectors in order.
+func New(detectors ...Detector) *Scanner {
+	return &Scanner{detectors: detectors}
+}
+
+// Default returns a Scanner with DefaultDetectors and no allowlist.
+func Default() *Scanner {
+	return New(DefaultDetectors()...)
+}
+
+// WithAllowlist returns a copy of s that ignores findings allowed by a.
+func (s *Scanner) WithAllowlist(a *Allowlist) *Scanner {
+	return &Scanner{detectors: s.detectors, allow: a}
+}
+
+// Scan returns the findings in content, ordered by position. file labels the
+// findings and is matched against allowlist path patterns.
+func (s *Scanner) Scan(file, content string) []Finding {
+	if s.allow.skipsPath(file) {
+		return nil
+	}
+	var all []Finding
+	for i, d := range s.detectors {
+		if s.allow.skipsDetector(d.Name()) {
+			continue
+		}
+		for _, span := range d.Find(content) {
+			all = append(all, Finding{Detector: d.Name(), File: file, start: span[0], end: span[1], order: i})
+		}
+	}
+	// Earliest first; on equal starts the longer match, then the earlier
+	// detector, wins.
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		if a.end != b.end {
+			return a.end > b.end
+		}
+		return a.order < b.order
+	})
+
+	var out []Finding
+	end := -1
+	for _, f := range all {
+		if f.start < end {
+			continue // overlaps a finding already kept
+		}
+		f.value = content[f.start:f.end]
+		if s.allow.allowsValue(f.value) {
+			continue
+		}
+		f.Line = 1 + strings.Count(content[:f.start], "\n")
+		f.Preview = mask(f.value)
+		out = append(out, f)
+		end = f.end
+	}
+	return out
+}
+
+// Redact replaces every finding in content with "<redacted:TYPE>" and returns
+// the redacted content with the findings.
+func (s *Scanner) Redact(file, content string) (string, []Finding) {
+	findings := s.Scan(file, content)
+	if len(findings) == 0 {
+		return content, nil
+	}
+	var b strings.Builder
+	last := 0
+	for _, f := range findings {
+		b.WriteString(content[last:f.start])
+		b.WriteString("<redacted:" + f.Detector + ">")
+		last = f.end
+	}
+	b.WriteString(content[last:])
+	return b.String(), findings
+}
+
+// maxScanFileBytes skips files larger than this in ScanDir.
+const maxScanFileBytes = 1 << 20 // 1 MiB
+
+// ScanDir scans the Terraform sources (.tf, .tfvars, .tf.json, .hcl) under
+// dir, skipping .terraform, .git, and .tfai. Findings carry slash-separated
+// paths relative to dir and are ordered by file, then line.
+func (s *Scanner) ScanDir(dir string) ([]Finding, error) {
+	var out []Finding
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // skip unreadable entries
+		}
+		if d.IsDir() {
+			switch d.Name() {
+			case ".terraform", ".git", ".tfai":
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !isTerraformSource(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.Size() > maxScanFileBytes {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		out = append(out, s.Scan(filepath.ToSlash(rel), string(content))...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("secretscan: scan %s: %w", dir, err)
+	}
+	return out, nil
+}
+
+// isTerraformSource reports whether name is a file ScanDir inspects.
+func isTerraformSource(name string) bool {
+	for _, ext := range []string{".tf", ".tfvars", ".tf.json", ".hcl"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// mask returns a preview of a secret that keeps only its first four
+// characters.
+func mask(v string) string {
+	if len(v) <= 8 {
+		return "****"
+	}
+	return v[:4] + "****"
+}
+
+// Summary renders findings as "type@file:line" entries joined by ", ",
+// suitable for logs and response headers.
+func Summary(findings []Finding) string {
+	parts := make([]string, 0, len(findings))
+	for _, f := range findings {
+		parts = append(parts, fmt.Sprintf("%s@%s:%d", f.Detector, f.File, f.Line))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ---------------------------------------------------------------------------
+// Detectors
+// ---------------------------------------------------------------------------
+
+// DefaultDetectors returns the built-in detectors. More specific detectors
+// come first so a GCP key is reported as such rather than as a bare private
+// key or high-entropy string.
+func DefaultDetectors() []Detector {
+	return []Detector{
+		GCPServiceAccount(),
+		PrivateKey(),
+		AWSAccessKey(),
+		AzureConnectionString(),
+		HighEntropy(DefaultEntropyThreshold, DefaultEntropyMinLength),
+	}
+}
+
+// regexDetector matches any of its patterns. When a pattern has a capture
+// group, only group 1 is reported, so the key name around a value survives
+// redaction.
+type regexDetector struct {
+	// name is the finding type.
+	name string
+	// patterns are tried in order.
+	patterns []*regexp.Regexp
+}
+
+// Name implements Detector.
+func (d *regexDetector) Name() string { return d.name }
+
+// Find implements Detector.
+func (d *regexDetector) Find(content string) [][2]int {
+	var out [][2]int
+	for _, re := range d.patterns {
+		for _, m := range re.FindAllStringSubmatchIndex(content, -1) {
+			if len(m) >= 4 && m[2] >= 0 {
+				out = append(out, [2]int{m[2], m[3]})
+				continue
+			}
+			out = append(out, [2]int{m[0], m[1]})
+		}
+	}
+	return out
+}
+
+// AWSAccessKey detects AWS access key IDs and secret access keys assigned to
+// an aws_secret_access_key-style attribute.
+func AWSAccessKey() Detector {
+	return &regexDetector{name: "aws_access_key", patterns: []*regexp.Regexp{
+		regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[A-Z0-9]{16}\b`),
+		regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[=:]\s*["']([A-Za-z0-9/+=]{40})["']`),
+	}}
+}
+
+// AzureConnectionString detects storage account, Service Bus / Event Hubs,
+// and SQL connection strings that embed a key or password.
+func AzureConnectionString() Detector {
+	return &regexDetector{name: "azure_connection_string", patterns: []*regexp.Regexp{
+		regexp.MustCompile(`(?i)(?:DefaultEndpointsProtocol=https?;)?AccountName=[^;"'\s]+;AccountKey=[A-Za-z0-9+/=]{20,}(?:;[^"'\s]*)?`),
+		regexp.MustCompile(`(?i)Endpoint=sb://[^;"'\s]+;SharedAccessKeyName=[^;"'\s]+;SharedAccessKey=[A-Za-z0-9+/=]{20,}(?:;[^"'\s]*)?`),
+		regexp.MustCompile(`(?i)Server=tcp:[^;"'\s]+;[^"']*?Password=[^;"'\s]+;?`),
+	}}
+}
+
+// GCPServiceAccount detects the key material of a service-account JSON key:
+// the private_key_id and the escaped PEM in private_key, in plain or
+// string-escaped JSON.
+func GCPServiceAccount() Detector {
+	return &regexDetector{name: "gcp_service_account", patterns: []*regexp.Regexp{
+		regexp.MustCompile(`\\?"private_key_id\\?"\s*:\s*\\?"([0-9a-f]{40})\\?"`),
+		regexp.MustCompile(`\\?"private_key\\?"\s*:\s*\\?"(-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----(?:\\\\?n)?)`),
+	}}
+}
+
+// PrivateKey detects PEM-encoded private key blocks (RSA, EC, OpenSSH, PKCS#8,
+// and PGP).
+func PrivateKey() Detector {
+	return &regexDetector{name: "private_key", patterns: []*regexp.Regexp{
+		regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----.*?-----END [A-Z ]*PRIVATE KEY(?: BLOCK)?-----`),
+	}}
+}
+
+// Defaults for HighEntropy.
+const (
+	// DefaultEntropyThreshold is the minimum Shannon entropy in bits per
+	// character. Random base64 scores 4.2 and up from 24 characters; hex
+	// digests and UUIDs stay below 4.
+	DefaultEntropyThreshold = 4.0
+	// DefaultEntropyMinLength is the minimum length of a candidate string.
+	DefaultEntropyMinLength = 24
+	// minClassChangeRatio is the minimum fraction of adjacent character
+	// pairs that switch between upper case, lower case, digit, and symbol.
+	// Random base64 switches about 65% of the time; identifiers such as
+	// "ProductionDatabaseSubnetGroup2" or resource paths, which can score as
+	// high on entropy alone, switch at word boundaries only.
+	minClassChangeRatio = 0.45
+)
+
+// quotedToken matches quoted strings drawn from the base64/URL-safe alphabet.
+var quotedToken = regexp.MustCompile(`["']([A-Za-z0-9+/=_\-]+)["']`)
+
+// entropyDetector flags quoted tokens that look randomly generated.
+type entropyDetector struct {
+	// threshold is the minimum entropy in bits per character.
+	threshold float64
+	// minLen is the minimum token length.
+	minLen int
+}
+
+// HighEntropy detects quoted strings of at least minLen characters from the
+// base64 alphabet whose Shannon entropy is at least threshold bits per
+// character and whose character classes alternate like random data.
+func HighEntropy(threshold float64, minLen int) Detector {
+	return &entropyDetector{threshold: threshold, minLen: minLen}
+}
+
+// Name implements Detector.
+func (d *entropyDetector) Name() string { return "high_entropy" }
+
+// Find implements Detector.
+func (d *entropyDetector) Find(content string) [][2]int {
+	var out [][2]int
+	for _, m := range quotedToken.FindAllStringSubmatchIndex(content, -1) {
+		tok := content[m[2]:m[3]]
+		if len(tok) < d.minLen || classChangeRatio(tok) < minClassChangeRatio {
+			continue
+		}
+		if shannon(tok) >= d.threshold {
+			out = append(out, [2]int{m[2], m[3]})
+		}
+	}
+	return out
+}
+
+// classChangeRatio returns the fraction of adjacent byte pairs in s that
+// belong to different classes (upper, lower, digit, other).
+func classChangeRatio(s string) float64 {
+	if len(s) < 2 {
+		return 0
+	}
+	class := func(c byte) int {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			return 0
+		case c >= 'a' && c <= 'z':
+			return 1
+		case c >= '0' && c <= '9':
+			return 2
+		}
+		return 3
+	}
+	changes := 0
+	for i := 1; i < len(s); i++ {
+		if class(s[i]) != class(s[i-1]) {
+			changes++
+		}
+	}
+	return float64(changes) / float64(len(s)-1)
+}
+
+// shannon returns the Shannon entropy of s in bits per character.
+func shannon(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,162 @@
+// Package security builds the access review report printed by
+// `tfai serve --print-security-report` and served on GET
+// /api/security-report. The report restates the security-relevant parts of
+// the resolved server configuration and flags risky combinations of them,
+// so a quarterly access review can be answered from one document.
+package security
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// Warning severities.
+const (
+	// SeverityHigh marks a configuration that exposes the server to
+	// untrusted clients.
+	SeverityHigh = "high"
+	// SeverityMedium marks a configuration that widens what an
+	// authenticated client can do.
+	SeverityMedium = "medium"
+)
+
+// Config is the resolved configuration the report is built from. It holds
+// no secret values: APIKeys counts the keys rather than carrying them.
+type Config struct {
+	// Host is the address the server binds to.
+	Host string
+	// Port is the TCP port the server listens on.
+	Port int
+	// APIKeys is the number of configured API keys; zero disables auth.
+	APIKeys int
+	// AdminKey is true when a separate key guards the admin routes.
+	AdminKey bool
+	// AllowedRoots lists the directories workspace operations are confined
+	// to. Empty means workspace paths are not confined.
+	AllowedRoots []string
+	// Tools lists the agent tools and whether each can be used.
+	Tools []api.ToolStatus
+	// WriteActions lists the enabled operations that change files,
+	// infrastructure, or state.
+	WriteActions []string
+	// ReadOnly is true when write actions are refused.
+	ReadOnly bool
+	// RateLimit is the sustained request rate allowed per IP.
+	RateLimit float64
+	// RateBurst is the maximum instantaneous burst per IP.
+	RateBurst int
+	// TLS is true when the server terminates TLS itself.
+	TLS bool
+	// CORSOrigins lists the origins allowed to make cross-origin requests;
+	// "*" allows any.
+	CORSOrigins []string
+	// BlockSecretsOnSave is true when saving credentials is refused.
+	BlockSecretsOnSave bool
+}
+
+// rule is one warning check.
+type rule struct {
+	// id is reported as SecurityWarning.Rule.
+	id string
+	// severity is reported as SecurityWarning.Severity.
+	severity string
+	// check returns the warning message when cfg trips the rule, or "".
+	check func(cfg Config) string
+}
+
+// rules is the ordered list of warning checks run by Report.
+var rules = []rule{
+	{id: "auth_disabled_non_localhost", severity: SeverityHigh, check: authDisabledNonLocalhost},
+	{id: "writes_without_read_only", severity: SeverityMedium, check: writesWithoutReadOnly},
+	{id: "wildcard_cors", severity: SeverityHigh, check: wildcardCORS},
+}
+
+// authDisabledNonLocalhost flags a server reachable from other hosts that
+// does not require an API key.
+func authDisabledNonLocalhost(cfg Config) string {
+	if cfg.APIKeys > 0 || IsLoopback(cfg.Host) {
+		return ""
+	}
+	return "authentication is disabled while the server listens on " + cfg.Host +
+		"; set TFAI_API_KEY or bind to 127.0.0.1"
+}
+
+// writesWithoutReadOnly flags write actions that no read-only guard can
+// switch off.
+func writesWithoutReadOnly(cfg Config) string {
+	if len(cfg.WriteActions) == 0 || cfg.ReadOnly {
+		return ""
+	}
+	return strconv.Itoa(len(cfg.WriteActions)) +
+		" write actions are enabled without a read-only guard; any client that can reach the API can change workspace files"
+}
+
+// wildcardCORS flags a CORS policy that lets any web page call the API from
+// a user's browser.
+func wildcardCORS(cfg Config) string {
+	for _, o := range cfg.CORSOrigins {
+		if o == "*" {
+			return "CORS allows any origin; restrict it to the origins that serve the UI"
+		}
+	}
+	return ""
+}
+
+// Warnings runs every rule against cfg and returns the warnings raised, in
+// rule order. It never returns nil.
+func Warnings(cfg Config) []api.SecurityWarning {
+	out := []api.SecurityWarning{}
+	for _, r := range rules {
+		if msg := r.check(cfg); msg != "" {
+			out = append(out, api.SecurityWarning{Rule: r.id, Severity: r.severity, Message: msg})
+		}
+	}
+	return out
+}
+
+// Report builds the security report for cfg. Slices in the report are never
+// nil, so they encode as [] rather than null.
+func Report(cfg Config) api.SecurityReport {
+	return api.SecurityReport{
+		Listen:    net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Localhost: IsLoopback(cfg.Host),
+		Auth: api.SecurityAuth{
+			Enabled:  cfg.APIKeys > 0,
+			Keys:     cfg.APIKeys,
+			AdminKey: cfg.AdminKey,
+		},
+		AllowedRoots: nonNil(cfg.AllowedRoots),
+		Tools:        nonNil(cfg.Tools),
+		WriteActions: nonNil(cfg.WriteActions),
+		ReadOnly:     cfg.ReadOnly,
+		RateLimit: api.SecurityRateLimit{
+			RequestsPerSecond: cfg.RateLimit,
+			Burst:             cfg.RateBurst,
+		},
+		TLS:                cfg.TLS,
+		CORSOrigins:        nonNil(cfg.CORSOrigins),
+		BlockSecretsOnSave: cfg.BlockSecretsOnSave,
+		Warnings:           Warnings(cfg),
+	}
+}
+
+// IsLoopback reports whether host only accepts connections from the local
+// machine: "localhost" or a loopback IP. An empty host binds every
+// interface and is not loopback.
+func IsLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// nonNil returns s, or an empty slice when s is nil.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package security
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// hardened is a configuration that trips no rule.
+func hardened() Config {
+	return Config{
+		Host:               "127.0.0.1",
+		Port:               8080,
+		APIKeys:            1,
+		AllowedRoots:       []string{"/srv/workspaces"},
+		Tools:              []api.ToolStatus{{Name: "terraform_plan", Available: true}},
+		WriteActions:       []string{"PUT /api/file"},
+		ReadOnly:           true,
+		RateLimit:          10,
+		RateBurst:          20,
+		CORSOrigins:        []string{"http://127.0.0.1:8080"},
+		BlockSecretsOnSave: true,
+	}
+}
+
+// ruleIDs returns the rule of each warning.
+func ruleIDs(ws []api.SecurityWarning) []string {
+	ids := make([]string, len(ws))
+	for i, w := range ws {
+		ids[i] = w.Rule
+	}
+	return ids
+}
+
+func TestReport_Hardened(t *testing.T) {
+	t.Parallel()
+
+	r := Report(hardened())
+	if len(r.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %+v", r.Warnings)
+	}
+	if r.Listen != "127.0.0.1:8080" || !r.Localhost || !r.Auth.Enabled || r.Auth.Keys != 1 {
+		t.Errorf("unexpected listener or auth %+v", r)
+	}
+	if r.RateLimit != (api.SecurityRateLimit{RequestsPerSecond: 10, Burst: 20}) || !r.ReadOnly || !r.BlockSecretsOnSave {
+		t.Errorf("unexpected report %+v", r)
+	}
+}
+
+func TestReport_Risky(t *testing.T) {
+	t.Parallel()
+
+	r := Report(Config{
+		Host:         "0.0.0.0",
+		Port:         9090,
+		WriteActions: []string{"PUT /api/file", "DELETE /api/file"},
+		CORSOrigins:  []string{"*"},
+	})
+	want := []string{"auth_disabled_non_localhost", "writes_without_read_only", "wildcard_cors"}
+	if got := ruleIDs(r.Warnings); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected warnings %v, got %v", want, got)
+	}
+	if r.Localhost || r.Auth.Enabled || len(r.AllowedRoots) != 0 {
+		t.Errorf("unexpected report %+v", r)
+	}
+
+	// Empty lists encode as [] so reviewers can tell "none" from "unknown".
+	body, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"allowedRoots":[]`, `"tools":[]`} {
+		if !strings.Contains(string(body), field) {
+			t.Errorf("expected %s in %s", field, body)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		rule   string
+		mutate func(*Config)
+	}{
+		{rule: "auth_disabled_non_localhost", mutate: func(c *Config) { c.Host, c.APIKeys = "0.0.0.0", 0 }},
+		{rule: "auth_disabled_non_localhost", mutate: func(c *Config) { c.Host, c.APIKeys = "", 0 }},
+		{rule: "writes_without_read_only", mutate: func(c *Config) { c.ReadOnly = false }},
+		{rule: "wildcard_cors", mutate: func(c *Config) { c.CORSOrigins = append(c.CORSOrigins, "*") }},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.rule, func(t *testing.T) {
+			t.Parallel()
+			cfg := hardened()
+			tc.mutate(&cfg)
+			ws := Warnings(cfg)
+			if len(ws) != 1 || ws[0].Rule != tc.rule || ws[0].Message == "" {
+				t.Errorf("expected only %s, got %+v", tc.rule, ws)
+			}
+		})
+	}
+}
+
+func TestRules_NotTripped(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]func(*Config){
+		"auth disabled on loopback":  func(c *Config) { c.APIKeys = 0; c.Host = "::1" },
+		"auth disabled on localhost": func(c *Config) { c.APIKeys = 0; c.Host = "localhost" },
+		"auth enabled on all":        func(c *Config) { c.Host = "0.0.0.0" },
+		"no write actions":           func(c *Config) { c.ReadOnly, c.WriteActions = false, nil },
+		"explicit origins":           func(c *Config) { c.CORSOrigins = []string{"https://tfai.example.com"} },
+	}
+	for name, mutate := range tests {
+		mutate := mutate
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := hardened()
+			mutate(&cfg)
+			if ws := Warnings(cfg); len(ws) != 0 {
+				t.Errorf("expected no warnings, got %+v", ws)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// Limits for the limit query parameter of GET /api/workspace/activity.
+const (
+	// defaultActivityLimit is used when limit is omitted.
+	defaultActivityLimit = 20
+	// maxActivityLimit caps limit; it matches store.DefaultActivityCap, so
+	// one page can hold everything kept.
+	maxActivityLimit = 200
+)
+
+// handleWorkspaceActivity handles
+// GET /api/workspace/activity?workspaceDir=<abs>&limit=N&before=ID. It
+// returns a page of the workspace's file-producing actions, newest first,
+// for the UI's activity feed. nextBefore in the response fetches the next
+// page. Like history, the workspace directory is not required to exist.
+func (s *Server) handleWorkspaceActivity(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.Activity == nil {
+		writeJSONError(w, "activity is unavailable: conversation history is disabled", http.StatusServiceUnavailable)
+		return
+	}
+	q := r.URL.Query()
+	dir, ok := s.storeDir(w, q.Get("workspaceDir"))
+	if !ok {
+		return
+	}
+	limit := defaultActivityLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeJSONError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxActivityLimit)
+	}
+	var before int64
+	if v := q.Get("before"); v != "" {
+		var err error
+		before, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || before <= 0 {
+			writeJSONError(w, "before must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// One extra entry tells whether there is a next page.
+	entries, err := s.cfg.Activity.Activity(r.Context(), dir, before, limit+1)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("activity query error", slog.Any("error", err))
+		writeJSONError(w, "failed to load activity", http.StatusInternalServerError)
+		return
+	}
+
+	resp := api.WorkspaceActivityResponse{Entries: make([]api.WorkspaceActivity, 0, min(len(entries), limit))}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		resp.NextBefore = entries[limit-1].ID
+	}
+	for _, e := range entries {
+		resp.Entries = append(resp.Entries, api.WorkspaceActivity{
+			ID:        e.ID,
+			Summary:   e.Summary,
+			Files:     e.Files,
+			Conflicts: e.Conflicts,
+			RequestID: e.RequestID,
+			Model:     e.Model,
+			CreatedAt: api.NewTimestamp(e.CreatedAt),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("activity encode error", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// newActivityTestServer returns a Server reading activity from a fresh
+// in-memory SQLiteStore holding n entries for /ws/a, summarised "a1" (the
+// oldest) to "aN", and one for /ws/b.
+func newActivityTestServer(t *testing.T, n int) *Server {
+	t.Helper()
+	hs, err := store.Open(t.Context(), ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory store: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+	for i := 1; i <= n; i++ {
+		a := store.Activity{Workspace: "/ws/a", Summary: fmt.Sprintf("a%d", i), Files: []string{"main.tf"}, RequestID: "req-" + strconv.Itoa(i), Model: "gpt-4o"}
+		if err := hs.RecordActivity(t.Context(), a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hs.RecordActivity(t.Context(), store.Activity{Workspace: "/ws/b", Summary: "other workspace"}); err != nil {
+		t.Fatal(err)
+	}
+	return &Server{cfg: &Config{Activity: hs, WorkspaceRoot: "/ws"}, log: slog.Default()}
+}
+
+// getActivity calls handleWorkspaceActivity with the given query parameters.
+func getActivity(s *Server, params url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/workspace/activity?"+params.Encode(), nil)
+	w := httptest.NewRecorder()
+	s.handleWorkspaceActivity(w, req)
+	return w
+}
+
+// decodeActivity decodes a 200 activity response.
+func decodeActivity(t *testing.T, w *httptest.ResponseRecorder) api.WorkspaceActivityResponse {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	var resp api.WorkspaceActivityResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+// activitySummaries returns the summaries of entries, in order.
+func activitySummaries(entries []api.WorkspaceActivity) string {
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.Summary)
+	}
+	return strings.Join(out, ",")
+}
+
+// ---------------------------------------------------------------------------
+// GET /api/workspace/activity
+// ---------------------------------------------------------------------------
+
+func TestHandleWorkspaceActivity_Pagination(t *testing.T) {
+	t.Parallel()
+
+	s := newActivityTestServer(t, 5)
+
+	page1 := decodeActivity(t, getActivity(s, url.Values{"workspaceDir": {"/ws/a/"}, "limit": {"2"}}))
+	if got := activitySummaries(page1.Entries); got != "a5,a4" || page1.NextBefore == 0 {
+		t.Fatalf("expected a5,a4 with a next page, got %s (nextBefore %d)", got, page1.NextBefore)
+	}
+	e := page1.Entries[0]
+	if e.ID == 0 || e.RequestID != "req-5" || e.Model != "gpt-4o" || strings.Join(e.Files, ",") != "main.tf" || e.CreatedAt.IsZero() {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+
+	page2 := decodeActivity(t, getActivity(s, url.Values{
+		"workspaceDir": {"/ws/a"}, "limit": {"2"}, "before": {strconv.FormatInt(page1.NextBefore, 10)},
+	}))
+	if got := activitySummaries(page2.Entries); got != "a3,a2" || page2.NextBefore == 0 {
+		t.Fatalf("expected a3,a2 with a next page, got %s (nextBefore %d)", got, page2.NextBefore)
+	}
+
+	page3 := decodeActivity(t, getActivity(s, url.Values{
+		"workspaceDir": {"/ws/a"}, "limit": {"2"}, "before": {strconv.FormatInt(page2.NextBefore, 10)},
+	}))
+	if got := activitySummaries(page3.Entries); got != "a1" || page3.NextBefore != 0 {
+		t.Errorf("expected a1 on the last page, got %s (nextBefore %d)", got, page3.NextBefore)
+	}
+
+	all := decodeActivity(t, getActivity(s, url.Values{"workspaceDir": {"/ws/a"}}))
+	if len(all.Entries) != 5 || all.NextBefore != 0 {
+		t.Errorf("expected every entry within the default limit, got %+v", all)
+	}
+}
+
+func TestHandleWorkspaceActivity_Empty(t *testing.T) {
+	t.Parallel()
+
+	w := getActivity(newActivityTestServer(t, 0), url.Values{"workspaceDir": {"/ws/empty"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Body.String(); got != "{\"entries\":[]}\n" {
+		t.Errorf("expected an empty entries array, got %q", got)
+	}
+}
+
+func TestHandleWorkspaceActivity_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params url.Values
+		want   int
+	}{
+		{name: "missing workspaceDir", params: url.Values{}, want: http.StatusBadRequest},
+		{name: "relative workspaceDir", params: url.Values{"workspaceDir": {"ws/a"}}, want: http.StatusBadRequest},
+		{name: "outside workspace root", params: url.Values{"workspaceDir": {"/etc"}}, want: http.StatusForbidden},
+		{name: "non-numeric limit", params: url.Values{"workspaceDir": {"/ws/a"}, "limit": {"ten"}}, want: http.StatusBadRequest},
+		{name: "zero limit", params: url.Values{"workspaceDir": {"/ws/a"}, "limit": {"0"}}, want: http.StatusBadRequest},
+		{name: "negative before", params: url.Values{"workspaceDir": {"/ws/a"}, "before": {"-1"}}, want: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if w := getActivity(newActivityTestServer(t, 1), tc.params); w.Code != tc.want {
+				t.Errorf("expected %d, got %d — body: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleWorkspaceActivity_Disabled(t *testing.T) {
+	t.Parallel()
+
+	w := getActivity(newTestServer(), url.Values{"workspaceDir": {"/ws/a"}})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+)
+
+// basePathPlaceholder is replaced with Config.BasePath in index.html when it
+// is served, so the UI prefixes its API calls with the external path.
+const basePathPlaceholder = "__TFAI_BASE_PATH__"
+
+// basePathSegment matches one segment of a base path. It excludes the
+// characters ServeMux patterns and URLs give a meaning, such as braces.
+var basePathSegment = regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+
+// normalizeBasePath validates p and returns it with a leading slash and no
+// trailing slash, e.g. "tfai/" becomes "/tfai". "" and "/" mean the root and
+// return "".
+func normalizeBasePath(p string) (string, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	for _, seg := range strings.Split(p, "/") {
+		if seg == "." || seg == ".." || !basePathSegment.MatchString(seg) {
+			return "", fmt.Errorf("server: invalid base path %q: segment %q must be non-empty letters, digits, or ._~-", "/"+p, seg)
+		}
+	}
+	return "/" + p, nil
+}
+
+// uiHandler serves the static UI in dir, with index.html rewritten for the
+// base path. Paths are relative to the base path: mount it behind
+// http.StripPrefix.
+func (s *Server) uiHandler(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" || r.URL.Path == "/index.html" {
+			s.serveIndex(w, r, filepath.Join(dir, "index.html"))
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// serveIndex serves the index.html at path with the base path substituted.
+// It is read on every request so edits to the UI show up without a restart,
+// like the files next to it.
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request, path string) {
+	page, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ui: failed to read index.html", slog.Any("error", err))
+		http.Error(w, "failed to load the UI", http.StatusInternalServerError)
+		return
+	}
+	page = []byte(strings.ReplaceAll(string(page), basePathPlaceholder, s.cfg.BasePath))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(page)
+}
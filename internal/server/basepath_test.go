@@ -0,0 +1,239 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// ---------------------------------------------------------------------------
+// normalizeBasePath
+// ---------------------------------------------------------------------------
+
+func TestNormalizeBasePath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: ""},
+		{in: "/", want: ""},
+		{in: "/tfai", want: "/tfai"},
+		{in: "tfai/", want: "/tfai"},
+		{in: "/tools/tf-ai_v2/", want: "/tools/tf-ai_v2"},
+		{in: "/a//b", wantErr: true},
+		{in: "/a/../b", wantErr: true},
+		{in: "/{id}", wantErr: true},
+		{in: "/tfai?x=1", wantErr: true},
+		{in: "/t fai", wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+			got, err := normalizeBasePath(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Routing under a base path
+// ---------------------------------------------------------------------------
+
+// newBasePathTestServer starts the full handler chain with base as the base
+// path and a UI directory holding an index.html that uses the placeholder.
+func newBasePathTestServer(t *testing.T, base string, disableRootProbes bool) (*httptest.Server, *Server) {
+	t.Helper()
+	uiDir := t.TempDir()
+	index := "<script>const basePath = '" + basePathPlaceholder + "';</script>"
+	if err := os.WriteFile(filepath.Join(uiDir, "index.html"), []byte(index), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(uiDir, "app.css"), []byte("body{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newChatTestServer(&fakeQuerier{response: "ok"})
+	s.cfg.APIKey = testAPIKey
+	s.cfg.BasePath = base
+	s.cfg.DisableRootProbes = disableRootProbes
+	s.cfg.UIDir = uiDir
+	handler, err := s.routes(newRateLimiter(1000, 1000, slog.Default()))
+	if err != nil {
+		t.Fatalf("routes: %v", err)
+	}
+	ts := httptest.NewServer(requestLogger(s.log, handler))
+	t.Cleanup(ts.Close)
+	return ts, s
+}
+
+// get sends an authenticated GET for path without following redirects and
+// returns the response with its body read.
+func get(t *testing.T, ts *httptest.Server, path string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAPIKey)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("GET %s: read body: %v", path, err)
+	}
+	return resp, string(body)
+}
+
+func TestRoutes_BasePath(t *testing.T) {
+	t.Parallel()
+
+	ts, s := newBasePathTestServer(t, "/tfai", false)
+
+	for _, rt := range s.apiRoutes() {
+		rt := rt
+		method, path, _ := strings.Cut(rt.pattern, " ")
+		t.Run(rt.pattern, func(t *testing.T) {
+			t.Parallel()
+			req, err := http.NewRequest(method, ts.URL+"/tfai"+path, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("Authorization", "Bearer "+testAPIKey)
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			if isRoutingFailure(resp.StatusCode) {
+				t.Errorf("prefixed route not mounted, got %d", resp.StatusCode)
+			}
+		})
+	}
+
+	t.Run("unprefixed API path", func(t *testing.T) {
+		t.Parallel()
+		if resp, _ := get(t, ts, "/api/status"); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("metrics", func(t *testing.T) {
+		t.Parallel()
+		if resp, _ := get(t, ts, "/tfai/metrics"); resp.StatusCode != http.StatusOK {
+			t.Errorf("expected 200, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("version reports base path", func(t *testing.T) {
+		t.Parallel()
+		_, body := get(t, ts, "/tfai/api/version")
+		var v api.VersionResponse
+		if err := json.Unmarshal([]byte(body), &v); err != nil || v.BasePath != "/tfai" {
+			t.Errorf("expected basePath /tfai, got %+v (%v)", v, err)
+		}
+	})
+	t.Run("bare prefix redirects", func(t *testing.T) {
+		t.Parallel()
+		resp, _ := get(t, ts, "/tfai")
+		if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "/tfai/" {
+			t.Errorf("expected 301 to /tfai/, got %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+		}
+	})
+	t.Run("index rewritten", func(t *testing.T) {
+		t.Parallel()
+		for _, path := range []string{"/tfai/", "/tfai/index.html"} {
+			resp, body := get(t, ts, path)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("GET %s: expected 200, got %d", path, resp.StatusCode)
+			}
+			if want := "const basePath = '/tfai';"; !strings.Contains(body, want) {
+				t.Errorf("GET %s: expected %q in %q", path, want, body)
+			}
+		}
+	})
+	t.Run("static file", func(t *testing.T) {
+		t.Parallel()
+		if resp, body := get(t, ts, "/tfai/app.css"); resp.StatusCode != http.StatusOK || body != "body{}" {
+			t.Errorf("expected app.css, got %d %q", resp.StatusCode, body)
+		}
+	})
+}
+
+func TestRoutes_BasePathProbes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		disable bool
+		// wantRoot is the status of the unprefixed probes.
+		wantRoot int
+	}{
+		{name: "mounted at root too", wantRoot: http.StatusOK},
+		{name: "root probes disabled", disable: true, wantRoot: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ts, _ := newBasePathTestServer(t, "/tfai", tc.disable)
+			for _, path := range []string{"/api/health", "/api/ready"} {
+				if resp, _ := get(t, ts, "/tfai"+path); resp.StatusCode != http.StatusOK {
+					t.Errorf("GET /tfai%s: expected 200, got %d", path, resp.StatusCode)
+				}
+				if resp, _ := get(t, ts, path); resp.StatusCode != tc.wantRoot {
+					t.Errorf("GET %s: expected %d, got %d", path, tc.wantRoot, resp.StatusCode)
+				}
+			}
+		})
+	}
+}
+
+func TestRoutes_RootIndexRewritten(t *testing.T) {
+	t.Parallel()
+
+	ts, _ := newBasePathTestServer(t, "", false)
+	resp, body := get(t, ts, "/")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "const basePath = '';") {
+		t.Errorf("expected an empty base path, got %d %q", resp.StatusCode, body)
+	}
+}
+
+// TestIndexHTML_UsesBasePath guards the shipped UI: it must declare the
+// placeholder and send every API request through the base path.
+func TestIndexHTML_UsesBasePath(t *testing.T) {
+	t.Parallel()
+
+	page, err := os.ReadFile(filepath.Join("..", "..", defaultUIDir, "index.html"))
+	if err != nil {
+		t.Fatalf("read index.html: %v", err)
+	}
+	if !strings.Contains(string(page), "const basePath = '"+basePathPlaceholder+"';") {
+		t.Error("index.html does not declare basePath from the placeholder")
+	}
+	if m := regexp.MustCompile(`\bfetch\(\s*['"]/`).FindString(string(page)); m != "" {
+		t.Errorf("index.html fetches an absolute path without the base path: %s", m)
+	}
+}
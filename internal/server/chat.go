@@ -0,0 +1,465 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/tracing"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// Outcome label values for the chat request metrics. Both the SSE and JSON
+// response modes record through chatOutcome so the labels cannot diverge.
+const (
+	// outcomeOK is a query that completed without error.
+	outcomeOK = "ok"
+	// outcomeTimeout is a query cut off by ChatTimeout.
+	outcomeTimeout = "timeout"
+	// outcomeCanceled is a query abandoned because the client disconnected.
+	outcomeCanceled = "canceled"
+	// outcomeError is any other query failure, typically the model provider.
+	outcomeError = "error"
+)
+
+// statusClientClosedRequest is the nginx convention for a request the
+// client abandoned; it is only logged, since nobody is left to receive it.
+const statusClientClosedRequest = 499
+
+// chatOutcome classifies the result of a query for metrics and, in JSON
+// mode, the HTTP status: 200 on success, 499 when the client disconnected,
+// 504 when the chat timeout passed, and 502 for every other failure.
+func chatOutcome(ctx context.Context, err error) (outcome string, status int) {
+	switch {
+	case err == nil:
+		return outcomeOK, http.StatusOK
+	case errors.Is(ctx.Err(), context.Canceled):
+		return outcomeCanceled, statusClientClosedRequest
+	case ctx.Err() != nil:
+		return outcomeTimeout, http.StatusGatewayTimeout
+	default:
+		return outcomeError, http.StatusBadGateway
+	}
+}
+
+// recordChat records the completion of a chat request started at start.
+func (s *Server) recordChat(outcome string, start time.Time) {
+	s.metrics.chatRequestsTotal.WithLabelValues(outcome).Inc()
+	s.metrics.chatDurationSeconds.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+// acceptsJSON reports whether the client prefers a single JSON document over
+// an SSE stream, i.e. application/json appears in the Accept header before
+// text/event-stream. A missing or wildcard Accept keeps the SSE default.
+func acceptsJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "application/json":
+			return true
+		case "text/event-stream":
+			return false
+		}
+	}
+	return false
+}
+
+// writeChatError writes a request validation error in the response mode the
+// client asked for: a JSON api.ErrorResponse, or plain text for SSE clients.
+func writeChatError(w http.ResponseWriter, jsonMode bool, msg string, status int) {
+	if jsonMode {
+		writeJSONError(w, msg, status)
+		return
+	}
+	http.Error(w, msg, status)
+}
+
+// handleChatJSON runs a validated chat request to completion, buffering the
+// answer, and writes it as one api.ChatResponse. Query failures are reported
+// with a status code instead of an in-band SSE error event.
+func (s *Server) handleChatJSON(w http.ResponseWriter, r *http.Request, req api.ChatRequest) {
+	ctx, cancelChat, log, _ := s.chatContext(r, req, w.Header().Get(api.HeaderRequestID))
+	defer cancelChat()
+
+	s.setChatCORS(w, r)
+	start := time.Now()
+
+	var answer strings.Builder
+	res, err := s.querier.Run(ctx, agent.QueryRequest{
+		Message:      req.Message,
+		WorkspaceDir: req.WorkspaceDir,
+		SessionID:    req.SessionID,
+		RequestID:    w.Header().Get(api.HeaderRequestID),
+		Output:       &answer,
+		Options:      queryOptions(req),
+	})
+	outcome, status := chatOutcome(ctx, err)
+	s.recordChat(outcome, start)
+	if outcome == outcomeCanceled {
+		log.Info("chat canceled: client disconnected", slog.Duration("duration", time.Since(start)))
+		return
+	}
+	if outcome == outcomeTimeout {
+		err = &agent.TimeoutError{After: s.cfg.ChatTimeout, Err: err}
+	}
+	if err != nil {
+		log.Error("chat agent error", slog.Any("error", err), slog.String("outcome", outcome))
+		// Nothing to continue is the client's mistake, not the model's.
+		if res.ErrorCode == agent.CodeCannotContinue {
+			status = http.StatusConflict
+		}
+		writeWorkspaceError(w, &workspaceError{status, string(res.ErrorCode), err.Error()})
+		return
+	}
+
+	duration := time.Since(start)
+	log.Info("chat complete", chatCompleteAttrs(res, duration)...)
+
+	resp := api.ChatResponse{
+		Answer:       answer.String(),
+		FilesWritten: res.FilesWritten(),
+		Files:        res.Files,
+		Sources:      res.Sources,
+		RequestID:    w.Header().Get(api.HeaderRequestID),
+		TraceID:      s.traceID(w.Header().Get(api.HeaderRequestID)),
+		DurationMs:   duration.Milliseconds(),
+	}
+	// Encode empty lists as [] so clients need no null checks.
+	if resp.Files == nil {
+		resp.Files = []string{}
+	}
+	if resp.Sources == nil {
+		resp.Sources = []string{}
+	}
+	if res.Usage != nil {
+		resp.Usage = &api.ChatUsage{PromptTokens: res.Usage.PromptTokens, CompletionTokens: res.Usage.CompletionTokens}
+	}
+	resp.Notices = res.Notices
+	resp.Disclosure = s.cfg.DisclosureText
+	resp.Truncated = res.Truncated
+	resp.Timings = chatTimings(res.Timings)
+	resp.InputsNeeded = inputsNeeded(res.InputsNeeded)
+	if resp.Preview, err = s.filesPreview(req.WorkspaceDir, res); err != nil {
+		log.Error("chat preview error", slog.Any("error", err))
+		writeJSONError(w, "failed to store file preview", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("chat encode error", slog.Any("error", err))
+	}
+}
+
+// chatCompleteAttrs returns the attributes of the "chat complete" log line
+// of res, which took duration: the token counts are included when the
+// provider reported them.
+func chatCompleteAttrs(res *agent.QueryResult, duration time.Duration) []any {
+	attrs := []any{
+		slog.Duration("duration", duration),
+		slog.Bool("files_written", res.FilesWritten()),
+	}
+	if res.Usage != nil {
+		attrs = append(attrs,
+			slog.Int("prompt_tokens", res.Usage.PromptTokens),
+			slog.Int("completion_tokens", res.Usage.CompletionTokens),
+		)
+	}
+	return attrs
+}
+
+// chatTimings converts the agent's timings to their wire form; nil when t
+// is nil.
+func chatTimings(t *agent.Timings) *api.ChatTimings {
+	if t == nil {
+		return nil
+	}
+	resp := &api.ChatTimings{
+		ContextMs:    t.Context.Milliseconds(),
+		FirstTokenMs: t.FirstToken.Milliseconds(),
+		ModelMs:      t.Model.Milliseconds(),
+		ToolsMs:      t.Tools.Milliseconds(),
+		ToolCalls:    len(t.ToolCalls),
+		ParseMs:      t.Parse.Milliseconds(),
+		ApplyMs:      t.Apply.Milliseconds(),
+		TotalMs:      t.Total.Milliseconds(),
+	}
+	for _, c := range t.ToolCalls {
+		resp.Tools = append(resp.Tools, api.ToolTiming{Tool: c.Name, ElapsedMs: c.Elapsed.Milliseconds()})
+	}
+	return resp
+}
+
+// inputsNeeded converts the import IDs the operator has to fill in to their
+// wire form; nil when there are none.
+func inputsNeeded(inputs []agent.ImportInput) *api.InputsNeededEvent {
+	if len(inputs) == 0 {
+		return nil
+	}
+	ev := &api.InputsNeededEvent{Imports: make([]api.ImportInput, 0, len(inputs))}
+	for _, in := range inputs {
+		ev.Imports = append(ev.Imports, api.ImportInput{To: in.To, ID: in.ID, Path: in.Path, Line: in.Line})
+	}
+	return ev
+}
+
+// DefaultMaxTokensLimit is the default Config.MaxTokensLimit.
+const DefaultMaxTokensLimit = 16384
+
+// maxTemperature is the highest temperature a chat request may ask for, the
+// upper bound every supported provider accepts.
+const maxTemperature = 2
+
+// checkTuning validates the per-request model overrides of req and returns
+// the error message for a 400 response, or "" when they are valid.
+func (s *Server) checkTuning(req api.ChatRequest) string {
+	if t := req.Temperature; t != nil && (*t < 0 || *t > maxTemperature) {
+		return fmt.Sprintf("temperature must be between 0 and %d", maxTemperature)
+	}
+	if req.MaxTokens < 0 || req.MaxTokens > s.cfg.MaxTokensLimit {
+		return fmt.Sprintf("maxTokens must be between 1 and %d", s.cfg.MaxTokensLimit)
+	}
+	return ""
+}
+
+// queryOptions returns the agent options carrying the per-request model
+// overrides and the continue, preview, and import flags of req.
+func queryOptions(req api.ChatRequest) agent.QueryOptions {
+	return agent.QueryOptions{
+		Temperature:  req.Temperature,
+		MaxTokens:    req.MaxTokens,
+		Continue:     req.Continue,
+		PreviewFiles: req.PreviewFiles,
+		ImportMode:   req.ImportMode,
+	}
+}
+
+// requestCounter is a monotonically increasing counter used to generate
+// unique per-request session IDs for Langfuse traces.
+var requestCounter atomic.Uint64
+
+// maxChatBodyBytes is the maximum allowed size for a /api/chat request body.
+// Prevents unbounded memory allocation from oversized requests.
+const maxChatBodyBytes = 1 << 20 // 1 MiB
+
+// handleChat handles POST /api/chat requests. It streams the agent's response
+// using Server-Sent Events (SSE) so the UI can render tokens as they arrive.
+// Clients that send Accept: application/json or "stream": false instead get a
+// single api.ChatResponse once the query completes (see handleChatJSON).
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	jsonMode := acceptsJSON(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxChatBodyBytes)
+	var req api.ChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeChatError(w, jsonMode, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Stream != nil && !*req.Stream {
+		jsonMode = true
+	}
+	if req.Message == "" && !req.Continue {
+		writeChatError(w, jsonMode, "message is required", http.StatusBadRequest)
+		return
+	}
+	if msg := s.checkTuning(req); msg != "" {
+		writeChatError(w, jsonMode, msg, http.StatusBadRequest)
+		return
+	}
+
+	// workspaceDir is optional for chat, but when present it goes through the
+	// same validation as the workspace and file APIs. This must happen before
+	// SSE headers are written so the client receives a proper status code.
+	if req.WorkspaceDir != "" {
+		dir, wsErr := s.resolveWorkspace(req.WorkspaceDir)
+		if wsErr != nil {
+			writeWorkspaceError(w, wsErr)
+			return
+		}
+		req.WorkspaceDir = dir
+	}
+	// A session is a thread of one workspace's history; it is ignored when
+	// history is disabled, as the default thread is.
+	if req.SessionID != "" && s.cfg.History != nil {
+		if req.WorkspaceDir == "" {
+			writeChatError(w, jsonMode, "sessionId requires workspaceDir", http.StatusBadRequest)
+			return
+		}
+		if wsErr := s.checkSession(r.Context(), req.WorkspaceDir, req.SessionID); wsErr != nil {
+			writeWorkspaceError(w, wsErr)
+			return
+		}
+	}
+
+	if jsonMode {
+		s.handleChatJSON(w, r, req)
+		return
+	}
+	s.streamQuery(w, r, req, nil)
+}
+
+// streamQuery runs req through the querier and streams the response as SSE,
+// from the accepted event to the final error or done event. finish, when
+// non-nil, is called with the query result just before that final event so
+// callers can add events of their own; the result's Files are only
+// meaningful when the query succeeded.
+func (s *Server) streamQuery(w http.ResponseWriter, r *http.Request, req api.ChatRequest, finish func(sw *sseWriter, res *agent.QueryResult)) {
+	// Set SSE headers so the client receives a streaming response.
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	s.setChatCORS(w, r)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	requestID := w.Header().Get(api.HeaderRequestID)
+	ctx, cancelChat, log, chatID := s.chatContext(r, req, requestID)
+	defer cancelChat()
+
+	// Track active streams and record duration + outcome for every request.
+	s.metrics.chatActiveStreams.Inc()
+	start := time.Now()
+	defer s.metrics.chatActiveStreams.Dec()
+
+	sw := s.newSSEWriter(w, flusher)
+	stopHeartbeat := sw.startHeartbeat(s.cfg.SSEHeartbeat)
+	defer stopHeartbeat()
+
+	// Send the first byte before any context is built: EventSource does not
+	// fire onopen until it arrives, and RAG, workspace, and history loading
+	// can take seconds. Phase events then report progress until the first
+	// token.
+	_ = sw.WriteEvent(sseEvent{Type: api.EventAccepted, Data: api.AcceptedEvent{
+		Protocol:  api.ProtocolVersion,
+		RequestID: requestID,
+		ChatID:    chatID,
+	}})
+	if traceID := s.traceID(requestID); traceID != "" {
+		_ = sw.WriteEvent(sseEvent{Type: api.EventMeta, Data: api.MetaEvent{RequestID: requestID, TraceID: traceID}})
+	}
+
+	res, err := s.querier.Run(ctx, agent.QueryRequest{
+		Message:      req.Message,
+		WorkspaceDir: req.WorkspaceDir,
+		SessionID:    req.SessionID,
+		RequestID:    requestID,
+		Output:       sw,
+		Events:       streamEvents{sw: sw},
+		Options:      queryOptions(req),
+	})
+	outcome, _ := chatOutcome(ctx, err)
+	s.recordChat(outcome, start)
+	// Nobody is left to read an error event; the agent has already stopped
+	// the model.
+	if outcome == outcomeCanceled {
+		log.Info("chat canceled: client disconnected", slog.Duration("duration", time.Since(start)))
+		return
+	}
+	if res == nil {
+		res = &agent.QueryResult{}
+	}
+	if outcome == outcomeTimeout {
+		err = &agent.TimeoutError{After: s.cfg.ChatTimeout, Err: err}
+	}
+	if err != nil {
+		log.Error("chat agent error", slog.Any("error", err), slog.String("outcome", outcome))
+		if finish != nil {
+			finish(sw, res)
+		}
+		_ = sw.WriteEvent(sseEvent{Type: api.EventError, Data: err.Error()})
+		return
+	}
+
+	log.Info("chat complete", chatCompleteAttrs(res, time.Since(start))...)
+
+	if res.FilesWritten() {
+		_ = sw.WriteEvent(sseEvent{Type: api.EventFilesWritten, Data: true})
+	}
+	preview, err := s.filesPreview(req.WorkspaceDir, res)
+	if err != nil {
+		log.Error("chat preview error", slog.Any("error", err))
+		_ = sw.WriteEvent(sseEvent{Type: api.EventError, Data: "failed to store file preview"})
+		return
+	}
+	if preview != nil {
+		_ = sw.WriteEvent(sseEvent{Type: api.EventFilesPreview, Data: preview})
+	}
+	if inputs := inputsNeeded(res.InputsNeeded); inputs != nil {
+		_ = sw.WriteEvent(sseEvent{Type: api.EventInputsNeeded, Data: inputs})
+	}
+	if res.Truncated {
+		_ = sw.WriteEvent(sseEvent{Type: api.EventTruncated, Data: true})
+	}
+	if t := chatTimings(res.Timings); t != nil {
+		_ = sw.WriteEvent(sseEvent{Type: api.EventTimings, Data: t})
+	}
+	if finish != nil {
+		finish(sw, res)
+	}
+	// Signal stream completion.
+	_ = sw.WriteDone()
+}
+
+// setChatCORS restricts CORS to the configured localhost origin only — this
+// server is local-only.
+func (s *Server) setChatCORS(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(corsOrigins(s.cfg.Port), origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+}
+
+// corsOrigins returns the origins setChatCORS allows: the UI served by this
+// server on port, under either loopback name.
+func corsOrigins(port int) []string {
+	return []string{
+		fmt.Sprintf("http://127.0.0.1:%d", port),
+		fmt.Sprintf("http://localhost:%d", port),
+	}
+}
+
+// chatContext derives the query context for a chat request: a hard deadline
+// of cfg.ChatTimeout so a hung backend never blocks the goroutine
+// indefinitely, and a unique session ID so each request appears as a
+// distinct named trace in Langfuse, with requestID as the trace ID. The
+// context carries the chat's logger, so the agent's log lines for the
+// request have the same request and session IDs as the server's. It also
+// returns that logger and the session ID, which clients see as the chat ID.
+func (s *Server) chatContext(r *http.Request, req api.ChatRequest, requestID string) (context.Context, context.CancelFunc, *slog.Logger, string) {
+	sessionID := fmt.Sprintf("tfai-%d-%d", time.Now().UnixMilli(), requestCounter.Add(1))
+	chatCtx, cancel := context.WithTimeout(r.Context(), s.cfg.ChatTimeout)
+	ctx := tracing.SetRequestTrace(chatCtx, sessionID, requestID)
+
+	log := logging.FromContext(r.Context()).With(
+		slog.String("session_id", sessionID),
+		slog.String("workspace", req.WorkspaceDir),
+	)
+	ctx = logging.WithLogger(ctx, log)
+	log.Info("chat start", slog.String("message", req.Message))
+	return ctx, cancel, log, sessionID
+}
+
+// traceID returns the Langfuse trace ID of the chat with requestID, or ""
+// when chats are not traced.
+func (s *Server) traceID(requestID string) string {
+	if !s.cfg.Tracing {
+		return ""
+	}
+	return requestID
+}
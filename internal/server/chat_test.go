@@ -1,17 +1,25 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/pkg/api"
 )
 
 // ---------------------------------------------------------------------------
@@ -19,22 +27,39 @@ import (
 // ---------------------------------------------------------------------------
 
 // fakeQuerier implements the querier interface for tests.
-// It writes a fixed response to the writer and returns configurable values.
+// It writes a fixed response to the request's output and returns
+// configurable values.
 type fakeQuerier struct {
-	// response is written verbatim to the writer on each Query call.
+	// response is written verbatim to the output on each Run call.
 	response string
-	// filesWritten is the value returned as the first return value.
-	filesWritten bool
-	// err is returned as the error value.
-	err error
+	// files is reported as the files written.
+	files []string
+	// truncated reports the answer as cut off at the output token limit.
+	truncated bool
+	// timings is reported as the query's timings.
+	timings *agent.Timings
+	// preview and envelope are reported as an unwritten file envelope.
+	preview  []agent.FilePreview
+	envelope *agent.TerraformAgentOutput
+	// inputs is reported as the import IDs the operator has to fill in.
+	inputs []agent.ImportInput
+	// err is returned as the error value, classified as code.
+	err  error
+	code agent.ErrorCode
+	// options records the options of the last Run call.
+	options agent.QueryOptions
+	// requestID records the request ID of the last Run call.
+	requestID string
 }
 
-func (f *fakeQuerier) Query(_ context.Context, _, _ string, w io.Writer) (bool, error) {
+func (f *fakeQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	f.options = req.Options
+	f.requestID = req.RequestID
 	if f.err != nil {
-		return false, f.err
+		return &agent.QueryResult{ErrorCode: f.code}, f.err
 	}
-	_, _ = fmt.Fprint(w, f.response)
-	return f.filesWritten, nil
+	_, _ = fmt.Fprint(req.Output, f.response)
+	return &agent.QueryResult{Files: f.files, Truncated: f.truncated, Timings: f.timings, Preview: f.preview, Envelope: f.envelope, InputsNeeded: f.inputs}, nil
 }
 
 // newChatTestServer builds a *Server wired with the given querier fake.
@@ -45,14 +70,16 @@ func newChatTestServer(q querier) *Server {
 	cfg := &Config{
 		Port:            8080,
 		ChatTimeout:     5 * time.Minute,
+		MaxTokensLimit:  DefaultMaxTokensLimit,
 		MetricsRegistry: reg,
 		MetricsGatherer: reg,
 	}
 	return &Server{
-		querier: q,
-		cfg:     cfg,
-		log:     slog.Default(),
-		metrics: newServerMetrics(reg),
+		querier:  q,
+		previews: newPreviewCache(),
+		cfg:      cfg,
+		log:      slog.Default(),
+		metrics:  newServerMetrics(reg),
 	}
 }
 
@@ -108,6 +135,30 @@ func TestHandleChat_RelativeWorkspaceDir(t *testing.T) {
 	}
 }
 
+func TestHandleChat_NonexistentWorkspaceDir(t *testing.T) {
+	t.Parallel()
+
+	q := &fakeQuerier{response: "should not run"}
+	s := newChatTestServer(q)
+	dir := filepath.Join(t.TempDir(), "typo")
+	req := httptest.NewRequest(http.MethodPost, "/api/chat",
+		strings.NewReader(`{"message":"hi","workspaceDir":"`+dir+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	s.handleChat(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d — body: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), errCodeWorkspaceNotFound) {
+		t.Errorf("expected %q code in body, got: %s", errCodeWorkspaceNotFound, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); strings.HasPrefix(ct, "text/event-stream") {
+		t.Error("validation failure must not start an SSE stream")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // POST /api/chat — happy path (fake querier, SSE response)
 // ---------------------------------------------------------------------------
@@ -143,7 +194,7 @@ func TestHandleChat_Success(t *testing.T) {
 func TestHandleChat_FilesWritten(t *testing.T) {
 	t.Parallel()
 
-	q := &fakeQuerier{response: "ok", filesWritten: true}
+	q := &fakeQuerier{response: "ok", files: []string{"main.tf"}}
 	s := newChatTestServer(q)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/chat",
@@ -183,3 +234,867 @@ func TestHandleChat_AgentError(t *testing.T) {
 		t.Errorf("expected error message in body, got: %s", body)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// POST /api/chat — non-streaming JSON mode
+// ---------------------------------------------------------------------------
+
+// reportingQuerier answers like the agent and fills the result the way
+// TerraformAgent.Run does.
+type reportingQuerier struct{}
+
+func (reportingQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	_, _ = fmt.Fprint(req.Output, "Generated 2 files.")
+	return &agent.QueryResult{
+		Files:   []string{"main.tf", "variables.tf"},
+		Sources: []string{"https://registry.terraform.io/providers/hashicorp/aws/latest/docs"},
+		Usage:   &agent.Usage{PromptTokens: 120, CompletionTokens: 30},
+	}, nil
+}
+
+// blockingQuerier waits for the chat context to end, like a hung provider.
+type blockingQuerier struct{}
+
+func (blockingQuerier) Run(ctx context.Context, _ agent.QueryRequest) (*agent.QueryResult, error) {
+	<-ctx.Done()
+	return &agent.QueryResult{ErrorCode: agent.CodeModel}, fmt.Errorf("agent: stream failed: %w", ctx.Err())
+}
+
+// chatRequests returns tfai_chat_requests_total for outcome.
+func chatRequests(t *testing.T, s *Server, outcome string) float64 {
+	t.Helper()
+	mfs, err := s.cfg.MetricsGatherer.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "tfai_chat_requests_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "outcome" && lp.GetValue() == outcome {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestAcceptsJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "*/*", want: false},
+		{accept: "text/event-stream", want: false},
+		{accept: "application/json", want: true},
+		{accept: "application/json; charset=utf-8", want: true},
+		{accept: "text/event-stream, application/json", want: false},
+		{accept: "text/html, application/json;q=0.9", want: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.accept, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodPost, "/api/chat", nil)
+			req.Header.Set("Accept", tc.accept)
+			if got := acceptsJSON(req); got != tc.want {
+				t.Errorf("acceptsJSON(%q) = %v, want %v", tc.accept, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleChat_JSONSuccess(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		accept string
+		body   string
+	}{
+		{name: "accept header", accept: "application/json", body: `{"message":"generate"}`},
+		{name: "stream false", body: `{"message":"generate","stream":false}`},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := newChatTestServer(reportingQuerier{})
+			req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			w.Header().Set(api.HeaderRequestID, "req-123")
+
+			s.handleChat(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+			var resp api.ChatResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Answer != "Generated 2 files." || !resp.FilesWritten {
+				t.Errorf("unexpected answer: %+v", resp)
+			}
+			if strings.Join(resp.Files, ",") != "main.tf,variables.tf" || len(resp.Sources) != 1 {
+				t.Errorf("unexpected files/sources: %+v", resp)
+			}
+			if resp.Usage == nil || *resp.Usage != (api.ChatUsage{PromptTokens: 120, CompletionTokens: 30}) {
+				t.Errorf("unexpected usage: %+v", resp.Usage)
+			}
+			if resp.RequestID != "req-123" {
+				t.Errorf("expected requestId req-123, got %q", resp.RequestID)
+			}
+			if got := chatRequests(t, s, outcomeOK); got != 1 {
+				t.Errorf("expected ok counter 1, got %v", got)
+			}
+		})
+	}
+}
+
+func TestHandleChat_JSONEmptyLists(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(&fakeQuerier{response: "just text"})
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi"}`))
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+
+	s.handleChat(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, `"files":[]`) || !strings.Contains(body, `"sources":[]`) {
+		t.Errorf("expected empty lists to encode as [], got %s", body)
+	}
+	if strings.Contains(body, `"usage"`) {
+		t.Errorf("expected usage to be omitted when unreported, got %s", body)
+	}
+}
+
+func TestHandleChat_JSONErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		q           querier
+		body        string
+		timeout     time.Duration
+		wantCode    int
+		wantErrCode agent.ErrorCode
+		wantOutcome string
+	}{
+		{
+			name:     "invalid body",
+			body:     `not-json`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing message",
+			body:     `{"stream":false}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:        "provider failure",
+			q:           &fakeQuerier{err: fmt.Errorf("LLM unavailable"), code: agent.CodeModel},
+			body:        `{"message":"hi"}`,
+			wantCode:    http.StatusBadGateway,
+			wantErrCode: agent.CodeModel,
+			wantOutcome: outcomeError,
+		},
+		{
+			name:        "nothing to continue",
+			q:           &fakeQuerier{err: fmt.Errorf("nothing to continue"), code: agent.CodeCannotContinue},
+			body:        `{"continue":true}`,
+			wantCode:    http.StatusConflict,
+			wantErrCode: agent.CodeCannotContinue,
+			wantOutcome: outcomeError,
+		},
+		{
+			name:        "timeout",
+			q:           blockingQuerier{},
+			body:        `{"message":"hi"}`,
+			timeout:     10 * time.Millisecond,
+			wantCode:    http.StatusGatewayTimeout,
+			wantErrCode: agent.CodeModel,
+			wantOutcome: outcomeTimeout,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := newChatTestServer(tc.q)
+			if tc.timeout > 0 {
+				s.cfg.ChatTimeout = tc.timeout
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(tc.body))
+			req.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+
+			s.handleChat(w, req)
+
+			if w.Code != tc.wantCode {
+				t.Fatalf("expected %d, got %d — body: %s", tc.wantCode, w.Code, w.Body.String())
+			}
+			var resp api.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Error == "" {
+				t.Errorf("expected JSON error body, got err=%v resp=%+v", err, resp)
+			}
+			if resp.Code != string(tc.wantErrCode) {
+				t.Errorf("expected error code %q, got %q", tc.wantErrCode, resp.Code)
+			}
+			if tc.wantOutcome == outcomeTimeout && resp.Error != "query timed out after "+tc.timeout.String() {
+				t.Errorf("expected the timeout named in the error, got %q", resp.Error)
+			}
+			if tc.wantOutcome != "" {
+				if got := chatRequests(t, s, tc.wantOutcome); got != 1 {
+					t.Errorf("expected %s counter 1, got %v", tc.wantOutcome, got)
+				}
+			}
+		})
+	}
+}
+
+// TestHandleChat_SSEOutcomeLabels verifies the streaming path records the
+// same outcome labels as JSON mode.
+func TestHandleChat_SSEOutcomeLabels(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(blockingQuerier{})
+	s.cfg.ChatTimeout = 50 * time.Millisecond
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi"}`))
+	w := httptest.NewRecorder()
+
+	s.handleChat(w, req)
+
+	events := sseEvents(w.Body.String())
+	if last := events[len(events)-1]; last != `error:"query timed out after 50ms"` {
+		t.Errorf("expected an in-band SSE timeout error, got: %q", events)
+	}
+	if got := chatRequests(t, s, outcomeTimeout); got != 1 {
+		t.Errorf("expected timeout counter 1, got %v", got)
+	}
+}
+
+// hangingQuerier streams part of an answer, signals started, and then
+// blocks until the chat context ends, as the agent does when the client
+// disconnects mid-generation.
+type hangingQuerier struct {
+	started chan struct{}
+}
+
+func (q hangingQuerier) Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	_, _ = fmt.Fprint(req.Output, "partial")
+	close(q.started)
+	<-ctx.Done()
+	return &agent.QueryResult{ErrorCode: agent.CodeCanceled}, fmt.Errorf("agent: query canceled: %w", ctx.Err())
+}
+
+func TestHandleChat_ClientDisconnect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "sse", body: `{"message":"hi"}`},
+		{name: "json", body: `{"message":"hi","stream":false}`},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			q := hangingQuerier{started: make(chan struct{})}
+			s := newChatTestServer(q)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				<-q.started
+				cancel()
+			}()
+			req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(tc.body)).WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			s.handleChat(w, req)
+
+			if strings.Contains(w.Body.String(), "event: error") || strings.Contains(w.Body.String(), `"error"`) {
+				t.Errorf("expected no error written for a client that is gone, got: %s", w.Body.String())
+			}
+			if got := chatRequests(t, s, outcomeCanceled); got != 1 {
+				t.Errorf("expected canceled counter 1, got %v", got)
+			}
+			if got := chatRequests(t, s, outcomeTimeout) + chatRequests(t, s, outcomeError); got != 0 {
+				t.Errorf("expected no timeout or error outcome, got %v", got)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST /api/chat — accepted and phase events
+// ---------------------------------------------------------------------------
+
+// slowPhaseQuerier reports each phase with a delay before answering, like an
+// agent spending time building context.
+type slowPhaseQuerier struct {
+	phases []agent.Phase
+	delay  time.Duration
+}
+
+func (q *slowPhaseQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	for _, p := range q.phases {
+		time.Sleep(q.delay)
+		req.Events.OnPhase(p)
+	}
+	_, _ = fmt.Fprint(req.Output, "answer")
+	return &agent.QueryResult{}, nil
+}
+
+// sseEvents parses an SSE body into "event:data" strings, using "message"
+// for unnamed frames.
+func sseEvents(body string) []string {
+	var out []string
+	for _, frame := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		name, data := "message", ""
+		for _, line := range strings.Split(frame, "\n") {
+			if v, ok := strings.CutPrefix(line, "event: "); ok {
+				name = v
+			} else if v, ok := strings.CutPrefix(line, "data: "); ok {
+				data = v
+			}
+		}
+		out = append(out, name+":"+data)
+	}
+	return out
+}
+
+func TestHandleChat_AcceptedBeforeInstantError(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(&fakeQuerier{err: fmt.Errorf("LLM unavailable")})
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi"}`))
+	w := httptest.NewRecorder()
+	w.Header().Set(api.HeaderRequestID, "req-9")
+
+	s.handleChat(w, req)
+
+	events := sseEvents(w.Body.String())
+	if len(events) != 2 || !strings.HasPrefix(events[0], api.EventAccepted+":") || events[1] != `error:"LLM unavailable"` {
+		t.Fatalf("expected accepted then error, got %q", events)
+	}
+	var accepted api.AcceptedEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(events[0], api.EventAccepted+":")), &accepted); err != nil {
+		t.Fatalf("accepted data: %v", err)
+	}
+	if accepted.Protocol != api.ProtocolVersion || accepted.RequestID != "req-9" || !strings.HasPrefix(accepted.ChatID, "tfai-") {
+		t.Errorf("unexpected accepted event: %+v", accepted)
+	}
+}
+
+func TestHandleChat_PhaseEventsInOrder(t *testing.T) {
+	t.Parallel()
+
+	q := &slowPhaseQuerier{
+		phases: []agent.Phase{agent.PhaseRetrievingDocs, agent.PhaseReadingWorkspace, agent.PhaseCallingModel},
+		delay:  5 * time.Millisecond,
+	}
+	s := newChatTestServer(q)
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi"}`))
+	w := httptest.NewRecorder()
+
+	s.handleChat(w, req)
+
+	events := sseEvents(w.Body.String())
+	want := []string{
+		`phase:"retrieving_docs"`,
+		`phase:"reading_workspace"`,
+		`phase:"calling_model"`,
+		"message:answer",
+		`done:"[DONE]"`,
+	}
+	if len(events) != len(want)+1 || !strings.HasPrefix(events[0], api.EventAccepted+":") {
+		t.Fatalf("expected accepted plus %d events, got %q", len(want), events)
+	}
+	for i, ev := range events[1:] {
+		if ev != want[i] {
+			t.Errorf("event %d: expected %q, got %q", i+1, want[i], ev)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST /api/chat — tool events
+// ---------------------------------------------------------------------------
+
+// toolQuerier interleaves response text with a terraform_plan call, like an
+// agent that explains what it is about to check.
+type toolQuerier struct{}
+
+func (toolQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	ev := agent.ToolEvent{Name: "terraform_plan", CallID: "call-1", Dir: "/ws/a"}
+	_, _ = fmt.Fprint(req.Output, "checking the plan")
+	req.Events.OnToolStart(ev)
+	ev.Elapsed = 1500 * time.Millisecond
+	ev.Error = "exit status 1"
+	req.Events.OnToolEnd(ev)
+	_, _ = fmt.Fprint(req.Output, "the plan fails")
+	return &agent.QueryResult{}, nil
+}
+
+func TestHandleChat_ToolEvents(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(toolQuerier{})
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"why does plan fail?"}`))
+	w := httptest.NewRecorder()
+
+	s.handleChat(w, req)
+
+	events := sseEvents(w.Body.String())
+	want := []string{
+		"message:checking the plan",
+		`tool_start:{"tool":"terraform_plan","callId":"call-1","dir":"/ws/a","elapsedMs":0}`,
+		`tool_end:{"tool":"terraform_plan","callId":"call-1","dir":"/ws/a","elapsedMs":1500,"error":"exit status 1"}`,
+		"message:the plan fails",
+		`done:"[DONE]"`,
+	}
+	if len(events) != len(want)+1 || !strings.HasPrefix(events[0], api.EventAccepted+":") {
+		t.Fatalf("expected accepted plus %d events, got %q", len(want), events)
+	}
+	for i, ev := range events[1:] {
+		if ev != want[i] {
+			t.Errorf("event %d: expected %q, got %q", i+1, want[i], ev)
+		}
+	}
+
+	var end api.ToolEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(events[3], api.EventToolEnd+":")), &end); err != nil {
+		t.Fatalf("tool_end data: %v", err)
+	}
+	if end.Tool != "terraform_plan" || end.ElapsedMs != 1500 {
+		t.Errorf("unexpected tool_end payload: %+v", end)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST /api/chat — notices
+// ---------------------------------------------------------------------------
+
+// noticeQuerier reports a notice before answering, like an agent running
+// without the terraform binary.
+type noticeQuerier struct{}
+
+func (noticeQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	if req.Events != nil {
+		req.Events.OnNotice(agent.TerraformUnavailableNotice)
+	}
+	_, _ = fmt.Fprint(req.Output, "run terraform plan yourself")
+	return &agent.QueryResult{Notices: []string{agent.TerraformUnavailableNotice}}, nil
+}
+
+func TestHandleChat_NoticeEvent(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(noticeQuerier{})
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"run terraform plan"}`))
+	w := httptest.NewRecorder()
+
+	s.handleChat(w, req)
+
+	events := sseEvents(w.Body.String())
+	want := []string{
+		"notice:" + strconv.Quote(agent.TerraformUnavailableNotice),
+		"message:run terraform plan yourself",
+		`done:"[DONE]"`,
+	}
+	if len(events) != len(want)+1 || !strings.HasPrefix(events[0], api.EventAccepted+":") {
+		t.Fatalf("expected accepted plus %d events, got %q", len(want), events)
+	}
+	for i, ev := range events[1:] {
+		if ev != want[i] {
+			t.Errorf("event %d: expected %q, got %q", i+1, want[i], ev)
+		}
+	}
+}
+
+func TestHandleChat_JSONNotices(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(noticeQuerier{})
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"run terraform plan","stream":false}`))
+	w := httptest.NewRecorder()
+
+	s.handleChat(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	var resp api.ChatResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Notices) != 1 || resp.Notices[0] != agent.TerraformUnavailableNotice {
+		t.Errorf("expected the terraform notice, got %q", resp.Notices)
+	}
+}
+
+func TestHandleChat_Truncated(t *testing.T) {
+	t.Parallel()
+
+	for _, truncated := range []bool{true, false} {
+		truncated := truncated
+		t.Run(fmt.Sprintf("truncated=%v", truncated), func(t *testing.T) {
+			t.Parallel()
+			q := &fakeQuerier{response: "part one", truncated: truncated}
+			s := newChatTestServer(q)
+			w := httptest.NewRecorder()
+			s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"continue":true}`)))
+
+			if !q.options.Continue {
+				t.Errorf("expected the continue flag passed to the querier")
+			}
+			events := sseEvents(w.Body.String())
+			want := []string{"message:part one", `done:"[DONE]"`}
+			if truncated {
+				want = []string{"message:part one", api.EventTruncated + ":true", `done:"[DONE]"`}
+			}
+			if strings.Join(events[1:], "|") != strings.Join(want, "|") {
+				t.Errorf("expected events %q after accepted, got %q", want, events)
+			}
+
+			w = httptest.NewRecorder()
+			s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi","stream":false}`)))
+			var resp api.ChatResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Truncated != truncated {
+				t.Errorf("expected truncated %v, got %+v", truncated, resp)
+			}
+		})
+	}
+}
+
+func TestHandleChat_InputsNeeded(t *testing.T) {
+	t.Parallel()
+
+	q := &fakeQuerier{
+		response: "Imported the logs bucket.",
+		files:    []string{"main.tf", "imports.tf"},
+		inputs:   []agent.ImportInput{{To: "aws_s3_bucket.logs", ID: "<bucket name>", Path: "imports.tf", Line: 1}},
+	}
+	s := newChatTestServer(q)
+	want := `{"imports":[{"to":"aws_s3_bucket.logs","id":"\u003cbucket name\u003e","path":"imports.tf","line":1}]}`
+
+	w := httptest.NewRecorder()
+	s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi","importMode":true}`)))
+	if !q.options.ImportMode {
+		t.Error("expected the import flag passed to the querier")
+	}
+	events := sseEvents(w.Body.String())
+	wantEvents := []string{"message:Imported the logs bucket.", api.EventFilesWritten + ":true", api.EventInputsNeeded + ":" + want, `done:"[DONE]"`}
+	if strings.Join(events[1:], "|") != strings.Join(wantEvents, "|") {
+		t.Errorf("expected events %q after accepted, got %q", wantEvents, events)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi","stream":false}`)))
+	var resp api.ChatResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.InputsNeeded == nil || len(resp.InputsNeeded.Imports) != 1 || resp.InputsNeeded.Imports[0].ID != "<bucket name>" {
+		t.Errorf("expected the import ID in the response, got %+v", resp.InputsNeeded)
+	}
+	if q.options.ImportMode {
+		t.Error("expected import mode off when the request does not ask for it")
+	}
+
+	q.inputs = nil
+	w = httptest.NewRecorder()
+	s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi"}`)))
+	if strings.Contains(w.Body.String(), api.EventInputsNeeded) {
+		t.Errorf("expected no inputs_needed event without placeholder IDs, got %s", w.Body.String())
+	}
+}
+
+func TestHandleChat_Timings(t *testing.T) {
+	t.Parallel()
+
+	q := &fakeQuerier{response: "ok", timings: &agent.Timings{
+		Context:    120 * time.Millisecond,
+		FirstToken: 900 * time.Millisecond,
+		Model:      1500 * time.Millisecond,
+		Tools:      800 * time.Millisecond,
+		ToolCalls:  []agent.ToolTiming{{Name: "terraform_plan", Elapsed: 800 * time.Millisecond}},
+		Total:      2500 * time.Millisecond,
+	}}
+	s := newChatTestServer(q)
+	want := api.ChatTimings{
+		ContextMs: 120, FirstTokenMs: 900, ModelMs: 1500, ToolsMs: 800, ToolCalls: 1,
+		Tools:   []api.ToolTiming{{Tool: "terraform_plan", ElapsedMs: 800}},
+		TotalMs: 2500,
+	}
+
+	w := httptest.NewRecorder()
+	s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi"}`)))
+	events := sseEvents(w.Body.String())
+	if len(events) < 2 || !strings.HasPrefix(events[len(events)-2], api.EventTimings+":") {
+		t.Fatalf("expected a timings event before done, got %q", events)
+	}
+	payload := strings.TrimPrefix(events[len(events)-2], api.EventTimings+":")
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(payload), &fields); err != nil {
+		t.Fatalf("decode timings event: %v", err)
+	}
+	for _, key := range []string{"contextMs", "firstTokenMs", "modelMs", "toolsMs", "toolCalls", "tools", "parseMs", "applyMs", "totalMs"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("expected %q in the timings event, got %s", key, payload)
+		}
+	}
+	var got api.ChatTimings
+	if err := json.Unmarshal([]byte(payload), &got); err != nil {
+		t.Fatalf("decode timings event: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want timings event %+v, got %+v", want, got)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi","stream":false}`)))
+	var resp api.ChatResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Timings == nil || !reflect.DeepEqual(*resp.Timings, want) {
+		t.Errorf("want response timings %+v, got %+v", want, resp.Timings)
+	}
+}
+
+func TestHandleChat_Disclosure(t *testing.T) {
+	t.Parallel()
+
+	const label = "AI-generated advice. Review before applying."
+	tests := []struct {
+		name       string
+		disclosure string
+		body       string
+	}{
+		{name: "sse with disclosure", disclosure: label, body: `{"message":"hi"}`},
+		{name: "sse without disclosure", body: `{"message":"hi"}`},
+		{name: "json with disclosure", disclosure: label, body: `{"message":"hi","stream":false}`},
+		{name: "json without disclosure", body: `{"message":"hi","stream":false}`},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := newChatTestServer(&fakeQuerier{response: "use for_each"})
+			s.cfg.DisclosureText = tc.disclosure
+			w := httptest.NewRecorder()
+			s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(tc.body)))
+
+			if strings.Contains(tc.body, `"stream":false`) {
+				var resp api.ChatResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if resp.Disclosure != tc.disclosure || resp.Answer != "use for_each" {
+					t.Errorf("expected disclosure %q beside the answer, got %+v", tc.disclosure, resp)
+				}
+				return
+			}
+
+			events := sseEvents(w.Body.String())
+			want := []string{"message:use for_each", `done:"[DONE]"`}
+			if tc.disclosure != "" {
+				want = []string{"message:use for_each", "disclosure:" + strconv.Quote(label), `done:"[DONE]"`}
+			}
+			if strings.Join(events[1:], "|") != strings.Join(want, "|") {
+				t.Errorf("expected events %q after accepted, got %q", want, events)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST /api/chat — temperature and maxTokens
+// ---------------------------------------------------------------------------
+
+func TestHandleChat_TuningValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{name: "negative temperature", body: `{"message":"hi","temperature":-0.1}`, want: "temperature must be between 0 and 2"},
+		{name: "temperature above 2", body: `{"message":"hi","temperature":2.5}`, want: "temperature must be between 0 and 2"},
+		{name: "negative maxTokens", body: `{"message":"hi","maxTokens":-1}`, want: "maxTokens must be between 1 and 16384"},
+		{name: "maxTokens above limit", body: `{"message":"hi","maxTokens":16385}`, want: "maxTokens must be between 1 and 16384"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			q := &fakeQuerier{response: "unused"}
+			s := newChatTestServer(q)
+			w := httptest.NewRecorder()
+			s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(tc.body)))
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d — body: %s", w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tc.want) {
+				t.Errorf("expected %q in the body, got %s", tc.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleChat_TuningPassedToAgent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		body          string
+		wantTemp      *float32
+		wantMaxTokens int
+	}{
+		{name: "sse", body: `{"message":"hi","temperature":0.2,"maxTokens":512}`, wantTemp: ptrFloat32(0.2), wantMaxTokens: 512},
+		{name: "json", body: `{"message":"hi","stream":false,"temperature":0,"maxTokens":16384}`, wantTemp: ptrFloat32(0), wantMaxTokens: 16384},
+		{name: "unset", body: `{"message":"hi","stream":false}`},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			q := &fakeQuerier{response: "ok"}
+			s := newChatTestServer(q)
+			w := httptest.NewRecorder()
+			s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(tc.body)))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+			}
+			got := q.options
+			if (got.Temperature == nil) != (tc.wantTemp == nil) ||
+				got.Temperature != nil && *got.Temperature != *tc.wantTemp {
+				t.Errorf("expected temperature %v, got %v", tc.wantTemp, got.Temperature)
+			}
+			if got.MaxTokens != tc.wantMaxTokens {
+				t.Errorf("expected maxTokens %d, got %d", tc.wantMaxTokens, got.MaxTokens)
+			}
+		})
+	}
+}
+
+// ptrFloat32 returns a pointer to v.
+func ptrFloat32(v float32) *float32 { return &v }
+
+func TestHandleChat_PassesRequestID(t *testing.T) {
+	t.Parallel()
+
+	for _, body := range []string{`{"message":"hi"}`, `{"message":"hi","stream":false}`} {
+		q := &fakeQuerier{response: "ok"}
+		s := newChatTestServer(q)
+		w := httptest.NewRecorder()
+		w.Header().Set(api.HeaderRequestID, "req-123")
+		s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(body)))
+
+		if q.requestID != "req-123" {
+			t.Errorf("%s: expected the agent to get request ID req-123 for its activity, got %q", body, q.requestID)
+		}
+	}
+}
+
+// loggingQuerier logs one line through the query context's logger, as the
+// agent does, then answers "ok".
+type loggingQuerier struct{}
+
+func (loggingQuerier) Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	logging.FromContext(ctx).Info("agent step")
+	_, _ = fmt.Fprint(req.Output, "ok")
+	return &agent.QueryResult{}, nil
+}
+
+func TestHandleChat_TraceIDs(t *testing.T) {
+	t.Parallel()
+
+	const reqID = "req-trace-1"
+	for _, tracing := range []bool{false, true} {
+		for _, body := range []string{`{"message":"hi"}`, `{"message":"hi","stream":false}`} {
+			name := fmt.Sprintf("tracing=%v %s", tracing, body)
+			s := newChatTestServer(loggingQuerier{})
+			s.cfg.Tracing = tracing
+			var logs bytes.Buffer
+			handler := requestLogger(slog.New(slog.NewJSONHandler(&logs, nil)), http.HandlerFunc(s.handleChat))
+			req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(body))
+			req.Header.Set(api.HeaderRequestID, reqID)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if got := w.Header().Get(api.HeaderTFAIRequestID); got != reqID {
+				t.Errorf("%s: expected %s %q, got %q", name, api.HeaderTFAIRequestID, reqID, got)
+			}
+			wantTrace := ""
+			if tracing {
+				wantTrace = reqID
+			}
+			if strings.Contains(body, "stream") {
+				var resp api.ChatResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("%s: decode: %v", name, err)
+				}
+				if resp.RequestID != reqID || resp.TraceID != wantTrace {
+					t.Errorf("%s: expected request ID %q and trace ID %q, got %+v", name, reqID, wantTrace, resp)
+				}
+			} else {
+				events := sseEvents(w.Body.String())
+				meta := `meta:{"requestId":"req-trace-1","traceId":"req-trace-1"}`
+				if tracing && (len(events) < 2 || events[1] != meta) {
+					t.Errorf("%s: expected %s directly after accepted, got %q", name, meta, events)
+				}
+				if !tracing && strings.Contains(w.Body.String(), "event: "+api.EventMeta) {
+					t.Errorf("%s: expected no meta event without tracing, got %q", name, events)
+				}
+			}
+
+			// Every log line of the request carries its ID, and the
+			// agent's lines carry the chat's session ID as well.
+			var agentLogged bool
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				var entry map[string]any
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("%s: decode log line %q: %v", name, line, err)
+				}
+				if entry["request_id"] != reqID {
+					t.Errorf("%s: expected request_id %s on %q", name, reqID, line)
+				}
+				if entry["msg"] == "agent step" {
+					agentLogged = true
+					if id, _ := entry["session_id"].(string); !strings.HasPrefix(id, "tfai-") {
+						t.Errorf("%s: expected the agent's log line to carry the session ID, got %q", name, line)
+					}
+				}
+			}
+			if !agentLogged {
+				t.Errorf("%s: expected the agent's log line in:\n%s", name, logs.String())
+			}
+		}
+	}
+}
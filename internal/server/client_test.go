@@ -0,0 +1,285 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+	"github.com/54b3r/tfai-go/pkg/client"
+)
+
+// ---------------------------------------------------------------------------
+// pkg/client against a real route table
+// ---------------------------------------------------------------------------
+//
+// These tests live in the server package so they can mount the full route
+// table (auth, rate limiting, request logging) with a fake querier, then
+// drive it through the public client exactly as external tooling would.
+
+// testAPIKey is the bearer token configured on servers built by
+// newClientTestServer.
+const testAPIKey = "test-key"
+
+// newClientTestServer starts an httptest server running the full route
+// table with auth enabled and returns a client configured for it.
+func newClientTestServer(t *testing.T, q querier, pingers ...Pinger) (*httptest.Server, *client.Client) {
+	t.Helper()
+
+	s := newChatTestServer(q)
+	s.cfg.APIKey = testAPIKey
+	s.pingers = pingers
+	s.readiness = newReadiness(0, 0, nil)
+
+	rl := newRateLimiter(1000, 1000, slog.Default())
+
+	handler, err := s.routes(rl)
+	if err != nil {
+		t.Fatalf("routes: %v", err)
+	}
+	ts := httptest.NewServer(requestLogger(s.log, handler))
+	t.Cleanup(ts.Close)
+
+	c, err := client.New(ts.URL, client.WithAPIKey(testAPIKey), client.WithRetries(0, 0))
+	if err != nil {
+		t.Fatalf("client.New: %v", err)
+	}
+	return ts, c
+}
+
+func TestClient_ChatStreamsEvents(t *testing.T) {
+	t.Parallel()
+
+	_, c := newClientTestServer(t, &fakeQuerier{response: "line one\nline two", files: []string{"main.tf"}})
+
+	var events []client.Event
+	err := c.Chat(context.Background(), api.ChatRequest{Message: "hi"}, func(ev client.Event) {
+		events = append(events, ev)
+	})
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	// Every stream opens with the accepted event carrying its IDs.
+	if len(events) == 0 || events[0].Type != api.EventAccepted {
+		t.Fatalf("expected accepted event first, got %+v", events)
+	}
+	var accepted api.AcceptedEvent
+	if err := json.Unmarshal([]byte(events[0].Data), &accepted); err != nil || accepted.RequestID == "" || accepted.ChatID == "" {
+		t.Errorf("expected request and chat IDs in accepted event, got %q (%v)", events[0].Data, err)
+	}
+	events = events[1:]
+
+	want := []client.Event{
+		{Type: client.EventMessage, Data: "line one\nline two"},
+		{Type: api.EventFilesWritten, Data: "true"},
+		{Type: api.EventDone, Data: `"[DONE]"`},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: expected %+v, got %+v", i, want[i], events[i])
+		}
+	}
+}
+
+func TestClient_ChatAgentError(t *testing.T) {
+	t.Parallel()
+
+	_, c := newClientTestServer(t, &fakeQuerier{err: errors.New("model unavailable")})
+
+	err := c.Chat(context.Background(), api.ChatRequest{Message: "hi"}, nil)
+	var streamErr *client.StreamError
+	if !errors.As(err, &streamErr) {
+		t.Fatalf("expected *client.StreamError, got %T: %v", err, err)
+	}
+	if streamErr.Message != "model unavailable" {
+		t.Errorf("expected the decoded agent error, got %q", streamErr.Message)
+	}
+}
+
+func TestClient_ChatValidationError(t *testing.T) {
+	t.Parallel()
+
+	_, c := newClientTestServer(t, &fakeQuerier{response: "unused"})
+
+	dir := filepath.Join(t.TempDir(), "missing")
+	err := c.Chat(context.Background(), api.ChatRequest{Message: "hi", WorkspaceDir: dir}, nil)
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *client.APIError, got %T: %v", err, err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != errCodeWorkspaceNotFound {
+		t.Errorf("expected 404 %s, got %d %s", errCodeWorkspaceNotFound, apiErr.StatusCode, apiErr.Code)
+	}
+}
+
+func TestClient_ChatComplete(t *testing.T) {
+	t.Parallel()
+
+	_, c := newClientTestServer(t, reportingQuerier{})
+
+	ctx := client.WithRequestID(context.Background(), "caller-req-7")
+	resp, err := c.ChatComplete(ctx, api.ChatRequest{Message: "hi"})
+	if err != nil {
+		t.Fatalf("ChatComplete: %v", err)
+	}
+	if resp.Answer != "Generated 2 files." || len(resp.Files) != 2 || resp.Usage == nil {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.RequestID != "caller-req-7" {
+		t.Errorf("expected requestId caller-req-7, got %q", resp.RequestID)
+	}
+
+	_, failing := newClientTestServer(t, &fakeQuerier{err: errors.New("model unavailable")})
+	_, err = failing.ChatComplete(context.Background(), api.ChatRequest{Message: "hi"})
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected 502 *client.APIError, got %T: %v", err, err)
+	}
+}
+
+func TestClient_Auth(t *testing.T) {
+	t.Parallel()
+
+	ts, _ := newClientTestServer(t, &fakeQuerier{})
+	dir := t.TempDir()
+
+	tests := []struct {
+		name       string
+		opts       []client.Option
+		wantStatus int // 0 means success
+	}{
+		{name: "no key", opts: nil, wantStatus: http.StatusUnauthorized},
+		{name: "wrong key", opts: []client.Option{client.WithAPIKey("nope")}, wantStatus: http.StatusUnauthorized},
+		{name: "correct key", opts: []client.Option{client.WithAPIKey(testAPIKey)}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := client.New(ts.URL, tc.opts...)
+			if err != nil {
+				t.Fatalf("client.New: %v", err)
+			}
+			_, err = c.Workspace(context.Background(), dir)
+			if tc.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("expected success, got %v", err)
+				}
+				return
+			}
+			var apiErr *client.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.wantStatus {
+				t.Errorf("expected APIError %d, got %v", tc.wantStatus, err)
+			}
+		})
+	}
+}
+
+func TestClient_RequestIDPropagation(t *testing.T) {
+	t.Parallel()
+
+	_, c := newClientTestServer(t, &fakeQuerier{})
+
+	ctx := client.WithRequestID(context.Background(), "caller-req-42")
+	_, err := c.Workspace(ctx, filepath.Join(t.TempDir(), "missing"))
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *client.APIError, got %T: %v", err, err)
+	}
+	if apiErr.RequestID != "caller-req-42" {
+		t.Errorf("expected server to echo request ID, got %q", apiErr.RequestID)
+	}
+}
+
+func TestClient_WorkspaceAndFiles(t *testing.T) {
+	t.Parallel()
+
+	_, c := newClientTestServer(t, &fakeQuerier{})
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	created, err := c.CreateWorkspace(ctx, api.CreateWorkspaceRequest{Dir: dir, Description: "a vpc"})
+	if err != nil {
+		t.Fatalf("CreateWorkspace: %v", err)
+	}
+	if created.Prompt == "" || len(created.Files) == 0 {
+		t.Errorf("unexpected create response: %+v", created)
+	}
+
+	ws, err := c.Workspace(ctx, dir)
+	if err != nil {
+		t.Fatalf("Workspace: %v", err)
+	}
+	if len(ws.Files) != len(created.Files) {
+		t.Errorf("expected %d files, got %v", len(created.Files), ws.Files)
+	}
+	tree, err := c.WorkspaceTree(ctx, dir)
+	if err != nil {
+		t.Fatalf("WorkspaceTree: %v", err)
+	}
+	if tree.Entries != len(created.Files) || len(tree.Root.Children) != len(created.Files) {
+		t.Errorf("expected %d tree entries, got %+v", len(created.Files), tree)
+	}
+
+	path := filepath.Join(dir, "main.tf")
+	if err := c.SaveFile(ctx, api.FileSaveRequest{WorkspaceDir: dir, Path: path, Content: "# saved"}); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	f, err := c.ReadFile(ctx, dir, path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if f.Content != "# saved" {
+		t.Errorf("expected saved content, got %q", f.Content)
+	}
+
+	_, err = c.ReadFile(ctx, dir, "/etc/passwd")
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 APIError for traversal, got %v", err)
+	}
+
+	if err := c.DeleteFile(ctx, api.FileDeleteRequest{WorkspaceDir: dir, Path: path}); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	_, err = c.ReadFile(ctx, dir, path)
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 APIError after delete, got %v", err)
+	}
+}
+
+func TestClient_ReadyAndVersion(t *testing.T) {
+	t.Parallel()
+
+	_, c := newClientTestServer(t, &fakeQuerier{},
+		&fakePinger{name: "ollama"},
+		&fakePinger{name: "qdrant", err: errors.New("connection refused")},
+	)
+	ctx := context.Background()
+
+	ready, err := c.Ready(ctx)
+	if err != nil {
+		t.Fatalf("Ready: a 503 readiness answer must not be an error, got %v", err)
+	}
+	if ready.Ready || len(ready.Checks) != 2 {
+		t.Errorf("unexpected ready response: %+v", ready)
+	}
+
+	v, err := c.Version(ctx)
+	if err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+	if v.Version == "" {
+		t.Error("expected a version string")
+	}
+}
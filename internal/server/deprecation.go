@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/54b3r/tfai-go/internal/deprecation"
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// workspaceDirParam returns the workspaceDir query parameter of r, falling
+// back to the deprecated dir parameter. When r used dir the deprecation is
+// logged (once per process, see deprecation.Warn) and returned for the
+// response's deprecations array, which is filled even when the warning is
+// suppressed: suppression quiets the server log, not the client.
+func workspaceDirParam(r *http.Request) (string, []api.Deprecation) {
+	q := r.URL.Query()
+	if dir := q.Get("workspaceDir"); dir != "" || !q.Has("dir") {
+		return dir, nil
+	}
+	deprecation.Warn(logging.FromContext(r.Context()), deprecation.WorkspaceDirParam)
+	return q.Get("dir"), []api.Deprecation{apiDeprecation(deprecation.WorkspaceDirParam)}
+}
+
+// apiDeprecation converts the registered deprecation id to its wire form.
+func apiDeprecation(id string) api.Deprecation {
+	d, _ := deprecation.Lookup(id)
+	return api.Deprecation{ID: d.ID, Field: d.Name, Replacement: d.Replacement, Message: d.Message}
+}
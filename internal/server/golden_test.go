@@ -0,0 +1,193 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// updateGolden rewrites the golden files instead of comparing against them:
+//
+//	go test ./internal/server -run Golden -update
+var updateGolden = flag.Bool("update", false, "rewrite testdata/golden files")
+
+// ---------------------------------------------------------------------------
+// Golden responses — response bodies are byte-for-byte stable
+// ---------------------------------------------------------------------------
+
+// assertGolden compares the JSON body with testdata/golden/<name>.json after
+// indenting it. Clients diff and cache these responses, so any change in
+// field or element order fails here.
+func assertGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+	var got bytes.Buffer
+	if err := json.Indent(&got, bytes.TrimSpace(body), "", "  "); err != nil {
+		t.Fatalf("response is not JSON: %v\n%s", err, body)
+	}
+	got.WriteByte('\n')
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("missing golden file (run with -update): %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("%s drifted from %s:\ngot:\n%s\nwant:\n%s", name, path, got.Bytes(), want)
+	}
+}
+
+// delayPinger is a Pinger that answers after delay.
+type delayPinger struct {
+	name  string
+	delay time.Duration
+	err   error
+}
+
+func (p *delayPinger) Name() string { return p.name }
+func (p *delayPinger) Ping(ctx context.Context) error {
+	select {
+	case <-time.After(p.delay):
+		return p.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TestGolden_Workspace verifies that GET /api/workspace lists files in
+// lexicographic order regardless of the directory walk order, which visits
+// "modules/" before its sibling "modules.tf".
+func TestGolden_Workspace(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, f := range []string{"variables.tf", "modules/vpc/main.tf", "modules.tf", "main.tf", "env/prod.tfvars", "README.md"} {
+		mustMkdir(t, filepath.Dir(filepath.Join(dir, f)))
+		mustWriteFile(t, filepath.Join(dir, f), "# "+f)
+	}
+	mustWriteFile(t, filepath.Join(dir, ".terraform.lock.hcl"), "")
+
+	s := newTestServer()
+	w := httptest.NewRecorder()
+	s.handleWorkspace(w, httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir="+dir, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	dirJSON, _ := json.Marshal(dir)
+	assertGolden(t, "workspace", bytes.ReplaceAll(w.Body.Bytes(), dirJSON, []byte(`"$WORKSPACE"`)))
+}
+
+// TestGolden_Ready verifies that GET /api/ready reports checks in pinger
+// registration order even though the probes run concurrently and finish in
+// the reverse order.
+func TestGolden_Ready(t *testing.T) {
+	t.Parallel()
+
+	s := newReadyTestServer(
+		&delayPinger{name: "llm", delay: 200 * time.Millisecond},
+		&delayPinger{name: "qdrant", delay: 100 * time.Millisecond, err: errors.New("connection refused")},
+		&delayPinger{name: "history", delay: 0},
+	)
+	start := time.Now()
+	w := httptest.NewRecorder()
+	s.handleReady(w, httptest.NewRequest(http.MethodGet, "/api/ready", nil))
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("expected the probes to run concurrently, took %v", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+	assertGolden(t, "ready", w.Body.Bytes())
+}
+
+// TestGolden_Status verifies that GET /api/status sorts tools by name
+// whatever order they were configured in.
+func TestGolden_Status(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer()
+	s.cfg.Tools = []api.ToolStatus{
+		{Name: "terraform_validate", Available: true},
+		{Name: "terraform_plan", Available: true},
+		{Name: "terraform_state", Reason: "no state backend"},
+		{Name: "terraform_fmt", Available: true},
+	}
+	s.cfg.WriteTimeout, s.cfg.ChatTimeout = 330*time.Second, 300*time.Second
+	w := httptest.NewRecorder()
+	s.handleStatus(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	assertGolden(t, "status", w.Body.Bytes())
+
+	if s.cfg.Tools[0].Name != "terraform_validate" {
+		t.Error("expected the configured tool list left unsorted")
+	}
+	if !strings.Contains(w.Body.String(), `"tools":[{"name":"terraform_fmt"`) {
+		t.Errorf("expected terraform_fmt first, got %s", w.Body.String())
+	}
+}
+
+// timestampPattern matches a JSON timestamp as written by timefmt: RFC 3339
+// in UTC with millisecond precision.
+var timestampPattern = regexp.MustCompile(`"\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z"`)
+
+// TestGolden_WorkspaceClean verifies that artifact modification times are
+// written in UTC with millisecond precision whatever zone they were read in.
+func TestGolden_WorkspaceClean(t *testing.T) {
+	t.Parallel()
+
+	dir := newCleanWorkspace(t)
+	mod := time.Date(2024, 6, 1, 14, 30, 0, 123456789, time.FixedZone("CEST", 2*60*60))
+	for _, sub := range tfaidir.Subdirs {
+		if err := os.Chtimes(filepath.Join(tfaidir.Path(dir, sub), "artifact"), mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	newTestServer().handleWorkspaceClean(w, httptest.NewRequest(http.MethodPost, "/api/workspace/clean",
+		strings.NewReader(fmt.Sprintf(`{"dir":%q,"dryRun":true}`, dir))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	dirJSON, _ := json.Marshal(dir)
+	assertGolden(t, "workspace_clean", bytes.ReplaceAll(w.Body.Bytes(), dirJSON, []byte(`"$WORKSPACE"`)))
+}
+
+// TestGolden_History verifies the createdAt format of GET /api/history.
+// The store stamps messages with the current time, so every timestamp must
+// match timestampPattern and is then replaced with "$TIME".
+func TestGolden_History(t *testing.T) {
+	t.Parallel()
+
+	w := getHistory(newHistoryTestServer(t, 2), url.Values{"workspaceDir": {"/ws/a"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	body := timestampPattern.ReplaceAllLiteral(w.Body.Bytes(), []byte(`"$TIME"`))
+	if n := bytes.Count(body, []byte(`"createdAt":"$TIME"`)); n != 2 {
+		t.Fatalf("expected 2 timefmt timestamps, got %d:\n%s", n, w.Body.String())
+	}
+	assertGolden(t, "history", body)
+}
@@ -6,9 +6,14 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/version"
+	"github.com/54b3r/tfai-go/pkg/api"
 )
 
 // probeTimeout is the maximum time allowed for each individual dependency
@@ -56,48 +61,44 @@ func (m *MultiPinger) Ping(ctx context.Context) error {
 // Name returns a combined label for logging purposes.
 func (m *MultiPinger) Name() string { return "multi" }
 
-// readyCheck holds the per-dependency result of a readiness probe.
-type readyCheck struct {
-	// Name is the dependency label (e.g. "ollama", "qdrant").
-	Name string `json:"name"`
-	// OK is true when the dependency responded successfully.
-	OK bool `json:"ok"`
-	// Error contains the failure reason when OK is false. Empty on success.
-	Error string `json:"error,omitempty"`
-}
-
-// readyResponse is the JSON body returned by GET /api/ready.
-type readyResponse struct {
-	// Ready is true only when every dependency probe succeeded.
-	Ready bool `json:"ready"`
-	// Checks contains the per-dependency probe results.
-	Checks []readyCheck `json:"checks"`
-}
-
 // handleReady handles GET /api/ready for readiness checks.
-// It probes each registered Pinger with a short timeout and returns 200 when
-// all dependencies are reachable, or 503 when any probe fails.
-// Unlike /api/health (liveness), this endpoint reflects actual dependency state.
+// It probes every registered Pinger concurrently, each with a short timeout,
+// and returns 200 when all dependencies are healthy, or 503 when any is not.
+// Results are debounced by s.readiness, so a dependency is unhealthy only
+// after several failed probes in a row. Checks are reported in registration
+// order whichever probe finishes first. Unlike /api/health (liveness), this
+// endpoint reflects actual dependency state.
 func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 	log := logging.FromContext(r.Context())
 
-	resp := readyResponse{Ready: true}
-	allOK := true
-
-	for _, p := range s.pingers {
-		probeCtx, cancel := context.WithTimeout(r.Context(), probeTimeout)
-		err := p.Ping(probeCtx)
-		cancel()
+	errs := make([]error, len(s.pingers))
+	var wg sync.WaitGroup
+	for i, p := range s.pingers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+			defer cancel()
+			errs[i] = p.Ping(probeCtx)
+		}()
+	}
+	wg.Wait()
 
-		check := readyCheck{Name: p.Name(), OK: err == nil}
+	resp := api.ReadyResponse{Ready: true}
+	allOK := true
+	for i, p := range s.pingers {
+		err := errs[i]
 		if err != nil {
-			check.Error = err.Error()
-			allOK = false
-			log.Warn("readiness probe failed",
+			// Transitions are logged by observe; this is per probe.
+			log.Debug("readiness probe failed",
 				slog.String("dependency", p.Name()),
 				slog.Any("error", err),
 			)
 		}
+		check := s.readiness.observe(log, p.Name(), err)
+		if !check.OK {
+			allOK = false
+		}
 		resp.Checks = append(resp.Checks, check)
 	}
 
@@ -114,3 +115,95 @@ func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 		log.Error("ready encode error", slog.Any("error", err))
 	}
 }
+
+// handleConfig handles GET /api/config for UI bootstrap.
+// It is intentionally unauthenticated so the UI can determine whether to
+// prompt for an API key before making any protected requests.
+// The API key value is never returned — only its presence is indicated.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	resp := map[string]bool{"auth_required": s.cfg.APIKey != ""}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("config encode error", slog.Any("error", err))
+	}
+}
+
+// handleStatus handles GET /api/status. It reports which agent tools are
+// available, sorted by name, so the UI can explain why, for example, plans
+// cannot be run. Like /api/version it is unauthenticated and returns no
+// secrets.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	resp := api.StatusResponse{Tools: sortedTools(s.cfg.Tools), Timeouts: timeoutChain(s.cfg), Loops: s.loopStatus()}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("status encode error", slog.Any("error", err))
+	}
+}
+
+// handleTools serves GET /api/tools: the tool catalog, sorted by name.
+func (s *Server) handleTools(w http.ResponseWriter, r *http.Request) {
+	tools := slices.Clone(s.cfg.ToolCatalog)
+	if tools == nil {
+		tools = []api.ToolDescription{}
+	}
+	slices.SortFunc(tools, func(a, b api.ToolDescription) int { return strings.Compare(a.Name, b.Name) })
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(api.ToolsResponse{Tools: tools}); err != nil {
+		logging.FromContext(r.Context()).Error("tools encode error", slog.Any("error", err))
+	}
+}
+
+// sortedTools returns a copy of tools sorted by name, never nil.
+func sortedTools(tools []api.ToolStatus) []api.ToolStatus {
+	out := slices.Clone(tools)
+	if out == nil {
+		out = []api.ToolStatus{}
+	}
+	slices.SortFunc(out, func(a, b api.ToolStatus) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// loopStatus reports the supervised background loops for /api/status. A
+// panic is reported only as "panicked": its value can hold paths, arguments,
+// or provider payloads, and is left to the log with its stack.
+func (s *Server) loopStatus() []api.LoopStatus {
+	out := []api.LoopStatus{}
+	if s.loops == nil {
+		return out
+	}
+	for _, st := range s.loops.Status() {
+		ls := api.LoopStatus{Name: st.Name, Running: st.Running, Restarts: st.Restarts, LastError: st.LastError}
+		if st.Panicked {
+			ls.LastError = api.LoopPanicked
+		}
+		if !st.LastRestart.IsZero() {
+			t := api.NewTimestamp(st.LastRestart)
+			ls.LastRestart = &t
+		}
+		out = append(out, ls)
+	}
+	return out
+}
+
+// handleHealth handles GET /api/health for liveness checks.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"}); err != nil {
+		logging.FromContext(r.Context()).Error("health encode error", slog.Any("error", err))
+	}
+}
+
+// handleVersion handles GET /api/version. It reports the build metadata of
+// the running binary so clients can detect version skew.
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	resp := api.VersionResponse{
+		Version:   version.Version,
+		Commit:    version.Commit,
+		BuildDate: version.BuildDate,
+		BasePath:  s.cfg.BasePath,
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("version encode error", slog.Any("error", err))
+	}
+}
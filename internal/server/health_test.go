@@ -6,7 +6,15 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/supervise"
+	"github.com/54b3r/tfai-go/pkg/api"
 )
 
 // ---------------------------------------------------------------------------
@@ -24,10 +32,16 @@ type fakePinger struct {
 func (f *fakePinger) Name() string                 { return f.name }
 func (f *fakePinger) Ping(_ context.Context) error { return f.err }
 
-// newReadyTestServer builds a *Server with the given pingers wired in.
+// readyTestTime is the clock of newReadyTestServer.
+var readyTestTime = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// newReadyTestServer builds a *Server with the given pingers wired in and
+// the default readiness thresholds, its clock pinned to readyTestTime.
 func newReadyTestServer(pingers ...Pinger) *Server {
 	s := newTestServer()
 	s.pingers = pingers
+	s.readiness = newReadiness(0, 0, nil)
+	s.readiness.now = func() time.Time { return readyTestTime }
 	return s
 }
 
@@ -82,7 +96,7 @@ func TestHandleReady_NoPingers(t *testing.T) {
 		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
 	}
 
-	var resp readyResponse
+	var resp api.ReadyResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
@@ -112,7 +126,7 @@ func TestHandleReady_AllHealthy(t *testing.T) {
 		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
 	}
 
-	var resp readyResponse
+	var resp api.ReadyResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
@@ -151,7 +165,7 @@ func TestHandleReady_OneFailing(t *testing.T) {
 		t.Fatalf("expected 503, got %d — body: %s", w.Code, w.Body.String())
 	}
 
-	var resp readyResponse
+	var resp api.ReadyResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
@@ -159,7 +173,7 @@ func TestHandleReady_OneFailing(t *testing.T) {
 		t.Errorf("expected ready:false")
 	}
 
-	var qdrantCheck *readyCheck
+	var qdrantCheck *api.ReadyCheck
 	for i := range resp.Checks {
 		if resp.Checks[i].Name == "qdrant" {
 			qdrantCheck = &resp.Checks[i]
@@ -194,7 +208,7 @@ func TestHandleReady_AllFailing(t *testing.T) {
 		t.Fatalf("expected 503, got %d — body: %s", w.Code, w.Body.String())
 	}
 
-	var resp readyResponse
+	var resp api.ReadyResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("decode: %v", err)
 	}
@@ -223,3 +237,147 @@ func TestHandleReady_ContentType(t *testing.T) {
 		t.Errorf("Content-Type: expected application/json, got %q", ct)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// GET /api/status — tool availability
+// ---------------------------------------------------------------------------
+
+// TestHandleStatus verifies that /api/status reports the configured tool
+// statuses, and an empty list rather than null when none are configured,
+// together with the effective timeout chain.
+func TestHandleStatus(t *testing.T) {
+	t.Parallel()
+
+	// The effective timeout chain and the background loops (none in a test
+	// server) are reported with every response.
+	const timeouts = `,"timeouts":{"writeMs":330000,"chatMs":300000,"probeMs":5000},"loops":[]}`
+
+	tests := []struct {
+		name  string
+		tools []api.ToolStatus
+		want  string
+	}{
+		{name: "no tools configured", want: `{"tools":[]` + timeouts},
+		{
+			name: "terraform unavailable",
+			tools: []api.ToolStatus{
+				{Name: "terraform_plan", Reason: "terraform not found"},
+				{Name: "terraform_state", Reason: "terraform not found"},
+			},
+			want: `{"tools":[{"name":"terraform_plan","available":false,"reason":"terraform not found"},` +
+				`{"name":"terraform_state","available":false,"reason":"terraform not found"}]` + timeouts,
+		},
+		{
+			name:  "terraform available",
+			tools: []api.ToolStatus{{Name: "terraform_plan", Available: true}},
+			want:  `{"tools":[{"name":"terraform_plan","available":true}]` + timeouts,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newTestServer()
+			s.cfg.Tools = tc.tools
+			s.cfg.WriteTimeout, s.cfg.ChatTimeout = 330*time.Second, 300*time.Second
+			req := httptest.NewRequest(http.MethodGet, "/api/status", nil)
+			w := httptest.NewRecorder()
+
+			s.handleStatus(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// TestHandleStatus_Loops verifies that /api/status reports the supervised
+// background loops, including a restart after a panic.
+func TestHandleStatus_Loops(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer()
+	s.loops = supervise.New(supervise.Config{MinBackoff: time.Millisecond, Registerer: prometheus.NewRegistry()})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() { cancel(); s.loops.Wait() })
+
+	var calls atomic.Int32
+	restarted := make(chan struct{})
+	s.loops.Go(ctx, "flaky", func(ctx context.Context) error {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		close(restarted)
+		<-ctx.Done()
+		return nil
+	})
+	select {
+	case <-restarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("loop was not restarted")
+	}
+
+	w := httptest.NewRecorder()
+	s.handleStatus(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	var resp api.StatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Loops) != 1 {
+		t.Fatalf("expected one loop, got %+v", resp.Loops)
+	}
+	got := resp.Loops[0]
+	if got.Name != "flaky" || !got.Running || got.Restarts != 1 || got.LastRestart == nil || got.LastError != api.LoopPanicked {
+		t.Errorf("unexpected loop status %+v", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /api/tools — tool catalog
+// ---------------------------------------------------------------------------
+
+// TestHandleTools verifies that /api/tools serves the catalog sorted by name
+// and an empty array, not null, when there is none.
+func TestHandleTools(t *testing.T) {
+	t.Parallel()
+
+	get := func(s *Server) (string, api.ToolsResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/tools", nil)
+		w := httptest.NewRecorder()
+		s.handleTools(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+		}
+		body := w.Body.String()
+		var resp api.ToolsResponse
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return body, resp
+	}
+
+	if body, _ := get(newTestServer()); strings.TrimSpace(body) != `{"tools":[]}` {
+		t.Errorf("expected an empty tools array, got %s", body)
+	}
+
+	s := newTestServer()
+	s.cfg.ToolCatalog = []api.ToolDescription{
+		{Name: "terraform_validate", Parameters: json.RawMessage(`{"type":"object"}`), Available: true},
+		{Name: "terraform_apply", Parameters: json.RawMessage(`{"type":"object"}`), RequiresConfirmation: true, Reason: "TFAI_ALLOW_APPLY is not set to true"},
+	}
+	_, resp := get(s)
+	if len(resp.Tools) != 2 || resp.Tools[0].Name != "terraform_apply" || resp.Tools[1].Name != "terraform_validate" {
+		t.Fatalf("expected the catalog sorted by name, got %+v", resp.Tools)
+	}
+	if resp.Tools[0].Available || resp.Tools[0].Reason == "" || !resp.Tools[0].RequiresConfirmation {
+		t.Errorf("expected the apply entry as configured, got %+v", resp.Tools[0])
+	}
+	if s.cfg.ToolCatalog[0].Name != "terraform_validate" {
+		t.Error("expected the configured catalog left unsorted")
+	}
+}
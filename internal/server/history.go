@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// Limits for the limit query parameter of GET /api/history.
+const (
+	// defaultHistoryLimit is used when limit is omitted.
+	defaultHistoryLimit = 50
+	// maxHistoryLimit caps limit so one request cannot load a whole thread.
+	maxHistoryLimit = 500
+)
+
+// handleHistory handles GET /api/history?workspaceDir=<abs>&limit=N. It
+// returns the most recent stored conversation turns for the workspace,
+// oldest first, so the UI can restore the chat pane after a restart. The
+// optional sessionId parameter selects a session instead of the default
+// thread. The workspace directory is not required to exist: history
+// outlives it.
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	dir, ok := s.historyWorkspace(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	sessionID := q.Get("sessionId")
+	if wsErr := s.checkSession(r.Context(), dir, sessionID); wsErr != nil {
+		writeWorkspaceError(w, wsErr)
+		return
+	}
+	limit := defaultHistoryLimit
+	if v := q.Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeJSONError(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxHistoryLimit)
+	}
+
+	msgs, err := s.cfg.History.Recent(r.Context(), dir, sessionID, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("history query error", slog.Any("error", err))
+		writeJSONError(w, "failed to load history", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]api.HistoryMessage, 0, len(msgs))
+	for _, m := range msgs {
+		msg := api.HistoryMessage{Role: string(m.Role), Content: m.Content, CreatedAt: api.NewTimestamp(m.CreatedAt)}
+		if m.Kind != store.KindMessage {
+			msg.Kind = string(m.Kind)
+		}
+		if t := m.Timings; t != nil {
+			msg.Timings = &api.ChatTimings{
+				ContextMs:    t.ContextMs,
+				FirstTokenMs: t.FirstTokenMs,
+				ModelMs:      t.ModelMs,
+				ToolsMs:      t.ToolsMs,
+				ToolCalls:    t.ToolCalls,
+				ParseMs:      t.ParseMs,
+				ApplyMs:      t.ApplyMs,
+				TotalMs:      t.TotalMs,
+			}
+		}
+		resp = append(resp, msg)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("history encode error", slog.Any("error", err))
+	}
+}
+
+// handleHistoryClear handles DELETE /api/history?workspaceDir=<abs>. It
+// deletes the workspace's stored conversation, in every session, so the next
+// query starts a fresh thread, leaving every other workspace untouched.
+func (s *Server) handleHistoryClear(w http.ResponseWriter, r *http.Request) {
+	dir, ok := s.historyWorkspace(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := s.cfg.History.Clear(r.Context(), dir)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("history clear error", slog.Any("error", err))
+		writeJSONError(w, "failed to clear history", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info("history cleared", slog.String("workspace", dir), slog.Int64("deleted", n))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(api.ClearHistoryResponse{Deleted: n}); err != nil {
+		logging.FromContext(r.Context()).Error("history encode error", slog.Any("error", err))
+	}
+}
+
+// historyWorkspace resolves the workspaceDir query parameter of a history
+// request. On failure, or when history is disabled, it writes the error
+// response and returns false.
+func (s *Server) historyWorkspace(w http.ResponseWriter, r *http.Request) (string, bool) {
+	return s.historyDir(w, r.URL.Query().Get("workspaceDir"))
+}
+
+// historyDir validates the workspace directory of a history or session
+// request. Unlike resolveWorkspace it does not require the directory to
+// exist. On failure, or when history is disabled, it writes the error
+// response and returns false.
+func (s *Server) historyDir(w http.ResponseWriter, raw string) (string, bool) {
+	if s.cfg.History == nil {
+		writeJSONError(w, "history is unavailable: conversation history is disabled", http.StatusServiceUnavailable)
+		return "", false
+	}
+	return s.storeDir(w, raw)
+}
+
+// storeDir validates the workspace directory of a request for stored data
+// (history, sessions, activity), which may outlive the directory itself: it
+// must be absolute and inside WorkspaceRoot, but need not exist. On failure
+// it writes the error response and returns false.
+func (s *Server) storeDir(w http.ResponseWriter, raw string) (string, bool) {
+	dir, err := resolveAbsDir(raw)
+	if err != nil {
+		writeJSONError(w, "workspaceDir: "+err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	if s.cfg.WorkspaceRoot != "" {
+		if _, err := ConfineToDir(s.cfg.WorkspaceRoot, dir); err != nil {
+			writeWorkspaceError(w, &workspaceError{http.StatusForbidden, errCodeWorkspaceOutsideRoot, err.Error()})
+			return "", false
+		}
+	}
+	return dir, true
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// newHistoryTestServer returns a Server reading history from a fresh
+// in-memory SQLiteStore holding n alternating user/assistant messages for
+// /ws/a and one message for /ws/b.
+func newHistoryTestServer(t *testing.T, n int) *Server {
+	t.Helper()
+	hs, err := store.Open(t.Context(), ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory store: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+	for i := 0; i < n; i++ {
+		role := store.RoleUser
+		if i%2 == 1 {
+			role = store.RoleAssistant
+		}
+		if err := hs.Append(t.Context(), "/ws/a", "", role, fmt.Sprintf("message %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hs.Append(t.Context(), "/ws/b", "", store.RoleUser, "other workspace"); err != nil {
+		t.Fatal(err)
+	}
+	return &Server{cfg: &Config{History: hs, WorkspaceRoot: "/ws"}, log: slog.Default()}
+}
+
+// getHistory calls handleHistory with the given query parameters.
+func getHistory(s *Server, params url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/history?"+params.Encode(), nil)
+	w := httptest.NewRecorder()
+	s.handleHistory(w, req)
+	return w
+}
+
+// clearHistory calls handleHistoryClear with the given query parameters.
+func clearHistory(s *Server, params url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/api/history?"+params.Encode(), nil)
+	w := httptest.NewRecorder()
+	s.handleHistoryClear(w, req)
+	return w
+}
+
+// ---------------------------------------------------------------------------
+// GET /api/history
+// ---------------------------------------------------------------------------
+
+func TestHandleHistory(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		stored    int
+		params    url.Values
+		wantCount int
+		wantFirst string
+	}{
+		{name: "all turns oldest first", stored: 4, params: url.Values{"workspaceDir": {"/ws/a"}}, wantCount: 4, wantFirst: "message 0"},
+		{name: "unclean path", stored: 4, params: url.Values{"workspaceDir": {"/ws/a/"}}, wantCount: 4, wantFirst: "message 0"},
+		{name: "limit keeps the newest", stored: 4, params: url.Values{"workspaceDir": {"/ws/a"}, "limit": {"2"}}, wantCount: 2, wantFirst: "message 2"},
+		{name: "default limit", stored: defaultHistoryLimit + 5, params: url.Values{"workspaceDir": {"/ws/a"}}, wantCount: defaultHistoryLimit, wantFirst: "message 5"},
+		{name: "limit capped", stored: maxHistoryLimit + 1, params: url.Values{"workspaceDir": {"/ws/a"}, "limit": {"10000"}}, wantCount: maxHistoryLimit, wantFirst: "message 1"},
+		{name: "no history", stored: 0, params: url.Values{"workspaceDir": {"/ws/empty"}}, wantCount: 0},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := getHistory(newHistoryTestServer(t, tc.stored), tc.params)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+			if tc.wantCount == 0 && w.Body.String() != "[]\n" {
+				t.Errorf("expected an empty array, got %q", w.Body.String())
+			}
+			var msgs []api.HistoryMessage
+			if err := json.NewDecoder(w.Body).Decode(&msgs); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(msgs) != tc.wantCount {
+				t.Fatalf("expected %d messages, got %d", tc.wantCount, len(msgs))
+			}
+			if tc.wantCount > 0 {
+				if msgs[0].Content != tc.wantFirst {
+					t.Errorf("expected first message %q, got %q", tc.wantFirst, msgs[0].Content)
+				}
+				if msgs[0].Role == "" || msgs[0].CreatedAt.IsZero() {
+					t.Errorf("expected role and createdAt, got %+v", msgs[0])
+				}
+			}
+		})
+	}
+}
+
+func TestHandleHistory_EventKinds(t *testing.T) {
+	t.Parallel()
+
+	s := newHistoryTestServer(t, 1)
+	hs := s.cfg.History.(store.EventRecorder)
+	if err := hs.AppendEvent(t.Context(), "/ws/a", "", store.KindFilesWritten, "main.tf"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := getHistory(s, url.Values{"workspaceDir": {"/ws/a"}})
+	var msgs []api.HistoryMessage
+	if err := json.NewDecoder(w.Body).Decode(&msgs); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(msgs))
+	}
+	if msgs[0].Kind != "" {
+		t.Errorf("expected no kind on a message, got %q", msgs[0].Kind)
+	}
+	if msgs[1].Kind != "files_written" || msgs[1].Content != "main.tf" {
+		t.Errorf("unexpected event row: %+v", msgs[1])
+	}
+}
+
+func TestHandleHistory_Timings(t *testing.T) {
+	t.Parallel()
+
+	s := newHistoryTestServer(t, 1)
+	hs := s.cfg.History.(store.TimingsRecorder)
+	tm := &store.Timings{ContextMs: 100, FirstTokenMs: 900, ModelMs: 1500, ToolsMs: 800, ToolCalls: 2, TotalMs: 2400}
+	if err := hs.AppendWithMeta(t.Context(), "/ws/a", "", store.RoleAssistant, "answer", nil, tm); err != nil {
+		t.Fatal(err)
+	}
+
+	w := getHistory(s, url.Values{"workspaceDir": {"/ws/a"}})
+	var msgs []api.HistoryMessage
+	if err := json.NewDecoder(w.Body).Decode(&msgs); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(msgs))
+	}
+	if msgs[0].Timings != nil {
+		t.Errorf("expected no timings on a message stored without them, got %+v", msgs[0].Timings)
+	}
+	want := api.ChatTimings{ContextMs: 100, FirstTokenMs: 900, ModelMs: 1500, ToolsMs: 800, ToolCalls: 2, TotalMs: 2400}
+	if got := msgs[1].Timings; got == nil || !reflect.DeepEqual(*got, want) {
+		t.Errorf("want timings %+v, got %+v", want, got)
+	}
+}
+
+func TestHandleHistory_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params url.Values
+		want   int
+	}{
+		{name: "missing workspaceDir", params: url.Values{}, want: http.StatusBadRequest},
+		{name: "relative workspaceDir", params: url.Values{"workspaceDir": {"ws/a"}}, want: http.StatusBadRequest},
+		{name: "outside workspace root", params: url.Values{"workspaceDir": {"/etc"}}, want: http.StatusForbidden},
+		{name: "non-numeric limit", params: url.Values{"workspaceDir": {"/ws/a"}, "limit": {"ten"}}, want: http.StatusBadRequest},
+		{name: "zero limit", params: url.Values{"workspaceDir": {"/ws/a"}, "limit": {"0"}}, want: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if w := getHistory(newHistoryTestServer(t, 1), tc.params); w.Code != tc.want {
+				t.Errorf("expected %d, got %d — body: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleHistory_Disabled(t *testing.T) {
+	t.Parallel()
+
+	w := getHistory(newTestServer(), url.Values{"workspaceDir": {"/ws/a"}})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", w.Code)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// DELETE /api/history
+// ---------------------------------------------------------------------------
+
+func TestHandleHistoryClear(t *testing.T) {
+	t.Parallel()
+
+	s := newHistoryTestServer(t, 4)
+
+	w := clearHistory(s, url.Values{"workspaceDir": {"/ws/a/"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	var resp api.ClearHistoryResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Deleted != 4 {
+		t.Errorf("expected 4 deleted, got %d", resp.Deleted)
+	}
+
+	var msgs []api.HistoryMessage
+	_ = json.NewDecoder(getHistory(s, url.Values{"workspaceDir": {"/ws/a"}}).Body).Decode(&msgs)
+	if len(msgs) != 0 {
+		t.Errorf("expected /ws/a to be empty, got %d messages", len(msgs))
+	}
+	_ = json.NewDecoder(getHistory(s, url.Values{"workspaceDir": {"/ws/b"}}).Body).Decode(&msgs)
+	if len(msgs) != 1 || msgs[0].Content != "other workspace" {
+		t.Errorf("expected /ws/b to survive the clear, got %+v", msgs)
+	}
+
+	w = clearHistory(s, url.Values{"workspaceDir": {"/ws/a"}})
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Deleted != 0 {
+		t.Errorf("expected clearing again to delete nothing, got %+v (err %v)", resp, err)
+	}
+}
+
+func TestHandleHistoryClear_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		server func(t *testing.T) *Server
+		params url.Values
+		want   int
+	}{
+		{name: "missing workspaceDir", server: func(t *testing.T) *Server { return newHistoryTestServer(t, 1) }, params: url.Values{}, want: http.StatusBadRequest},
+		{name: "outside workspace root", server: func(t *testing.T) *Server { return newHistoryTestServer(t, 1) }, params: url.Values{"workspaceDir": {"/etc"}}, want: http.StatusForbidden},
+		{name: "history disabled", server: func(*testing.T) *Server { return newTestServer() }, params: url.Values{"workspaceDir": {"/ws/a"}}, want: http.StatusServiceUnavailable},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if w := clearHistory(tc.server(t), tc.params); w.Code != tc.want {
+				t.Errorf("expected %d, got %d — body: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
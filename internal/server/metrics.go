@@ -19,7 +19,8 @@ const (
 // inject a fresh prometheus.Registry without polluting the default one.
 type serverMetrics struct {
 	// chatRequestsTotal counts completed /api/chat requests, partitioned by
-	// outcome: "ok", "timeout", or "error".
+	// outcome: "ok", "timeout", "canceled" (client disconnected), or
+	// "error".
 	chatRequestsTotal *prometheus.CounterVec
 
 	// chatDurationSeconds records the wall-clock duration of each /api/chat
@@ -29,12 +30,21 @@ type serverMetrics struct {
 	// chatActiveStreams is the number of /api/chat SSE streams currently open.
 	chatActiveStreams prometheus.Gauge
 
+	// chatStreamBytesTotal counts the bytes of SSE frames written to
+	// /api/chat streams, partitioned by event type ("message" for response
+	// text, "keepalive" for heartbeat comments).
+	chatStreamBytesTotal *prometheus.CounterVec
+
 	// httpRequestsTotal counts all HTTP requests handled by the mux,
 	// partitioned by method, path pattern, and status code.
 	httpRequestsTotal *prometheus.CounterVec
 
 	// httpDurationSeconds records the latency of all HTTP requests.
 	httpDurationSeconds *prometheus.HistogramVec
+
+	// dependencyUp is 1 while a /api/ready dependency is considered
+	// healthy and 0 otherwise, partitioned by dependency name.
+	dependencyUp *prometheus.GaugeVec
 }
 
 // newServerMetrics registers all server metrics against reg and returns the
@@ -67,6 +77,13 @@ func newServerMetrics(reg prometheus.Registerer) *serverMetrics {
 			Help:      "Number of /api/chat SSE streams currently open.",
 		}),
 
+		chatStreamBytesTotal: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tfai",
+			Subsystem: "chat",
+			Name:      "stream_bytes_total",
+			Help:      "Total bytes of SSE frames written to /api/chat streams, partitioned by event type.",
+		}, []string{"event"}),
+
 		httpRequestsTotal: factory.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "tfai",
 			Subsystem: "http",
@@ -81,5 +98,11 @@ func newServerMetrics(reg prometheus.Registerer) *serverMetrics {
 			Help:      "Latency of HTTP requests handled by the server.",
 			Buckets:   prometheus.DefBuckets,
 		}, []string{"method", labelHandler}),
+
+		dependencyUp: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "tfai",
+			Name:      "dependency_up",
+			Help:      "1 while a /api/ready dependency is considered healthy, 0 after it turned unhealthy.",
+		}, []string{"name"}),
 	}
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // newMetricsTestServer builds a Server backed by a fresh isolated registry so
@@ -109,3 +110,57 @@ func Test_Metrics_ActiveStreamsGauge(t *testing.T) {
 	}
 	t.Error("tfai_chat_active_streams not found in gathered metrics")
 }
+
+func Test_Metrics_HTTPRequestsLabelledByPattern(t *testing.T) {
+	t.Parallel()
+	ts, s := newBasePathTestServer(t, "/tfai", false)
+
+	for _, path := range []string{
+		"/tfai/api/health",
+		"/api/health",
+		"/tfai/api/file?path=a.tf&dir=/tmp/one",
+		"/tfai/api/file?path=b.tf&dir=/tmp/two",
+		"/tfai/app.css",
+		"/tfai/metrics",
+		"/outside/the/base/path",
+	} {
+		get(t, ts, path)
+	}
+
+	for _, tc := range []struct {
+		method, handler, code string
+		want                  float64
+	}{
+		{"GET", "GET /api/health", "200", 2},
+		{"GET", "GET /api/file", "400", 2},
+		{"GET", "/", "200", 1},
+		{"GET", "GET /metrics", "200", 1},
+		{"GET", "unmatched", "404", 1},
+	} {
+		got := testutil.ToFloat64(s.metrics.httpRequestsTotal.WithLabelValues(tc.method, tc.handler, tc.code))
+		if got != tc.want {
+			t.Errorf("tfai_http_requests_total{method=%q,handler=%q,code=%q}: want %v, got %v", tc.method, tc.handler, tc.code, tc.want, got)
+		}
+	}
+	if n := testutil.CollectAndCount(s.metrics.httpRequestsTotal); n != 5 {
+		t.Errorf("want 5 request series, one per pattern and code, got %d", n)
+	}
+	if n := testutil.CollectAndCount(s.metrics.httpDurationSeconds); n != 5 {
+		t.Errorf("want 5 duration series, got %d", n)
+	}
+}
+
+func TestMetricsHandlerLabel(t *testing.T) {
+	t.Parallel()
+	for _, tc := range []struct{ pattern, base, want string }{
+		{"POST /api/chat", "", "POST /api/chat"},
+		{"POST /tfai/api/chat", "/tfai", "POST /api/chat"},
+		{"/tfai/", "/tfai", "/"},
+		{"/tfai", "/tfai", "/"},
+		{"", "/tfai", "unmatched"},
+	} {
+		if got := metricsHandlerLabel(tc.pattern, tc.base); got != tc.want {
+			t.Errorf("metricsHandlerLabel(%q, %q): want %q, got %q", tc.pattern, tc.base, tc.want, got)
+		}
+	}
+}
@@ -1,31 +1,44 @@
 package server
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
-	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/pkg/api"
 )
 
 // requestLogger is an [http.Handler] middleware that:
-//  1. Generates a unique request_id for every inbound request.
+//  1. Reuses a well-formed client-supplied X-Request-ID, or generates a
+//     unique request_id for every inbound request, and returns it in the
+//     X-Request-ID and X-TFAI-Request-ID response headers.
 //  2. Injects a child [*slog.Logger] carrying that ID into the request context.
-//  3. Logs method, path, status code, and latency on completion.
+//  3. Logs method, path, status code, latency, and the class of the route
+//     that served the request on completion. Successful requests to public
+//     routes, such as probes, are logged at debug level.
 func requestLogger(base *slog.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		reqID := newRequestID()
-		w.Header().Set("X-Request-ID", reqID)
+		reqID := r.Header.Get(api.HeaderRequestID)
+		if !validRequestID(reqID) {
+			reqID = newRequestID()
+		}
+		w.Header().Set(api.HeaderRequestID, reqID)
+		w.Header().Set(api.HeaderTFAIRequestID, reqID)
 		log := base.With(
 			slog.String("request_id", reqID),
 			slog.String("method", r.Method),
 			slog.String("path", r.URL.Path),
 		)
 
+		info := &accessInfo{}
 		ctx := logging.WithLogger(r.Context(), log)
+		ctx = context.WithValue(ctx, accessInfoKey{}, info)
 		r = r.WithContext(ctx)
 
 		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
@@ -34,10 +47,40 @@ func requestLogger(base *slog.Logger, next http.Handler) http.Handler {
 		next.ServeHTTP(rw, r)
 		elapsed := time.Since(start)
 
-		log.Info("request",
+		attrs := []any{
 			slog.Int("status", rw.status),
 			slog.Duration("duration", elapsed),
-		)
+		}
+		if info.class != classUnset {
+			attrs = append(attrs, slog.String("route_class", info.class.String()))
+		}
+		if info.class == classPublic && rw.status < http.StatusBadRequest {
+			log.Debug("request", attrs...)
+			return
+		}
+		log.Info("request", attrs...)
+	})
+}
+
+// accessInfo is what requestLogger learns about a request from the route
+// that serves it.
+type accessInfo struct {
+	// class is the class of the route, or classUnset when no route in the
+	// table matched (the static UI, or a 404).
+	class routeClass
+}
+
+// accessInfoKey is the context key of a request's *accessInfo.
+type accessInfoKey struct{}
+
+// classify records class in the request's accessInfo, when requestLogger
+// set one, for the access log.
+func classify(class routeClass, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, ok := r.Context().Value(accessInfoKey{}).(*accessInfo); ok {
+			info.class = class
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
@@ -55,24 +98,64 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// metricsMiddleware records Prometheus HTTP metrics for every request.
-// It increments httpRequestsTotal (method, handler, code) and observes
-// httpDurationSeconds (method, handler) after the handler returns.
-// handler is the logical route name (e.g. "POST /api/chat") used as the
-// label value so cardinality stays bounded regardless of path parameters.
-func metricsMiddleware(m *serverMetrics, handler string, next http.Handler) http.Handler {
+// Flush forwards to the underlying writer when it supports flushing.
+// Without this, wrapping a handler in the middleware chain hides
+// [http.Flusher] and handleChat rejects every SSE request.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer so [http.ResponseController] can
+// reach optional interfaces it implements.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// metricsMiddleware records Prometheus HTTP metrics for every request the
+// mux serves. It increments httpRequestsTotal (method, handler, code) and
+// observes httpDurationSeconds (method, handler) after the handler returns.
+// handler is the ServeMux pattern that matched (e.g. "POST /api/chat"),
+// without the base path, so cardinality stays bounded regardless of path
+// parameters and query strings and dashboards do not depend on where the
+// server is mounted; "unmatched" when no pattern matched.
+func metricsMiddleware(m *serverMetrics, base string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 		start := time.Now()
+		// ServeMux sets r.Pattern on the request it is given, so it can be
+		// read once the handler returns.
 		next.ServeHTTP(rw, r)
 		elapsed := time.Since(start)
 
-		code := fmt.Sprintf("%d", rw.status)
+		handler := metricsHandlerLabel(r.Pattern, base)
+		code := strconv.Itoa(rw.status)
 		m.httpRequestsTotal.WithLabelValues(r.Method, handler, code).Inc()
 		m.httpDurationSeconds.WithLabelValues(r.Method, handler).Observe(elapsed.Seconds())
 	})
 }
 
+// metricsHandlerLabel returns the handler label of a request that matched
+// pattern on a mux mounted under base.
+func metricsHandlerLabel(pattern, base string) string {
+	if pattern == "" {
+		return "unmatched"
+	}
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	}
+	path = strings.TrimPrefix(path, base)
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+	if method == "" {
+		return path
+	}
+	return method + " " + path
+}
+
 // newRequestID returns a 16-byte cryptographically random hex string.
 // Falls back to a zero-filled ID on the (impossible in practice) error path.
 func newRequestID() string {
@@ -82,3 +165,24 @@ func newRequestID() string {
 	}
 	return hex.EncodeToString(b)
 }
+
+// maxRequestIDLen bounds client-supplied request IDs so a hostile caller
+// cannot bloat every log line for the request.
+const maxRequestIDLen = 64
+
+// validRequestID reports whether id is safe to echo into headers and logs:
+// non-empty, at most maxRequestIDLen bytes, and limited to [A-Za-z0-9._-].
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
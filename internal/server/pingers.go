@@ -9,6 +9,7 @@ import (
 	"github.com/cloudwego/eino/schema"
 	"github.com/qdrant/go-client/qdrant"
 
+	"github.com/54b3r/tfai-go/internal/logging"
 	"github.com/54b3r/tfai-go/internal/provider"
 )
 
@@ -44,7 +45,7 @@ func (p *LLMPinger) Ping(ctx context.Context) error {
 	}
 
 	// Legacy fallback — burns tokens. Remove when all providers implement HealthCheckConfig.
-	slog.Warn("pinger: falling back to Generate-based health check — tokens will be consumed",
+	logging.FromContext(ctx).Warn("pinger: falling back to Generate-based health check — tokens will be consumed",
 		slog.String("backend", p.name),
 	)
 	msgs := []*schema.Message{
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// previewTTL is how long a previewed file envelope can be applied.
+const previewTTL = 10 * time.Minute
+
+// maxFilesApplyBodyBytes bounds the POST /api/files/apply body, which only
+// carries a token.
+const maxFilesApplyBodyBytes = 4 << 10 // 4 KiB
+
+// errCodePreviewNotFound means a POST /api/files/apply token is unknown,
+// expired, or already used.
+const errCodePreviewNotFound = "preview_not_found"
+
+// envelopeApplier writes a previewed file envelope.
+// *agent.TerraformAgent satisfies it; tests inject a fake.
+type envelopeApplier interface {
+	// ApplyEnvelope writes env to workspaceDir and reports the files written.
+	// The result is non-nil even when err is not.
+	ApplyEnvelope(ctx context.Context, workspaceDir string, env *agent.TerraformAgentOutput, requestID string) (*agent.QueryResult, error)
+}
+
+// pendingPreview is a file envelope waiting for POST /api/files/apply.
+type pendingPreview struct {
+	// workspaceDir is the resolved workspace of the chat request.
+	workspaceDir string
+	// envelope is the previewed envelope.
+	envelope *agent.TerraformAgentOutput
+	// expiresAt is when the token stops being accepted.
+	expiresAt time.Time
+}
+
+// previewCache holds previewed file envelopes in memory, keyed by a random
+// token, for previewTTL. Each token can be taken once. Expired entries are
+// pruned whenever one is added.
+type previewCache struct {
+	// now returns the current time; replaced in tests.
+	now func() time.Time
+	// mu guards pending.
+	mu      sync.Mutex
+	pending map[string]pendingPreview
+}
+
+// newPreviewCache returns an empty previewCache.
+func newPreviewCache() *previewCache {
+	return &previewCache{now: time.Now, pending: make(map[string]pendingPreview)}
+}
+
+// put stores env for workspaceDir and returns its token and expiry.
+func (c *previewCache) put(workspaceDir string, env *agent.TerraformAgentOutput) (string, time.Time, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, fmt.Errorf("server: preview token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for t, p := range c.pending {
+		if !now.Before(p.expiresAt) {
+			delete(c.pending, t)
+		}
+	}
+	expiresAt := now.Add(previewTTL)
+	c.pending[token] = pendingPreview{workspaceDir: workspaceDir, envelope: env, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// take removes and returns the preview stored under token; false when there
+// is none or it has expired.
+func (c *previewCache) take(token string) (pendingPreview, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[token]
+	if !ok {
+		return pendingPreview{}, false
+	}
+	delete(c.pending, token)
+	if !c.now().Before(p.expiresAt) {
+		return pendingPreview{}, false
+	}
+	return p, true
+}
+
+// filesPreview stores the envelope previewed by res and returns its wire
+// form; nil when res previewed nothing.
+func (s *Server) filesPreview(workspaceDir string, res *agent.QueryResult) (*api.FilesPreview, error) {
+	if res.Envelope == nil {
+		return nil, nil
+	}
+	token, expiresAt, err := s.previews.put(workspaceDir, res.Envelope)
+	if err != nil {
+		return nil, err
+	}
+	preview := &api.FilesPreview{Token: token, ExpiresAt: api.NewTimestamp(expiresAt), Files: make([]api.FilePreview, 0, len(res.Preview))}
+	for _, f := range res.Preview {
+		preview.Files = append(preview.Files, api.FilePreview{Path: f.Path, New: f.New, Diff: f.Diff})
+	}
+	return preview, nil
+}
+
+// handleFilesApply handles POST /api/files/apply. It writes the file
+// envelope a chat request with previewFiles set returned a token for, to
+// that request's workspace. A token can be used once, within previewTTL.
+func (s *Server) handleFilesApply(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFilesApplyBodyBytes)
+	var req api.FilesApplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		writeJSONError(w, "token is required", http.StatusBadRequest)
+		return
+	}
+	p, ok := s.previews.take(req.Token)
+	if !ok {
+		writeWorkspaceError(w, &workspaceError{http.StatusNotFound, errCodePreviewNotFound, "preview not found or expired"})
+		return
+	}
+	// The workspace was validated by the chat request, but it may have been
+	// removed since.
+	dir, wsErr := s.resolveWorkspace(p.workspaceDir)
+	if wsErr != nil {
+		writeWorkspaceError(w, wsErr)
+		return
+	}
+
+	log := logging.FromContext(r.Context())
+	res, err := s.applier.ApplyEnvelope(r.Context(), dir, p.envelope, w.Header().Get(api.HeaderRequestID))
+	if err != nil {
+		log.Error("files apply failed", slog.String("workspace", dir), slog.Any("error", err))
+		status := http.StatusInternalServerError
+		switch res.ErrorCode {
+		case agent.CodeWorkspaceOutsideRoot:
+			status = http.StatusForbidden
+		case agent.CodeEnvelopeRejected:
+			status = http.StatusUnprocessableEntity
+		}
+		writeWorkspaceError(w, &workspaceError{status, string(res.ErrorCode), err.Error()})
+		return
+	}
+	log.Info("files applied", slog.String("workspace", dir), slog.Int("files", len(res.Files)))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(api.FilesApplyResponse{WorkspaceDir: dir, Files: res.Files, BackupDir: res.BackupDir}); err != nil {
+		log.Error("files apply encode error", slog.Any("error", err))
+	}
+}
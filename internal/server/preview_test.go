@@ -0,0 +1,232 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// fakeApplier implements envelopeApplier for tests, recording its calls.
+type fakeApplier struct {
+	// workspaceDir and envelope record the last call.
+	workspaceDir string
+	envelope     *agent.TerraformAgentOutput
+	// calls counts the calls.
+	calls int
+	// err is returned as the error value, classified as code.
+	err  error
+	code agent.ErrorCode
+}
+
+func (f *fakeApplier) ApplyEnvelope(_ context.Context, workspaceDir string, env *agent.TerraformAgentOutput, _ string) (*agent.QueryResult, error) {
+	f.calls++
+	f.workspaceDir, f.envelope = workspaceDir, env
+	if f.err != nil {
+		return &agent.QueryResult{ErrorCode: f.code}, f.err
+	}
+	res := &agent.QueryResult{BackupDir: ".tfai/backups/20260101T120000.000Z"}
+	for _, file := range env.Files {
+		res.Files = append(res.Files, file.Path)
+	}
+	return res, nil
+}
+
+// previewQuerier returns a fakeQuerier that previews a one-file envelope.
+func previewQuerier() *fakeQuerier {
+	return &fakeQuerier{
+		response: "Wrote 1 file.",
+		preview:  []agent.FilePreview{{Path: "main.tf", Diff: "--- a/main.tf\n+++ b/main.tf\n@@ -1 +1 @@\n-# old\n+# new\n"}},
+		envelope: &agent.TerraformAgentOutput{
+			Files:   []agent.GeneratedFile{{Path: "main.tf", Content: "# new\n"}},
+			Summary: "Wrote 1 file.",
+		},
+	}
+}
+
+// postFilesApply sends token to handleFilesApply.
+func postFilesApply(s *Server, token string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(api.FilesApplyRequest{Token: token})
+	w := httptest.NewRecorder()
+	s.handleFilesApply(w, httptest.NewRequest(http.MethodPost, "/api/files/apply", strings.NewReader(string(body))))
+	return w
+}
+
+func TestPreviewCache_Expiry(t *testing.T) {
+	t.Parallel()
+
+	c := newPreviewCache()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	env := &agent.TerraformAgentOutput{Summary: "s"}
+
+	token, expiresAt, err := c.put("/ws", env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiresAt.Equal(now.Add(previewTTL)) {
+		t.Errorf("expected expiry %v, got %v", now.Add(previewTTL), expiresAt)
+	}
+	p, ok := c.take(token)
+	if !ok || p.workspaceDir != "/ws" || p.envelope != env {
+		t.Fatalf("expected the stored preview, got %+v (%v)", p, ok)
+	}
+	if _, ok := c.take(token); ok {
+		t.Error("expected a token to be usable once")
+	}
+
+	expired, _, err := c.put("/ws", env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(previewTTL)
+	if _, ok := c.take(expired); ok {
+		t.Error("expected an expired token to be rejected")
+	}
+
+	// Adding a preview prunes the expired ones.
+	if _, _, err := c.put("/ws", env); err != nil {
+		t.Fatal(err)
+	}
+	stale, _, _ := c.put("/ws", env)
+	now = now.Add(previewTTL + time.Second)
+	if _, _, err := c.put("/ws", env); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.pending[stale]; ok || len(c.pending) != 1 {
+		t.Errorf("expected only the new preview to remain, got %d", len(c.pending))
+	}
+}
+
+func TestHandleChat_PreviewFiles(t *testing.T) {
+	t.Parallel()
+
+	q := previewQuerier()
+	s := newChatTestServer(q)
+	applier := &fakeApplier{}
+	s.applier = applier
+	dir := t.TempDir()
+	chatBody := func(stream bool) *strings.Reader {
+		b, _ := json.Marshal(api.ChatRequest{Message: "make a vpc", WorkspaceDir: dir, PreviewFiles: true, Stream: &stream})
+		return strings.NewReader(string(b))
+	}
+
+	w := httptest.NewRecorder()
+	s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", chatBody(true)))
+	if !q.options.PreviewFiles {
+		t.Error("expected previewFiles to reach the agent")
+	}
+	var preview api.FilesPreview
+	for _, e := range sseEvents(w.Body.String()) {
+		if strings.HasPrefix(e, api.EventFilesWritten+":") {
+			t.Errorf("expected no files_written event for a preview, got %q", e)
+		}
+		if data, ok := strings.CutPrefix(e, api.EventFilesPreview+":"); ok {
+			if err := json.Unmarshal([]byte(data), &preview); err != nil {
+				t.Fatalf("decode files_preview: %v", err)
+			}
+		}
+	}
+	if preview.Token == "" || preview.ExpiresAt.IsZero() {
+		t.Fatalf("expected a token and expiry, got %+v", preview)
+	}
+	if len(preview.Files) != 1 || preview.Files[0].Path != "main.tf" || preview.Files[0].Diff != q.preview[0].Diff {
+		t.Errorf("unexpected preview files %+v", preview.Files)
+	}
+	if applier.calls != 0 {
+		t.Fatal("expected nothing applied before POST /api/files/apply")
+	}
+
+	w = postFilesApply(s, preview.Token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	var resp api.FilesApplyResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.WorkspaceDir != dir || strings.Join(resp.Files, ",") != "main.tf" || resp.BackupDir == "" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if applier.workspaceDir != dir || applier.envelope != q.envelope {
+		t.Errorf("expected the previewed envelope applied to %s, got %+v in %s", dir, applier.envelope, applier.workspaceDir)
+	}
+
+	// The JSON response mode carries the same preview.
+	w = httptest.NewRecorder()
+	s.handleChat(w, httptest.NewRequest(http.MethodPost, "/api/chat", chatBody(false)))
+	var chat api.ChatResponse
+	if err := json.NewDecoder(w.Body).Decode(&chat); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if chat.Preview == nil || chat.Preview.Token == "" || chat.Preview.Token == preview.Token || chat.FilesWritten {
+		t.Errorf("expected a new preview and no files written, got %+v", chat)
+	}
+}
+
+func TestHandleFilesApply_Errors(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(nil)
+	applier := &fakeApplier{}
+	s.applier = applier
+	env := previewQuerier().envelope
+	dir := t.TempDir()
+
+	t.Run("unknown token", func(t *testing.T) {
+		w := postFilesApply(s, "nope")
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), errCodePreviewNotFound) {
+			t.Errorf("expected 404 %s, got %d — body: %s", errCodePreviewNotFound, w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		if w := postFilesApply(s, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d", w.Code)
+		}
+	})
+
+	t.Run("used token", func(t *testing.T) {
+		token, _, err := s.previews.put(dir, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := postFilesApply(s, token); w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+		}
+		if w := postFilesApply(s, token); w.Code != http.StatusNotFound {
+			t.Errorf("expected 404 on reuse, got %d", w.Code)
+		}
+	})
+
+	t.Run("workspace removed", func(t *testing.T) {
+		token, _, err := s.previews.put(dir+"/gone", env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := postFilesApply(s, token)
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), errCodeWorkspaceNotFound) {
+			t.Errorf("expected 404 %s, got %d — body: %s", errCodeWorkspaceNotFound, w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("rejected envelope", func(t *testing.T) {
+		s := newChatTestServer(nil)
+		s.applier = &fakeApplier{err: errors.New("too many files"), code: agent.CodeEnvelopeRejected}
+		token, _, err := s.previews.put(dir, env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := postFilesApply(s, token)
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), string(agent.CodeEnvelopeRejected)) {
+			t.Errorf("expected 422 %s, got %d — body: %s", agent.CodeEnvelopeRejected, w.Code, w.Body.String())
+		}
+	})
+}
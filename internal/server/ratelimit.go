@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"sync"
@@ -29,7 +30,8 @@ type ipEntry struct {
 }
 
 // rateLimiter is an HTTP middleware that enforces a per-IP token-bucket rate
-// limit. Stale IP entries are evicted every minute to bound memory usage.
+// limit. Stale IP entries are evicted every minute by evictLoop, which the
+// server runs under its supervisor, to bound memory usage.
 type rateLimiter struct {
 	// mu protects the ips map.
 	mu sync.Mutex
@@ -43,21 +45,16 @@ type rateLimiter struct {
 	log *slog.Logger
 }
 
-// newRateLimiter constructs a rateLimiter and starts the background eviction
-// goroutine. The goroutine exits when the returned stop function is called.
-// rps and burst are the per-IP token-bucket parameters.
-func newRateLimiter(rps float64, burst int, log *slog.Logger) (*rateLimiter, func()) {
-	rl := &rateLimiter{
+// newRateLimiter constructs a rateLimiter. rps and burst are the per-IP
+// token-bucket parameters. Stale entries are only evicted while evictLoop
+// runs.
+func newRateLimiter(rps float64, burst int, log *slog.Logger) *rateLimiter {
+	return &rateLimiter{
 		ips:   make(map[string]*ipEntry),
 		rps:   rate.Limit(rps),
 		burst: burst,
 		log:   log,
 	}
-
-	stopCh := make(chan struct{})
-	go rl.evictLoop(stopCh)
-
-	return rl, func() { close(stopCh) }
 }
 
 // getLimiter returns the per-IP limiter for the given IP, creating one if
@@ -76,15 +73,15 @@ func (rl *rateLimiter) getLimiter(ip string) *rate.Limiter {
 }
 
 // evictLoop removes IP entries that have not been seen for more than 5 minutes.
-// It runs in a background goroutine and exits when stopCh is closed.
-func (rl *rateLimiter) evictLoop(stopCh <-chan struct{}) {
+// It is a supervise.Loop and returns when ctx is done.
+func (rl *rateLimiter) evictLoop(ctx context.Context) error {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-stopCh:
-			return
+		case <-ctx.Done():
+			return nil
 		case <-ticker.C:
 			rl.evict()
 		}
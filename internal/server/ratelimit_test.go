@@ -18,8 +18,7 @@ var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 func TestRateLimit_AllowsUnderLimit(t *testing.T) {
 	t.Parallel()
 
-	rl, stop := newRateLimiter(100, 5, slog.Default())
-	defer stop()
+	rl := newRateLimiter(100, 5, slog.Default())
 
 	h := rl.middleware(okHandler)
 
@@ -40,8 +39,7 @@ func TestRateLimit_BlocksOverLimit(t *testing.T) {
 	t.Parallel()
 
 	// burst=2, rps=0.001 — third request must be rejected immediately.
-	rl, stop := newRateLimiter(0.001, 2, slog.Default())
-	defer stop()
+	rl := newRateLimiter(0.001, 2, slog.Default())
 
 	h := rl.middleware(okHandler)
 
@@ -67,8 +65,7 @@ func TestRateLimit_BlocksOverLimit(t *testing.T) {
 func TestRateLimit_RetryAfterHeader(t *testing.T) {
 	t.Parallel()
 
-	rl, stop := newRateLimiter(0.001, 1, slog.Default())
-	defer stop()
+	rl := newRateLimiter(0.001, 1, slog.Default())
 
 	h := rl.middleware(okHandler)
 
@@ -96,8 +93,7 @@ func TestRateLimit_RetryAfterHeader(t *testing.T) {
 func TestRateLimit_PerIPIsolation(t *testing.T) {
 	t.Parallel()
 
-	rl, stop := newRateLimiter(0.001, 1, slog.Default())
-	defer stop()
+	rl := newRateLimiter(0.001, 1, slog.Default())
 
 	h := rl.middleware(okHandler)
 
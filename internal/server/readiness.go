@@ -0,0 +1,135 @@
+package server
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// Defaults for Config.ReadyFailureThreshold and Config.ReadyRecoveryThreshold.
+const (
+	// DefaultReadyFailureThreshold is how many probes in a row must fail
+	// before a dependency is reported unready.
+	DefaultReadyFailureThreshold = 2
+	// DefaultReadyRecoveryThreshold is how many probes in a row must
+	// succeed before an unready dependency is reported ready again.
+	DefaultReadyRecoveryThreshold = 1
+)
+
+// dependencyState is the debounced health of one dependency.
+type dependencyState struct {
+	// up is the reported state; it only changes once a threshold is met.
+	up bool
+	// failures and successes count the latest run of identical results.
+	failures, successes int
+	// lastTransition is when up last changed, or when the dependency was
+	// first probed.
+	lastTransition time.Time
+}
+
+// readiness debounces /api/ready probe results so a dependency that is
+// briefly slow does not flap the endpoint: it turns unready only after
+// failureThreshold failed probes in a row and ready again after
+// recoveryThreshold successful ones. The first probe of a dependency sets
+// its state directly. Transitions are logged once each, not per probe.
+type readiness struct {
+	// failureThreshold and recoveryThreshold are the run lengths that
+	// change a dependency's state.
+	failureThreshold, recoveryThreshold int
+	// up is the tfai_dependency_up gauge, keyed by dependency name. May be
+	// nil.
+	up *prometheus.GaugeVec
+	// now returns the current time; tests pin it.
+	now func() time.Time
+
+	// mu guards deps.
+	mu sync.Mutex
+	// deps is the state of each dependency probed so far, by name.
+	deps map[string]*dependencyState
+}
+
+// newReadiness returns a readiness with the given thresholds, using the
+// defaults for thresholds below 1.
+func newReadiness(failureThreshold, recoveryThreshold int, up *prometheus.GaugeVec) *readiness {
+	if failureThreshold < 1 {
+		failureThreshold = DefaultReadyFailureThreshold
+	}
+	if recoveryThreshold < 1 {
+		recoveryThreshold = DefaultReadyRecoveryThreshold
+	}
+	return &readiness{
+		failureThreshold:  failureThreshold,
+		recoveryThreshold: recoveryThreshold,
+		up:                up,
+		now:               time.Now,
+		deps:              make(map[string]*dependencyState),
+	}
+}
+
+// observe records the result of one probe of the dependency name and
+// returns its check for the /api/ready response. OK is the debounced state,
+// while Error always reports the latest probe, so a failure that has not
+// yet reached the threshold is visible without failing readiness.
+func (r *readiness) observe(log *slog.Logger, name string, err error) api.ReadyCheck {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	st, seen := r.deps[name]
+	if !seen {
+		st = &dependencyState{up: err == nil, lastTransition: now}
+		r.deps[name] = st
+	}
+	if err != nil {
+		st.failures++
+		st.successes = 0
+	} else {
+		st.successes++
+		st.failures = 0
+	}
+
+	switch {
+	case !seen && err != nil:
+		log.Warn("dependency unhealthy",
+			slog.String("dependency", name),
+			slog.Any("error", err),
+		)
+	case st.up && st.failures >= r.failureThreshold:
+		log.Warn("dependency became unhealthy",
+			slog.String("dependency", name),
+			slog.Any("error", err),
+			slog.Int("consecutive_failures", st.failures),
+			slog.Duration("healthy_for", now.Sub(st.lastTransition)),
+		)
+		st.up, st.lastTransition = false, now
+	case !st.up && st.successes >= r.recoveryThreshold:
+		log.Info("dependency recovered",
+			slog.String("dependency", name),
+			slog.Duration("unhealthy_for", now.Sub(st.lastTransition)),
+		)
+		st.up, st.lastTransition = true, now
+	}
+
+	if r.up != nil {
+		v := 0.0
+		if st.up {
+			v = 1
+		}
+		r.up.WithLabelValues(name).Set(v)
+	}
+
+	check := api.ReadyCheck{
+		Name:                name,
+		OK:                  st.up,
+		ConsecutiveFailures: st.failures,
+		LastTransition:      api.NewTimestamp(st.lastTransition),
+	}
+	if err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
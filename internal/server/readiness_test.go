@@ -0,0 +1,151 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// flappingPinger fails the probes whose results entry is false, in order,
+// and succeeds once the results run out.
+type flappingPinger struct {
+	mu      sync.Mutex
+	results []bool
+}
+
+func (f *flappingPinger) Name() string { return "ollama" }
+
+func (f *flappingPinger) Ping(_ context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.results) == 0 {
+		return nil
+	}
+	ok := f.results[0]
+	f.results = f.results[1:]
+	if !ok {
+		return errors.New("context deadline exceeded")
+	}
+	return nil
+}
+
+func TestHandleReady_Hysteresis(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                 string
+		failures, recoveries int
+		probes               []bool
+		// want is the HTTP status after each probe.
+		want []int
+		// transitions are the indexes of the probes that change state.
+		transitions []int
+	}{
+		{
+			name:        "defaults",
+			probes:      []bool{true, false, true, false, false, false, true, true},
+			want:        []int{200, 200, 200, 200, 503, 503, 200, 200},
+			transitions: []int{0, 4, 6},
+		},
+		{
+			name:        "slow recovery",
+			failures:    3,
+			recoveries:  2,
+			probes:      []bool{false, true, false, true, true, false, false, false},
+			want:        []int{503, 503, 503, 503, 200, 200, 200, 503},
+			transitions: []int{0, 4, 7},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := prometheus.NewRegistry()
+			metrics := newServerMetrics(reg)
+			pinger := &flappingPinger{results: tc.probes}
+			s := newTestServer()
+			s.pingers = []Pinger{pinger}
+			s.readiness = newReadiness(tc.failures, tc.recoveries, metrics.dependencyUp)
+			clock := readyTestTime
+			s.readiness.now = func() time.Time { return clock }
+
+			var logs bytes.Buffer
+			ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&logs, nil)))
+
+			lastTransition := time.Time{}
+			for i, want := range tc.want {
+				clock = readyTestTime.Add(time.Duration(i) * time.Minute)
+				w := httptest.NewRecorder()
+				s.handleReady(w, httptest.NewRequest(http.MethodGet, "/api/ready", nil).WithContext(ctx))
+				if w.Code != want {
+					t.Errorf("probe %d: expected %d, got %d", i, want, w.Code)
+				}
+				var resp api.ReadyResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("probe %d: decode: %v", i, err)
+				}
+				check := resp.Checks[0]
+				if check.OK != (want == http.StatusOK) || (check.Error == "") != tc.probes[i] {
+					t.Errorf("probe %d: unexpected check %+v", i, check)
+				}
+				if !tc.probes[i] && check.ConsecutiveFailures == 0 || tc.probes[i] && check.ConsecutiveFailures != 0 {
+					t.Errorf("probe %d: unexpected consecutiveFailures %d", i, check.ConsecutiveFailures)
+				}
+				for _, tr := range tc.transitions {
+					if tr == i {
+						lastTransition = clock
+					}
+				}
+				if !check.LastTransition.Equal(lastTransition) {
+					t.Errorf("probe %d: expected lastTransition %v, got %v", i, lastTransition, check.LastTransition)
+				}
+				wantUp := 0.0
+				if want == http.StatusOK {
+					wantUp = 1
+				}
+				if got := testutil.ToFloat64(metrics.dependencyUp.WithLabelValues("ollama")); got != wantUp {
+					t.Errorf("probe %d: expected tfai_dependency_up %v, got %v", i, wantUp, got)
+				}
+			}
+
+			// One line per transition after the first probe, none per
+			// probe: the per-probe failure is only logged at debug.
+			var warns, infos int
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				switch {
+				case strings.Contains(line, `"level":"WARN"`):
+					warns++
+				case strings.Contains(line, `"msg":"dependency recovered"`):
+					infos++
+				}
+			}
+			wantWarns, wantInfos := 0, 0
+			for _, tr := range tc.transitions {
+				if tc.want[tr] == http.StatusOK {
+					if tr > 0 {
+						wantInfos++
+					}
+				} else {
+					wantWarns++
+				}
+			}
+			if warns != wantWarns || infos != wantInfos {
+				t.Errorf("expected %d warnings and %d recoveries logged, got %d and %d:\n%s", wantWarns, wantInfos, warns, infos, logs.String())
+			}
+		})
+	}
+}
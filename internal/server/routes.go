@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// defaultUIDir is the static UI directory used when Config.UIDir is empty.
+const defaultUIDir = "ui/static"
+
+// routeClass says who may call a route. It decides the auth, rate limiting,
+// and access logging a route gets when it is mounted; no middleware looks at
+// request paths to decide.
+type routeClass int
+
+const (
+	// classUnset is the zero value. mountRoutes rejects a route without a
+	// class, so a new route cannot be exposed by omission.
+	classUnset routeClass = iota
+	// classPublic routes need no API key and are not rate limited:
+	// Kubernetes and load balancer probes cannot send a bearer token, and
+	// clients read the bootstrap endpoints before they have one. Successful
+	// requests are logged at debug level so probes do not flood the log.
+	classPublic
+	// classAuthenticated routes require the API key and are rate limited.
+	classAuthenticated
+	// classAdmin routes describe or change the server itself. They require
+	// Config.AdminAPIKey, or the API key when it is unset, and are rate
+	// limited.
+	classAdmin
+)
+
+// String returns the class name logged as route_class.
+func (c routeClass) String() string {
+	switch c {
+	case classPublic:
+		return "public"
+	case classAuthenticated:
+		return "authenticated"
+	case classAdmin:
+		return "admin"
+	default:
+		return "unset"
+	}
+}
+
+// route is one entry in the route table.
+type route struct {
+	// pattern is the net/http ServeMux pattern, e.g. "GET /api/file". It is
+	// also the handler label on HTTP metrics.
+	pattern string
+	// handler serves the route.
+	handler http.HandlerFunc
+	// class decides the route's auth, rate limiting, and access logging.
+	class routeClass
+	// probe routes are load balancer probes. With a base path they are also
+	// mounted at their unprefixed path, unless Config.DisableRootProbes.
+	probe bool
+}
+
+// apiRoutes is the single list of /api routes. Every route is registered
+// from here exactly once, and TestRoutes walks the same list, so a handler
+// cannot be mounted twice or silently dropped.
+func (s *Server) apiRoutes() []route {
+	return []route{
+		{pattern: "POST /api/chat", handler: s.handleChat, class: classAuthenticated},
+		{pattern: "GET /api/workspace", handler: s.handleWorkspace, class: classAuthenticated},
+		{pattern: "GET /api/workspace/tree", handler: s.handleWorkspaceTree, class: classAuthenticated},
+		{pattern: "GET /api/workspace/summary", handler: s.handleWorkspaceSummary, class: classAuthenticated},
+		{pattern: "POST /api/workspace/create", handler: s.handleWorkspaceCreate, class: classAuthenticated},
+		{pattern: "POST /api/workspace/clean", handler: s.handleWorkspaceClean, class: classAuthenticated},
+		{pattern: "GET /api/workspace/activity", handler: s.handleWorkspaceActivity, class: classAuthenticated},
+		{pattern: "GET /api/usage/report", handler: s.handleUsageReport, class: classAuthenticated},
+		{pattern: "GET /api/history", handler: s.handleHistory, class: classAuthenticated},
+		{pattern: "DELETE /api/history", handler: s.handleHistoryClear, class: classAuthenticated},
+		{pattern: "POST /api/session", handler: s.handleSessionCreate, class: classAuthenticated},
+		{pattern: "GET /api/file", handler: s.handleFileRead, class: classAuthenticated},
+		{pattern: "PUT /api/file", handler: s.handleFileSave, class: classAuthenticated},
+		{pattern: "DELETE /api/file", handler: s.handleFileDelete, class: classAuthenticated},
+		{pattern: "POST /api/files/apply", handler: s.handleFilesApply, class: classAuthenticated},
+		{pattern: "GET /api/tools", handler: s.handleTools, class: classAuthenticated},
+		{pattern: "GET /api/status", handler: s.handleStatus, class: classAuthenticated},
+		{pattern: "GET /api/security-report", handler: s.handleSecurityReport, class: classAdmin},
+		// /api/health and /api/ready must always respond regardless of auth
+		// state (liveness/readiness probes); /api/config and /api/version
+		// let clients bootstrap before they have a key.
+		{pattern: "GET /api/health", handler: s.handleHealth, class: classPublic, probe: true},
+		{pattern: "GET /api/ready", handler: s.handleReady, class: classPublic, probe: true},
+		{pattern: "GET /api/config", handler: s.handleConfig, class: classPublic},
+		{pattern: "GET /api/version", handler: s.handleVersion, class: classPublic},
+	}
+}
+
+// metricsRoute is the Prometheus scrape endpoint. It is public, as scrapers
+// usually run outside the auth boundary, unless Config.MetricsAuth.
+func (s *Server) metricsRoute() route {
+	rt := route{
+		pattern: "GET /metrics",
+		handler: promhttp.HandlerFor(s.cfg.MetricsGatherer, promhttp.HandlerOpts{}).ServeHTTP,
+		class:   classPublic,
+	}
+	if s.cfg.MetricsAuth {
+		rt.class = classAuthenticated
+	}
+	return rt
+}
+
+// mountRoutes registers routes on mux under the base path with the
+// middleware their class calls for. A route without a class is an error.
+func (s *Server) mountRoutes(mux *http.ServeMux, routes []route, rl *rateLimiter) error {
+	base := s.cfg.BasePath
+	for _, rt := range routes {
+		var h http.Handler = rt.handler
+		switch rt.class {
+		case classPublic:
+		case classAuthenticated:
+			h = authMiddleware(s.cfg.APIKey, rl.middleware(h))
+		case classAdmin:
+			key := s.cfg.AdminAPIKey
+			if key == "" {
+				key = s.cfg.APIKey
+			}
+			h = authMiddleware(key, rl.middleware(h))
+		default:
+			return fmt.Errorf("server: route %q has no class", rt.pattern)
+		}
+		h = classify(rt.class, h)
+		method, path, _ := strings.Cut(rt.pattern, " ")
+		mux.Handle(method+" "+base+path, h)
+		if base != "" && rt.probe && !s.cfg.DisableRootProbes {
+			mux.Handle(rt.pattern, h)
+		}
+	}
+	return nil
+}
+
+// routes builds the request multiplexer with every API route, the metrics
+// endpoint, and the static UI, all under Config.BasePath, wrapped in the
+// HTTP metrics middleware. Split out of New so tests can mount the full
+// route table on an httptest server with a fake querier.
+func (s *Server) routes(rl *rateLimiter) (http.Handler, error) {
+	base := s.cfg.BasePath
+	mux := http.NewServeMux()
+	if err := s.mountRoutes(mux, append(s.apiRoutes(), s.metricsRoute()), rl); err != nil {
+		return nil, err
+	}
+	// Resolve ui/static relative to the binary's working directory.
+	// Using an absolute path avoids breakage when the binary is run from a
+	// different working directory than the project root.
+	uiDir := s.cfg.UIDir
+	if uiDir == "" {
+		uiDir = defaultUIDir
+	}
+	uiDir, err := filepath.Abs(uiDir)
+	if err != nil {
+		return nil, fmt.Errorf("server: failed to resolve ui/static path: %w", err)
+	}
+	mux.Handle(base+"/", http.StripPrefix(base, s.uiHandler(uiDir)))
+	if base != "" {
+		// The bare prefix would resolve the UI's relative URLs against the
+		// parent path.
+		mux.Handle(base, http.RedirectHandler(base+"/", http.StatusMovedPermanently))
+	}
+	return metricsMiddleware(s.metrics, base, mux), nil
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// Route table
+// ---------------------------------------------------------------------------
+
+// wantRoutes is every /api route the server must expose and its class.
+// Adding or removing a route, or changing who may call it, is a deliberate
+// API change and must update this list.
+var wantRoutes = map[string]routeClass{
+	"POST /api/chat":              classAuthenticated,
+	"GET /api/workspace":          classAuthenticated,
+	"POST /api/workspace/create":  classAuthenticated,
+	"POST /api/workspace/clean":   classAuthenticated,
+	"GET /api/workspace/activity": classAuthenticated,
+	"GET /api/workspace/tree":     classAuthenticated,
+	"GET /api/usage/report":       classAuthenticated,
+	"GET /api/history":            classAuthenticated,
+	"GET /api/workspace/summary":  classAuthenticated,
+	"DELETE /api/history":         classAuthenticated,
+	"POST /api/session":           classAuthenticated,
+	"GET /api/file":               classAuthenticated,
+	"PUT /api/file":               classAuthenticated,
+	"DELETE /api/file":            classAuthenticated,
+	"POST /api/files/apply":       classAuthenticated,
+	"GET /api/tools":              classAuthenticated,
+	"GET /api/status":             classAuthenticated,
+	"GET /api/security-report":    classAdmin,
+	"GET /api/health":             classPublic,
+	"GET /api/ready":              classPublic,
+	"GET /api/config":             classPublic,
+	"GET /api/version":            classPublic,
+}
+
+func TestAPIRoutes_MatchExpected(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(&fakeQuerier{})
+	got := map[string]routeClass{}
+	for _, rt := range s.apiRoutes() {
+		if _, dup := got[rt.pattern]; dup {
+			t.Errorf("route %q registered twice", rt.pattern)
+		}
+		if rt.handler == nil {
+			t.Errorf("route %q has no handler", rt.pattern)
+		}
+		if rt.class == classUnset {
+			t.Errorf("route %q has no class", rt.pattern)
+		}
+		got[rt.pattern] = rt.class
+	}
+
+	var missing, extra []string
+	for pattern, class := range wantRoutes {
+		gotClass, ok := got[pattern]
+		switch {
+		case !ok:
+			missing = append(missing, pattern)
+		case gotClass != class:
+			t.Errorf("route %q: expected class %v, got %v", pattern, class, gotClass)
+		}
+	}
+	for pattern := range got {
+		if _, ok := wantRoutes[pattern]; !ok {
+			extra = append(extra, pattern)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	if len(missing) > 0 || len(extra) > 0 {
+		t.Errorf("route table drifted: missing %v, unexpected %v", missing, extra)
+	}
+}
+
+// TestRoutes_EndToEnd mounts the full handler chain (request logger, metrics,
+// auth, rate limiting) and sends every registered route a request with and
+// without the API key. Bodies and parameters are deliberately empty: a
+// handler rejecting them with 4xx/5xx proves the request reached it, while
+// 404 or 405 means the route is not mounted where the table says.
+func TestRoutes_EndToEnd(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(&fakeQuerier{response: "ok"})
+	s.cfg.APIKey = testAPIKey
+	rl := newRateLimiter(1000, 1000, slog.Default())
+	handler, err := s.routes(rl)
+	if err != nil {
+		t.Fatalf("routes: %v", err)
+	}
+	ts := httptest.NewServer(requestLogger(s.log, handler))
+	t.Cleanup(ts.Close)
+
+	do := func(t *testing.T, method, path, key string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "application/json")
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	for _, rt := range s.apiRoutes() {
+		rt := rt
+		method, path, _ := strings.Cut(rt.pattern, " ")
+		t.Run(rt.pattern, func(t *testing.T) {
+			t.Parallel()
+
+			resp := do(t, method, path, "")
+			if rt.class != classPublic && resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("without key: expected 401, got %d", resp.StatusCode)
+			}
+			if rt.class == classPublic && isRoutingFailure(resp.StatusCode) {
+				t.Errorf("without key: route not mounted, got %d", resp.StatusCode)
+			}
+
+			resp = do(t, method, path, testAPIKey)
+			if isRoutingFailure(resp.StatusCode) {
+				t.Errorf("with key: route not mounted, got %d", resp.StatusCode)
+			}
+			if resp.Header.Get("X-Request-ID") == "" {
+				t.Error("expected the request logger to assign a request ID")
+			}
+
+			// A method no pattern allows must not reach the handler; it falls
+			// through to the static UI, which has no such file.
+			if resp := do(t, http.MethodPatch, path, testAPIKey); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("PATCH %s: expected 404 from the UI fallback, got %d", path, resp.StatusCode)
+			}
+		})
+	}
+
+	t.Run("GET /metrics", func(t *testing.T) {
+		t.Parallel()
+		if resp := do(t, http.MethodGet, "/metrics", ""); resp.StatusCode != http.StatusOK {
+			t.Errorf("expected 200 without key, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("unknown API path", func(t *testing.T) {
+		t.Parallel()
+		if resp := do(t, http.MethodGet, "/api/nope", testAPIKey); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", resp.StatusCode)
+		}
+	})
+}
+
+// isRoutingFailure reports whether status means the mux, not a handler,
+// answered the request.
+func isRoutingFailure(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusMethodNotAllowed || status == http.StatusUnauthorized
+}
+
+// TestMountRoutes_RejectsUnclassified is the guard that keeps a route from
+// being exposed without a decision on who may call it.
+func TestMountRoutes_RejectsUnclassified(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(&fakeQuerier{})
+	rl := newRateLimiter(1000, 1000, slog.Default())
+	routes := append(s.apiRoutes(), route{pattern: "GET /api/new", handler: s.handleHealth})
+	err := s.mountRoutes(http.NewServeMux(), routes, rl)
+	if err == nil || !strings.Contains(err.Error(), `"GET /api/new" has no class`) {
+		t.Errorf("expected an unclassified route to be rejected, got %v", err)
+	}
+}
+
+// TestRoutes_ProbesWithAuth checks that with auth enabled, and a rate
+// limiter with no tokens to spare, probes and other public routes answer
+// without a key while workspace routes still require one, and that admin
+// routes take the admin key when one is set.
+func TestRoutes_ProbesWithAuth(t *testing.T) {
+	t.Parallel()
+
+	const adminKey = "admin-key"
+	s := newChatTestServer(&fakeQuerier{response: "ok"})
+	s.cfg.APIKey = testAPIKey
+	s.cfg.AdminAPIKey = adminKey
+	rl := newRateLimiter(0.001, 1, slog.Default())
+	handler, err := s.routes(rl)
+	if err != nil {
+		t.Fatalf("routes: %v", err)
+	}
+
+	do := func(path, key string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		requestLogger(s.log, handler).ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for range 5 {
+		for _, path := range []string{"/api/health", "/api/ready", "/api/version", "/metrics"} {
+			if code := do(path, ""); code != http.StatusOK && code != http.StatusServiceUnavailable {
+				t.Errorf("GET %s without key: expected a probe response, got %d", path, code)
+			}
+		}
+	}
+	for _, path := range []string{"/api/workspace", "/api/status"} {
+		if code := do(path, ""); code != http.StatusUnauthorized {
+			t.Errorf("GET %s without key: expected 401, got %d", path, code)
+		}
+	}
+	if code := do("/api/security-report", testAPIKey); code != http.StatusUnauthorized {
+		t.Errorf("GET /api/security-report with the API key: expected 401, got %d", code)
+	}
+	// Rejected requests never reach the limiter: its single token goes to
+	// the first authenticated request, and admin routes are limited too.
+	if code := do("/api/tools", testAPIKey); code != http.StatusOK {
+		t.Errorf("GET /api/tools with the API key: expected 200, got %d", code)
+	}
+	if code := do("/api/security-report", adminKey); code != http.StatusTooManyRequests {
+		t.Errorf("GET /api/security-report over the limit: expected 429, got %d", code)
+	}
+}
+
+func TestRequestLogger_RouteClass(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(&fakeQuerier{})
+	s.cfg.APIKey = testAPIKey
+	handler, err := s.routes(newRateLimiter(1000, 1000, slog.Default()))
+	if err != nil {
+		t.Fatalf("routes: %v", err)
+	}
+	var logs strings.Builder
+	log := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	for _, path := range []string{"/api/health", "/api/workspace", "/nope"} {
+		requestLogger(log, handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for _, want := range []string{
+		`level=DEBUG msg=request request_id=`,
+		`path=/api/health status=200`,
+		`route_class=public`,
+		`level=INFO msg=request`,
+		`path=/api/workspace status=401`,
+		`route_class=authenticated`,
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected %q in the access log:\n%s", want, logs.String())
+		}
+	}
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, "path=/nope") && strings.Contains(line, "route_class") {
+			t.Errorf("expected no class for a path outside the route table: %s", line)
+		}
+	}
+}
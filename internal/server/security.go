@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/security"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// writeActions lists the server operations that change workspace files.
+// None of them can be switched off, so the report never claims read-only.
+var writeActions = []string{
+	"POST /api/chat (writes generated files)",
+	"POST /api/workspace/create",
+	"POST /api/workspace/clean",
+	"PUT /api/file",
+	"DELETE /api/file",
+}
+
+// SecurityReport builds the access review report for cfg as the server
+// would run it, with zero fields resolved to their defaults. It does not
+// modify cfg.
+func SecurityReport(cfg *Config) api.SecurityReport {
+	resolved := Config{}
+	if cfg != nil {
+		resolved = *cfg
+	}
+	applyDefaults(&resolved)
+	return security.Report(securityConfig(&resolved))
+}
+
+// securityConfig maps a resolved Config onto the security package's input.
+func securityConfig(cfg *Config) security.Config {
+	sc := security.Config{
+		Host:               cfg.Host,
+		Port:               cfg.Port,
+		Tools:              sortedTools(cfg.Tools),
+		WriteActions:       writeActions,
+		RateLimit:          cfg.RateLimit,
+		RateBurst:          cfg.RateBurst,
+		CORSOrigins:        corsOrigins(cfg.Port),
+		BlockSecretsOnSave: cfg.BlockSecretsOnSave,
+	}
+	// The admin key guards only the admin routes: with it alone, every
+	// other protected route is open.
+	if cfg.APIKey != "" {
+		sc.APIKeys = 1
+	}
+	sc.AdminKey = cfg.AdminAPIKey != ""
+	if cfg.WorkspaceRoot != "" {
+		sc.AllowedRoots = []string{cfg.WorkspaceRoot}
+	}
+	return sc
+}
+
+// handleSecurityReport handles GET /api/security-report. It is an admin
+// route: the report describes the attack surface, so only holders of the
+// admin key, or the API key when there is none, may read it.
+func (s *Server) handleSecurityReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(security.Report(securityConfig(s.cfg))); err != nil {
+		logging.FromContext(r.Context()).Error("security report encode error", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// ---------------------------------------------------------------------------
+// GET /api/security-report
+// ---------------------------------------------------------------------------
+
+// TestSecurityReport_Defaults verifies that the report resolves zero config
+// fields to the values the server would run with, and leaves cfg untouched.
+func TestSecurityReport_Defaults(t *testing.T) {
+	t.Parallel()
+
+	cfg := &Config{}
+	r := SecurityReport(cfg)
+	if r.Listen != "127.0.0.1:8080" || !r.Localhost {
+		t.Errorf("expected the default loopback listener, got %q", r.Listen)
+	}
+	if r.RateLimit != (api.SecurityRateLimit{RequestsPerSecond: defaultRateLimit, Burst: defaultRateBurst}) {
+		t.Errorf("expected the default rate limit, got %+v", r.RateLimit)
+	}
+	if !slices.Equal(r.CORSOrigins, []string{"http://127.0.0.1:8080", "http://localhost:8080"}) {
+		t.Errorf("unexpected CORS origins %v", r.CORSOrigins)
+	}
+	if cfg.Port != 0 || cfg.Logger != nil {
+		t.Errorf("expected cfg unmodified, got %+v", cfg)
+	}
+	// Writes are always on and there is no read-only mode to guard them.
+	if len(r.Warnings) != 1 || r.Warnings[0].Rule != "writes_without_read_only" {
+		t.Errorf("unexpected warnings %+v", r.Warnings)
+	}
+}
+
+// TestHandleSecurityReport verifies that the endpoint reports the running
+// configuration without revealing the API key.
+func TestHandleSecurityReport(t *testing.T) {
+	t.Parallel()
+
+	s := newTestServer()
+	s.cfg = &Config{Host: "0.0.0.0", Port: 9090, APIKey: "s3cret-key", WorkspaceRoot: "/srv/ws", RateLimit: 5, RateBurst: 10}
+	w := httptest.NewRecorder()
+	s.handleSecurityReport(w, httptest.NewRequest(http.MethodGet, "/api/security-report", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "s3cret-key") {
+		t.Fatal("report leaked the API key")
+	}
+	var r api.SecurityReport
+	if err := json.Unmarshal([]byte(body), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Listen != "0.0.0.0:9090" || r.Localhost || !r.Auth.Enabled || r.Auth.Keys != 1 {
+		t.Errorf("unexpected listener or auth %+v", r)
+	}
+	if !slices.Equal(r.AllowedRoots, []string{"/srv/ws"}) || r.RateLimit.Burst != 10 {
+		t.Errorf("unexpected report %+v", r)
+	}
+	for _, w := range r.Warnings {
+		if w.Rule == "auth_disabled_non_localhost" {
+			t.Errorf("expected no auth warning with a key set, got %+v", w)
+		}
+	}
+}
+
+// TestSecurityReport_AdminKeyOnly verifies that an admin key alone does not
+// count as auth: every other protected route is still open.
+func TestSecurityReport_AdminKeyOnly(t *testing.T) {
+	t.Parallel()
+
+	r := SecurityReport(&Config{Host: "0.0.0.0", AdminAPIKey: "admin-key"})
+	if r.Auth.Enabled || r.Auth.Keys != 0 || !r.Auth.AdminKey {
+		t.Errorf("expected auth disabled with only the admin key, got %+v", r.Auth)
+	}
+	if !slices.ContainsFunc(r.Warnings, func(w api.SecurityWarning) bool { return w.Rule == "auth_disabled_non_localhost" }) {
+		t.Errorf("expected the auth warning on a non-loopback host, got %+v", r.Warnings)
+	}
+}
@@ -4,29 +4,19 @@
 package server
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"path/filepath"
-	"strings"
-	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/54b3r/tfai-go/internal/agent"
 	"github.com/54b3r/tfai-go/internal/logging"
-	"github.com/54b3r/tfai-go/internal/tracing"
+	"github.com/54b3r/tfai-go/internal/supervise"
 )
 
-// requestCounter is a monotonically increasing counter used to generate
-// unique per-request session IDs for Langfuse traces.
-var requestCounter atomic.Uint64
-
 // New constructs a Server from the provided agent and config.
 // If cfg.Logger is nil, [logging.New] is used.
 func New(tfAgent *agent.TerraformAgent, cfg *Config) (*Server, error) {
@@ -36,43 +26,22 @@ func New(tfAgent *agent.TerraformAgent, cfg *Config) (*Server, error) {
 	if cfg == nil {
 		cfg = &Config{}
 	}
-	if cfg.Host == "" {
-		cfg.Host = "127.0.0.1"
-	}
-	if cfg.Port == 0 {
-		cfg.Port = 8080
-	}
-	if cfg.ReadTimeout == 0 {
-		cfg.ReadTimeout = 30 * time.Second
-	}
-	if cfg.WriteTimeout == 0 {
-		// WriteTimeout must be long enough for streaming responses.
-		cfg.WriteTimeout = 5 * time.Minute
-	}
-	if cfg.ShutdownTimeout == 0 {
-		cfg.ShutdownTimeout = 10 * time.Second
-	}
+	applyDefaults(cfg)
 
-	if cfg.Logger == nil {
-		cfg.Logger = logging.New()
-	}
-	if cfg.RateLimit == 0 {
-		cfg.RateLimit = defaultRateLimit
-	}
-	if cfg.RateBurst == 0 {
-		cfg.RateBurst = defaultRateBurst
+	// A misordered timeout chain truncates streams silently, so refuse to
+	// start rather than serve them.
+	warnings, err := checkTimeouts(cfg)
+	if err != nil {
+		return nil, err
 	}
-	if cfg.ChatTimeout == 0 {
-		cfg.ChatTimeout = 5 * time.Minute
+	if cfg.BasePath, err = normalizeBasePath(cfg.BasePath); err != nil {
+		return nil, err
 	}
-	if cfg.MetricsRegistry == nil {
-		cfg.MetricsRegistry = prometheus.DefaultRegisterer
-	}
-	if cfg.MetricsGatherer == nil {
-		cfg.MetricsGatherer = prometheus.DefaultGatherer
+	for _, w := range warnings {
+		cfg.Logger.Warn("server: " + w)
 	}
 
-	rl, stopRL := newRateLimiter(cfg.RateLimit, cfg.RateBurst, cfg.Logger)
+	rl := newRateLimiter(cfg.RateLimit, cfg.RateBurst, cfg.Logger)
 
 	if cfg.APIKey == "" {
 		cfg.Logger.Warn("auth disabled: TFAI_API_KEY not set — all API routes are unauthenticated")
@@ -80,15 +49,25 @@ func New(tfAgent *agent.TerraformAgent, cfg *Config) (*Server, error) {
 		cfg.Logger.Info("auth enabled", slog.Bool("api_key_set", true))
 	}
 
+	metrics := newServerMetrics(cfg.MetricsRegistry)
 	s := &Server{
-		agent:   tfAgent,
-		querier: tfAgent,
-		cfg:     cfg,
-		log:     cfg.Logger,
-		pingers: cfg.Pingers,
-		stopRL:  stopRL,
-		metrics: newServerMetrics(cfg.MetricsRegistry),
-	}
+		agent:     tfAgent,
+		querier:   tfAgent,
+		applier:   tfAgent,
+		previews:  newPreviewCache(),
+		cfg:       cfg,
+		log:       cfg.Logger,
+		pingers:   cfg.Pingers,
+		readiness: newReadiness(cfg.ReadyFailureThreshold, cfg.ReadyRecoveryThreshold, metrics.dependencyUp),
+		metrics:   metrics,
+		loops:     supervise.New(supervise.Config{Registerer: cfg.MetricsRegistry, Logger: cfg.Logger}),
+	}
+	loopCtx, cancelLoops := context.WithCancel(logging.WithLogger(context.Background(), cfg.Logger))
+	s.stopLoops = func() {
+		cancelLoops()
+		s.loops.Wait()
+	}
+	s.loops.Go(loopCtx, "ratelimit_evictor", rl.evictLoop)
 
 	cfg.Logger.Info("server configured",
 		slog.String("host", cfg.Host),
@@ -96,45 +75,22 @@ func New(tfAgent *agent.TerraformAgent, cfg *Config) (*Server, error) {
 		slog.Bool("auth_enabled", cfg.APIKey != ""),
 		slog.Float64("rate_limit_rps", float64(cfg.RateLimit)),
 		slog.Int("rate_burst", cfg.RateBurst),
+		slog.Duration("write_timeout", cfg.WriteTimeout),
 		slog.Duration("chat_timeout", cfg.ChatTimeout),
+		slog.Duration("probe_timeout", probeTimeout),
+		slog.Duration("provider_timeout", cfg.ProviderTimeout),
 		slog.String("workspace_root", cfg.WorkspaceRoot),
+		slog.String("base_path", cfg.BasePath),
 	)
 
-	mux := http.NewServeMux()
-	// protected wraps a handler with auth, rate-limiting, and HTTP metrics.
-	// /api/health and /api/ready are exempt — they must always respond
-	// regardless of auth state (liveness/readiness probes).
-	protected := func(pattern string, h http.Handler) http.Handler {
-		return metricsMiddleware(s.metrics, pattern,
-			authMiddleware(cfg.APIKey, rl.middleware(h)))
-	}
-	unprotected := func(pattern string, h http.Handler) http.Handler {
-		return metricsMiddleware(s.metrics, pattern, h)
-	}
-	mux.Handle("POST /api/chat", protected("POST /api/chat", http.HandlerFunc(s.handleChat)))
-	mux.Handle("GET /api/workspace", protected("GET /api/workspace", http.HandlerFunc(s.handleWorkspace)))
-	mux.Handle("POST /api/workspace/create", protected("POST /api/workspace/create", http.HandlerFunc(s.handleWorkspaceCreate)))
-	mux.Handle("GET /api/file", protected("GET /api/file", http.HandlerFunc(s.handleFileRead)))
-	mux.Handle("PUT /api/file", protected("PUT /api/file", http.HandlerFunc(s.handleFileSave)))
-	// Unprotected routes.
-	mux.Handle("GET /api/health", unprotected("GET /api/health", http.HandlerFunc(s.handleHealth)))
-	mux.Handle("GET /api/ready", unprotected("GET /api/ready", http.HandlerFunc(s.handleReady)))
-	mux.Handle("GET /api/config", unprotected("GET /api/config", http.HandlerFunc(s.handleConfig)))
-	// /metrics is intentionally unauthenticated — Prometheus scrapers run
-	// outside the auth boundary. Restrict network access at the infra layer.
-	mux.Handle("GET /metrics", promhttp.HandlerFor(cfg.MetricsGatherer, promhttp.HandlerOpts{}))
-	// Resolve ui/static relative to the binary's working directory.
-	// Using an absolute path avoids breakage when the binary is run from a
-	// different working directory than the project root.
-	uiDir, err := filepath.Abs("ui/static")
+	handler, err := s.routes(rl)
 	if err != nil {
-		return nil, fmt.Errorf("server: failed to resolve ui/static path: %w", err)
+		return nil, err
 	}
-	mux.Handle("/", http.FileServer(http.Dir(uiDir)))
 
 	s.httpServer = &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Handler:      requestLogger(s.log, mux),
+		Handler:      requestLogger(s.log, handler),
 		ReadTimeout:  cfg.ReadTimeout,
 		WriteTimeout: cfg.WriteTimeout,
 	}
@@ -164,152 +120,58 @@ func (s *Server) Start(ctx context.Context) error {
 			return fmt.Errorf("server: graceful shutdown failed: %w", err)
 		}
 		s.log.Info("server shutdown complete")
-		s.stopRL()
+		s.stopLoops()
 		return nil
 	}
 }
 
-// maxChatBodyBytes is the maximum allowed size for a /api/chat request body.
-// Prevents unbounded memory allocation from oversized requests.
-const maxChatBodyBytes = 1 << 20 // 1 MiB
-
-// handleChat handles POST /api/chat requests. It streams the agent's response
-// using Server-Sent Events (SSE) so the UI can render tokens as they arrive.
-func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
-	r.Body = http.MaxBytesReader(w, r.Body, maxChatBodyBytes)
-	var req chatRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
-		return
+// applyDefaults fills the zero fields of cfg with their defaults.
+func applyDefaults(cfg *Config) {
+	if cfg.Host == "" {
+		cfg.Host = "127.0.0.1"
 	}
-	if req.Message == "" {
-		http.Error(w, "message is required", http.StatusBadRequest)
-		return
+	if cfg.Port == 0 {
+		cfg.Port = 8080
 	}
-
-	// Validate workspaceDir is absolute if provided — same constraint as file API.
-	if req.WorkspaceDir != "" && !filepath.IsAbs(filepath.Clean(req.WorkspaceDir)) {
-		http.Error(w, "workspaceDir must be an absolute path", http.StatusBadRequest)
-		return
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = 30 * time.Second
 	}
-
-	// Set SSE headers so the client receives a streaming response.
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	// Restrict CORS to the configured localhost origin only — this server is local-only.
-	origin := r.Header.Get("Origin")
-	allowedOrigin127 := fmt.Sprintf("http://127.0.0.1:%d", s.cfg.Port)
-	allowedOriginLocal := fmt.Sprintf("http://localhost:%d", s.cfg.Port)
-	if origin == allowedOrigin127 || origin == allowedOriginLocal || origin == "" {
-		w.Header().Set("Access-Control-Allow-Origin", origin)
+	if cfg.ChatTimeout == 0 {
+		cfg.ChatTimeout = 5 * time.Minute
 	}
-
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "streaming not supported", http.StatusInternalServerError)
-		return
+	if cfg.WriteTimeout == 0 {
+		// WriteTimeout must outlast the longest chat stream.
+		cfg.WriteTimeout = cfg.ChatTimeout + writeTimeoutMargin
 	}
-
-	// Stamp the request context with a unique session ID so each chat
-	// request appears as a distinct named trace in Langfuse.
-	sessionID := fmt.Sprintf("tfai-%d-%d", time.Now().UnixMilli(), requestCounter.Add(1))
-
-	// Apply a hard deadline on the LLM call so a hung backend never blocks
-	// the goroutine indefinitely. The timeout matches WriteTimeout by default.
-	chatCtx, cancelChat := context.WithTimeout(r.Context(), s.cfg.ChatTimeout)
-	defer cancelChat()
-	ctx := tracing.SetRequestTrace(chatCtx, sessionID)
-
-	log := logging.FromContext(r.Context()).With(
-		slog.String("session_id", sessionID),
-		slog.String("workspace", req.WorkspaceDir),
-	)
-	log.Info("chat start", slog.String("message", req.Message))
-
-	// Track active streams and record duration + outcome for every request.
-	s.metrics.chatActiveStreams.Inc()
-	start := time.Now()
-	defer s.metrics.chatActiveStreams.Dec()
-
-	// sseWriter wraps the ResponseWriter to emit SSE-formatted data events.
-	sw := &sseWriter{w: w, flusher: flusher}
-
-	filesWritten, err := s.querier.Query(ctx, req.Message, req.WorkspaceDir, sw)
-	if err != nil {
-		outcome := "error"
-		if ctx.Err() != nil {
-			outcome = "timeout"
-		}
-		s.metrics.chatRequestsTotal.WithLabelValues(outcome).Inc()
-		s.metrics.chatDurationSeconds.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
-		log.Error("chat agent error", slog.Any("error", err))
-		_, _ = fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
-		flusher.Flush()
-		return
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 10 * time.Second
 	}
 
-	duration := time.Since(start)
-	s.metrics.chatRequestsTotal.WithLabelValues("ok").Inc()
-	s.metrics.chatDurationSeconds.WithLabelValues("ok").Observe(duration.Seconds())
-	log.Info("chat complete",
-		slog.Duration("duration", duration),
-		slog.Bool("files_written", filesWritten),
-	)
-
-	if filesWritten {
-		_, _ = fmt.Fprintf(w, "event: files_written\ndata: true\n\n")
+	if cfg.Logger == nil {
+		cfg.Logger = logging.New()
 	}
-	// Signal stream completion.
-	_, _ = fmt.Fprintf(w, "event: done\ndata: [DONE]\n\n")
-	flusher.Flush()
-}
-
-// handleConfig handles GET /api/config for UI bootstrap.
-// It is intentionally unauthenticated so the UI can determine whether to
-// prompt for an API key before making any protected requests.
-// The API key value is never returned — only its presence is indicated.
-func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	resp := map[string]bool{"auth_required": s.cfg.APIKey != ""}
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logging.FromContext(r.Context()).Error("config encode error", slog.Any("error", err))
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = defaultRateLimit
 	}
-}
-
-// handleHealth handles GET /api/health for liveness checks.
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "ok"}); err != nil {
-		logging.FromContext(r.Context()).Error("health encode error", slog.Any("error", err))
+	if cfg.MaxTokensLimit == 0 {
+		cfg.MaxTokensLimit = DefaultMaxTokensLimit
 	}
-}
-
-// sseWriter wraps an http.ResponseWriter to emit Server-Sent Event data frames.
-type sseWriter struct {
-	// w is the underlying response writer.
-	w http.ResponseWriter
-
-	// flusher flushes buffered data to the client after each write.
-	flusher http.Flusher
-}
-
-// Write formats p as one or more SSE data lines and flushes to the client.
-// Each newline in p is prefixed with "data: " so multi-line chunks never
-// break the SSE frame boundary.
-func (s *sseWriter) Write(p []byte) (n int, err error) {
-	chunk := strings.TrimRight(string(bytes.Clone(p)), "\n")
-	lines := strings.Split(chunk, "\n")
-	var buf strings.Builder
-	for _, line := range lines {
-		buf.WriteString("data: ")
-		buf.WriteString(line)
-		buf.WriteString("\n")
+	if cfg.SSEHeartbeat == 0 {
+		cfg.SSEHeartbeat = DefaultSSEHeartbeat
 	}
-	buf.WriteString("\n")
-	if _, err = fmt.Fprint(s.w, buf.String()); err != nil {
-		return 0, err //nolint:wrapcheck // SSE writer error
+	if cfg.RateBurst == 0 {
+		cfg.RateBurst = defaultRateBurst
+	}
+	if cfg.ReadyFailureThreshold == 0 {
+		cfg.ReadyFailureThreshold = DefaultReadyFailureThreshold
+	}
+	if cfg.ReadyRecoveryThreshold == 0 {
+		cfg.ReadyRecoveryThreshold = DefaultReadyRecoveryThreshold
+	}
+	if cfg.MetricsRegistry == nil {
+		cfg.MetricsRegistry = prometheus.DefaultRegisterer
+	}
+	if cfg.MetricsGatherer == nil {
+		cfg.MetricsGatherer = prometheus.DefaultGatherer
 	}
-	s.flusher.Flush()
-	return len(p), nil
 }
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// maxSessionCreateBodyBytes is the maximum allowed size for a POST
+// /api/session request body.
+const maxSessionCreateBodyBytes = 64 << 10 // 64 KiB
+
+// handleSessionCreate handles POST /api/session. It starts a new
+// conversation session for the workspace in the request body, so a second
+// task in the same directory gets a thread of its own instead of extending
+// the workspace's default one.
+func (s *Server) handleSessionCreate(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSessionCreateBodyBytes)
+	var req api.CreateSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	dir, ok := s.historyDir(w, req.WorkspaceDir)
+	if !ok {
+		return
+	}
+	sessions, ok := s.cfg.History.(store.SessionStore)
+	if !ok {
+		writeJSONError(w, "sessions are unavailable: the history store does not support them", http.StatusServiceUnavailable)
+		return
+	}
+
+	sess, err := sessions.CreateSession(r.Context(), dir)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("session create error", slog.Any("error", err))
+		writeJSONError(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	logging.FromContext(r.Context()).Info("session created", slog.String("workspace", dir), slog.String("conversation_session", sess.ID))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(api.SessionResponse{SessionID: sess.ID, WorkspaceDir: dir, CreatedAt: api.NewTimestamp(sess.CreatedAt)}); err != nil {
+		logging.FromContext(r.Context()).Error("session encode error", slog.Any("error", err))
+	}
+}
+
+// checkSession verifies that id is a session of the workspace dir. The
+// empty ID is the workspace's default thread and always valid. History must
+// be enabled.
+func (s *Server) checkSession(ctx context.Context, dir, id string) *workspaceError {
+	if id == store.DefaultSession {
+		return nil
+	}
+	notFound := &workspaceError{http.StatusNotFound, errCodeSessionNotFound, "sessionId: no such session for this workspace"}
+	sessions, ok := s.cfg.History.(store.SessionStore)
+	if !ok {
+		return notFound
+	}
+	_, err := sessions.Session(ctx, dir, id)
+	switch {
+	case errors.Is(err, store.ErrSessionNotFound):
+		return notFound
+	case err != nil:
+		logging.FromContext(ctx).Error("session lookup error", slog.Any("error", err))
+		return &workspaceError{http.StatusInternalServerError, "", "failed to look up session"}
+	default:
+		return nil
+	}
+}
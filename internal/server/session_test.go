@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// postSession calls handleSessionCreate with body.
+func postSession(s *Server, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/session", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.handleSessionCreate(w, req)
+	return w
+}
+
+// mustCreateSession creates a session for dir through the handler.
+func mustCreateSession(t *testing.T, s *Server, dir string) string {
+	t.Helper()
+	w := postSession(s, fmt.Sprintf(`{"workspaceDir":%q}`, dir))
+	if w.Code != http.StatusOK {
+		t.Fatalf("create session: expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	var resp api.SessionResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp.SessionID
+}
+
+// ---------------------------------------------------------------------------
+// POST /api/session
+// ---------------------------------------------------------------------------
+
+func TestHandleSessionCreate(t *testing.T) {
+	t.Parallel()
+
+	s := newHistoryTestServer(t, 0)
+	w := postSession(s, `{"workspaceDir":"/ws/a/"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	var resp api.SessionResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.SessionID == "" || resp.WorkspaceDir != "/ws/a" || resp.CreatedAt.IsZero() {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if _, err := s.cfg.History.(store.SessionStore).Session(t.Context(), "/ws/a", resp.SessionID); err != nil {
+		t.Errorf("expected the session to be stored: %v", err)
+	}
+}
+
+func TestHandleSessionCreate_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{name: "invalid body", body: `{`, want: http.StatusBadRequest},
+		{name: "missing workspaceDir", body: `{}`, want: http.StatusBadRequest},
+		{name: "relative workspaceDir", body: `{"workspaceDir":"ws/a"}`, want: http.StatusBadRequest},
+		{name: "outside workspace root", body: `{"workspaceDir":"/etc"}`, want: http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if w := postSession(newHistoryTestServer(t, 0), tc.body); w.Code != tc.want {
+				t.Errorf("expected %d, got %d — body: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleSessionCreate_Disabled(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{cfg: &Config{}, log: slog.Default()}
+	if w := postSession(s, `{"workspaceDir":"/ws/a"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d — body: %s", w.Code, w.Body.String())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Sessions through GET /api/history and POST /api/chat
+// ---------------------------------------------------------------------------
+
+func TestHandleHistory_Sessions(t *testing.T) {
+	t.Parallel()
+
+	s := newHistoryTestServer(t, 2)
+	hs := s.cfg.History
+	one, two := mustCreateSession(t, s, "/ws/a"), mustCreateSession(t, s, "/ws/a")
+	if err := hs.Append(t.Context(), "/ws/a", one, store.RoleUser, "task one"); err != nil {
+		t.Fatal(err)
+	}
+	if err := hs.Append(t.Context(), "/ws/a", two, store.RoleUser, "task two"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		sessionID string
+		want      []string
+	}{
+		{name: "default thread", want: []string{"message 0", "message 1"}},
+		{name: "session one", sessionID: one, want: []string{"task one"}},
+		{name: "session two", sessionID: two, want: []string{"task two"}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			params := url.Values{"workspaceDir": {"/ws/a"}}
+			if tc.sessionID != "" {
+				params.Set("sessionId", tc.sessionID)
+			}
+			w := getHistory(s, params)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+			}
+			var msgs []api.HistoryMessage
+			if err := json.NewDecoder(w.Body).Decode(&msgs); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			var got []string
+			for _, m := range msgs {
+				got = append(got, m.Content)
+			}
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("session of another workspace", func(t *testing.T) {
+		t.Parallel()
+		w := getHistory(s, url.Values{"workspaceDir": {"/ws/b"}, "sessionId": {one}})
+		if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), errCodeSessionNotFound) {
+			t.Errorf("expected 404 %s, got %d — body: %s", errCodeSessionNotFound, w.Code, w.Body.String())
+		}
+	})
+}
+
+// sessionQuerier records the session of every query it answers.
+type sessionQuerier struct {
+	mu       sync.Mutex
+	sessions []string
+}
+
+func (q *sessionQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	q.mu.Lock()
+	q.sessions = append(q.sessions, req.SessionID)
+	q.mu.Unlock()
+	_, _ = fmt.Fprint(req.Output, "ok")
+	return &agent.QueryResult{}, nil
+}
+
+func TestHandleChat_Session(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	hs, err := store.Open(t.Context(), ":memory:")
+	if err != nil {
+		t.Fatalf("open in-memory store: %v", err)
+	}
+	t.Cleanup(func() { _ = hs.Close() })
+	sess, err := hs.CreateSession(t.Context(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantSession string
+	}{
+		{name: "session", body: fmt.Sprintf(`{"message":"hi","workspaceDir":%q,"sessionId":%q,"stream":false}`, dir, sess.ID), wantStatus: http.StatusOK, wantSession: sess.ID},
+		{name: "default thread", body: fmt.Sprintf(`{"message":"hi","workspaceDir":%q,"stream":false}`, dir), wantStatus: http.StatusOK},
+		{name: "unknown session", body: fmt.Sprintf(`{"message":"hi","workspaceDir":%q,"sessionId":"nope","stream":false}`, dir), wantStatus: http.StatusNotFound},
+		{name: "session without workspace", body: fmt.Sprintf(`{"message":"hi","sessionId":%q,"stream":false}`, sess.ID), wantStatus: http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			q := &sessionQuerier{}
+			s := newChatTestServer(q)
+			s.cfg.History = hs
+
+			req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.handleChat(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("expected %d, got %d — body: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.wantStatus != http.StatusOK {
+				if len(q.sessions) != 0 {
+					t.Errorf("expected the query to be refused, got %v", q.sessions)
+				}
+				return
+			}
+			if len(q.sessions) != 1 || q.sessions[0] != tc.wantSession {
+				t.Errorf("expected session %q passed to the agent, got %q", tc.wantSession, q.sessions)
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// sseEvent is one named Server-Sent Event of a POST /api/chat stream.
+type sseEvent struct {
+	// Type is the event name, one of the api.Event* constants.
+	Type string
+	// Data is the event payload. It is JSON-encoded into the frame, so a
+	// string payload arrives as a JSON string.
+	Data any
+}
+
+// sseWriter wraps an http.ResponseWriter to emit Server-Sent Event frames:
+// named events through WriteEvent and streamed response text through Write.
+type sseWriter struct {
+	// mu serialises frames so progress events reported from other goroutines
+	// and heartbeat comments never interleave with response text.
+	mu sync.Mutex
+
+	// heartbeatDone is set, under mu, once response text or the final
+	// event has been written; heartbeats stop from then on.
+	heartbeatDone bool
+
+	// w is the underlying response writer.
+	w http.ResponseWriter
+
+	// flusher flushes buffered data to the client after each write.
+	flusher http.Flusher
+
+	// bytes counts the bytes written, partitioned by event type. Nil
+	// disables the count.
+	bytes *prometheus.CounterVec
+
+	// disclosure is sent as an api.EventDisclosure event by WriteDone.
+	// Empty sends none.
+	disclosure string
+}
+
+// newSSEWriter returns an sseWriter for w that records its bytes in the
+// server's stream metrics and ends streams with the configured disclosure.
+func (s *Server) newSSEWriter(w http.ResponseWriter, flusher http.Flusher) *sseWriter {
+	return &sseWriter{w: w, flusher: flusher, bytes: s.metrics.chatStreamBytesTotal, disclosure: s.cfg.DisclosureText}
+}
+
+// WriteDone ends a successful stream: the disclosure event, when one is
+// configured, then api.EventDone. Every stream ends here so none can omit
+// the label.
+func (s *sseWriter) WriteDone() error {
+	if s.disclosure != "" {
+		if err := s.WriteEvent(sseEvent{Type: api.EventDisclosure, Data: s.disclosure}); err != nil {
+			return err
+		}
+	}
+	return s.WriteEvent(sseEvent{Type: api.EventDone, Data: "[DONE]"})
+}
+
+// WriteEvent JSON-encodes ev.Data and writes it as a named SSE frame. Callers
+// may ignore a write error: the client has gone and the query context ends
+// with it.
+func (s *sseWriter) WriteEvent(ev sseEvent) error {
+	data, err := json.Marshal(ev.Data)
+	if err != nil {
+		return fmt.Errorf("server: failed to encode %s event: %w", ev.Type, err)
+	}
+	return s.writeFrame(ev.Type, string(data))
+}
+
+// Write formats p as one or more SSE data lines of an unnamed frame and
+// flushes to the client. Response text is not JSON-encoded.
+func (s *sseWriter) Write(p []byte) (n int, err error) {
+	if err := s.writeFrame("", strings.TrimRight(string(p), "\r\n")); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFrame writes one SSE frame and flushes. Every line of data gets its
+// own "data: " prefix, splitting on all three SSE line endings, so no
+// payload can end the frame early or inject a field of its own.
+func (s *sseWriter) writeFrame(name, data string) error {
+	var buf strings.Builder
+	if name != "" {
+		buf.WriteString("event: ")
+		buf.WriteString(name)
+		buf.WriteString("\n")
+	}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: ")
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	buf.WriteString("\n")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name == "" || name == api.EventDone || name == api.EventError {
+		s.heartbeatDone = true
+	}
+	n, err := fmt.Fprint(s.w, buf.String())
+	if s.bytes != nil {
+		label := name
+		if label == "" {
+			label = eventMessage
+		}
+		s.bytes.WithLabelValues(label).Add(float64(n))
+	}
+	if err != nil {
+		return err //nolint:wrapcheck // SSE writer error
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// eventMessage is the metrics label of unnamed frames carrying response
+// text, matching the SSE default event type.
+const eventMessage = "message"
+
+// DefaultSSEHeartbeat is the default Config.SSEHeartbeat, well inside the
+// 60 second idle timeout common to proxies.
+const DefaultSSEHeartbeat = 15 * time.Second
+
+// keepaliveComment is the SSE comment frame written by heartbeats. Clients
+// ignore comments, so it only keeps proxies from closing an idle stream.
+const keepaliveComment = ": keepalive\n\n"
+
+// eventKeepalive is the metrics label of heartbeat comments.
+const eventKeepalive = "keepalive"
+
+// startHeartbeat writes a keepalive comment every interval while the stream
+// waits for its first response text, which can take minutes when a tool
+// such as terraform plan runs first. It stops once response text or the
+// final done or error event is written, or when stop is called; stop waits
+// for the goroutine to exit, so nothing is written after the handler
+// returns. A non-positive interval disables heartbeats.
+func (s *sseWriter) startHeartbeat(interval time.Duration) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if !s.writeKeepalive() {
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
+
+// writeKeepalive writes one keepalive comment and flushes. It reports
+// whether heartbeats should continue: false once they are done or the
+// client has gone.
+func (s *sseWriter) writeKeepalive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.heartbeatDone {
+		return false
+	}
+	n, err := fmt.Fprint(s.w, keepaliveComment)
+	if s.bytes != nil {
+		s.bytes.WithLabelValues(eventKeepalive).Add(float64(n))
+	}
+	if err != nil {
+		return false
+	}
+	s.flusher.Flush()
+	return true
+}
+
+// streamEvents forwards query progress, tool activity, and notices to the
+// client as named SSE events.
+type streamEvents struct {
+	agent.NopEventSink
+
+	// sw is the stream the events are written to.
+	sw *sseWriter
+}
+
+// OnPhase implements agent.EventSink.
+func (e streamEvents) OnPhase(p agent.Phase) {
+	_ = e.sw.WriteEvent(sseEvent{Type: api.EventPhase, Data: string(p)})
+}
+
+// OnNotice implements agent.EventSink.
+func (e streamEvents) OnNotice(notice string) {
+	_ = e.sw.WriteEvent(sseEvent{Type: api.EventNotice, Data: notice})
+}
+
+// OnToolStart implements agent.EventSink.
+func (e streamEvents) OnToolStart(ev agent.ToolEvent) {
+	e.toolEvent(api.EventToolStart, ev)
+}
+
+// OnToolEnd implements agent.EventSink.
+func (e streamEvents) OnToolEnd(ev agent.ToolEvent) {
+	e.toolEvent(api.EventToolEnd, ev)
+}
+
+// toolEvent writes ev as a named event with an api.ToolEvent payload.
+func (e streamEvents) toolEvent(name string, ev agent.ToolEvent) {
+	_ = e.sw.WriteEvent(sseEvent{Type: name, Data: api.ToolEvent{
+		Tool:      ev.Name,
+		CallID:    ev.CallID,
+		Dir:       ev.Dir,
+		ElapsedMs: ev.Elapsed.Milliseconds(),
+		Error:     ev.Error,
+	}})
+}
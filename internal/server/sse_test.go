@@ -0,0 +1,287 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// newTestSSEWriter returns an sseWriter over a recorder, counting bytes in
+// the metrics of a fresh chat test server.
+func newTestSSEWriter() (*sseWriter, *httptest.ResponseRecorder, *Server) {
+	s := newChatTestServer(&fakeQuerier{})
+	w := httptest.NewRecorder()
+	return s.newSSEWriter(w, w), w, s
+}
+
+// ---------------------------------------------------------------------------
+// Frame formatting
+// ---------------------------------------------------------------------------
+
+func TestSSEWriter_Frames(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		write func(sw *sseWriter) error
+		want  string
+	}{
+		{
+			name:  "string payload",
+			write: func(sw *sseWriter) error { return sw.WriteEvent(sseEvent{Type: api.EventPhase, Data: "calling_model"}) },
+			want:  "event: phase\ndata: \"calling_model\"\n\n",
+		},
+		{
+			name:  "bool payload",
+			write: func(sw *sseWriter) error { return sw.WriteEvent(sseEvent{Type: api.EventFilesWritten, Data: true}) },
+			want:  "event: files_written\ndata: true\n\n",
+		},
+		{
+			name: "multi-line JSON payload",
+			write: func(sw *sseWriter) error {
+				return sw.WriteEvent(sseEvent{Type: api.EventToolEnd, Data: json.RawMessage("{\n  \"tool\": \"terraform_plan\"\n}")})
+			},
+			want: "event: tool_end\ndata: {\"tool\":\"terraform_plan\"}\n\n",
+		},
+		{
+			name: "struct with multi-line field",
+			write: func(sw *sseWriter) error {
+				return sw.WriteEvent(sseEvent{Type: api.EventToolEnd, Data: api.ToolEvent{Tool: "terraform_plan", Error: "exit status 1\nError: bad"}})
+			},
+			want: "event: tool_end\ndata: {\"tool\":\"terraform_plan\",\"callId\":\"\",\"elapsedMs\":0,\"error\":\"exit status 1\\nError: bad\"}\n\n",
+		},
+		{
+			name: "response text",
+			write: func(sw *sseWriter) error {
+				_, err := sw.Write([]byte("line one\r\nline two\rline three\n"))
+				return err
+			},
+			want: "data: line one\ndata: line two\ndata: line three\n\n",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			sw, w, _ := newTestSSEWriter()
+			if err := tc.write(sw); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if got := w.Body.String(); got != tc.want {
+				t.Errorf("want frame %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSSEWriter_UnencodablePayload(t *testing.T) {
+	t.Parallel()
+
+	sw, w, _ := newTestSSEWriter()
+	if err := sw.WriteEvent(sseEvent{Type: api.EventNotice, Data: func() {}}); err == nil {
+		t.Error("expected an encoding error")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected nothing written, got %q", w.Body.String())
+	}
+}
+
+// TestSSEWriter_EscapesErrorMessages writes an error whose text tries to end
+// the frame and forge a done event, as a provider error echoing user input
+// could.
+func TestSSEWriter_EscapesErrorMessages(t *testing.T) {
+	t.Parallel()
+
+	msg := "model said:\n\nevent: done\ndata: [DONE]\r\n"
+	sw, w, _ := newTestSSEWriter()
+	if err := sw.WriteEvent(sseEvent{Type: api.EventError, Data: msg}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	events := sseEvents(w.Body.String())
+	if len(events) != 1 || !strings.HasPrefix(events[0], api.EventError+":") {
+		t.Fatalf("expected a single error event, got %q", events)
+	}
+	var got string
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(events[0], api.EventError+":")), &got); err != nil {
+		t.Fatalf("error data: %v", err)
+	}
+	if got != msg {
+		t.Errorf("want message %q, got %q", msg, got)
+	}
+}
+
+func TestSSEWriter_CountsBytes(t *testing.T) {
+	t.Parallel()
+
+	sw, w, s := newTestSSEWriter()
+	_, _ = sw.Write([]byte("answer"))
+	_ = sw.WriteEvent(sseEvent{Type: api.EventDone, Data: "[DONE]"})
+
+	text := float64(len("data: answer\n\n"))
+	done := float64(w.Body.Len()) - text
+	if got := testutil.ToFloat64(s.metrics.chatStreamBytesTotal.WithLabelValues(eventMessage)); got != text {
+		t.Errorf("want %v message bytes, got %v", text, got)
+	}
+	if got := testutil.ToFloat64(s.metrics.chatStreamBytesTotal.WithLabelValues(api.EventDone)); got != done {
+		t.Errorf("want %v done bytes, got %v", done, got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Protocol version
+// ---------------------------------------------------------------------------
+
+func TestHandleChat_AnnouncesProtocolVersion(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(&fakeQuerier{response: "ok"})
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi"}`))
+	w := httptest.NewRecorder()
+
+	s.handleChat(w, req)
+
+	events := sseEvents(w.Body.String())
+	if len(events) == 0 || !strings.HasPrefix(events[0], api.EventAccepted+":") {
+		t.Fatalf("expected the accepted event first, got %q", events)
+	}
+	var accepted map[string]any
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(events[0], api.EventAccepted+":")), &accepted); err != nil {
+		t.Fatalf("accepted data: %v", err)
+	}
+	if v, ok := accepted["protocol"].(float64); !ok || int(v) != api.ProtocolVersion {
+		t.Errorf("want protocol %d in the accepted event, got %v", api.ProtocolVersion, accepted["protocol"])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Heartbeats
+// ---------------------------------------------------------------------------
+
+// busyQuerier reports notices from several goroutines for delay, as tools
+// running before the first token would, then writes its response.
+type busyQuerier struct {
+	delay    time.Duration
+	response string
+}
+
+func (q busyQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(q.delay)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				req.Events.OnNotice("still planning\nline two")
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+	_, _ = fmt.Fprint(req.Output, q.response)
+	return &agent.QueryResult{}, nil
+}
+
+// checkFrames fails t unless every frame of body is a keepalive comment or
+// consists only of event and data lines, i.e. no write interleaved with
+// another.
+func checkFrames(t *testing.T, body string) {
+	t.Helper()
+	if !strings.HasSuffix(body, "\n\n") {
+		t.Errorf("stream does not end at a frame boundary: %q", body)
+	}
+	for _, frame := range strings.Split(strings.TrimSuffix(body, "\n\n"), "\n\n") {
+		if frame+"\n\n" == keepaliveComment {
+			continue
+		}
+		for _, line := range strings.Split(frame, "\n") {
+			if !strings.HasPrefix(line, "event: ") && !strings.HasPrefix(line, "data: ") {
+				t.Errorf("corrupt frame %q", frame)
+				break
+			}
+		}
+	}
+}
+
+func TestHandleChat_Heartbeat(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(busyQuerier{delay: 50 * time.Millisecond, response: "answer"})
+	s.cfg.SSEHeartbeat = time.Millisecond
+	req := httptest.NewRequest(http.MethodPost, "/api/chat", strings.NewReader(`{"message":"hi"}`))
+	w := httptest.NewRecorder()
+
+	s.handleChat(w, req)
+
+	body := w.Body.String()
+	checkFrames(t, body)
+	text := strings.Index(body, "data: answer\n\n")
+	if text < 0 {
+		t.Fatalf("expected the response text, got %q", body)
+	}
+	if !strings.Contains(body[:text], keepaliveComment) {
+		t.Errorf("expected keepalive comments before the response text, got %q", body[:text])
+	}
+	if strings.Contains(body[text:], keepaliveComment) {
+		t.Errorf("expected no keepalive after the response text, got %q", body[text:])
+	}
+	if got := testutil.ToFloat64(s.metrics.chatStreamBytesTotal.WithLabelValues(eventKeepalive)); got == 0 {
+		t.Error("expected keepalive bytes to be counted")
+	}
+}
+
+func TestSSEWriter_HeartbeatStops(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		// end writes the final event, which stops heartbeats.
+		end func(sw *sseWriter)
+	}{
+		{name: "done", interval: time.Millisecond, end: func(sw *sseWriter) { _ = sw.WriteDone() }},
+		{name: "error", interval: time.Millisecond, end: func(sw *sseWriter) {
+			_ = sw.WriteEvent(sseEvent{Type: api.EventError, Data: "boom"})
+		}},
+		{name: "disabled", interval: 0, end: func(sw *sseWriter) { _ = sw.WriteDone() }},
+		{name: "negative", interval: -time.Second, end: func(sw *sseWriter) { _ = sw.WriteDone() }},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			sw, w, _ := newTestSSEWriter()
+			stop := sw.startHeartbeat(tc.interval)
+			time.Sleep(10 * time.Millisecond)
+			tc.end(sw)
+			n := w.Body.Len()
+			time.Sleep(10 * time.Millisecond)
+			stop()
+			stop()
+
+			body := w.Body.String()
+			checkFrames(t, body)
+			if tc.interval <= 0 && strings.Contains(body, keepaliveComment) {
+				t.Errorf("expected no keepalive, got %q", body)
+			}
+			if tc.interval > 0 && !strings.HasPrefix(body, keepaliveComment) {
+				t.Errorf("expected keepalive before the final event, got %q", body)
+			}
+			if w.Body.Len() != n {
+				t.Errorf("expected nothing written after the final event, got %q", body[n:])
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package server
 
 import (
 	"context"
-	"io"
 	"log/slog"
 	"net/http"
 	"time"
@@ -10,6 +9,12 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/secretscan"
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/internal/supervise"
+	"github.com/54b3r/tfai-go/internal/usage"
+	"github.com/54b3r/tfai-go/internal/wscache"
+	"github.com/54b3r/tfai-go/pkg/api"
 )
 
 // Config holds the HTTP server configuration.
@@ -20,7 +25,9 @@ type Config struct {
 	Port int
 	// ReadTimeout is the maximum duration for reading the request.
 	ReadTimeout time.Duration
-	// WriteTimeout is the maximum duration for writing the response.
+	// WriteTimeout is the maximum duration for writing the response,
+	// including a whole chat stream. It must be longer than ChatTimeout.
+	// Defaults to ChatTimeout plus 30 seconds if zero.
 	WriteTimeout time.Duration
 	// ShutdownTimeout is the maximum duration for a graceful shutdown.
 	ShutdownTimeout time.Duration
@@ -30,6 +37,14 @@ type Config struct {
 	// Pingers is the ordered list of dependency probes run by GET /api/ready.
 	// If empty, /api/ready returns 200 with no checks (liveness-only mode).
 	Pingers []Pinger
+	// ReadyFailureThreshold is how many probes of a dependency in a row
+	// must fail before /api/ready reports it unready. Defaults to
+	// DefaultReadyFailureThreshold if zero.
+	ReadyFailureThreshold int
+	// ReadyRecoveryThreshold is how many probes in a row must succeed
+	// before an unready dependency is reported ready again. Defaults to
+	// DefaultReadyRecoveryThreshold if zero.
+	ReadyRecoveryThreshold int
 	// RateLimit is the sustained request rate allowed per IP on rate-limited
 	// endpoints (requests/second). Defaults to 10 if zero.
 	RateLimit float64
@@ -38,12 +53,23 @@ type Config struct {
 	// APIKey is the Bearer token required on all protected /api/* routes.
 	// If empty, authentication is disabled (development mode).
 	APIKey string
+	// AdminAPIKey is the Bearer token required on admin routes, such as GET
+	// /api/security-report, instead of APIKey. If empty, admin routes
+	// accept APIKey.
+	AdminAPIKey string
+	// MetricsAuth puts GET /metrics behind APIKey and the rate limiter.
+	// By default it is public, for scrapers outside the auth boundary.
+	MetricsAuth bool
 	// WorkspaceRoot is the root directory for workspace operations.
 	// If empty, the server will use the current working directory.
 	WorkspaceRoot string
 	// ChatTimeout is the maximum duration for a single /api/chat request,
 	// including LLM streaming. Defaults to 5 minutes if zero.
 	ChatTimeout time.Duration
+	// ProviderTimeout is the model client's timeout for a single request,
+	// when it has one (see provider.Config.HTTPTimeout). It is reported on
+	// GET /api/status, and New warns when it exceeds ChatTimeout.
+	ProviderTimeout time.Duration
 	// MetricsRegistry is the Prometheus registry used to register server
 	// metrics. If nil, prometheus.DefaultRegisterer / DefaultGatherer are used.
 	// Inject a fresh prometheus.NewRegistry() in tests to keep them hermetic.
@@ -51,14 +77,71 @@ type Config struct {
 	// MetricsGatherer is the Prometheus gatherer paired with MetricsRegistry.
 	// If nil, prometheus.DefaultGatherer is used.
 	MetricsGatherer prometheus.Gatherer
+	// History is the conversation store read by GET /api/history. If nil,
+	// the endpoint returns 503.
+	History store.ConversationStore
+	// Activity is the workspace activity read by GET /api/workspace/activity.
+	// If nil, the endpoint returns 503.
+	Activity store.ActivityReader
+	// Usage is the source of stored token usage for GET /api/usage/report.
+	// If nil, the endpoint returns 503.
+	Usage store.UsageReader
+	// Prices is the price table used to estimate cost in usage reports.
+	Prices usage.Prices
+	// SecretScanner checks content saved through PUT /api/file for
+	// credentials. If nil, secretscan.Default() is used.
+	SecretScanner *secretscan.Scanner
+	// BlockSecretsOnSave rejects PUT /api/file with 422 when the content
+	// contains credentials. When false, the file is saved and the findings
+	// are reported in the X-Secrets-Detected response header.
+	BlockSecretsOnSave bool
+	// Tools reports the availability of each agent tool on GET /api/status.
+	Tools []api.ToolStatus
+	// ToolCatalog describes every agent tool on GET /api/tools.
+	ToolCatalog []api.ToolDescription
+	// DisclosureText labels every chat answer as AI-generated. Streams end
+	// with an api.EventDisclosure event carrying it, and JSON responses set
+	// api.ChatResponse.Disclosure. Empty disables the label.
+	DisclosureText string
+	// Tracing is true when chats are traced to Langfuse (see tracing.Setup).
+	// Each chat's trace is then keyed by its request ID, which streams
+	// announce in an api.EventMeta event and JSON responses return as
+	// api.ChatResponse.TraceID.
+	Tracing bool
+	// MaxTokensLimit is the largest maxTokens a chat request may ask for;
+	// larger values are rejected with 400. Defaults to
+	// DefaultMaxTokensLimit if zero.
+	MaxTokensLimit int
+	// WorkspaceCache is invalidated for a workspace whenever PUT /api/file,
+	// DELETE /api/file, or POST /api/workspace/create changes its files. Pass
+	// the group given to agent.Config.WorkspaceCache so the agent never
+	// serves stale workspace context. Nil disables invalidation.
+	WorkspaceCache *wscache.Group
+	// BasePath is the path prefix the server is reachable under behind a
+	// reverse proxy, e.g. "/tfai" for https://tools.corp/tfai/. Every route
+	// and the UI are mounted under it, and it is reported on GET
+	// /api/version. Empty mounts everything at the root.
+	BasePath string
+	// DisableRootProbes stops GET /api/health and GET /api/ready from also
+	// being mounted at their unprefixed paths when BasePath is set. Load
+	// balancers usually probe the backend directly, without the prefix.
+	DisableRootProbes bool
+	// SSEHeartbeat is how often a chat stream writes a keepalive comment
+	// while it waits for the first response text, so proxies and browsers
+	// that drop idle connections keep it open. Defaults to
+	// DefaultSSEHeartbeat if zero; negative disables heartbeats.
+	SSEHeartbeat time.Duration
+	// UIDir is the directory of the static UI, relative to the working
+	// directory. Defaults to ui/static if empty.
+	UIDir string
 }
 
-// querier is the interface handleChat calls to stream a response.
+// querier is the interface handleChat calls to run a query.
 // *agent.TerraformAgent satisfies it; tests inject a fake.
 type querier interface {
-	// Query streams the agent response for userMessage to w.
-	// Returns true if files were written to workspaceDir.
-	Query(ctx context.Context, userMessage, workspaceDir string, w io.Writer) (bool, error)
+	// Run streams the answer to req.Output and reports what the query did.
+	// The result is non-nil even when err is not.
+	Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error)
 }
 
 // Server is the HTTP server that wraps the TerraformAgent.
@@ -68,6 +151,12 @@ type Server struct {
 	// querier is the interface used by handleChat; set to agent in production,
 	// overridden by a fake in tests.
 	querier querier
+	// applier writes previewed file envelopes for POST /api/files/apply;
+	// set to agent in production, overridden by a fake in tests.
+	applier envelopeApplier
+	// previews holds the file envelopes previewed by chat requests until
+	// they are applied or expire.
+	previews *previewCache
 	// cfg holds the resolved server configuration.
 	cfg *Config
 	// httpServer is the underlying net/http server.
@@ -76,70 +165,13 @@ type Server struct {
 	log *slog.Logger
 	// pingers is the ordered list of dependency probes for GET /api/ready.
 	pingers []Pinger
-	// stopRL stops the rate limiter's background eviction goroutine on shutdown.
-	stopRL func()
+	// readiness debounces the probe results of pingers.
+	readiness *readiness
+	// loops supervises the server's background goroutines.
+	loops *supervise.Supervisor
+	// stopLoops stops the background goroutines and waits for them to exit.
+	stopLoops func()
 	// metrics holds all Prometheus counters, histograms, and gauges for this
 	// server instance.
 	metrics *serverMetrics
 }
-
-// chatRequest is the JSON body for POST /api/chat.
-type chatRequest struct {
-	// Message is the user's natural language query.
-	Message string `json:"message"`
-	// WorkspaceDir is the directory to work in.
-	WorkspaceDir string `json:"workspaceDir"`
-}
-
-// workspaceResponse is the JSON response for GET /api/workspace.
-type workspaceResponse struct {
-	// Dir is the cleaned absolute path that was inspected.
-	Dir string `json:"dir"`
-	// Files is the recursive list of .tf and .tfvars files found under Dir,
-	// returned as paths relative to Dir (e.g. "modules/vpc/main.tf").
-	Files []string `json:"files"`
-	// Dirs is kept for backward compatibility but is now always empty.
-	Dirs []string `json:"dirs"`
-	// Initialized indicates a .terraform directory is present.
-	Initialized bool `json:"initialized"`
-	// HasState indicates a terraform.tfstate file is present.
-	HasState bool `json:"hasState"`
-	// HasLockfile indicates .terraform.lock.hcl is present.
-	HasLockfile bool `json:"hasLockfile"`
-}
-
-// createWorkspaceRequest is the JSON body for POST /api/workspace/create.
-type createWorkspaceRequest struct {
-	// Dir is the absolute path of the directory to create.
-	Dir string `json:"dir"`
-	// Description is an optional hint for the LLM to pre-fill the chat.
-	Description string `json:"description,omitempty"`
-}
-
-// createWorkspaceResponse is the JSON response for POST /api/workspace/create.
-type createWorkspaceResponse struct {
-	// Dir is the absolute path that was created.
-	Dir string `json:"dir"`
-	// Files is the list of scaffold files written.
-	Files []string `json:"files"`
-	// Prompt is a pre-filled chat prompt if Description was provided.
-	Prompt string `json:"prompt,omitempty"`
-}
-
-// fileResponse is the JSON response for GET /api/file.
-type fileResponse struct {
-	// Path is the absolute path of the file that was read.
-	Path string `json:"path"`
-	// Content is the raw file content.
-	Content string `json:"content"`
-}
-
-// fileSaveRequest is the JSON body for PUT /api/file.
-type fileSaveRequest struct {
-	// WorkspaceDir is the declared workspace root. The path must resolve within it.
-	WorkspaceDir string `json:"workspaceDir"`
-	// Path is the absolute path of the file to write.
-	Path string `json:"path"`
-	// Content is the new file content to write.
-	Content string `json:"content"`
-}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// writeTimeoutMargin is how much longer than ChatTimeout the default
+// WriteTimeout is, so a chat that runs to its deadline still has time to
+// send its error event before net/http closes the connection.
+const writeTimeoutMargin = 30 * time.Second
+
+// checkTimeouts validates the order of the timeouts a chat request runs
+// under:
+//
+//	WriteTimeout > ChatTimeout > probeTimeout
+//
+// net/http closes a response once WriteTimeout has passed without telling
+// the handler or the client, so a stream outliving it ends mid-answer with
+// no error anywhere. An ordering violation is returned as an error; a
+// provider timeout that can never take effect is returned as a warning.
+func checkTimeouts(cfg *Config) (warnings []string, err error) {
+	if cfg.WriteTimeout <= cfg.ChatTimeout {
+		return nil, fmt.Errorf("server: WriteTimeout (%s) must be longer than ChatTimeout (%s): chat streams will be cut off after %s with no error",
+			cfg.WriteTimeout, cfg.ChatTimeout, cfg.WriteTimeout)
+	}
+	if cfg.ChatTimeout <= probeTimeout {
+		return nil, fmt.Errorf("server: ChatTimeout (%s) must be longer than the readiness probe timeout (%s): a chat would get less time than a single model ping",
+			cfg.ChatTimeout, probeTimeout)
+	}
+	if cfg.ProviderTimeout > cfg.ChatTimeout {
+		warnings = append(warnings, fmt.Sprintf("model requests time out after %s but chats are cut off after %s; the provider timeout never takes effect",
+			cfg.ProviderTimeout, cfg.ChatTimeout))
+	}
+	return warnings, nil
+}
+
+// timeoutChain reports the effective timeouts for GET /api/status.
+func timeoutChain(cfg *Config) api.TimeoutChain {
+	return api.TimeoutChain{
+		WriteMs:    cfg.WriteTimeout.Milliseconds(),
+		ChatMs:     cfg.ChatTimeout.Milliseconds(),
+		ProbeMs:    probeTimeout.Milliseconds(),
+		ProviderMs: cfg.ProviderTimeout.Milliseconds(),
+	}
+}
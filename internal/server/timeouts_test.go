@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/logging"
+)
+
+// ---------------------------------------------------------------------------
+// Timeout chain validation
+// ---------------------------------------------------------------------------
+
+func TestCheckTimeouts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantErr     string
+		wantWarning string
+	}{
+		{name: "ordered", cfg: Config{WriteTimeout: 330 * time.Second, ChatTimeout: 300 * time.Second}},
+		{
+			name:    "write shorter than chat",
+			cfg:     Config{WriteTimeout: 30 * time.Second, ChatTimeout: 300 * time.Second},
+			wantErr: "chat streams will be cut off after 30s",
+		},
+		{
+			name:    "write equal to chat",
+			cfg:     Config{WriteTimeout: 300 * time.Second, ChatTimeout: 300 * time.Second},
+			wantErr: "chat streams will be cut off after 5m0s",
+		},
+		{
+			name:    "chat shorter than probe",
+			cfg:     Config{WriteTimeout: 10 * time.Second, ChatTimeout: 2 * time.Second},
+			wantErr: "must be longer than the readiness probe timeout",
+		},
+		{
+			name:    "chat equal to probe",
+			cfg:     Config{WriteTimeout: 10 * time.Second, ChatTimeout: probeTimeout},
+			wantErr: "must be longer than the readiness probe timeout",
+		},
+		{
+			name: "provider within chat",
+			cfg:  Config{WriteTimeout: 330 * time.Second, ChatTimeout: 300 * time.Second, ProviderTimeout: 300 * time.Second},
+		},
+		{
+			name:        "provider longer than chat",
+			cfg:         Config{WriteTimeout: 90 * time.Second, ChatTimeout: 60 * time.Second, ProviderTimeout: 300 * time.Second},
+			wantWarning: "the provider timeout never takes effect",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			warnings, err := checkTimeouts(&tc.cfg)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+			got := strings.Join(warnings, "\n")
+			if tc.wantWarning == "" && got != "" {
+				t.Errorf("unexpected warnings: %s", got)
+			}
+			if !strings.Contains(got, tc.wantWarning) {
+				t.Errorf("expected a warning containing %q, got %q", tc.wantWarning, got)
+			}
+		})
+	}
+}
+
+func TestNew_Timeouts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		wantErr   bool
+		wantWrite time.Duration
+	}{
+		{name: "defaults", wantWrite: 5*time.Minute + writeTimeoutMargin},
+		{name: "write derived from chat", cfg: Config{ChatTimeout: 10 * time.Minute}, wantWrite: 10*time.Minute + writeTimeoutMargin},
+		{name: "copy-pasted write timeout", cfg: Config{WriteTimeout: 30 * time.Second}, wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := tc.cfg
+			cfg.Logger = logging.New()
+			cfg.MetricsRegistry = prometheus.NewRegistry()
+			s, err := New(&agent.TerraformAgent{}, &cfg)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected New to refuse the timeouts")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			t.Cleanup(s.stopLoops)
+			if s.httpServer.WriteTimeout != tc.wantWrite {
+				t.Errorf("expected WriteTimeout %s, got %s", tc.wantWrite, s.httpServer.WriteTimeout)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Truncated stream regression
+// ---------------------------------------------------------------------------
+
+// slowQuerier streams its answer in two parts with a pause between them,
+// like a model that takes a while to finish.
+type slowQuerier struct {
+	pause time.Duration
+}
+
+func (q slowQuerier) Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	_, _ = fmt.Fprint(req.Output, "first half")
+	select {
+	case <-time.After(q.pause):
+	case <-ctx.Done():
+		return &agent.QueryResult{ErrorCode: agent.CodeModel}, ctx.Err()
+	}
+	_, _ = fmt.Fprint(req.Output, "second half")
+	return &agent.QueryResult{}, nil
+}
+
+// streamChat serves handleChat with the given write timeout and returns the
+// stream as the client saw it.
+func streamChat(t *testing.T, s *Server, writeTimeout time.Duration) string {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(s.handleChat))
+	ts.Config.WriteTimeout = writeTimeout
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	resp, err := http.Post(ts.URL, "application/json", strings.NewReader(`{"message":"hi"}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// A truncated stream ends with an error; what arrived is the point.
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+// TestChatStream_WriteTimeoutTruncates reproduces the misconfiguration
+// checkTimeouts rejects: with WriteTimeout shorter than the time the answer
+// takes, net/http cuts the stream off and neither side sees an error event.
+func TestChatStream_WriteTimeoutTruncates(t *testing.T) {
+	t.Parallel()
+
+	const pause = 300 * time.Millisecond
+	s := newChatTestServer(slowQuerier{pause: pause})
+	s.cfg.ChatTimeout = 2 * time.Second
+
+	t.Run("misordered", func(t *testing.T) {
+		t.Parallel()
+		cfg := Config{WriteTimeout: 50 * time.Millisecond, ChatTimeout: s.cfg.ChatTimeout}
+		if _, err := checkTimeouts(&cfg); err == nil {
+			t.Error("expected checkTimeouts to reject this configuration")
+		}
+		got := streamChat(t, s, cfg.WriteTimeout)
+		if strings.Contains(got, "second half") || strings.Contains(got, "event: done") {
+			t.Errorf("expected the stream to be cut off:\n%s", got)
+		}
+		if strings.Contains(got, "event: error") {
+			t.Errorf("a truncated stream carries no error event, got:\n%s", got)
+		}
+	})
+
+	t.Run("ordered", func(t *testing.T) {
+		t.Parallel()
+		got := streamChat(t, s, s.cfg.ChatTimeout+time.Second)
+		if !strings.Contains(got, "second half") || !strings.Contains(got, "event: done") {
+			t.Errorf("expected the complete stream:\n%s", got)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// Caps on the tree GET /api/workspace/tree returns, so a monorepo cannot
+// produce an unbounded response.
+const (
+	// maxTreeEntries is the most nodes a tree holds.
+	maxTreeEntries = 1000
+	// maxTreeDepth is the deepest directory level walked; the workspace's
+	// own files are level 1.
+	maxTreeDepth = 12
+)
+
+// treeFile reports whether a file named name belongs in the workspace tree.
+func treeFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".tf", ".tfvars", ".hcl":
+		return true
+	}
+	return name == "README.md"
+}
+
+// treeWalker builds a workspace tree, counting entries against
+// maxTreeEntries.
+type treeWalker struct {
+	// entries is the number of nodes added so far.
+	entries int
+	// truncated is set once a cap skipped anything.
+	truncated bool
+}
+
+// walk fills node's children from the directory abs, whose nodes sit at
+// depth, and rolls their sizes and modification times up into node.
+// Hidden directories are skipped, like GET /api/workspace does, and
+// directories left without children are dropped.
+func (tw *treeWalker) walk(node *api.TreeNode, abs string, depth int) {
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return // skip unreadable directories
+	}
+	// os.ReadDir sorts by name; list directories before files.
+	var files []api.TreeNode
+	for _, e := range entries {
+		name := e.Name()
+		rel := path.Join(node.RelPath, name)
+		switch {
+		case e.IsDir():
+			if strings.HasPrefix(name, ".") {
+				continue
+			}
+			if depth >= maxTreeDepth || tw.entries >= maxTreeEntries {
+				tw.truncated = true
+				continue
+			}
+			tw.entries++
+			child := api.TreeNode{Name: name, RelPath: rel, Dir: true}
+			tw.walk(&child, filepath.Join(abs, name), depth+1)
+			if len(child.Children) == 0 {
+				tw.entries--
+				continue
+			}
+			node.Children = append(node.Children, child)
+			rollUp(node, child)
+		case e.Type().IsRegular() && treeFile(name):
+			if tw.entries >= maxTreeEntries {
+				tw.truncated = true
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			tw.entries++
+			child := api.TreeNode{Name: name, RelPath: rel, SizeBytes: info.Size(), ModTime: api.NewTimestamp(info.ModTime())}
+			files = append(files, child)
+			rollUp(node, child)
+		}
+	}
+	node.Children = append(node.Children, files...)
+}
+
+// rollUp adds child's size to its parent directory and carries its
+// modification time up when it is the newest.
+func rollUp(parent *api.TreeNode, child api.TreeNode) {
+	parent.SizeBytes += child.SizeBytes
+	if child.ModTime.After(parent.ModTime.Time) {
+		parent.ModTime = child.ModTime
+	}
+}
+
+// handleWorkspaceTree handles GET /api/workspace/tree?workspaceDir=<abs>.
+// It returns the workspace's .tf, .tfvars, .hcl, and README.md files as a
+// nested tree of directories, each node carrying its size and modification
+// time, so the UI can sort by them. The tree holds at most maxTreeEntries
+// nodes and maxTreeDepth levels; truncated reports when a cap was hit.
+// GET /api/workspace keeps returning the flat file list.
+func (s *Server) handleWorkspaceTree(w http.ResponseWriter, r *http.Request) {
+	raw, deprecations := workspaceDirParam(r)
+	dir, wsErr := s.resolveWorkspace(raw)
+	if wsErr != nil {
+		writeWorkspaceError(w, wsErr)
+		return
+	}
+
+	tw := &treeWalker{}
+	root := api.TreeNode{Name: filepath.Base(dir), Dir: true}
+	tw.walk(&root, dir, 1)
+	resp := api.WorkspaceTreeResponse{
+		Dir:          dir,
+		Root:         root,
+		Entries:      tw.entries,
+		Truncated:    tw.truncated,
+		Deprecations: deprecations,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("workspace tree encode error", slog.Any("error", err))
+	}
+}
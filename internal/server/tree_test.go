@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// writeTreeFile writes content to rel under dir with modification time mod.
+func writeTreeFile(t *testing.T, dir, rel, content string, mod time.Time) {
+	t.Helper()
+	p := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getTree calls handleWorkspaceTree for dir and decodes the response.
+func getTree(t *testing.T, dir string) api.WorkspaceTreeResponse {
+	t.Helper()
+	s := newChatTestServer(&fakeQuerier{})
+	w := httptest.NewRecorder()
+	s.handleWorkspaceTree(w, httptest.NewRequest(http.MethodGet, "/api/workspace/tree?workspaceDir="+url.QueryEscape(dir), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	var resp api.WorkspaceTreeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp
+}
+
+// treeShape renders node's descendants one per line as "relPath size",
+// directories marked with a trailing slash.
+func treeShape(node api.TreeNode) string {
+	var b strings.Builder
+	for _, c := range node.Children {
+		if c.Dir {
+			fmt.Fprintf(&b, "%s/ %d\n", c.RelPath, c.SizeBytes)
+			b.WriteString(treeShape(c))
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d\n", c.RelPath, c.SizeBytes)
+	}
+	return b.String()
+}
+
+func TestHandleWorkspaceTree(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	old := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	newest := old.Add(time.Hour)
+	writeTreeFile(t, dir, "main.tf", "# main\n", old)
+	writeTreeFile(t, dir, "README.md", "# readme\n", old)
+	writeTreeFile(t, dir, "prod.tfvars", "a = 1\n", old)
+	writeTreeFile(t, dir, "notes.txt", "ignored\n", old)
+	writeTreeFile(t, dir, "modules/vpc/main.tf", "# vpc\n", old)
+	writeTreeFile(t, dir, "modules/vpc/versions.tf", "# versions\n", newest)
+	writeTreeFile(t, dir, "modules/empty/script.sh", "echo\n", old)
+	writeTreeFile(t, dir, "live/terragrunt.hcl", "# tg\n", old)
+	writeTreeFile(t, dir, ".terraform/modules/x.tf", "# hidden\n", old)
+
+	resp := getTree(t, dir)
+	want := `live/ 5
+live/terragrunt.hcl 5
+modules/ 17
+modules/vpc/ 17
+modules/vpc/main.tf 6
+modules/vpc/versions.tf 11
+README.md 9
+main.tf 7
+prod.tfvars 6
+`
+	if got := treeShape(resp.Root); got != want {
+		t.Errorf("expected tree:\n%s\ngot:\n%s", want, got)
+	}
+	if resp.Dir != dir || resp.Root.Name != filepath.Base(dir) || !resp.Root.Dir || resp.Root.RelPath != "" {
+		t.Errorf("unexpected root %+v in %s", resp.Root, resp.Dir)
+	}
+	if resp.Entries != 9 || resp.Truncated {
+		t.Errorf("expected 9 entries, not truncated, got %d (%v)", resp.Entries, resp.Truncated)
+	}
+	if resp.Root.SizeBytes != 44 || !resp.Root.ModTime.Equal(newest) {
+		t.Errorf("expected the root to total 44 bytes modified at %v, got %d at %v", newest, resp.Root.SizeBytes, resp.Root.ModTime)
+	}
+	modules := resp.Root.Children[1]
+	if modules.Name != "modules" || !modules.ModTime.Equal(newest) {
+		t.Errorf("expected modules to carry the newest mod time, got %+v", modules)
+	}
+	if f := modules.Children[0].Children[0]; f.Name != "main.tf" || !f.ModTime.Equal(old) {
+		t.Errorf("unexpected file node %+v", f)
+	}
+}
+
+func TestHandleWorkspaceTree_Caps(t *testing.T) {
+	t.Parallel()
+
+	t.Run("entries", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		mod := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		for i := range maxTreeEntries + 50 {
+			writeTreeFile(t, dir, fmt.Sprintf("f%04d.tf", i), "#\n", mod)
+		}
+		resp := getTree(t, dir)
+		if resp.Entries != maxTreeEntries || len(resp.Root.Children) != maxTreeEntries || !resp.Truncated {
+			t.Errorf("expected %d entries and truncated, got %d (%d children, %v)",
+				maxTreeEntries, resp.Entries, len(resp.Root.Children), resp.Truncated)
+		}
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		mod := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+		deep := strings.Repeat("d/", maxTreeDepth)
+		writeTreeFile(t, dir, deep+"too-deep.tf", "#\n", mod)
+		writeTreeFile(t, dir, strings.Repeat("d/", maxTreeDepth-1)+"deepest.tf", "#\n", mod)
+		resp := getTree(t, dir)
+		if !resp.Truncated || resp.Entries != maxTreeDepth {
+			t.Errorf("expected %d entries and truncated, got %d (%v)", maxTreeDepth, resp.Entries, resp.Truncated)
+		}
+		if strings.Contains(treeShape(resp.Root), "too-deep.tf") {
+			t.Error("expected the file below the depth cap to be left out")
+		}
+	})
+}
+
+func TestHandleWorkspaceTree_NotFound(t *testing.T) {
+	t.Parallel()
+
+	s := newChatTestServer(&fakeQuerier{})
+	w := httptest.NewRecorder()
+	s.handleWorkspaceTree(w, httptest.NewRequest(http.MethodGet, "/api/workspace/tree?workspaceDir=/nonexistent/tfai", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), errCodeWorkspaceNotFound) {
+		t.Errorf("expected 404 %s, got %d — body: %s", errCodeWorkspaceNotFound, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/usage"
+)
+
+// handleUsageReport handles GET /api/usage/report?since=<date>&groupBy=<dim>.
+// It aggregates the token usage stored with conversation history and returns
+// the same JSON document as `tfai usage report --format json`.
+func (s *Server) handleUsageReport(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.Usage == nil {
+		writeJSONError(w, "usage reporting is unavailable: conversation history is disabled", http.StatusServiceUnavailable)
+		return
+	}
+	since, err := usage.ParseSince(r.URL.Query().Get("since"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groupBy, err := usage.ParseGroupBy(r.URL.Query().Get("groupBy"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, err := s.cfg.Usage.UsageRecords(r.Context(), since)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("usage report query error", slog.Any("error", err))
+		writeJSONError(w, "failed to load usage records", http.StatusInternalServerError)
+		return
+	}
+
+	report := usage.Aggregate(records, since, groupBy, s.cfg.Prices)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logging.FromContext(r.Context()).Error("usage report encode error", slog.Any("error", err))
+	}
+}
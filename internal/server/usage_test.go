@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/internal/usage"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// fakeUsageReader returns fixed records, filtered by since like the real store.
+type fakeUsageReader struct {
+	records []store.UsageRecord
+	err     error
+}
+
+func (f *fakeUsageReader) UsageRecords(_ context.Context, since time.Time) ([]store.UsageRecord, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var out []store.UsageRecord
+	for _, r := range f.records {
+		if !r.CreatedAt.Before(since) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// newUsageTestServer returns a Server whose usage source is reader.
+func newUsageTestServer(reader store.UsageReader) *Server {
+	return &Server{
+		cfg: &Config{
+			Usage:  reader,
+			Prices: usage.Prices{"openai": {"*": {Prompt: 0.01, Completion: 0.03}}},
+		},
+		log: slog.Default(),
+	}
+}
+
+func TestHandleUsageReport(t *testing.T) {
+	t.Parallel()
+
+	reader := &fakeUsageReader{records: []store.UsageRecord{
+		{Workspace: "/ws/a", CreatedAt: time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)},
+		{Workspace: "/ws/a", CreatedAt: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{
+			Workspace: "/ws/b",
+			CreatedAt: time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC),
+			Tracked:   true,
+			Usage:     store.Usage{Provider: "openai", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 1000},
+		},
+	}}
+	s := newUsageTestServer(reader)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/usage/report?since=2024-06-01&groupBy=workspace", nil)
+	w := httptest.NewRecorder()
+	s.handleUsageReport(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d — body: %s", w.Code, w.Body.String())
+	}
+	var resp api.UsageReport
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.GroupBy != "workspace" || len(resp.Groups) != 2 {
+		t.Fatalf("expected 2 workspace groups, got %+v", resp)
+	}
+	if resp.Total.Requests != 2 || resp.Total.Untracked != 1 {
+		t.Errorf("expected the May record to be excluded, got total %+v", resp.Total)
+	}
+	if resp.Total.EstimatedCost < 0.0399 || resp.Total.EstimatedCost > 0.0401 {
+		t.Errorf("expected cost 0.04, got %v", resp.Total.EstimatedCost)
+	}
+}
+
+func TestHandleUsageReport_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		reader   store.UsageReader
+		query    string
+		wantCode int
+	}{
+		{name: "history disabled", reader: nil, wantCode: http.StatusServiceUnavailable},
+		{name: "bad since", reader: &fakeUsageReader{}, query: "?since=yesterday", wantCode: http.StatusBadRequest},
+		{name: "bad groupBy", reader: &fakeUsageReader{}, query: "?groupBy=model", wantCode: http.StatusBadRequest},
+		{name: "store error", reader: &fakeUsageReader{err: errors.New("boom")}, wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := newUsageTestServer(tc.reader)
+			req := httptest.NewRequest(http.MethodGet, "/api/usage/report"+tc.query, nil)
+			w := httptest.NewRecorder()
+			s.handleUsageReport(w, req)
+			if w.Code != tc.wantCode {
+				t.Errorf("expected %d, got %d — body: %s", tc.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
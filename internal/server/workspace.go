@@ -3,16 +3,27 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/audit"
+	"github.com/54b3r/tfai-go/internal/hclinspect"
 	"github.com/54b3r/tfai-go/internal/logging"
+	"github.com/54b3r/tfai-go/internal/secretscan"
+	"github.com/54b3r/tfai-go/internal/textenc"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+	"github.com/54b3r/tfai-go/pkg/api"
 )
 
 // resolveAbsDir cleans and validates that the given path is absolute.
@@ -28,10 +39,103 @@ func resolveAbsDir(raw string) (string, error) {
 	return dir, nil
 }
 
+// Error codes returned in the "code" field of workspace validation failures.
+// Clients branch on these rather than on the human-readable message.
+const (
+	// errCodeWorkspaceRequired means no workspace directory was supplied.
+	errCodeWorkspaceRequired = "workspace_required"
+	// errCodeWorkspaceNotAbsolute means the supplied path was relative.
+	errCodeWorkspaceNotAbsolute = "workspace_not_absolute"
+	// errCodeWorkspaceOutsideRoot means the path escapes Config.WorkspaceRoot.
+	errCodeWorkspaceOutsideRoot = "workspace_outside_root"
+	// errCodeWorkspaceNotFound means the directory does not exist.
+	errCodeWorkspaceNotFound = "workspace_not_found"
+	// errCodeWorkspaceNotDirectory means the path exists but is not a directory.
+	errCodeWorkspaceNotDirectory = "workspace_not_directory"
+	// errCodeWorkspaceUnreadable means the directory could not be stat'ed.
+	errCodeWorkspaceUnreadable = "workspace_unreadable"
+	// errCodeSecretsDetected means a file save was rejected because its
+	// content contains credentials and Config.BlockSecretsOnSave is set.
+	errCodeSecretsDetected = "secrets_detected"
+	// errCodeFileNotText means a file read was rejected because the file is
+	// neither UTF-8 nor UTF-16 text.
+	errCodeFileNotText = "file_not_text"
+	// errCodeStateFileProtected means a delete of terraform.tfstate was
+	// refused because force was not set.
+	errCodeStateFileProtected = "state_file_protected"
+	// errCodeSessionNotFound means the sessionId was not created for the
+	// workspace by POST /api/session.
+	errCodeSessionNotFound = "session_not_found"
+)
+
+// workspaceError is a validation failure from resolveWorkspace. It carries the
+// HTTP status and machine-readable code so every handler reports the same
+// failure the same way.
+type workspaceError struct {
+	// status is the HTTP status code to return (400, 403, 404, or 500).
+	status int
+	// code is one of the errCodeWorkspace* constants.
+	code string
+	// msg is the human-readable message returned to the client.
+	msg string
+}
+
+// Error returns the human-readable message.
+func (e *workspaceError) Error() string { return e.msg }
+
+// resolveWorkspace is the single validation path for every client-supplied
+// workspace directory. It cleans the path and checks, in order:
+//
+//  1. present and absolute                       → 400
+//  2. inside Config.WorkspaceRoot when configured → 403
+//  3. exists                                     → 404
+//  4. is a directory                             → 400
+//
+// The cheap syntactic checks run before touching the filesystem so a path
+// outside the permitted root never leaks whether it exists.
+func (s *Server) resolveWorkspace(raw string) (string, *workspaceError) {
+	if raw == "" {
+		return "", &workspaceError{http.StatusBadRequest, errCodeWorkspaceRequired, "workspace directory is required"}
+	}
+	dir := filepath.Clean(raw)
+	if !filepath.IsAbs(dir) {
+		return "", &workspaceError{http.StatusBadRequest, errCodeWorkspaceNotAbsolute, "workspace directory must be an absolute path"}
+	}
+	if s.cfg.WorkspaceRoot != "" {
+		if _, err := ConfineToDir(s.cfg.WorkspaceRoot, dir); err != nil {
+			return "", &workspaceError{http.StatusForbidden, errCodeWorkspaceOutsideRoot, err.Error()}
+		}
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", &workspaceError{http.StatusNotFound, errCodeWorkspaceNotFound, "workspace directory not found"}
+		}
+		return "", &workspaceError{http.StatusInternalServerError, errCodeWorkspaceUnreadable, "failed to access workspace directory"}
+	}
+	if !info.IsDir() {
+		return "", &workspaceError{http.StatusBadRequest, errCodeWorkspaceNotDirectory, "workspace path is not a directory"}
+	}
+	return dir, nil
+}
+
+// writeWorkspaceError writes a workspaceError as a JSON body carrying both
+// the message and the machine-readable code.
+func writeWorkspaceError(w http.ResponseWriter, e *workspaceError) {
+	b, err := json.Marshal(api.ErrorResponse{Error: e.msg, Code: e.code})
+	if err != nil {
+		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.status)
+	w.Write(b) //nolint:errcheck // best-effort write on error path
+}
+
 // writeJSONError writes a JSON-formatted error response with the given status code.
 // msg is marshalled via encoding/json to prevent injection via user-controlled values.
 func writeJSONError(w http.ResponseWriter, msg string, status int) {
-	b, err := json.Marshal(map[string]string{"error": msg})
+	b, err := json.Marshal(api.ErrorResponse{Error: msg})
 	if err != nil {
 		http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
 		return
@@ -53,35 +157,29 @@ func ConfineToDir(root, target string) (string, error) {
 	return target, nil
 }
 
-// handleWorkspace handles GET /api/workspace?dir=<path>.
+// handleWorkspace handles GET /api/workspace?workspaceDir=<path>.
 // It recursively walks the directory and returns all .tf/.tfvars files as
-// relative paths (e.g. "modules/vpc/main.tf"), plus workspace status flags.
+// slash-separated relative paths (e.g. "modules/vpc/main.tf") sorted
+// lexicographically, plus workspace status flags, the configured state
+// backend, and the selected terraform workspace.
 func (s *Server) handleWorkspace(w http.ResponseWriter, r *http.Request) {
-	dir, err := resolveAbsDir(r.URL.Query().Get("dir"))
-	if err != nil {
-		writeJSONError(w, err.Error(), http.StatusBadRequest)
+	raw, deprecations := workspaceDirParam(r)
+	dir, wsErr := s.resolveWorkspace(raw)
+	if wsErr != nil {
+		writeWorkspaceError(w, wsErr)
 		return
 	}
-	if s.cfg.WorkspaceRoot != "" {
-		dir, err = ConfineToDir(s.cfg.WorkspaceRoot, dir)
-		if err != nil {
-			writeJSONError(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-	}
 
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		writeJSONError(w, "directory not found", http.StatusNotFound)
-		return
-	}
-
-	resp := workspaceResponse{
-		Dir:   dir,
-		Files: []string{},
-		Dirs:  []string{},
+	resp := api.WorkspaceResponse{
+		Dir:             dir,
+		Files:           []string{},
+		Dirs:            []string{},
+		BackendType:     hclinspect.BackendType(dir),
+		ActiveWorkspace: hclinspect.ActiveWorkspace(dir),
+		Deprecations:    deprecations,
 	}
 
-	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil // skip unreadable entries
 		}
@@ -106,7 +204,7 @@ func (s *Server) handleWorkspace(w http.ResponseWriter, r *http.Request) {
 		if ext == ".tf" || ext == ".tfvars" {
 			rel, relErr := filepath.Rel(dir, path)
 			if relErr == nil {
-				resp.Files = append(resp.Files, rel)
+				resp.Files = append(resp.Files, filepath.ToSlash(rel))
 			}
 		}
 		return nil
@@ -114,6 +212,9 @@ func (s *Server) handleWorkspace(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		logging.FromContext(r.Context()).Error("workspace walk error", slog.Any("error", err))
 	}
+	// WalkDir visits a directory before its sibling files ("a/b.tf" before
+	// "a.tf"); sort so the order is a plain string order clients can rely on.
+	slices.Sort(resp.Files)
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -121,6 +222,58 @@ func (s *Server) handleWorkspace(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleWorkspaceSummary handles GET /api/workspace/summary?workspaceDir=<abs>. It
+// reports the providers locked in .terraform.lock.hcl and which of them lack
+// a hash for the server's platform, with the command that records it. A
+// lock file that fails to parse is reported in the response, not as an error.
+func (s *Server) handleWorkspaceSummary(w http.ResponseWriter, r *http.Request) {
+	raw, deprecations := workspaceDirParam(r)
+	dir, wsErr := s.resolveWorkspace(raw)
+	if wsErr != nil {
+		writeWorkspaceError(w, wsErr)
+		return
+	}
+
+	host := hclinspect.HostPlatform()
+	resp := api.WorkspaceSummaryResponse{
+		Dir:               dir,
+		HostPlatform:      host,
+		Providers:         []api.LockedProvider{},
+		MissingHostHashes: []string{},
+		Deprecations:      deprecations,
+	}
+	providers, err := hclinspect.ReadLockfile(dir)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// Not initialised yet: nothing to report.
+	case err != nil:
+		resp.LockfileError = err.Error()
+	default:
+		hclinspect.IdentifyPlatforms(dir, providers)
+		for _, p := range providers {
+			resp.Providers = append(resp.Providers, api.LockedProvider{
+				Source:              p.Source,
+				Version:             p.Version,
+				Constraints:         p.Constraints,
+				Hashes:              p.Hashes,
+				Platforms:           append([]string{}, p.Platforms...),
+				UnrecordedPlatforms: p.Unrecorded,
+			})
+		}
+		if missing := hclinspect.MissingPlatform(providers, host); len(missing) > 0 {
+			for _, p := range missing {
+				resp.MissingHostHashes = append(resp.MissingHostHashes, p.Source)
+			}
+			resp.LockCommand = hclinspect.LockCommand(providers, host)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("workspace summary encode error", slog.Any("error", err))
+	}
+}
+
 // maxWorkspaceCreateBodyBytes is the maximum allowed size for a /api/workspace/create request body.
 const maxWorkspaceCreateBodyBytes = 1 << 20 // 1 MiB
 
@@ -130,9 +283,11 @@ const maxFileSaveBodyBytes = 5 << 20 // 5 MiB
 // handleWorkspaceCreate handles POST /api/workspace/create.
 // It writes a minimal Terraform scaffold into an existing directory.
 // The directory must already exist — this handler will not create it.
+// With "generate": true it then runs the description prompt against the
+// new workspace and streams the answer as SSE (see generateWorkspace).
 func (s *Server) handleWorkspaceCreate(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxWorkspaceCreateBodyBytes)
-	var body createWorkspaceRequest
+	var body api.CreateWorkspaceRequest
 	defer func() { _ = r.Body.Close() }()
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		logging.FromContext(r.Context()).Warn("workspace create decode error", slog.Any("error", err))
@@ -140,38 +295,44 @@ func (s *Server) handleWorkspaceCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	dir, err := resolveAbsDir(body.Dir)
-	if err != nil {
-		writeJSONError(w, err.Error(), http.StatusBadRequest)
+	// The directory must already exist — this handler never creates it.
+	dir, wsErr := s.resolveWorkspace(body.Dir)
+	if wsErr != nil {
+		writeWorkspaceError(w, wsErr)
 		return
 	}
 
-	if s.cfg.WorkspaceRoot != "" {
-		dir, err = ConfineToDir(s.cfg.WorkspaceRoot, dir)
-		if err != nil {
-			writeJSONError(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+	resp := api.CreateWorkspaceResponse{Dir: dir}
+	if body.Description != "" {
+		resp.Prompt = "Create a Terraform workspace for: " + body.Description
+	}
+	if body.Generate && resp.Prompt == "" {
+		writeJSONError(w, "generate requires a description", http.StatusBadRequest)
+		return
 	}
 
-	// Reject if the directory does not already exist — we do not create directories.
-	if info, err := os.Stat(dir); err != nil {
-		if os.IsNotExist(err) {
-			writeJSONError(w, "directory does not exist — create it first, then scaffold", http.StatusBadRequest)
-			return
-		}
-		writeJSONError(w, "failed to access directory: "+err.Error(), http.StatusInternalServerError)
+	files, err := s.writeScaffold(r, dir)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
-	} else if !info.IsDir() {
-		writeJSONError(w, "path exists but is not a directory", http.StatusBadRequest)
+	}
+	resp.Files = files
+	if body.Generate {
+		s.generateWorkspace(w, r, resp)
 		return
 	}
-
-	resp := createWorkspaceResponse{Dir: dir}
-	if body.Description != "" {
-		resp.Prompt = "Create a Terraform workspace for: " + body.Description
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(r.Context()).Error("workspace create encode error", slog.Any("error", err))
 	}
+}
 
+// writeScaffold writes the scaffold files into dir and returns their names.
+// The error names the file that could not be written.
+func (s *Server) writeScaffold(r *http.Request, dir string) ([]string, error) {
+	// Scaffold files may replace existing ones, even when a later write fails.
+	defer s.cfg.WorkspaceCache.Invalidate(dir)
+	var files []string
 	for _, f := range scaffoldFiles() {
 		path := filepath.Join(dir, f.name)
 		if err := os.WriteFile(path, []byte(f.content), 0o644); err != nil {
@@ -179,26 +340,106 @@ func (s *Server) handleWorkspaceCreate(w http.ResponseWriter, r *http.Request) {
 				slog.String("file", f.name),
 				slog.Any("error", err),
 			)
-			writeJSONError(w, "failed to create "+f.name+": "+err.Error(), http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("failed to create %s: %w", f.name, err)
 		}
-		resp.Files = append(resp.Files, f.name)
+		files = append(files, f.name)
 	}
-	logging.FromContext(r.Context()).Info("audit: workspace scaffold",
-		slog.String("event", "file_write"),
-		slog.String("path", dir),
-		slog.String("actor", r.RemoteAddr),
-		slog.Int("files", len(resp.Files)),
+	audit.LogEvent(r.Context(), audit.Event{Action: "workspace scaffold", Kind: "file_write", Path: dir, Actor: r.RemoteAddr},
+		slog.Int("files", len(files)),
 	)
+	return files, nil
+}
+
+// generateWorkspace runs the prompt of a freshly scaffolded workspace through
+// the querier and streams the answer as SSE, exactly like POST /api/chat,
+// with an EventWorkspaceFiles event listing the scaffold and generated files
+// before the final event. The scaffold is kept when generation fails.
+func (s *Server) generateWorkspace(w http.ResponseWriter, r *http.Request, resp api.CreateWorkspaceResponse) {
+	req := api.ChatRequest{Message: resp.Prompt, WorkspaceDir: resp.Dir}
+	s.streamQuery(w, r, req, func(sw *sseWriter, res *agent.QueryResult) {
+		for _, f := range res.Files {
+			if !slices.Contains(resp.Files, f) {
+				resp.Files = append(resp.Files, f)
+			}
+		}
+		_ = sw.WriteEvent(sseEvent{Type: api.EventWorkspaceFiles, Data: resp})
+	})
+}
+
+// maxWorkspaceCleanBodyBytes is the maximum allowed size for a /api/workspace/clean request body.
+const maxWorkspaceCleanBodyBytes = 64 << 10 // 64 KiB
+
+// handleWorkspaceClean handles POST /api/workspace/clean. It removes aged
+// artifacts from the registered .tfai subdirectories of the workspace (or
+// only reports them when dryRun is set). User files are never touched.
+func (s *Server) handleWorkspaceClean(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxWorkspaceCleanBodyBytes)
+	var body api.CleanWorkspaceRequest
+	defer func() { _ = r.Body.Close() }()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logging.FromContext(r.Context()).Warn("workspace clean decode error", slog.Any("error", err))
+		writeJSONError(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dir, wsErr := s.resolveWorkspace(body.Dir)
+	if wsErr != nil {
+		writeWorkspaceError(w, wsErr)
+		return
+	}
+	olderThan, err := tfaidir.ParseAge(body.OlderThan)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	subdirs, err := tfaidir.ParseSubdirs(strings.Join(body.What, ","))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := tfaidir.Clean(dir, tfaidir.CleanOptions{
+		Subdirs:   subdirs,
+		OlderThan: olderThan,
+		DryRun:    body.DryRun,
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Error("workspace clean error", slog.String("path", dir), slog.Any("error", err))
+		writeJSONError(w, "failed to clean workspace: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := api.CleanWorkspaceResponse{
+		Dir:            dir,
+		DryRun:         report.DryRun,
+		Removed:        make([]api.CleanedArtifact, 0, len(report.Entries)),
+		ReclaimedBytes: report.Bytes,
+	}
+	for _, e := range report.Entries {
+		resp.Removed = append(resp.Removed, api.CleanedArtifact{
+			Type:    string(e.Subdir),
+			Path:    filepath.ToSlash(e.Path),
+			Bytes:   e.Bytes,
+			ModTime: api.NewTimestamp(e.ModTime),
+		})
+	}
+	if !body.DryRun {
+		audit.LogEvent(r.Context(), audit.Event{Action: "workspace clean", Kind: "file_delete", Path: dir, Actor: r.RemoteAddr},
+			slog.Int("artifacts", len(resp.Removed)),
+			slog.Int64("bytes", resp.ReclaimedBytes),
+		)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		logging.FromContext(r.Context()).Error("workspace create encode error", slog.Any("error", err))
+		logging.FromContext(r.Context()).Error("workspace clean encode error", slog.Any("error", err))
 	}
 }
 
 // handleFileRead handles GET /api/file?path=<absolute-path>&workspaceDir=<root>.
-// Returns the raw content of the requested file. The path must resolve within
-// the declared workspaceDir to prevent path traversal.
+// Returns the content of the requested file decoded to UTF-8 with LF line
+// endings, along with its original encoding and line-ending style. Files that
+// are not text are rejected with 415. The path must resolve within the
+// declared workspaceDir to prevent path traversal.
 func (s *Server) handleFileRead(w http.ResponseWriter, r *http.Request) {
 	rawPath := r.URL.Query().Get("path")
 	rawRoot := r.URL.Query().Get("workspaceDir")
@@ -215,13 +456,9 @@ func (s *Server) handleFileRead(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, err.Error(), http.StatusForbidden)
 		return
 	}
-
-	if s.cfg.WorkspaceRoot != "" {
-		path, err = ConfineToDir(s.cfg.WorkspaceRoot, path)
-		if err != nil {
-			writeJSONError(w, err.Error(), http.StatusForbidden)
-			return
-		}
+	if _, wsErr := s.resolveWorkspace(rawRoot); wsErr != nil {
+		writeWorkspaceError(w, wsErr)
+		return
 	}
 
 	content, err := os.ReadFile(path)
@@ -233,18 +470,31 @@ func (s *Server) handleFileRead(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, "failed to read file", http.StatusInternalServerError)
 		return
 	}
+	text, err := textenc.Decode(content)
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("file read: not a text file", slog.String("path", path))
+		writeWorkspaceError(w, &workspaceError{http.StatusUnsupportedMediaType, errCodeFileNotText, err.Error()})
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(fileResponse{Path: path, Content: string(content)}); err != nil {
+	resp := api.FileResponse{
+		Path:       path,
+		Content:    text.Content,
+		Encoding:   string(text.Encoding),
+		LineEnding: string(text.LineEnding),
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		logging.FromContext(r.Context()).Error("file read encode error", slog.Any("error", err))
 	}
 }
 
 // handleFileSave handles PUT /api/file.
-// Writes content to the given path. The path must resolve within the declared
+// Writes content to the given path as UTF-8, keeping CRLF line endings when
+// the file being replaced used them. The path must resolve within the declared
 // workspaceDir to prevent writes outside the user's workspace.
 func (s *Server) handleFileSave(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxFileSaveBodyBytes)
-	var body fileSaveRequest
+	var body api.FileSaveRequest
 	defer func() { _ = r.Body.Close() }()
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeJSONError(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
@@ -263,16 +513,25 @@ func (s *Server) handleFileSave(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, err.Error(), http.StatusForbidden)
 		return
 	}
+	ws, wsErr := s.resolveWorkspace(body.WorkspaceDir)
+	if wsErr != nil {
+		writeWorkspaceError(w, wsErr)
+		return
+	}
 
-	if s.cfg.WorkspaceRoot != "" {
-		path, err = ConfineToDir(s.cfg.WorkspaceRoot, path)
-		if err != nil {
-			writeJSONError(w, err.Error(), http.StatusForbidden)
+	if findings := s.scanSave(r.Context(), ws, path, body.Content); len(findings) > 0 {
+		summary := secretscan.Summary(findings)
+		if s.cfg.BlockSecretsOnSave {
+			writeWorkspaceError(w, &workspaceError{http.StatusUnprocessableEntity, errCodeSecretsDetected,
+				"file contains secrets: " + summary})
 			return
 		}
+		w.Header().Set(api.HeaderSecretsDetected, summary)
 	}
 
-	if err := os.WriteFile(path, []byte(body.Content), 0o644); err != nil {
+	err = textenc.WriteFile(path, body.Content, 0o644)
+	s.cfg.WorkspaceCache.Invalidate(ws)
+	if err != nil {
 		logging.FromContext(r.Context()).Error("file save error",
 			slog.String("path", path),
 			slog.Any("error", err),
@@ -280,10 +539,7 @@ func (s *Server) handleFileSave(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, "failed to save file: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	logging.FromContext(r.Context()).Info("audit: file write",
-		slog.String("event", "file_write"),
-		slog.String("path", path),
-		slog.String("actor", r.RemoteAddr),
+	audit.LogEvent(r.Context(), audit.Event{Action: "file write", Kind: "file_write", Path: path, Actor: r.RemoteAddr},
 		slog.Int("bytes", len(body.Content)),
 	)
 
@@ -291,6 +547,121 @@ func (s *Server) handleFileSave(w http.ResponseWriter, r *http.Request) {
 	_, _ = fmt.Fprintf(w, `{"ok":true}`)
 }
 
+// maxFileDeleteBodyBytes is the maximum allowed size for a /api/file DELETE request body.
+const maxFileDeleteBodyBytes = 64 << 10 // 64 KiB
+
+// handleFileDelete handles DELETE /api/file. The path, workspaceDir, and
+// force fields are read from the query string or a JSON body. The path must
+// resolve within the declared workspaceDir; directories are never removed,
+// and terraform.tfstate is only removed with force=true because losing state
+// is not recoverable from the workspace alone.
+func (s *Server) handleFileDelete(w http.ResponseWriter, r *http.Request) {
+	var body api.FileDeleteRequest
+	if r.ContentLength != 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxFileDeleteBodyBytes)
+		defer func() { _ = r.Body.Close() }()
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			writeJSONError(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	q := r.URL.Query()
+	if v := q.Get("path"); v != "" {
+		body.Path = v
+	}
+	if v := q.Get("workspaceDir"); v != "" {
+		body.WorkspaceDir = v
+	}
+	if q.Has("force") {
+		body.Force = q.Get("force") == "true"
+	}
+
+	if body.Path == "" {
+		writeJSONError(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	if body.WorkspaceDir == "" {
+		writeJSONError(w, "workspaceDir is required", http.StatusBadRequest)
+		return
+	}
+	path, err := ConfineToDir(body.WorkspaceDir, body.Path)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	ws, wsErr := s.resolveWorkspace(body.WorkspaceDir)
+	if wsErr != nil {
+		writeWorkspaceError(w, wsErr)
+		return
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			writeJSONError(w, "file not found", http.StatusNotFound)
+			return
+		}
+		writeJSONError(w, "failed to access file", http.StatusInternalServerError)
+		return
+	}
+	if info.IsDir() || path == ws {
+		writeJSONError(w, "path is a directory", http.StatusBadRequest)
+		return
+	}
+	if filepath.Base(path) == "terraform.tfstate" && !body.Force {
+		writeWorkspaceError(w, &workspaceError{http.StatusConflict, errCodeStateFileProtected,
+			"refusing to delete terraform.tfstate without force=true"})
+		return
+	}
+
+	if err := os.Remove(path); err != nil {
+		logging.FromContext(r.Context()).Error("file delete error",
+			slog.String("path", path),
+			slog.Any("error", err),
+		)
+		writeJSONError(w, "failed to delete file: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.cfg.WorkspaceCache.Invalidate(ws)
+	audit.LogEvent(r.Context(), audit.Event{Action: "file delete", Kind: "file_delete", Path: path, Actor: r.RemoteAddr},
+		slog.Bool("force", body.Force),
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = fmt.Fprintf(w, `{"ok":true}`)
+}
+
+// scanSave runs the secret scanner over content about to be saved to path,
+// honouring the workspace allowlist, and logs any findings. Findings name
+// path relative to ws.
+func (s *Server) scanSave(ctx context.Context, ws, path, content string) []secretscan.Finding {
+	log := logging.FromContext(ctx)
+	scanner := s.cfg.SecretScanner
+	if scanner == nil {
+		scanner = secretscan.Default()
+	}
+	allow, err := secretscan.LoadAllowlist(secretscan.AllowlistPath(ws))
+	if err != nil {
+		log.Warn("secretscan: ignoring unreadable allowlist", slog.Any("error", err))
+	} else {
+		scanner = scanner.WithAllowlist(allow)
+	}
+
+	rel, err := filepath.Rel(ws, path)
+	if err != nil {
+		rel = path
+	}
+	findings := scanner.Scan(filepath.ToSlash(rel), content)
+	if len(findings) > 0 {
+		log.Warn("secretscan: secrets in saved file",
+			slog.String("path", path),
+			slog.String("findings", secretscan.Summary(findings)),
+			slog.Bool("blocked", s.cfg.BlockSecretsOnSave),
+		)
+	}
+	return findings
+}
+
 // scaffoldFile is a name/content pair for a file to write during workspace creation.
 type scaffoldFile struct {
 	// name is the filename to write inside the workspace directory.
@@ -18,14 +18,24 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"net/http/httptest" // provides fake request/response — no real network needed
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/deprecation"
+	"github.com/54b3r/tfai-go/internal/hclinspect"
+	"github.com/54b3r/tfai-go/internal/testutil"
+	"github.com/54b3r/tfai-go/internal/tfaidir"
+	"github.com/54b3r/tfai-go/pkg/api"
 )
 
 // ---------------------------------------------------------------------------
@@ -161,7 +171,7 @@ func TestHandleWorkspace_RelativePath(t *testing.T) {
 
 	s := newTestServer()
 	// Embed the query parameter directly in the URL string.
-	req := httptest.NewRequest(http.MethodGet, "/api/workspace?dir=relative/path", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir=relative/path", nil)
 	w := httptest.NewRecorder()
 
 	s.handleWorkspace(w, req)
@@ -178,7 +188,7 @@ func TestHandleWorkspace_NotFound(t *testing.T) {
 
 	s := newTestServer()
 	// The long random suffix makes accidental collision essentially impossible.
-	req := httptest.NewRequest(http.MethodGet, "/api/workspace?dir=/tmp/tfai-does-not-exist-xyz-abc", nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir=/tmp/tfai-does-not-exist-xyz-abc", nil)
 	w := httptest.NewRecorder()
 
 	s.handleWorkspace(w, req)
@@ -199,26 +209,26 @@ func TestHandleWorkspace_NotFound(t *testing.T) {
 func TestHandleWorkspace_EmptyDir(t *testing.T) {
 	t.Parallel()
 
-	// t.TempDir() creates a temporary directory that is automatically deleted
-	// when the test finishes. Always prefer this over os.MkdirTemp — cleanup
-	// is guaranteed even if the test panics.
-	dir := t.TempDir()
+	// testutil.NewWorkspace creates a workspace under t.TempDir(), which is
+	// automatically deleted when the test finishes. Always prefer this over
+	// os.MkdirTemp — cleanup is guaranteed even if the test panics.
+	dir := testutil.NewWorkspace(t).Dir()
 
 	s := newTestServer()
-	req := httptest.NewRequest(http.MethodGet, "/api/workspace?dir="+dir, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir="+dir, nil)
 	w := httptest.NewRecorder()
 
 	s.handleWorkspace(w, req)
 
 	// t.Fatalf stops immediately — if we don't get 200 there's no point trying
-	// to decode the body; it will be an error string, not a workspaceResponse.
+	// to decode the body; it will be an error string, not a api.WorkspaceResponse.
 	if w.Code != http.StatusOK {
 		t.Fatalf("expected 200 OK, got %d — body: %s", w.Code, w.Body.String())
 	}
 
 	// Decode the JSON response body into our response struct.
 	// w.Body is a *bytes.Buffer so we pass it directly to json.NewDecoder.
-	var resp workspaceResponse
+	var resp api.WorkspaceResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to decode JSON response: %v", err)
 	}
@@ -249,21 +259,19 @@ func TestHandleWorkspace_EmptyDir(t *testing.T) {
 func TestHandleWorkspace_TFWorkspace(t *testing.T) {
 	t.Parallel()
 
-	dir := t.TempDir()
-
-	// Set up a realistic Terraform workspace layout inside the temp dir.
-	// mustWriteFile / mustMkdir are test helpers defined at the bottom of this
-	// file. They call t.Fatal on error so tests fail fast with a clear message
-	// instead of a cryptic nil-pointer panic later.
-	mustWriteFile(t, filepath.Join(dir, "main.tf"), "# main")
-	mustWriteFile(t, filepath.Join(dir, "terraform.tfstate"), "{}")
-	mustWriteFile(t, filepath.Join(dir, ".terraform.lock.hcl"), "# lock")
-	mustMkdir(t, filepath.Join(dir, ".terraform"))                      // presence signals `terraform init` was run
-	mustMkdir(t, filepath.Join(dir, "modules"))                         // a visible (non-hidden) subdirectory
-	mustWriteFile(t, filepath.Join(dir, "modules", "main.tf"), "# mod") // file inside subdir
+	// Set up a realistic Terraform workspace layout inside a temp dir. The
+	// testutil builder calls t.Fatal on error so tests fail fast with a clear
+	// message instead of a cryptic nil-pointer panic later.
+	dir := testutil.NewWorkspace(t).
+		WithFile("main.tf", "# main").
+		WithFile("terraform.tfstate", "{}").
+		WithFile(".terraform.lock.hcl", "# lock").
+		WithDir(".terraform").                // presence signals `terraform init` was run
+		WithFile("modules/main.tf", "# mod"). // file inside a visible subdir
+		Dir()
 
 	s := newTestServer()
-	req := httptest.NewRequest(http.MethodGet, "/api/workspace?dir="+dir, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir="+dir, nil)
 	w := httptest.NewRecorder()
 
 	s.handleWorkspace(w, req)
@@ -272,7 +280,7 @@ func TestHandleWorkspace_TFWorkspace(t *testing.T) {
 		t.Fatalf("expected 200 OK, got %d — body: %s", w.Code, w.Body.String())
 	}
 
-	var resp workspaceResponse
+	var resp api.WorkspaceResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to decode JSON response: %v", err)
 	}
@@ -307,6 +315,281 @@ func TestHandleWorkspace_TFWorkspace(t *testing.T) {
 	}
 }
 
+// TestHandleWorkspace_Backend verifies the reported state backend and
+// terraform workspace, so a user whose state lives in S3 is not left
+// wondering why terraform.tfstate is missing.
+func TestHandleWorkspace_Backend(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		workspace     func(t *testing.T) *testutil.Workspace
+		wantBackend   string
+		wantWorkspace string
+	}{
+		{
+			name: "local without .terraform",
+			workspace: func(t *testing.T) *testutil.Workspace {
+				return testutil.NewWorkspace(t).WithFile("main.tf", "terraform {\n  required_version = \">= 1.5\"\n}\n")
+			},
+			wantBackend:   "local",
+			wantWorkspace: "default",
+		},
+		{
+			name: "s3 with a selected workspace",
+			workspace: func(t *testing.T) *testutil.Workspace {
+				return testutil.NewWorkspace(t).
+					WithFile("backend.tf", "terraform {\n  backend \"s3\" {\n    bucket = \"state\"\n  }\n}\n").
+					WithFile(".terraform/environment", "staging")
+			},
+			wantBackend:   "s3",
+			wantWorkspace: "staging",
+		},
+		{
+			name: "azurerm initialised in the default workspace",
+			workspace: func(t *testing.T) *testutil.Workspace {
+				return testutil.NewWorkspace(t).
+					WithFile("versions.tf", "terraform {\n  backend \"azurerm\" {}\n}\n").
+					WithDir(".terraform")
+			},
+			wantBackend:   "azurerm",
+			wantWorkspace: "default",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := tc.workspace(t).Dir()
+			w := httptest.NewRecorder()
+			newTestServer().handleWorkspace(w, httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir="+dir, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200 OK, got %d — body: %s", w.Code, w.Body.String())
+			}
+			var resp api.WorkspaceResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode JSON response: %v", err)
+			}
+			if resp.BackendType != tc.wantBackend || resp.ActiveWorkspace != tc.wantWorkspace {
+				t.Errorf("expected backend %q in workspace %q, got %q in %q",
+					tc.wantBackend, tc.wantWorkspace, resp.BackendType, resp.ActiveWorkspace)
+			}
+		})
+	}
+}
+
+// TestHandleWorkspace_Layouts runs the listing over the canonical fixtures
+// and the edge cases they do not cover: deep module trees, symlinks, large
+// files and terragrunt units, whose .hcl files are not listed.
+func TestHandleWorkspace_Layouts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		workspace func(t *testing.T) *testutil.Workspace
+		wantFiles []string
+	}{
+		{
+			name:      "basic fixture",
+			workspace: func(t *testing.T) *testutil.Workspace { return testutil.Fixture(t, testutil.FixtureBasic) },
+			wantFiles: []string{"main.tf", "outputs.tf", "terraform.tfvars", "variables.tf", "versions.tf"},
+		},
+		{
+			name:      "deep module tree",
+			workspace: func(t *testing.T) *testutil.Workspace { return testutil.Fixture(t, testutil.FixtureModules) },
+			wantFiles: []string{
+				"main.tf",
+				"modules/network/vpc/main.tf",
+				"modules/network/vpc/outputs.tf",
+				"modules/network/vpc/subnets/main.tf",
+				"modules/network/vpc/variables.tf",
+				"modules/platform/eks/nodegroups/spot/main.tf",
+			},
+		},
+		{
+			name:      "terragrunt units",
+			workspace: func(t *testing.T) *testutil.Workspace { return testutil.Fixture(t, testutil.FixtureTerragrunt) },
+			wantFiles: []string{"modules/vpc/main.tf"},
+		},
+		{
+			// A symlinked file is listed; a symlinked directory is not
+			// followed, so its files are not listed twice.
+			name: "symlinks",
+			workspace: func(t *testing.T) *testutil.Workspace {
+				return testutil.NewWorkspace(t).
+					WithModule("modules/vpc").
+					WithSymlink("shared.tf", "modules/vpc/main.tf").
+					WithSymlink("env/vpc", "../modules/vpc")
+			},
+			wantFiles: []string{"modules/vpc/main.tf", "modules/vpc/outputs.tf", "modules/vpc/variables.tf", "shared.tf"},
+		},
+		{
+			name: "large file",
+			workspace: func(t *testing.T) *testutil.Workspace {
+				return testutil.NewWorkspace(t).WithLargeFile("generated.tf", 1<<20).WithFile("main.tf", "# main")
+			},
+			wantFiles: []string{"generated.tf", "main.tf"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := tc.workspace(t).Dir()
+			req := httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir="+dir, nil)
+			w := httptest.NewRecorder()
+
+			newTestServer().handleWorkspace(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200 OK, got %d — body: %s", w.Code, w.Body.String())
+			}
+			var resp api.WorkspaceResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode JSON response: %v", err)
+			}
+			if !slices.Equal(resp.Files, tc.wantFiles) {
+				t.Errorf("Files: expected %v, got %v", tc.wantFiles, resp.Files)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// GET /api/workspace/summary
+// ---------------------------------------------------------------------------
+
+// TestHandleWorkspaceSummary verifies the lock file summary, including the
+// missing-hash check for a provider package installed for the server's own
+// platform whose hash the lock file does not record.
+func TestHandleWorkspaceSummary(t *testing.T) {
+	t.Parallel()
+
+	const lock = `provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.31.0"
+  constraints = "~> 5.0"
+  hashes      = ["h1:ltxyuBWIy9cq0kIKDJH1jeWJy/y7XJLjS4QrsQK4plA="]
+}
+`
+	host := hclinspect.HostPlatform()
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantSources []string
+		wantMissing []string
+		wantError   bool
+	}{
+		{name: "no lock file"},
+		{
+			name:        "host hash recorded or unknown",
+			files:       map[string]string{".terraform.lock.hcl": lock},
+			wantSources: []string{"registry.terraform.io/hashicorp/aws"},
+		},
+		{
+			name: "host package installed without a hash",
+			files: map[string]string{
+				".terraform.lock.hcl": lock,
+				".terraform/providers/registry.terraform.io/hashicorp/aws/5.31.0/" + host + "/terraform-provider-aws": "binary",
+			},
+			wantSources: []string{"registry.terraform.io/hashicorp/aws"},
+			wantMissing: []string{"registry.terraform.io/hashicorp/aws"},
+		},
+		{
+			name:      "malformed lock file",
+			files:     map[string]string{".terraform.lock.hcl": `provider "x" {`},
+			wantError: true,
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for rel, content := range tc.files {
+				mustMkdir(t, filepath.Dir(filepath.Join(dir, rel)))
+				mustWriteFile(t, filepath.Join(dir, rel), content)
+			}
+			w := httptest.NewRecorder()
+			newTestServer().handleWorkspaceSummary(w, httptest.NewRequest(http.MethodGet, "/api/workspace/summary?workspaceDir="+dir, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200 OK, got %d — body: %s", w.Code, w.Body.String())
+			}
+
+			var resp api.WorkspaceSummaryResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode JSON response: %v", err)
+			}
+			var sources []string
+			for _, p := range resp.Providers {
+				sources = append(sources, p.Source)
+			}
+			if fmt.Sprint(sources) != fmt.Sprint(tc.wantSources) {
+				t.Errorf("providers: expected %v, got %v", tc.wantSources, sources)
+			}
+			if fmt.Sprint(resp.MissingHostHashes) != fmt.Sprint(tc.wantMissing) {
+				t.Errorf("missingHostHashes: expected %v, got %v", tc.wantMissing, resp.MissingHostHashes)
+			}
+			if wantCmd := len(tc.wantMissing) > 0; (resp.LockCommand != "") != wantCmd || (wantCmd && !strings.Contains(resp.LockCommand, "-platform="+host)) {
+				t.Errorf("unexpected lockCommand %q", resp.LockCommand)
+			}
+			if (resp.LockfileError != "") != tc.wantError {
+				t.Errorf("lockfileError: expected set=%v, got %q", tc.wantError, resp.LockfileError)
+			}
+			if resp.HostPlatform != host {
+				t.Errorf("hostPlatform: expected %q, got %q", host, resp.HostPlatform)
+			}
+		})
+	}
+}
+
+// TestHandleWorkspace_Deprecations verifies that the deprecated dir parameter
+// still works on both workspace GET endpoints and is reported in the
+// response's deprecations array, while workspaceDir reports nothing.
+func TestHandleWorkspace_Deprecations(t *testing.T) {
+	t.Parallel()
+
+	dir := testutil.Fixture(t, testutil.FixtureBasic).Dir()
+	tests := []struct {
+		name    string
+		path    string
+		handler func(*Server, http.ResponseWriter, *http.Request)
+		want    []string
+	}{
+		{name: "workspace dir", path: "/api/workspace?dir=", handler: (*Server).handleWorkspace, want: []string{deprecation.WorkspaceDirParam}},
+		{name: "workspace workspaceDir", path: "/api/workspace?workspaceDir=", handler: (*Server).handleWorkspace},
+		{name: "summary dir", path: "/api/workspace/summary?dir=", handler: (*Server).handleWorkspaceSummary, want: []string{deprecation.WorkspaceDirParam}},
+		{name: "summary workspaceDir", path: "/api/workspace/summary?workspaceDir=", handler: (*Server).handleWorkspaceSummary},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+			tc.handler(newTestServer(), w, httptest.NewRequest(http.MethodGet, tc.path+dir, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200 OK, got %d — body: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Deprecations []api.Deprecation `json:"deprecations"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode JSON response: %v", err)
+			}
+			var ids []string
+			for _, d := range resp.Deprecations {
+				ids = append(ids, d.ID)
+				if d.Field != "dir" || d.Replacement != "workspaceDir" {
+					t.Errorf("unexpected deprecation %+v", d)
+				}
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tc.want) {
+				t.Errorf("expected deprecations %v, got %v", tc.want, ids)
+			}
+		})
+	}
+}
+
 // ---------------------------------------------------------------------------
 // POST /api/workspace/create — error path tests
 // ---------------------------------------------------------------------------
@@ -396,12 +679,12 @@ func TestHandleWorkspaceCreate_Success(t *testing.T) {
 
 	s.handleWorkspaceCreate(w, req)
 
-	// Fatalf here — a non-200 body is an error string, not a createWorkspaceResponse.
+	// Fatalf here — a non-200 body is an error string, not a api.CreateWorkspaceResponse.
 	if w.Code != http.StatusOK {
 		t.Fatalf("expected 200 OK, got %d — body: %s", w.Code, w.Body.String())
 	}
 
-	var resp createWorkspaceResponse
+	var resp api.CreateWorkspaceResponse
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("failed to decode JSON response: %v", err)
 	}
@@ -444,8 +727,113 @@ func TestHandleWorkspaceCreate_NonExistentDir(t *testing.T) {
 
 	s.handleWorkspaceCreate(w, req)
 
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 Not Found, got %d — body: %s", w.Code, w.Body.String())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// POST /api/workspace/create — "generate": true
+// ---------------------------------------------------------------------------
+
+// generateQuerier records the request it was given and answers like
+// fakeQuerier.
+type generateQuerier struct {
+	fakeQuerier
+	req agent.QueryRequest
+}
+
+func (q *generateQuerier) Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	q.req = req
+	return q.fakeQuerier.Run(ctx, req)
+}
+
+func TestHandleWorkspaceCreate_Generate(t *testing.T) {
+	t.Parallel()
+
+	scaffold := `["main.tf","variables.tf","outputs.tf","versions.tf"]`
+	tests := []struct {
+		name string
+		q    fakeQuerier
+		// want lists the expected events after accepted, with {dir}
+		// standing for the workspace.
+		want []string
+	}{
+		{
+			name: "scaffold and generated files",
+			q:    fakeQuerier{response: "Wrote the bucket.", files: []string{"main.tf", "s3.tf"}},
+			want: []string{
+				`message:Wrote the bucket.`,
+				`files_written:true`,
+				`files:{"dir":"{dir}","files":["main.tf","variables.tf","outputs.tf","versions.tf","s3.tf"],"prompt":"Create a Terraform workspace for: S3 bucket"}`,
+				`done:"[DONE]"`,
+			},
+		},
+		{
+			name: "agent failure keeps the scaffold",
+			q:    fakeQuerier{err: fmt.Errorf("LLM unavailable")},
+			want: []string{
+				`files:{"dir":"{dir}","files":` + scaffold + `,"prompt":"Create a Terraform workspace for: S3 bucket"}`,
+				`error:"LLM unavailable"`,
+			},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			q := &generateQuerier{fakeQuerier: tc.q}
+			s := newChatTestServer(q)
+			body := `{"dir":"` + dir + `","description":"S3 bucket","generate":true}`
+			w := httptest.NewRecorder()
+			s.handleWorkspaceCreate(w, httptest.NewRequest(http.MethodPost, "/api/workspace/create", strings.NewReader(body)))
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+				t.Fatalf("expected a 200 event stream, got %d %q — body: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+			}
+			if q.req.Message != "Create a Terraform workspace for: S3 bucket" || q.req.WorkspaceDir != dir {
+				t.Errorf("expected the description prompt against %s, got %q against %q", dir, q.req.Message, q.req.WorkspaceDir)
+			}
+			events := sseEvents(w.Body.String())
+			if len(events) == 0 || !strings.HasPrefix(events[0], "accepted:") {
+				t.Fatalf("expected the stream to start with accepted, got %q", events)
+			}
+			want := make([]string, len(tc.want))
+			for i, e := range tc.want {
+				want[i] = strings.ReplaceAll(e, "{dir}", dir)
+			}
+			if got := events[1:]; !slices.Equal(got, want) {
+				t.Errorf("expected events\n%q\ngot\n%q", want, got)
+			}
+			for _, f := range scaffoldFiles() {
+				if _, err := os.Stat(filepath.Join(dir, f.name)); err != nil {
+					t.Errorf("expected scaffold file %s to exist: %v", f.name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleWorkspaceCreate_GenerateRequiresDescription(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	q := &generateQuerier{}
+	s := newChatTestServer(q)
+	w := httptest.NewRecorder()
+	s.handleWorkspaceCreate(w, httptest.NewRequest(http.MethodPost, "/api/workspace/create",
+		strings.NewReader(`{"dir":"`+dir+`","generate":true}`)))
+
 	if w.Code != http.StatusBadRequest {
-		t.Errorf("expected 400 Bad Request, got %d — body: %s", w.Code, w.Body.String())
+		t.Fatalf("expected 400, got %d — body: %s", w.Code, w.Body.String())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected nothing scaffolded, got %d entries", len(entries))
+	}
+	if q.req.Message != "" {
+		t.Error("expected the agent not to run")
 	}
 }
 
@@ -453,6 +841,109 @@ func TestHandleWorkspaceCreate_NonExistentDir(t *testing.T) {
 // ConfineToDir — pure function tests
 // ---------------------------------------------------------------------------
 
+// ---------------------------------------------------------------------------
+// handleWorkspaceClean — POST /api/workspace/clean
+// ---------------------------------------------------------------------------
+
+// newCleanWorkspace returns a workspace with one artifact in each registered
+// .tfai subdirectory next to an ordinary user file.
+func newCleanWorkspace(t *testing.T) string {
+	t.Helper()
+	ws := testutil.NewWorkspace(t).WithFile("main.tf", "# user file\n")
+	for _, sub := range tfaidir.Subdirs {
+		ws.WithFile(filepath.Join(tfaidir.DirName, string(sub), "artifact"), "12345")
+	}
+	return ws.Dir()
+}
+
+func TestHandleWorkspaceClean(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		body        string
+		wantRemoved int
+		wantKept    bool // artifacts still on disk afterwards
+	}{
+		{name: "dry run", body: `{"dir":%q,"dryRun":true}`, wantRemoved: 4, wantKept: true},
+		{name: "all", body: `{"dir":%q}`, wantRemoved: 4},
+		{name: "selected type", body: `{"dir":%q,"what":["trash"]}`, wantRemoved: 1, wantKept: true},
+		{name: "too young", body: `{"dir":%q,"olderThan":"30d"}`, wantRemoved: 0, wantKept: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := newCleanWorkspace(t)
+			req := httptest.NewRequest(http.MethodPost, "/api/workspace/clean",
+				strings.NewReader(fmt.Sprintf(tc.body, dir)))
+			w := httptest.NewRecorder()
+
+			newTestServer().handleWorkspaceClean(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200 OK, got %d — body: %s", w.Code, w.Body.String())
+			}
+			var resp api.CleanWorkspaceResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("failed to decode JSON response: %v", err)
+			}
+			if len(resp.Removed) != tc.wantRemoved {
+				t.Errorf("expected %d removed artifacts, got %+v", tc.wantRemoved, resp.Removed)
+			}
+			if resp.ReclaimedBytes != int64(5*tc.wantRemoved) {
+				t.Errorf("expected %d reclaimed bytes, got %d", 5*tc.wantRemoved, resp.ReclaimedBytes)
+			}
+			_, err := os.Stat(filepath.Join(tfaidir.Path(dir, tfaidir.Backups), "artifact"))
+			if kept := err == nil; kept != tc.wantKept {
+				t.Errorf("backups artifact kept=%v, expected %v", kept, tc.wantKept)
+			}
+			if got := mustReadFile(t, filepath.Join(dir, "main.tf")); got != "# user file\n" {
+				t.Errorf("user file was modified: %q", got)
+			}
+		})
+	}
+}
+
+func TestHandleWorkspaceClean_BadRequest(t *testing.T) {
+	t.Parallel()
+
+	dir := newCleanWorkspace(t)
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{name: "invalid JSON", body: `{`, wantCode: http.StatusBadRequest},
+		{name: "missing dir", body: `{}`, wantCode: http.StatusBadRequest},
+		{name: "unknown type", body: fmt.Sprintf(`{"dir":%q,"what":["src"]}`, dir), wantCode: http.StatusBadRequest},
+		{name: "invalid age", body: fmt.Sprintf(`{"dir":%q,"olderThan":"soon"}`, dir), wantCode: http.StatusBadRequest},
+		{name: "nonexistent dir", body: fmt.Sprintf(`{"dir":%q}`, filepath.Join(dir, "nope")), wantCode: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodPost, "/api/workspace/clean", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			newTestServer().handleWorkspaceClean(w, req)
+
+			if w.Code != tc.wantCode {
+				t.Errorf("expected %d, got %d — body: %s", tc.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+	// Runs after the parallel subtests have finished.
+	t.Cleanup(func() {
+		if _, err := os.Stat(filepath.Join(tfaidir.Path(dir, tfaidir.Trash), "artifact")); err != nil {
+			t.Errorf("rejected requests must not delete anything: %v", err)
+		}
+	})
+}
+
 // TestConfineToDir verifies the path confinement helper used by all handlers
 // and the agent. It must accept valid sub-paths and reject traversal attempts.
 func TestConfineToDir(t *testing.T) {
@@ -487,6 +978,93 @@ func TestConfineToDir(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// resolveWorkspace — shared validation matrix
+// ---------------------------------------------------------------------------
+
+// TestResolveWorkspace verifies every branch of the shared workspace
+// validation helper: the HTTP status and error code must be the same no
+// matter which handler calls it.
+func TestResolveWorkspace(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	inside := filepath.Join(root, "ws")
+	mustMkdir(t, inside)
+	file := filepath.Join(inside, "main.tf")
+	mustWriteFile(t, file, "# tf")
+	outside := t.TempDir()
+
+	tests := []struct {
+		name       string
+		root       string // Config.WorkspaceRoot
+		input      string
+		wantDir    string
+		wantStatus int    // 0 means success
+		wantCode   string // expected workspaceError.code
+	}{
+		{name: "empty", input: "", wantStatus: http.StatusBadRequest, wantCode: errCodeWorkspaceRequired},
+		{name: "relative", input: "relative/path", wantStatus: http.StatusBadRequest, wantCode: errCodeWorkspaceNotAbsolute},
+		{name: "outside root", root: root, input: outside, wantStatus: http.StatusForbidden, wantCode: errCodeWorkspaceOutsideRoot},
+		{name: "traversal out of root", root: root, input: inside + "/../../etc", wantStatus: http.StatusForbidden, wantCode: errCodeWorkspaceOutsideRoot},
+		{name: "nonexistent", input: filepath.Join(root, "missing"), wantStatus: http.StatusNotFound, wantCode: errCodeWorkspaceNotFound},
+		{name: "nonexistent inside root", root: root, input: filepath.Join(root, "missing"), wantStatus: http.StatusNotFound, wantCode: errCodeWorkspaceNotFound},
+		{name: "regular file", input: file, wantStatus: http.StatusBadRequest, wantCode: errCodeWorkspaceNotDirectory},
+		{name: "valid without root", input: inside, wantDir: inside},
+		{name: "valid inside root", root: root, input: inside, wantDir: inside},
+		{name: "unclean path is cleaned", input: inside + "/./sub/..", wantDir: inside},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			s := newTestServerWithRoot(tc.root)
+			dir, wsErr := s.resolveWorkspace(tc.input)
+			if tc.wantStatus == 0 {
+				if wsErr != nil {
+					t.Fatalf("resolveWorkspace(%q) unexpected error: %v", tc.input, wsErr)
+				}
+				if dir != tc.wantDir {
+					t.Errorf("resolveWorkspace(%q) = %q, want %q", tc.input, dir, tc.wantDir)
+				}
+				return
+			}
+			if wsErr == nil {
+				t.Fatalf("resolveWorkspace(%q) expected error, got dir %q", tc.input, dir)
+			}
+			if wsErr.status != tc.wantStatus {
+				t.Errorf("status: expected %d, got %d", tc.wantStatus, wsErr.status)
+			}
+			if wsErr.code != tc.wantCode {
+				t.Errorf("code: expected %q, got %q", tc.wantCode, wsErr.code)
+			}
+		})
+	}
+}
+
+// TestWriteWorkspaceError verifies the JSON shape clients branch on.
+func TestWriteWorkspaceError(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	writeWorkspaceError(w, &workspaceError{http.StatusNotFound, errCodeWorkspaceNotFound, "workspace directory not found"})
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("response is not valid JSON: %v — body: %s", err, w.Body.String())
+	}
+	if body["code"] != errCodeWorkspaceNotFound {
+		t.Errorf("code: expected %q, got %q", errCodeWorkspaceNotFound, body["code"])
+	}
+	if body["error"] == "" {
+		t.Error("error message should not be empty")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // WorkspaceRoot confinement — handler tests
 // ---------------------------------------------------------------------------
@@ -501,7 +1079,7 @@ func newTestServerWithRoot(root string) *Server {
 }
 
 // TestHandleWorkspace_WorkspaceRootConfinement verifies that GET /api/workspace
-// rejects a dir outside WorkspaceRoot with 400 and accepts one inside it.
+// rejects a dir outside WorkspaceRoot with 403 and accepts one inside it.
 func TestHandleWorkspace_WorkspaceRootConfinement(t *testing.T) {
 	t.Parallel()
 
@@ -513,7 +1091,7 @@ func TestHandleWorkspace_WorkspaceRootConfinement(t *testing.T) {
 
 	t.Run("inside root — allowed", func(t *testing.T) {
 		t.Parallel()
-		req := httptest.NewRequest(http.MethodGet, "/api/workspace?dir="+allowed, nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir="+allowed, nil)
 		w := httptest.NewRecorder()
 		s.handleWorkspace(w, req)
 		if w.Code != http.StatusOK {
@@ -523,29 +1101,29 @@ func TestHandleWorkspace_WorkspaceRootConfinement(t *testing.T) {
 
 	t.Run("outside root — rejected", func(t *testing.T) {
 		t.Parallel()
-		req := httptest.NewRequest(http.MethodGet, "/api/workspace?dir=/tmp", nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir=/tmp", nil)
 		w := httptest.NewRecorder()
 		s.handleWorkspace(w, req)
-		if w.Code != http.StatusBadRequest {
-			t.Errorf("expected 400, got %d — body: %s", w.Code, w.Body.String())
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected 403, got %d — body: %s", w.Code, w.Body.String())
 		}
 	})
 
 	t.Run("traversal path — rejected", func(t *testing.T) {
 		t.Parallel()
 		traversal := allowed + "/../../etc"
-		req := httptest.NewRequest(http.MethodGet, "/api/workspace?dir="+traversal, nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir="+traversal, nil)
 		w := httptest.NewRecorder()
 		s.handleWorkspace(w, req)
-		if w.Code != http.StatusBadRequest {
-			t.Errorf("expected 400, got %d — body: %s", w.Code, w.Body.String())
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected 403, got %d — body: %s", w.Code, w.Body.String())
 		}
 	})
 
 	t.Run("no workspace root — no confinement", func(t *testing.T) {
 		t.Parallel()
 		s2 := newTestServer() // WorkspaceRoot is empty
-		req := httptest.NewRequest(http.MethodGet, "/api/workspace?dir="+allowed, nil)
+		req := httptest.NewRequest(http.MethodGet, "/api/workspace?workspaceDir="+allowed, nil)
 		w := httptest.NewRecorder()
 		s2.handleWorkspace(w, req)
 		if w.Code != http.StatusOK {
@@ -584,8 +1162,8 @@ func TestHandleWorkspaceCreate_WorkspaceRootConfinement(t *testing.T) {
 		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		s.handleWorkspaceCreate(w, req)
-		if w.Code != http.StatusBadRequest {
-			t.Errorf("expected 400, got %d — body: %s", w.Code, w.Body.String())
+		if w.Code != http.StatusForbidden {
+			t.Errorf("expected 403, got %d — body: %s", w.Code, w.Body.String())
 		}
 	})
 }
@@ -0,0 +1,162 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultActivityCap is the number of activity entries kept per workspace;
+// recording another prunes the oldest.
+const DefaultActivityCap = 200
+
+// Activity is one file-producing action in a workspace, for its activity
+// feed: "Created EKS module with KMS and IRSA (6 files)". Unlike the
+// conversation history it records only actions that wrote files, whichever
+// entry point ran them.
+type Activity struct {
+	// ID orders the entries of every workspace; larger is newer.
+	ID int64
+	// Workspace is the workspace directory the files were written to.
+	Workspace string
+	// Summary is the summary of the generated file envelope.
+	Summary string
+	// Files lists the workspace-relative paths written, in envelope order.
+	Files []string
+	// Conflicts maps each slash-separated path the user had changed since
+	// tfai last wrote it to how the conflict was resolved: "keep-mine",
+	// "take-generated", or "merge-markers". Nil when there were none.
+	Conflicts map[string]string
+	// RequestID is the HTTP request ID of the action. Empty for the CLI.
+	RequestID string
+	// Model is the model or deployment name that generated the files.
+	Model string
+	// CreatedAt is when the entry was recorded.
+	CreatedAt time.Time
+}
+
+// ActivityRecorder is implemented by stores that can record workspace
+// activity.
+type ActivityRecorder interface {
+	// RecordActivity stores a. ID and CreatedAt are assigned by the store.
+	RecordActivity(ctx context.Context, a Activity) error
+}
+
+// ActivityReader is implemented by stores that can list workspace activity.
+type ActivityReader interface {
+	// Activity returns up to limit entries of the workspace, newest first,
+	// starting below the ID before. Zero before starts at the newest entry.
+	Activity(ctx context.Context, workspaceDir string, before int64, limit int) ([]Activity, error)
+}
+
+// activityDDL creates the workspace activity table. files holds the written
+// paths as a JSON array and conflicts the resolutions as a JSON object.
+const activityDDL = `
+CREATE TABLE IF NOT EXISTS workspace_activity (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace   TEXT    NOT NULL,
+    summary     TEXT    NOT NULL,
+    files       TEXT    NOT NULL,
+    conflicts   TEXT    NOT NULL DEFAULT '{}',
+    request_id  TEXT    NOT NULL DEFAULT '',
+    model       TEXT    NOT NULL DEFAULT '',
+    created_at  INTEGER NOT NULL  -- Unix timestamp (seconds)
+);
+CREATE INDEX IF NOT EXISTS idx_workspace_activity_workspace
+    ON workspace_activity (workspace, id);
+`
+
+// RecordActivity stores a and prunes the workspace's oldest entries beyond
+// the activity cap, in one transaction.
+func (s *SQLiteStore) RecordActivity(ctx context.Context, a Activity) error {
+	files, err := json.Marshal(a.Files)
+	if err != nil {
+		return fmt.Errorf("store: record activity: %w", err)
+	}
+	if a.Files == nil {
+		files = []byte("[]")
+	}
+	conflicts, err := json.Marshal(a.Conflicts)
+	if err != nil {
+		return fmt.Errorf("store: record activity: %w", err)
+	}
+	if a.Conflicts == nil {
+		conflicts = []byte("{}")
+	}
+	createdAt := s.now().Unix()
+	return retryBusy(ctx, func() error {
+		return s.recordActivity(ctx, a, string(files), string(conflicts), createdAt)
+	})
+}
+
+// recordActivity runs one attempt of the RecordActivity transaction.
+func (s *SQLiteStore) recordActivity(ctx context.Context, a Activity, files, conflicts string, createdAt int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("store: record activity: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	const insert = `INSERT INTO workspace_activity (workspace, summary, files, conflicts, request_id, model, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, insert, a.Workspace, a.Summary, files, conflicts, a.RequestID, a.Model, createdAt); err != nil {
+		return fmt.Errorf("store: record activity: %w", err)
+	}
+	// The subquery selects the newest entry past the cap; it and everything
+	// older go. With fewer entries it is NULL and nothing matches.
+	const prune = `
+DELETE FROM workspace_activity
+WHERE  workspace = ? AND id <= (
+    SELECT id FROM workspace_activity WHERE workspace = ?
+    ORDER  BY id DESC LIMIT 1 OFFSET ?
+)`
+	if _, err := tx.ExecContext(ctx, prune, a.Workspace, a.Workspace, s.activityCap); err != nil {
+		return fmt.Errorf("store: record activity: prune: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("store: record activity: %w", err)
+	}
+	return nil
+}
+
+// Activity returns up to limit entries of the workspace, newest first,
+// with IDs below before, or from the newest entry when before is zero.
+func (s *SQLiteStore) Activity(ctx context.Context, workspaceDir string, before int64, limit int) ([]Activity, error) {
+	const q = `
+SELECT id, summary, files, conflicts, request_id, model, created_at
+FROM   workspace_activity
+WHERE  workspace = ? AND (? = 0 OR id < ?)
+ORDER  BY id DESC
+LIMIT  ?`
+
+	rows, err := s.db.QueryContext(ctx, q, workspaceDir, before, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("store: activity: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []Activity
+	for rows.Next() {
+		a := Activity{Workspace: workspaceDir}
+		var files, conflicts string
+		var ts int64
+		if err := rows.Scan(&a.ID, &a.Summary, &files, &conflicts, &a.RequestID, &a.Model, &ts); err != nil {
+			return nil, fmt.Errorf("store: activity scan: %w", err)
+		}
+		if err := json.Unmarshal([]byte(files), &a.Files); err != nil {
+			return nil, fmt.Errorf("store: activity %d: files: %w", a.ID, err)
+		}
+		if err := json.Unmarshal([]byte(conflicts), &a.Conflicts); err != nil {
+			return nil, fmt.Errorf("store: activity %d: conflicts: %w", a.ID, err)
+		}
+		if len(a.Conflicts) == 0 {
+			a.Conflicts = nil
+		}
+		a.CreatedAt = time.Unix(ts, 0)
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: activity rows: %w", err)
+	}
+	return out, nil
+}
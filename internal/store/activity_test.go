@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+	"time"
+)
+
+// summaries returns the summaries of entries, in order.
+func summaries(entries []Activity) string {
+	out := make([]string, 0, len(entries))
+	for _, a := range entries {
+		out = append(out, a.Summary)
+	}
+	return strings.Join(out, ",")
+}
+
+func Test_Store_RecordActivity(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	at := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return at }
+	want := Activity{
+		Workspace: "/ws/a",
+		Summary:   "Created EKS module with KMS and IRSA",
+		Files:     []string{"main.tf", "variables.tf"},
+		Conflicts: map[string]string{"variables.tf": "merge-markers", "outputs.tf": "keep-mine"},
+		RequestID: "req-1",
+		Model:     "gpt-4o",
+	}
+	if err := s.RecordActivity(ctx, want); err != nil {
+		t.Fatalf("record activity: %v", err)
+	}
+	if err := s.RecordActivity(ctx, Activity{Workspace: "/ws/b", Summary: "other"}); err != nil {
+		t.Fatalf("record activity: %v", err)
+	}
+
+	got, err := s.Activity(ctx, "/ws/a", 0, 10)
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("want 1 entry for /ws/a, got %+v", got)
+	}
+	a := got[0]
+	if a.ID == 0 || a.Summary != want.Summary || a.RequestID != "req-1" || a.Model != "gpt-4o" || !a.CreatedAt.Equal(at) {
+		t.Errorf("unexpected entry: %+v", a)
+	}
+	if strings.Join(a.Files, ",") != "main.tf,variables.tf" {
+		t.Errorf("want files main.tf,variables.tf, got %v", a.Files)
+	}
+	if !maps.Equal(a.Conflicts, want.Conflicts) {
+		t.Errorf("want conflicts %v, got %v", want.Conflicts, a.Conflicts)
+	}
+
+	other, err := s.Activity(ctx, "/ws/b", 0, 10)
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+	if len(other) != 1 || other[0].Files == nil || len(other[0].Files) != 0 || other[0].Conflicts != nil {
+		t.Errorf("want one entry with an empty file list and no conflicts for /ws/b, got %+v", other)
+	}
+}
+
+func Test_Store_ActivityPagination(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		if err := s.RecordActivity(ctx, Activity{Workspace: "/ws/a", Summary: fmt.Sprintf("a%d", i)}); err != nil {
+			t.Fatalf("record activity: %v", err)
+		}
+	}
+
+	page1, err := s.Activity(ctx, "/ws/a", 0, 2)
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+	if got := summaries(page1); got != "a5,a4" {
+		t.Fatalf("want newest first a5,a4, got %s", got)
+	}
+	page2, err := s.Activity(ctx, "/ws/a", page1[1].ID, 2)
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+	if got := summaries(page2); got != "a3,a2" {
+		t.Errorf("want a3,a2 after a4, got %s", got)
+	}
+	page3, err := s.Activity(ctx, "/ws/a", page2[1].ID, 2)
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+	if got := summaries(page3); got != "a1" {
+		t.Errorf("want a1 last, got %s", got)
+	}
+}
+
+func Test_Store_ActivityPruning(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	s.activityCap = 3
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		if err := s.RecordActivity(ctx, Activity{Workspace: "/ws/a", Summary: fmt.Sprintf("a%d", i)}); err != nil {
+			t.Fatalf("record activity: %v", err)
+		}
+	}
+	if err := s.RecordActivity(ctx, Activity{Workspace: "/ws/b", Summary: "b1"}); err != nil {
+		t.Fatalf("record activity: %v", err)
+	}
+
+	got, err := s.Activity(ctx, "/ws/a", 0, 10)
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+	if sum := summaries(got); sum != "a5,a4,a3" {
+		t.Errorf("want the 3 newest entries kept, got %s", sum)
+	}
+	other, err := s.Activity(ctx, "/ws/b", 0, 10)
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+	if len(other) != 1 {
+		t.Errorf("want pruning to leave /ws/b alone, got %+v", other)
+	}
+}
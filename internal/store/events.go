@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+// Kind distinguishes conversation messages from the event notes stored
+// alongside them.
+type Kind string
+
+const (
+	// KindMessage is a user or assistant message.
+	KindMessage Kind = "message"
+	// KindFilesWritten records that the assistant wrote files. Content is
+	// the newline-separated list of workspace-relative paths.
+	KindFilesWritten Kind = "files_written"
+	// KindToolRun records that the assistant ran a tool. Content is
+	// "<tool>: <outcome>", e.g. "terraform_plan: ok". Tool output is never
+	// stored.
+	KindToolRun Kind = "tool_run"
+	// KindDisclosure records the label shown with the assistant message
+	// before it to mark it as AI-generated. Content is the label text. It is
+	// never replayed to the model.
+	KindDisclosure Kind = "disclosure"
+	// KindTruncated records that the assistant message before it was cut
+	// off at the output token limit. Content is the number of continuations
+	// the response has had so far, "0" for the first part. It is never
+	// replayed to the model.
+	KindTruncated Kind = "truncated"
+	// KindContinued records that the assistant message before it continues
+	// the previous one, which was cut off; the two are one response. Content
+	// is the continuation's number, from "1". It is never replayed to the
+	// model.
+	KindContinued Kind = "continued"
+)
+
+// EventRecorder is implemented by stores that can persist event notes in a
+// conversation. Events are returned by Recent, in order, with their Kind
+// set; the agent renders them into replayed history.
+type EventRecorder interface {
+	// AppendEvent persists an assistant event note in the given session of
+	// the workspace.
+	AppendEvent(ctx context.Context, workspaceDir, sessionID string, kind Kind, content string) error
+}
+
+// AppendEvent persists an assistant event note in the given session of the
+// workspace, in the turn set by WithTurn.
+func (s *SQLiteStore) AppendEvent(ctx context.Context, workspaceDir, sessionID string, kind Kind, content string) error {
+	const q = `INSERT INTO conversations (workspace, session_id, turn_id, role, kind, content, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	turn, err := turnFrom(ctx)
+	if err != nil {
+		return err
+	}
+	createdAt := s.now().Unix()
+	err = retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, q, workspaceDir, sessionID, turn, string(RoleAssistant), string(kind), content, createdAt)
+		return err //nolint:wrapcheck // wrapped below
+	})
+	if err != nil {
+		return fmt.Errorf("store: append event: %w", err)
+	}
+	return nil
+}
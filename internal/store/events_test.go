@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Store_EventsInRecent(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	if err := s.Append(ctx, "/ws/a", "", RoleUser, "create a vpc"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := s.AppendEvent(ctx, "/ws/a", "", KindToolRun, "terraform_validate: ok"); err != nil {
+		t.Fatalf("append event: %v", err)
+	}
+	if err := s.AppendEvent(ctx, "/ws/a", "", KindFilesWritten, "main.tf\nvariables.tf"); err != nil {
+		t.Fatalf("append event: %v", err)
+	}
+	if err := s.AppendWithUsage(ctx, "/ws/a", "", RoleAssistant, "Wrote 2 files.", Usage{Provider: "openai", Model: "gpt-4o"}); err != nil {
+		t.Fatalf("append with usage: %v", err)
+	}
+
+	msgs, err := s.Recent(ctx, "/ws/a", "", 10)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	want := []Kind{KindMessage, KindToolRun, KindFilesWritten, KindMessage}
+	if len(msgs) != len(want) {
+		t.Fatalf("want %d rows, got %d", len(want), len(msgs))
+	}
+	for i, k := range want {
+		if msgs[i].Kind != k {
+			t.Errorf("row %d: want kind %s, got %s", i, k, msgs[i].Kind)
+		}
+	}
+	if msgs[2].Role != RoleAssistant || msgs[2].Content != "main.tf\nvariables.tf" {
+		t.Errorf("files_written row: got %+v", msgs[2])
+	}
+
+	records, err := s.UsageRecords(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("usage records: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("want event rows excluded from usage records, got %d records", len(records))
+	}
+}
+
+func Test_Store_MigratesOldSchema(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	// A database written before event rows and sessions existed.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const oldDDL = `
+CREATE TABLE conversations (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace    TEXT    NOT NULL,
+    role         TEXT    NOT NULL CHECK(role IN ('user','assistant')),
+    content      TEXT    NOT NULL,
+    created_at   INTEGER NOT NULL
+);
+INSERT INTO conversations (workspace, role, content, created_at) VALUES ('/ws/old', 'user', 'hello', 1);`
+	if _, err := db.ExecContext(ctx, oldDDL); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	s, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	msgs, err := s.Recent(ctx, "/ws/old", "", 10)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Kind != KindMessage || msgs[0].Content != "hello" {
+		t.Errorf("want the old row as a message of the default thread, got %+v", msgs)
+	}
+	if err := s.AppendEvent(ctx, "/ws/old", "", KindToolRun, "terraform_plan: ok"); err != nil {
+		t.Errorf("append event after migration: %v", err)
+	}
+}
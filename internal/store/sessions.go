@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultSession is the session ID of a workspace's default thread: the
+// messages sent without a session, which is every message stored before
+// sessions existed.
+const DefaultSession = ""
+
+// ErrSessionNotFound is returned by Session when the workspace has no
+// session with the given ID.
+var ErrSessionNotFound = errors.New("store: session not found")
+
+// Session is a conversation thread of a workspace, separate from the
+// workspace's default thread and from its other sessions.
+type Session struct {
+	// ID identifies the session in Append and Recent calls.
+	ID string
+	// Workspace is the workspace directory the session belongs to.
+	Workspace string
+	// CreatedAt is when the session was created.
+	CreatedAt time.Time
+}
+
+// SessionStore is implemented by stores that can create sessions and check
+// that a session ID belongs to a workspace.
+type SessionStore interface {
+	// CreateSession starts a new, empty session for the workspace.
+	CreateSession(ctx context.Context, workspaceDir string) (Session, error)
+	// Session returns the workspace's session with the given ID, or an
+	// error wrapping ErrSessionNotFound.
+	Session(ctx context.Context, workspaceDir, id string) (Session, error)
+}
+
+// sessionsDDL creates the sessions table. Messages reference a session by
+// conversations.session_id; the default thread has no row here.
+const sessionsDDL = `
+CREATE TABLE IF NOT EXISTS sessions (
+    id          TEXT    PRIMARY KEY,
+    workspace   TEXT    NOT NULL,
+    created_at  INTEGER NOT NULL  -- Unix timestamp (seconds)
+);
+`
+
+// CreateSession starts a new session for the workspace with a random ID.
+func (s *SQLiteStore) CreateSession(ctx context.Context, workspaceDir string) (Session, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return Session{}, fmt.Errorf("store: create session: %w", err)
+	}
+	sess := Session{ID: hex.EncodeToString(b), Workspace: workspaceDir, CreatedAt: time.Unix(s.now().Unix(), 0)}
+
+	const q = `INSERT INTO sessions (id, workspace, created_at) VALUES (?, ?, ?)`
+	err := retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, q, sess.ID, sess.Workspace, sess.CreatedAt.Unix())
+		return err //nolint:wrapcheck // wrapped below
+	})
+	if err != nil {
+		return Session{}, fmt.Errorf("store: create session: %w", err)
+	}
+	return sess, nil
+}
+
+// Session returns the workspace's session with the given ID. A session of
+// another workspace is reported as not found.
+func (s *SQLiteStore) Session(ctx context.Context, workspaceDir, id string) (Session, error) {
+	const q = `SELECT created_at FROM sessions WHERE id = ? AND workspace = ?`
+	var ts int64
+	err := retryBusy(ctx, func() error {
+		return s.db.QueryRowContext(ctx, q, id, workspaceDir).Scan(&ts) //nolint:wrapcheck // wrapped below
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, fmt.Errorf("%w: %q", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return Session{}, fmt.Errorf("store: session: %w", err)
+	}
+	return Session{ID: id, Workspace: workspaceDir, CreatedAt: time.Unix(ts, 0)}, nil
+}
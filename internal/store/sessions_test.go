@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func Test_Store_CreateSession(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	a, err := s.CreateSession(ctx, "/ws/a")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	b, err := s.CreateSession(ctx, "/ws/a")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if a.ID == "" || a.ID == b.ID {
+		t.Errorf("want distinct non-empty IDs, got %q and %q", a.ID, b.ID)
+	}
+
+	got, err := s.Session(ctx, "/ws/a", a.ID)
+	if err != nil {
+		t.Fatalf("session: %v", err)
+	}
+	if got.ID != a.ID || got.Workspace != "/ws/a" || !got.CreatedAt.Equal(a.CreatedAt) {
+		t.Errorf("want %+v, got %+v", a, got)
+	}
+
+	if _, err := s.Session(ctx, "/ws/b", a.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("want ErrSessionNotFound for another workspace, got %v", err)
+	}
+	if _, err := s.Session(ctx, "/ws/a", "nope"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("want ErrSessionNotFound for an unknown ID, got %v", err)
+	}
+}
+
+func Test_Store_SessionsAreIsolated(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	a, err := s.CreateSession(ctx, "/ws/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.CreateSession(ctx, "/ws/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two sessions and the default thread write to the same workspace
+	// concurrently.
+	threads := map[string]string{DefaultSession: "default", a.ID: "task a", b.ID: "task b"}
+	var wg sync.WaitGroup
+	for id, label := range threads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 5; i++ {
+				if err := s.Append(ctx, "/ws/a", id, RoleUser, label); err != nil {
+					t.Errorf("append: %v", err)
+				}
+				if err := s.AppendEvent(ctx, "/ws/a", id, KindToolRun, label); err != nil {
+					t.Errorf("append event: %v", err)
+				}
+				if err := s.AppendWithUsage(ctx, "/ws/a", id, RoleAssistant, label, Usage{Provider: "openai", Model: "gpt-4o"}); err != nil {
+					t.Errorf("append with usage: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for id, label := range threads {
+		msgs, err := s.Recent(ctx, "/ws/a", id, 100)
+		if err != nil {
+			t.Fatalf("recent: %v", err)
+		}
+		if len(msgs) != 15 {
+			t.Errorf("%s: want 15 rows, got %d", label, len(msgs))
+		}
+		for _, m := range msgs {
+			if m.Content != label {
+				t.Errorf("%s: saw a row of another thread: %q", label, m.Content)
+			}
+		}
+	}
+
+	n, err := s.Clear(ctx, "/ws/a")
+	if err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if n != 45 {
+		t.Errorf("want Clear to delete every session's rows, got %d", n)
+	}
+	if _, err := s.Session(ctx, "/ws/a", a.ID); err != nil {
+		t.Errorf("want the session to survive Clear, got %v", err)
+	}
+}
@@ -1,18 +1,25 @@
 // Package store provides a SQLite-backed conversation history store for the
-// TF-AI agent. Each workspace directory has its own conversation thread.
-// Messages are persisted across server restarts and injected into the LLM
-// context window on subsequent queries.
+// TF-AI agent. Each workspace directory has a default conversation thread
+// and any number of sessions, each a thread of its own. Messages are
+// persisted across server restarts and injected into the LLM context window
+// on subsequent queries.
 package store
 
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
-	_ "modernc.org/sqlite" // register "sqlite" driver
+	"modernc.org/sqlite" // also registers the "sqlite" driver
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/54b3r/tfai-go/internal/logging"
 )
 
 // Role identifies the author of a conversation message.
@@ -29,21 +36,38 @@ const (
 type Message struct {
 	// Role is the author of the message.
 	Role Role
+	// Kind is KindMessage for messages, or the kind of an event note.
+	Kind Kind
 	// Content is the text of the message.
 	Content string
 	// CreatedAt is when the message was persisted.
 	CreatedAt time.Time
+	// Timings is where the time of the query that produced an assistant
+	// message went. Nil when none were stored.
+	Timings *Timings
+	// TurnID groups the rows of one query: its user message, event notes,
+	// and assistant reply (see WithTurn).
+	TurnID string
 }
 
 // ConversationStore persists and retrieves conversation history keyed by
-// workspace directory. Implementations must be safe for concurrent use.
+// workspace directory and session. The session ID DefaultSession selects the
+// workspace's default thread. Implementations must be safe for concurrent
+// use.
 type ConversationStore interface {
-	// Append persists a single message for the given workspace.
-	Append(ctx context.Context, workspaceDir string, role Role, content string) error
-	// Recent returns the most recent n messages for the workspace, ordered
+	// Append persists a single message in the given session of the workspace.
+	Append(ctx context.Context, workspaceDir, sessionID string, role Role, content string) error
+	// Recent returns the most recent n messages of the session, ordered
 	// oldest-first so they can be prepended to the LLM message slice directly.
-	// If fewer than n messages exist, all are returned.
-	Recent(ctx context.Context, workspaceDir string, n int) ([]Message, error)
+	// If fewer than n messages exist, all are returned. Event notes written
+	// through EventRecorder are included and count towards n. Messages are
+	// returned in whole turns (see WithTurn), ordered by when each turn's
+	// last message was written, so a turn that does not fit in n is left out
+	// rather than split.
+	Recent(ctx context.Context, workspaceDir, sessionID string, n int) ([]Message, error)
+	// Clear deletes every message of the workspace, in every session, and
+	// returns how many were deleted. Other workspaces are untouched.
+	Clear(ctx context.Context, workspaceDir string) (int64, error)
 	// Close releases any resources held by the store.
 	Close() error
 }
@@ -52,6 +76,11 @@ type ConversationStore interface {
 type SQLiteStore struct {
 	// db is the underlying database connection pool.
 	db *sql.DB
+	// now returns the timestamp stored with new messages. Tests override it
+	// to seed history across several days.
+	now func() time.Time
+	// activityCap is the number of activity entries kept per workspace.
+	activityCap int
 }
 
 // DefaultDBPath returns the default path for the conversation history database.
@@ -70,24 +99,95 @@ func DefaultDBPath() (string, error) {
 
 // Open opens (or creates) a SQLiteStore at the given path and runs the schema
 // migration. Use ":memory:" for an in-memory database in tests.
+//
+// The CLI and a running server may open the same file at once, so every
+// connection enables WAL and a busy timeout, and writes retry briefly on
+// SQLITE_BUSY (see retryBusy).
 func Open(ctx context.Context, path string) (*SQLiteStore, error) {
-	// WAL mode improves concurrent read performance and is safe for single-host use.
-	dsn := path + "?_journal_mode=WAL&_busy_timeout=5000"
-	db, err := sql.Open("sqlite", dsn)
+	db, err := sql.Open("sqlite", path+"?"+dsnParams)
 	if err != nil {
 		return nil, fmt.Errorf("store: open %s: %w", path, err)
 	}
-	// Limit to a single writer connection to avoid SQLITE_BUSY under concurrent writes.
+	// Limit to a single connection per process so writes from this store
+	// are serialised here rather than contending inside SQLite.
 	db.SetMaxOpenConns(1)
 
-	s := &SQLiteStore{db: db}
+	s := &SQLiteStore{db: db, now: time.Now, activityCap: DefaultActivityCap}
 	if err := s.migrate(ctx); err != nil {
 		_ = db.Close()
 		return nil, err
 	}
+
+	mode, err := s.journalMode(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	log := logging.FromContext(ctx)
+	if mode != "wal" && path != ":memory:" {
+		log.Warn("store: WAL journal mode not active; concurrent access may see SQLITE_BUSY",
+			slog.String("path", path), slog.String("journal_mode", mode))
+	} else {
+		log.Debug("store: opened", slog.String("path", path), slog.String("journal_mode", mode))
+	}
 	return s, nil
 }
 
+// dsnParams configures every connection. modernc.org/sqlite only applies
+// pragmas passed as _pragma=name(value); the mattn-style _journal_mode and
+// _busy_timeout keys are silently ignored. _txlock=immediate takes the write
+// lock at BEGIN so a transaction never fails mid-way on lock upgrade.
+const dsnParams = "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+
+// journalMode reports the journal mode in effect, e.g. "wal".
+func (s *SQLiteStore) journalMode(ctx context.Context) (string, error) {
+	var mode string
+	if err := s.db.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil {
+		return "", fmt.Errorf("store: journal mode: %w", err)
+	}
+	return strings.ToLower(mode), nil
+}
+
+// Busy retry policy. busy_timeout already waits inside SQLite; these retries
+// cover the cases it does not, such as a WAL snapshot conflict or another
+// process holding the lock past the timeout.
+const (
+	// busyRetries is the number of retries after the first attempt.
+	busyRetries = 4
+	// busyBackoff is the delay before the first retry; it doubles each time.
+	busyBackoff = 25 * time.Millisecond
+)
+
+// retryBusy runs op, retrying with exponential backoff while it fails with
+// SQLITE_BUSY or SQLITE_LOCKED. Other errors and context cancellation end
+// the loop immediately.
+func retryBusy(ctx context.Context, op func() error) error {
+	backoff := busyBackoff
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt == busyRetries || !isBusy(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// isBusy reports whether err is a SQLite busy or locked error, including
+// extended result codes such as SQLITE_BUSY_SNAPSHOT.
+func isBusy(err error) bool {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return false
+	}
+	code := se.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
 // migrate creates the schema if it does not already exist.
 func (s *SQLiteStore) migrate(ctx context.Context) error {
 	const ddl = `
@@ -95,61 +195,188 @@ CREATE TABLE IF NOT EXISTS conversations (
     id           INTEGER PRIMARY KEY AUTOINCREMENT,
     workspace    TEXT    NOT NULL,
     role         TEXT    NOT NULL CHECK(role IN ('user','assistant')),
+    kind         TEXT    NOT NULL DEFAULT 'message',
+    session_id   TEXT    NOT NULL DEFAULT '',
+    turn_id      TEXT    NOT NULL DEFAULT '',
     content      TEXT    NOT NULL,
     created_at   INTEGER NOT NULL  -- Unix timestamp (seconds)
 );
 CREATE INDEX IF NOT EXISTS idx_conversations_workspace_created
     ON conversations (workspace, created_at);
 `
-	if _, err := s.db.ExecContext(ctx, ddl); err != nil {
+	if _, err := s.db.ExecContext(ctx, ddl+usageDDL+timingsDDL+sessionsDDL+activityDDL+summariesDDL); err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	if err := s.addColumn(ctx, "conversations", "kind", "TEXT NOT NULL DEFAULT 'message'"); err != nil {
+		return err
+	}
+	if err := s.addColumn(ctx, "conversations", "session_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(ctx, "conversations", "turn_id", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := s.addColumn(ctx, "workspace_activity", "conflicts", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+		return err
+	}
+	// Created after addColumn: older databases have no session_id or
+	// turn_id until then.
+	const indexes = `
+CREATE INDEX IF NOT EXISTS idx_conversations_session_created
+    ON conversations (workspace, session_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_conversations_session_turn
+    ON conversations (workspace, session_id, turn_id);
+`
+	if _, err := s.db.ExecContext(ctx, indexes); err != nil {
+		return fmt.Errorf("store: migrate: %w", err)
+	}
+	return s.backfillTurns(ctx)
+}
+
+// addColumn adds a column to a table created by an older release, which
+// CREATE TABLE IF NOT EXISTS leaves unchanged. Existing rows get the
+// column's default.
+func (s *SQLiteStore) addColumn(ctx context.Context, table, column, decl string) error {
+	var n int
+	const q = `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+	if err := s.db.QueryRowContext(ctx, q, table, column).Scan(&n); err != nil {
 		return fmt.Errorf("store: migrate: %w", err)
 	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := s.db.ExecContext(ctx, "ALTER TABLE "+table+" ADD COLUMN "+column+" "+decl); err != nil {
+		return fmt.Errorf("store: migrate: add %s.%s: %w", table, column, err)
+	}
 	return nil
 }
 
-// Append persists a single message for the given workspace.
-func (s *SQLiteStore) Append(ctx context.Context, workspaceDir string, role Role, content string) error {
-	const q = `INSERT INTO conversations (workspace, role, content, created_at) VALUES (?, ?, ?, ?)`
-	if _, err := s.db.ExecContext(ctx, q, workspaceDir, string(role), content, time.Now().Unix()); err != nil {
+// Append persists a single message in the given session of the workspace,
+// in the turn set by WithTurn.
+func (s *SQLiteStore) Append(ctx context.Context, workspaceDir, sessionID string, role Role, content string) error {
+	const q = `INSERT INTO conversations (workspace, session_id, turn_id, role, content, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	turn, err := turnFrom(ctx)
+	if err != nil {
+		return err
+	}
+	createdAt := s.now().Unix()
+	err = retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, q, workspaceDir, sessionID, turn, string(role), content, createdAt)
+		return err //nolint:wrapcheck // wrapped below
+	})
+	if err != nil {
 		return fmt.Errorf("store: append: %w", err)
 	}
 	return nil
 }
 
-// Recent returns the most recent n messages for the workspace, ordered
-// oldest-first. Uses a subquery to select the tail then re-order for injection.
-func (s *SQLiteStore) Recent(ctx context.Context, workspaceDir string, n int) ([]Message, error) {
+// Recent returns the most recent whole turns of the session holding at most
+// n messages and event notes, ordered oldest-first: turns by when their
+// last row was written, rows within a turn in the order they were written.
+// The turns table sums the rows of the newer turns to find the tail, which
+// is then re-ordered for injection.
+func (s *SQLiteStore) Recent(ctx context.Context, workspaceDir, sessionID string, n int) ([]Message, error) {
 	const q = `
-SELECT role, content, created_at FROM (
-    SELECT id, role, content, created_at
+WITH turns AS (
+    SELECT turn_id, MAX(created_at) AS done_at, MAX(id) AS last_id,
+           SUM(COUNT(*)) OVER (ORDER BY MAX(created_at) DESC, MAX(id) DESC) AS running
     FROM   conversations
-    WHERE  workspace = ?
-    ORDER  BY created_at DESC, id DESC
-    LIMIT  ?
-) ORDER BY created_at ASC, id ASC`
+    WHERE  workspace = ? AND session_id = ?
+    GROUP  BY turn_id
+)
+SELECT c.turn_id, c.role, c.kind, c.content, c.created_at,
+       t.context_ms, t.first_token_ms, t.model_ms, t.tools_ms, t.tool_calls, t.parse_ms, t.apply_ms, t.total_ms
+FROM   turns
+JOIN   conversations c ON c.workspace = ? AND c.session_id = ? AND c.turn_id = turns.turn_id
+LEFT JOIN message_timings t ON t.message_id = c.id
+WHERE  turns.running <= ?
+ORDER BY turns.done_at ASC, turns.last_id ASC, c.id ASC`
 
-	rows, err := s.db.QueryContext(ctx, q, workspaceDir, n)
+	var msgs []Message
+	err := retryBusy(ctx, func() error {
+		msgs = nil
+		return s.recent(ctx, q, &msgs, workspaceDir, sessionID, workspaceDir, sessionID, n)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("store: recent: %w", err)
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// recent runs one attempt of the Recent query, appending results to msgs.
+func (s *SQLiteStore) recent(ctx context.Context, q string, msgs *[]Message, args ...any) error {
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("store: recent: %w", err)
 	}
 	defer func() { _ = rows.Close() }()
 
-	var msgs []Message
 	for rows.Next() {
 		var m Message
 		var ts int64
-		var role string
-		if err := rows.Scan(&role, &m.Content, &ts); err != nil {
-			return nil, fmt.Errorf("store: recent scan: %w", err)
+		var role, kind string
+		var nt nullTimings
+		if err := rows.Scan(append([]any{&m.TurnID, &role, &kind, &m.Content, &ts}, nt.dest()...)...); err != nil {
+			return fmt.Errorf("store: recent scan: %w", err)
 		}
-		m.Role = Role(role)
+		m.Role, m.Kind = Role(role), Kind(kind)
 		m.CreatedAt = time.Unix(ts, 0)
-		msgs = append(msgs, m)
+		m.Timings = nt.timings()
+		*msgs = append(*msgs, m)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("store: recent rows: %w", err)
+		return fmt.Errorf("store: recent rows: %w", err)
 	}
-	return msgs, nil
+	return nil
+}
+
+// Clear deletes every message of the workspace, with its usage metadata
+// and history summaries, and returns the number of messages deleted.
+// Sessions created for the workspace remain valid and start out empty.
+func (s *SQLiteStore) Clear(ctx context.Context, workspaceDir string) (int64, error) {
+	var n int64
+	err := retryBusy(ctx, func() error {
+		var err error
+		n, err = s.clear(ctx, workspaceDir)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// clear runs one attempt of the Clear transaction. Foreign keys are not
+// enforced, so usage and timings rows are deleted explicitly rather than by
+// cascade.
+func (s *SQLiteStore) clear(ctx context.Context, workspaceDir string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("store: clear: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range []string{"message_usage", "message_timings"} {
+		q := `DELETE FROM ` + table + ` WHERE message_id IN (SELECT id FROM conversations WHERE workspace = ?)`
+		if _, err := tx.ExecContext(ctx, q, workspaceDir); err != nil {
+			return 0, fmt.Errorf("store: clear: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM history_summaries WHERE workspace = ?`, workspaceDir); err != nil {
+		return 0, fmt.Errorf("store: clear: %w", err)
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM conversations WHERE workspace = ?`, workspaceDir)
+	if err != nil {
+		return 0, fmt.Errorf("store: clear: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("store: clear: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("store: clear: %w", err)
+	}
+	return n, nil
 }
 
 // Close releases the database connection pool.
@@ -2,7 +2,13 @@ package store
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 )
 
 // openTestStore opens an in-memory SQLiteStore for use in tests.
@@ -21,14 +27,14 @@ func Test_Store_AppendAndRecent(t *testing.T) {
 	s := openTestStore(t)
 	ctx := context.Background()
 
-	if err := s.Append(ctx, "/ws/a", RoleUser, "hello"); err != nil {
+	if err := s.Append(ctx, "/ws/a", "", RoleUser, "hello"); err != nil {
 		t.Fatalf("append user: %v", err)
 	}
-	if err := s.Append(ctx, "/ws/a", RoleAssistant, "world"); err != nil {
+	if err := s.Append(ctx, "/ws/a", "", RoleAssistant, "world"); err != nil {
 		t.Fatalf("append assistant: %v", err)
 	}
 
-	msgs, err := s.Recent(ctx, "/ws/a", 10)
+	msgs, err := s.Recent(ctx, "/ws/a", "", 10)
 	if err != nil {
 		t.Fatalf("recent: %v", err)
 	}
@@ -53,12 +59,12 @@ func Test_Store_RecentLimitRespected(t *testing.T) {
 		if i%2 == 1 {
 			role = RoleAssistant
 		}
-		if err := s.Append(ctx, "/ws/b", role, "msg"); err != nil {
+		if err := s.Append(ctx, "/ws/b", "", role, "msg"); err != nil {
 			t.Fatalf("append: %v", err)
 		}
 	}
 
-	msgs, err := s.Recent(ctx, "/ws/b", 4)
+	msgs, err := s.Recent(ctx, "/ws/b", "", 4)
 	if err != nil {
 		t.Fatalf("recent: %v", err)
 	}
@@ -72,18 +78,18 @@ func Test_Store_WorkspaceIsolation(t *testing.T) {
 	s := openTestStore(t)
 	ctx := context.Background()
 
-	if err := s.Append(ctx, "/ws/x", RoleUser, "from x"); err != nil {
+	if err := s.Append(ctx, "/ws/x", "", RoleUser, "from x"); err != nil {
 		t.Fatalf("append x: %v", err)
 	}
-	if err := s.Append(ctx, "/ws/y", RoleUser, "from y"); err != nil {
+	if err := s.Append(ctx, "/ws/y", "", RoleUser, "from y"); err != nil {
 		t.Fatalf("append y: %v", err)
 	}
 
-	msgsX, err := s.Recent(ctx, "/ws/x", 10)
+	msgsX, err := s.Recent(ctx, "/ws/x", "", 10)
 	if err != nil {
 		t.Fatalf("recent x: %v", err)
 	}
-	msgsY, err := s.Recent(ctx, "/ws/y", 10)
+	msgsY, err := s.Recent(ctx, "/ws/y", "", 10)
 	if err != nil {
 		t.Fatalf("recent y: %v", err)
 	}
@@ -96,12 +102,54 @@ func Test_Store_WorkspaceIsolation(t *testing.T) {
 	}
 }
 
+func Test_Store_Clear(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	if err := s.Append(ctx, "/ws/x", "", RoleUser, "question"); err != nil {
+		t.Fatalf("append x: %v", err)
+	}
+	u := Usage{Provider: "openai", Model: "gpt-4o", PromptTokens: 10, CompletionTokens: 5}
+	if err := s.AppendWithUsage(ctx, "/ws/x", "", RoleAssistant, "answer", u); err != nil {
+		t.Fatalf("append x with usage: %v", err)
+	}
+	if err := s.AppendWithUsage(ctx, "/ws/y", "", RoleAssistant, "from y", u); err != nil {
+		t.Fatalf("append y: %v", err)
+	}
+
+	n, err := s.Clear(ctx, "/ws/x")
+	if err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("want 2 deleted, got %d", n)
+	}
+	if msgs, _ := s.Recent(ctx, "/ws/x", "", 10); len(msgs) != 0 {
+		t.Errorf("want workspace x empty, got %v", msgs)
+	}
+	if msgs, _ := s.Recent(ctx, "/ws/y", "", 10); len(msgs) != 1 || msgs[0].Content != "from y" {
+		t.Errorf("want workspace y untouched, got %v", msgs)
+	}
+	var usageRows int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM message_usage").Scan(&usageRows); err != nil {
+		t.Fatalf("count usage: %v", err)
+	}
+	if usageRows != 1 {
+		t.Errorf("want only workspace y's usage row left, got %d", usageRows)
+	}
+
+	if n, err := s.Clear(ctx, "/ws/x"); err != nil || n != 0 {
+		t.Errorf("clearing an empty workspace: want 0, nil; got %d, %v", n, err)
+	}
+}
+
 func Test_Store_EmptyWorkspaceReturnsNil(t *testing.T) {
 	t.Parallel()
 	s := openTestStore(t)
 	ctx := context.Background()
 
-	msgs, err := s.Recent(ctx, "/ws/empty", 10)
+	msgs, err := s.Recent(ctx, "/ws/empty", "", 10)
 	if err != nil {
 		t.Fatalf("recent empty: %v", err)
 	}
@@ -117,12 +165,12 @@ func Test_Store_OldestFirstOrdering(t *testing.T) {
 
 	contents := []string{"first", "second", "third"}
 	for _, c := range contents {
-		if err := s.Append(ctx, "/ws/order", RoleUser, c); err != nil {
+		if err := s.Append(ctx, "/ws/order", "", RoleUser, c); err != nil {
 			t.Fatalf("append: %v", err)
 		}
 	}
 
-	msgs, err := s.Recent(ctx, "/ws/order", 10)
+	msgs, err := s.Recent(ctx, "/ws/order", "", 10)
 	if err != nil {
 		t.Fatalf("recent: %v", err)
 	}
@@ -132,3 +180,163 @@ func Test_Store_OldestFirstOrdering(t *testing.T) {
 		}
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Concurrent access from several processes sharing one database file
+// ---------------------------------------------------------------------------
+
+func Test_Store_WALModeActive(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	s, err := Open(ctx, filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	mode, err := s.journalMode(ctx)
+	if err != nil {
+		t.Fatalf("journal mode: %v", err)
+	}
+	if mode != "wal" {
+		t.Errorf("want journal_mode=wal, got %q", mode)
+	}
+	var timeout int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		t.Fatalf("busy_timeout: %v", err)
+	}
+	if timeout != 5000 {
+		t.Errorf("want busy_timeout=5000, got %d", timeout)
+	}
+}
+
+// Test_Store_ConcurrentStoresShareFile opens two stores on one file, the way
+// `tfai` in a terminal and `tfai serve` share ~/.tfai/history.db, and
+// interleaves writes and reads from both. Run with -race.
+func Test_Store_ConcurrentStoresShareFile(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	stores := make([]*SQLiteStore, 2)
+	for i := range stores {
+		s, err := Open(ctx, path)
+		if err != nil {
+			t.Fatalf("open store %d: %v", i, err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		stores[i] = s
+	}
+
+	const workers, perWorker = 4, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers*perWorker)
+	for i := 0; i < 2*workers; i++ {
+		s := stores[i%2]
+		ws := fmt.Sprintf("/ws/%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				var err error
+				if j%3 == 0 {
+					err = s.AppendWithUsage(ctx, ws, "", RoleAssistant, "a", Usage{Provider: "openai", Model: "m", PromptTokens: 1})
+				} else {
+					err = s.Append(ctx, ws, "", RoleUser, "u")
+				}
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if _, err := s.Recent(ctx, ws, "", 10); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent access: %v", err)
+	}
+
+	for i := 0; i < 2*workers; i++ {
+		msgs, err := stores[(i+1)%2].Recent(ctx, fmt.Sprintf("/ws/%d", i), "", 2*perWorker)
+		if err != nil {
+			t.Fatalf("recent: %v", err)
+		}
+		if len(msgs) != perWorker {
+			t.Errorf("/ws/%d: want %d messages visible from the other store, got %d", i, perWorker, len(msgs))
+		}
+	}
+}
+
+func Test_Store_RetryBusy(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	s, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	// A second handle without busy_timeout fails immediately while the
+	// write lock is held, producing a genuine SQLITE_BUSY.
+	raw, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(0)")
+	if err != nil {
+		t.Fatalf("open raw: %v", err)
+	}
+	t.Cleanup(func() { _ = raw.Close() })
+	raw.SetMaxOpenConns(1)
+
+	lock, err := s.db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("conn: %v", err)
+	}
+	if _, err := lock.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+
+	insert := func() error {
+		_, err := raw.ExecContext(ctx, `INSERT INTO conversations (workspace, role, content, created_at) VALUES ('/ws', 'user', 'x', 0)`)
+		return err //nolint:wrapcheck // test helper
+	}
+	busyErr := insert()
+	if !isBusy(busyErr) {
+		t.Fatalf("want SQLITE_BUSY while locked, got %v", busyErr)
+	}
+	if isBusy(errors.New("store: unrelated")) {
+		t.Error("isBusy must not match non-SQLite errors")
+	}
+
+	// Release the lock after the first retry's backoff has started.
+	go func() {
+		time.Sleep(busyBackoff / 2)
+		_, _ = lock.ExecContext(ctx, "COMMIT")
+		_ = lock.Close()
+	}()
+	attempts := 0
+	err = retryBusy(ctx, func() error {
+		attempts++
+		return insert()
+	})
+	if err != nil {
+		t.Fatalf("retryBusy: %v", err)
+	}
+	if attempts < 2 {
+		t.Errorf("want at least one retry, got %d attempts", attempts)
+	}
+
+	// Non-busy errors are returned without retrying.
+	attempts = 0
+	_ = retryBusy(ctx, func() error {
+		attempts++
+		return errors.New("boom")
+	})
+	if attempts != 1 {
+		t.Errorf("want 1 attempt for non-busy error, got %d", attempts)
+	}
+}
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HistorySummary is a model-written summary of the oldest turns of a
+// conversation, injected in their place once they no longer fit the
+// context budget.
+type HistorySummary struct {
+	// Through is the ID of the newest turn the summary covers (see WithTurn).
+	Through string
+	// Content is the summary text.
+	Content string
+	// CreatedAt is when the summary was saved.
+	CreatedAt time.Time
+}
+
+// SummaryStore is implemented by stores that can keep one history summary
+// per session, so it is not generated again for every query.
+type SummaryStore interface {
+	// HistorySummary returns the session's summary, or false when it has
+	// none.
+	HistorySummary(ctx context.Context, workspaceDir, sessionID string) (HistorySummary, bool, error)
+	// SaveHistorySummary replaces the session's summary. CreatedAt is
+	// assigned by the store.
+	SaveHistorySummary(ctx context.Context, workspaceDir, sessionID string, sum HistorySummary) error
+}
+
+// summariesDDL creates the history summaries table: one row per session,
+// replaced whenever the summary is regenerated.
+const summariesDDL = `
+CREATE TABLE IF NOT EXISTS history_summaries (
+    workspace     TEXT    NOT NULL,
+    session_id    TEXT    NOT NULL,
+    through_turn  TEXT    NOT NULL,
+    content       TEXT    NOT NULL,
+    created_at    INTEGER NOT NULL,  -- Unix timestamp (seconds)
+    PRIMARY KEY (workspace, session_id)
+);
+`
+
+// HistorySummary returns the session's summary.
+func (s *SQLiteStore) HistorySummary(ctx context.Context, workspaceDir, sessionID string) (HistorySummary, bool, error) {
+	const q = `SELECT through_turn, content, created_at FROM history_summaries WHERE workspace = ? AND session_id = ?`
+	var sum HistorySummary
+	var ts int64
+	err := retryBusy(ctx, func() error {
+		return s.db.QueryRowContext(ctx, q, workspaceDir, sessionID).Scan(&sum.Through, &sum.Content, &ts) //nolint:wrapcheck // wrapped below
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return HistorySummary{}, false, nil
+	}
+	if err != nil {
+		return HistorySummary{}, false, fmt.Errorf("store: history summary: %w", err)
+	}
+	sum.CreatedAt = time.Unix(ts, 0)
+	return sum, true, nil
+}
+
+// SaveHistorySummary replaces the session's summary.
+func (s *SQLiteStore) SaveHistorySummary(ctx context.Context, workspaceDir, sessionID string, sum HistorySummary) error {
+	const q = `INSERT INTO history_summaries (workspace, session_id, through_turn, content, created_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (workspace, session_id) DO UPDATE SET through_turn = excluded.through_turn, content = excluded.content, created_at = excluded.created_at`
+	err := retryBusy(ctx, func() error {
+		_, err := s.db.ExecContext(ctx, q, workspaceDir, sessionID, sum.Through, sum.Content, s.now().Unix())
+		return err //nolint:wrapcheck // wrapped below
+	})
+	if err != nil {
+		return fmt.Errorf("store: save history summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// History summaries
+// ---------------------------------------------------------------------------
+
+func Test_Store_HistorySummary(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	s.now = func() time.Time { return time.Unix(100, 0) }
+	ctx := context.Background()
+
+	if _, ok, err := s.HistorySummary(ctx, "/ws", ""); err != nil || ok {
+		t.Fatalf("want no summary yet, got %v, %v", ok, err)
+	}
+	for _, sum := range []HistorySummary{
+		{Through: "turn-1", Content: "- region: eu-west-1"},
+		{Through: "turn-3", Content: "- region: eu-west-1\n- tags: prod scheme"},
+	} {
+		if err := s.SaveHistorySummary(ctx, "/ws", "", sum); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	if err := s.SaveHistorySummary(ctx, "/ws", "sess", HistorySummary{Through: "turn-9", Content: "other session"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	got, ok, err := s.HistorySummary(ctx, "/ws", "")
+	if err != nil || !ok {
+		t.Fatalf("want a summary, got %v, %v", ok, err)
+	}
+	want := HistorySummary{Through: "turn-3", Content: "- region: eu-west-1\n- tags: prod scheme", CreatedAt: time.Unix(100, 0)}
+	if got != want {
+		t.Errorf("want the latest summary %+v, got %+v", want, got)
+	}
+
+	// Clearing the workspace drops its summaries with its messages.
+	if err := s.Append(ctx, "/ws", "", RoleUser, "q"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Clear(ctx, "/ws"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	for _, session := range []string{"", "sess"} {
+		if _, ok, err := s.HistorySummary(ctx, "/ws", session); err != nil || ok {
+			t.Errorf("session %q: want no summary after clear, got %v, %v", session, ok, err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Timings is where the time of the query that produced an assistant message
+// went, in milliseconds.
+type Timings struct {
+	// ContextMs is building the model input: history, documentation, and
+	// workspace context.
+	ContextMs int64
+	// FirstTokenMs is the time from the first model call to the first
+	// response text; zero when no text arrived.
+	FirstTokenMs int64
+	// ModelMs is the model thinking and streaming, tool calls excluded.
+	ModelMs int64
+	// ToolsMs is the total time of the tool calls.
+	ToolsMs int64
+	// ToolCalls is the number of tool calls.
+	ToolCalls int
+	// ParseMs is parsing the answer as a file envelope.
+	ParseMs int64
+	// ApplyMs is writing the envelope's files to the workspace.
+	ApplyMs int64
+	// TotalMs is the whole query.
+	TotalMs int64
+}
+
+// TimingsRecorder is implemented by stores that can persist query timings
+// with a message. The agent uses it when available and falls back to
+// UsageRecorder and ConversationStore.Append otherwise. Recent returns the
+// timings in Message.Timings.
+type TimingsRecorder interface {
+	// AppendWithMeta persists a message together with its usage metadata,
+	// when u is non-nil, and its timings, when t is non-nil.
+	AppendWithMeta(ctx context.Context, workspaceDir, sessionID string, role Role, content string, u *Usage, t *Timings) error
+}
+
+// timingsDDL creates the per-message timings table. Rows reference the
+// conversations table like message_usage.
+const timingsDDL = `
+CREATE TABLE IF NOT EXISTS message_timings (
+    message_id      INTEGER PRIMARY KEY REFERENCES conversations(id) ON DELETE CASCADE,
+    context_ms      INTEGER NOT NULL,
+    first_token_ms  INTEGER NOT NULL,
+    model_ms        INTEGER NOT NULL,
+    tools_ms        INTEGER NOT NULL,
+    tool_calls      INTEGER NOT NULL,
+    parse_ms        INTEGER NOT NULL,
+    apply_ms        INTEGER NOT NULL,
+    total_ms        INTEGER NOT NULL
+);
+`
+
+// AppendWithMeta persists a message with its usage metadata and timings
+// atomically, in the turn set by WithTurn, retrying the transaction while
+// the database is busy. Either may be nil.
+func (s *SQLiteStore) AppendWithMeta(ctx context.Context, workspaceDir, sessionID string, role Role, content string, u *Usage, t *Timings) error {
+	turn, err := turnFrom(ctx)
+	if err != nil {
+		return err
+	}
+	createdAt := s.now().Unix()
+	return retryBusy(ctx, func() error {
+		return s.appendWithMeta(ctx, workspaceDir, sessionID, turn, role, content, u, t, createdAt)
+	})
+}
+
+// appendWithMeta runs one attempt of the AppendWithMeta transaction.
+func (s *SQLiteStore) appendWithMeta(ctx context.Context, workspaceDir, sessionID, turn string, role Role, content string, u *Usage, t *Timings, createdAt int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("store: append with metadata: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	const insertMsg = `INSERT INTO conversations (workspace, session_id, turn_id, role, content, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	res, err := tx.ExecContext(ctx, insertMsg, workspaceDir, sessionID, turn, string(role), content, createdAt)
+	if err != nil {
+		return fmt.Errorf("store: append with metadata: %w", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("store: append with metadata: %w", err)
+	}
+
+	if u != nil {
+		const insertUsage = `INSERT INTO message_usage (message_id, provider, model, prompt_tokens, completion_tokens) VALUES (?, ?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, insertUsage, id, u.Provider, u.Model, u.PromptTokens, u.CompletionTokens); err != nil {
+			return fmt.Errorf("store: append with metadata: %w", err)
+		}
+	}
+	if t != nil {
+		const insertTimings = `INSERT INTO message_timings (message_id, context_ms, first_token_ms, model_ms, tools_ms, tool_calls, parse_ms, apply_ms, total_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, insertTimings, id, t.ContextMs, t.FirstTokenMs, t.ModelMs, t.ToolsMs, t.ToolCalls, t.ParseMs, t.ApplyMs, t.TotalMs); err != nil {
+			return fmt.Errorf("store: append with metadata: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("store: append with metadata: %w", err)
+	}
+	return nil
+}
+
+// nullTimings scans the message_timings columns of a LEFT JOIN, which are
+// NULL for messages stored without timings.
+type nullTimings struct {
+	context, firstToken, model, tools, toolCalls, parse, apply, total sql.NullInt64
+}
+
+// dest returns the scan destinations, in timingsDDL column order.
+func (n *nullTimings) dest() []any {
+	return []any{&n.context, &n.firstToken, &n.model, &n.tools, &n.toolCalls, &n.parse, &n.apply, &n.total}
+}
+
+// timings returns the scanned timings, or nil when the row had none.
+func (n *nullTimings) timings() *Timings {
+	if !n.total.Valid {
+		return nil
+	}
+	return &Timings{
+		ContextMs:    n.context.Int64,
+		FirstTokenMs: n.firstToken.Int64,
+		ModelMs:      n.model.Int64,
+		ToolsMs:      n.tools.Int64,
+		ToolCalls:    int(n.toolCalls.Int64),
+		ParseMs:      n.parse.Int64,
+		ApplyMs:      n.apply.Int64,
+		TotalMs:      n.total.Int64,
+	}
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_Store_AppendWithMeta(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	if err := s.Append(ctx, "/ws/a", "", RoleUser, "q"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	tm := Timings{ContextMs: 10, FirstTokenMs: 900, ModelMs: 1200, ToolsMs: 300, ToolCalls: 2, ParseMs: 1, ApplyMs: 4, TotalMs: 1520}
+	if err := s.AppendWithMeta(ctx, "/ws/a", "", RoleAssistant, "a", nil, &tm); err != nil {
+		t.Fatalf("append with meta: %v", err)
+	}
+	u := Usage{Provider: "openai", Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 20}
+	if err := s.AppendWithMeta(ctx, "/ws/a", "", RoleAssistant, "b", &u, &tm); err != nil {
+		t.Fatalf("append with meta: %v", err)
+	}
+
+	msgs, err := s.Recent(ctx, "/ws/a", "", 10)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("want 3 messages, got %d", len(msgs))
+	}
+	if msgs[0].Timings != nil {
+		t.Errorf("want no timings on a plain message, got %+v", msgs[0].Timings)
+	}
+	for _, m := range msgs[1:] {
+		if m.Timings == nil || *m.Timings != tm {
+			t.Errorf("message %q: want timings %+v, got %+v", m.Content, tm, m.Timings)
+		}
+	}
+
+	// Usage is recorded only for the message it was given with.
+	records, err := s.UsageRecords(ctx, msgs[0].CreatedAt)
+	if err != nil {
+		t.Fatalf("usage records: %v", err)
+	}
+	if len(records) != 2 || records[0].Tracked || !records[1].Tracked || records[1].Usage != u {
+		t.Errorf("want a and b with usage on b only, got %+v", records)
+	}
+
+	// Clearing the workspace removes the timings with the messages.
+	if _, err := s.Clear(ctx, "/ws/a"); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM message_timings`).Scan(&n); err != nil {
+		t.Fatalf("count timings: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("want timings cleared, %d rows left", n)
+	}
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// turnKey is the context key of the turn ID written with new rows.
+type turnKey struct{}
+
+// WithTurn returns a context whose writes through Append, AppendEvent,
+// AppendWithUsage, and AppendWithMeta are grouped in the turn id: one
+// query's user message, event notes, and assistant reply. Recent returns
+// turns whole, so a turn written while another process writes the same
+// conversation is never interleaved with it. A row written without a turn
+// forms a turn of its own.
+func WithTurn(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, turnKey{}, id)
+}
+
+// turnFrom returns the turn ID set by WithTurn, or a new one.
+func turnFrom(ctx context.Context) (string, error) {
+	if id, ok := ctx.Value(turnKey{}).(string); ok && id != "" {
+		return id, nil
+	}
+	return NewTurnID()
+}
+
+// NewTurnID returns a random version 4 UUID for WithTurn.
+func NewTurnID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("store: turn id: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// turnRow is a conversation row as seen by backfillTurns.
+type turnRow struct {
+	id                 int64
+	workspace, session string
+	role               Role
+	kind               Kind
+}
+
+// startsTurn reports whether row r, following the rows of the current turn
+// of the same conversation, starts a new one. A user message always does.
+// Tool and file notes are written before the reply they belong to, so they,
+// like another reply, start a new turn once the current one has its reply.
+// The notes written after a reply (continuation, truncation, disclosure)
+// never do.
+func startsTurn(r turnRow, replied bool) bool {
+	if r.kind == KindMessage && r.role == RoleUser {
+		return true
+	}
+	if !replied {
+		return false
+	}
+	switch r.kind {
+	case KindMessage, KindToolRun, KindFilesWritten:
+		return true
+	default:
+		return false
+	}
+}
+
+// backfillTurns assigns turn IDs to rows stored before turns existed,
+// pairing each user message with the notes and reply after it in the same
+// conversation (see startsTurn). It runs in one transaction, so a second
+// process opening the database at the same time finds nothing left to do.
+func (s *SQLiteStore) backfillTurns(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("store: migrate: backfill turns: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	const q = `SELECT id, workspace, session_id, role, kind FROM conversations WHERE turn_id = ''
+ORDER BY workspace, session_id, created_at, id`
+	rows, err := tx.QueryContext(ctx, q)
+	if err != nil {
+		return fmt.Errorf("store: migrate: backfill turns: %w", err)
+	}
+	var pending []turnRow
+	for rows.Next() {
+		var r turnRow
+		var role, kind string
+		if err := rows.Scan(&r.id, &r.workspace, &r.session, &role, &kind); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("store: migrate: backfill turns: %w", err)
+		}
+		r.role, r.kind = Role(role), Kind(kind)
+		pending = append(pending, r)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("store: migrate: backfill turns: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var (
+		turn    string
+		replied bool
+		prev    turnRow
+	)
+	for i, r := range pending {
+		if i == 0 || r.workspace != prev.workspace || r.session != prev.session || startsTurn(r, replied) {
+			if turn, err = NewTurnID(); err != nil {
+				return err
+			}
+			replied = false
+		}
+		replied = replied || (r.kind == KindMessage && r.role == RoleAssistant)
+		if _, err := tx.ExecContext(ctx, `UPDATE conversations SET turn_id = ? WHERE id = ?`, turn, r.id); err != nil {
+			return fmt.Errorf("store: migrate: backfill turns: %w", err)
+		}
+		prev = r
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("store: migrate: backfill turns: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Turns
+// ---------------------------------------------------------------------------
+
+// appendTurn writes one query's rows, user message, tool note, and reply,
+// in a turn of its own, the way the agent does.
+func appendTurn(ctx context.Context, s *SQLiteStore, ws, tag string) error {
+	id, err := NewTurnID()
+	if err != nil {
+		return err
+	}
+	ctx = WithTurn(ctx, id)
+	if err := s.Append(ctx, ws, "", RoleUser, tag+" question"); err != nil {
+		return err
+	}
+	if err := s.AppendEvent(ctx, ws, "", KindToolRun, tag+" terraform_plan: ok"); err != nil {
+		return err
+	}
+	return s.AppendWithMeta(ctx, ws, "", RoleAssistant, tag+" answer", nil, &Timings{TotalMs: 1})
+}
+
+// checkWholeTurns fails unless msgs is a sequence of whole turns written by
+// appendTurn, each contiguous, and returns their tags in order.
+func checkWholeTurns(t *testing.T, msgs []Message) []string {
+	t.Helper()
+	if len(msgs)%3 != 0 {
+		t.Fatalf("want whole turns of 3 rows, got %d rows", len(msgs))
+	}
+	var tags []string
+	for i := 0; i < len(msgs); i += 3 {
+		tag, _, _ := strings.Cut(msgs[i].Content, " ")
+		want := []string{tag + " question", tag + " terraform_plan: ok", tag + " answer"}
+		for j, m := range msgs[i : i+3] {
+			if m.Content != want[j] || m.TurnID != msgs[i].TurnID {
+				t.Fatalf("turn %s split or interleaved at row %d: %+v", tag, i+j, msgs[i:i+3])
+			}
+		}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Test_Store_InterleavedTurns writes turns from two stores on one file at
+// once, the way `tfai chat` and `tfai serve` share a workspace thread, and
+// checks that Recent returns every turn whole.
+func Test_Store_InterleavedTurns(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	stores := make([]*SQLiteStore, 2)
+	for i := range stores {
+		s, err := Open(ctx, path)
+		if err != nil {
+			t.Fatalf("open store %d: %v", i, err)
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		stores[i] = s
+	}
+
+	const perWriter = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*perWriter)
+	for i, s := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range perWriter {
+				if err := appendTurn(ctx, s, "/ws/shared", fmt.Sprintf("w%d-%d", i, j)); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("append: %v", err)
+	}
+
+	all, err := stores[0].Recent(ctx, "/ws/shared", "", 1000)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	if tags := checkWholeTurns(t, all); len(tags) != 2*perWriter {
+		t.Errorf("want %d turns, got %d", 2*perWriter, len(tags))
+	}
+
+	// A limit that ends mid-turn leaves that turn out.
+	tail, err := stores[1].Recent(ctx, "/ws/shared", "", 8)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	tags := checkWholeTurns(t, tail)
+	want := checkWholeTurns(t, all)[2*perWriter-2:]
+	if strings.Join(tags, ",") != strings.Join(want, ",") {
+		t.Errorf("want the two newest turns %v, got %v", want, tags)
+	}
+}
+
+func Test_Store_RecentOrdersTurnsByCompletion(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	// Turn a starts first but finishes after turn b.
+	a, b := WithTurn(ctx, "turn-a"), WithTurn(ctx, "turn-b")
+	for _, step := range []func() error{
+		func() error { return s.Append(a, "/ws", "", RoleUser, "a question") },
+		func() error { return s.Append(b, "/ws", "", RoleUser, "b question") },
+		func() error { return s.Append(b, "/ws", "", RoleAssistant, "b answer") },
+		func() error { return s.Append(a, "/ws", "", RoleAssistant, "a answer") },
+	} {
+		if err := step(); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	msgs, err := s.Recent(ctx, "/ws", "", 10)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	var got []string
+	for _, m := range msgs {
+		got = append(got, m.Content)
+	}
+	if want := "b question,b answer,a question,a answer"; strings.Join(got, ",") != want {
+		t.Errorf("want %s, got %s", want, strings.Join(got, ","))
+	}
+}
+
+func Test_Store_BackfillsTurns(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "history.db")
+
+	// A database written before turns existed: a turn with tool notes, a
+	// continuation with no user message, and a user message whose reply
+	// was never stored, in two sessions.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const oldDDL = `
+CREATE TABLE conversations (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace    TEXT    NOT NULL,
+    role         TEXT    NOT NULL CHECK(role IN ('user','assistant')),
+    kind         TEXT    NOT NULL DEFAULT 'message',
+    session_id   TEXT    NOT NULL DEFAULT '',
+    content      TEXT    NOT NULL,
+    created_at   INTEGER NOT NULL
+);
+INSERT INTO conversations (workspace, session_id, role, kind, content, created_at) VALUES
+    ('/ws', '',  'user',      'message',       'q1',                 1),
+    ('/ws', 's', 'user',      'message',       's-q1',               1),
+    ('/ws', '',  'assistant', 'tool_run',      'terraform_plan: ok', 2),
+    ('/ws', '',  'assistant', 'message',       'a1 part 1',          3),
+    ('/ws', 's', 'assistant', 'message',       's-a1',               3),
+    ('/ws', '',  'assistant', 'truncated',     '0',                  3),
+    ('/ws', '',  'assistant', 'tool_run',      'terraform_fmt: ok',  4),
+    ('/ws', '',  'assistant', 'message',       'a1 part 2',          5),
+    ('/ws', '',  'assistant', 'continued',     '1',                  5),
+    ('/ws', '',  'assistant', 'disclosure',    'AI-generated',       5),
+    ('/ws', '',  'user',      'message',       'q2',                 6),
+    ('/ws', '',  'user',      'message',       'q3',                 7),
+    ('/ws', '',  'assistant', 'message',       'a3',                 8);`
+	if _, err := db.ExecContext(ctx, oldDDL); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	s, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	msgs, err := s.Recent(ctx, "/ws", "", 100)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	var turns [][]string
+	for i, m := range msgs {
+		if i == 0 || m.TurnID != msgs[i-1].TurnID {
+			turns = append(turns, nil)
+		}
+		if m.TurnID == "" {
+			t.Errorf("row %q has no turn", m.Content)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], m.Content)
+	}
+	want := [][]string{
+		{"q1", "terraform_plan: ok", "a1 part 1", "0"},
+		{"terraform_fmt: ok", "a1 part 2", "1", "AI-generated"},
+		{"q2"},
+		{"q3", "a3"},
+	}
+	if fmt.Sprint(turns) != fmt.Sprint(want) {
+		t.Errorf("want turns %v, got %v", want, turns)
+	}
+
+	sess, err := s.Recent(ctx, "/ws", "s", 100)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	if len(sess) != 2 || sess[0].TurnID != sess[1].TurnID || sess[0].TurnID == msgs[0].TurnID {
+		t.Errorf("want the session's pair in a turn of its own, got %+v", sess)
+	}
+
+	// Reopening finds nothing left to backfill and keeps the IDs.
+	s2, err := Open(ctx, path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	t.Cleanup(func() { _ = s2.Close() })
+	again, err := s2.Recent(ctx, "/ws", "", 100)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	if len(again) != len(msgs) || again[0].TurnID != msgs[0].TurnID {
+		t.Errorf("want turn IDs kept on reopen")
+	}
+}
+
+func Test_Store_RowsWithoutTurn(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	s.now = func() time.Time { return time.Unix(100, 0) }
+	ctx := context.Background()
+
+	if err := s.Append(ctx, "/ws", "", RoleUser, "q"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Append(ctx, "/ws", "", RoleAssistant, "a"); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := s.Recent(ctx, "/ws", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].TurnID == "" || msgs[0].TurnID == msgs[1].TurnID {
+		t.Errorf("want each row written without a turn in a turn of its own, got %+v", msgs)
+	}
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Usage is the token usage metadata recorded alongside an assistant message.
+type Usage struct {
+	// Provider is the model backend that produced the message (e.g. "openai").
+	Provider string
+	// Model is the model or deployment name.
+	Model string
+	// PromptTokens is the number of input tokens billed for the message,
+	// summed across every model call the query made.
+	PromptTokens int
+	// CompletionTokens is the number of output tokens billed for the message.
+	CompletionTokens int
+}
+
+// UsageRecorder is implemented by stores that can persist usage metadata
+// with a message. The agent uses it when available and falls back to
+// ConversationStore.Append otherwise.
+type UsageRecorder interface {
+	// AppendWithUsage persists a message together with its usage metadata.
+	AppendWithUsage(ctx context.Context, workspaceDir, sessionID string, role Role, content string, u Usage) error
+}
+
+// UsageRecord is one assistant message as seen by usage reporting.
+type UsageRecord struct {
+	// Workspace is the workspace directory the message belongs to.
+	Workspace string
+	// CreatedAt is when the message was persisted.
+	CreatedAt time.Time
+	// Tracked is false when no usage metadata was stored for the message
+	// (older messages, or providers that do not report usage).
+	Tracked bool
+	// Usage is the stored metadata; zero when Tracked is false.
+	Usage Usage
+}
+
+// UsageReader is implemented by stores that can list usage records.
+type UsageReader interface {
+	// UsageRecords returns every assistant message created at or after since,
+	// oldest first, with its usage metadata if any was stored.
+	UsageRecords(ctx context.Context, since time.Time) ([]UsageRecord, error)
+}
+
+// usageDDL creates the per-message usage table. Rows reference the
+// conversations table; messages without a row are reported as untracked.
+const usageDDL = `
+CREATE TABLE IF NOT EXISTS message_usage (
+    message_id         INTEGER PRIMARY KEY REFERENCES conversations(id) ON DELETE CASCADE,
+    provider           TEXT    NOT NULL,
+    model              TEXT    NOT NULL,
+    prompt_tokens      INTEGER NOT NULL,
+    completion_tokens  INTEGER NOT NULL
+);
+`
+
+// AppendWithUsage persists a message and its usage metadata atomically,
+// retrying the transaction while the database is busy.
+func (s *SQLiteStore) AppendWithUsage(ctx context.Context, workspaceDir, sessionID string, role Role, content string, u Usage) error {
+	return s.AppendWithMeta(ctx, workspaceDir, sessionID, role, content, &u, nil)
+}
+
+// UsageRecords returns every assistant message created at or after since,
+// oldest first, joined with its usage metadata.
+func (s *SQLiteStore) UsageRecords(ctx context.Context, since time.Time) ([]UsageRecord, error) {
+	const q = `
+SELECT c.workspace, c.created_at, u.provider, u.model, u.prompt_tokens, u.completion_tokens
+FROM   conversations c
+LEFT   JOIN message_usage u ON u.message_id = c.id
+WHERE  c.role = 'assistant' AND c.kind = 'message' AND c.created_at >= ?
+ORDER  BY c.created_at ASC, c.id ASC`
+
+	rows, err := s.db.QueryContext(ctx, q, since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("store: usage records: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var records []UsageRecord
+	for rows.Next() {
+		var (
+			r                  UsageRecord
+			ts                 int64
+			provider, model    sql.NullString
+			prompt, completion sql.NullInt64
+		)
+		if err := rows.Scan(&r.Workspace, &ts, &provider, &model, &prompt, &completion); err != nil {
+			return nil, fmt.Errorf("store: usage records scan: %w", err)
+		}
+		r.CreatedAt = time.Unix(ts, 0)
+		if provider.Valid {
+			r.Tracked = true
+			r.Usage = Usage{
+				Provider:         provider.String,
+				Model:            model.String,
+				PromptTokens:     int(prompt.Int64),
+				CompletionTokens: int(completion.Int64),
+			}
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("store: usage records rows: %w", err)
+	}
+	return records, nil
+}
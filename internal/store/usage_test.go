@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_Store_UsageRecords(t *testing.T) {
+	t.Parallel()
+	s := openTestStore(t)
+	ctx := context.Background()
+
+	day1 := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	day3 := day2.Add(24 * time.Hour)
+
+	s.now = func() time.Time { return day1 }
+	if err := s.Append(ctx, "/ws/a", "", RoleUser, "q1"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	// Persisted before usage tracking existed: no metadata row.
+	if err := s.Append(ctx, "/ws/a", "", RoleAssistant, "a1"); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	s.now = func() time.Time { return day2 }
+	u := Usage{Provider: "openai", Model: "gpt-4o", PromptTokens: 1200, CompletionTokens: 300}
+	if err := s.AppendWithUsage(ctx, "/ws/b", "", RoleAssistant, "a2", u); err != nil {
+		t.Fatalf("append with usage: %v", err)
+	}
+
+	s.now = func() time.Time { return day3 }
+	if err := s.AppendWithUsage(ctx, "/ws/a", "", RoleAssistant, "a3", Usage{Provider: "ollama", Model: "llama3", PromptTokens: 10, CompletionTokens: 5}); err != nil {
+		t.Fatalf("append with usage: %v", err)
+	}
+
+	all, err := s.UsageRecords(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("usage records: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("want 3 assistant records (user messages excluded), got %d", len(all))
+	}
+	if all[0].Tracked {
+		t.Errorf("record[0]: want untracked, got %+v", all[0])
+	}
+	if !all[1].Tracked || all[1].Usage != u || all[1].Workspace != "/ws/b" || !all[1].CreatedAt.Equal(day2) {
+		t.Errorf("record[1]: want tracked %+v in /ws/b at %v, got %+v", u, day2, all[1])
+	}
+
+	recent, err := s.UsageRecords(ctx, day2)
+	if err != nil {
+		t.Fatalf("usage records since: %v", err)
+	}
+	if len(recent) != 2 || recent[0].Usage.Model != "gpt-4o" || recent[1].Usage.Model != "llama3" {
+		t.Errorf("since day2: want gpt-4o then llama3, got %+v", recent)
+	}
+
+	// Usage rows must not disturb history replay.
+	msgs, err := s.Recent(ctx, "/ws/a", "", 10)
+	if err != nil {
+		t.Fatalf("recent: %v", err)
+	}
+	if len(msgs) != 3 || msgs[2].Content != "a3" {
+		t.Errorf("recent: want q1, a1, a3, got %+v", msgs)
+	}
+}
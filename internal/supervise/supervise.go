@@ -0,0 +1,231 @@
+// Package supervise runs long-lived background loops so that a loop dying
+// from a panic or an unexpected error is noticed and restarted instead of
+// silently degrading the process. Each loop is restarted with exponential
+// backoff, restarts are counted in tfai_background_restarts_total{loop}, and
+// the state of every loop is available for status reporting.
+package supervise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+)
+
+// Default restart backoff bounds, used when Config leaves them zero.
+const (
+	// DefaultMinBackoff is the delay before the first restart of a loop.
+	DefaultMinBackoff = time.Second
+	// DefaultMaxBackoff caps the delay between restarts. A loop that ran at
+	// least this long before failing starts again from DefaultMinBackoff.
+	DefaultMaxBackoff = time.Minute
+)
+
+// Loop is a background loop. It should run until ctx is done and then
+// return nil. Returning an error or panicking before that makes the
+// supervisor restart it; returning nil early ends it for good.
+type Loop func(ctx context.Context) error
+
+// Config configures a Supervisor.
+type Config struct {
+	// MinBackoff is the delay before the first restart of a failed loop; it
+	// doubles with each consecutive failure. Defaults to DefaultMinBackoff.
+	MinBackoff time.Duration
+	// MaxBackoff caps the restart delay. Defaults to DefaultMaxBackoff.
+	MaxBackoff time.Duration
+	// Registerer receives the restart counter. Defaults to
+	// prometheus.DefaultRegisterer.
+	Registerer prometheus.Registerer
+	// Logger receives failure and restart events. Defaults to the logger
+	// carried by the context passed to Go.
+	Logger *slog.Logger
+}
+
+// Status is a snapshot of one supervised loop.
+type Status struct {
+	// Name identifies the loop, e.g. "ratelimit_evictor".
+	Name string
+	// Running is false while the loop waits to be restarted, and once it
+	// has ended.
+	Running bool
+	// Restarts counts the restarts since the loop was started.
+	Restarts int
+	// LastRestart is when the loop was last restarted; zero if never.
+	LastRestart time.Time
+	// LastError describes the last failure; empty if none.
+	LastError string
+	// Panicked is true when the last failure was a recovered panic, whose
+	// value LastError carries.
+	Panicked bool
+}
+
+// Supervisor starts, restarts, and tracks background loops. It is safe for
+// concurrent use.
+type Supervisor struct {
+	// cfg is the resolved configuration.
+	cfg Config
+	// restarts counts restarts per loop name.
+	restarts *prometheus.CounterVec
+	// wg tracks the running supervision goroutines for Wait.
+	wg sync.WaitGroup
+	// mu guards loops.
+	mu sync.Mutex
+	// loops holds the state of every loop started, by name.
+	loops map[string]*Status
+}
+
+// New constructs a Supervisor and registers its restart counter.
+func New(cfg Config) *Supervisor {
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	cfg.MaxBackoff = max(cfg.MaxBackoff, cfg.MinBackoff)
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.DefaultRegisterer
+	}
+	return &Supervisor{
+		cfg: cfg,
+		restarts: promauto.With(cfg.Registerer).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tfai",
+			Subsystem: "background",
+			Name:      "restarts_total",
+			Help:      "Total number of times a background loop was restarted after a panic or error.",
+		}, []string{"loop"}),
+		loops: make(map[string]*Status),
+	}
+}
+
+// Go starts loop under supervision in a new goroutine. The loop runs, and
+// is restarted whenever it fails, until ctx is done. name must be unique
+// within the supervisor.
+func (s *Supervisor) Go(ctx context.Context, name string, loop Loop) {
+	s.mu.Lock()
+	if _, ok := s.loops[name]; ok {
+		s.mu.Unlock()
+		panic(fmt.Sprintf("supervise: loop %q started twice", name))
+	}
+	st := &Status{Name: name, Running: true}
+	s.loops[name] = st
+	s.mu.Unlock()
+
+	s.restarts.WithLabelValues(name).Add(0)
+	s.wg.Add(1)
+	go s.supervise(ctx, st, loop)
+}
+
+// supervise runs loop until ctx is done or it returns nil, restarting it
+// with backoff after every failure.
+func (s *Supervisor) supervise(ctx context.Context, st *Status, loop Loop) {
+	defer s.wg.Done()
+	log := s.cfg.Logger
+	if log == nil {
+		log = logging.FromContext(ctx)
+	}
+	log = log.With(slog.String("loop", st.Name))
+
+	backoff := s.cfg.MinBackoff
+	for {
+		started := time.Now()
+		err := runOnce(ctx, loop)
+		if ctx.Err() == nil && err == nil {
+			log.Warn("background loop exited")
+		}
+		if ctx.Err() != nil || err == nil {
+			s.update(st, func(st *Status) { st.Running = false })
+			return
+		}
+
+		if time.Since(started) >= s.cfg.MaxBackoff {
+			// It ran healthily for a while: this is a fresh failure, not
+			// a crash loop.
+			backoff = s.cfg.MinBackoff
+		}
+		log.Error("background loop failed, restarting",
+			slog.Any("error", err),
+			slog.Duration("backoff", backoff),
+		)
+		var pe *panicError
+		panicked := errors.As(err, &pe)
+		s.update(st, func(st *Status) { st.Running, st.LastError, st.Panicked = false, err.Error(), panicked })
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		backoff = min(backoff*2, s.cfg.MaxBackoff)
+
+		s.restarts.WithLabelValues(st.Name).Inc()
+		s.update(st, func(st *Status) {
+			st.Running, st.Restarts, st.LastRestart = true, st.Restarts+1, time.Now().UTC()
+		})
+	}
+}
+
+// runOnce runs loop, turning a panic into an error. The stack is logged
+// with the error rather than kept in it, so Status stays readable.
+func runOnce(ctx context.Context, loop Loop) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r, stack: debug.Stack()}
+		}
+	}()
+	return loop(ctx)
+}
+
+// panicError is the error a recovered panic is reported as.
+type panicError struct {
+	value any
+	stack []byte
+}
+
+// Error implements error.
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// LogValue includes the stack in structured logs.
+func (e *panicError) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("panic", fmt.Sprint(e.value)),
+		slog.String("stack", string(e.stack)),
+	)
+}
+
+// update applies fn to st under the lock.
+func (s *Supervisor) update(st *Status, fn func(*Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(st)
+}
+
+// Status returns a snapshot of every loop, sorted by name.
+func (s *Supervisor) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.loops))
+	for _, st := range s.loops {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Wait blocks until every loop has ended. Cancel the loops' context first.
+func (s *Supervisor) Wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,179 @@
+package supervise
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestSupervisor returns a Supervisor with short backoffs and its own
+// registry, and a context cancelled (and waited for) at cleanup.
+func newTestSupervisor(t *testing.T, minBackoff, maxBackoff time.Duration) (*Supervisor, context.Context) {
+	t.Helper()
+	s := New(Config{MinBackoff: minBackoff, MaxBackoff: maxBackoff, Registerer: prometheus.NewRegistry()})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		s.Wait()
+	})
+	return s, ctx
+}
+
+// status returns the status of the named loop.
+func status(t *testing.T, s *Supervisor, name string) Status {
+	t.Helper()
+	for _, st := range s.Status() {
+		if st.Name == name {
+			return st
+		}
+	}
+	t.Fatalf("no loop %q", name)
+	return Status{}
+}
+
+// eventually polls cond until it holds or five seconds pass.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 5s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSupervisor_RecoversPanic(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestSupervisor(t, time.Millisecond, 10*time.Millisecond)
+	var calls atomic.Int32
+	s.Go(ctx, "evictor", func(ctx context.Context) error {
+		if calls.Add(1) <= 2 {
+			panic("nil map write")
+		}
+		<-ctx.Done()
+		return nil
+	})
+
+	eventually(t, func() bool { return calls.Load() == 3 })
+	eventually(t, func() bool { return status(t, s, "evictor").Restarts == 2 })
+	st := status(t, s, "evictor")
+	if !st.Running || st.LastError != "panic: nil map write" || !st.Panicked || st.LastRestart.IsZero() {
+		t.Errorf("unexpected status %+v", st)
+	}
+	if got := testutil.ToFloat64(s.restarts.WithLabelValues("evictor")); got != 2 {
+		t.Errorf("expected the restart counter at 2, got %v", got)
+	}
+}
+
+func TestSupervisor_BackoffBounds(t *testing.T) {
+	t.Parallel()
+
+	const minBackoff, maxBackoff = 20 * time.Millisecond, 80 * time.Millisecond
+	s, ctx := newTestSupervisor(t, minBackoff, maxBackoff)
+
+	var (
+		mu     sync.Mutex
+		starts []time.Time
+	)
+	s.Go(ctx, "failing", func(context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		starts = append(starts, time.Now())
+		return errors.New("store unavailable")
+	})
+	eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(starts) == 5
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond, 80 * time.Millisecond}
+	for i, w := range want {
+		gap := starts[i+1].Sub(starts[i])
+		if gap < w || gap > w+time.Second {
+			t.Errorf("restart %d after %v, want about %v", i+1, gap, w)
+		}
+	}
+	if st := status(t, s, "failing"); st.LastError != "store unavailable" {
+		t.Errorf("unexpected last error %q", st.LastError)
+	}
+}
+
+func TestSupervisor_EarlyReturnEndsLoop(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestSupervisor(t, time.Millisecond, time.Millisecond)
+	var calls atomic.Int32
+	s.Go(ctx, "once", func(context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+
+	eventually(t, func() bool { return !status(t, s, "once").Running })
+	time.Sleep(20 * time.Millisecond)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected a loop returning nil not to be restarted, ran %d times", n)
+	}
+	if st := status(t, s, "once"); st.Restarts != 0 {
+		t.Errorf("unexpected restarts %+v", st)
+	}
+}
+
+func TestSupervisor_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	s := New(Config{MinBackoff: time.Hour, Registerer: prometheus.NewRegistry()})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	failed := make(chan struct{})
+	s.Go(ctx, "backing_off", func(context.Context) error {
+		close(failed)
+		return errors.New("boom")
+	})
+	s.Go(ctx, "healthy", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+	<-failed
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		s.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after cancellation")
+	}
+	for _, st := range s.Status() {
+		if st.Running {
+			t.Errorf("expected %s stopped, got %+v", st.Name, st)
+		}
+	}
+}
+
+func TestSupervisor_DuplicateName(t *testing.T) {
+	t.Parallel()
+
+	s, ctx := newTestSupervisor(t, time.Millisecond, time.Millisecond)
+	loop := func(ctx context.Context) error { <-ctx.Done(); return nil }
+	s.Go(ctx, "evictor", loop)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected starting a loop name twice to panic")
+		}
+	}()
+	s.Go(ctx, "evictor", loop)
+}
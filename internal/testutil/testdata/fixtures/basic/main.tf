@@ -0,0 +1,11 @@
+provider "aws" {
+  region = var.region
+}
+
+resource "aws_s3_bucket" "logs" {
+  bucket = "${var.name}-logs"
+
+  tags = {
+    Name = var.name
+  }
+}
@@ -0,0 +1,9 @@
+variable "region" {
+  type        = string
+  description = "AWS region to deploy into"
+}
+
+variable "name" {
+  type        = string
+  description = "Name prefix for every resource"
+}
@@ -0,0 +1,5 @@
+resource "aws_vpc" "broken" {
+  cidr_block = "10.0.0.0/16"
+
+variable "region" {
+  type = string
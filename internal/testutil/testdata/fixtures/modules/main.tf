@@ -0,0 +1,12 @@
+module "vpc" {
+  source = "./modules/network/vpc"
+
+  name = "fixture"
+}
+
+module "spot_nodes" {
+  source = "./modules/platform/eks/nodegroups/spot"
+
+  name       = "fixture-spot"
+  subnet_ids = module.vpc.private_subnet_ids
+}
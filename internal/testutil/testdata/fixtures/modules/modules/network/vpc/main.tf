@@ -0,0 +1,13 @@
+resource "aws_vpc" "this" {
+  cidr_block = "10.0.0.0/16"
+
+  tags = {
+    Name = var.name
+  }
+}
+
+module "subnets" {
+  source = "./subnets"
+
+  vpc_id = aws_vpc.this.id
+}
@@ -0,0 +1,3 @@
+output "private_subnet_ids" {
+  value = module.subnets.ids
+}
@@ -0,0 +1,15 @@
+variable "vpc_id" {
+  type        = string
+  description = "VPC the subnets belong to"
+}
+
+resource "aws_subnet" "private" {
+  count = 2
+
+  vpc_id     = var.vpc_id
+  cidr_block = cidrsubnet("10.0.0.0/16", 8, count.index)
+}
+
+output "ids" {
+  value = aws_subnet.private[*].id
+}
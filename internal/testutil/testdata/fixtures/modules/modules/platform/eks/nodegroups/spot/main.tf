@@ -0,0 +1,23 @@
+variable "name" {
+  type        = string
+  description = "Name of the node group"
+}
+
+variable "subnet_ids" {
+  type        = list(string)
+  description = "Subnets the nodes run in"
+}
+
+resource "aws_eks_node_group" "spot" {
+  cluster_name    = "fixture"
+  node_group_name = var.name
+  node_role_arn   = "arn:aws:iam::123456789012:role/fixture-nodes"
+  subnet_ids      = var.subnet_ids
+  capacity_type   = "SPOT"
+
+  scaling_config {
+    desired_size = 2
+    max_size     = 4
+    min_size     = 1
+  }
+}
@@ -0,0 +1,11 @@
+include "root" {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "../../../modules//vpc"
+}
+
+inputs = {
+  name = "prod-vpc"
+}
@@ -0,0 +1,12 @@
+variable "name" {
+  type        = string
+  description = "Name tag of the VPC"
+}
+
+resource "aws_vpc" "this" {
+  cidr_block = "10.0.0.0/16"
+
+  tags = {
+    Name = var.name
+  }
+}
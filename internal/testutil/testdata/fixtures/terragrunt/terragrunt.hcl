@@ -0,0 +1,8 @@
+remote_state {
+  backend = "s3"
+  config = {
+    bucket = "fixture-terraform-state"
+    key    = "${path_relative_to_include()}/terraform.tfstate"
+    region = "eu-west-1"
+  }
+}
@@ -0,0 +1,210 @@
+// Package testutil builds Terraform workspaces for tests. A Workspace is a
+// directory under t.TempDir() assembled with a fluent API, either from
+// scratch or from one of the canonical fixtures embedded under
+// testdata/fixtures:
+//
+//	ws := testutil.NewWorkspace(t).
+//		WithFixture("basic").
+//		WithModule("modules/vpc").
+//		WithSymlink("shared.tf", "main.tf")
+//
+// Every method fails the test on error, so setup code needs no error
+// handling.
+package testutil
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Canonical fixture workspaces, by directory name under testdata/fixtures.
+const (
+	// FixtureBasic is a single root module with variables, outputs, a
+	// tfvars file and a provider lock file.
+	FixtureBasic = "basic"
+	// FixtureModules is a root module calling nested local modules up to
+	// five directories deep.
+	FixtureModules = "modules"
+	// FixtureTerragrunt is a terragrunt layout: a root terragrunt.hcl, two
+	// units under live/prod and the module they deploy.
+	FixtureTerragrunt = "terragrunt"
+	// FixtureBroken has one valid file and one with unclosed blocks.
+	FixtureBroken = "broken"
+)
+
+// fixtures holds the canonical workspaces. The all: prefix keeps dotfiles
+// such as .terraform.lock.hcl.
+//
+//go:embed all:testdata/fixtures
+var fixtures embed.FS
+
+// Workspace is a Terraform workspace under t.TempDir().
+type Workspace struct {
+	// t fails the test when a setup step fails.
+	t testing.TB
+	// dir is the workspace root.
+	dir string
+}
+
+// NewWorkspace returns an empty workspace in a new temporary directory,
+// removed when the test ends.
+func NewWorkspace(t testing.TB) *Workspace {
+	t.Helper()
+	return &Workspace{t: t, dir: t.TempDir()}
+}
+
+// Fixture returns a new workspace holding a copy of the named canonical
+// fixture.
+func Fixture(t testing.TB, name string) *Workspace {
+	t.Helper()
+	return NewWorkspace(t).WithFixture(name)
+}
+
+// Dir returns the workspace root.
+func (w *Workspace) Dir() string { return w.dir }
+
+// Path returns the absolute path of the slash-separated rel.
+func (w *Workspace) Path(rel string) string {
+	return filepath.Join(w.dir, filepath.FromSlash(rel))
+}
+
+// WithFile writes content to rel, creating parent directories.
+func (w *Workspace) WithFile(rel, content string) *Workspace {
+	w.t.Helper()
+	p := w.Path(rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		w.t.Fatalf("testutil: %v", err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		w.t.Fatalf("testutil: %v", err)
+	}
+	return w
+}
+
+// WithDir creates the directory rel and its parents.
+func (w *Workspace) WithDir(rel string) *Workspace {
+	w.t.Helper()
+	if err := os.MkdirAll(w.Path(rel), 0o755); err != nil {
+		w.t.Fatalf("testutil: %v", err)
+	}
+	return w
+}
+
+// WithFixture copies the named canonical fixture into the workspace root,
+// overwriting files of the same name.
+func (w *Workspace) WithFixture(name string) *Workspace {
+	w.t.Helper()
+	root := path.Join("testdata/fixtures", name)
+	if _, err := fs.Stat(fixtures, root); err != nil {
+		w.t.Fatalf("testutil: unknown fixture %q", name)
+	}
+	err := fs.WalkDir(fixtures, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fixtures.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		w.WithFile(strings.TrimPrefix(p, root+"/"), string(b))
+		return nil
+	})
+	if err != nil {
+		w.t.Fatalf("testutil: failed to copy fixture %q: %v", name, err)
+	}
+	return w
+}
+
+// WithModule writes a small, valid module to the directory rel: main.tf,
+// variables.tf with a typed and described variable, and outputs.tf.
+func (w *Workspace) WithModule(rel string) *Workspace {
+	w.t.Helper()
+	name := path.Base(rel)
+	w.WithFile(path.Join(rel, "main.tf"), "resource \"terraform_data\" \"this\" {\n  input = var.name\n}\n")
+	w.WithFile(path.Join(rel, "variables.tf"), fmt.Sprintf(
+		"variable \"name\" {\n  type        = string\n  description = \"Name of the %s resources\"\n}\n", name))
+	w.WithFile(path.Join(rel, "outputs.tf"), "output \"id\" {\n  value = terraform_data.this.id\n}\n")
+	return w
+}
+
+// WithTerragrunt writes a terragrunt unit to the directory rel: a
+// terragrunt.hcl that includes the parent configuration and deploys
+// source.
+func (w *Workspace) WithTerragrunt(rel, source string) *Workspace {
+	w.t.Helper()
+	return w.WithFile(path.Join(rel, "terragrunt.hcl"), fmt.Sprintf(
+		"include \"root\" {\n  path = find_in_parent_folders()\n}\n\n"+
+			"terraform {\n  source = %q\n}\n\n"+
+			"inputs = {\n  name = %q\n}\n", source, path.Base(rel)))
+}
+
+// WithBrokenHCL writes a file to rel whose block is never closed, so it
+// fails to parse.
+func (w *Workspace) WithBrokenHCL(rel string) *Workspace {
+	w.t.Helper()
+	return w.WithFile(rel, "resource \"aws_vpc\" \"broken\" {\n  cidr_block = \"10.0.0.0/16\"\n")
+}
+
+// WithLargeFile writes exactly size bytes of HCL comments to rel: a valid,
+// formatted file of any size.
+func (w *Workspace) WithLargeFile(rel string, size int) *Workspace {
+	w.t.Helper()
+	const line = "# generated padding to make this file large .................................\n"
+	var b strings.Builder
+	b.Grow(size)
+	for b.Len()+len(line) <= size {
+		b.WriteString(line)
+	}
+	if rest := size - b.Len(); rest > 1 {
+		b.WriteString("#" + strings.Repeat(".", rest-2) + "\n")
+	} else if rest == 1 {
+		b.WriteString("\n")
+	}
+	return w.WithFile(rel, b.String())
+}
+
+// WithSymlink creates a symbolic link at rel pointing to target, which is
+// used as given: a relative target resolves against rel's directory. The
+// test is skipped where symbolic links cannot be created.
+func (w *Workspace) WithSymlink(rel, target string) *Workspace {
+	w.t.Helper()
+	p := w.Path(rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		w.t.Fatalf("testutil: %v", err)
+	}
+	if err := os.Symlink(filepath.FromSlash(target), p); err != nil {
+		w.t.Skipf("testutil: symbolic links not supported: %v", err)
+	}
+	return w
+}
+
+// WithGit turns the workspace into a git repository and commits every file
+// in it. The test is skipped when git is not installed.
+func (w *Workspace) WithGit() *Workspace {
+	w.t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		w.t.Skip("testutil: git not installed")
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"config", "user.name", "tfai test"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "commit.gpgsign", "false"},
+		{"add", "-A"},
+		{"commit", "--quiet", "--allow-empty", "-m", "fixture"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = w.dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			w.t.Fatalf("testutil: git %s: %v: %s", args[0], err, out)
+		}
+	}
+	return w
+}
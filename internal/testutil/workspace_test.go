@@ -0,0 +1,113 @@
+package testutil
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// parses reports whether the HCL file at path parses without errors.
+func parses(t *testing.T, path string) bool {
+	t.Helper()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, diags := hclsyntax.ParseConfig(src, path, hcl.InitialPos)
+	return !diags.HasErrors()
+}
+
+// ---------------------------------------------------------------------------
+// Fixtures
+// ---------------------------------------------------------------------------
+
+func TestFixture(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		wantFiles []string
+		wantValid bool
+	}{
+		{name: FixtureBasic, wantFiles: []string{"main.tf", "terraform.tfvars", ".terraform.lock.hcl"}, wantValid: true},
+		{name: FixtureModules, wantFiles: []string{"main.tf", "modules/platform/eks/nodegroups/spot/main.tf"}, wantValid: true},
+		{name: FixtureTerragrunt, wantFiles: []string{"terragrunt.hcl", "live/prod/vpc/terragrunt.hcl"}, wantValid: true},
+		{name: FixtureBroken, wantFiles: []string{"main.tf", "network.tf"}},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ws := Fixture(t, tc.name)
+			for _, rel := range tc.wantFiles {
+				if _, err := os.Stat(ws.Path(rel)); err != nil {
+					t.Errorf("expected %s in the fixture: %v", rel, err)
+				}
+			}
+			valid := parses(t, ws.Path(tc.wantFiles[len(tc.wantFiles)-1]))
+			if valid != tc.wantValid {
+				t.Errorf("expected parse success=%v, got %v", tc.wantValid, valid)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Builder
+// ---------------------------------------------------------------------------
+
+func TestWorkspace_Builders(t *testing.T) {
+	t.Parallel()
+
+	ws := NewWorkspace(t).
+		WithModule("modules/vpc").
+		WithTerragrunt("live/dev/vpc", "../../../modules//vpc").
+		WithBrokenHCL("broken.tf").
+		WithLargeFile("large.tf", 4097).
+		WithDir(".terraform")
+
+	for _, rel := range []string{"modules/vpc/main.tf", "modules/vpc/variables.tf", "modules/vpc/outputs.tf", "live/dev/vpc/terragrunt.hcl", "large.tf"} {
+		if !parses(t, ws.Path(rel)) {
+			t.Errorf("expected %s to parse", rel)
+		}
+	}
+	if parses(t, ws.Path("broken.tf")) {
+		t.Error("expected broken.tf not to parse")
+	}
+	if info, err := os.Stat(ws.Path("large.tf")); err != nil || info.Size() != 4097 {
+		t.Errorf("expected large.tf to be 4097 bytes, got %v, %v", info, err)
+	}
+	if info, err := os.Stat(ws.Path(".terraform")); err != nil || !info.IsDir() {
+		t.Errorf("expected .terraform to be a directory, got %v", err)
+	}
+}
+
+func TestWorkspace_WithSymlink(t *testing.T) {
+	t.Parallel()
+
+	ws := NewWorkspace(t).WithModule("modules/vpc").WithSymlink("env/vpc", "../modules/vpc")
+	b, err := os.ReadFile(ws.Path("env/vpc/main.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "terraform_data") {
+		t.Errorf("expected the link to resolve to the module, got %q", b)
+	}
+}
+
+func TestWorkspace_WithGit(t *testing.T) {
+	t.Parallel()
+
+	ws := Fixture(t, FixtureBasic).WithGit()
+	out, err := exec.Command("git", "-C", ws.Dir(), "ls-files").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "versions.tf") {
+		t.Errorf("expected the fixture to be committed, got:\n%s", out)
+	}
+}
@@ -0,0 +1,162 @@
+// Package textenc decodes workspace files into the UTF-8, LF-terminated text
+// the model and the web UI work with, and re-encodes edited text so it keeps
+// the line-ending style of the file it replaces. Workspaces edited on Windows
+// commonly contain CRLF line endings and occasionally UTF-16 files; sending
+// those bytes to the model unmodified produces mojibake and fmt churn.
+package textenc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is the byte encoding a file was decoded from.
+type Encoding string
+
+const (
+	// UTF8 is plain UTF-8 without a byte-order mark.
+	UTF8 Encoding = "utf-8"
+	// UTF8BOM is UTF-8 preceded by the EF BB BF byte-order mark.
+	UTF8BOM Encoding = "utf-8-bom"
+	// UTF16LE is little-endian UTF-16 with a byte-order mark.
+	UTF16LE Encoding = "utf-16le"
+	// UTF16BE is big-endian UTF-16 with a byte-order mark.
+	UTF16BE Encoding = "utf-16be"
+)
+
+// LineEnding is the line terminator style of a file.
+type LineEnding string
+
+const (
+	// LF is Unix-style "\n". New files are written with LF.
+	LF LineEnding = "lf"
+	// CRLF is Windows-style "\r\n".
+	CRLF LineEnding = "crlf"
+)
+
+// ErrNotText is returned by Decode for content that is neither valid UTF-8
+// nor UTF-16 with a byte-order mark, such as binary files.
+var ErrNotText = errors.New("textenc: file is not UTF-8 or UTF-16 text")
+
+// Byte-order marks recognised by Decode.
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Text is a decoded file.
+type Text struct {
+	// Content is the decoded text with the byte-order mark removed and CRLF
+	// line endings normalised to LF.
+	Content string
+	// Encoding is the encoding the content was decoded from.
+	Encoding Encoding
+	// LineEnding is the file's original line-ending style.
+	LineEnding LineEnding
+}
+
+// Decode sniffs data's byte-order mark, transcodes UTF-16 to UTF-8, and
+// normalises CRLF to LF. It returns ErrNotText when data is not valid UTF-8
+// after any BOM is removed or contains NUL bytes, which valid Terraform never
+// does and which BOM-less UTF-16 and binary files always do.
+func Decode(data []byte) (Text, error) {
+	var (
+		content string
+		enc     Encoding
+	)
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		content, enc = string(data[len(bomUTF8):]), UTF8BOM
+	case bytes.HasPrefix(data, bomUTF16LE):
+		s, err := decodeUTF16(data[len(bomUTF16LE):], false)
+		if err != nil {
+			return Text{}, err
+		}
+		content, enc = s, UTF16LE
+	case bytes.HasPrefix(data, bomUTF16BE):
+		s, err := decodeUTF16(data[len(bomUTF16BE):], true)
+		if err != nil {
+			return Text{}, err
+		}
+		content, enc = s, UTF16BE
+	default:
+		content, enc = string(data), UTF8
+	}
+	if !utf8.ValidString(content) || strings.ContainsRune(content, 0) {
+		return Text{}, ErrNotText
+	}
+	le := DetectLineEnding(content)
+	return Text{
+		Content:    strings.ReplaceAll(content, "\r\n", "\n"),
+		Encoding:   enc,
+		LineEnding: le,
+	}, nil
+}
+
+// decodeUTF16 transcodes UTF-16 code units to UTF-8. An odd byte count or an
+// unpaired surrogate means the BOM was coincidental and the data is binary.
+func decodeUTF16(b []byte, bigEndian bool) (string, error) {
+	if len(b)%2 != 0 {
+		return "", ErrNotText
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		} else {
+			units[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+		}
+	}
+	runes := utf16.Decode(units)
+	for _, r := range runes {
+		if r == utf8.RuneError {
+			return "", ErrNotText
+		}
+	}
+	return string(runes), nil
+}
+
+// DetectLineEnding returns CRLF when most of the line breaks in s are
+// "\r\n", and LF otherwise (including when s has no line breaks).
+func DetectLineEnding(s string) LineEnding {
+	crlf := strings.Count(s, "\r\n")
+	if crlf > 0 && crlf >= strings.Count(s, "\n")-crlf {
+		return CRLF
+	}
+	return LF
+}
+
+// Encode converts LF-normalised content to le. Content that already contains
+// CRLF is normalised first so a round trip never produces "\r\r\n".
+func Encode(content string, le LineEnding) []byte {
+	if le != CRLF {
+		return []byte(content)
+	}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	return []byte(strings.ReplaceAll(content, "\n", "\r\n"))
+}
+
+// FileLineEnding returns the line-ending style of the existing file at path,
+// or LF when the file does not exist or cannot be decoded.
+func FileLineEnding(path string) LineEnding {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return LF
+	}
+	t, err := Decode(data)
+	if err != nil {
+		return LF
+	}
+	return t.LineEnding
+}
+
+// WriteFile writes LF-normalised content to path as UTF-8, keeping the
+// line-ending style of the file it replaces. New files are written with LF.
+func WriteFile(path, content string, perm os.FileMode) error {
+	return os.WriteFile(path, Encode(content, FileLineEnding(path)), perm) //nolint:wrapcheck // os errors already name the path
+}
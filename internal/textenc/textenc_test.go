@@ -0,0 +1,123 @@
+package textenc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// utf16Bytes encodes s as UTF-16 with a byte-order mark.
+func utf16Bytes(s string, bigEndian bool) []byte {
+	out := []byte{0xFF, 0xFE}
+	if bigEndian {
+		out = []byte{0xFE, 0xFF}
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Decode
+// ---------------------------------------------------------------------------
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	const hcl = "variable \"region\" {\n  default = \"eu-west-1\"\n}\n"
+	const crlf = "variable \"region\" {\r\n  default = \"eu-west-1\"\r\n}\r\n"
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Text
+		wantErr error
+	}{
+		{name: "utf-8", data: []byte(hcl), want: Text{hcl, UTF8, LF}},
+		{name: "utf-8 crlf", data: []byte(crlf), want: Text{hcl, UTF8, CRLF}},
+		{name: "utf-8 bom", data: append([]byte{0xEF, 0xBB, 0xBF}, hcl...), want: Text{hcl, UTF8BOM, LF}},
+		{name: "utf-16le crlf", data: utf16Bytes(crlf, false), want: Text{hcl, UTF16LE, CRLF}},
+		{name: "utf-16be", data: utf16Bytes("# café\n", true), want: Text{"# café\n", UTF16BE, LF}},
+		{name: "mostly lf", data: []byte("a\nb\nc\r\n"), want: Text{"a\nb\nc\n", UTF8, LF}},
+		{name: "no line breaks", data: []byte("# x"), want: Text{"# x", UTF8, LF}},
+		{name: "empty", data: nil, want: Text{"", UTF8, LF}},
+		{name: "binary", data: []byte{0x1F, 0x8B, 0x08, 0x00, 0xFF, 0x00}, wantErr: ErrNotText},
+		{name: "invalid utf-8", data: []byte("# caf\xE9\n"), wantErr: ErrNotText},
+		{name: "utf-16 without bom", data: utf16Bytes(hcl, false)[2:], wantErr: ErrNotText},
+		{name: "utf-16 odd length", data: []byte{0xFF, 0xFE, 0x41}, wantErr: ErrNotText},
+		{name: "utf-16 unpaired surrogate", data: []byte{0xFF, 0xFE, 0x00, 0xD8, 0x41, 0x00}, wantErr: ErrNotText},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := Decode(tc.data)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Encode and write-back
+// ---------------------------------------------------------------------------
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+
+	if got := string(Encode("a\nb\n", LF)); got != "a\nb\n" {
+		t.Errorf("LF: got %q", got)
+	}
+	if got := string(Encode("a\nb\n", CRLF)); got != "a\r\nb\r\n" {
+		t.Errorf("CRLF: got %q", got)
+	}
+	if got := string(Encode("a\r\nb\n", CRLF)); got != "a\r\nb\r\n" {
+		t.Errorf("CRLF must not double carriage returns, got %q", got)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		existing []byte
+		want     string
+	}{
+		{name: "new file", want: "a = 1\nb = 2\n"},
+		{name: "lf file", existing: []byte("old\n"), want: "a = 1\nb = 2\n"},
+		{name: "crlf file", existing: []byte("old\r\n"), want: "a = 1\r\nb = 2\r\n"},
+		{name: "utf-16le crlf file", existing: utf16Bytes("old\r\n", false), want: "a = 1\r\nb = 2\r\n"},
+		{name: "binary file", existing: []byte{0x00, 0x01, '\r', '\n'}, want: "a = 1\nb = 2\n"},
+	}
+	for _, tc := range tests {
+		path := filepath.Join(dir, tc.name+".tf")
+		if tc.existing != nil {
+			if err := os.WriteFile(path, tc.existing, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := WriteFile(path, "a = 1\nb = 2\n", 0o644); err != nil {
+			t.Fatalf("%s: WriteFile: %v", tc.name, err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+}
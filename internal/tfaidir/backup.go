@@ -0,0 +1,228 @@
+package tfaidir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBackups is the number of backup snapshots kept per workspace; taking
+// another removes the oldest.
+const MaxBackups = 10
+
+// MaxBackupFileBytes is the largest file Backup copies. Larger files are
+// overwritten without a backup.
+const MaxBackupFileBytes = 1 << 20 // 1 MiB
+
+// backupTimeFormat names backup snapshot directories. It sorts
+// chronologically and is safe in file names on every platform.
+const backupTimeFormat = "20060102T150405.000Z"
+
+// BackupSnapshot is one backup directory: the files one write replaced.
+type BackupSnapshot struct {
+	// Timestamp is the snapshot's directory name, passed to Restore.
+	Timestamp string
+	// Time is when the snapshot was taken, parsed from Timestamp.
+	Time time.Time
+	// Files lists the backed-up paths, slash-separated and relative to the
+	// workspace, sorted.
+	Files []string
+}
+
+// Backup copies the current content of each workspace-relative path that
+// exists into a new snapshot directory under the Backups subdirectory,
+// named after now, and removes the oldest snapshots beyond MaxBackups. Call
+// it before overwriting files. Missing files, non-regular files, and files
+// larger than MaxBackupFileBytes are skipped. It returns the snapshot
+// directory relative to workspace, or "" when nothing needed a backup.
+func Backup(workspace string, paths []string, now time.Time) (string, error) {
+	var rels []string
+	for _, rel := range paths {
+		info, err := os.Lstat(filepath.Join(workspace, rel))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("tfaidir: failed to stat %s for a backup: %w", rel, err)
+		}
+		if info.Mode().IsRegular() && info.Size() <= MaxBackupFileBytes {
+			rels = append(rels, rel)
+		}
+	}
+	if len(rels) == 0 {
+		return "", nil
+	}
+
+	root := Path(workspace, Backups)
+	if err := ensureDir(workspace, root); err != nil {
+		return "", err
+	}
+	dir, err := newSnapshotDir(root, now)
+	if err != nil {
+		return "", err
+	}
+	for _, rel := range rels {
+		if err := copyFile(filepath.Join(workspace, rel), filepath.Join(dir, rel)); err != nil {
+			return "", err
+		}
+	}
+	if err := pruneBackups(root); err != nil {
+		return "", err
+	}
+	rel, _ := filepath.Rel(workspace, dir)
+	return filepath.ToSlash(rel), nil
+}
+
+// newSnapshotDir creates the snapshot directory for now under root. A
+// snapshot taken in the same millisecond as another gets a numeric suffix,
+// which still sorts after it.
+func newSnapshotDir(root string, now time.Time) (string, error) {
+	name := now.UTC().Format(backupTimeFormat)
+	for i := 0; ; i++ {
+		dir := filepath.Join(root, name)
+		if i > 0 {
+			dir += "-" + strconv.Itoa(i)
+		}
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			return dir, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("tfaidir: failed to create backup %s: %w", dir, err)
+		}
+	}
+}
+
+// pruneBackups removes the oldest snapshots under root beyond MaxBackups.
+func pruneBackups(root string) error {
+	names, err := snapshotNames(root)
+	if err != nil {
+		return err
+	}
+	for len(names) > MaxBackups {
+		if err := os.RemoveAll(filepath.Join(root, names[0])); err != nil {
+			return fmt.Errorf("tfaidir: failed to remove old backup %s: %w", names[0], err)
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// snapshotNames returns the names of the snapshot directories under root,
+// oldest first. Entries whose names are not snapshot timestamps are
+// ignored.
+func snapshotNames(root string) ([]string, error) {
+	entries, err := os.ReadDir(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tfaidir: failed to read %s: %w", root, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if _, ok := snapshotTime(e.Name()); ok && e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// snapshotTime parses a snapshot directory name, ignoring any same-instant
+// suffix.
+func snapshotTime(name string) (time.Time, bool) {
+	stamp, _, _ := strings.Cut(name, "-")
+	t, err := time.Parse(backupTimeFormat, stamp)
+	return t, err == nil
+}
+
+// ListBackups returns the backup snapshots of workspace, newest first.
+func ListBackups(workspace string) ([]BackupSnapshot, error) {
+	root := Path(workspace, Backups)
+	names, err := snapshotNames(root)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]BackupSnapshot, 0, len(names))
+	for i := len(names) - 1; i >= 0; i-- {
+		files, err := snapshotFiles(filepath.Join(root, names[i]))
+		if err != nil {
+			return nil, err
+		}
+		t, _ := snapshotTime(names[i])
+		snapshots = append(snapshots, BackupSnapshot{Timestamp: names[i], Time: t, Files: files})
+	}
+	return snapshots, nil
+}
+
+// snapshotFiles returns the regular files under dir, slash-separated and
+// relative to it, sorted.
+func snapshotFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			rel, _ := filepath.Rel(dir, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tfaidir: failed to read backup %s: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// RestoreBackup copies every file of the snapshot named timestamp back into
+// workspace, replacing the current content, and returns the restored paths
+// as ListBackups reports them. Files written since the snapshot that it
+// does not hold are left alone.
+func RestoreBackup(workspace, timestamp string) ([]string, error) {
+	if _, ok := snapshotTime(timestamp); !ok || filepath.Base(timestamp) != timestamp {
+		return nil, fmt.Errorf("tfaidir: invalid backup timestamp %q", timestamp)
+	}
+	dir := filepath.Join(Path(workspace, Backups), timestamp)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("tfaidir: no backup %q in %s", timestamp, workspace)
+	}
+	files, err := snapshotFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range files {
+		if err := copyFile(filepath.Join(dir, filepath.FromSlash(rel)), filepath.Join(workspace, filepath.FromSlash(rel))); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// copyFile copies the regular file src to dst, creating dst's parent
+// directories and keeping src's permissions.
+func copyFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("tfaidir: failed to read %s: %w", src, err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return fmt.Errorf("tfaidir: failed to stat %s: %w", src, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("tfaidir: failed to create %s: %w", filepath.Dir(dst), err)
+	}
+	if err := os.WriteFile(dst, b, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("tfaidir: failed to write %s: %w", dst, err)
+	}
+	return nil
+}
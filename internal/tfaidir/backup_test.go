@@ -0,0 +1,128 @@
+package tfaidir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readFile returns the content of rel under dir.
+func readFile(t *testing.T, dir, rel string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, rel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestBackup(t *testing.T) {
+	t.Parallel()
+
+	ws := t.TempDir()
+	writeAged(t, ws, "main.tf", "# main\n", 0)
+	writeAged(t, ws, "modules/vpc/main.tf", "# vpc\n", 0)
+	writeAged(t, ws, "big.tf", strings.Repeat("#", MaxBackupFileBytes+1), 0)
+
+	dir, err := Backup(ws, []string{"main.tf", "modules/vpc/main.tf", "big.tf", "new.tf"}, now)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if want := ".tfai/backups/20240701T120000.000Z"; dir != want {
+		t.Errorf("expected backup dir %s, got %s", want, dir)
+	}
+	if got := readFile(t, ws, dir+"/main.tf"); got != "# main\n" {
+		t.Errorf("main.tf backup: got %q", got)
+	}
+	if got := readFile(t, ws, dir+"/modules/vpc/main.tf"); got != "# vpc\n" {
+		t.Errorf("modules/vpc/main.tf backup: got %q", got)
+	}
+	if exists(t, ws, dir+"/big.tf") || exists(t, ws, dir+"/new.tf") {
+		t.Error("expected large and missing files to be skipped")
+	}
+
+	// A second backup in the same millisecond gets its own directory.
+	again, err := Backup(ws, []string{"main.tf"}, now)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if again == dir || !exists(t, ws, again+"/main.tf") {
+		t.Errorf("expected a separate backup, got %s", again)
+	}
+
+	// Nothing to back up creates nothing.
+	if none, err := Backup(ws, []string{"new.tf", "big.tf"}, now); err != nil || none != "" {
+		t.Errorf("expected no backup, got %q (%v)", none, err)
+	}
+}
+
+func TestBackupRotation(t *testing.T) {
+	t.Parallel()
+
+	ws := t.TempDir()
+	writeAged(t, ws, "main.tf", "# main\n", 0)
+	var dirs []string
+	for i := range MaxBackups + 3 {
+		dir, err := Backup(ws, []string{"main.tf"}, now.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatalf("Backup %d: %v", i, err)
+		}
+		dirs = append(dirs, dir)
+	}
+
+	snapshots, err := ListBackups(ws)
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	if len(snapshots) != MaxBackups {
+		t.Fatalf("expected %d backups kept, got %d", MaxBackups, len(snapshots))
+	}
+	if newest := snapshots[0]; !newest.Time.Equal(now.Add((MaxBackups+2)*time.Minute)) || strings.Join(newest.Files, ",") != "main.tf" {
+		t.Errorf("expected the newest backup first, got %+v", newest)
+	}
+	for i, dir := range dirs {
+		if kept := exists(t, ws, dir); kept != (i >= 3) {
+			t.Errorf("backup %d (%s): kept = %v", i, dir, kept)
+		}
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	t.Parallel()
+
+	ws := t.TempDir()
+	writeAged(t, ws, "main.tf", "# v1\n", 0)
+	writeAged(t, ws, "modules/vpc/main.tf", "# vpc v1\n", 0)
+	dir, err := Backup(ws, []string{"main.tf", "modules/vpc/main.tf"}, now)
+	if err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	writeAged(t, ws, "main.tf", "# v2\n", 0)
+	writeAged(t, ws, "modules/vpc/main.tf", "# vpc v2\n", 0)
+	writeAged(t, ws, "outputs.tf", "# added since\n", 0)
+
+	files, err := RestoreBackup(ws, filepath.Base(dir))
+	if err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	if strings.Join(files, ",") != "main.tf,modules/vpc/main.tf" {
+		t.Errorf("unexpected restored files %v", files)
+	}
+	if got := readFile(t, ws, "main.tf"); got != "# v1\n" {
+		t.Errorf("main.tf: want the backed-up content, got %q", got)
+	}
+	if got := readFile(t, ws, "modules/vpc/main.tf"); got != "# vpc v1\n" {
+		t.Errorf("modules/vpc/main.tf: want the backed-up content, got %q", got)
+	}
+	if got := readFile(t, ws, "outputs.tf"); got != "# added since\n" {
+		t.Errorf("outputs.tf: want it left alone, got %q", got)
+	}
+
+	for _, bad := range []string{"", "nope", "../backups", "20240701T120000.000Z/../x", "20990101T000000.000Z"} {
+		if _, err := RestoreBackup(ws, bad); err == nil {
+			t.Errorf("RestoreBackup(%q): expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package tfaidir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Versioned lists the files in DirName that .tfai/.gitignore leaves
+// unignored: settings users may want to commit and share with their team.
+// Everything else in DirName is local state.
+var Versioned = []string{"settings.yaml", "policies.yaml", "secrets.allow"}
+
+// Markers delimiting the block UpdateGitignore manages in a workspace's own
+// .gitignore. Lines outside them are never changed.
+const (
+	gitignoreBegin = "# BEGIN tfai"
+	gitignoreEnd   = "# END tfai"
+)
+
+// ManageGitignoreEnv enables UpdateGitignore on every write under DirName
+// when set to "true" (config: workspace.manage_gitignore).
+const ManageGitignoreEnv = "TFAI_MANAGE_GITIGNORE"
+
+// ensureDir creates dir, which lies inside the DirName of workspace, and
+// keeps the artifacts tfai writes there out of git. Every write under
+// DirName goes through it.
+func ensureDir(workspace, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("tfaidir: failed to create %s: %w", dir, err)
+	}
+	if !inGitRepository(workspace) {
+		return nil
+	}
+	if err := EnsureGitignore(workspace); err != nil {
+		return err
+	}
+	if os.Getenv(ManageGitignoreEnv) == "true" {
+		return UpdateGitignore(workspace)
+	}
+	return nil
+}
+
+// inGitRepository reports whether dir or one of its parents holds a .git
+// entry, a directory in a normal clone or a file in a worktree or submodule.
+func inGitRepository(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for {
+		if _, err := os.Lstat(filepath.Join(abs, ".git")); err == nil {
+			return true
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return false
+		}
+		abs = parent
+	}
+}
+
+// dirGitignore returns the content of .tfai/.gitignore.
+func dirGitignore() string {
+	var b strings.Builder
+	b.WriteString("# Written by tfai. Backups, trash, and the manifest are local state;\n")
+	b.WriteString("# the files listed below are settings you may want to commit.\n")
+	b.WriteString("*\n!.gitignore\n")
+	for _, name := range Versioned {
+		b.WriteString("!" + name + "\n")
+	}
+	return b.String()
+}
+
+// EnsureGitignore writes .tfai/.gitignore in workspace, ignoring everything
+// in DirName except the Versioned files, unless the file already exists. An
+// existing file is left as it is, so edits to it are kept.
+func EnsureGitignore(workspace string) error {
+	path := filepath.Join(workspace, DirName, ".gitignore")
+	if _, err := os.Lstat(path); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("tfaidir: failed to stat %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(dirGitignore()), 0o644); err != nil {
+		return fmt.Errorf("tfaidir: failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// gitignoreBlock returns the marker-delimited block UpdateGitignore keeps in
+// a workspace's .gitignore. It repeats the rules of .tfai/.gitignore, for
+// tools that only read the top-level file, and ignores terraform's own
+// state backups.
+func gitignoreBlock() string {
+	var b strings.Builder
+	b.WriteString(gitignoreBegin + "\n")
+	b.WriteString(DirName + "/*\n")
+	for _, name := range append([]string{".gitignore"}, Versioned...) {
+		b.WriteString("!" + DirName + "/" + name + "\n")
+	}
+	b.WriteString("*.tfstate.backup\n*.tfstate.*.backup\n")
+	b.WriteString(gitignoreEnd + "\n")
+	return b.String()
+}
+
+// UpdateGitignore adds tfai's block to the .gitignore at the top of
+// workspace, creating the file if needed. A block from an earlier run is
+// replaced in place rather than added again, and the file is not rewritten
+// when the block is already current. A begin marker without an end marker
+// is an error, since the block's extent is then unknown.
+func UpdateGitignore(workspace string) error {
+	path := filepath.Join(workspace, ".gitignore")
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("tfaidir: failed to read %s: %w", path, err)
+	}
+	content := string(b)
+	block := gitignoreBlock()
+
+	var updated string
+	start := markerLine(content, gitignoreBegin, 0)
+	switch {
+	case start < 0:
+		updated = content
+		if updated != "" && !strings.HasSuffix(updated, "\n") {
+			updated += "\n"
+		}
+		if updated != "" {
+			updated += "\n"
+		}
+		updated += block
+	default:
+		end := markerLine(content, gitignoreEnd, start)
+		if end < 0 {
+			return fmt.Errorf("tfaidir: %s has %q without %q; fix the block by hand", path, gitignoreBegin, gitignoreEnd)
+		}
+		end += len(gitignoreEnd)
+		if end < len(content) && content[end] == '\r' {
+			end++
+		}
+		if end < len(content) && content[end] == '\n' {
+			end++
+		}
+		updated = content[:start] + block + content[end:]
+	}
+	if updated == content {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(updated), 0o644); err != nil {
+		return fmt.Errorf("tfaidir: failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// markerLine returns the offset in content of the first line at or after
+// from that is exactly marker, or -1.
+func markerLine(content, marker string, from int) int {
+	for off := from; off < len(content); {
+		line, _, _ := strings.Cut(content[off:], "\n")
+		if strings.TrimRight(line, "\r") == marker {
+			return off
+		}
+		off += len(line) + 1
+	}
+	return -1
+}
@@ -0,0 +1,158 @@
+package tfaidir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// .gitignore management
+// ---------------------------------------------------------------------------
+
+// gitWorkspace returns a workspace directory one level below a fake git
+// repository root.
+func gitWorkspace(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ws := filepath.Join(root, "infra")
+	if err := os.Mkdir(ws, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return ws
+}
+
+func TestEnsureDir_FirstWrite(t *testing.T) {
+	t.Parallel()
+
+	ws := gitWorkspace(t)
+	if err := RecordBaseline(ws, []string{"main.tf"}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(ws, DirName, ".gitignore")
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected .tfai/.gitignore after the first write: %v", err)
+	}
+	if string(got) != dirGitignore() || !strings.Contains(string(got), "*\n!.gitignore\n!settings.yaml\n!policies.yaml\n") {
+		t.Errorf("unexpected .tfai/.gitignore:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(ws, ".gitignore")); !os.IsNotExist(err) {
+		t.Errorf("expected the workspace .gitignore to be left alone, got %v", err)
+	}
+
+	// A later write keeps the user's edits.
+	edited := string(got) + "!notes.md\n"
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ws, "main.tf"), []byte("# main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Backup(ws, []string{"main.tf"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != edited {
+		t.Errorf("expected an existing .tfai/.gitignore to be kept, got:\n%s", got)
+	}
+}
+
+func TestEnsureDir_NotGit(t *testing.T) {
+	t.Parallel()
+
+	ws := t.TempDir()
+	if err := RecordBaseline(ws, []string{"main.tf"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(ws, DirName, ".gitignore")); !os.IsNotExist(err) {
+		t.Errorf("expected no .tfai/.gitignore outside a git repository, got %v", err)
+	}
+}
+
+func TestEnsureDir_ManageGitignore(t *testing.T) {
+	t.Setenv(ManageGitignoreEnv, "true")
+
+	ws := gitWorkspace(t)
+	if err := os.WriteFile(filepath.Join(ws, ".gitignore"), []byte(".terraform/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err := RecordBaseline(ws, []string{"main.tf"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := ResetManifest(ws); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := os.ReadFile(filepath.Join(ws, ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ".terraform/\n\n" + gitignoreBlock(); string(got) != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestUpdateGitignore(t *testing.T) {
+	t.Parallel()
+
+	block := gitignoreBlock()
+	stale := gitignoreBegin + "\n.tfai/\n" + gitignoreEnd + "\n"
+	tests := []struct {
+		name    string
+		initial *string
+		want    string
+		wantErr string
+	}{
+		{name: "no file", want: block},
+		{name: "empty file", initial: ptr(""), want: block},
+		{name: "appended after user rules", initial: ptr("*.tfvars\n.terraform/"), want: "*.tfvars\n.terraform/\n\n" + block},
+		{name: "current block unchanged", initial: ptr("a\n\n" + block + "b\n"), want: "a\n\n" + block + "b\n"},
+		{name: "stale block replaced in place", initial: ptr("a\n" + stale + "b\n"), want: "a\n" + block + "b\n"},
+		{name: "stale block with CRLF", initial: ptr("a\r\n# BEGIN tfai\r\n.tfai/\r\n# END tfai\r\nb\r\n"), want: "a\r\n" + block + "b\r\n"},
+		{name: "begin without end", initial: ptr("a\n" + gitignoreBegin + "\n.tfai/\n"), wantErr: "without"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ws := t.TempDir()
+			path := filepath.Join(ws, ".gitignore")
+			if tc.initial != nil {
+				if err := os.WriteFile(path, []byte(*tc.initial), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := UpdateGitignore(ws)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				if got, _ := os.ReadFile(path); string(got) != *tc.initial {
+					t.Errorf("expected the file to be left alone, got:\n%s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Running again changes nothing.
+			if err := UpdateGitignore(ws); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tc.want {
+				t.Errorf("expected:\n%q\ngot:\n%q", tc.want, got)
+			}
+			if strings.Count(string(got), gitignoreBegin) != 1 {
+				t.Errorf("expected exactly one tfai block, got:\n%s", got)
+			}
+		})
+	}
+}
+
+// ptr returns a pointer to s.
+func ptr(s string) *string { return &s }
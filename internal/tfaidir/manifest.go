@@ -0,0 +1,233 @@
+package tfaidir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/textenc"
+)
+
+// manifestFile is the name of the manifest inside DirName.
+const manifestFile = "manifest.json"
+
+// ErrNoManifest is returned by LoadManifest when tfai has not changed any
+// file since the manifest was last reset.
+var ErrNoManifest = errors.New("tfaidir: no manifest: tfai has not changed any files since the last reset")
+
+// manifestMu serialises manifest updates within the process, so concurrent
+// chats in one workspace do not lose each other's baselines.
+var manifestMu sync.Mutex
+
+// Manifest records the content every file had before tfai first changed it,
+// so the changes made since can be reviewed and described. It accumulates
+// across agent runs until ResetManifest, typically once the changes are
+// committed. It is stored directly in DirName, outside the artifact
+// subdirectories, so Clean never removes it.
+type Manifest struct {
+	// Created is when the first baseline was recorded.
+	Created time.Time `json:"created"`
+	// Files maps workspace-relative paths to their baseline.
+	Files map[string]Baseline `json:"files"`
+	// Written maps workspace-relative paths to the SHA-256 of the content
+	// tfai last wrote to them, so a later change by the user can be told
+	// apart from tfai's own (see UserModified).
+	Written map[string]string `json:"written,omitempty"`
+}
+
+// Baseline is a file as it was before tfai first changed it.
+type Baseline struct {
+	// Existed is false when tfai created the file.
+	Existed bool `json:"existed"`
+	// Binary is true when the file was not text; Content is then empty.
+	Binary bool `json:"binary,omitempty"`
+	// Content is the decoded file content, with LF line endings.
+	Content string `json:"content,omitempty"`
+}
+
+// manifestPath returns the path of the manifest file of workspace.
+func manifestPath(workspace string) string {
+	return filepath.Join(workspace, DirName, manifestFile)
+}
+
+// LoadManifest reads the manifest of workspace. It returns ErrNoManifest
+// when there is none.
+func LoadManifest(workspace string) (*Manifest, error) {
+	b, err := os.ReadFile(manifestPath(workspace))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoManifest
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tfaidir: failed to read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("tfaidir: invalid manifest %s: %w", manifestPath(workspace), err)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]Baseline)
+	}
+	return &m, nil
+}
+
+// RecordBaseline adds the current content of each workspace-relative path
+// to the manifest, unless the manifest already holds a baseline for it. Call
+// it before overwriting files, so the manifest keeps the content from before
+// the first change.
+func RecordBaseline(workspace string, paths []string) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	m, err := LoadManifest(workspace)
+	if errors.Is(err, ErrNoManifest) {
+		m, err = &Manifest{Created: time.Now().UTC(), Files: make(map[string]Baseline)}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, rel := range paths {
+		rel = filepath.ToSlash(filepath.Clean(rel))
+		if _, ok := m.Files[rel]; ok {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(workspace, filepath.FromSlash(rel)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			m.Files[rel] = Baseline{}
+		case err != nil:
+			return fmt.Errorf("tfaidir: failed to read %s for the manifest: %w", rel, err)
+		default:
+			text, err := textenc.Decode(b)
+			if err != nil {
+				m.Files[rel] = Baseline{Existed: true, Binary: true}
+			} else {
+				m.Files[rel] = Baseline{Existed: true, Content: text.Content}
+			}
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return writeManifest(workspace, m)
+}
+
+// RecordWritten records files, workspace-relative paths mapped to the
+// content tfai just wrote to them, as the content UserModified compares
+// against. Call it after writing.
+func RecordWritten(workspace string, files map[string]string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	m, err := LoadManifest(workspace)
+	if errors.Is(err, ErrNoManifest) {
+		m, err = &Manifest{Created: time.Now().UTC(), Files: make(map[string]Baseline)}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if m.Written == nil {
+		m.Written = make(map[string]string, len(files))
+	}
+	for rel, content := range files {
+		m.Written[filepath.ToSlash(filepath.Clean(rel))] = contentHash(content)
+	}
+	return writeManifest(workspace, m)
+}
+
+// UserModified returns the current content of each of the workspace-relative
+// paths whose content changed since tfai last wrote it (see RecordWritten),
+// keyed by the path as given. Files tfai has not written since the manifest
+// was last reset, deleted files, and binary files are left out.
+func UserModified(workspace string, paths []string) (map[string]string, error) {
+	m, err := LoadManifest(workspace)
+	if errors.Is(err, ErrNoManifest) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	modified := make(map[string]string)
+	for _, rel := range paths {
+		written, ok := m.Written[filepath.ToSlash(filepath.Clean(rel))]
+		if !ok {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(workspace, filepath.FromSlash(rel)))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tfaidir: failed to read %s: %w", rel, err)
+		}
+		text, err := textenc.Decode(b)
+		if err != nil {
+			continue
+		}
+		if contentHash(text.Content) != written {
+			modified[rel] = text.Content
+		}
+	}
+	return modified, nil
+}
+
+// contentHash returns the hex SHA-256 of content with LF line endings, so a
+// file that only had its line endings kept by textenc.WriteFile compares
+// equal.
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(strings.ReplaceAll(content, "\r\n", "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeManifest replaces the manifest of workspace with m atomically.
+func writeManifest(workspace string, m *Manifest) error {
+	dir := filepath.Join(workspace, DirName)
+	if err := ensureDir(workspace, dir); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("tfaidir: failed to encode manifest: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, manifestFile+".*")
+	if err != nil {
+		return fmt.Errorf("tfaidir: failed to write manifest: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("tfaidir: failed to write manifest: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("tfaidir: failed to write manifest: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), manifestPath(workspace)); err != nil {
+		return fmt.Errorf("tfaidir: failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// ResetManifest deletes the manifest of workspace, so the next change tfai
+// makes starts a new one. UserModified then reports no file until tfai
+// writes it again. It is not an error if there is none.
+func ResetManifest(workspace string) error {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	if err := os.Remove(manifestPath(workspace)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("tfaidir: failed to reset manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package tfaidir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if _, err := LoadManifest(dir); !errors.Is(err, ErrNoManifest) {
+		t.Fatalf("want ErrNoManifest before any change, got %v", err)
+	}
+
+	writeAged(t, dir, "main.tf", "original\r\n", 0)
+	writeAged(t, dir, "logo.png", "\x89PNG\x00", 0)
+	if err := RecordBaseline(dir, []string{"main.tf", "new.tf", "logo.png"}); err != nil {
+		t.Fatalf("RecordBaseline: %v", err)
+	}
+	// A later change to a recorded file keeps the first baseline.
+	writeAged(t, dir, "main.tf", "edited by tfai\n", 0)
+	if err := RecordBaseline(dir, []string{"main.tf", "./sub/../other.tf"}); err != nil {
+		t.Fatalf("RecordBaseline: %v", err)
+	}
+
+	m, err := LoadManifest(dir)
+	if err != nil {
+		t.Fatalf("LoadManifest: %v", err)
+	}
+	want := map[string]Baseline{
+		"main.tf":  {Existed: true, Content: "original\n"},
+		"new.tf":   {},
+		"logo.png": {Existed: true, Binary: true},
+		"other.tf": {},
+	}
+	if len(m.Files) != len(want) {
+		t.Errorf("want %d baselines, got %+v", len(want), m.Files)
+	}
+	for path, b := range want {
+		if got, ok := m.Files[path]; !ok || got != b {
+			t.Errorf("%s: want %+v, got %+v", path, b, got)
+		}
+	}
+	if m.Created.IsZero() {
+		t.Error("want the creation time recorded")
+	}
+
+	// Clean leaves the manifest alone.
+	if _, err := Clean(dir, CleanOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadManifest(dir); err != nil {
+		t.Errorf("want the manifest to survive Clean, got %v", err)
+	}
+
+	if err := ResetManifest(dir); err != nil {
+		t.Fatalf("ResetManifest: %v", err)
+	}
+	if _, err := LoadManifest(dir); !errors.Is(err, ErrNoManifest) {
+		t.Errorf("want ErrNoManifest after a reset, got %v", err)
+	}
+	if err := ResetManifest(dir); err != nil {
+		t.Errorf("want resetting twice to succeed, got %v", err)
+	}
+}
+
+func TestLoadManifest_Invalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeAged(t, dir, filepath.Join(DirName, manifestFile), "{", 0)
+	if _, err := LoadManifest(dir); err == nil || errors.Is(err, ErrNoManifest) {
+		t.Errorf("want a decode error, got %v", err)
+	}
+	if err := RecordBaseline(dir, []string{"main.tf"}); err == nil {
+		t.Error("want RecordBaseline to refuse to overwrite an unreadable manifest")
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, DirName, manifestFile)); string(b) != "{" {
+		t.Errorf("want the manifest left untouched, got %q", b)
+	}
+}
+
+func TestUserModified(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	paths := []string{"main.tf", "crlf.tf", "gone.tf", "mine.tf", "sub/vars.tf"}
+	if got, err := UserModified(dir, paths); err != nil || len(got) != 0 {
+		t.Fatalf("want nothing modified without a manifest, got %v, %v", got, err)
+	}
+
+	written := map[string]string{"main.tf": "a\n", "crlf.tf": "b\n", "gone.tf": "c\n", "sub/vars.tf": "d\n"}
+	for rel, content := range written {
+		writeAged(t, dir, rel, content, 0)
+	}
+	writeAged(t, dir, "mine.tf", "never written by tfai\n", 0)
+	if err := RecordWritten(dir, written); err != nil {
+		t.Fatalf("RecordWritten: %v", err)
+	}
+	if got, err := UserModified(dir, paths); err != nil || len(got) != 0 {
+		t.Fatalf("want nothing modified right after the write, got %v, %v", got, err)
+	}
+
+	writeAged(t, dir, "main.tf", "a\nedited\n", 0)
+	writeAged(t, dir, "crlf.tf", "b\r\n", 0)
+	writeAged(t, dir, "sub/vars.tf", "d\nedited\n", 0)
+	if err := os.Remove(filepath.Join(dir, "gone.tf")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := UserModified(dir, paths)
+	if err != nil {
+		t.Fatalf("UserModified: %v", err)
+	}
+	want := map[string]string{"main.tf": "a\nedited\n", "sub/vars.tf": "d\nedited\n"}
+	if len(got) != len(want) || got["main.tf"] != want["main.tf"] || got["sub/vars.tf"] != want["sub/vars.tf"] {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// Writing the file again makes its new content the reference.
+	if err := RecordWritten(dir, map[string]string{"main.tf": "a\nedited\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := UserModified(dir, []string{"main.tf"}); len(got) != 0 {
+		t.Errorf("want main.tf unmodified after tfai wrote it, got %q", got)
+	}
+}
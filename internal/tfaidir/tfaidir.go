@@ -0,0 +1,269 @@
+// Package tfaidir owns the .tfai directory that tfai keeps inside each
+// Terraform workspace. Every feature that stores artifacts there must use a
+// subdirectory registered in Subdirs, so that Clean can find all of them and
+// never touches anything else in the workspace.
+package tfaidir
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DirName is the name of the per-workspace tfai directory.
+const DirName = ".tfai"
+
+// Subdir names an artifact subdirectory under DirName.
+type Subdir string
+
+// Registered artifact subdirectories. Add new ones here and to Subdirs.
+const (
+	// Backups holds copies of files taken before the agent overwrites them.
+	Backups Subdir = "backups"
+	// Trash holds files deleted through tfai so they can be restored.
+	Trash Subdir = "trash"
+	// StateBackups holds copies of Terraform state taken before state edits.
+	StateBackups Subdir = "state-backups"
+	// Tmp holds agent responses spooled to disk while a query runs. Each
+	// is removed when its query ends; Clean removes any a crash left behind.
+	Tmp Subdir = "tmp"
+)
+
+// Subdirs is every registered artifact subdirectory. Clean only ever deletes
+// entries inside these directories.
+var Subdirs = []Subdir{Backups, Trash, StateBackups, Tmp}
+
+// Path returns the absolute path of sub inside workspace.
+func Path(workspace string, sub Subdir) string {
+	return filepath.Join(workspace, DirName, string(sub))
+}
+
+// TmpDir creates the Tmp directory of workspace and returns its path.
+func TmpDir(workspace string) (string, error) {
+	dir := Path(workspace, Tmp)
+	if err := ensureDir(workspace, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// ParseSubdirs parses a comma-separated list of subdirectory names. "all"
+// (or an empty string) selects every registered subdirectory.
+func ParseSubdirs(s string) ([]Subdir, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "all" {
+		return Subdirs, nil
+	}
+	var out []Subdir
+	seen := make(map[Subdir]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "all" {
+			return Subdirs, nil
+		}
+		sub := Subdir(part)
+		if !isRegistered(sub) {
+			return nil, fmt.Errorf("tfaidir: unknown artifact type %q (valid: %s, all)", part, subdirList())
+		}
+		if !seen[sub] {
+			seen[sub] = true
+			out = append(out, sub)
+		}
+	}
+	return out, nil
+}
+
+// ParseAge parses an age such as "30d", "12h", or "90m". A bare "d" suffix
+// means days; anything else is parsed by time.ParseDuration. An empty string
+// is zero, which means "any age".
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("tfaidir: invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("tfaidir: invalid age %q", s)
+	}
+	return d, nil
+}
+
+// CleanOptions selects what Clean removes.
+type CleanOptions struct {
+	// Subdirs limits cleaning to these subdirectories. Empty means all.
+	Subdirs []Subdir
+	// OlderThan only removes entries whose newest file is at least this old.
+	// Zero removes every entry.
+	OlderThan time.Duration
+	// DryRun reports what would be removed without deleting anything.
+	DryRun bool
+	// Now overrides the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Entry is one artifact that was (or would be) removed. An artifact is a
+// direct child of a registered subdirectory, file or directory.
+type Entry struct {
+	// Subdir is the registered subdirectory the entry lives in.
+	Subdir Subdir
+	// Path is the entry's path relative to the workspace.
+	Path string
+	// Bytes is the total size of the entry's regular files.
+	Bytes int64
+	// ModTime is the newest modification time within the entry.
+	ModTime time.Time
+}
+
+// CleanReport is the result of Clean.
+type CleanReport struct {
+	// Entries are the removed (or removable, in dry-run mode) artifacts,
+	// sorted by path.
+	Entries []Entry
+	// Bytes is the total size of Entries.
+	Bytes int64
+	// DryRun is true when nothing was actually deleted.
+	DryRun bool
+}
+
+// Clean removes aged artifacts from the registered subdirectories of
+// workspace. Only direct children of those subdirectories are candidates;
+// the subdirectories themselves, the rest of .tfai, and all user files are
+// left alone. A symlinked .tfai or subdirectory is skipped so a link cannot
+// redirect deletion outside the workspace.
+func Clean(workspace string, opts CleanOptions) (*CleanReport, error) {
+	subs := opts.Subdirs
+	if len(subs) == 0 {
+		subs = Subdirs
+	}
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	cutoff := now().Add(-opts.OlderThan)
+
+	report := &CleanReport{DryRun: opts.DryRun}
+	root := filepath.Join(workspace, DirName)
+	info, err := os.Lstat(root)
+	if errors.Is(err, fs.ErrNotExist) {
+		return report, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tfaidir: failed to stat %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return report, nil
+	}
+
+	for _, sub := range subs {
+		if !isRegistered(sub) {
+			return nil, fmt.Errorf("tfaidir: unknown artifact type %q", sub)
+		}
+		dir := Path(workspace, sub)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tfaidir: failed to stat %s: %w", dir, err)
+		}
+		if !info.IsDir() {
+			// A symlink or a stray file in place of the directory.
+			continue
+		}
+
+		children, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("tfaidir: failed to read %s: %w", dir, err)
+		}
+		for _, child := range children {
+			path := filepath.Join(dir, child.Name())
+			size, mod, err := measure(path)
+			if err != nil {
+				return nil, err
+			}
+			if opts.OlderThan > 0 && mod.After(cutoff) {
+				continue
+			}
+			if !opts.DryRun {
+				if err := os.RemoveAll(path); err != nil {
+					return nil, fmt.Errorf("tfaidir: failed to remove %s: %w", path, err)
+				}
+			}
+			rel, _ := filepath.Rel(workspace, path)
+			report.Entries = append(report.Entries, Entry{Subdir: sub, Path: rel, Bytes: size, ModTime: mod})
+			report.Bytes += size
+		}
+	}
+	sort.Slice(report.Entries, func(i, j int) bool { return report.Entries[i].Path < report.Entries[j].Path })
+	return report, nil
+}
+
+// measure returns the total size of the regular files under path and the
+// newest modification time among its files. Directory mtimes are ignored
+// (creating a backup touches its parent) unless path contains no files at
+// all. Symlinks are counted but not followed.
+func measure(path string) (int64, time.Time, error) {
+	var size int64
+	var newest, dirTime time.Time
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p == path {
+				dirTime = info.ModTime()
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("tfaidir: failed to inspect %s: %w", path, err)
+	}
+	if newest.IsZero() {
+		newest = dirTime
+	}
+	return size, newest, nil
+}
+
+// isRegistered reports whether sub is in Subdirs.
+func isRegistered(sub Subdir) bool {
+	for _, s := range Subdirs {
+		if s == sub {
+			return true
+		}
+	}
+	return false
+}
+
+// subdirList returns the registered subdirectory names, comma-separated.
+func subdirList() string {
+	names := make([]string, len(Subdirs))
+	for i, s := range Subdirs {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,280 @@
+package tfaidir
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// now is the fixed clock used by Clean tests.
+var now = time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+// writeAged writes content to rel under dir and sets its mtime to age before now.
+func writeAged(t *testing.T, dir, rel, content string, age time.Duration) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mt := now.Add(-age)
+	if err := os.Chtimes(path, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// exists reports whether rel exists under dir.
+func exists(t *testing.T, dir, rel string) bool {
+	t.Helper()
+	_, err := os.Lstat(filepath.Join(dir, rel))
+	return err == nil
+}
+
+// entryPaths returns the relative paths in r.
+func entryPaths(r *CleanReport) []string {
+	paths := make([]string, len(r.Entries))
+	for i, e := range r.Entries {
+		paths[i] = filepath.ToSlash(e.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+const day = 24 * time.Hour
+
+// newArtifactWorkspace builds a workspace with old and new artifacts in every
+// registered subdirectory plus user files, including user files inside .tfai
+// that are not in a registered subdirectory.
+func newArtifactWorkspace(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeAged(t, dir, "main.tf", "user", 90*day)
+	writeAged(t, dir, "backups/old.tf", "user dir named like an artifact dir", 90*day)
+	writeAged(t, dir, ".tfai/notes.txt", "unregistered", 90*day)
+	writeAged(t, dir, ".tfai/backups/2024-01-01/main.tf", "0123456789", 60*day)
+	writeAged(t, dir, ".tfai/backups/2024-06-30/main.tf", "new", 1*day)
+	writeAged(t, dir, ".tfai/trash/old.tf", "12345", 45*day)
+	writeAged(t, dir, ".tfai/state-backups/terraform.tfstate.1", "abc", 40*day)
+	return dir
+}
+
+// ---------------------------------------------------------------------------
+// Clean
+// ---------------------------------------------------------------------------
+
+func TestClean_OlderThanAll(t *testing.T) {
+	t.Parallel()
+
+	dir := newArtifactWorkspace(t)
+	r, err := Clean(dir, CleanOptions{OlderThan: 30 * day, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+
+	want := []string{".tfai/backups/2024-01-01", ".tfai/state-backups/terraform.tfstate.1", ".tfai/trash/old.tf"}
+	if got := entryPaths(r); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected entries %v, got %v", want, got)
+	}
+	if r.Bytes != 18 {
+		t.Errorf("expected 18 reclaimed bytes, got %d", r.Bytes)
+	}
+	for _, rel := range want {
+		if exists(t, dir, rel) {
+			t.Errorf("expected %s to be removed", rel)
+		}
+	}
+	for _, rel := range []string{"main.tf", "backups/old.tf", ".tfai/notes.txt", ".tfai/backups/2024-06-30/main.tf", ".tfai/trash", ".tfai/state-backups"} {
+		if !exists(t, dir, rel) {
+			t.Errorf("expected %s to be kept", rel)
+		}
+	}
+}
+
+func TestClean_Selective(t *testing.T) {
+	t.Parallel()
+
+	dir := newArtifactWorkspace(t)
+	r, err := Clean(dir, CleanOptions{Subdirs: []Subdir{Trash}, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+	if got := entryPaths(r); len(got) != 1 || got[0] != ".tfai/trash/old.tf" {
+		t.Errorf("expected only the trash entry, got %v", got)
+	}
+	if !exists(t, dir, ".tfai/backups/2024-01-01/main.tf") || !exists(t, dir, ".tfai/state-backups/terraform.tfstate.1") {
+		t.Error("expected backups and state-backups to be kept")
+	}
+}
+
+func TestClean_DryRun(t *testing.T) {
+	t.Parallel()
+
+	dir := newArtifactWorkspace(t)
+	dry, err := Clean(dir, CleanOptions{OlderThan: 30 * day, DryRun: true, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("Clean dry run: %v", err)
+	}
+	if !dry.DryRun {
+		t.Error("expected DryRun in report")
+	}
+	if !exists(t, dir, ".tfai/backups/2024-01-01/main.tf") {
+		t.Error("dry run deleted an artifact")
+	}
+
+	real, err := Clean(dir, CleanOptions{OlderThan: 30 * day, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+	if strings.Join(entryPaths(dry), ",") != strings.Join(entryPaths(real), ",") || dry.Bytes != real.Bytes {
+		t.Errorf("dry run reported %v (%d bytes), real run removed %v (%d bytes)",
+			entryPaths(dry), dry.Bytes, entryPaths(real), real.Bytes)
+	}
+}
+
+func TestClean_NewestFileKeepsDirectory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeAged(t, dir, ".tfai/backups/run/old.tf", "old", 60*day)
+	writeAged(t, dir, ".tfai/backups/run/new.tf", "new", 1*day)
+
+	r, err := Clean(dir, CleanOptions{OlderThan: 30 * day, Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+	if len(r.Entries) != 0 || !exists(t, dir, ".tfai/backups/run/old.tf") {
+		t.Errorf("expected a backup with a recent file to be kept, removed %v", entryPaths(r))
+	}
+}
+
+func TestClean_SymlinkedSubdirIsSkipped(t *testing.T) {
+	t.Parallel()
+
+	outside := t.TempDir()
+	writeAged(t, outside, "precious.tf", "keep me", 90*day)
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, DirName), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, Path(dir, Trash)); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+
+	r, err := Clean(dir, CleanOptions{Now: func() time.Time { return now }})
+	if err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+	if len(r.Entries) != 0 {
+		t.Errorf("expected nothing removed, got %v", entryPaths(r))
+	}
+	if !exists(t, outside, "precious.tf") {
+		t.Error("Clean followed a symlink out of the workspace")
+	}
+}
+
+func TestClean_NoTfaiDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeAged(t, dir, "main.tf", "user", 90*day)
+	r, err := Clean(dir, CleanOptions{})
+	if err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+	if len(r.Entries) != 0 || r.Bytes != 0 {
+		t.Errorf("expected empty report, got %+v", r)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Parsing
+// ---------------------------------------------------------------------------
+
+func TestParseSubdirs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    []Subdir
+		wantErr bool
+	}{
+		{in: "", want: Subdirs},
+		{in: "all", want: Subdirs},
+		{in: "trash", want: []Subdir{Trash}},
+		{in: "backups, state-backups,backups", want: []Subdir{Backups, StateBackups}},
+		{in: "trash,all", want: Subdirs},
+		{in: "manifest", wantErr: true},
+		{in: "../etc", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseSubdirs(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSubdirs: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("expected %v, got %v", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "", want: 0},
+		{in: "30d", want: 30 * day},
+		{in: "0d", want: 0},
+		{in: "12h", want: 12 * time.Hour},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "d", wantErr: true},
+		{in: "-3d", wantErr: true},
+		{in: "-1h", wantErr: true},
+		{in: "soon", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+			got, err := ParseAge(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAge: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
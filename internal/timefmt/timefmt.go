@@ -0,0 +1,41 @@
+// Package timefmt formats every externally visible timestamp — API
+// responses, reports, and log lines — the same way: RFC 3339 in UTC with
+// millisecond precision, e.g. 2024-06-01T12:00:00.000Z. Such strings sort
+// lexically in time order and do not depend on the host's locale or time
+// zone. Storage formats stay as they are; values are converted at the
+// boundary.
+package timefmt
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Layout is the time.Format layout of Format. Times are converted to UTC
+// first, so the zone is always "Z".
+const Layout = "2006-01-02T15:04:05.000Z07:00"
+
+// Format returns t in UTC as Layout.
+func Format(t time.Time) string {
+	return t.UTC().Format(Layout)
+}
+
+// Parse parses an RFC 3339 timestamp with any fractional precision, such as
+// one produced by Format, and returns it in UTC.
+func Parse(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timefmt: invalid timestamp %q: %w", s, err)
+	}
+	return t.UTC(), nil
+}
+
+// ReplaceAttr is a slog.HandlerOptions.ReplaceAttr that writes the record's
+// time, and any other time-valued attribute, with Format.
+func ReplaceAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindTime {
+		return slog.String(a.Key, Format(a.Value.Time()))
+	}
+	return a
+}
@@ -0,0 +1,88 @@
+package timefmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   time.Time
+		want string
+	}{
+		{name: "utc", in: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), want: "2024-06-01T12:00:00.000Z"},
+		{name: "other zone", in: time.Date(2024, 6, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*60*60)), want: "2024-06-01T12:00:00.000Z"},
+		{name: "truncated to milliseconds", in: time.Date(2024, 6, 1, 12, 0, 0, 999999999, time.UTC), want: "2024-06-01T12:00:00.999Z"},
+		{name: "unix seconds", in: time.Unix(1717243200, 0), want: "2024-06-01T12:00:00.000Z"},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := Format(tc.in); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFormat_SortsLexically(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2024, 6, 1, 23, 59, 59, 0, time.FixedZone("PDT", -7*60*60))
+	times := []time.Time{base, base.Add(-time.Hour), base.Add(2 * time.Millisecond), base.Add(24 * time.Hour), base.Add(-9 * time.Millisecond)}
+	strs := make([]string, len(times))
+	for i, tm := range times {
+		strs[i] = Format(tm)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	sort.Strings(strs)
+	for i := range times {
+		if strs[i] != Format(times[i]) {
+			t.Errorf("position %d: expected %s, got %s", i, Format(times[i]), strs[i])
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	in := time.Date(2024, 6, 1, 12, 0, 0, 123000000, time.UTC)
+	got, err := Parse(Format(in))
+	if err != nil || !got.Equal(in) || got.Location() != time.UTC {
+		t.Errorf("expected %v, got %v, %v", in, got, err)
+	}
+	if got, err := Parse("2024-06-01T14:00:00+02:00"); err != nil || Format(got) != "2024-06-01T12:00:00.000Z" {
+		t.Errorf("expected an offset timestamp to parse, got %v, %v", got, err)
+	}
+	if _, err := Parse("01/06/2024"); err == nil {
+		t.Error("expected an error for a non-RFC 3339 timestamp")
+	}
+}
+
+func TestReplaceAttr(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: ReplaceAttr}))
+	log.Info("hello", slog.Time("started", time.Date(2024, 6, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*60*60))))
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal(err)
+	}
+	if got := line["started"]; got != "2024-06-01T12:00:00.000Z" {
+		t.Errorf("expected the attribute formatted, got %v", got)
+	}
+	ts, _ := line[slog.TimeKey].(string)
+	if _, err := time.Parse(Layout, ts); err != nil || ts[len(ts)-1] != 'Z' {
+		t.Errorf("expected the record time formatted, got %q", ts)
+	}
+}
@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/54b3r/tfai-go/internal/audit"
+)
+
+// AllowApplyEnv must be "true" for the CLI and server to register ApplyTool.
+// Without it the agent has no way to apply changes.
+const AllowApplyEnv = "TFAI_ALLOW_APPLY"
+
+// applyOutputLines is how many trailing lines of `terraform apply` output
+// ApplyTool returns. Applies print a line per resource and can run to
+// thousands of lines; the end carries the summary and any errors.
+const applyOutputLines = 200
+
+// ApplyTool is an Eino tool that runs `terraform apply -auto-approve` in a
+// given workspace directory. It changes real infrastructure, so it is only
+// registered when AllowApplyEnv is set, every call must set confirm, and
+// every run is audit-logged at WARN.
+type ApplyTool struct {
+	// runner executes the terraform binary.
+	runner Runner
+}
+
+// applyInput is the JSON-serialisable input schema for ApplyTool.
+type applyInput struct {
+	// Dir is the absolute path to the Terraform working directory.
+	Dir string `json:"dir"`
+
+	// VarFiles is an optional list of .tfvars file paths.
+	VarFiles []string `json:"var_files,omitempty"`
+
+	// Confirm must be true; it makes the model state its intent to apply.
+	Confirm bool `json:"confirm"`
+}
+
+// NewApplyTool constructs an ApplyTool using the provided Runner.
+func NewApplyTool(runner Runner) *ApplyTool {
+	return &ApplyTool{runner: runner}
+}
+
+// Name returns the tool name registered with the agent.
+func (t *ApplyTool) Name() string { return "terraform_apply" }
+
+// RequiresConfirmation implements Confirmable: apply creates, changes, and
+// destroys real infrastructure.
+func (t *ApplyTool) RequiresConfirmation() bool { return true }
+
+// Description returns the LLM-facing description of this tool.
+func (t *ApplyTool) Description() string {
+	return "Runs `terraform apply -auto-approve` in the specified directory, changing real infrastructure, " +
+		"and returns the end of the output. Only call it when the user has explicitly asked to apply, " +
+		"after a terraform_plan they have seen, and set confirm to true."
+}
+
+// Info returns the Eino tool metadata including the JSON input schema.
+func (t *ApplyTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: t.Name(),
+		Desc: t.Description(),
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"dir": {
+				Type:     schema.String,
+				Desc:     "Absolute path to the Terraform working directory.",
+				Required: true,
+			},
+			"var_files": {
+				Type: schema.Array,
+				Desc: "Optional list of .tfvars file paths to pass to terraform apply.",
+				ElemInfo: &schema.ParameterInfo{
+					Type: schema.String,
+				},
+			},
+			"confirm": {
+				Type:     schema.Boolean,
+				Desc:     "Must be true: confirms the user asked for these changes to be applied.",
+				Required: true,
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun executes the tool given a JSON-encoded input string and returns
+// the last applyOutputLines lines of the apply output.
+func (t *ApplyTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var input applyInput
+	if err := json.Unmarshal([]byte(argumentsInJSON), &input); err != nil {
+		return "", fmt.Errorf("terraform_apply: invalid input: %w", err)
+	}
+	if input.Dir == "" {
+		return "", fmt.Errorf("terraform_apply: dir is required")
+	}
+	if !input.Confirm {
+		return "", fmt.Errorf("terraform_apply: confirm must be true to apply changes")
+	}
+
+	audit.LogEvent(ctx, audit.Event{
+		Action: "terraform apply",
+		Kind:   "terraform_apply",
+		Path:   input.Dir,
+		Actor:  "agent",
+		Level:  slog.LevelWarn,
+	}, slog.Any("var_files", input.VarFiles))
+
+	ws := &WorkspaceContext{Dir: input.Dir, VarFiles: input.VarFiles}
+	result, err := t.runner.Run(ctx, ws, "apply", "-auto-approve", "-no-color", "-input=false")
+	if err != nil {
+		return "", fmt.Errorf("terraform_apply: execution failed: %w", err)
+	}
+
+	output := result.Stdout
+	if result.Stderr != "" {
+		output += "\n--- stderr ---\n" + result.Stderr
+	}
+	output = tailLines(output, applyOutputLines)
+	if result.ExitCode != 0 {
+		return fmt.Sprintf("terraform apply exited with code %d:\n%s", result.ExitCode, output), nil
+	}
+	return output, nil
+}
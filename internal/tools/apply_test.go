@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/logging"
+)
+
+// ---------------------------------------------------------------------------
+// terraform_apply
+// ---------------------------------------------------------------------------
+
+func TestApplyTool_RequiresConfirm(t *testing.T) {
+	t.Parallel()
+
+	runner := &fakeRunner{result: &RunResult{}}
+	tool := NewApplyTool(runner)
+	for _, args := range []string{`{"dir":"/ws/app"}`, `{"dir":"/ws/app","confirm":false}`} {
+		_, err := tool.InvokableRun(context.Background(), args)
+		if err == nil || !strings.Contains(err.Error(), "confirm must be true") {
+			t.Errorf("%s: expected a confirm error, got %v", args, err)
+		}
+	}
+	if runner.subcommand != "" {
+		t.Errorf("expected terraform not to run without confirm, got %s %v", runner.subcommand, runner.args)
+	}
+	if !tool.RequiresConfirmation() {
+		t.Error("expected terraform_apply to require interactive confirmation too")
+	}
+}
+
+func TestApplyTool_InvokableRun(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	ctx := logging.WithLogger(context.Background(), slog.New(slog.NewJSONHandler(&logs, nil)))
+	runner := &fakeRunner{result: &RunResult{Stdout: "Apply complete! Resources: 1 added, 0 changed, 0 destroyed.\n"}}
+	got, err := NewApplyTool(runner).InvokableRun(ctx, `{"dir":"/ws/app","confirm":true}`)
+	if err != nil {
+		t.Fatalf("InvokableRun: %v", err)
+	}
+	if runner.dir != "/ws/app" || runner.subcommand != "apply" ||
+		!reflect.DeepEqual(runner.args, []string{"-auto-approve", "-no-color", "-input=false"}) {
+		t.Errorf("unexpected invocation: dir=%q %s %v", runner.dir, runner.subcommand, runner.args)
+	}
+	if got != "Apply complete! Resources: 1 added, 0 changed, 0 destroyed." {
+		t.Errorf("unexpected output %q", got)
+	}
+	line := logs.String()
+	if !strings.Contains(line, `"level":"WARN"`) || !strings.Contains(line, `"msg":"audit: terraform apply"`) ||
+		!strings.Contains(line, `"path":"/ws/app"`) {
+		t.Errorf("expected a WARN audit entry naming the workspace, got %s", line)
+	}
+}
+
+func TestApplyTool_Truncation(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	for i := range 500 {
+		fmt.Fprintf(&out, "aws_s3_object.o[%d]: Creation complete\n", i)
+	}
+	runner := &fakeRunner{result: &RunResult{Stdout: out.String(), Stderr: "Error: quota exceeded", ExitCode: 1}}
+	got, err := NewApplyTool(runner).InvokableRun(context.Background(), `{"dir":"/ws/app","confirm":true}`)
+	if err != nil {
+		t.Fatalf("InvokableRun: %v", err)
+	}
+	lines := strings.Split(got, "\n")
+	// The exit code header, the omission note, and applyOutputLines lines.
+	if len(lines) != applyOutputLines+2 {
+		t.Errorf("expected %d lines, got %d", applyOutputLines+2, len(lines))
+	}
+	if lines[0] != "terraform apply exited with code 1:" || !strings.HasPrefix(lines[1], "[303 earlier lines omitted]") {
+		t.Errorf("unexpected header %q / %q", lines[0], lines[1])
+	}
+	if !strings.HasSuffix(got, "--- stderr ---\nError: quota exceeded") {
+		t.Errorf("expected the stderr to be kept at the end, got ...%s", got[len(got)-80:])
+	}
+}
+
+func TestApplyTool_Errors(t *testing.T) {
+	t.Parallel()
+
+	tool := NewApplyTool(&fakeRunner{err: errors.New("exec: terraform: not found")})
+	for _, args := range []string{`not json`, `{"confirm":true}`, `{"dir":"/ws","confirm":true}`} {
+		if _, err := tool.InvokableRun(context.Background(), args); err == nil || !strings.HasPrefix(err.Error(), "terraform_apply: ") {
+			t.Errorf("%s: expected a terraform_apply error, got %v", args, err)
+		}
+	}
+}
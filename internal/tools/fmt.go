@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// FmtTool is an Eino tool that runs `terraform fmt` in a given workspace
+// directory, rewriting .tf and .tfvars files into canonical style, and
+// reports which files changed.
+type FmtTool struct {
+	// runner executes the terraform binary.
+	runner Runner
+}
+
+// fmtInput is the JSON-serialisable input schema for FmtTool.
+type fmtInput struct {
+	// Dir is the absolute path to the Terraform working directory.
+	Dir string `json:"dir"`
+
+	// Check reports unformatted files without rewriting them when true.
+	Check bool `json:"check,omitempty"`
+
+	// Recursive also formats files in subdirectories when true.
+	Recursive bool `json:"recursive,omitempty"`
+}
+
+// NewFmtTool constructs a FmtTool using the provided Runner.
+func NewFmtTool(runner Runner) *FmtTool {
+	return &FmtTool{runner: runner}
+}
+
+// Name returns the tool name registered with the agent.
+func (t *FmtTool) Name() string { return "terraform_fmt" }
+
+// Description returns the LLM-facing description of this tool.
+func (t *FmtTool) Description() string {
+	return "Runs `terraform fmt` in the specified directory to rewrite Terraform files into canonical style " +
+		"and returns the files that changed. Set check to only report unformatted files."
+}
+
+// Info returns the Eino tool metadata including the JSON input schema.
+func (t *FmtTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: t.Name(),
+		Desc: t.Description(),
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"dir": {
+				Type:     schema.String,
+				Desc:     "Absolute path to the Terraform working directory.",
+				Required: true,
+			},
+			"check": {
+				Type: schema.Boolean,
+				Desc: "If true, list unformatted files without rewriting them.",
+			},
+			"recursive": {
+				Type: schema.Boolean,
+				Desc: "If true, also process files in subdirectories.",
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun executes the tool given a JSON-encoded input string and returns
+// the list of changed (or, in check mode, unformatted) files.
+func (t *FmtTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var input fmtInput
+	if err := json.Unmarshal([]byte(argumentsInJSON), &input); err != nil {
+		return "", fmt.Errorf("terraform_fmt: invalid input: %w", err)
+	}
+	if input.Dir == "" {
+		return "", fmt.Errorf("terraform_fmt: dir is required")
+	}
+
+	args := []string{"-list=true", "-no-color"}
+	if input.Check {
+		args = append(args, "-check")
+	}
+	if input.Recursive {
+		args = append(args, "-recursive")
+	}
+
+	result, err := t.runner.Run(ctx, &WorkspaceContext{Dir: input.Dir}, "fmt", args...)
+	if err != nil {
+		return "", fmt.Errorf("terraform_fmt: execution failed: %w", err)
+	}
+
+	files := strings.Fields(result.Stdout)
+	// `fmt -check` exits 3 when files need formatting; anything else
+	// non-zero is a real failure such as a syntax error.
+	if result.ExitCode != 0 && !(input.Check && result.ExitCode == 3) {
+		output := result.Stdout
+		if result.Stderr != "" {
+			output += "\n--- stderr ---\n" + result.Stderr
+		}
+		return fmt.Sprintf("terraform fmt exited with code %d:\n%s", result.ExitCode, output), nil
+	}
+	switch {
+	case len(files) == 0:
+		return "terraform fmt: all files are already formatted.", nil
+	case input.Check:
+		return "terraform fmt: these files are not formatted:\n" + strings.Join(files, "\n"), nil
+	default:
+		return "terraform fmt: formatted these files:\n" + strings.Join(files, "\n"), nil
+	}
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// terraform_fmt
+// ---------------------------------------------------------------------------
+
+func TestFmtTool_InvokableRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		result   *RunResult
+		wantArgs []string
+		want     string
+	}{
+		{
+			name:     "formats files",
+			input:    `{"dir":"/ws/app"}`,
+			result:   &RunResult{Stdout: "main.tf\nvariables.tf\n"},
+			wantArgs: []string{"-list=true", "-no-color"},
+			want:     "terraform fmt: formatted these files:\nmain.tf\nvariables.tf",
+		},
+		{
+			name:     "already formatted",
+			input:    `{"dir":"/ws/app","recursive":true}`,
+			result:   &RunResult{},
+			wantArgs: []string{"-list=true", "-no-color", "-recursive"},
+			want:     "terraform fmt: all files are already formatted.",
+		},
+		{
+			name:     "check reports unformatted files",
+			input:    `{"dir":"/ws/app","check":true}`,
+			result:   &RunResult{Stdout: "main.tf\n", ExitCode: 3},
+			wantArgs: []string{"-list=true", "-no-color", "-check"},
+			want:     "terraform fmt: these files are not formatted:\nmain.tf",
+		},
+		{
+			name:     "syntax error",
+			input:    `{"dir":"/ws/app"}`,
+			result:   &RunResult{Stderr: "Error: Invalid expression", ExitCode: 2},
+			wantArgs: []string{"-list=true", "-no-color"},
+			want:     "terraform fmt exited with code 2:\n\n--- stderr ---\nError: Invalid expression",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &fakeRunner{result: tc.result}
+			got, err := NewFmtTool(runner).InvokableRun(context.Background(), tc.input)
+			if err != nil {
+				t.Fatalf("InvokableRun: %v", err)
+			}
+			if runner.dir != "/ws/app" || runner.subcommand != "fmt" || !reflect.DeepEqual(runner.args, tc.wantArgs) {
+				t.Errorf("unexpected invocation: dir=%q %s %v", runner.dir, runner.subcommand, runner.args)
+			}
+			if got != tc.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFmtTool_Errors(t *testing.T) {
+	t.Parallel()
+
+	tool := NewFmtTool(&fakeRunner{err: errors.New("exec: terraform: not found")})
+	for _, args := range []string{`not json`, `{}`, `{"dir":"/ws"}`} {
+		if _, err := tool.InvokableRun(context.Background(), args); err == nil || !strings.HasPrefix(err.Error(), "terraform_fmt: ") {
+			t.Errorf("%s: expected a terraform_fmt error, got %v", args, err)
+		}
+	}
+}
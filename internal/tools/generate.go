@@ -1,29 +1,101 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+
+	"github.com/54b3r/tfai-go/internal/envelope"
 )
 
 // GenerateTool is an Eino tool that writes LLM-generated Terraform HCL files
 // to a target directory on the local filesystem. The agent produces the HCL
 // content and this tool persists it, keeping file I/O out of the LLM context.
-type GenerateTool struct{}
+type GenerateTool struct {
+	// Limits bounds the number and size of files in a single call. Calls over
+	// any limit are rejected before the target directory is touched.
+	// Zero fields use the envelope package defaults.
+	Limits envelope.Limits
+}
 
 // generateInput is the JSON-serialisable input schema for GenerateTool.
 type generateInput struct {
 	// Dir is the absolute path to the directory where files will be written.
 	Dir string `json:"dir"`
 
-	// Files is a map of filename → HCL content to write.
-	Files map[string]string `json:"files"`
+	// Files holds the filename → HCL content pairs to write, in the order
+	// the model listed them.
+	Files generatedFiles `json:"files"`
+}
+
+// generatedFile is one entry of the files object.
+type generatedFile struct {
+	// Name is the filename relative to Dir.
+	Name string
+	// Content is the HCL to write.
+	Content string
+}
+
+// generatedFiles decodes the files object into a slice that keeps the
+// model's key order, so files are written, limit-checked, and reported in a
+// stable order rather than Go's random map order. A repeated name keeps its
+// first position and its last content, as a map would.
+type generatedFiles []generatedFile
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *generatedFiles) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err //nolint:wrapcheck // wrapped by InvokableRun
+	}
+	if tok == nil {
+		*f = nil
+		return nil
+	}
+	if tok != json.Delim('{') {
+		return fmt.Errorf("files must be an object of filename to content")
+	}
+	index := map[string]int{}
+	var out generatedFiles
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return err //nolint:wrapcheck // wrapped by InvokableRun
+		}
+		name, _ := keyTok.(string)
+		var content string
+		if err := dec.Decode(&content); err != nil {
+			return fmt.Errorf("file %q: %w", name, err)
+		}
+		if i, ok := index[name]; ok {
+			out[i].Content = content
+			continue
+		}
+		index[name] = len(out)
+		out = append(out, generatedFile{Name: name, Content: content})
+	}
+	*f = out
+	return nil
+}
+
+// All yields the name → content pairs in order.
+func (f generatedFiles) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, file := range f {
+			if !yield(file.Name, file.Content) {
+				return
+			}
+		}
+	}
 }
 
 // NewGenerateTool constructs a GenerateTool.
@@ -73,6 +145,9 @@ func (t *GenerateTool) InvokableRun(ctx context.Context, argumentsInJSON string,
 	if len(input.Files) == 0 {
 		return "", fmt.Errorf("terraform_generate: files map must not be empty")
 	}
+	if err := t.Limits.Check(input.Files.All()); err != nil {
+		return "", fmt.Errorf("terraform_generate: %w", err)
+	}
 
 	root := filepath.Clean(input.Dir)
 	if err := os.MkdirAll(root, 0o755); err != nil {
@@ -80,7 +155,7 @@ func (t *GenerateTool) InvokableRun(ctx context.Context, argumentsInJSON string,
 	}
 
 	written := make([]string, 0, len(input.Files))
-	for name, content := range input.Files {
+	for name, content := range input.Files.All() {
 		path := filepath.Join(root, name)
 		// Separator-aware confinement — prevents path traversal via LLM-supplied filenames.
 		if !strings.HasPrefix(path+string(filepath.Separator), root+string(filepath.Separator)) {
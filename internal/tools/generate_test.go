@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/envelope"
+)
+
+// ---------------------------------------------------------------------------
+// terraform_generate
+// ---------------------------------------------------------------------------
+
+func TestGenerateTool_KeepsFileOrder(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	input := fmt.Sprintf(`{"dir":%q,"files":{"variables.tf":"v","main.tf":"m1","outputs.tf":"o","main.tf":"m2"}}`, dir)
+
+	// Map iteration order is random, so one run could pass by chance.
+	for i := 0; i < 20; i++ {
+		got, err := NewGenerateTool().InvokableRun(context.Background(), input)
+		if err != nil {
+			t.Fatalf("InvokableRun: %v", err)
+		}
+		want := fmt.Sprintf("Successfully wrote 3 file(s) to %s:\n[%s %s %s]", dir,
+			filepath.Join(dir, "variables.tf"), filepath.Join(dir, "main.tf"), filepath.Join(dir, "outputs.tf"))
+		if got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "main.tf")); err != nil || string(b) != "m2" {
+		t.Errorf("expected a repeated name to keep its last content, got %q, %v", b, err)
+	}
+}
+
+func TestGenerateTool_LimitErrorIsStable(t *testing.T) {
+	t.Parallel()
+
+	tool := &GenerateTool{Limits: envelope.Limits{MaxFileBytes: 4}}
+	input := fmt.Sprintf(`{"dir":%q,"files":{"a.tf":"ok","b.tf":"too long","c.tf":"also too long"}}`, t.TempDir())
+	for i := 0; i < 20; i++ {
+		_, err := tool.InvokableRun(context.Background(), input)
+		var le *envelope.LimitError
+		if !errors.As(err, &le) || le.Path != "b.tf" {
+			t.Fatalf("expected the first oversized file b.tf reported, got %v", err)
+		}
+	}
+}
+
+func TestGenerateTool_InvalidFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"array":         `{"dir":"/tmp/x","files":["main.tf"]}`,
+		"non-string":    `{"dir":"/tmp/x","files":{"main.tf":1}}`,
+		"missing files": `{"dir":"/tmp/x"}`,
+	}
+	for name, input := range tests {
+		input := input
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewGenerateTool().InvokableRun(context.Background(), input)
+			if err == nil || !strings.HasPrefix(err.Error(), "terraform_generate: ") {
+				t.Errorf("expected a terraform_generate error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// initSummaryLines is how many trailing lines of `terraform init` output
+// InitTool returns. Earlier lines are provider and module download progress,
+// which is long and tells the model nothing the summary does not.
+const initSummaryLines = 15
+
+// InitTool is an Eino tool that runs `terraform init` in a given workspace
+// directory so that plan and validate can run in a freshly generated one.
+type InitTool struct {
+	// runner executes the terraform binary.
+	runner Runner
+}
+
+// initInput is the JSON-serialisable input schema for InitTool.
+type initInput struct {
+	// Dir is the absolute path to the Terraform working directory.
+	Dir string `json:"dir"`
+
+	// Upgrade picks the newest provider and module versions the
+	// constraints allow when true, instead of those in the lock file.
+	Upgrade bool `json:"upgrade,omitempty"`
+
+	// NoBackend skips backend initialisation when true.
+	NoBackend bool `json:"no_backend,omitempty"`
+}
+
+// NewInitTool constructs an InitTool using the provided Runner.
+func NewInitTool(runner Runner) *InitTool {
+	return &InitTool{runner: runner}
+}
+
+// Name returns the tool name registered with the agent.
+func (t *InitTool) Name() string { return "terraform_init" }
+
+// RequiresConfirmation implements Confirmable: init downloads providers and
+// modules and connects to the configured backend.
+func (t *InitTool) RequiresConfirmation() bool { return true }
+
+// Description returns the LLM-facing description of this tool.
+func (t *InitTool) Description() string {
+	return "Runs `terraform init` in the specified directory to install providers and modules and configure the backend. " +
+		"Run it before terraform_plan or terraform_validate in a workspace that has not been initialised, " +
+		"and again after changing provider, module, or backend configuration."
+}
+
+// Info returns the Eino tool metadata including the JSON input schema.
+func (t *InitTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: t.Name(),
+		Desc: t.Description(),
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"dir": {
+				Type:     schema.String,
+				Desc:     "Absolute path to the Terraform working directory.",
+				Required: true,
+			},
+			"upgrade": {
+				Type: schema.Boolean,
+				Desc: "If true, upgrade providers and modules to the newest versions the constraints allow.",
+			},
+			"no_backend": {
+				Type: schema.Boolean,
+				Desc: "If true, skip backend initialisation; enough for validate, but plan needs the backend.",
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun executes the tool given a JSON-encoded input string and returns
+// the end of the init output for the agent to consume.
+func (t *InitTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var input initInput
+	if err := json.Unmarshal([]byte(argumentsInJSON), &input); err != nil {
+		return "", fmt.Errorf("terraform_init: invalid input: %w", err)
+	}
+	if input.Dir == "" {
+		return "", fmt.Errorf("terraform_init: dir is required")
+	}
+
+	args := []string{"-no-color", "-input=false"}
+	if input.Upgrade {
+		args = append(args, "-upgrade")
+	}
+	if input.NoBackend {
+		args = append(args, "-backend=false")
+	}
+
+	result, err := t.runner.Run(ctx, &WorkspaceContext{Dir: input.Dir}, "init", args...)
+	if err != nil {
+		return "", fmt.Errorf("terraform_init: execution failed: %w", err)
+	}
+
+	output := tailLines(result.Stdout, initSummaryLines)
+	if result.Stderr != "" {
+		output += "\n--- stderr ---\n" + result.Stderr
+	}
+	if result.ExitCode != 0 {
+		return fmt.Sprintf("terraform init exited with code %d:\n%s", result.ExitCode, output), nil
+	}
+	return output, nil
+}
+
+// tailLines returns the last n lines of s, preceded by a note counting the
+// lines left out when there are more.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	omitted := len(lines) - n
+	return fmt.Sprintf("[%d earlier lines omitted]\n", omitted) + strings.Join(lines[omitted:], "\n")
+}
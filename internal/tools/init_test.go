@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// terraform_init
+// ---------------------------------------------------------------------------
+
+// initOutput is the stdout of an init that downloads providers lines
+// lines of progress before its summary.
+func initOutput(lines int) string {
+	var b strings.Builder
+	b.WriteString("Initializing the backend...\n")
+	for i := range lines {
+		fmt.Fprintf(&b, "- Installing hashicorp/p%d v1.0.0...\n", i)
+	}
+	b.WriteString("\nTerraform has been successfully initialized!\n")
+	return b.String()
+}
+
+func TestInitTool_InvokableRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		result   *RunResult
+		wantArgs []string
+		want     string
+	}{
+		{
+			name:     "defaults",
+			input:    `{"dir":"/ws/app"}`,
+			result:   &RunResult{Stdout: initOutput(1)},
+			wantArgs: []string{"-no-color", "-input=false"},
+			want:     strings.TrimRight(initOutput(1), "\n"),
+		},
+		{
+			name:     "upgrade without backend",
+			input:    `{"dir":"/ws/app","upgrade":true,"no_backend":true}`,
+			result:   &RunResult{Stdout: initOutput(1)},
+			wantArgs: []string{"-no-color", "-input=false", "-upgrade", "-backend=false"},
+			want:     strings.TrimRight(initOutput(1), "\n"),
+		},
+		{
+			name:     "download noise truncated",
+			input:    `{"dir":"/ws/app"}`,
+			result:   &RunResult{Stdout: initOutput(40)},
+			wantArgs: []string{"-no-color", "-input=false"},
+			want: "[28 earlier lines omitted]\n" +
+				strings.Join(strings.Split(strings.TrimRight(initOutput(40), "\n"), "\n")[28:], "\n"),
+		},
+		{
+			name:     "failure keeps stderr",
+			input:    `{"dir":"/ws/app"}`,
+			result:   &RunResult{Stdout: "Initializing the backend...\n", Stderr: "Error: Failed to query available provider packages", ExitCode: 1},
+			wantArgs: []string{"-no-color", "-input=false"},
+			want:     "terraform init exited with code 1:\nInitializing the backend...\n--- stderr ---\nError: Failed to query available provider packages",
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &fakeRunner{result: tc.result}
+			got, err := NewInitTool(runner).InvokableRun(context.Background(), tc.input)
+			if err != nil {
+				t.Fatalf("InvokableRun: %v", err)
+			}
+			if runner.dir != "/ws/app" || runner.subcommand != "init" || !reflect.DeepEqual(runner.args, tc.wantArgs) {
+				t.Errorf("unexpected invocation: dir=%q %s %v", runner.dir, runner.subcommand, runner.args)
+			}
+			if got != tc.want {
+				t.Errorf("expected:\n%s\ngot:\n%s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestInitTool_Errors(t *testing.T) {
+	t.Parallel()
+
+	tool := NewInitTool(&fakeRunner{err: errors.New("exec: terraform: not found")})
+	for _, args := range []string{`not json`, `{}`, `{"dir":"/ws"}`} {
+		if _, err := tool.InvokableRun(context.Background(), args); err == nil || !strings.HasPrefix(err.Error(), "terraform_init: ") {
+			t.Errorf("%s: expected a terraform_init error, got %v", args, err)
+		}
+	}
+}
@@ -32,6 +32,14 @@ type TerraformTool interface {
 	Description() string
 }
 
+// Confirmable is implemented by tools whose calls reach beyond the local
+// workspace, such as cloud provider APIs or state backends, so interactive
+// callers can ask the user before they run.
+type Confirmable interface {
+	// RequiresConfirmation reports whether calls need the user's approval.
+	RequiresConfirmation() bool
+}
+
 // WorkspaceContext carries the resolved path and optional configuration for
 // the Terraform workspace the agent is currently operating on.
 type WorkspaceContext struct {
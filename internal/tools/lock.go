@@ -0,0 +1,181 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/54b3r/tfai-go/internal/hclinspect"
+)
+
+// platformPattern matches a terraform platform name such as "linux_amd64".
+var platformPattern = regexp.MustCompile(`^[a-z0-9]+_[a-z0-9]+$`)
+
+// LockInfoTool is an Eino tool that reports the providers recorded in a
+// workspace's .terraform.lock.hcl, which buildWorkspaceContext only
+// summarises, and can run `terraform providers lock` to record checksums
+// for more platforms.
+type LockInfoTool struct {
+	// runner executes the terraform binary.
+	runner Runner
+}
+
+// lockInput is the JSON-serialisable input schema for LockInfoTool.
+type lockInput struct {
+	// Dir is the absolute path to the Terraform working directory.
+	Dir string `json:"dir"`
+
+	// Subcommand is "show" (the default) or "lock".
+	Subcommand string `json:"subcommand,omitempty"`
+
+	// Platforms are the platforms "lock" records checksums for.
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// lockedProvider is one provider in the "show" output.
+type lockedProvider struct {
+	// Source is the provider address, e.g. "registry.terraform.io/hashicorp/aws".
+	Source string `json:"source"`
+	// Version is the selected version.
+	Version string `json:"version"`
+	// Constraints is the version constraint the selection satisfied, if any.
+	Constraints string `json:"constraints,omitempty"`
+	// Hashes is the number of recorded checksums.
+	Hashes int `json:"hashes"`
+}
+
+// NewLockInfoTool constructs a LockInfoTool using the provided Runner.
+func NewLockInfoTool(runner Runner) *LockInfoTool {
+	return &LockInfoTool{runner: runner}
+}
+
+// Name returns the tool name registered with the agent.
+func (t *LockInfoTool) Name() string { return "terraform_providers_lock" }
+
+// RequiresConfirmation implements Confirmable: the lock subcommand downloads
+// provider packages from the registry and rewrites the lock file.
+func (t *LockInfoTool) RequiresConfirmation() bool { return true }
+
+// Description returns the LLM-facing description of this tool.
+func (t *LockInfoTool) Description() string {
+	return "Reads .terraform.lock.hcl in the specified directory. " +
+		"Subcommands: 'show' (default) returns each locked provider's address, selected version, version constraints, " +
+		"and number of recorded checksums as JSON; 'lock' runs `terraform providers lock` for the given platforms " +
+		"(e.g. linux_amd64, darwin_arm64) to record their checksums. " +
+		"Use this to diagnose provider version conflicts and checksum mismatch errors."
+}
+
+// Info returns the Eino tool metadata including the JSON input schema.
+func (t *LockInfoTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: t.Name(),
+		Desc: t.Description(),
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"dir": {
+				Type:     schema.String,
+				Desc:     "Absolute path to the Terraform working directory.",
+				Required: true,
+			},
+			"subcommand": {
+				Type: schema.String,
+				Desc: "'show' (default) or 'lock'.",
+				Enum: []string{"show", "lock"},
+			},
+			"platforms": {
+				Type: schema.Array,
+				Desc: "Platforms to record checksums for with 'lock', e.g. [\"linux_amd64\", \"darwin_arm64\"].",
+				ElemInfo: &schema.ParameterInfo{
+					Type: schema.String,
+				},
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun executes the tool given a JSON-encoded input string. dir must
+// lie within the query's workspace (see WithWorkspace).
+func (t *LockInfoTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var input lockInput
+	if err := json.Unmarshal([]byte(argumentsInJSON), &input); err != nil {
+		return "", fmt.Errorf("terraform_providers_lock: invalid input: %w", err)
+	}
+	if input.Dir == "" {
+		return "", fmt.Errorf("terraform_providers_lock: dir is required")
+	}
+	dir, err := scopedDir(ctx, input.Dir)
+	if err != nil {
+		return "", fmt.Errorf("terraform_providers_lock: %w", err)
+	}
+
+	switch input.Subcommand {
+	case "", "show":
+		return showLockfile(dir)
+	case "lock":
+		return t.lock(ctx, dir, input.Platforms)
+	default:
+		return "", fmt.Errorf("terraform_providers_lock: unsupported subcommand %q; use 'show' or 'lock'", input.Subcommand)
+	}
+}
+
+// showLockfile returns the providers locked in dir as JSON.
+func showLockfile(dir string) (string, error) {
+	providers, err := hclinspect.ReadLockfile(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Sprintf("%s has no %s; run terraform_init to create it.", dir, hclinspect.LockfileName), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("terraform_providers_lock: %w", err)
+	}
+	out := make([]lockedProvider, 0, len(providers))
+	for _, p := range providers {
+		out = append(out, lockedProvider{
+			Source:      p.Source,
+			Version:     p.Version,
+			Constraints: p.Constraints,
+			Hashes:      len(p.Hashes),
+		})
+	}
+	// Constraints such as "~> 5.0" read better unescaped.
+	var sb strings.Builder
+	enc := json.NewEncoder(&sb)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(out); err != nil {
+		return "", fmt.Errorf("terraform_providers_lock: %w", err)
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+// lock runs `terraform providers lock` in dir for platforms.
+func (t *LockInfoTool) lock(ctx context.Context, dir string, platforms []string) (string, error) {
+	if len(platforms) == 0 {
+		return "", fmt.Errorf("terraform_providers_lock: platforms is required for 'lock'")
+	}
+	args := []string{"lock"}
+	for _, p := range platforms {
+		if !platformPattern.MatchString(p) {
+			return "", fmt.Errorf("terraform_providers_lock: invalid platform %q; want os_arch, e.g. linux_amd64", p)
+		}
+		args = append(args, "-platform="+p)
+	}
+
+	result, err := t.runner.Run(ctx, &WorkspaceContext{Dir: dir}, "providers", args...)
+	if err != nil {
+		return "", fmt.Errorf("terraform_providers_lock: execution failed: %w", err)
+	}
+
+	output := result.Stdout
+	if result.Stderr != "" {
+		output += "\n--- stderr ---\n" + result.Stderr
+	}
+	if result.ExitCode != 0 {
+		return fmt.Sprintf("terraform providers lock exited with code %d:\n%s", result.ExitCode, output), nil
+	}
+	return output, nil
+}
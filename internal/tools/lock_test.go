@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// terraform_providers_lock
+// ---------------------------------------------------------------------------
+
+func TestLockInfoTool_Show(t *testing.T) {
+	t.Parallel()
+
+	dir, err := filepath.Abs("testdata/lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := &fakeRunner{}
+	ctx := WithWorkspace(context.Background(), dir)
+	got, err := NewLockInfoTool(runner).InvokableRun(ctx, `{"dir":"`+dir+`"}`)
+	if err != nil {
+		t.Fatalf("InvokableRun: %v", err)
+	}
+	want := `[{"source":"registry.terraform.io/hashicorp/aws","version":"5.31.0","constraints":"~> 5.0","hashes":3},` +
+		`{"source":"registry.terraform.io/hashicorp/random","version":"3.6.0","hashes":3}]`
+	if got != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, want)
+	}
+	if runner.subcommand != "" {
+		t.Errorf("expected show not to run terraform, got %s %v", runner.subcommand, runner.args)
+	}
+
+	// A relative dir is taken relative to the workspace.
+	if rel, err := NewLockInfoTool(runner).InvokableRun(ctx, `{"dir":".","subcommand":"show"}`); err != nil || rel != want {
+		t.Errorf("expected the same output for a relative dir, got %q, %v", rel, err)
+	}
+
+	missing := t.TempDir()
+	got, err = NewLockInfoTool(runner).InvokableRun(context.Background(), `{"dir":"`+missing+`"}`)
+	if err != nil || !strings.Contains(got, "has no .terraform.lock.hcl") {
+		t.Errorf("expected a note about the missing lock file, got %q, %v", got, err)
+	}
+}
+
+func TestLockInfoTool_Lock(t *testing.T) {
+	t.Parallel()
+
+	runner := &fakeRunner{result: &RunResult{Stdout: "Success! Terraform has updated the lock file.\n"}}
+	got, err := NewLockInfoTool(runner).InvokableRun(context.Background(),
+		`{"dir":"/ws/app","subcommand":"lock","platforms":["linux_amd64","darwin_arm64"]}`)
+	if err != nil {
+		t.Fatalf("InvokableRun: %v", err)
+	}
+	if runner.dir != "/ws/app" || runner.subcommand != "providers" ||
+		!reflect.DeepEqual(runner.args, []string{"lock", "-platform=linux_amd64", "-platform=darwin_arm64"}) {
+		t.Errorf("unexpected invocation: dir=%q %s %v", runner.dir, runner.subcommand, runner.args)
+	}
+	if got != "Success! Terraform has updated the lock file.\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestLockInfoTool_RejectsOutsideWorkspace(t *testing.T) {
+	t.Parallel()
+
+	runner := &fakeRunner{result: &RunResult{}}
+	ctx := WithWorkspace(context.Background(), "/ws/app")
+	for _, args := range []string{
+		`{"dir":"/ws/other"}`,
+		`{"dir":"/ws/app/../other"}`,
+		`{"dir":"../other"}`,
+		`{"dir":"/","subcommand":"lock","platforms":["linux_amd64"]}`,
+	} {
+		_, err := NewLockInfoTool(runner).InvokableRun(ctx, args)
+		if err == nil || !strings.Contains(err.Error(), "is outside the workspace") {
+			t.Errorf("%s: expected an outside-workspace error, got %v", args, err)
+		}
+	}
+	if runner.subcommand != "" {
+		t.Errorf("expected terraform not to run, got %s %v", runner.subcommand, runner.args)
+	}
+	if _, err := NewLockInfoTool(runner).InvokableRun(ctx, `{"dir":"/ws/app/modules/vpc","subcommand":"lock","platforms":["linux_amd64"]}`); err != nil {
+		t.Errorf("expected a subdirectory of the workspace to be allowed, got %v", err)
+	}
+}
+
+func TestLockInfoTool_Errors(t *testing.T) {
+	t.Parallel()
+
+	tool := NewLockInfoTool(&fakeRunner{result: &RunResult{}})
+	for _, args := range []string{
+		`not json`,
+		`{}`,
+		`{"dir":"/ws","subcommand":"upgrade"}`,
+		`{"dir":"/ws","subcommand":"lock"}`,
+		`{"dir":"/ws","subcommand":"lock","platforms":["linux_amd64 -fs-mirror=/tmp"]}`,
+	} {
+		if _, err := tool.InvokableRun(context.Background(), args); err == nil || !strings.HasPrefix(err.Error(), "terraform_providers_lock: ") {
+			t.Errorf("%s: expected a terraform_providers_lock error, got %v", args, err)
+		}
+	}
+}
@@ -4,16 +4,47 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 )
 
+// DefaultPlanSummaryBytes is the default size cap of the summary PlanTool
+// returns. See PlanTool.WithSummaryBytes.
+const DefaultPlanSummaryBytes = 8 << 10 // 8 KiB
+
+// planOmittedReserve is the room summarizePlan keeps for its "... and N
+// more lines omitted" line.
+const planOmittedReserve = 40
+
+// PlanSummaryBytesEnv overrides DefaultPlanSummaryBytes for the CLI and
+// server.
+const PlanSummaryBytesEnv = "TFAI_PLAN_SUMMARY_BYTES"
+
+// planActions is the order in which the summary lists each action's
+// resources, with the heading used for it.
+var planActions = []struct {
+	action, heading string
+}{
+	{"create", "create"},
+	{"replace", "replace"},
+	{"update", "update"},
+	{"delete", "destroy"},
+	{"read", "read"},
+}
+
 // PlanTool is an Eino tool that runs `terraform plan` in a given workspace
-// directory and returns the plan output for the agent to analyse.
+// directory and returns a summary of the planned resource changes for the
+// agent to analyse, or the full plan text when asked for raw output.
 type PlanTool struct {
 	// runner executes the terraform binary.
 	runner Runner
+
+	// summaryBytes caps the size of the summary.
+	summaryBytes int
 }
 
 // planInput is the JSON-serialisable input schema for PlanTool.
@@ -26,20 +57,54 @@ type planInput struct {
 
 	// Destroy requests a destroy plan when true.
 	Destroy bool `json:"destroy,omitempty"`
+
+	// Raw returns the human-readable plan text instead of the summary.
+	Raw bool `json:"raw,omitempty"`
+}
+
+// planJSON is the subset of the `terraform show -json` plan document the
+// summary reports.
+type planJSON struct {
+	// ResourceChanges lists every resource instance the plan considered.
+	ResourceChanges []struct {
+		// Address is the absolute resource instance address.
+		Address string `json:"address"`
+		// Change holds the planned actions.
+		Change struct {
+			// Actions is e.g. ["create"], ["update"], ["delete", "create"],
+			// or ["no-op"].
+			Actions []string `json:"actions"`
+		} `json:"change"`
+	} `json:"resource_changes"`
 }
 
 // NewPlanTool constructs a PlanTool using the provided Runner.
 func NewPlanTool(runner Runner) *PlanTool {
-	return &PlanTool{runner: runner}
+	return &PlanTool{runner: runner, summaryBytes: DefaultPlanSummaryBytes}
+}
+
+// WithSummaryBytes sets the size cap of the summary and returns t. Values
+// below 1 keep DefaultPlanSummaryBytes.
+func (t *PlanTool) WithSummaryBytes(n int) *PlanTool {
+	if n > 0 {
+		t.summaryBytes = n
+	}
+	return t
 }
 
 // Name returns the tool name registered with the agent.
 func (t *PlanTool) Name() string { return "terraform_plan" }
 
+// RequiresConfirmation implements Confirmable: plan refreshes state through
+// the backend and provider APIs.
+func (t *PlanTool) RequiresConfirmation() bool { return true }
+
 // Description returns the LLM-facing description of this tool.
 func (t *PlanTool) Description() string {
-	return "Runs `terraform plan` in the specified directory and returns the plan output. " +
-		"Use this to preview infrastructure changes before applying them or to diagnose configuration issues."
+	return "Runs `terraform plan` in the specified directory and returns how many resources would be added, " +
+		"changed, and destroyed, the resource addresses grouped by action, and any warnings. " +
+		"Use this to preview infrastructure changes before applying them or to diagnose configuration issues. " +
+		"Set raw to true only when you need the full attribute-level plan text."
 }
 
 // Info returns the Eino tool metadata including the JSON input schema.
@@ -64,12 +129,18 @@ func (t *PlanTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
 				Type: schema.Boolean,
 				Desc: "If true, generate a destroy plan instead of an apply plan.",
 			},
+			"raw": {
+				Type: schema.Boolean,
+				Desc: "If true, return the full human-readable plan output instead of the summary.",
+			},
 		}),
 	}, nil
 }
 
 // InvokableRun executes the tool given a JSON-encoded input string and returns
-// the plan output as a string for the agent to consume.
+// the plan summary, or with raw the plan output, as a string for the agent to
+// consume. The summary comes from `terraform show -json` of a saved plan;
+// if that fails the plan text is returned instead.
 func (t *PlanTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
 	var input planInput
 	if err := json.Unmarshal([]byte(argumentsInJSON), &input); err != nil {
@@ -89,6 +160,18 @@ func (t *PlanTool) InvokableRun(ctx context.Context, argumentsInJSON string, opt
 		args = append(args, "-destroy")
 	}
 
+	var planFile string
+	if !input.Raw {
+		f, err := os.CreateTemp("", "tfai-*.tfplan")
+		if err != nil {
+			return "", fmt.Errorf("terraform_plan: failed to create plan file: %w", err)
+		}
+		planFile = f.Name()
+		_ = f.Close()
+		defer func() { _ = os.Remove(planFile) }()
+		args = append(args, "-out="+planFile)
+	}
+
 	result, err := t.runner.Run(ctx, ws, "plan", args...)
 	if err != nil {
 		return "", fmt.Errorf("terraform_plan: execution failed: %w", err)
@@ -101,6 +184,93 @@ func (t *PlanTool) InvokableRun(ctx context.Context, argumentsInJSON string, opt
 	if result.ExitCode != 0 {
 		return fmt.Sprintf("terraform plan exited with code %d:\n%s", result.ExitCode, output), nil
 	}
+	if input.Raw {
+		return output, nil
+	}
 
-	return output, nil
+	// show takes no -var-file flags; the values are in the saved plan.
+	shown, err := t.runner.Run(ctx, &WorkspaceContext{Dir: input.Dir}, "show", "-json", "-no-color", planFile)
+	if err != nil || shown.ExitCode != 0 {
+		return output, nil
+	}
+	var plan planJSON
+	if err := json.Unmarshal([]byte(shown.Stdout), &plan); err != nil {
+		return output, nil
+	}
+	return summarizePlan(&plan, planDiagnostics(output), t.summaryBytes), nil
+}
+
+// planAction folds a resource change's actions into one of the planActions,
+// or "" for no-op.
+func planAction(actions []string) string {
+	switch {
+	case len(actions) == 2 && slices.Contains(actions, "create") && slices.Contains(actions, "delete"):
+		return "replace"
+	case len(actions) == 1 && actions[0] != "no-op":
+		return actions[0]
+	default:
+		return ""
+	}
+}
+
+// planDiagnostics returns the "Warning: ..." and "Error: ..." headlines in
+// the plan text, without the box-drawing prefix terraform puts before them.
+func planDiagnostics(text string) []string {
+	var diags []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "│╷╵"))
+		if strings.HasPrefix(line, "Warning: ") || strings.HasPrefix(line, "Error: ") {
+			diags = append(diags, line)
+		}
+	}
+	return diags
+}
+
+// summarizePlan renders plan as terraform's count line, followed by the
+// addresses under a heading per action and the diagnostics. Lines that would
+// take the summary past maxBytes are left out and counted in a final line;
+// the count line is always kept.
+func summarizePlan(plan *planJSON, diags []string, maxBytes int) string {
+	byAction := make(map[string][]string)
+	for _, rc := range plan.ResourceChanges {
+		if action := planAction(rc.Change.Actions); action != "" {
+			byAction[action] = append(byAction[action], rc.Address)
+		}
+	}
+	add := len(byAction["create"]) + len(byAction["replace"])
+	change := len(byAction["update"])
+	destroy := len(byAction["delete"]) + len(byAction["replace"])
+
+	var sb strings.Builder
+	if add+change+destroy == 0 {
+		sb.WriteString("terraform plan: no changes. Your infrastructure matches the configuration.")
+	} else {
+		fmt.Fprintf(&sb, "terraform plan: %d to add, %d to change, %d to destroy.", add, change, destroy)
+	}
+
+	var lines []string
+	for _, a := range planActions {
+		if addrs := byAction[a.action]; len(addrs) > 0 {
+			lines = append(lines, fmt.Sprintf("%s (%d):", a.heading, len(addrs)))
+			for _, addr := range addrs {
+				lines = append(lines, "  "+addr)
+			}
+		}
+	}
+	if len(diags) > 0 {
+		lines = append(lines, fmt.Sprintf("diagnostics (%d):", len(diags)))
+		for _, d := range diags {
+			lines = append(lines, "  "+d)
+		}
+	}
+
+	for i, line := range lines {
+		if sb.Len()+len(line)+1 > maxBytes-planOmittedReserve {
+			fmt.Fprintf(&sb, "\n... and %d more lines omitted", len(lines)-i)
+			break
+		}
+		sb.WriteString("\n")
+		sb.WriteString(line)
+	}
+	return sb.String()
 }
@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runnerCall is one invocation recorded by scriptedRunner.
+type runnerCall struct {
+	ws         WorkspaceContext
+	subcommand string
+	args       []string
+}
+
+// scriptedRunner returns a canned result per subcommand and records every
+// invocation in order.
+type scriptedRunner struct {
+	results map[string]*RunResult
+	calls   []runnerCall
+}
+
+func (r *scriptedRunner) Run(_ context.Context, ws *WorkspaceContext, subcommand string, args ...string) (*RunResult, error) {
+	r.calls = append(r.calls, runnerCall{ws: *ws, subcommand: subcommand, args: args})
+	res, ok := r.results[subcommand]
+	if !ok {
+		return nil, fmt.Errorf("unexpected terraform %s", subcommand)
+	}
+	return res, nil
+}
+
+// planShowFixture returns testdata/plan_show.json.
+func planShowFixture(t *testing.T) string {
+	t.Helper()
+	b, err := os.ReadFile("testdata/plan_show.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// ---------------------------------------------------------------------------
+// terraform_plan
+// ---------------------------------------------------------------------------
+
+func TestPlanTool_Summary(t *testing.T) {
+	t.Parallel()
+
+	planText := "data.aws_ami.ubuntu: Reading...\n\nWarning: Argument is deprecated\n\n  with aws_s3_bucket.logs,\n\nPlan: 3 to add, 1 to change, 2 to destroy.\n"
+	runner := &scriptedRunner{results: map[string]*RunResult{
+		"plan": {Stdout: planText},
+		"show": {Stdout: planShowFixture(t)},
+	}}
+	got, err := NewPlanTool(runner).InvokableRun(context.Background(), `{"dir":"/ws/app","var_files":["prod.tfvars"],"destroy":true}`)
+	if err != nil {
+		t.Fatalf("InvokableRun: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"terraform plan: 3 to add, 1 to change, 2 to destroy.",
+		"create (2):",
+		"  aws_s3_bucket.logs",
+		`  module.vpc.aws_subnet.private["a"]`,
+		"replace (1):",
+		"  aws_instance.web",
+		"update (1):",
+		"  aws_security_group.web",
+		"destroy (1):",
+		"  aws_iam_role.legacy",
+		"read (1):",
+		"  data.aws_ami.ubuntu",
+		"diagnostics (1):",
+		"  Warning: Argument is deprecated",
+	}, "\n")
+	if got != want {
+		t.Errorf("unexpected summary:\n%s\nwant:\n%s", got, want)
+	}
+
+	if len(runner.calls) != 2 {
+		t.Fatalf("expected plan then show, got %+v", runner.calls)
+	}
+	plan, show := runner.calls[0], runner.calls[1]
+	if plan.subcommand != "plan" || len(plan.args) != 3 || plan.args[0] != "-no-color" || plan.args[1] != "-destroy" ||
+		!strings.HasPrefix(plan.args[2], "-out=") || !reflect.DeepEqual(plan.ws.VarFiles, []string{"prod.tfvars"}) {
+		t.Errorf("unexpected plan invocation: %+v", plan)
+	}
+	planFile := strings.TrimPrefix(plan.args[2], "-out=")
+	if show.subcommand != "show" || !reflect.DeepEqual(show.args, []string{"-json", "-no-color", planFile}) ||
+		show.ws.Dir != "/ws/app" || len(show.ws.VarFiles) != 0 {
+		t.Errorf("expected show -json of the saved plan without var files, got %+v", show)
+	}
+	if _, err := os.Stat(planFile); !os.IsNotExist(err) {
+		t.Errorf("expected the plan file to be removed, got %v", err)
+	}
+}
+
+func TestPlanTool_NoChanges(t *testing.T) {
+	t.Parallel()
+
+	runner := &scriptedRunner{results: map[string]*RunResult{
+		"plan": {Stdout: "No changes. Your infrastructure matches the configuration.\n"},
+		"show": {Stdout: `{"format_version":"1.2","resource_changes":[{"address":"aws_vpc.main","change":{"actions":["no-op"]}}]}`},
+	}}
+	got, err := NewPlanTool(runner).InvokableRun(context.Background(), `{"dir":"/ws/app"}`)
+	if err != nil {
+		t.Fatalf("InvokableRun: %v", err)
+	}
+	if got != "terraform plan: no changes. Your infrastructure matches the configuration." {
+		t.Errorf("unexpected summary %q", got)
+	}
+}
+
+func TestPlanTool_Raw(t *testing.T) {
+	t.Parallel()
+
+	runner := &scriptedRunner{results: map[string]*RunResult{
+		"plan": {Stdout: "  # aws_s3_bucket.logs will be created\n", Stderr: "Warning: x"},
+	}}
+	got, err := NewPlanTool(runner).InvokableRun(context.Background(), `{"dir":"/ws/app","raw":true}`)
+	if err != nil {
+		t.Fatalf("InvokableRun: %v", err)
+	}
+	if got != "  # aws_s3_bucket.logs will be created\n\n--- stderr ---\nWarning: x" {
+		t.Errorf("expected the plan text as is, got %q", got)
+	}
+	if len(runner.calls) != 1 || !reflect.DeepEqual(runner.calls[0].args, []string{"-no-color"}) {
+		t.Errorf("expected a single plan without -out, got %+v", runner.calls)
+	}
+}
+
+func TestPlanTool_FallsBackToText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		plan *RunResult
+		show *RunResult
+		want string
+	}{
+		{
+			name: "plan failed",
+			plan: &RunResult{Stderr: "Error: No valid credential sources found", ExitCode: 1},
+			want: "terraform plan exited with code 1:\n\n--- stderr ---\nError: No valid credential sources found",
+		},
+		{
+			name: "show failed",
+			plan: &RunResult{Stdout: "Plan: 1 to add, 0 to change, 0 to destroy.\n"},
+			show: &RunResult{Stderr: "Error: unsupported plan format", ExitCode: 1},
+			want: "Plan: 1 to add, 0 to change, 0 to destroy.\n",
+		},
+		{
+			name: "show not json",
+			plan: &RunResult{Stdout: "Plan: 1 to add, 0 to change, 0 to destroy.\n"},
+			show: &RunResult{Stdout: "garbage"},
+			want: "Plan: 1 to add, 0 to change, 0 to destroy.\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			results := map[string]*RunResult{"plan": tc.plan}
+			if tc.show != nil {
+				results["show"] = tc.show
+			}
+			got, err := NewPlanTool(&scriptedRunner{results: results}).InvokableRun(context.Background(), `{"dir":"/ws/app"}`)
+			if err != nil {
+				t.Fatalf("InvokableRun: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSummarizePlan_Budget(t *testing.T) {
+	t.Parallel()
+
+	var changes []string
+	for i := range 500 {
+		changes = append(changes, fmt.Sprintf(`{"address":"aws_s3_object.o[%d]","change":{"actions":["create"]}}`, i))
+	}
+	var plan planJSON
+	if err := json.Unmarshal([]byte(`{"resource_changes":[`+strings.Join(changes, ",")+`]}`), &plan); err != nil {
+		t.Fatal(err)
+	}
+	got := summarizePlan(&plan, nil, 1024)
+	if len(got) > 1024 {
+		t.Errorf("expected at most 1024 bytes, got %d", len(got))
+	}
+	lines := strings.Split(got, "\n")
+	if lines[0] != "terraform plan: 500 to add, 0 to change, 0 to destroy." || lines[1] != "create (500):" {
+		t.Errorf("expected the counts to survive the cap, got %q / %q", lines[0], lines[1])
+	}
+	// The listed and omitted addresses account for all 500.
+	listed := len(lines) - 3
+	var omitted int
+	if _, err := fmt.Sscanf(lines[len(lines)-1], "... and %d more lines omitted", &omitted); err != nil {
+		t.Fatalf("expected an omission line, got %q", lines[len(lines)-1])
+	}
+	if listed+omitted != 500 {
+		t.Errorf("expected %d listed + %d omitted = 500", listed, omitted)
+	}
+}
+
+func TestPlanTool_Errors(t *testing.T) {
+	t.Parallel()
+
+	tool := NewPlanTool(&fakeRunner{err: errors.New("exec: terraform: not found")})
+	for _, args := range []string{`not json`, `{}`, `{"dir":"/ws"}`} {
+		if _, err := tool.InvokableRun(context.Background(), args); err == nil || !strings.HasPrefix(err.Error(), "terraform_plan: ") {
+			t.Errorf("%s: expected a terraform_plan error, got %v", args, err)
+		}
+	}
+}
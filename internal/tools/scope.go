@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// workspaceKey is the context key under which WithWorkspace stores the
+// workspace directory.
+type workspaceKey struct{}
+
+// WithWorkspace returns a context that scopes tools which read workspace
+// files directly to dir: they reject a dir argument outside it. An empty
+// dir leaves ctx unscoped.
+func WithWorkspace(ctx context.Context, dir string) context.Context {
+	if dir == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, workspaceKey{}, filepath.Clean(dir))
+}
+
+// scopedDir resolves dir against the workspace in ctx, if any: a relative
+// dir is taken relative to it, and a dir outside it is an error. Without a
+// workspace dir is returned cleaned.
+func scopedDir(ctx context.Context, dir string) (string, error) {
+	root, _ := ctx.Value(workspaceKey{}).(string)
+	if root == "" {
+		return filepath.Clean(dir), nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	dir = filepath.Clean(dir)
+	if rel, err := filepath.Rel(root, dir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("dir %q is outside the workspace %q", dir, root)
+	}
+	return dir, nil
+}
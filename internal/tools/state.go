@@ -37,6 +37,10 @@ func NewStateTool(runner Runner) *StateTool {
 // Name returns the tool name registered with the agent.
 func (t *StateTool) Name() string { return "terraform_state" }
 
+// RequiresConfirmation implements Confirmable: state subcommands read the
+// remote state backend.
+func (t *StateTool) RequiresConfirmation() bool { return true }
+
 // Description returns the LLM-facing description of this tool.
 func (t *StateTool) Description() string {
 	return "Inspects the Terraform state for a workspace. " +
@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxValidateDiagnostics caps the diagnostics listed in the tool output so a
+// badly broken workspace does not flood the model's context.
+const maxValidateDiagnostics = 20
+
+// ValidateTool is an Eino tool that runs `terraform validate -json` in a given
+// workspace directory and returns a compact summary of the diagnostics, so
+// the agent can catch syntax and reference errors in HCL it has written.
+type ValidateTool struct {
+	// runner executes the terraform binary.
+	runner Runner
+}
+
+// validateInput is the JSON-serialisable input schema for ValidateTool.
+type validateInput struct {
+	// Dir is the absolute path to the Terraform working directory.
+	Dir string `json:"dir"`
+}
+
+// validateOutput is the subset of the `terraform validate -json` document
+// the tool reports.
+type validateOutput struct {
+	// Valid is true when the configuration has no errors.
+	Valid bool `json:"valid"`
+	// ErrorCount is the number of error diagnostics.
+	ErrorCount int `json:"error_count"`
+	// WarningCount is the number of warning diagnostics.
+	WarningCount int `json:"warning_count"`
+	// Diagnostics are the individual errors and warnings.
+	Diagnostics []validateDiagnostic `json:"diagnostics"`
+}
+
+// validateDiagnostic is one error or warning from `terraform validate -json`.
+type validateDiagnostic struct {
+	// Severity is "error" or "warning".
+	Severity string `json:"severity"`
+	// Summary is the one-line description.
+	Summary string `json:"summary"`
+	// Detail is the optional longer explanation.
+	Detail string `json:"detail"`
+	// Range locates the diagnostic; nil for configuration-wide problems.
+	Range *struct {
+		// Filename is relative to the working directory.
+		Filename string `json:"filename"`
+		// Start is the first character of the offending range.
+		Start struct {
+			// Line is 1-based.
+			Line int `json:"line"`
+		} `json:"start"`
+	} `json:"range"`
+}
+
+// NewValidateTool constructs a ValidateTool using the provided Runner.
+func NewValidateTool(runner Runner) *ValidateTool {
+	return &ValidateTool{runner: runner}
+}
+
+// Name returns the tool name registered with the agent.
+func (t *ValidateTool) Name() string { return "terraform_validate" }
+
+// Description returns the LLM-facing description of this tool.
+func (t *ValidateTool) Description() string {
+	return "Runs `terraform validate` in the specified directory and returns the error and warning count " +
+		"with the file, line, and message of each diagnostic. " +
+		"Use this to check Terraform files for syntax, type, and reference errors."
+}
+
+// Info returns the Eino tool metadata including the JSON input schema.
+func (t *ValidateTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: t.Name(),
+		Desc: t.Description(),
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"dir": {
+				Type:     schema.String,
+				Desc:     "Absolute path to the Terraform working directory.",
+				Required: true,
+			},
+		}),
+	}, nil
+}
+
+// InvokableRun executes the tool given a JSON-encoded input string and returns
+// the diagnostics summary as a string for the agent to consume.
+func (t *ValidateTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	var input validateInput
+	if err := json.Unmarshal([]byte(argumentsInJSON), &input); err != nil {
+		return "", fmt.Errorf("terraform_validate: invalid input: %w", err)
+	}
+	if input.Dir == "" {
+		return "", fmt.Errorf("terraform_validate: dir is required")
+	}
+
+	result, err := t.runner.Run(ctx, &WorkspaceContext{Dir: input.Dir}, "validate", "-json", "-no-color")
+	if err != nil {
+		return "", fmt.Errorf("terraform_validate: execution failed: %w", err)
+	}
+
+	var out validateOutput
+	if err := json.Unmarshal([]byte(result.Stdout), &out); err != nil {
+		// Not JSON: terraform failed before validating (e.g. a crash or an
+		// unsupported version). Pass the raw output through like the other tools.
+		output := result.Stdout
+		if result.Stderr != "" {
+			output += "\n--- stderr ---\n" + result.Stderr
+		}
+		return fmt.Sprintf("terraform validate exited with code %d:\n%s", result.ExitCode, output), nil
+	}
+	return summarizeValidate(&out), nil
+}
+
+// summarizeValidate renders out as a count line followed by one line per
+// diagnostic: "error main.tf:12: Unsupported argument: <detail>".
+func summarizeValidate(out *validateOutput) string {
+	if out.Valid && out.WarningCount == 0 {
+		return "terraform validate: the configuration is valid."
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "terraform validate: %d error(s), %d warning(s)", out.ErrorCount, out.WarningCount)
+	for i, d := range out.Diagnostics {
+		if i == maxValidateDiagnostics {
+			fmt.Fprintf(&sb, "\n... and %d more", len(out.Diagnostics)-i)
+			break
+		}
+		sb.WriteString("\n")
+		sb.WriteString(d.Severity)
+		if d.Range != nil && d.Range.Filename != "" {
+			fmt.Fprintf(&sb, " %s:%d", d.Range.Filename, d.Range.Start.Line)
+		}
+		sb.WriteString(": ")
+		sb.WriteString(d.Summary)
+		if detail := strings.Join(strings.Fields(d.Detail), " "); detail != "" {
+			sb.WriteString(": ")
+			sb.WriteString(detail)
+		}
+	}
+	return sb.String()
+}
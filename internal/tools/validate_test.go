@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRunner records the last invocation and returns a canned result.
+type fakeRunner struct {
+	result *RunResult
+	err    error
+
+	dir        string
+	subcommand string
+	args       []string
+}
+
+func (r *fakeRunner) Run(_ context.Context, ws *WorkspaceContext, subcommand string, args ...string) (*RunResult, error) {
+	r.dir, r.subcommand, r.args = ws.Dir, subcommand, args
+	return r.result, r.err
+}
+
+const (
+	validJSON = `{"format_version":"1.0","valid":true,"error_count":0,"warning_count":0,"diagnostics":[]}`
+
+	invalidJSON = `{
+  "format_version": "1.0",
+  "valid": false,
+  "error_count": 2,
+  "warning_count": 1,
+  "diagnostics": [
+    {
+      "severity": "error",
+      "summary": "Unsupported argument",
+      "detail": "An argument named \"instance_typ\" is not expected here.\nDid you mean \"instance_type\"?",
+      "range": {"filename": "main.tf", "start": {"line": 4, "column": 3, "byte": 60}, "end": {"line": 4, "column": 15, "byte": 72}},
+      "snippet": {"context": "resource \"aws_instance\" \"web\"", "code": "  instance_typ = \"t3.micro\"", "start_line": 4}
+    },
+    {
+      "severity": "error",
+      "summary": "Reference to undeclared input variable",
+      "detail": "An input variable with the name \"region\" has not been declared.",
+      "range": {"filename": "modules/vpc/main.tf", "start": {"line": 2, "column": 12, "byte": 30}, "end": {"line": 2, "column": 22, "byte": 40}}
+    },
+    {
+      "severity": "warning",
+      "summary": "Deprecated attribute",
+      "detail": ""
+    }
+  ]
+}`
+)
+
+// ---------------------------------------------------------------------------
+// terraform_validate
+// ---------------------------------------------------------------------------
+
+func TestValidateTool_InvokableRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		result *RunResult
+		want   []string
+	}{
+		{
+			name:   "valid",
+			result: &RunResult{Stdout: validJSON},
+			want:   []string{"terraform validate: the configuration is valid."},
+		},
+		{
+			name:   "invalid",
+			result: &RunResult{Stdout: invalidJSON, ExitCode: 1},
+			want: []string{
+				"terraform validate: 2 error(s), 1 warning(s)",
+				`error main.tf:4: Unsupported argument: An argument named "instance_typ" is not expected here. Did you mean "instance_type"?`,
+				`error modules/vpc/main.tf:2: Reference to undeclared input variable: An input variable with the name "region" has not been declared.`,
+				"warning: Deprecated attribute",
+			},
+		},
+		{
+			name:   "not json",
+			result: &RunResult{Stderr: "Error: Terraform crashed", ExitCode: 11},
+			want:   []string{"terraform validate exited with code 11:", "--- stderr ---", "Error: Terraform crashed"},
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := &fakeRunner{result: tc.result}
+			got, err := NewValidateTool(runner).InvokableRun(context.Background(), `{"dir":"/ws/app"}`)
+			if err != nil {
+				t.Fatalf("InvokableRun: %v", err)
+			}
+			if runner.dir != "/ws/app" || runner.subcommand != "validate" || !reflect.DeepEqual(runner.args, []string{"-json", "-no-color"}) {
+				t.Errorf("unexpected invocation: dir=%q %s %v", runner.dir, runner.subcommand, runner.args)
+			}
+			lines := strings.Split(got, "\n")
+			for _, want := range tc.want {
+				found := false
+				for _, line := range lines {
+					found = found || line == want
+				}
+				if !found {
+					t.Errorf("expected line %q in:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateTool_CapsDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	var diags []string
+	for i := 1; i <= maxValidateDiagnostics+5; i++ {
+		diags = append(diags, fmt.Sprintf(`{"severity":"error","summary":"Error %d","range":{"filename":"main.tf","start":{"line":%d}}}`, i, i))
+	}
+	stdout := fmt.Sprintf(`{"valid":false,"error_count":%d,"warning_count":0,"diagnostics":[%s]}`, len(diags), strings.Join(diags, ","))
+
+	got, err := NewValidateTool(&fakeRunner{result: &RunResult{Stdout: stdout, ExitCode: 1}}).
+		InvokableRun(context.Background(), `{"dir":"/ws"}`)
+	if err != nil {
+		t.Fatalf("InvokableRun: %v", err)
+	}
+	if !strings.HasSuffix(got, "\n... and 5 more") {
+		t.Errorf("expected the list to be capped, got:\n%s", got)
+	}
+	if strings.Contains(got, fmt.Sprintf("Error %d", maxValidateDiagnostics+1)) {
+		t.Errorf("expected diagnostics past the cap to be omitted, got:\n%s", got)
+	}
+}
+
+func TestValidateTool_Errors(t *testing.T) {
+	t.Parallel()
+
+	tool := NewValidateTool(&fakeRunner{err: errors.New("exec: terraform: not found")})
+	for _, args := range []string{`not json`, `{}`, `{"dir":"/ws"}`} {
+		if _, err := tool.InvokableRun(context.Background(), args); err == nil || !strings.HasPrefix(err.Error(), "terraform_validate: ") {
+			t.Errorf("%s: expected a terraform_validate error, got %v", args, err)
+		}
+	}
+}
@@ -41,9 +41,11 @@ func Setup() (callbacks.Handler, func(), bool) {
 // SetRequestTrace stamps the context with per-request trace metadata so each
 // chat request appears as a distinct, named trace in Langfuse. Call this once
 // per request before invoking the agent. sessionID should be a unique ID for
-// the request (e.g. a UUID or the HTTP request ID).
-func SetRequestTrace(ctx context.Context, sessionID string) context.Context {
+// the request (e.g. a UUID or the HTTP request ID); traceID becomes the
+// trace's ID, so the request ID a client sees finds the trace directly.
+func SetRequestTrace(ctx context.Context, sessionID, traceID string) context.Context {
 	return langfuse.SetTrace(ctx,
+		langfuse.WithID(traceID),
 		langfuse.WithName("tfai-chat"),
 		langfuse.WithSessionID(sessionID),
 		langfuse.WithRelease(version.Version),
@@ -0,0 +1,78 @@
+package upgrade
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BuildPrompt assembles the agent prompt for upgrading provider to major
+// version to. current is the version constraint found in the workspace ("" if
+// none was found) and guideURL is the official upgrade guide ("" if unknown).
+// The workspace files themselves are added by the agent, and the provider
+// name and version in the prompt steer RAG retrieval toward the ingested
+// upgrade guide.
+func BuildPrompt(provider, current string, to int, guideURL string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Upgrade this workspace to version %d of the %s Terraform provider.\n\n", to, provider)
+	if current != "" {
+		fmt.Fprintf(&b, "The workspace currently constrains the %s provider to %q.\n", provider, current)
+	} else {
+		fmt.Fprintf(&b, "No version constraint for the %s provider was found in the workspace.\n", provider)
+	}
+	if guideURL != "" {
+		fmt.Fprintf(&b, "The official upgrade guide is %s; rely on its content from the reference documentation where available.\n", guideURL)
+	}
+	fmt.Fprintf(&b, `
+Start your answer with a migration plan:
+1. List every resource, data source, argument, and block in the workspace that is removed, renamed, deprecated, or changes behaviour in %[1]s %[2]d.x, citing the file where it appears.
+2. For each item, describe the edit and whether it can cause resource replacement or state changes (including any required "moved" blocks or state operations).
+3. List anything that must be checked manually after the upgrade.
+
+Then return the edited files through the JSON file envelope:
+- Update the required_providers constraint for %[1]s to "~> %[2]d.0".
+- Include only files that change, each with its complete new content.
+- Preserve existing formatting, comments, and unrelated configuration.
+`, provider, to)
+	return b.String()
+}
+
+// fixPrompt asks the agent to correct the workspace after terraform init or
+// validate failed following an upgrade.
+func fixPrompt(provider string, to int, output string) string {
+	return fmt.Sprintf(`The workspace was just upgraded to version %d of the %s provider, but terraform reported errors:
+
+%s
+
+Fix these errors. Return only the files that change through the JSON file envelope, each with its complete new content.`,
+		to, provider, strings.TrimSpace(output))
+}
+
+// DetectVersionConstraint returns the version constraint declared for
+// provider in any required_providers block under dir, or "" if none is
+// found. Files are scanned in lexical order and the first match wins. This is
+// a best-effort textual scan, not an HCL parse.
+func DetectVersionConstraint(dir, provider string) (string, error) {
+	re, err := regexp.Compile(`(?s)\b` + regexp.QuoteMeta(provider) + `\s*=\s*\{[^}]*?\bversion\s*=\s*"([^"]*)"`)
+	if err != nil {
+		return "", fmt.Errorf("upgrade: invalid provider name %q: %w", provider, err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return "", fmt.Errorf("upgrade: failed to list %s: %w", dir, err)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("upgrade: failed to read %s: %w", path, err)
+		}
+		if m := re.FindSubmatch(b); m != nil {
+			return string(m[1]), nil
+		}
+	}
+	return "", nil
+}
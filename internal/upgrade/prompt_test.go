@@ -0,0 +1,112 @@
+package upgrade
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildPrompt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		current  string
+		guideURL string
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "constraint and guide",
+			current:  "~> 4.0",
+			guideURL: "https://example.com/aws-5",
+			want: []string{
+				"version 5 of the aws Terraform provider",
+				`currently constrains the aws provider to "~> 4.0"`,
+				"https://example.com/aws-5",
+				"migration plan",
+				`"~> 5.0"`,
+				"JSON file envelope",
+			},
+		},
+		{
+			name:    "no constraint, no guide",
+			want:    []string{"No version constraint for the aws provider"},
+			notWant: []string{"upgrade guide is"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := BuildPrompt("aws", tc.current, 5, tc.guideURL)
+			for _, w := range tc.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("expected prompt to contain %q, got:\n%s", w, got)
+				}
+			}
+			for _, w := range tc.notWant {
+				if strings.Contains(got, w) {
+					t.Errorf("expected prompt not to contain %q, got:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectVersionConstraint(t *testing.T) {
+	t.Parallel()
+
+	const versions = `terraform {
+  required_version = ">= 1.5"
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.67"
+    }
+    random = {
+      source = "hashicorp/random"
+    }
+  }
+}
+`
+	tests := []struct {
+		name     string
+		files    map[string]string
+		provider string
+		want     string
+	}{
+		{name: "found", files: map[string]string{"versions.tf": versions}, provider: "aws", want: "~> 4.67"},
+		{name: "provider without version", files: map[string]string{"versions.tf": versions}, provider: "random", want: ""},
+		{name: "provider absent", files: map[string]string{"versions.tf": versions}, provider: "google", want: ""},
+		{name: "no tf files", files: map[string]string{"README.md": versions}, provider: "aws", want: ""},
+		{
+			name:     "first file wins",
+			files:    map[string]string{"a.tf": `aws = { version = "5.1.0" }`, "versions.tf": versions},
+			provider: "aws",
+			want:     "5.1.0",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := DetectVersionConstraint(dir, tc.provider)
+			if err != nil {
+				t.Fatalf("DetectVersionConstraint: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
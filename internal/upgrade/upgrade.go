@@ -0,0 +1,210 @@
+// Package upgrade implements `tfai upgrade`: a guided provider major-version
+// migration. It asks the agent for a migration plan and file edits for a
+// workspace, applies them (or previews them against a scratch copy in dry-run
+// mode), and then runs terraform validate, feeding any errors back to the
+// agent for a bounded number of correction rounds.
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/filediff"
+	"github.com/54b3r/tfai-go/internal/ingestion"
+	"github.com/54b3r/tfai-go/internal/tools"
+)
+
+// DefaultMaxFixAttempts is the number of correction rounds attempted after a
+// failed validation when Advisor.MaxFixAttempts is zero.
+const DefaultMaxFixAttempts = 2
+
+// ErrValidationFailed is returned (wrapped) when the workspace still fails
+// terraform validate after all correction rounds.
+var ErrValidationFailed = errors.New("upgrade: terraform validate still fails after the upgrade")
+
+// Querier is the subset of agent.TerraformAgent used by the advisor.
+type Querier interface {
+	Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error)
+}
+
+// Request describes one upgrade.
+type Request struct {
+	// Dir is the absolute path of the Terraform workspace to upgrade.
+	Dir string
+	// Provider is the provider name as used in required_providers (e.g. "aws").
+	Provider string
+	// To is the target major version.
+	To int
+	// DryRun previews the edits without touching Dir.
+	DryRun bool
+}
+
+// Advisor runs provider upgrades.
+type Advisor struct {
+	// Querier produces the migration plan and file edits.
+	Querier Querier
+	// Runner executes terraform init/validate after edits are applied.
+	// When nil, validation is skipped.
+	Runner tools.Runner
+	// Out receives agent output, diff summaries, and validation results.
+	Out io.Writer
+	// Color enables ANSI colors in diff summaries.
+	Color bool
+	// MaxFixAttempts is the number of correction rounds after a failed
+	// validation. Defaults to DefaultMaxFixAttempts.
+	MaxFixAttempts int
+}
+
+// Run performs req. In dry-run mode the agent works on a scratch copy of the
+// workspace's Terraform files and only a summary of the proposed changes is
+// printed; Dir is never modified and validation is skipped.
+func (a *Advisor) Run(ctx context.Context, req Request) error {
+	if req.Provider == "" {
+		return fmt.Errorf("upgrade: provider is required")
+	}
+	if req.To <= 0 {
+		return fmt.Errorf("upgrade: target major version must be positive, got %d", req.To)
+	}
+	info, err := os.Stat(req.Dir)
+	if err != nil {
+		return fmt.Errorf("upgrade: workspace %s: %w", req.Dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("upgrade: workspace %s is not a directory", req.Dir)
+	}
+
+	current, err := DetectVersionConstraint(req.Dir, req.Provider)
+	if err != nil {
+		return err
+	}
+	var guideURL string
+	if g, ok := ingestion.LookupUpgradeGuide(req.Provider, req.To); ok {
+		guideURL = g.URL
+	}
+	prompt := BuildPrompt(req.Provider, current, req.To, guideURL)
+
+	if req.DryRun {
+		return a.dryRun(ctx, req, prompt)
+	}
+
+	if err := a.query(ctx, prompt, req.Dir); err != nil {
+		return err
+	}
+	return a.validate(ctx, req)
+}
+
+// dryRun runs the query against a scratch copy of the workspace and prints
+// the changes the agent would have made.
+func (a *Advisor) dryRun(ctx context.Context, req Request, prompt string) error {
+	before, err := filediff.Snapshot(req.Dir)
+	if err != nil {
+		return err //nolint:wrapcheck // filediff errors are already prefixed
+	}
+	scratch, err := os.MkdirTemp("", "tfai-upgrade-")
+	if err != nil {
+		return fmt.Errorf("upgrade: failed to create scratch directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(scratch) }()
+
+	for rel, content := range before {
+		path := filepath.Join(scratch, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("upgrade: failed to copy workspace: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			return fmt.Errorf("upgrade: failed to copy workspace: %w", err)
+		}
+	}
+
+	if _, err := a.Querier.Run(ctx, agent.QueryRequest{Message: prompt, WorkspaceDir: scratch, Output: a.Out}); err != nil {
+		return fmt.Errorf("upgrade: agent query failed: %w", err)
+	}
+	after, err := filediff.Snapshot(scratch)
+	if err != nil {
+		return err //nolint:wrapcheck // filediff errors are already prefixed
+	}
+	fmt.Fprintf(a.Out, "\n\ndry run: proposed changes to %s (nothing written)\n", req.Dir)
+	filediff.WriteSummary(a.Out, filediff.Compare(before, after), a.Color)
+	return nil
+}
+
+// query runs one agent query against dir and prints a summary of what changed.
+func (a *Advisor) query(ctx context.Context, prompt, dir string) error {
+	before, err := filediff.Snapshot(dir)
+	if err != nil {
+		return err //nolint:wrapcheck // filediff errors are already prefixed
+	}
+	if _, err := a.Querier.Run(ctx, agent.QueryRequest{Message: prompt, WorkspaceDir: dir, Output: a.Out}); err != nil {
+		return fmt.Errorf("upgrade: agent query failed: %w", err)
+	}
+	after, err := filediff.Snapshot(dir)
+	if err != nil {
+		return err //nolint:wrapcheck // filediff errors are already prefixed
+	}
+	fmt.Fprintln(a.Out)
+	fmt.Fprintln(a.Out)
+	filediff.WriteSummary(a.Out, filediff.Compare(before, after), a.Color)
+	return nil
+}
+
+// validate runs terraform init and validate on the workspace, asking the
+// agent to fix any errors up to MaxFixAttempts times.
+func (a *Advisor) validate(ctx context.Context, req Request) error {
+	if a.Runner == nil {
+		fmt.Fprintln(a.Out, "\nterraform not available: skipping validation")
+		return nil
+	}
+	maxFix := a.MaxFixAttempts
+	if maxFix <= 0 {
+		maxFix = DefaultMaxFixAttempts
+	}
+
+	for attempt := 0; ; attempt++ {
+		output, ok, err := a.check(ctx, req.Dir)
+		if err != nil {
+			return err
+		}
+		if ok {
+			fmt.Fprintln(a.Out, "\nterraform validate: passed")
+			return nil
+		}
+		fmt.Fprintf(a.Out, "\nterraform validate: failed\n%s\n", strings.TrimSpace(output))
+		if attempt == maxFix {
+			return fmt.Errorf("%w (%d correction attempts)", ErrValidationFailed, maxFix)
+		}
+		fmt.Fprintf(a.Out, "\n--- asking the agent to fix validation errors (attempt %d of %d) ---\n", attempt+1, maxFix)
+		if err := a.query(ctx, fixPrompt(req.Provider, req.To, output), req.Dir); err != nil {
+			return err
+		}
+	}
+}
+
+// check runs terraform init (without a backend, upgrading provider
+// selections) followed by terraform validate. It returns the combined output
+// of the failing step and ok=false on failure.
+func (a *Advisor) check(ctx context.Context, dir string) (string, bool, error) {
+	ws := &tools.WorkspaceContext{Dir: dir}
+	steps := []struct {
+		subcommand string
+		args       []string
+	}{
+		{"init", []string{"-backend=false", "-upgrade", "-input=false", "-no-color"}},
+		{"validate", []string{"-no-color"}},
+	}
+	for _, s := range steps {
+		res, err := a.Runner.Run(ctx, ws, s.subcommand, s.args...)
+		if err != nil {
+			return "", false, fmt.Errorf("upgrade: terraform %s: %w", s.subcommand, err)
+		}
+		if res.ExitCode != 0 {
+			return "terraform " + s.subcommand + ":\n" + res.Stdout + res.Stderr, false, nil
+		}
+	}
+	return "", true, nil
+}
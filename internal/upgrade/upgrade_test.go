@@ -0,0 +1,262 @@
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/tools"
+)
+
+// ---------------------------------------------------------------------------
+// Fakes
+// ---------------------------------------------------------------------------
+
+// fakeQuerier writes the next entry of edits into workspaceDir on each call
+// and records the prompts and directories it was given.
+type fakeQuerier struct {
+	mu      sync.Mutex
+	edits   []map[string]string
+	prompts []string
+	dirs    []string
+}
+
+func (q *fakeQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	call := len(q.prompts)
+	q.prompts = append(q.prompts, req.Message)
+	q.dirs = append(q.dirs, req.WorkspaceDir)
+	_, _ = io.WriteString(req.Output, "plan: bump the provider")
+	res := &agent.QueryResult{}
+	if call >= len(q.edits) {
+		return res, nil
+	}
+	for rel, content := range q.edits[call] {
+		if err := os.WriteFile(filepath.Join(req.WorkspaceDir, rel), []byte(content), 0o644); err != nil {
+			return res, err
+		}
+		res.Files = append(res.Files, rel)
+	}
+	return res, nil
+}
+
+// fakeRunner fails terraform validate for the first failValidate calls.
+type fakeRunner struct {
+	mu           sync.Mutex
+	failValidate int
+	calls        []string
+}
+
+func (r *fakeRunner) Run(_ context.Context, _ *tools.WorkspaceContext, subcommand string, _ ...string) (*tools.RunResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, subcommand)
+	if subcommand == "validate" && r.failValidate > 0 {
+		r.failValidate--
+		return &tools.RunResult{Stderr: "Error: Unsupported argument \"acl\"", ExitCode: 1}, nil
+	}
+	return &tools.RunResult{ExitCode: 0}, nil
+}
+
+const oldVersions = `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 4.0"
+    }
+  }
+}
+`
+
+const newVersions = `terraform {
+  required_providers {
+    aws = {
+      source  = "hashicorp/aws"
+      version = "~> 5.0"
+    }
+  }
+}
+`
+
+// newWorkspace returns a temp workspace containing versions.tf.
+func newWorkspace(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "versions.tf"), []byte(oldVersions), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// readVersions returns the content of versions.tf in dir.
+func readVersions(t *testing.T, dir string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, "versions.tf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// ---------------------------------------------------------------------------
+// Advisor.Run
+// ---------------------------------------------------------------------------
+
+func TestRun_DryRunLeavesWorkspaceUntouched(t *testing.T) {
+	t.Parallel()
+
+	dir := newWorkspace(t)
+	q := &fakeQuerier{edits: []map[string]string{{"versions.tf": newVersions, "moved.tf": "moved {}\n"}}}
+	r := &fakeRunner{}
+	var out strings.Builder
+	a := &Advisor{Querier: q, Runner: r, Out: &out}
+
+	if err := a.Run(context.Background(), Request{Dir: dir, Provider: "aws", To: 5, DryRun: true}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := readVersions(t, dir); got != oldVersions {
+		t.Errorf("dry run modified versions.tf:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "moved.tf")); !os.IsNotExist(err) {
+		t.Errorf("dry run created moved.tf (stat err %v)", err)
+	}
+	if q.dirs[0] == dir {
+		t.Error("expected the agent to run against a scratch copy, got the workspace itself")
+	}
+	if _, err := os.Stat(q.dirs[0]); !os.IsNotExist(err) {
+		t.Errorf("expected scratch directory to be removed (stat err %v)", err)
+	}
+	for _, want := range []string{"nothing written", "+ moved.tf (+1)", "~ versions.tf (+1 -1)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+	if len(r.calls) != 0 {
+		t.Errorf("expected no terraform calls in dry run, got %v", r.calls)
+	}
+	if !strings.Contains(q.prompts[0], `"~> 4.0"`) || !strings.Contains(q.prompts[0], "version-5-upgrade") {
+		t.Errorf("expected prompt to include current constraint and guide URL, got:\n%s", q.prompts[0])
+	}
+}
+
+func TestRun_ApplyAndValidate(t *testing.T) {
+	t.Parallel()
+
+	dir := newWorkspace(t)
+	q := &fakeQuerier{edits: []map[string]string{{"versions.tf": newVersions}}}
+	r := &fakeRunner{}
+	var out strings.Builder
+	a := &Advisor{Querier: q, Runner: r, Out: &out}
+
+	if err := a.Run(context.Background(), Request{Dir: dir, Provider: "aws", To: 5}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if got := readVersions(t, dir); got != newVersions {
+		t.Errorf("expected versions.tf to be upgraded, got:\n%s", got)
+	}
+	if q.dirs[0] != dir {
+		t.Errorf("expected the agent to run against %s, got %s", dir, q.dirs[0])
+	}
+	if strings.Join(r.calls, ",") != "init,validate" {
+		t.Errorf("expected init,validate, got %v", r.calls)
+	}
+	if !strings.Contains(out.String(), "terraform validate: passed") {
+		t.Errorf("expected validation success in output, got:\n%s", out.String())
+	}
+}
+
+func TestRun_ValidationErrorsAreFedBack(t *testing.T) {
+	t.Parallel()
+
+	dir := newWorkspace(t)
+	q := &fakeQuerier{edits: []map[string]string{
+		{"versions.tf": newVersions},
+		{"main.tf": "resource \"aws_s3_bucket\" \"b\" {}\n"},
+	}}
+	r := &fakeRunner{failValidate: 1}
+	var out strings.Builder
+	a := &Advisor{Querier: q, Runner: r, Out: &out}
+
+	if err := a.Run(context.Background(), Request{Dir: dir, Provider: "aws", To: 5}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(q.prompts) != 2 {
+		t.Fatalf("expected one correction query, got %d queries", len(q.prompts))
+	}
+	if !strings.Contains(q.prompts[1], `Unsupported argument "acl"`) {
+		t.Errorf("expected the correction prompt to include validate output, got:\n%s", q.prompts[1])
+	}
+	if !strings.Contains(out.String(), "terraform validate: passed") {
+		t.Errorf("expected validation to pass after correction, got:\n%s", out.String())
+	}
+}
+
+func TestRun_ValidationGivesUp(t *testing.T) {
+	t.Parallel()
+
+	dir := newWorkspace(t)
+	q := &fakeQuerier{edits: []map[string]string{{"versions.tf": newVersions}}}
+	r := &fakeRunner{failValidate: 10}
+	a := &Advisor{Querier: q, Runner: r, Out: io.Discard, MaxFixAttempts: 1}
+
+	err := a.Run(context.Background(), Request{Dir: dir, Provider: "aws", To: 5})
+	if !errors.Is(err, ErrValidationFailed) {
+		t.Fatalf("expected ErrValidationFailed, got %v", err)
+	}
+	if len(q.prompts) != 2 {
+		t.Errorf("expected 1 upgrade query and 1 correction query, got %d", len(q.prompts))
+	}
+}
+
+func TestRun_NoRunnerSkipsValidation(t *testing.T) {
+	t.Parallel()
+
+	dir := newWorkspace(t)
+	q := &fakeQuerier{edits: []map[string]string{{"versions.tf": newVersions}}}
+	var out strings.Builder
+	a := &Advisor{Querier: q, Out: &out}
+
+	if err := a.Run(context.Background(), Request{Dir: dir, Provider: "aws", To: 5}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(out.String(), "skipping validation") {
+		t.Errorf("expected skip notice, got:\n%s", out.String())
+	}
+}
+
+func TestRun_InvalidRequest(t *testing.T) {
+	t.Parallel()
+
+	dir := newWorkspace(t)
+	tests := []struct {
+		name string
+		req  Request
+	}{
+		{name: "missing provider", req: Request{Dir: dir, To: 5}},
+		{name: "zero version", req: Request{Dir: dir, Provider: "aws"}},
+		{name: "missing dir", req: Request{Dir: filepath.Join(dir, "nope"), Provider: "aws", To: 5}},
+		{name: "file not dir", req: Request{Dir: filepath.Join(dir, "versions.tf"), Provider: "aws", To: 5}},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			q := &fakeQuerier{}
+			a := &Advisor{Querier: q, Out: io.Discard}
+			if err := a.Run(context.Background(), tc.req); err == nil {
+				t.Fatal("expected error")
+			}
+			if len(q.prompts) != 0 {
+				t.Errorf("expected no agent query, got %d", len(q.prompts))
+			}
+		})
+	}
+}
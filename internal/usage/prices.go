@@ -0,0 +1,52 @@
+package usage
+
+import "maps"
+
+// DefaultPrices returns the built-in price table: list prices, in dollars
+// per 1K tokens, of the models tfai is most often run with. Azure
+// deployments are priced by name, so they are only matched when named
+// after their model. Local and recorded backends cost nothing.
+func DefaultPrices() Prices {
+	openai := map[string]Price{
+		"gpt-4o":       {Prompt: 0.0025, Completion: 0.01},
+		"gpt-4o-mini":  {Prompt: 0.00015, Completion: 0.0006},
+		"gpt-4.1":      {Prompt: 0.002, Completion: 0.008},
+		"gpt-4.1-mini": {Prompt: 0.0004, Completion: 0.0016},
+		"gpt-4.1-nano": {Prompt: 0.0001, Completion: 0.0004},
+		"gpt-4-turbo":  {Prompt: 0.01, Completion: 0.03},
+		"o3":           {Prompt: 0.002, Completion: 0.008},
+		"o3-mini":      {Prompt: 0.0011, Completion: 0.0044},
+		"o4-mini":      {Prompt: 0.0011, Completion: 0.0044},
+	}
+	return Prices{
+		"openai": openai,
+		"azure":  maps.Clone(openai),
+		"gemini": {
+			"gemini-1.5-pro":   {Prompt: 0.00125, Completion: 0.005},
+			"gemini-1.5-flash": {Prompt: 0.000075, Completion: 0.0003},
+			"gemini-2.0-flash": {Prompt: 0.0001, Completion: 0.0004},
+			"gemini-2.5-pro":   {Prompt: 0.00125, Completion: 0.01},
+			"gemini-2.5-flash": {Prompt: 0.0003, Completion: 0.0025},
+		},
+		"bedrock": {
+			"anthropic.claude-3-5-sonnet": {Prompt: 0.003, Completion: 0.015},
+			"anthropic.claude-3-7-sonnet": {Prompt: 0.003, Completion: 0.015},
+			"anthropic.claude-3-5-haiku":  {Prompt: 0.0008, Completion: 0.004},
+			"anthropic.claude-3-haiku":    {Prompt: 0.00025, Completion: 0.00125},
+			"anthropic.claude-3-opus":     {Prompt: 0.015, Completion: 0.075},
+		},
+		"ollama": {"*": {}},
+		"replay": {"*": {}},
+	}
+}
+
+// With returns p with the tables of every provider in overrides replacing
+// p's, so a provider priced in the config file is priced only by it.
+func (p Prices) With(overrides Prices) Prices {
+	out := maps.Clone(p)
+	if out == nil {
+		out = make(Prices, len(overrides))
+	}
+	maps.Copy(out, overrides)
+	return out
+}
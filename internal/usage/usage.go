@@ -0,0 +1,268 @@
+// Package usage aggregates the token usage metadata stored with assistant
+// messages into grouped reports with optional cost estimates. It backs
+// `tfai usage report` and GET /api/usage/report.
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/config"
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/internal/timefmt"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// GroupBy is a report grouping dimension.
+type GroupBy string
+
+// Supported grouping dimensions.
+const (
+	// GroupByDay groups by the UTC calendar day of each response.
+	GroupByDay GroupBy = "day"
+	// GroupByWorkspace groups by workspace directory.
+	GroupByWorkspace GroupBy = "workspace"
+	// GroupByProvider groups by model provider.
+	GroupByProvider GroupBy = "provider"
+)
+
+// Output formats accepted by Write.
+const (
+	// FormatTable is an aligned, human-readable table.
+	FormatTable = "table"
+	// FormatJSON is the api.UsageReport JSON document.
+	FormatJSON = "json"
+	// FormatCSV is RFC 4180 CSV with a header row.
+	FormatCSV = "csv"
+)
+
+// untrackedKey is the provider group for responses without usage metadata.
+const untrackedKey = "untracked"
+
+// noWorkspaceKey is the workspace group for responses stored without one.
+const noWorkspaceKey = "(none)"
+
+// ParseGroupBy validates a grouping dimension. Empty means GroupByDay.
+func ParseGroupBy(s string) (GroupBy, error) {
+	switch g := GroupBy(s); g {
+	case "":
+		return GroupByDay, nil
+	case GroupByDay, GroupByWorkspace, GroupByProvider:
+		return g, nil
+	}
+	return "", fmt.Errorf("usage: invalid group-by %q (valid: day, workspace, provider)", s)
+}
+
+// ParseSince parses a report start as a date (2006-01-02, UTC midnight), an
+// RFC 3339 timestamp, or a time before now: a number of days such as "7d",
+// or a duration such as "12h". Empty returns the zero time, meaning "all
+// history".
+func ParseSince(s string) (time.Time, error) {
+	return parseSince(s, time.Now())
+}
+
+// parseSince implements ParseSince, with relative starts counted back from
+// now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		if days, err := strconv.Atoi(n); err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("usage: invalid since %q (use YYYY-MM-DD, RFC 3339, or a time ago such as 7d or 12h)", s)
+}
+
+// Price is the cost in dollars of 1,000 tokens.
+type Price struct {
+	// Prompt is the price of 1K prompt tokens.
+	Prompt float64
+	// Completion is the price of 1K completion tokens.
+	Completion float64
+}
+
+// Prices maps provider → model → price. A model key of "*" applies to every
+// model of the provider without its own entry.
+type Prices map[string]map[string]Price
+
+// PricesFromConfig converts the budget.prices config section.
+func PricesFromConfig(b config.BudgetConfig) Prices {
+	p := make(Prices, len(b.Prices))
+	for provider, models := range b.Prices {
+		p[provider] = make(map[string]Price, len(models))
+		for model, price := range models {
+			p[provider][model] = Price{Prompt: price.Prompt, Completion: price.Completion}
+		}
+	}
+	return p
+}
+
+// lookup returns the price for provider and model. A model without its own
+// entry takes the price of the longest entry it extends with a version
+// suffix, "-" and a digit, so "gpt-4o-2024-08-06" is priced as "gpt-4o"
+// but "gpt-4o-mini" is not; failing that, the provider's "*" entry.
+func (p Prices) lookup(provider, model string) (Price, bool) {
+	models, ok := p[provider]
+	if !ok {
+		return Price{}, false
+	}
+	if price, ok := models[model]; ok {
+		return price, true
+	}
+	best := ""
+	for name := range models {
+		version, ok := strings.CutPrefix(model, name+"-")
+		if ok && len(name) > len(best) && version != "" && version[0] >= '0' && version[0] <= '9' {
+			best = name
+		}
+	}
+	if best != "" {
+		return models[best], true
+	}
+	price, ok := models["*"]
+	return price, ok
+}
+
+// Aggregate groups records and applies prices. since is echoed in the
+// report; records are expected to be filtered by the caller already.
+func Aggregate(records []store.UsageRecord, since time.Time, groupBy GroupBy, prices Prices) api.UsageReport {
+	report := api.UsageReport{GroupBy: string(groupBy), Groups: []api.UsageGroup{}, Total: api.UsageGroup{Key: "total"}}
+	if !since.IsZero() {
+		report.Since = timefmt.Format(since)
+	}
+
+	groups := make(map[string]*api.UsageGroup)
+	for _, r := range records {
+		key := groupKey(r, groupBy)
+		g, ok := groups[key]
+		if !ok {
+			g = &api.UsageGroup{Key: key}
+			groups[key] = g
+		}
+		add(g, r, prices)
+		add(&report.Total, r, prices)
+	}
+
+	for _, g := range groups {
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].Key < report.Groups[j].Key })
+	return report
+}
+
+// groupKey returns the group a record belongs to.
+func groupKey(r store.UsageRecord, groupBy GroupBy) string {
+	switch groupBy {
+	case GroupByWorkspace:
+		if r.Workspace == "" {
+			return noWorkspaceKey
+		}
+		return r.Workspace
+	case GroupByProvider:
+		if !r.Tracked {
+			return untrackedKey
+		}
+		return r.Usage.Provider
+	default:
+		return r.CreatedAt.UTC().Format(time.DateOnly)
+	}
+}
+
+// add accumulates one record into g.
+func add(g *api.UsageGroup, r store.UsageRecord, prices Prices) {
+	g.Requests++
+	if !r.Tracked {
+		g.Untracked++
+		return
+	}
+	g.PromptTokens += int64(r.Usage.PromptTokens)
+	g.CompletionTokens += int64(r.Usage.CompletionTokens)
+	price, ok := prices.lookup(r.Usage.Provider, r.Usage.Model)
+	if !ok {
+		g.Unpriced++
+		return
+	}
+	g.EstimatedCost += float64(r.Usage.PromptTokens)/1000*price.Prompt +
+		float64(r.Usage.CompletionTokens)/1000*price.Completion
+}
+
+// Write renders report in format (table, json, or csv).
+func Write(w io.Writer, report api.UsageReport, format string) error {
+	switch format {
+	case FormatTable, "":
+		return writeTable(w, report)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("usage: failed to write JSON: %w", err)
+		}
+		return nil
+	case FormatCSV:
+		return writeCSV(w, report)
+	}
+	return fmt.Errorf("usage: invalid format %q (valid: table, json, csv)", format)
+}
+
+// header is the column header shared by the table and CSV formats.
+var header = []string{"key", "requests", "untracked", "prompt_tokens", "completion_tokens", "estimated_cost", "unpriced"}
+
+// row renders g as header-ordered cells.
+func row(g api.UsageGroup) []string {
+	return []string{
+		g.Key,
+		strconv.Itoa(g.Requests),
+		strconv.Itoa(g.Untracked),
+		strconv.FormatInt(g.PromptTokens, 10),
+		strconv.FormatInt(g.CompletionTokens, 10),
+		strconv.FormatFloat(g.EstimatedCost, 'f', 4, 64),
+		strconv.Itoa(g.Unpriced),
+	}
+}
+
+// writeTable renders report as an aligned table with a total row.
+func writeTable(w io.Writer, report api.UsageReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	cols := append([]string{report.GroupBy}, header[1:]...)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(cols, "\t"))+"\t")
+	for _, g := range report.Groups {
+		fmt.Fprintln(tw, strings.Join(row(g), "\t")+"\t")
+	}
+	fmt.Fprintln(tw, strings.Join(row(report.Total), "\t")+"\t")
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("usage: failed to write table: %w", err)
+	}
+	return nil
+}
+
+// writeCSV renders report as CSV; the total row comes last.
+func writeCSV(w io.Writer, report api.UsageReport) error {
+	cw := csv.NewWriter(w)
+	records := [][]string{header}
+	for _, g := range report.Groups {
+		records = append(records, row(g))
+	}
+	records = append(records, row(report.Total))
+	if err := cw.WriteAll(records); err != nil {
+		return fmt.Errorf("usage: failed to write CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,349 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/config"
+	"github.com/54b3r/tfai-go/internal/store"
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// day returns midnight UTC of June d, 2024 plus h hours.
+func day(d, h int) time.Time {
+	return time.Date(2024, 6, d, h, 0, 0, 0, time.UTC)
+}
+
+// tracked builds a record with usage metadata.
+func tracked(ws string, at time.Time, provider, model string, prompt, completion int) store.UsageRecord {
+	return store.UsageRecord{
+		Workspace: ws,
+		CreatedAt: at,
+		Tracked:   true,
+		Usage:     store.Usage{Provider: provider, Model: model, PromptTokens: prompt, CompletionTokens: completion},
+	}
+}
+
+// syntheticRecords spans three days, two workspaces, two providers, and one
+// untracked message.
+func syntheticRecords() []store.UsageRecord {
+	return []store.UsageRecord{
+		{Workspace: "/ws/a", CreatedAt: day(1, 9)}, // untracked
+		tracked("/ws/a", day(1, 10), "openai", "gpt-4o", 2000, 1000),
+		tracked("/ws/b", day(2, 23), "openai", "gpt-4o-mini", 1000, 0),
+		tracked("/ws/b", day(3, 1), "ollama", "llama3", 500, 500),
+		tracked("/ws/a", day(3, 2), "bedrock", "claude", 100, 100),
+	}
+}
+
+// testPrices prices gpt-4o exactly, every other openai model via "*", and
+// ollama at zero. bedrock is unpriced.
+var testPrices = Prices{
+	"openai": {
+		"gpt-4o": {Prompt: 0.005, Completion: 0.015},
+		"*":      {Prompt: 0.001, Completion: 0.002},
+	},
+	"ollama": {"*": {}},
+}
+
+// group returns the group with key, failing the test if absent.
+func group(t *testing.T, r api.UsageReport, key string) api.UsageGroup {
+	t.Helper()
+	for _, g := range r.Groups {
+		if g.Key == key {
+			return g
+		}
+	}
+	t.Fatalf("no group %q in %+v", key, r.Groups)
+	return api.UsageGroup{}
+}
+
+// approx reports whether a and b are equal to within a micro-dollar.
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// ---------------------------------------------------------------------------
+// Aggregate
+// ---------------------------------------------------------------------------
+
+func TestAggregate_Totals(t *testing.T) {
+	t.Parallel()
+
+	r := Aggregate(syntheticRecords(), time.Time{}, GroupByDay, testPrices)
+	want := api.UsageGroup{
+		Key:              "total",
+		Requests:         5,
+		Untracked:        1,
+		PromptTokens:     3600,
+		CompletionTokens: 1600,
+		Unpriced:         1,
+	}
+	got := r.Total
+	// gpt-4o: 2*0.005 + 1*0.015 = 0.025; gpt-4o-mini via "*": 1*0.001 = 0.001; ollama: 0.
+	if !approx(got.EstimatedCost, 0.026) {
+		t.Errorf("total cost: expected 0.026, got %v", got.EstimatedCost)
+	}
+	got.EstimatedCost = 0
+	if got != want {
+		t.Errorf("total: expected %+v, got %+v", want, got)
+	}
+	if r.Since != "" {
+		t.Errorf("expected empty since, got %q", r.Since)
+	}
+}
+
+func TestAggregate_GroupBy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		groupBy GroupBy
+		keys    []string
+		check   func(t *testing.T, r api.UsageReport)
+	}{
+		{
+			groupBy: GroupByDay,
+			keys:    []string{"2024-06-01", "2024-06-02", "2024-06-03"},
+			check: func(t *testing.T, r api.UsageReport) {
+				g := group(t, r, "2024-06-01")
+				if g.Requests != 2 || g.Untracked != 1 || g.PromptTokens != 2000 || !approx(g.EstimatedCost, 0.025) {
+					t.Errorf("2024-06-01: got %+v", g)
+				}
+			},
+		},
+		{
+			groupBy: GroupByWorkspace,
+			keys:    []string{"/ws/a", "/ws/b"},
+			check: func(t *testing.T, r api.UsageReport) {
+				g := group(t, r, "/ws/b")
+				if g.Requests != 2 || g.PromptTokens != 1500 || g.CompletionTokens != 500 || !approx(g.EstimatedCost, 0.001) {
+					t.Errorf("/ws/b: got %+v", g)
+				}
+			},
+		},
+		{
+			groupBy: GroupByProvider,
+			keys:    []string{"bedrock", "ollama", "openai", "untracked"},
+			check: func(t *testing.T, r api.UsageReport) {
+				if g := group(t, r, "bedrock"); g.Unpriced != 1 || g.EstimatedCost != 0 {
+					t.Errorf("bedrock: expected unpriced, got %+v", g)
+				}
+				if g := group(t, r, "untracked"); g.Requests != 1 || g.Untracked != 1 || g.PromptTokens != 0 {
+					t.Errorf("untracked: got %+v", g)
+				}
+				if g := group(t, r, "openai"); g.Requests != 2 || !approx(g.EstimatedCost, 0.026) {
+					t.Errorf("openai: got %+v", g)
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(string(tc.groupBy), func(t *testing.T) {
+			t.Parallel()
+			r := Aggregate(syntheticRecords(), day(1, 0), tc.groupBy, testPrices)
+			if r.GroupBy != string(tc.groupBy) {
+				t.Errorf("expected groupBy %q, got %q", tc.groupBy, r.GroupBy)
+			}
+			if r.Since != "2024-06-01T00:00:00.000Z" {
+				t.Errorf("expected since to be echoed, got %q", r.Since)
+			}
+			var keys []string
+			for _, g := range r.Groups {
+				keys = append(keys, g.Key)
+			}
+			if strings.Join(keys, ",") != strings.Join(tc.keys, ",") {
+				t.Errorf("expected keys %v, got %v", tc.keys, keys)
+			}
+			tc.check(t, r)
+		})
+	}
+}
+
+func TestAggregate_Empty(t *testing.T) {
+	t.Parallel()
+
+	r := Aggregate(nil, time.Time{}, GroupByWorkspace, nil)
+	if r.Groups == nil || len(r.Groups) != 0 || r.Total.Requests != 0 {
+		t.Errorf("expected an empty, non-nil report, got %+v", r)
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"groups":[]`) {
+		t.Errorf("expected groups to encode as [], got %s", b)
+	}
+}
+
+func TestPricesFromConfig(t *testing.T) {
+	t.Parallel()
+
+	p := PricesFromConfig(config.BudgetConfig{Prices: map[string]map[string]config.PriceConfig{
+		"openai": {"gpt-4o": {Prompt: 1, Completion: 2}},
+	}})
+	if got, ok := p.lookup("openai", "gpt-4o"); !ok || got != (Price{Prompt: 1, Completion: 2}) {
+		t.Errorf("expected openai/gpt-4o price, got %+v, %v", got, ok)
+	}
+	if _, ok := p.lookup("openai", "gpt-4o-mini"); ok {
+		t.Error("expected no price for an unlisted model without a wildcard")
+	}
+	if got, ok := p.lookup("openai", "gpt-4o-2024-08-06"); !ok || got != (Price{Prompt: 1, Completion: 2}) {
+		t.Errorf("expected a dated version priced as its model, got %+v, %v", got, ok)
+	}
+}
+
+func TestDefaultPrices(t *testing.T) {
+	t.Parallel()
+
+	p := DefaultPrices().With(PricesFromConfig(config.BudgetConfig{Prices: map[string]map[string]config.PriceConfig{
+		"azure": {"*": {Prompt: 1, Completion: 1}},
+	}}))
+	tests := []struct {
+		provider, model string
+		want            Price
+		wantOK          bool
+	}{
+		{"openai", "gpt-4o", Price{Prompt: 0.0025, Completion: 0.01}, true},
+		{"openai", "gpt-4o-mini-2024-07-18", Price{Prompt: 0.00015, Completion: 0.0006}, true},
+		{"bedrock", "anthropic.claude-3-5-sonnet-20240620-v1:0", Price{Prompt: 0.003, Completion: 0.015}, true},
+		{"ollama", "llama3.1", Price{}, true},
+		{"openai", "my-finetune", Price{}, false},
+		// The config's azure table replaces the built-in one.
+		{"azure", "gpt-4o", Price{Prompt: 1, Completion: 1}, true},
+	}
+	for _, tc := range tests {
+		if got, ok := p.lookup(tc.provider, tc.model); ok != tc.wantOK || got != tc.want {
+			t.Errorf("%s/%s: expected %+v, %v, got %+v, %v", tc.provider, tc.model, tc.want, tc.wantOK, got, ok)
+		}
+	}
+
+	// A million gpt-4o prompt tokens and 100K completion tokens cost
+	// $2.50 + $1.00.
+	r := Aggregate([]store.UsageRecord{tracked("/ws", day(1, 0), "openai", "gpt-4o", 1_000_000, 100_000)}, time.Time{}, GroupByDay, DefaultPrices())
+	if !approx(r.Total.EstimatedCost, 3.5) || r.Total.Unpriced != 0 {
+		t.Errorf("expected $3.50, got %+v", r.Total)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Write
+// ---------------------------------------------------------------------------
+
+func TestWrite_CSVEscaping(t *testing.T) {
+	t.Parallel()
+
+	records := []store.UsageRecord{
+		tracked(`/ws/with,comma`, day(1, 0), "openai", "gpt-4o", 1000, 0),
+		tracked(`/ws/with "quotes"`, day(1, 0), "openai", "gpt-4o", 1000, 0),
+	}
+	r := Aggregate(records, time.Time{}, GroupByWorkspace, testPrices)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, r, FormatCSV); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"/ws/with ""quotes"""`) || !strings.Contains(buf.String(), `"/ws/with,comma"`) {
+		t.Errorf("expected quoted fields, got:\n%s", buf.String())
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV does not parse back: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected header, 2 groups, and total, got %d rows", len(rows))
+	}
+	if rows[0][0] != "key" || rows[1][0] != `/ws/with "quotes"` || rows[2][0] != "/ws/with,comma" || rows[3][0] != "total" {
+		t.Errorf("unexpected key column: %v", rows)
+	}
+	if rows[3][5] != "0.0100" {
+		t.Errorf("expected total cost 0.0100, got %q", rows[3][5])
+	}
+}
+
+func TestWrite_Formats(t *testing.T) {
+	t.Parallel()
+
+	r := Aggregate(syntheticRecords(), time.Time{}, GroupByProvider, testPrices)
+
+	var table bytes.Buffer
+	if err := Write(&table, r, FormatTable); err != nil {
+		t.Fatalf("table: %v", err)
+	}
+	if !strings.Contains(table.String(), "PROVIDER") || !strings.Contains(table.String(), "untracked") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+
+	var js bytes.Buffer
+	if err := Write(&js, r, FormatJSON); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var back api.UsageReport
+	if err := json.Unmarshal(js.Bytes(), &back); err != nil {
+		t.Fatalf("json does not round-trip: %v", err)
+	}
+	if back.Total != r.Total || len(back.Groups) != len(r.Groups) {
+		t.Errorf("json round-trip mismatch: %+v vs %+v", back, r)
+	}
+
+	if err := Write(&bytes.Buffer{}, r, "xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Parsing
+// ---------------------------------------------------------------------------
+
+func TestParseSince(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "", want: time.Time{}},
+		{in: "2024-06-01", want: day(1, 0)},
+		{in: "2024-06-01T10:00:00Z", want: day(1, 10)},
+		{in: "7d", want: day(8, 10).AddDate(0, 0, -7)},
+		{in: "0d", want: day(8, 10)},
+		{in: "12h", want: day(8, 10).Add(-12 * time.Hour)},
+		{in: "June 1st", wantErr: true},
+		{in: "-7d", wantErr: true},
+		{in: "-1h", wantErr: true},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.in, func(t *testing.T) {
+			t.Parallel()
+			got, err := parseSince(tc.in, day(8, 10))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error=%v, got %v", tc.wantErr, err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseGroupBy(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string]GroupBy{"": GroupByDay, "day": GroupByDay, "workspace": GroupByWorkspace, "provider": GroupByProvider} {
+		if got, err := ParseGroupBy(in); err != nil || got != want {
+			t.Errorf("ParseGroupBy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseGroupBy("model"); err == nil {
+		t.Error("expected error for unknown group-by")
+	}
+}
@@ -0,0 +1,170 @@
+// Package watch implements the regenerate-on-change loop behind
+// `tfai generate --watch`. It polls a description file, debounces bursts of
+// edits, and re-runs generation into the same output directory so the agent
+// sees the previously applied files as workspace context and can make
+// incremental edits instead of full rewrites.
+//
+// Polling is used instead of filesystem notifications: a single small file
+// checked a few times a second costs nothing and behaves the same on every
+// platform and editor (including editors that replace files on save).
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+	"github.com/54b3r/tfai-go/internal/filediff"
+)
+
+// Default timings used when the corresponding Watcher field is zero.
+const (
+	// DefaultInterval is how often the description file is polled.
+	DefaultInterval = 250 * time.Millisecond
+	// DefaultDebounce is how long the file must stay unchanged before a
+	// regeneration starts, so a burst of saves triggers one run.
+	DefaultDebounce = 500 * time.Millisecond
+)
+
+// Querier is the subset of agent.TerraformAgent used by the watcher.
+type Querier interface {
+	Run(ctx context.Context, req agent.QueryRequest) (*agent.QueryResult, error)
+}
+
+// Watcher regenerates Terraform into OutDir whenever the file at Path changes.
+type Watcher struct {
+	// Path is the description (spec) file to watch.
+	Path string
+	// OutDir is the absolute output directory. It must already exist.
+	OutDir string
+	// Querier runs one generation.
+	Querier Querier
+	// Prompt builds the agent prompt from the current description.
+	// iteration is 0 for the initial generation and increments per change.
+	Prompt func(description string, iteration int) string
+	// Out receives agent output and per-iteration diff summaries.
+	Out io.Writer
+	// Color enables ANSI colors in diff summaries.
+	Color bool
+	// ResolveConflict is asked about generated files the user edited since
+	// the previous generation wrote them (see agent.QueryOptions). Nil
+	// overwrites them.
+	ResolveConflict agent.ConflictResolver
+	// Interval is the polling interval. Defaults to DefaultInterval.
+	Interval time.Duration
+	// Debounce is the quiet period required before regenerating.
+	// Defaults to DefaultDebounce.
+	Debounce time.Duration
+}
+
+// Run performs the initial generation and then regenerates on every change
+// until ctx is cancelled. Generations never overlap: changes made while a
+// run is in progress are picked up once it finishes. A failed generation is
+// reported to Out and the watcher keeps going. Run returns nil when ctx is
+// cancelled.
+func (w *Watcher) Run(ctx context.Context) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	debounce := w.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+
+	desc, sum, err := readSpec(w.Path)
+	if err != nil {
+		return err
+	}
+	iteration := 0
+	if err := w.generate(ctx, desc, iteration); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		fmt.Fprintf(w.Out, "\ngeneration failed: %v\n", err)
+	}
+	fmt.Fprintf(w.Out, "\nwatching %s for changes (Ctrl-C to stop)\n", w.Path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		pending    bool
+		lastChange time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			_, cur, err := readSpec(w.Path)
+			if err != nil {
+				// Editors may briefly remove the file while saving.
+				continue
+			}
+			if cur != sum {
+				sum = cur
+				pending = true
+				lastChange = now
+				continue
+			}
+			if !pending || now.Sub(lastChange) < debounce {
+				continue
+			}
+			pending = false
+
+			desc, sum, err = readSpec(w.Path)
+			if err != nil {
+				continue
+			}
+			iteration++
+			fmt.Fprintf(w.Out, "\n--- change detected, regenerating (iteration %d) ---\n", iteration)
+			if err := w.generate(ctx, desc, iteration); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fmt.Fprintf(w.Out, "\ngeneration failed: %v\n", err)
+			}
+		}
+	}
+}
+
+// generate runs one generation and prints a diff summary of OutDir.
+func (w *Watcher) generate(ctx context.Context, desc string, iteration int) error {
+	before, err := filediff.Snapshot(w.OutDir)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Querier.Run(ctx, agent.QueryRequest{
+		Message:      w.Prompt(desc, iteration),
+		WorkspaceDir: w.OutDir,
+		Output:       w.Out,
+		Options:      agent.QueryOptions{ExpectEnvelope: true, ResolveConflict: w.ResolveConflict},
+	}); err != nil {
+		return fmt.Errorf("watch: generation failed: %w", err)
+	}
+	after, err := filediff.Snapshot(w.OutDir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w.Out)
+	filediff.WriteSummary(w.Out, filediff.Compare(before, after), w.Color)
+	return nil
+}
+
+// readSpec reads the description file and returns its trimmed content and
+// a hash of it used for change detection, so saves that only add or remove
+// surrounding whitespace do not trigger a regeneration.
+func readSpec(path string) (string, [sha256.Size]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", [sha256.Size]byte{}, fmt.Errorf("watch: failed to read %s: %w", path, err)
+	}
+	desc := strings.TrimSpace(string(b))
+	return desc, sha256.Sum256([]byte(desc)), nil
+}
@@ -0,0 +1,227 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/agent"
+)
+
+// ---------------------------------------------------------------------------
+// Fake querier
+// ---------------------------------------------------------------------------
+
+// evolvingQuerier simulates the agent: each call writes main.tf containing
+// the description it was prompted with, plus outputs.tf from the second
+// call on, so successive envelopes differ.
+type evolvingQuerier struct {
+	mu      sync.Mutex
+	prompts []string
+	active  int
+	overlap bool
+	// delay keeps each generation busy so overlapping triggers can be observed.
+	delay time.Duration
+}
+
+func (q *evolvingQuerier) Run(_ context.Context, req agent.QueryRequest) (*agent.QueryResult, error) {
+	msg, dir := req.Message, req.WorkspaceDir
+	q.mu.Lock()
+	q.active++
+	if q.active > 1 {
+		q.overlap = true
+	}
+	q.prompts = append(q.prompts, msg)
+	n := len(q.prompts)
+	q.mu.Unlock()
+
+	time.Sleep(q.delay)
+
+	desc := msg[strings.LastIndex(msg, "Description: ")+len("Description: "):]
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte("# "+desc+"\n"), 0o644); err != nil {
+		return &agent.QueryResult{}, err
+	}
+	if n > 1 {
+		if err := os.WriteFile(filepath.Join(dir, "outputs.tf"), []byte(fmt.Sprintf("# v%d\n", n)), 0o644); err != nil {
+			return &agent.QueryResult{}, err
+		}
+	}
+	_, _ = fmt.Fprint(req.Output, "generated")
+
+	q.mu.Lock()
+	q.active--
+	q.mu.Unlock()
+	files := []string{"main.tf"}
+	if n > 1 {
+		files = append(files, "outputs.tf")
+	}
+	return &agent.QueryResult{Files: files}, nil
+}
+
+func (q *evolvingQuerier) calls() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]string(nil), q.prompts...)
+}
+
+// syncBuffer is a goroutine-safe io.Writer for capturing watcher output.
+type syncBuffer struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.String()
+}
+
+// startWatcher runs a Watcher in the background with fast timings and
+// returns a stop function that cancels it and waits for Run to return.
+func startWatcher(t *testing.T, q Querier, spec, out string, buf io.Writer) func() {
+	t.Helper()
+	w := &Watcher{
+		Path:    spec,
+		OutDir:  out,
+		Querier: q,
+		Prompt: func(desc string, iteration int) string {
+			return fmt.Sprintf("iteration=%d\nDescription: %s", iteration, desc)
+		},
+		Out:      buf,
+		Interval: 5 * time.Millisecond,
+		Debounce: 40 * time.Millisecond,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	return func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run returned error: %v", err)
+		}
+	}
+}
+
+// waitFor polls cond until it is true or the deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+// ---------------------------------------------------------------------------
+// Watcher.Run
+// ---------------------------------------------------------------------------
+
+func TestWatcher_RegeneratesSequentiallyOnChange(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	spec := filepath.Join(dir, "spec.md")
+	out := filepath.Join(dir, "out")
+	if err := os.Mkdir(out, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(spec, []byte("a vpc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	q := &evolvingQuerier{delay: 20 * time.Millisecond}
+	var buf syncBuffer
+	stop := startWatcher(t, q, spec, out, &buf)
+	defer stop()
+
+	waitFor(t, "initial generation", func() bool { return len(q.calls()) == 1 })
+
+	if err := os.WriteFile(spec, []byte("a vpc with flow logs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "regeneration", func() bool { return len(q.calls()) == 2 })
+
+	calls := q.calls()
+	if !strings.HasPrefix(calls[0], "iteration=0") || !strings.HasPrefix(calls[1], "iteration=1") {
+		t.Errorf("expected iterations 0 then 1, got %q", calls)
+	}
+	if !strings.HasSuffix(calls[1], "a vpc with flow logs") {
+		t.Errorf("regeneration should use the new description, got %q", calls[1])
+	}
+
+	waitFor(t, "diff summary", func() bool { return strings.Contains(buf.String(), "+ outputs.tf") })
+	output := buf.String()
+	for _, want := range []string{"+ main.tf (+1)", "~ main.tf (+1 -1)", "+ outputs.tf (+1)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+}
+
+func TestWatcher_DebouncesBurstsAndNeverOverlaps(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	spec := filepath.Join(dir, "spec.md")
+	if err := os.WriteFile(spec, []byte("v0"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	q := &evolvingQuerier{delay: 20 * time.Millisecond}
+	var buf syncBuffer
+	stop := startWatcher(t, q, spec, dir, &buf)
+
+	waitFor(t, "initial generation", func() bool { return len(q.calls()) == 1 })
+
+	// A burst of saves inside the debounce window must produce one run.
+	for i := 1; i <= 5; i++ {
+		if err := os.WriteFile(spec, []byte(fmt.Sprintf("v%d", i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	waitFor(t, "debounced regeneration", func() bool { return len(q.calls()) == 2 })
+	// A save that only changes surrounding whitespace is not a change.
+	if err := os.WriteFile(spec, []byte("v5\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(150 * time.Millisecond) // room for any spurious extra run
+	stop()
+
+	calls := q.calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 generations, got %d: %q", len(calls), calls)
+	}
+	if !strings.HasSuffix(calls[1], "v5") {
+		t.Errorf("debounced run should see the final content, got %q", calls[1])
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.overlap {
+		t.Error("generations overlapped")
+	}
+}
+
+func TestWatcher_MissingSpec(t *testing.T) {
+	t.Parallel()
+
+	w := &Watcher{Path: filepath.Join(t.TempDir(), "missing.md"), OutDir: t.TempDir(), Out: io.Discard}
+	if err := w.Run(context.Background()); err == nil {
+		t.Fatal("expected error for missing spec file")
+	}
+}
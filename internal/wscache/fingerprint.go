@@ -0,0 +1,42 @@
+package wscache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// TreeFingerprint hashes the slash-separated relative path, size, and
+// modification time of every regular file under dir for which match
+// returns true. Adding, removing, resizing, or touching a matching file
+// changes the result; file contents are never read.
+func TreeFingerprint(dir string, match func(rel string) bool) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // skip unreadable entries, as the loaders do
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || !match(filepath.ToSlash(rel)) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", filepath.ToSlash(rel), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("wscache: fingerprint %s: %w", dir, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,204 @@
+// Package wscache caches data derived from a workspace's files, such as the
+// workspace context injected into prompts, in bounded in-memory LRUs.
+//
+// Each entry records a fingerprint of the files it was derived from, usually
+// their names, sizes, and modification times (see TreeFingerprint), and is
+// recomputed when the fingerprint changes. Because modification times can
+// be coarser than back-to-back writes, code that mutates a workspace also
+// calls Group.Invalidate, which drops every entry of that workspace in every
+// cache of the group.
+package wscache
+
+import (
+	"container/list"
+	"path/filepath"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultSize is the number of entries a cache holds when Register is given
+// a size of zero.
+const DefaultSize = 64
+
+// Group is a set of caches invalidated together. It is safe for concurrent
+// use. A nil *Group is valid: Invalidate does nothing and Register returns
+// caches that are not invalidated by any group.
+type Group struct {
+	// lookups counts cache lookups, partitioned by kind and result ("hit"
+	// or "miss").
+	lookups *prometheus.CounterVec
+	// mu guards caches.
+	mu sync.Mutex
+	// caches holds every registered cache.
+	caches []invalidator
+}
+
+// invalidator is the type-erased view of a Cache used by Group.
+type invalidator interface {
+	invalidate(workspace string)
+}
+
+// NewGroup returns an empty Group whose lookup counter is registered
+// against reg. When reg is nil a private registry is used, so the counter
+// is recorded but never exported.
+func NewGroup(reg prometheus.Registerer) *Group {
+	if reg == nil {
+		reg = prometheus.NewRegistry()
+	}
+	return &Group{
+		lookups: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "tfai",
+			Subsystem: "wscache",
+			Name:      "lookups_total",
+			Help:      "Total number of workspace cache lookups, partitioned by data kind and result (hit or miss).",
+		}, []string{"kind", "result"}),
+	}
+}
+
+// Invalidate drops every cached entry of workspace, in every cache of the
+// group. Call it after writing, saving, or deleting files in workspace.
+func (g *Group) Invalidate(workspace string) {
+	if g == nil {
+		return
+	}
+	workspace = filepath.Clean(workspace)
+	g.mu.Lock()
+	caches := append([]invalidator(nil), g.caches...)
+	g.mu.Unlock()
+	for _, c := range caches {
+		c.invalidate(workspace)
+	}
+}
+
+// FingerprintFunc returns a value that changes whenever the files a cached
+// value was derived from change. An error disables caching for that lookup.
+type FingerprintFunc func(workspace string) (string, error)
+
+// Cache is a bounded LRU of values of one kind, keyed by workspace and an
+// optional sub-key (e.g. a file scope). It is safe for concurrent use.
+type Cache[V any] struct {
+	// kind labels the cache's lookups, e.g. "workspace_context".
+	kind string
+	// size is the maximum number of entries.
+	size int
+	// fingerprint computes the current fingerprint of a workspace.
+	fingerprint FingerprintFunc
+	// hits and misses count lookups; nil when the cache has no group.
+	hits, misses prometheus.Counter
+	// mu guards order and entries.
+	mu sync.Mutex
+	// order lists entries from most to least recently used.
+	order *list.List
+	// entries indexes order by entry key.
+	entries map[entryKey]*list.Element
+}
+
+// entryKey identifies a cache entry.
+type entryKey struct {
+	workspace string
+	key       string
+}
+
+// entry is one cached value.
+type entry[V any] struct {
+	key         entryKey
+	fingerprint string
+	value       V
+}
+
+// Register returns a new Cache of kind holding at most size entries
+// (DefaultSize if zero), whose entries are invalidated with g.
+func Register[V any](g *Group, kind string, size int, fingerprint FingerprintFunc) *Cache[V] {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	c := &Cache[V]{
+		kind:        kind,
+		size:        size,
+		fingerprint: fingerprint,
+		order:       list.New(),
+		entries:     make(map[entryKey]*list.Element),
+	}
+	if g != nil {
+		c.hits = g.lookups.WithLabelValues(kind, "hit")
+		c.misses = g.lookups.WithLabelValues(kind, "miss")
+		g.mu.Lock()
+		g.caches = append(g.caches, c)
+		g.mu.Unlock()
+	}
+	return c
+}
+
+// Get returns the value cached for workspace and key when the workspace's
+// fingerprint still matches the one it was stored with. Otherwise it calls
+// load and caches the result, evicting the least recently used entry when
+// the cache is full. Errors from load are returned and never cached.
+//
+// load runs without the cache locked, so concurrent misses on the same
+// entry may each call it; the last result stored wins.
+func (c *Cache[V]) Get(workspace, key string, load func() (V, error)) (V, error) {
+	k := entryKey{workspace: filepath.Clean(workspace), key: key}
+	fp, fpErr := c.fingerprint(k.workspace)
+	if fpErr == nil {
+		c.mu.Lock()
+		if el, ok := c.entries[k]; ok {
+			e := el.Value.(*entry[V]) //nolint:forcetypeassert // only *entry[V] is stored
+			if e.fingerprint == fp {
+				c.order.MoveToFront(el)
+				c.mu.Unlock()
+				c.count(c.hits)
+				return e.value, nil
+			}
+		}
+		c.mu.Unlock()
+	}
+	c.count(c.misses)
+
+	v, err := load()
+	if err != nil || fpErr != nil {
+		return v, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[k]; ok {
+		el.Value = &entry[V]{key: k, fingerprint: fp, value: v}
+		c.order.MoveToFront(el)
+		return v, nil
+	}
+	c.entries[k] = c.order.PushFront(&entry[V]{key: k, fingerprint: fp, value: v})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[V]).key) //nolint:forcetypeassert // only *entry[V] is stored
+	}
+	return v, nil
+}
+
+// Len returns the number of cached entries.
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// invalidate drops every entry of workspace.
+func (c *Cache[V]) invalidate(workspace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, el := range c.entries {
+		if k.workspace == workspace {
+			c.order.Remove(el)
+			delete(c.entries, k)
+		}
+	}
+}
+
+// count increments counter when the cache has one.
+func (c *Cache[V]) count(counter prometheus.Counter) {
+	if counter != nil {
+		counter.Inc()
+	}
+}
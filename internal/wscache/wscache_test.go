@@ -0,0 +1,259 @@
+package wscache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// tfFiles matches the .tf files of a workspace.
+func tfFiles(rel string) bool { return strings.HasSuffix(rel, ".tf") }
+
+// tfFingerprint fingerprints the .tf files of a workspace.
+func tfFingerprint(dir string) (string, error) { return TreeFingerprint(dir, tfFiles) }
+
+// staticFingerprint never changes, so only eviction and invalidation drop
+// entries.
+func staticFingerprint(string) (string, error) { return "v1", nil }
+
+// counter returns a load func that returns value and counts its calls.
+func counter(value string, calls *int) func() (string, error) {
+	return func() (string, error) {
+		*calls++
+		return value, nil
+	}
+}
+
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Fingerprints
+// ---------------------------------------------------------------------------
+
+func TestCache_FingerprintInvalidation(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		change   func(t *testing.T, dir string)
+		wantLoad bool
+	}{
+		{name: "nothing changed", change: func(*testing.T, string) {}},
+		{
+			name: "file modified",
+			change: func(t *testing.T, dir string) {
+				writeFile(t, filepath.Join(dir, "main.tf"), "# v2", base.Add(time.Second))
+			},
+			wantLoad: true,
+		},
+		{
+			name: "same size, newer mtime",
+			change: func(t *testing.T, dir string) {
+				writeFile(t, filepath.Join(dir, "main.tf"), "# v9", base.Add(time.Second))
+			},
+			wantLoad: true,
+		},
+		{
+			name:     "file added",
+			change:   func(t *testing.T, dir string) { writeFile(t, filepath.Join(dir, "outputs.tf"), "", base) },
+			wantLoad: true,
+		},
+		{
+			name: "file removed",
+			change: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, "main.tf")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantLoad: true,
+		},
+		{
+			name:   "unmatched file changed",
+			change: func(t *testing.T, dir string) { writeFile(t, filepath.Join(dir, "README.md"), "docs", base) },
+		},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			writeFile(t, filepath.Join(dir, "main.tf"), "# v1", base)
+
+			c := Register[string](NewGroup(nil), "test", 0, tfFingerprint)
+			calls := 0
+			if _, err := c.Get(dir, "", counter("ctx", &calls)); err != nil {
+				t.Fatal(err)
+			}
+			tc.change(t, dir)
+			if _, err := c.Get(dir, "", counter("ctx", &calls)); err != nil {
+				t.Fatal(err)
+			}
+			if got := calls == 2; got != tc.wantLoad {
+				t.Errorf("expected reload=%v, got %d loads", tc.wantLoad, calls)
+			}
+		})
+	}
+}
+
+func TestCache_Keys(t *testing.T) {
+	t.Parallel()
+
+	c := Register[string](NewGroup(nil), "test", 0, staticFingerprint)
+	for _, k := range []struct{ workspace, key, value string }{
+		{"/ws/a", "", "a"},
+		{"/ws/a", "modules/*", "a-modules"},
+		{"/ws/b", "", "b"},
+	} {
+		got, _ := c.Get(k.workspace, k.key, func() (string, error) { return k.value, nil })
+		if got != k.value {
+			t.Errorf("expected %q, got %q", k.value, got)
+		}
+	}
+	got, _ := c.Get("/ws/a/", "modules/*", func() (string, error) { return "reloaded", nil })
+	if got != "a-modules" {
+		t.Errorf("expected an unclean path to hit the same entry, got %q", got)
+	}
+}
+
+func TestCache_ErrorsAreNotCached(t *testing.T) {
+	t.Parallel()
+
+	c := Register[string](NewGroup(nil), "test", 0, staticFingerprint)
+	if _, err := c.Get("/ws/a", "", func() (string, error) { return "", errors.New("boom") }); err == nil {
+		t.Fatal("expected the load error")
+	}
+	if c.Len() != 0 {
+		t.Errorf("expected a failed load not to be cached, got %d entries", c.Len())
+	}
+
+	calls := 0
+	broken := Register[string](NewGroup(nil), "test", 0, tfFingerprint)
+	for i := 0; i < 2; i++ {
+		if _, err := broken.Get(filepath.Join(t.TempDir(), "missing"), "", counter("x", &calls)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 || broken.Len() != 0 {
+		t.Errorf("expected an unfingerprintable workspace to bypass the cache, got %d loads and %d entries", calls, broken.Len())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Eviction and invalidation
+// ---------------------------------------------------------------------------
+
+func TestCache_LRUEviction(t *testing.T) {
+	t.Parallel()
+
+	c := Register[string](NewGroup(nil), "test", 2, staticFingerprint)
+	calls := 0
+	get := func(ws string) {
+		if _, err := c.Get(ws, "", counter(ws, &calls)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get("/ws/a")
+	get("/ws/b")
+	get("/ws/a") // a is now the most recently used
+	get("/ws/c") // evicts b
+	if calls != 3 || c.Len() != 2 {
+		t.Fatalf("expected 3 loads and 2 entries, got %d and %d", calls, c.Len())
+	}
+	get("/ws/a")
+	get("/ws/c")
+	if calls != 3 {
+		t.Errorf("expected a and c still cached, got %d loads", calls)
+	}
+	get("/ws/b")
+	if calls != 4 {
+		t.Errorf("expected b evicted, got %d loads", calls)
+	}
+}
+
+func TestGroup_InvalidateDropsEveryKind(t *testing.T) {
+	t.Parallel()
+
+	g := NewGroup(nil)
+	contexts := Register[string](g, "workspace_context", 0, staticFingerprint)
+	summaries := Register[int](g, "summary", 0, staticFingerprint)
+	for _, ws := range []string{"/ws/a", "/ws/b"} {
+		_, _ = contexts.Get(ws, "", func() (string, error) { return ws, nil })
+		_, _ = contexts.Get(ws, "scoped", func() (string, error) { return ws, nil })
+		_, _ = summaries.Get(ws, "", func() (int, error) { return 1, nil })
+	}
+
+	g.Invalidate("/ws/a/")
+	if contexts.Len() != 2 || summaries.Len() != 1 {
+		t.Fatalf("expected only /ws/b entries left, got %d contexts and %d summaries", contexts.Len(), summaries.Len())
+	}
+	got, _ := contexts.Get("/ws/b", "", func() (string, error) { return "reloaded", nil })
+	if got != "/ws/b" {
+		t.Errorf("expected /ws/b untouched, got %q", got)
+	}
+
+	var nilGroup *Group
+	nilGroup.Invalidate("/ws/b") // must not panic
+}
+
+func TestCache_Metrics(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+	g := NewGroup(reg)
+	c := Register[string](g, "workspace_context", 0, staticFingerprint)
+	for i := 0; i < 3; i++ {
+		_, _ = c.Get("/ws/a", "", func() (string, error) { return "ctx", nil })
+	}
+	if hits := testutil.ToFloat64(g.lookups.WithLabelValues("workspace_context", "hit")); hits != 2 {
+		t.Errorf("expected 2 hits, got %v", hits)
+	}
+	if misses := testutil.ToFloat64(g.lookups.WithLabelValues("workspace_context", "miss")); misses != 1 {
+		t.Errorf("expected 1 miss, got %v", misses)
+	}
+}
+
+func TestCache_ConcurrentAccess(t *testing.T) {
+	t.Parallel()
+
+	g := NewGroup(nil)
+	c := Register[string](g, "test", 4, staticFingerprint)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				ws := fmt.Sprintf("/ws/%d", (i+j)%8)
+				got, err := c.Get(ws, "", func() (string, error) { return ws, nil })
+				if err != nil || got != ws {
+					t.Errorf("expected %q, got %q, %v", ws, got, err)
+					return
+				}
+				if j%10 == 0 {
+					g.Invalidate(ws)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if c.Len() > 4 {
+		t.Errorf("expected at most 4 entries, got %d", c.Len())
+	}
+}
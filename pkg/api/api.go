@@ -0,0 +1,819 @@
+// Package api defines the wire types shared by the tfai HTTP server and the
+// Go client in pkg/client. Both sides import these structs directly so the
+// request and response shapes cannot drift apart.
+//
+// Only plain data types and protocol constants live here — no behaviour
+// beyond the JSON encoding of Timestamp.
+package api
+
+import "encoding/json"
+
+// HeaderRequestID is the header carrying the per-request correlation ID.
+// The server echoes a client-supplied value when it is well-formed and
+// generates one otherwise.
+const HeaderRequestID = "X-Request-ID"
+
+// HeaderTFAIRequestID carries the same ID as HeaderRequestID on every
+// response. Proxies often replace X-Request-ID with an ID of their own; this
+// header survives them, so the ID a client reads always matches the
+// server's logs and, when tracing is enabled, its Langfuse trace.
+const HeaderTFAIRequestID = "X-TFAI-Request-ID"
+
+// HeaderSecretsDetected is set on PUT /api/file responses when the saved
+// content contains credentials. Its value lists the findings as
+// "type@path:line" entries separated by ", ".
+const HeaderSecretsDetected = "X-Secrets-Detected"
+
+// ProtocolVersion is the version of the POST /api/chat event stream,
+// announced in AcceptedEvent.Protocol. Since version 1 the data of every
+// named event is JSON; streamed response text is plain. Streams that
+// announce no version send the phase, notice, error, and done data as plain
+// text.
+const ProtocolVersion = 1
+
+// SSE event names emitted by POST /api/chat. Frames without an explicit
+// event name are streamed response text; named events carry JSON data.
+const (
+	// EventAccepted is the first event of every stream, sent before any
+	// context is built. Its data is an AcceptedEvent JSON object.
+	EventAccepted = "accepted"
+	// EventMeta follows EventAccepted when the server traces chats to
+	// Langfuse; its data is a MetaEvent JSON object naming the trace.
+	EventMeta = "meta"
+	// EventPhase reports a step of answering the query; its data is the
+	// phase name as a JSON string (e.g. "retrieving_docs").
+	EventPhase = "phase"
+	// EventToolStart reports that the agent started a tool call (e.g.
+	// terraform_plan); its data is a ToolEvent JSON object.
+	EventToolStart = "tool_start"
+	// EventToolEnd reports that a tool call returned; its data is a
+	// ToolEvent JSON object with ElapsedMs and, on failure, Error set.
+	EventToolEnd = "tool_end"
+	// EventNotice carries a one-line message for the user about what the
+	// agent cannot do in this environment, e.g. that terraform is not
+	// installed. Its data is a JSON string, sent before the first token.
+	EventNotice = "notice"
+	// EventError carries an error message as a JSON string; the stream ends
+	// after it.
+	EventError = "error"
+	// EventFilesWritten signals that the agent wrote files to the workspace;
+	// its data is true.
+	EventFilesWritten = "files_written"
+	// EventDisclosure labels the answer as AI-generated; its data is the
+	// configured disclosure text as a JSON string. When a disclosure is
+	// configured it is sent once, directly before EventDone, and is never
+	// part of the answer text.
+	EventDisclosure = "disclosure"
+	// EventTruncated signals that the answer was cut off at the output token
+	// limit; its data is true. Send a ChatRequest with Continue set to
+	// continue it. Sent before EventDisclosure.
+	EventTruncated = "truncated"
+	// EventTimings reports where the time of a completed chat went; its
+	// data is a ChatTimings object. Sent after EventTruncated and before
+	// EventDisclosure.
+	EventTimings = "timings"
+	// EventFilesPreview reports the file envelope of a ChatRequest with
+	// PreviewFiles set, which was not written; its data is a FilesPreview.
+	// Sent in place of EventFilesWritten.
+	EventFilesPreview = "files_preview"
+	// EventInputsNeeded lists the import blocks of the written or previewed
+	// files whose ID the operator has to fill in before terraform can
+	// import the resource; its data is an InputsNeededEvent. Sent after
+	// EventFilesWritten or EventFilesPreview, only when there are any.
+	EventInputsNeeded = "inputs_needed"
+	// EventWorkspaceFiles ends a POST /api/workspace/create stream with
+	// "generate": true, sent before EventError or EventDisclosure; its data
+	// is a CreateWorkspaceResponse listing the scaffold and generated files.
+	// When generation fails it lists only the scaffold, which is kept.
+	EventWorkspaceFiles = "files"
+	// EventDone marks successful completion of the stream; its data is the
+	// JSON string "[DONE]".
+	EventDone = "done"
+)
+
+// AcceptedEvent is the data of the EventAccepted SSE event.
+type AcceptedEvent struct {
+	// Protocol is the ProtocolVersion of the stream.
+	Protocol int `json:"protocol"`
+	// RequestID is the X-Request-ID of the request.
+	RequestID string `json:"requestId"`
+	// ChatID identifies this chat in server logs and traces.
+	ChatID string `json:"chatId"`
+}
+
+// MetaEvent is the data of the EventMeta SSE event.
+type MetaEvent struct {
+	// RequestID is the X-Request-ID of the request.
+	RequestID string `json:"requestId"`
+	// TraceID is the ID of the chat's Langfuse trace: paste it into the
+	// Langfuse trace search to find the model calls behind the answer.
+	TraceID string `json:"traceId"`
+}
+
+// InputsNeededEvent is the data of the EventInputsNeeded SSE event.
+type InputsNeededEvent struct {
+	// Imports lists the import blocks with a placeholder ID, in file order.
+	Imports []ImportInput `json:"imports"`
+}
+
+// ImportInput is an import block whose ID the operator has to fill in.
+type ImportInput struct {
+	// To is the address the block imports into, e.g. aws_s3_bucket.logs.
+	To string `json:"to"`
+	// ID is the placeholder written in place of the ID, describing the ID
+	// to look up, e.g. "<name of the existing bucket>".
+	ID string `json:"id"`
+	// Path is the workspace-relative file declaring the block.
+	Path string `json:"path"`
+	// Line is the line the block starts on.
+	Line int `json:"line"`
+}
+
+// ToolEvent is the data of the EventToolStart and EventToolEnd SSE events.
+type ToolEvent struct {
+	// Tool is the tool name, e.g. "terraform_plan".
+	Tool string `json:"tool"`
+	// CallID pairs the start and end events of one call.
+	CallID string `json:"callId"`
+	// Dir is the directory the tool runs in, when it takes one.
+	Dir string `json:"dir,omitempty"`
+	// ElapsedMs is the call duration in milliseconds. Zero on tool_start.
+	ElapsedMs int64 `json:"elapsedMs"`
+	// Error is the failure message of a failed call. Only set on tool_end.
+	Error string `json:"error,omitempty"`
+}
+
+// ErrorResponse is the JSON body returned by every non-streaming error.
+type ErrorResponse struct {
+	// Error is the human-readable failure message.
+	Error string `json:"error"`
+	// Code is a machine-readable error code. Empty when the failure has no
+	// dedicated code.
+	Code string `json:"code,omitempty"`
+}
+
+// ChatRequest is the JSON body for POST /api/chat.
+type ChatRequest struct {
+	// Message is the user's natural language query.
+	Message string `json:"message"`
+	// WorkspaceDir is the directory to work in.
+	WorkspaceDir string `json:"workspaceDir"`
+	// SessionID selects a conversation session of WorkspaceDir created by
+	// POST /api/session. Empty uses the workspace's default thread.
+	SessionID string `json:"sessionId,omitempty"`
+	// Stream selects the response mode. Nil or true streams SSE events;
+	// false returns a single ChatResponse, as does Accept: application/json.
+	Stream *bool `json:"stream,omitempty"`
+	// Temperature overrides the model temperature for this request, from 0
+	// to 2. Nil uses the server's MODEL_TEMPERATURE.
+	Temperature *float32 `json:"temperature,omitempty"`
+	// MaxTokens overrides the model's output token cap for this request, up
+	// to the server's limit. Zero uses the server's MODEL_MAX_TOKENS.
+	MaxTokens int `json:"maxTokens,omitempty"`
+	// Continue continues the session's last answer, which was cut off at
+	// the output token limit (ChatResponse.Truncated), instead of asking
+	// Message, which may then be empty. The answer is stitched to the cut-off
+	// part, and a file envelope split between them is applied whole. An
+	// answer can be continued at most twice.
+	Continue bool `json:"continue,omitempty"`
+	// PreviewFiles leaves the workspace untouched when the answer is a file
+	// envelope: the response carries a FilesPreview instead, and POST
+	// /api/files/apply writes the envelope with its token.
+	PreviewFiles bool `json:"previewFiles,omitempty"`
+	// ImportMode asks for Terraform 1.5+ import blocks that bring existing
+	// cloud resources under management along with their configuration. The
+	// server also enables it when Message says the resources already exist.
+	ImportMode bool `json:"importMode,omitempty"`
+}
+
+// ChatResponse is the JSON response for a non-streaming POST /api/chat.
+type ChatResponse struct {
+	// Answer is the complete response text, or the file summary when the
+	// agent wrote files.
+	Answer string `json:"answer"`
+	// FilesWritten indicates the agent wrote files to the workspace.
+	FilesWritten bool `json:"filesWritten"`
+	// Files lists the workspace-relative paths written.
+	Files []string `json:"files"`
+	// Sources lists the documentation sources injected as RAG context.
+	Sources []string `json:"sources"`
+	// Usage is the token usage reported by the provider. Nil when the
+	// provider did not report usage.
+	Usage *ChatUsage `json:"usage,omitempty"`
+	// Notices are messages for the user about what the agent cannot do in
+	// this environment; the same text the SSE notice event carries.
+	Notices []string `json:"notices,omitempty"`
+	// Disclosure labels the answer as AI-generated; the same text the SSE
+	// disclosure event carries. Omitted when none is configured.
+	Disclosure string `json:"disclosure,omitempty"`
+	// Truncated is true when the answer was cut off at the output token
+	// limit; see ChatRequest.Continue.
+	Truncated bool `json:"truncated,omitempty"`
+	// Timings is where the time of the request went; the same object the
+	// SSE timings event carries.
+	Timings *ChatTimings `json:"timings,omitempty"`
+	// Preview is the unwritten file envelope of a ChatRequest with
+	// PreviewFiles set; the same object the SSE files_preview event
+	// carries. Omitted when the answer was not an envelope.
+	Preview *FilesPreview `json:"preview,omitempty"`
+	// InputsNeeded lists the import IDs the operator has to fill in; the
+	// same object the SSE inputs_needed event carries. Omitted when there
+	// are none.
+	InputsNeeded *InputsNeededEvent `json:"inputsNeeded,omitempty"`
+	// RequestID is the X-Request-ID of the request.
+	RequestID string `json:"requestId"`
+	// TraceID is the ID of the chat's Langfuse trace. Omitted when the
+	// server does not trace chats.
+	TraceID string `json:"traceId,omitempty"`
+	// DurationMs is the time spent answering, in milliseconds.
+	DurationMs int64 `json:"durationMs"`
+}
+
+// ChatUsage is the token usage of one chat request, summed over every
+// model call the agent made.
+type ChatUsage struct {
+	// PromptTokens is the number of input tokens.
+	PromptTokens int `json:"promptTokens"`
+	// CompletionTokens is the number of generated tokens.
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// ChatTimings is where the time of one chat request went, in milliseconds.
+// Phases that did not run are zero.
+type ChatTimings struct {
+	// ContextMs is building the model input: history, documentation, and
+	// workspace context.
+	ContextMs int64 `json:"contextMs"`
+	// FirstTokenMs is the time from the first model call to the first
+	// response text, tool calls included. Zero when no text arrived.
+	FirstTokenMs int64 `json:"firstTokenMs"`
+	// ModelMs is the model thinking and streaming, tool calls excluded.
+	ModelMs int64 `json:"modelMs"`
+	// ToolsMs is the total time of the tool calls.
+	ToolsMs int64 `json:"toolsMs"`
+	// ToolCalls is the number of tool calls.
+	ToolCalls int `json:"toolCalls"`
+	// Tools lists each tool call in completion order. Omitted from the
+	// timings of stored history messages.
+	Tools []ToolTiming `json:"tools,omitempty"`
+	// ParseMs is parsing the answer as a file envelope.
+	ParseMs int64 `json:"parseMs"`
+	// ApplyMs is writing the envelope's files to the workspace.
+	ApplyMs int64 `json:"applyMs"`
+	// TotalMs is the whole query.
+	TotalMs int64 `json:"totalMs"`
+}
+
+// ToolTiming is the duration of one tool call in ChatTimings.
+type ToolTiming struct {
+	// Tool is the tool name, e.g. "terraform_plan".
+	Tool string `json:"tool"`
+	// ElapsedMs is the call duration in milliseconds.
+	ElapsedMs int64 `json:"elapsedMs"`
+}
+
+// FilesPreview describes a file envelope that was not written: what each
+// file would change, and the token that writes it.
+type FilesPreview struct {
+	// Token identifies the envelope to POST /api/files/apply. It can be
+	// used once, until ExpiresAt.
+	Token string `json:"token"`
+	// ExpiresAt is when the token stops being accepted.
+	ExpiresAt Timestamp `json:"expiresAt"`
+	// Files lists each file of the envelope, in envelope order.
+	Files []FilePreview `json:"files"`
+}
+
+// FilePreview is what writing one file of a FilesPreview would change.
+type FilePreview struct {
+	// Path is relative to the workspace.
+	Path string `json:"path"`
+	// New is true when the file does not exist yet.
+	New bool `json:"new,omitempty"`
+	// Diff is the unified diff from the current content to the generated
+	// content, against /dev/null for a new file. Empty when they are equal.
+	Diff string `json:"diff"`
+}
+
+// FilesApplyRequest is the body of POST /api/files/apply.
+type FilesApplyRequest struct {
+	// Token is FilesPreview.Token.
+	Token string `json:"token"`
+}
+
+// FilesApplyResponse is the JSON response for POST /api/files/apply.
+type FilesApplyResponse struct {
+	// WorkspaceDir is the workspace the files were written to.
+	WorkspaceDir string `json:"workspaceDir"`
+	// Files lists the workspace-relative paths written, in envelope order.
+	Files []string `json:"files"`
+	// BackupDir is where the files the write replaced were backed up,
+	// relative to WorkspaceDir. Omitted when no existing file was replaced.
+	BackupDir string `json:"backupDir,omitempty"`
+}
+
+// WorkspaceResponse is the JSON response for GET /api/workspace.
+type WorkspaceResponse struct {
+	// Dir is the cleaned absolute path that was inspected.
+	Dir string `json:"dir"`
+	// Files is the recursive list of .tf and .tfvars files found under Dir,
+	// returned as paths relative to Dir (e.g. "modules/vpc/main.tf").
+	Files []string `json:"files"`
+	// Dirs is kept for backward compatibility but is now always empty.
+	Dirs []string `json:"dirs"`
+	// Initialized indicates a .terraform directory is present.
+	Initialized bool `json:"initialized"`
+	// HasState indicates a terraform.tfstate file is present.
+	HasState bool `json:"hasState"`
+	// HasLockfile indicates .terraform.lock.hcl is present.
+	HasLockfile bool `json:"hasLockfile"`
+	// BackendType is the state backend the root module's terraform block
+	// configures (e.g. "s3", "azurerm"), "cloud" for HCP Terraform, or
+	// "local" when none is, in which case state is terraform.tfstate.
+	BackendType string `json:"backendType"`
+	// ActiveWorkspace is the terraform workspace selected in
+	// .terraform/environment, or "default" when none is.
+	ActiveWorkspace string `json:"activeWorkspace"`
+	// Deprecations lists the deprecated request parameters the request
+	// used. Omitted when it used none.
+	Deprecations []Deprecation `json:"deprecations,omitempty"`
+}
+
+// WorkspaceTreeResponse is the JSON response for GET /api/workspace/tree.
+type WorkspaceTreeResponse struct {
+	// Dir is the cleaned absolute path that was inspected.
+	Dir string `json:"dir"`
+	// Root is the node for Dir itself; its Name is the directory's base
+	// name and its RelPath is empty.
+	Root TreeNode `json:"root"`
+	// Entries is the number of nodes under Root.
+	Entries int `json:"entries"`
+	// Truncated is set when the entry or depth cap stopped the walk, so the
+	// tree is missing files.
+	Truncated bool `json:"truncated"`
+	// Deprecations lists the deprecated request parameters the request
+	// used. Omitted when it used none.
+	Deprecations []Deprecation `json:"deprecations,omitempty"`
+}
+
+// TreeNode is one directory or file of a WorkspaceTreeResponse.
+type TreeNode struct {
+	// Name is the base name of the entry.
+	Name string `json:"name"`
+	// RelPath is the slash-separated path relative to the workspace.
+	RelPath string `json:"relPath"`
+	// Dir is set for directories.
+	Dir bool `json:"dir,omitempty"`
+	// SizeBytes is the size of a file, or the total size of the files
+	// under a directory.
+	SizeBytes int64 `json:"sizeBytes"`
+	// ModTime is when a file was last modified, or the newest ModTime
+	// under a directory.
+	ModTime Timestamp `json:"modTime"`
+	// Children lists a directory's entries, directories first, each group
+	// sorted by name. Directories without matching files are left out.
+	Children []TreeNode `json:"children,omitempty"`
+}
+
+// WorkspaceSummaryResponse is the JSON response for GET /api/workspace/summary.
+type WorkspaceSummaryResponse struct {
+	// Dir is the cleaned absolute path that was inspected.
+	Dir string `json:"dir"`
+	// HostPlatform is the server's terraform platform, e.g. "linux_amd64".
+	HostPlatform string `json:"hostPlatform"`
+	// Providers are the entries of .terraform.lock.hcl. Empty when the
+	// workspace has no lock file.
+	Providers []LockedProvider `json:"providers"`
+	// LockfileError describes why an existing lock file could not be parsed.
+	LockfileError string `json:"lockfileError,omitempty"`
+	// MissingHostHashes lists the sources of providers whose lock entry is
+	// known to have no hash for HostPlatform.
+	MissingHostHashes []string `json:"missingHostHashes"`
+	// LockCommand is the terraform command that records the missing hashes.
+	// Empty when MissingHostHashes is.
+	LockCommand string `json:"lockCommand,omitempty"`
+	// Deprecations lists the deprecated request parameters the request
+	// used. Omitted when it used none.
+	Deprecations []Deprecation `json:"deprecations,omitempty"`
+}
+
+// Deprecation is a deprecated request field or parameter a request used. It
+// keeps working until it is removed; clients should switch to Replacement.
+type Deprecation struct {
+	// ID identifies the deprecation, e.g. "api-workspace-dir-param".
+	ID string `json:"id"`
+	// Field is the deprecated name the request used.
+	Field string `json:"field"`
+	// Replacement is the name to use instead.
+	Replacement string `json:"replacement"`
+	// Message explains the change.
+	Message string `json:"message"`
+}
+
+// LockedProvider is one provider entry of a dependency lock file.
+type LockedProvider struct {
+	// Source is the provider address, e.g. "registry.terraform.io/hashicorp/aws".
+	Source string `json:"source"`
+	// Version is the locked version.
+	Version string `json:"version"`
+	// Constraints is the version constraint recorded with the selection.
+	Constraints string `json:"constraints,omitempty"`
+	// Hashes are the recorded package checksums.
+	Hashes []string `json:"hashes"`
+	// Platforms are the installed platforms whose package hash is recorded.
+	Platforms []string `json:"platforms"`
+	// UnrecordedPlatforms are installed platforms with no recorded hash.
+	UnrecordedPlatforms []string `json:"unrecordedPlatforms,omitempty"`
+}
+
+// CreateWorkspaceRequest is the JSON body for POST /api/workspace/create.
+type CreateWorkspaceRequest struct {
+	// Dir is the absolute path of an existing directory to scaffold into.
+	Dir string `json:"dir"`
+	// Description is an optional hint for the LLM to pre-fill the chat.
+	Description string `json:"description,omitempty"`
+	// Generate runs the agent with the Description prompt against the new
+	// workspace right after scaffolding. The response is then an SSE stream
+	// like POST /api/chat's, ending with an EventWorkspaceFiles event.
+	// Requires Description.
+	Generate bool `json:"generate,omitempty"`
+}
+
+// CreateWorkspaceResponse is the JSON response for POST /api/workspace/create.
+type CreateWorkspaceResponse struct {
+	// Dir is the absolute path that was scaffolded.
+	Dir string `json:"dir"`
+	// Files is the list of scaffold files written, followed by the files
+	// the agent generated when the request set Generate.
+	Files []string `json:"files"`
+	// Prompt is a pre-filled chat prompt if Description was provided.
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// CleanWorkspaceRequest is the JSON body for POST /api/workspace/clean.
+type CleanWorkspaceRequest struct {
+	// Dir is the absolute path of the workspace whose .tfai artifacts to clean.
+	Dir string `json:"dir"`
+	// OlderThan limits cleaning to artifacts at least this old, e.g. "30d"
+	// or "12h". Empty removes artifacts of any age.
+	OlderThan string `json:"olderThan,omitempty"`
+	// What lists the artifact types to clean (backups, trash,
+	// state-backups, tmp). Empty or ["all"] cleans every type.
+	What []string `json:"what,omitempty"`
+	// DryRun reports what would be removed without deleting anything.
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// CleanedArtifact is one artifact listed in a CleanWorkspaceResponse.
+type CleanedArtifact struct {
+	// Type is the artifact type (backups, trash, state-backups, tmp).
+	Type string `json:"type"`
+	// Path is relative to the workspace directory.
+	Path string `json:"path"`
+	// Bytes is the artifact's size on disk.
+	Bytes int64 `json:"bytes"`
+	// ModTime is the newest modification time within the artifact.
+	ModTime Timestamp `json:"modTime"`
+}
+
+// CleanWorkspaceResponse is the JSON response for POST /api/workspace/clean.
+type CleanWorkspaceResponse struct {
+	// Dir is the absolute workspace path that was cleaned.
+	Dir string `json:"dir"`
+	// DryRun is true when nothing was deleted.
+	DryRun bool `json:"dryRun"`
+	// Removed lists the artifacts removed (or that would be removed).
+	Removed []CleanedArtifact `json:"removed"`
+	// ReclaimedBytes is the total size of Removed.
+	ReclaimedBytes int64 `json:"reclaimedBytes"`
+}
+
+// FileResponse is the JSON response for GET /api/file.
+type FileResponse struct {
+	// Path is the absolute path of the file that was read.
+	Path string `json:"path"`
+	// Content is the file content as UTF-8 with LF line endings. UTF-16
+	// files are transcoded and CRLF line endings normalised.
+	Content string `json:"content"`
+	// Encoding is the file's original encoding: "utf-8", "utf-8-bom",
+	// "utf-16le", or "utf-16be".
+	Encoding string `json:"encoding"`
+	// LineEnding is the file's original line-ending style, "lf" or "crlf".
+	// Saving the file through PUT /api/file keeps it.
+	LineEnding string `json:"lineEnding"`
+}
+
+// FileSaveRequest is the JSON body for PUT /api/file.
+type FileSaveRequest struct {
+	// WorkspaceDir is the declared workspace root. The path must resolve within it.
+	WorkspaceDir string `json:"workspaceDir"`
+	// Path is the absolute path of the file to write.
+	Path string `json:"path"`
+	// Content is the new file content to write.
+	Content string `json:"content"`
+}
+
+// FileDeleteRequest is the JSON body for DELETE /api/file. The same fields
+// may be sent as query parameters instead; query parameters take precedence.
+type FileDeleteRequest struct {
+	// WorkspaceDir is the declared workspace root. The path must resolve within it.
+	WorkspaceDir string `json:"workspaceDir"`
+	// Path is the absolute path of the file to delete.
+	Path string `json:"path"`
+	// Force allows deleting terraform.tfstate, which is refused otherwise.
+	Force bool `json:"force,omitempty"`
+}
+
+// ReadyCheck holds the per-dependency result of a readiness probe.
+type ReadyCheck struct {
+	// Name is the dependency label (e.g. "ollama", "qdrant").
+	Name string `json:"name"`
+	// OK is true while the dependency is considered healthy. It turns false
+	// only after several probes in a row fail, and true again after enough
+	// succeed, so a single slow probe does not flap readiness.
+	OK bool `json:"ok"`
+	// Error contains the failure reason of the latest probe, which may be
+	// set while OK is still true. Empty when the latest probe succeeded.
+	Error string `json:"error,omitempty"`
+	// ConsecutiveFailures is the number of probes in a row that failed,
+	// including this one. Zero when the latest probe succeeded.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+	// LastTransition is when OK last changed, or when the dependency was
+	// first probed.
+	LastTransition Timestamp `json:"lastTransition"`
+}
+
+// ReadyResponse is the JSON body returned by GET /api/ready.
+type ReadyResponse struct {
+	// Ready is true only when every dependency probe succeeded.
+	Ready bool `json:"ready"`
+	// Checks contains the per-dependency probe results.
+	Checks []ReadyCheck `json:"checks"`
+}
+
+// VersionResponse is the JSON body returned by GET /api/version.
+type VersionResponse struct {
+	// Version is the semantic version of the server binary.
+	Version string `json:"version"`
+	// Commit is the git SHA the server binary was built from.
+	Commit string `json:"commit"`
+	// BuildDate is the UTC build timestamp of the server binary.
+	BuildDate string `json:"buildDate"`
+	// BasePath is the path prefix the server is mounted under, e.g. "/tfai".
+	// Empty when it is mounted at the root.
+	BasePath string `json:"basePath"`
+}
+
+// StatusResponse is the JSON body returned by GET /api/status.
+type StatusResponse struct {
+	// Tools lists the agent tools and whether each can be used.
+	Tools []ToolStatus `json:"tools"`
+	// Timeouts is the effective timeout chain of a chat request.
+	Timeouts TimeoutChain `json:"timeouts"`
+	// Loops lists the server's supervised background loops.
+	Loops []LoopStatus `json:"loops"`
+}
+
+// LoopStatus reports the health of one supervised background loop.
+type LoopStatus struct {
+	// Name identifies the loop, e.g. "ratelimit_evictor".
+	Name string `json:"name"`
+	// Running is false while the loop waits to be restarted after a
+	// failure, and once it has ended.
+	Running bool `json:"running"`
+	// Restarts counts the restarts after a panic or error.
+	Restarts int `json:"restarts"`
+	// LastRestart is when the loop was last restarted; omitted if never.
+	LastRestart *Timestamp `json:"lastRestart,omitempty"`
+	// LastError describes the last failure. It is LoopPanicked when the
+	// loop panicked; the panic value is only logged.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// LoopPanicked is the LoopStatus.LastError of a loop that panicked.
+const LoopPanicked = "panicked"
+
+// TimeoutChain lists the timeouts a chat request runs under, longest
+// first. The server refuses to start unless each is longer than the next.
+type TimeoutChain struct {
+	// WriteMs is the HTTP write timeout; a stream still running when it
+	// passes is cut off.
+	WriteMs int64 `json:"writeMs"`
+	// ChatMs is the deadline of one chat query.
+	ChatMs int64 `json:"chatMs"`
+	// ProbeMs is the deadline of each readiness probe.
+	ProbeMs int64 `json:"probeMs"`
+	// ProviderMs is the model client's timeout for a single request. Zero
+	// when the client sets none.
+	ProviderMs int64 `json:"providerMs,omitempty"`
+}
+
+// ToolStatus reports whether one agent tool is available.
+type ToolStatus struct {
+	// Name is the tool name, e.g. "terraform_plan".
+	Name string `json:"name"`
+	// Available is true when the tool is registered with the agent.
+	Available bool `json:"available"`
+	// Reason explains why an unavailable tool cannot be used.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ToolsResponse is the JSON body returned by GET /api/tools.
+type ToolsResponse struct {
+	// Tools lists every tool the agent can be given, sorted by name,
+	// including those not registered in this configuration.
+	Tools []ToolDescription `json:"tools"`
+}
+
+// ToolDescription describes one agent tool as the model sees it.
+type ToolDescription struct {
+	// Name is the tool name, e.g. "terraform_plan".
+	Name string `json:"name"`
+	// Description is the description sent to the model.
+	Description string `json:"description"`
+	// Parameters is the JSON Schema of the tool's input.
+	Parameters json.RawMessage `json:"parameters"`
+	// RequiresConfirmation is true when interactive callers ask the user
+	// before each call.
+	RequiresConfirmation bool `json:"requiresConfirmation"`
+	// Available is true when the tool is registered with the agent.
+	Available bool `json:"available"`
+	// Reason explains why an unavailable tool is not registered.
+	Reason string `json:"reason,omitempty"`
+}
+
+// HistoryMessage is one element of the JSON array returned by
+// GET /api/history.
+type HistoryMessage struct {
+	// Role is "user" or "assistant".
+	Role string `json:"role"`
+	// Kind is empty for messages. Event notes recorded by the agent set it
+	// to "files_written" (Content lists the paths, one per line), "tool_run"
+	// (Content is "<tool>: ok" or "<tool>: failed"), "disclosure",
+	// "truncated" (the message before was cut off at the output token
+	// limit), or "continued" (the message before continues the one before
+	// it).
+	Kind string `json:"kind,omitempty"`
+	// Content is the message text.
+	Content string `json:"content"`
+	// Timings is where the time of the query that produced an assistant
+	// message went. Omitted for messages stored without timings.
+	Timings *ChatTimings `json:"timings,omitempty"`
+	// CreatedAt is when the message was stored.
+	CreatedAt Timestamp `json:"createdAt"`
+}
+
+// CreateSessionRequest is the JSON body for POST /api/session.
+type CreateSessionRequest struct {
+	// WorkspaceDir is the absolute workspace directory the session belongs to.
+	WorkspaceDir string `json:"workspaceDir"`
+}
+
+// SessionResponse is the JSON body returned by POST /api/session.
+type SessionResponse struct {
+	// SessionID identifies the session in ChatRequest.SessionID and the
+	// sessionId parameter of GET /api/history.
+	SessionID string `json:"sessionId"`
+	// WorkspaceDir is the cleaned workspace directory.
+	WorkspaceDir string `json:"workspaceDir"`
+	// CreatedAt is when the session was created.
+	CreatedAt Timestamp `json:"createdAt"`
+}
+
+// ClearHistoryResponse is the JSON body returned by DELETE /api/history.
+type ClearHistoryResponse struct {
+	// Deleted is the number of messages removed.
+	Deleted int64 `json:"deleted"`
+}
+
+// WorkspaceActivity is one file-producing action in a workspace, as listed
+// by GET /api/workspace/activity and `tfai activity`.
+type WorkspaceActivity struct {
+	// ID identifies the entry; pass it as the before parameter to list the
+	// entries older than it.
+	ID int64 `json:"id"`
+	// Summary is the summary of the generated files.
+	Summary string `json:"summary"`
+	// Files lists the workspace-relative paths written.
+	Files []string `json:"files"`
+	// Conflicts maps each path the user had changed since tfai last wrote
+	// it to how the CLI resolved the conflict: "keep-mine",
+	// "take-generated", or "merge-markers". Omitted when there were none.
+	Conflicts map[string]string `json:"conflicts,omitempty"`
+	// RequestID is the HTTP request ID of the action. Omitted for the CLI.
+	RequestID string `json:"requestId,omitempty"`
+	// Model is the model or deployment name that generated the files.
+	Model string `json:"model,omitempty"`
+	// CreatedAt is when the files were written.
+	CreatedAt Timestamp `json:"createdAt"`
+}
+
+// WorkspaceActivityResponse is the JSON body returned by
+// GET /api/workspace/activity.
+type WorkspaceActivityResponse struct {
+	// Entries holds the page of activity, newest first.
+	Entries []WorkspaceActivity `json:"entries"`
+	// NextBefore is the before parameter of the next page. Omitted on the
+	// last page.
+	NextBefore int64 `json:"nextBefore,omitempty"`
+}
+
+// UsageReport is the JSON body returned by GET /api/usage/report and by
+// `tfai usage report --format json`.
+type UsageReport struct {
+	// Since is the inclusive lower bound of the report, formatted like a
+	// Timestamp, or empty when the report covers all stored history.
+	Since string `json:"since,omitempty"`
+	// GroupBy is the grouping dimension: day, workspace, or provider.
+	GroupBy string `json:"groupBy"`
+	// Groups holds one row per group, sorted by key.
+	Groups []UsageGroup `json:"groups"`
+	// Total sums every group. Its Key is "total".
+	Total UsageGroup `json:"total"`
+}
+
+// UsageGroup is one row of a UsageReport.
+type UsageGroup struct {
+	// Key identifies the group: a UTC date (2006-01-02), a workspace path,
+	// or a provider name. Messages without usage metadata are grouped under
+	// "untracked" when grouping by provider.
+	Key string `json:"key"`
+	// Requests is the number of assistant responses in the group.
+	Requests int `json:"requests"`
+	// Untracked is the number of responses with no stored usage metadata.
+	// They count towards Requests but not towards tokens or cost.
+	Untracked int `json:"untracked"`
+	// PromptTokens is the summed prompt token count.
+	PromptTokens int64 `json:"promptTokens"`
+	// CompletionTokens is the summed completion token count.
+	CompletionTokens int64 `json:"completionTokens"`
+	// EstimatedCost is the cost in dollars from the configured price table.
+	EstimatedCost float64 `json:"estimatedCost"`
+	// Unpriced is the number of tracked responses whose provider and model
+	// have no configured price; their tokens are not included in the cost.
+	Unpriced int `json:"unpriced"`
+}
+
+// SecurityReport is the JSON body returned by GET /api/security-report and
+// printed by `tfai serve --print-security-report`. It describes the
+// security-relevant parts of the resolved server configuration. It never
+// contains secret values.
+type SecurityReport struct {
+	// Listen is the host:port the server binds to.
+	Listen string `json:"listen"`
+	// Localhost is true when Listen is a loopback address.
+	Localhost bool `json:"localhost"`
+	// Auth describes API authentication.
+	Auth SecurityAuth `json:"auth"`
+	// AllowedRoots lists the directories workspace operations are confined
+	// to. Empty means workspace paths are not confined.
+	AllowedRoots []string `json:"allowedRoots"`
+	// Tools lists the agent tools and whether each can be used.
+	Tools []ToolStatus `json:"tools"`
+	// WriteActions lists the enabled operations that change files,
+	// infrastructure, or state.
+	WriteActions []string `json:"writeActions"`
+	// ReadOnly is true when write actions are refused.
+	ReadOnly bool `json:"readOnly"`
+	// RateLimit is the per-IP limit on protected routes.
+	RateLimit SecurityRateLimit `json:"rateLimit"`
+	// TLS is true when the server terminates TLS itself.
+	TLS bool `json:"tls"`
+	// CORSOrigins lists the origins allowed to make cross-origin requests.
+	CORSOrigins []string `json:"corsOrigins"`
+	// BlockSecretsOnSave is true when saving a file containing credentials
+	// is refused rather than reported.
+	BlockSecretsOnSave bool `json:"blockSecretsOnSave"`
+	// Warnings lists the risky combinations found in the configuration.
+	Warnings []SecurityWarning `json:"warnings"`
+}
+
+// SecurityAuth describes API authentication in a SecurityReport.
+type SecurityAuth struct {
+	// Enabled is true when protected routes require an API key.
+	Enabled bool `json:"enabled"`
+	// Keys is the number of configured API keys. Every key grants access
+	// to all protected routes; keys are not scoped.
+	Keys int `json:"keys"`
+	// AdminKey is true when a separate admin key guards the admin routes.
+	// It is not counted in Keys and does not enable auth on other routes.
+	AdminKey bool `json:"adminKey"`
+}
+
+// SecurityRateLimit describes the per-IP rate limit in a SecurityReport.
+type SecurityRateLimit struct {
+	// RequestsPerSecond is the sustained request rate allowed per IP.
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	// Burst is the maximum instantaneous burst per IP.
+	Burst int `json:"burst"`
+}
+
+// SecurityWarning is one risky configuration found by the security report.
+type SecurityWarning struct {
+	// Rule identifies the check, e.g. "auth_disabled_non_localhost".
+	Rule string `json:"rule"`
+	// Severity is "high" or "medium".
+	Severity string `json:"severity"`
+	// Message explains the risk and how to address it.
+	Message string `json:"message"`
+}
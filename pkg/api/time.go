@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/54b3r/tfai-go/internal/timefmt"
+)
+
+// Timestamp is a time.Time that encodes to JSON as RFC 3339 in UTC with
+// millisecond precision (see timefmt.Layout), e.g. "2024-06-01T12:00:00.000Z".
+// Every timestamp in a response uses it, so clients can sort and compare
+// them as strings.
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp returns t as a Timestamp.
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timefmt.Format(t.Time)) //nolint:wrapcheck // marshalling a string cannot fail
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Any RFC 3339 timestamp is
+// accepted.
+func (t *Timestamp) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err //nolint:wrapcheck // the decoder reports the offending field
+	}
+	parsed, err := timefmt.Parse(s)
+	if err != nil {
+		return err //nolint:wrapcheck // timefmt errors name the value
+	}
+	t.Time = parsed
+	return nil
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wireTypes lists every struct type of this package. TestNoBareTime fails
+// when a struct declared here is missing, so new types are scanned too.
+var wireTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, v := range []any{
+		AcceptedEvent{}, MetaEvent{}, InputsNeededEvent{}, ImportInput{}, ToolEvent{}, ErrorResponse{}, ChatRequest{}, ChatResponse{}, ChatUsage{}, ChatTimings{}, ToolTiming{},
+		FilesPreview{}, FilePreview{}, FilesApplyRequest{}, FilesApplyResponse{},
+		WorkspaceResponse{}, WorkspaceTreeResponse{}, TreeNode{}, WorkspaceSummaryResponse{}, LockedProvider{}, CreateWorkspaceRequest{},
+		CreateWorkspaceResponse{}, CleanWorkspaceRequest{}, CleanedArtifact{}, CleanWorkspaceResponse{},
+		FileResponse{}, FileSaveRequest{}, FileDeleteRequest{}, ReadyCheck{}, ReadyResponse{},
+		VersionResponse{}, StatusResponse{}, LoopStatus{}, TimeoutChain{}, ToolStatus{}, ToolsResponse{}, ToolDescription{}, HistoryMessage{},
+		CreateSessionRequest{}, SessionResponse{}, ClearHistoryResponse{}, UsageReport{}, UsageGroup{},
+		SecurityReport{}, SecurityAuth{}, SecurityRateLimit{}, SecurityWarning{}, Timestamp{},
+		Deprecation{}, WorkspaceActivity{}, WorkspaceActivityResponse{},
+	} {
+		t := reflect.TypeOf(v)
+		wireTypes[t.Name()] = t
+	}
+}
+
+// declaredStructs returns the names of the struct types declared in the
+// package's non-test files.
+func declaredStructs(t *testing.T) []string {
+	t.Helper()
+	pkgs, err := parser.ParseDir(token.NewFileSet(), ".", nil, 0) //nolint:staticcheck // a single directory is all we need
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, pkg := range pkgs {
+		for name, f := range pkg.Files {
+			if strings.HasSuffix(name, "_test.go") {
+				continue
+			}
+			ast.Inspect(f, func(n ast.Node) bool {
+				if ts, ok := n.(*ast.TypeSpec); ok {
+					if _, ok := ts.Type.(*ast.StructType); ok {
+						names = append(names, ts.Name.Name)
+					}
+				}
+				return true
+			})
+		}
+	}
+	return names
+}
+
+// bareTimeFields returns the paths of the fields under typ, at any depth,
+// whose type is time.Time rather than Timestamp.
+func bareTimeFields(typ reflect.Type, path string, seen map[reflect.Type]bool) []string {
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return bareTimeFields(typ.Elem(), path, seen)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		return []string{path}
+	}
+	if typ == reflect.TypeOf(Timestamp{}) || seen[typ] {
+		return nil
+	}
+	seen[typ] = true
+	var found []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		found = append(found, bareTimeFields(f.Type, path+"."+f.Name, seen)...)
+	}
+	return found
+}
+
+// ---------------------------------------------------------------------------
+// Timestamps
+// ---------------------------------------------------------------------------
+
+// TestNoBareTime fails when a wire type encodes a time.Time directly, which
+// would bypass the Timestamp format.
+func TestNoBareTime(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range declaredStructs(t) {
+		typ, ok := wireTypes[name]
+		if !ok {
+			t.Errorf("struct %s is not listed in wireTypes", name)
+			continue
+		}
+		for _, field := range bareTimeFields(typ, name, map[reflect.Type]bool{}) {
+			t.Errorf("%s is a time.Time; use Timestamp so it encodes as RFC 3339 UTC", field)
+		}
+	}
+}
+
+func TestTimestamp_JSON(t *testing.T) {
+	t.Parallel()
+
+	in := HistoryMessage{
+		Role:      "user",
+		CreatedAt: NewTimestamp(time.Date(2024, 6, 1, 14, 30, 5, 987654321, time.FixedZone("CEST", 2*60*60))),
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"role":"user","content":"","createdAt":"2024-06-01T12:30:05.987Z"}`; string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+
+	var out HistoryMessage
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.CreatedAt.Equal(in.CreatedAt.Truncate(time.Millisecond)) {
+		t.Errorf("expected %v after a round trip, got %v", in.CreatedAt.Truncate(time.Millisecond), out.CreatedAt)
+	}
+	if err := json.Unmarshal([]byte(`{"createdAt":"yesterday"}`), &out); err == nil {
+		t.Error("expected an error for a malformed timestamp")
+	}
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// EventMessage is the Event.Type of a frame carrying streamed response text.
+// The other types are the api.Event* constants.
+const EventMessage = "message"
+
+// Event is one Server-Sent Event received from POST /api/chat.
+type Event struct {
+	// Type is EventMessage for response text, or one of api.EventAccepted,
+	// api.EventMeta, api.EventPhase, api.EventToolStart, api.EventToolEnd,
+	// api.EventNotice, api.EventError, api.EventFilesWritten,
+	// api.EventInputsNeeded, or api.EventDone.
+	Type string
+	// Data is the event payload. Multi-line payloads are joined with "\n".
+	// Response text is plain; named events carry JSON (see
+	// api.ProtocolVersion).
+	Data string
+}
+
+// StreamError is returned by Chat when the server reports a failure after
+// the stream has started (for example an agent error or a chat timeout).
+type StreamError struct {
+	// Message is the error text sent in the "error" event.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *StreamError) Error() string {
+	return "client: chat stream error: " + e.Message
+}
+
+// maxSSELineBytes bounds a single SSE line. The server splits chunks on
+// newlines, so one line is at most one line of model output.
+const maxSSELineBytes = 1 << 20 // 1 MiB
+
+// Chat sends req to POST /api/chat and calls handler for every event in the
+// stream, in order. It returns nil after the "done" event, a *StreamError
+// after an "error" event, an *APIError if the server rejected the request
+// before streaming, or the context error if ctx is cancelled.
+// handler may be nil when the caller only needs the final outcome.
+// req.Stream is ignored; use ChatComplete for a single JSON response.
+func (c *Client) Chat(ctx context.Context, req api.ChatRequest, handler func(Event)) error {
+	req.Stream = nil
+	b, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("client: failed to encode chat request: %w", err)
+	}
+	httpReq, err := c.newRequest(ctx, http.MethodPost, "/api/chat", nil, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("client: POST /api/chat: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // read-only body
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+
+	if handler == nil {
+		handler = func(Event) {}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxSSELineBytes)
+
+	var (
+		eventType string
+		data      []string
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				eventType = value
+			case "data":
+				data = append(data, value)
+			}
+			continue
+		}
+
+		// A blank line dispatches the accumulated event.
+		if eventType == "" && data == nil {
+			continue
+		}
+		ev := Event{Type: eventType, Data: strings.Join(data, "\n")}
+		if ev.Type == "" {
+			ev.Type = EventMessage
+		}
+		eventType, data = "", nil
+
+		handler(ev)
+		switch ev.Type {
+		case api.EventDone:
+			return nil
+		case api.EventError:
+			return &StreamError{Message: eventText(ev.Data)}
+		}
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("client: chat stream: %w", ctx.Err())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("client: failed to read chat stream: %w", err)
+	}
+	return fmt.Errorf("client: chat stream ended without a done event")
+}
+
+// eventText decodes the JSON string data of a named event. Data that is not
+// a JSON string, as sent by servers that predate api.ProtocolVersion, is
+// returned as is.
+func eventText(data string) string {
+	var s string
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return data
+	}
+	return s
+}
+
+// ChatComplete sends req to POST /api/chat in non-streaming mode and returns
+// the buffered answer. Query failures surface as an *APIError carrying the
+// server's status (502 for provider errors, 504 for timeouts). It is never
+// retried.
+func (c *Client) ChatComplete(ctx context.Context, req api.ChatRequest) (*api.ChatResponse, error) {
+	stream := false
+	req.Stream = &stream
+	var resp api.ChatResponse
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/chat", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,455 @@
+// Package client is a Go client for the tfai HTTP API served by `tfai serve`.
+//
+// Request and response bodies use the shared types in pkg/api, so the client
+// and server cannot drift apart. Every method takes a context; cancelling it
+// aborts the in-flight request, including an open chat stream.
+//
+//	c, err := client.New("http://127.0.0.1:8080", client.WithAPIKey(key))
+//	err = c.Chat(ctx, api.ChatRequest{Message: "list my buckets"}, func(ev client.Event) {
+//		fmt.Print(ev.Data)
+//	})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// Default retry policy for idempotent GET requests.
+const (
+	// defaultMaxRetries is the number of additional attempts after the first.
+	defaultMaxRetries = 2
+	// defaultRetryBackoff is the base delay, doubled after each attempt.
+	defaultRetryBackoff = 200 * time.Millisecond
+)
+
+// Client talks to a tfai server. It is safe for concurrent use.
+type Client struct {
+	// baseURL is the server root, e.g. http://127.0.0.1:8080, including any
+	// base path, e.g. https://tools.corp/tfai.
+	baseURL *url.URL
+	// httpClient performs the requests. No client-level timeout is set by
+	// default because chat streams can run for minutes; use the context.
+	// It is a plain client rather than tfai's instrumented one, so importing
+	// this package registers no metrics and reads no tfai environment.
+	httpClient *http.Client
+	// apiKey is sent as a bearer token when non-empty.
+	apiKey string
+	// maxRetries is the number of retries for idempotent GET requests.
+	maxRetries int
+	// retryBackoff is the base delay between retries.
+	retryBackoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIKey sets the bearer token sent on every request.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithHTTPClient replaces the underlying *http.Client.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithRetries sets how many times an idempotent GET is retried after a
+// transport error or a 502/503/504 response, and the base backoff between
+// attempts. Pass 0 retries to disable. Non-GET requests are never retried.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = n
+		c.retryBackoff = backoff
+	}
+}
+
+// New constructs a Client for the server at baseURL.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL %q: %w", baseURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: base URL %q must use http or https", baseURL)
+	}
+	c := &Client{
+		baseURL:      u,
+		httpClient:   &http.Client{},
+		maxRetries:   defaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// requestIDKey is the context key for a caller-supplied request ID.
+type requestIDKey struct{}
+
+// WithRequestID returns a context that makes the client send id as the
+// X-Request-ID header, so server logs can be correlated with the caller's.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// APIError is returned when the server answers with a non-success status.
+type APIError struct {
+	// StatusCode is the HTTP status returned by the server.
+	StatusCode int
+	// Message is the server's error message, or the raw body when it was
+	// not a JSON error.
+	Message string
+	// Code is the machine-readable error code, when the server supplied one.
+	Code string
+	// RequestID is the X-Request-ID the server assigned to the request.
+	RequestID string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("client: server returned %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("client: server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Workspace lists the Terraform files in dir via GET /api/workspace.
+func (c *Client) Workspace(ctx context.Context, dir string) (*api.WorkspaceResponse, error) {
+	var resp api.WorkspaceResponse
+	if err := c.getJSON(ctx, "/api/workspace", url.Values{"workspaceDir": {dir}}, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// WorkspaceTree returns the files of dir as a nested tree with sizes and
+// modification times via GET /api/workspace/tree.
+func (c *Client) WorkspaceTree(ctx context.Context, dir string) (*api.WorkspaceTreeResponse, error) {
+	var resp api.WorkspaceTreeResponse
+	if err := c.getJSON(ctx, "/api/workspace/tree", url.Values{"workspaceDir": {dir}}, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// WorkspaceSummary returns the lock file summary of dir via
+// GET /api/workspace/summary.
+func (c *Client) WorkspaceSummary(ctx context.Context, dir string) (*api.WorkspaceSummaryResponse, error) {
+	var resp api.WorkspaceSummaryResponse
+	if err := c.getJSON(ctx, "/api/workspace/summary", url.Values{"workspaceDir": {dir}}, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateWorkspace scaffolds an existing directory via POST /api/workspace/create.
+// req.Generate is not supported, since the response is then an event stream;
+// call Chat with the returned Prompt instead.
+func (c *Client) CreateWorkspace(ctx context.Context, req api.CreateWorkspaceRequest) (*api.CreateWorkspaceResponse, error) {
+	if req.Generate {
+		return nil, fmt.Errorf("client: CreateWorkspace does not support generate; use Chat with the returned prompt")
+	}
+	var resp api.CreateWorkspaceResponse
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/workspace/create", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CleanWorkspace removes aged .tfai artifacts via POST /api/workspace/clean.
+// Set req.DryRun to only list what would be removed.
+func (c *Client) CleanWorkspace(ctx context.Context, req api.CleanWorkspaceRequest) (*api.CleanWorkspaceResponse, error) {
+	var resp api.CleanWorkspaceResponse
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/workspace/clean", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ReadFile reads path inside workspaceDir via GET /api/file.
+func (c *Client) ReadFile(ctx context.Context, workspaceDir, path string) (*api.FileResponse, error) {
+	var resp api.FileResponse
+	q := url.Values{"workspaceDir": {workspaceDir}, "path": {path}}
+	if err := c.getJSON(ctx, "/api/file", q, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SaveFile writes a file inside the workspace via PUT /api/file.
+func (c *Client) SaveFile(ctx context.Context, req api.FileSaveRequest) error {
+	return c.sendJSON(ctx, http.MethodPut, "/api/file", req, nil)
+}
+
+// DeleteFile removes a file inside the workspace via DELETE /api/file.
+func (c *Client) DeleteFile(ctx context.Context, req api.FileDeleteRequest) error {
+	return c.sendJSON(ctx, http.MethodDelete, "/api/file", req, nil)
+}
+
+// ApplyFiles writes the file envelope previewed by a chat request with
+// api.ChatRequest.PreviewFiles set, via POST /api/files/apply. token is
+// api.FilesPreview.Token.
+func (c *Client) ApplyFiles(ctx context.Context, token string) (*api.FilesApplyResponse, error) {
+	var resp api.FilesApplyResponse
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/files/apply", api.FilesApplyRequest{Token: token}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UsageReport fetches aggregated token usage via GET /api/usage/report.
+// Empty since covers all history; empty groupBy groups by day.
+func (c *Client) UsageReport(ctx context.Context, since, groupBy string) (*api.UsageReport, error) {
+	q := url.Values{}
+	if since != "" {
+		q.Set("since", since)
+	}
+	if groupBy != "" {
+		q.Set("groupBy", groupBy)
+	}
+	var resp api.UsageReport
+	if err := c.getJSON(ctx, "/api/usage/report", q, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// History returns up to limit stored conversation messages for workspaceDir,
+// oldest first, via GET /api/history. A limit of zero uses the server default.
+func (c *Client) History(ctx context.Context, workspaceDir string, limit int) ([]api.HistoryMessage, error) {
+	q := url.Values{"workspaceDir": {workspaceDir}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var resp []api.HistoryMessage
+	if err := c.getJSON(ctx, "/api/history", q, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// WorkspaceActivity returns a page of the file-producing actions recorded
+// for workspaceDir, newest first, via GET /api/workspace/activity. A limit of
+// zero uses the server default; a before of zero starts at the newest entry.
+// Pass the response's NextBefore as before to fetch the next page.
+func (c *Client) WorkspaceActivity(ctx context.Context, workspaceDir string, limit int, before int64) (*api.WorkspaceActivityResponse, error) {
+	q := url.Values{"workspaceDir": {workspaceDir}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	if before > 0 {
+		q.Set("before", strconv.FormatInt(before, 10))
+	}
+	var resp api.WorkspaceActivityResponse
+	if err := c.getJSON(ctx, "/api/workspace/activity", q, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ClearHistory deletes the stored conversation of workspaceDir via
+// DELETE /api/history and returns the number of messages deleted.
+func (c *Client) ClearHistory(ctx context.Context, workspaceDir string) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodDelete, "/api/history", url.Values{"workspaceDir": {workspaceDir}}, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("client: DELETE /api/history: %w", err)
+	}
+	var out api.ClearHistoryResponse
+	if err := decodeResponse(resp, &out, http.StatusOK); err != nil {
+		return 0, err
+	}
+	return out.Deleted, nil
+}
+
+// CreateSession starts a new conversation session for workspaceDir via
+// POST /api/session. Pass the returned ID as api.ChatRequest.SessionID.
+func (c *Client) CreateSession(ctx context.Context, workspaceDir string) (*api.SessionResponse, error) {
+	var resp api.SessionResponse
+	if err := c.sendJSON(ctx, http.MethodPost, "/api/session", api.CreateSessionRequest{WorkspaceDir: workspaceDir}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Ready probes GET /api/ready. A server that is up but has failing
+// dependencies is not an error: the response is returned with Ready false.
+func (c *Client) Ready(ctx context.Context) (*api.ReadyResponse, error) {
+	var resp api.ReadyResponse
+	if err := c.getJSON(ctx, "/api/ready", nil, &resp, http.StatusOK, http.StatusServiceUnavailable); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Version returns the server's build metadata via GET /api/version.
+func (c *Client) Version(ctx context.Context) (*api.VersionResponse, error) {
+	var resp api.VersionResponse
+	if err := c.getJSON(ctx, "/api/version", nil, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Status reports which agent tools the server has registered via
+// GET /api/status.
+func (c *Client) Status(ctx context.Context) (*api.StatusResponse, error) {
+	var resp api.StatusResponse
+	if err := c.getJSON(ctx, "/api/status", nil, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Tools lists the agent tools, with their parameter schemas and whether
+// each is registered, via GET /api/tools.
+func (c *Client) Tools(ctx context.Context) (*api.ToolsResponse, error) {
+	var resp api.ToolsResponse
+	if err := c.getJSON(ctx, "/api/tools", nil, &resp, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// getJSON performs an idempotent GET, retrying transient failures, and
+// decodes the body into out when the status is one of okStatus.
+func (c *Client) getJSON(ctx context.Context, path string, query url.Values, out any, okStatus ...int) error {
+	backoff := c.retryBackoff
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("client: GET %s: %w", path, ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		req, err := c.newRequest(ctx, http.MethodGet, path, query, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("client: GET %s: %w", path, ctx.Err())
+			}
+			lastErr = fmt.Errorf("client: GET %s: %w", path, err)
+			continue
+		}
+		err = decodeResponse(resp, out, okStatus...)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && retryableStatus(apiErr.StatusCode) {
+			lastErr = err
+			continue
+		}
+		return err
+	}
+	return lastErr
+}
+
+// sendJSON marshals body, sends it with the given method, and decodes a 200
+// response into out (which may be nil). It is never retried.
+func (c *Client) sendJSON(ctx context.Context, method, path string, body, out any) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("client: failed to encode request body: %w", err)
+	}
+	req, err := c.newRequest(ctx, method, path, nil, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("client: %s %s: %w", method, path, err)
+	}
+	return decodeResponse(resp, out, http.StatusOK)
+}
+
+// newRequest builds a request against the base URL with auth and
+// request-ID headers applied.
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := *c.baseURL
+	u.Path = strings.TrimRight(u.Path, "/") + path
+	if query != nil {
+		u.RawQuery = query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("client: failed to build request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok && id != "" {
+		req.Header.Set(api.HeaderRequestID, id)
+	}
+	return req, nil
+}
+
+// maxErrorBodyBytes caps how much of an error body is read into APIError.
+const maxErrorBodyBytes = 64 << 10 // 64 KiB
+
+// decodeResponse closes resp.Body, mapping a status outside okStatus to an
+// *APIError and otherwise decoding the JSON body into out (if non-nil).
+func decodeResponse(resp *http.Response, out any, okStatus ...int) error {
+	defer resp.Body.Close() //nolint:errcheck // read-only body
+
+	for _, s := range okStatus {
+		if resp.StatusCode == s {
+			if out == nil {
+				return nil
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("client: failed to decode response: %w", err)
+			}
+			return nil
+		}
+	}
+	return newAPIError(resp)
+}
+
+// newAPIError builds an *APIError from a failed response. It does not close
+// the body.
+func newAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(api.HeaderRequestID),
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	var body api.ErrorResponse
+	if json.Unmarshal(raw, &body) == nil && body.Error != "" {
+		apiErr.Message = body.Error
+		apiErr.Code = body.Code
+	} else {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	return apiErr
+}
+
+// retryableStatus reports whether a GET that returned status is worth retrying.
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/54b3r/tfai-go/pkg/api"
+)
+
+// End-to-end tests against the real server live in internal/server; these
+// cover client-only behaviour that needs a misbehaving server.
+
+func TestNew_InvalidBaseURL(t *testing.T) {
+	t.Parallel()
+
+	for _, raw := range []string{"", "ftp://host", "://bad"} {
+		if _, err := New(raw); err == nil {
+			t.Errorf("New(%q): expected error, got nil", raw)
+		}
+	}
+}
+
+func TestGet_RetriesTransientFailures(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":"v1.0.0"}`))
+	}))
+	t.Cleanup(ts.Close)
+
+	c, err := New(ts.URL, WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	v, err := c.Version(context.Background())
+	if err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+	if v.Version != "v1.0.0" || calls.Load() != 3 {
+		t.Errorf("expected success on third attempt, got %+v after %d calls", v, calls.Load())
+	}
+}
+
+func TestSend_NotRetried(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(ts.Close)
+
+	c, err := New(ts.URL, WithRetries(3, time.Millisecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	err = c.SaveFile(context.Background(), api.FileSaveRequest{})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 APIError, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("PUT must not be retried, got %d calls", calls.Load())
+	}
+}
+
+func TestChat_StreamWithoutDone(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: partial\n\n"))
+	}))
+	t.Cleanup(ts.Close)
+
+	c, err := New(ts.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	var got []Event
+	err = c.Chat(context.Background(), api.ChatRequest{Message: "hi"}, func(ev Event) { got = append(got, ev) })
+	if err == nil {
+		t.Fatal("expected error for truncated stream, got nil")
+	}
+	if len(got) != 1 || got[0].Data != "partial" {
+		t.Errorf("expected the partial event to be delivered, got %+v", got)
+	}
+}